@@ -20,6 +20,11 @@ var TopUpLink = ""
 // var ChatLink2 = ""
 var QuotaPerUnit = 500 * 1000.0 // $0.002 / 1K tokens
 var DisplayInCurrencyEnabled = true
+
+// 额度以货币展示时的符号、小数位数及汇率（每 1 美元对应的展示货币数量），仅影响展示
+var QuotaDisplayCurrencySymbol = "＄"
+var QuotaDisplayDecimals = 6
+var QuotaDisplayExchangeRate = 1.0
 var DisplayTokenStatEnabled = true
 var DrawingEnabled = true
 var TaskEnabled = true
@@ -116,6 +121,14 @@ var SyncFrequency int // unit is second
 
 var BatchUpdateEnabled = false
 var BatchUpdateInterval int
+var BatchUpdateSize int
+var BatchUpdateWorkers int
+
+var BatchLogInsertEnabled = false
+var BatchLogInsertSize int
+
+var ChannelTestConcurrency int
+var ChannelTestTimeout int // unit is second
 
 var RelayTimeout int // unit is second
 
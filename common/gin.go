@@ -11,10 +11,52 @@ import (
 
 const KeyRequestBody = "key_request_body"
 
+// GetRequestBody returns the request body, reading it once and caching it in
+// the context. With SPOOL_LARGE_BODIES, bodies above the threshold are cached
+// in a temp file instead and read back on each call; use GetRequestBodyReader
+// to stream them.
 func GetRequestBody(c *gin.Context) ([]byte, error) {
+	requestBody, err := cacheRequestBody(c)
+	if err != nil {
+		return nil, err
+	}
+	if spooled, ok := requestBody.(*spooledBody); ok {
+		return io.ReadAll(spooled.reader())
+	}
+	return requestBody.([]byte), nil
+}
+
+// GetRequestBodyReader returns a reader over the request body. A spooled body
+// is streamed from its temp file instead of being loaded into memory.
+func GetRequestBodyReader(c *gin.Context) (io.ReadCloser, error) {
+	requestBody, err := cacheRequestBody(c)
+	if err != nil {
+		return nil, err
+	}
+	if spooled, ok := requestBody.(*spooledBody); ok {
+		return spooled.reader(), nil
+	}
+	return bytesBodyReader{bytes.NewReader(requestBody.([]byte))}, nil
+}
+
+// bytesBodyReader keeps the Size method of bytes.Reader, which io.NopCloser
+// would hide from callers setting Content-Length.
+type bytesBodyReader struct {
+	*bytes.Reader
+}
+
+func (bytesBodyReader) Close() error {
+	return nil
+}
+
+func cacheRequestBody(c *gin.Context) (any, error) {
 	requestBody, _ := c.Get(KeyRequestBody)
-	if requestBody != nil {
-		return requestBody.([]byte), nil
+	switch requestBody.(type) {
+	case []byte, *spooledBody:
+		return requestBody, nil
+	}
+	if constant.SpoolLargeBodies && constant.SpoolBodyThreshold > 0 {
+		return readRequestBodySpooled(c)
 	}
 	requestBody, err := io.ReadAll(c.Request.Body)
 	if err != nil {
@@ -22,7 +64,7 @@ func GetRequestBody(c *gin.Context) ([]byte, error) {
 	}
 	_ = c.Request.Body.Close()
 	c.Set(KeyRequestBody, requestBody)
-	return requestBody.([]byte), nil
+	return requestBody, nil
 }
 
 func UnmarshalBodyReusable(c *gin.Context, v any) error {
@@ -41,8 +83,8 @@ func UnmarshalBodyReusable(c *gin.Context, v any) error {
 		return err
 	}
 	// Reset request body
-	c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
-	return nil
+	c.Request.Body, err = GetRequestBodyReader(c)
+	return err
 }
 
 func SetContextKey(c *gin.Context, key constant.ContextKey, value any) {
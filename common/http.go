@@ -2,6 +2,7 @@ package common
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,6 +10,40 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+var ErrResponseTooLarge = errors.New("upstream response exceeds the size limit")
+
+type limitedReadCloser struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (r *limitedReadCloser) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		// 多读一个字节以区分恰好达到上限与超出上限
+		var probe [1]byte
+		if n, _ := r.ReadCloser.Read(probe[:]); n > 0 {
+			return 0, ErrResponseTooLarge
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.remaining -= int64(n)
+	return n, err
+}
+
+// LimitResponseBody makes reads of the response body fail with
+// ErrResponseTooLarge once more than limit bytes are read. A limit <= 0
+// leaves the body unchanged.
+func LimitResponseBody(resp *http.Response, limit int64) {
+	if limit <= 0 || resp == nil || resp.Body == nil {
+		return
+	}
+	resp.Body = &limitedReadCloser{ReadCloser: resp.Body, remaining: limit}
+}
+
 func CloseResponseBodyGracefully(httpResponse *http.Response) {
 	if httpResponse == nil || httpResponse.Body == nil {
 		return
@@ -4,10 +4,12 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"one-api/constant"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -82,6 +84,14 @@ func InitEnv() {
 	// Initialize variables with GetEnvOrDefault
 	SyncFrequency = GetEnvOrDefault("SYNC_FREQUENCY", 60)
 	BatchUpdateInterval = GetEnvOrDefault("BATCH_UPDATE_INTERVAL", 5)
+	// 任一类型累积的待更新记录数达到该值时提前写入，0 表示只按间隔写入
+	BatchUpdateSize = GetEnvOrDefault("BATCH_UPDATE_SIZE", 0)
+	// 批量写入的并发数，同一 id 的更新始终由同一个 worker 按顺序写入
+	BatchUpdateWorkers = max(GetEnvOrDefault("BATCH_UPDATE_WORKERS", 1), 1)
+	BatchLogInsertEnabled = GetEnvOrDefaultBool("BATCH_LOG_INSERT", false)
+	BatchLogInsertSize = GetEnvOrDefault("BATCH_LOG_INSERT_SIZE", 100)
+	ChannelTestConcurrency = GetEnvOrDefault("CHANNEL_TEST_CONCURRENCY", 1)
+	ChannelTestTimeout = GetEnvOrDefault("CHANNEL_TEST_TIMEOUT", 60)
 	RelayTimeout = GetEnvOrDefault("RELAY_TIMEOUT", 0)
 
 	// Initialize string variables with GetEnvOrDefaultString
@@ -117,4 +127,72 @@ func initConstantEnv() {
 	constant.GenerateDefaultToken = GetEnvOrDefaultBool("GENERATE_DEFAULT_TOKEN", false)
 	// 是否启用错误日志
 	constant.ErrorLogEnabled = GetEnvOrDefaultBool("ERROR_LOG_ENABLED", false)
+	// 请求体格式正确但字段校验失败时返回的状态码，例如 422
+	constant.ValidationErrorStatus = GetEnvOrDefault("VALIDATION_ERROR_STATUS", http.StatusBadRequest)
+	// 单次请求允许的最大消息数及消息文本总长度，0 表示不限制
+	constant.MaxMessagesPerRequest = GetEnvOrDefault("MAX_MESSAGES_PER_REQUEST", 0)
+	constant.MaxMessagesContentLength = GetEnvOrDefault("MAX_MESSAGES_CONTENT_LENGTH", 0)
+	// 记录上游请求的 DNS/连接/TLS/首字节/总耗时到消费日志
+	constant.TraceUpstreamTiming = GetEnvOrDefaultBool("TRACE_UPSTREAM_TIMING", false)
+	// 计算输入 token 失败时的处理方式：error 直接报错，estimate 按字符数估算后继续
+	constant.TokenCountFailMode = GetEnvOrDefaultString("TOKEN_COUNT_FAIL_MODE", constant.TokenCountFailModeError)
+	// 单节点同时进行的上游请求上限，0 表示不限制；超出时最多排队等待的秒数，超时返回 429
+	constant.GlobalMaxConcurrency = GetEnvOrDefault("GLOBAL_MAX_CONCURRENCY", 0)
+	constant.GlobalConcurrencyWaitSeconds = GetEnvOrDefault("GLOBAL_CONCURRENCY_WAIT_SECONDS", 10)
+	// 尝试修复上游非流式响应中的畸形 JSON（尾随逗号、截断等），可能掩盖上游问题，默认关闭
+	constant.RepairUpstreamJson = GetEnvOrDefaultBool("REPAIR_UPSTREAM_JSON", false)
+	// 将每个计费请求的事件批量推送到外部分析系统，未设置 URL 时不推送
+	constant.EventSinkUrl = GetEnvOrDefaultString("EVENT_SINK_URL", "")
+	constant.EventSinkSecret = GetEnvOrDefaultString("EVENT_SINK_SECRET", "")
+	constant.EventSinkBatchSize = max(GetEnvOrDefault("EVENT_SINK_BATCH_SIZE", 100), 1)
+	constant.EventSinkFlushInterval = max(GetEnvOrDefault("EVENT_SINK_FLUSH_INTERVAL", 5), 1)
+	constant.EventSinkMaxBuffer = GetEnvOrDefault("EVENT_SINK_MAX_BUFFER", 10000)
+	// 用户与令牌均未设置分组时使用的分组，用于渠道选择及计费倍率
+	constant.DefaultGroup = GetEnvOrDefaultString("DEFAULT_GROUP", "default")
+	// 单个上游响应体的最大字节数（流式与非流式均生效），超出时中断读取，0 表示不限制
+	constant.MaxUpstreamResponseSize = int64(GetEnvOrDefault("MAX_UPSTREAM_RESPONSE_SIZE", 0))
+	// 预扣费时原子地校验并扣减用户额度，避免并发请求同时通过额度检查导致超额消费
+	constant.AtomicPreConsume = GetEnvOrDefaultBool("ATOMIC_PRE_CONSUME", false)
+	// 渠道密钥的外部密钥管理后端：vault 或 aws，为空时直接使用数据库中的密钥
+	constant.SecretsBackend = GetEnvOrDefaultString("SECRETS_BACKEND", "")
+	// 从密钥管理后端解析出的渠道密钥缓存时间（秒）
+	constant.SecretsCacheTTL = GetEnvOrDefault("SECRETS_CACHE_TTL", 300)
+	// Vault 地址与访问令牌
+	constant.VaultAddr = GetEnvOrDefaultString("VAULT_ADDR", "")
+	constant.VaultToken = GetEnvOrDefaultString("VAULT_TOKEN", "")
+	// AWS Secrets Manager 所在区域与访问凭证
+	constant.AwsSecretsRegion = GetEnvOrDefaultString("AWS_SECRETS_REGION", "")
+	constant.AwsSecretsAccessKey = GetEnvOrDefaultString("AWS_SECRETS_ACCESS_KEY", "")
+	constant.AwsSecretsSecretKey = GetEnvOrDefaultString("AWS_SECRETS_SECRET_KEY", "")
+	// 严格模式：文本请求中包含未知字段时直接返回 400，便于尽早发现客户端拼写错误
+	constant.StrictUnknownFields = GetEnvOrDefaultBool("STRICT_UNKNOWN_FIELDS", false)
+	// 超过阈值（字节）的请求体写入临时文件而非常驻内存，请求结束后删除
+	constant.SpoolLargeBodies = GetEnvOrDefaultBool("SPOOL_LARGE_BODIES", false)
+	constant.SpoolBodyThreshold = int64(GetEnvOrDefault("SPOOL_BODY_THRESHOLD", 10*1024*1024))
+	// 记录管理员对渠道、倍率、用户额度等的修改到审计日志
+	constant.AuditLogEnabled = GetEnvOrDefaultBool("AUDIT_LOG_ENABLED", true)
+	// 上游连接在收到任何响应前失败时，在同一渠道内重试的次数，用尽后再切换渠道（RETRY_TIMES）
+	constant.InChannelRetry = GetEnvOrDefault("IN_CHANNEL_RETRY", 0)
+	// 定时将新增的消费日志导出到数据仓库：webhook 或 s3（NDJSON），为空时不导出，仅主节点执行
+	constant.LogExportSink = GetEnvOrDefaultString("LOG_EXPORT_SINK", "")
+	constant.LogExportInterval = max(GetEnvOrDefault("LOG_EXPORT_INTERVAL", 300), 1)
+	constant.LogExportBatchSize = max(GetEnvOrDefault("LOG_EXPORT_BATCH_SIZE", 1000), 1)
+	// 只导出创建时间早于该秒数的日志，避免遗漏仍在批量写入中的日志
+	constant.LogExportDelay = GetEnvOrDefault("LOG_EXPORT_DELAY", 60)
+	constant.LogExportWebhookUrl = GetEnvOrDefaultString("LOG_EXPORT_WEBHOOK_URL", "")
+	constant.LogExportWebhookSecret = GetEnvOrDefaultString("LOG_EXPORT_WEBHOOK_SECRET", "")
+	// S3 存储桶、区域及访问凭证；Endpoint 可指向兼容 S3 的对象存储
+	constant.LogExportS3Bucket = GetEnvOrDefaultString("LOG_EXPORT_S3_BUCKET", "")
+	constant.LogExportS3Region = GetEnvOrDefaultString("LOG_EXPORT_S3_REGION", "us-east-1")
+	constant.LogExportS3Endpoint = GetEnvOrDefaultString("LOG_EXPORT_S3_ENDPOINT", "")
+	constant.LogExportS3Prefix = GetEnvOrDefaultString("LOG_EXPORT_S3_PREFIX", "logs")
+	constant.LogExportS3AccessKey = GetEnvOrDefaultString("LOG_EXPORT_S3_ACCESS_KEY", "")
+	constant.LogExportS3SecretKey = GetEnvOrDefaultString("LOG_EXPORT_S3_SECRET_KEY", "")
+	// 仅信任指定代理转发的客户端 IP（逗号分隔的 IP/CIDR），未设置时保持 gin 默认行为
+	constant.TrustedProxies = nil
+	for _, proxy := range strings.Split(GetEnvOrDefaultString("TRUSTED_PROXIES", ""), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			constant.TrustedProxies = append(constant.TrustedProxies, proxy)
+		}
+	}
 }
@@ -3,6 +3,8 @@ package common
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"strings"
 )
 
 func UnmarshalJson(data []byte, v any) error {
@@ -13,10 +15,29 @@ func UnmarshalJsonStr(data string, v any) error {
 	return json.Unmarshal(StringToByteSlice(data), v)
 }
 
+// UnmarshalJsonStrict is UnmarshalJson that rejects fields not present in v,
+// naming the first unknown field in the error.
+func UnmarshalJsonStrict(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return fmt.Errorf("unknown field %s in request body", field)
+		}
+		return err
+	}
+	return nil
+}
+
 func DecodeJson(reader *bytes.Reader, v any) error {
 	return json.NewDecoder(reader).Decode(v)
 }
 
+// ValidJson reports whether data is a valid JSON document.
+func ValidJson(data []byte) bool {
+	return json.Valid(data)
+}
+
 func EncodeJson(v any) ([]byte, error) {
 	return json.Marshal(v)
 }
@@ -0,0 +1,92 @@
+package common
+
+import (
+	"bytes"
+)
+
+// RepairJson tries to fix common malformations in a JSON document: a UTF-8
+// BOM, trailing commas and truncation (unterminated strings, unclosed
+// objects/arrays). It reports false when the result is still not valid JSON.
+func RepairJson(data []byte) ([]byte, bool) {
+	data = bytes.TrimPrefix(bytes.TrimSpace(data), []byte("\xef\xbb\xbf"))
+	if len(data) == 0 {
+		return nil, false
+	}
+	out := make([]byte, 0, len(data)+8)
+	var stack []byte
+	inString := false
+	escaped := false
+	// 对象中最近一个尚未跟上冒号的 key 的起始位置
+	keyStart := -1
+	for _, b := range data {
+		if inString {
+			out = append(out, b)
+			if escaped {
+				escaped = false
+			} else if b == '\\' {
+				escaped = true
+			} else if b == '"' {
+				inString = false
+			}
+			continue
+		}
+		switch b {
+		case '"':
+			inString = true
+			if last := lastNonSpace(out); len(stack) > 0 && stack[len(stack)-1] == '}' && (last == '{' || last == ',') {
+				keyStart = len(out)
+			}
+		case ':':
+			keyStart = -1
+		case '{':
+			stack = append(stack, '}')
+		case '[':
+			stack = append(stack, ']')
+		case '}', ']':
+			out = trimTrailingComma(out)
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+		out = append(out, b)
+	}
+	if inString {
+		if escaped {
+			out = out[:len(out)-1]
+		}
+		out = append(out, '"')
+	}
+	if len(stack) > 0 {
+		if keyStart >= 0 {
+			// 截断在 key 中或 key 之后，丢弃这个没有值的 key
+			out = out[:keyStart]
+		}
+		out = trimTrailingComma(out)
+		if last := lastNonSpace(out); last == ':' {
+			out = append(out, "null"...)
+		}
+		for i := len(stack) - 1; i >= 0; i-- {
+			out = append(out, stack[i])
+		}
+	}
+	if !ValidJson(out) {
+		return nil, false
+	}
+	return out, true
+}
+
+func trimTrailingComma(data []byte) []byte {
+	trimmed := bytes.TrimRight(data, " \t\r\n")
+	if len(trimmed) > 0 && trimmed[len(trimmed)-1] == ',' {
+		return trimmed[:len(trimmed)-1]
+	}
+	return data
+}
+
+func lastNonSpace(data []byte) byte {
+	trimmed := bytes.TrimRight(data, " \t\r\n")
+	if len(trimmed) == 0 {
+		return 0
+	}
+	return trimmed[len(trimmed)-1]
+}
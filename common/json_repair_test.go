@@ -0,0 +1,40 @@
+package common
+
+import "testing"
+
+func TestRepairJson(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+		ok   bool
+	}{
+		{name: "trailing comma in object", data: `{"a":1,"b":2,}`, want: `{"a":1,"b":2}`, ok: true},
+		{name: "trailing comma in array", data: `{"a":[1,2, ]}`, want: `{"a":[1,2]}`, ok: true},
+		{name: "byte order mark", data: "\xef\xbb\xbf{\"a\":1}", want: `{"a":1}`, ok: true},
+		{name: "truncated inside a string", data: `{"a":"hel`, want: `{"a":"hel"}`, ok: true},
+		{name: "truncated after an escape", data: `{"a":"x\`, want: `{"a":"x"}`, ok: true},
+		{name: "truncated inside an object", data: `{"a":{"b":1,"c":[1,2`, want: `{"a":{"b":1,"c":[1,2]}}`, ok: true},
+		{name: "truncated after a colon", data: `{"a":1,"b":`, want: `{"a":1,"b":null}`, ok: true},
+		{name: "truncated inside a key", data: `{"a":1,"b`, want: `{"a":1}`, ok: true},
+		{name: "truncated after a key", data: `{"a":1,"b" `, want: `{"a":1}`, ok: true},
+		{name: "truncated inside the first key", data: `{"ab`, want: `{}`, ok: true},
+		{name: "truncated inside a nested key", data: `{"a":{"b":1,"c`, want: `{"a":{"b":1}}`, ok: true},
+		{name: "string value in an array is kept", data: `[{"a":1},"b`, want: `[{"a":1},"b"]`, ok: true},
+		{name: "truncated inside a literal", data: `{"a":tru`},
+		{name: "missing colon", data: `{"a" 1}`},
+		{name: "not json", data: `upstream error`},
+		{name: "empty", data: ` `},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := RepairJson([]byte(tt.data))
+			if ok != tt.ok {
+				t.Fatalf("RepairJson(%s) ok = %v, want %v", tt.data, ok, tt.ok)
+			}
+			if ok && string(got) != tt.want {
+				t.Errorf("RepairJson(%s) = %s, want %s", tt.data, got, tt.want)
+			}
+		})
+	}
+}
@@ -93,9 +93,22 @@ func FatalLog(v ...any) {
 	os.Exit(1)
 }
 
+// formatQuotaCurrency converts quota to the configured display currency.
+func formatQuotaCurrency(quota int) string {
+	decimals := QuotaDisplayDecimals
+	if decimals < 0 {
+		decimals = 0
+	}
+	rate := QuotaDisplayExchangeRate
+	if rate <= 0 {
+		rate = 1
+	}
+	return fmt.Sprintf("%s%.*f", QuotaDisplayCurrencySymbol, decimals, float64(quota)/QuotaPerUnit*rate)
+}
+
 func LogQuota(quota int) string {
 	if DisplayInCurrencyEnabled {
-		return fmt.Sprintf("＄%.6f 额度", float64(quota)/QuotaPerUnit)
+		return formatQuotaCurrency(quota) + " 额度"
 	} else {
 		return fmt.Sprintf("%d 点额度", quota)
 	}
@@ -103,7 +116,7 @@ func LogQuota(quota int) string {
 
 func FormatQuota(quota int) string {
 	if DisplayInCurrencyEnabled {
-		return fmt.Sprintf("＄%.6f", float64(quota)/QuotaPerUnit)
+		return formatQuotaCurrency(quota)
 	} else {
 		return fmt.Sprintf("%d", quota)
 	}
@@ -0,0 +1,52 @@
+package common
+
+import "testing"
+
+func TestFormatQuotaCurrency(t *testing.T) {
+	savedSymbol, savedDecimals, savedRate := QuotaDisplayCurrencySymbol, QuotaDisplayDecimals, QuotaDisplayExchangeRate
+	savedPerUnit, savedInCurrency := QuotaPerUnit, DisplayInCurrencyEnabled
+	QuotaPerUnit, DisplayInCurrencyEnabled = 500000, true
+	t.Cleanup(func() {
+		QuotaDisplayCurrencySymbol, QuotaDisplayDecimals, QuotaDisplayExchangeRate = savedSymbol, savedDecimals, savedRate
+		QuotaPerUnit, DisplayInCurrencyEnabled = savedPerUnit, savedInCurrency
+	})
+
+	tests := []struct {
+		name     string
+		symbol   string
+		decimals int
+		rate     float64
+		quota    int
+		want     string
+	}{
+		{name: "default dollars", symbol: "＄", decimals: 6, rate: 1, quota: 750000, want: "＄1.500000"},
+		{name: "custom symbol and precision", symbol: "¥", decimals: 2, rate: 1, quota: 750000, want: "¥1.50"},
+		{name: "exchange rate", symbol: "¥", decimals: 2, rate: 7.2, quota: 750000, want: "¥10.80"},
+		{name: "precision rounds", symbol: "$", decimals: 2, rate: 1, quota: 1234, want: "$0.00"},
+		{name: "zero decimals", symbol: "$", decimals: 0, rate: 1, quota: 750000, want: "$2"},
+		{name: "negative decimals fall back to zero", symbol: "$", decimals: -3, rate: 1, quota: 500000, want: "$1"},
+		{name: "invalid rate falls back to one", symbol: "$", decimals: 2, rate: 0, quota: 500000, want: "$1.00"},
+		{name: "negative rate falls back to one", symbol: "$", decimals: 2, rate: -2, quota: 500000, want: "$1.00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			QuotaDisplayCurrencySymbol, QuotaDisplayDecimals, QuotaDisplayExchangeRate = tt.symbol, tt.decimals, tt.rate
+			if got := FormatQuota(tt.quota); got != tt.want {
+				t.Errorf("FormatQuota(%d) = %q, want %q", tt.quota, got, tt.want)
+			}
+			if got, want := LogQuota(tt.quota), tt.want+" 额度"; got != want {
+				t.Errorf("LogQuota(%d) = %q, want %q", tt.quota, got, want)
+			}
+		})
+	}
+
+	// 不以货币展示时忽略上述配置
+	DisplayInCurrencyEnabled = false
+	QuotaDisplayCurrencySymbol, QuotaDisplayDecimals, QuotaDisplayExchangeRate = "¥", 2, 7.2
+	if got := FormatQuota(750000); got != "750000" {
+		t.Errorf("FormatQuota() without currency = %q, want %q", got, "750000")
+	}
+	if got := LogQuota(750000); got != "750000 点额度" {
+		t.Errorf("LogQuota() without currency = %q, want %q", got, "750000 点额度")
+	}
+}
@@ -43,6 +43,13 @@ func (l *InMemoryRateLimiter) clearExpiredItems() {
 
 // Request parameter duration's unit is seconds
 func (l *InMemoryRateLimiter) Request(key string, maxRequestNum int, duration int64) bool {
+	allowed, _ := l.RequestWithRetry(key, maxRequestNum, duration)
+	return allowed
+}
+
+// RequestWithRetry is Request that also returns, when the request is
+// rejected, how long until the oldest request leaves the window.
+func (l *InMemoryRateLimiter) RequestWithRetry(key string, maxRequestNum int, duration int64) (bool, time.Duration) {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
 	// [old <-- new]
@@ -51,14 +58,14 @@ func (l *InMemoryRateLimiter) Request(key string, maxRequestNum int, duration in
 	if ok {
 		if len(*queue) < maxRequestNum {
 			*queue = append(*queue, now)
-			return true
+			return true, 0
 		} else {
 			if now-(*queue)[0] >= duration {
 				*queue = (*queue)[1:]
 				*queue = append(*queue, now)
-				return true
+				return true, 0
 			} else {
-				return false
+				return false, time.Until(time.Unix((*queue)[0]+duration, 0))
 			}
 		}
 	} else {
@@ -66,5 +73,5 @@ func (l *InMemoryRateLimiter) Request(key string, maxRequestNum int, duration in
 		l.store[key] = &s
 		*(l.store[key]) = append(*(l.store[key]), now)
 	}
-	return true
+	return true, 0
 }
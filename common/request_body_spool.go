@@ -0,0 +1,116 @@
+package common
+
+import (
+	"bytes"
+	"io"
+	"one-api/constant"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// spooledBody 写入临时文件的请求体
+type spooledBody struct {
+	path string
+	size int64
+}
+
+func (b *spooledBody) reader() *spooledBodyReader {
+	return &spooledBodyReader{path: b.path, size: b.size}
+}
+
+// spooledBodyReader 首次读取时才打开临时文件，读到末尾或关闭时释放文件句柄
+type spooledBodyReader struct {
+	path string
+	size int64
+	file *os.File
+	done bool
+}
+
+func (r *spooledBodyReader) Read(p []byte) (int, error) {
+	if r.done {
+		return 0, io.EOF
+	}
+	if r.file == nil {
+		file, err := os.Open(r.path)
+		if err != nil {
+			return 0, err
+		}
+		r.file = file
+	}
+	n, err := r.file.Read(p)
+	if err == io.EOF {
+		_ = r.Close()
+	}
+	return n, err
+}
+
+func (r *spooledBodyReader) Close() error {
+	r.done = true
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// Size returns the length of the body, so callers can set Content-Length.
+func (r *spooledBodyReader) Size() int64 {
+	return r.size
+}
+
+// readRequestBodySpooled keeps bodies up to the threshold in memory and
+// spools larger ones to a temp file, which CleanupRequestBody removes.
+func readRequestBodySpooled(c *gin.Context) (any, error) {
+	defer c.Request.Body.Close()
+	head, err := io.ReadAll(io.LimitReader(c.Request.Body, constant.SpoolBodyThreshold+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(head)) <= constant.SpoolBodyThreshold {
+		c.Set(KeyRequestBody, head)
+		return head, nil
+	}
+	file, err := os.CreateTemp("", "one-api-body-*")
+	if err != nil {
+		return nil, err
+	}
+	size, err := io.Copy(file, io.MultiReader(bytes.NewReader(head), c.Request.Body))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return nil, err
+	}
+	body := &spooledBody{path: file.Name(), size: size}
+	c.Set(KeyRequestBody, body)
+	return body, nil
+}
+
+// IsRequestBodySpooled reports whether the cached request body lives in a
+// temp file.
+func IsRequestBodySpooled(c *gin.Context) bool {
+	body, _ := c.Get(KeyRequestBody)
+	_, ok := body.(*spooledBody)
+	return ok
+}
+
+// SetRequestBody replaces the cached request body, removing the temp file of
+// the previous body if it was spooled.
+func SetRequestBody(c *gin.Context, body []byte) {
+	CleanupRequestBody(c)
+	c.Set(KeyRequestBody, body)
+}
+
+// CleanupRequestBody removes the temp file of a spooled request body.
+func CleanupRequestBody(c *gin.Context) {
+	body, _ := c.Get(KeyRequestBody)
+	if spooled, ok := body.(*spooledBody); ok {
+		if err := os.Remove(spooled.path); err != nil && !os.IsNotExist(err) {
+			SysError("failed to remove spooled request body: " + err.Error())
+		}
+		c.Set(KeyRequestBody, nil)
+	}
+}
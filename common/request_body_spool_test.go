@@ -0,0 +1,96 @@
+package common
+
+import (
+	"io"
+	"net/http/httptest"
+	"one-api/constant"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newSpoolTestContext(t *testing.T, body string) *gin.Context {
+	spoolLargeBodies, threshold := constant.SpoolLargeBodies, constant.SpoolBodyThreshold
+	constant.SpoolLargeBodies, constant.SpoolBodyThreshold = true, 8
+	t.Cleanup(func() {
+		constant.SpoolLargeBodies, constant.SpoolBodyThreshold = spoolLargeBodies, threshold
+	})
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	return c
+}
+
+func spooledBodyPath(t *testing.T, c *gin.Context) string {
+	body, _ := c.Get(KeyRequestBody)
+	spooled, ok := body.(*spooledBody)
+	if !ok {
+		t.Fatalf("request body is %T, want it spooled", body)
+	}
+	return spooled.path
+}
+
+func TestGetRequestBodyReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		spooled bool
+	}{
+		{name: "small body in memory", body: `{"a":1}`, spooled: false},
+		{name: "large body spooled", body: `{"model":"gpt-4o","stream":true}`, spooled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newSpoolTestContext(t, tt.body)
+			defer CleanupRequestBody(c)
+			for i := 0; i < 2; i++ {
+				reader, err := GetRequestBodyReader(c)
+				if err != nil {
+					t.Fatalf("GetRequestBodyReader() error = %v", err)
+				}
+				data, err := io.ReadAll(reader)
+				_ = reader.Close()
+				if err != nil || string(data) != tt.body {
+					t.Fatalf("read body = %q, %v; want %q", data, err, tt.body)
+				}
+				if size := reader.(interface{ Size() int64 }).Size(); size != int64(len(tt.body)) {
+					t.Fatalf("Size() = %d, want %d", size, len(tt.body))
+				}
+			}
+			if IsRequestBodySpooled(c) != tt.spooled {
+				t.Fatalf("IsRequestBodySpooled() = %v, want %v", IsRequestBodySpooled(c), tt.spooled)
+			}
+		})
+	}
+}
+
+func TestSetRequestBodyRemovesSpoolFile(t *testing.T) {
+	c := newSpoolTestContext(t, `{"model":"gpt-4o","messages":[]}`)
+	if _, err := GetRequestBody(c); err != nil {
+		t.Fatalf("GetRequestBody() error = %v", err)
+	}
+	path := spooledBodyPath(t, c)
+
+	SetRequestBody(c, []byte(`{"model":"gpt-4o"}`))
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("spool file still exists after SetRequestBody: %v", err)
+	}
+	body, err := GetRequestBody(c)
+	if err != nil || string(body) != `{"model":"gpt-4o"}` {
+		t.Fatalf("GetRequestBody() = %q, %v", body, err)
+	}
+}
+
+func TestCleanupRequestBody(t *testing.T) {
+	c := newSpoolTestContext(t, `{"model":"gpt-4o","messages":[]}`)
+	if _, err := GetRequestBody(c); err != nil {
+		t.Fatalf("GetRequestBody() error = %v", err)
+	}
+	path := spooledBodyPath(t, c)
+	CleanupRequestBody(c)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("spool file still exists after CleanupRequestBody: %v", err)
+	}
+}
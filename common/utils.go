@@ -144,6 +144,29 @@ func IsIP(s string) bool {
 	return ip != nil
 }
 
+func IsCIDR(s string) bool {
+	_, _, err := net.ParseCIDR(s)
+	return err == nil
+}
+
+// IsIPAllowed reports whether ip equals one of the allowed IPs or falls in
+// one of the allowed CIDR ranges.
+func IsIPAllowed(ip string, allowed map[string]any) bool {
+	if _, ok := allowed[ip]; ok {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for entry := range allowed {
+		if _, ipNet, err := net.ParseCIDR(entry); err == nil && ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
 func GetUUID() string {
 	code := uuid.New().String()
 	code = strings.Replace(code, "-", "", -1)
@@ -0,0 +1,27 @@
+package common
+
+import "testing"
+
+func TestIsIPAllowed(t *testing.T) {
+	allowed := map[string]any{"203.0.113.7": true, "10.0.0.0/8": true, "2001:db8::/32": true}
+	tests := []struct {
+		name string
+		ip   string
+		want bool
+	}{
+		{name: "listed ip", ip: "203.0.113.7", want: true},
+		{name: "unlisted ip", ip: "203.0.113.8"},
+		{name: "ip in ipv4 range", ip: "10.20.30.40", want: true},
+		{name: "ip outside ipv4 range", ip: "11.0.0.1"},
+		{name: "ip in ipv6 range", ip: "2001:db8::1", want: true},
+		{name: "ip outside ipv6 range", ip: "2001:db9::1"},
+		{name: "invalid ip", ip: "not-an-ip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsIPAllowed(tt.ip, allowed); got != tt.want {
+				t.Errorf("IsIPAllowed(%s) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,5 +1,12 @@
 package constant
 
-import "time"
+import (
+	"regexp"
+	"time"
+)
 
 var AzureNoRemoveDotTime = time.Date(2025, time.May, 10, 0, 0, 0, 0, time.UTC).Unix()
+
+// AzureAPIVersionRegex matches api-version values such as 2024-10-21,
+// 2025-04-01-preview, preview and latest.
+var AzureAPIVersionRegex = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}(-preview)?|preview|latest)$`)
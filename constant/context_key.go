@@ -4,7 +4,13 @@ type ContextKey string
 
 const (
 	ContextKeyOriginalModel    ContextKey = "original_model"
+	ContextKeyRequestModel     ContextKey = "request_model"
 	ContextKeyRequestStartTime ContextKey = "request_start_time"
+	ContextKeyABTest           ContextKey = "ab_test"
+	ContextKeyABVariant        ContextKey = "ab_variant"
+	ContextKeyDowngradedFrom   ContextKey = "downgraded_from"
+	// 上游连接在收到任何响应前失败（连接被拒绝或重置），可在同一渠道内重试
+	ContextKeyUpstreamConnectionFailed ContextKey = "upstream_connection_failed"
 
 	/* token related keys */
 	ContextKeyTokenUnlimited         ContextKey = "token_unlimited_quota"
@@ -21,6 +27,7 @@ const (
 	ContextKeyChannelType    ContextKey = "channel_type"
 	ContextKeyChannelId      ContextKey = "channel_id"
 	ContextKeyChannelSetting ContextKey = "channel_setting"
+	ContextKeyChannelKey     ContextKey = "channel_key"
 	ContextKeyParamOverride  ContextKey = "param_override"
 
 	/* user related keys */
@@ -32,4 +39,6 @@ const (
 	ContextKeyUserGroup   ContextKey = "user_group"
 	ContextKeyUsingGroup  ContextKey = "group"
 	ContextKeyUserName    ContextKey = "username"
+
+	ContextKeyUserRateLimitTier ContextKey = "user_rate_limit_tier"
 )
@@ -13,3 +13,59 @@ var NotifyLimitCount int
 var NotificationLimitDurationMinute int
 var GenerateDefaultToken bool
 var ErrorLogEnabled bool
+var ValidationErrorStatus int
+var MaxMessagesPerRequest int
+var MaxMessagesContentLength int
+var TraceUpstreamTiming bool
+var TokenCountFailMode string
+var GlobalMaxConcurrency int
+var GlobalConcurrencyWaitSeconds int
+var RepairUpstreamJson bool
+var EventSinkUrl string
+var EventSinkSecret string
+var EventSinkBatchSize int
+var EventSinkFlushInterval int // unit is second
+var EventSinkMaxBuffer int
+var DefaultGroup string
+var MaxUpstreamResponseSize int64
+var AtomicPreConsume bool
+var SecretsBackend string
+var SecretsCacheTTL int // unit is second
+var VaultAddr string
+var VaultToken string
+var AwsSecretsRegion string
+var AwsSecretsAccessKey string
+var AwsSecretsSecretKey string
+var StrictUnknownFields bool
+var SpoolLargeBodies bool
+var SpoolBodyThreshold int64
+var AuditLogEnabled bool
+var InChannelRetry int
+var LogExportSink string
+var LogExportInterval int // unit is second
+var LogExportBatchSize int
+var LogExportDelay int // unit is second
+var LogExportWebhookUrl string
+var LogExportWebhookSecret string
+var LogExportS3Bucket string
+var LogExportS3Region string
+var LogExportS3Endpoint string
+var LogExportS3Prefix string
+var LogExportS3AccessKey string
+var LogExportS3SecretKey string
+var TrustedProxies []string
+
+const (
+	SecretsBackendVault = "vault"
+	SecretsBackendAws   = "aws"
+)
+
+const (
+	LogExportSinkWebhook = "webhook"
+	LogExportSinkS3      = "s3"
+)
+
+const (
+	TokenCountFailModeError    = "error"
+	TokenCountFailModeEstimate = "estimate"
+)
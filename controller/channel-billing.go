@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -125,6 +126,13 @@ func GetAuthHeader(token string) http.Header {
 	return h
 }
 
+// getChannelApiKey returns the channel's first key with a secret:
+// reference resolved, for the balance queries that talk to the upstream
+// directly instead of going through the relay.
+func getChannelApiKey(channel *model.Channel) (string, error) {
+	return service.ResolveChannelKey(context.Background(), channel.GetKeys()[0])
+}
+
 func GetResponseBody(method, url string, channel *model.Channel, headers http.Header) ([]byte, error) {
 	req, err := http.NewRequest(method, url, nil)
 	if err != nil {
@@ -152,8 +160,12 @@ func GetResponseBody(method, url string, channel *model.Channel, headers http.He
 }
 
 func updateChannelCloseAIBalance(channel *model.Channel) (float64, error) {
+	key, err := getChannelApiKey(channel)
+	if err != nil {
+		return 0, err
+	}
 	url := fmt.Sprintf("%s/dashboard/billing/credit_grants", channel.GetBaseURL())
-	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(channel.Key))
+	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(key))
 
 	if err != nil {
 		return 0, err
@@ -168,8 +180,12 @@ func updateChannelCloseAIBalance(channel *model.Channel) (float64, error) {
 }
 
 func updateChannelOpenAISBBalance(channel *model.Channel) (float64, error) {
-	url := fmt.Sprintf("https://api.openai-sb.com/sb-api/user/status?api_key=%s", channel.Key)
-	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(channel.Key))
+	key, err := getChannelApiKey(channel)
+	if err != nil {
+		return 0, err
+	}
+	url := fmt.Sprintf("https://api.openai-sb.com/sb-api/user/status?api_key=%s", key)
+	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(key))
 	if err != nil {
 		return 0, err
 	}
@@ -190,9 +206,13 @@ func updateChannelOpenAISBBalance(channel *model.Channel) (float64, error) {
 }
 
 func updateChannelAIProxyBalance(channel *model.Channel) (float64, error) {
+	key, err := getChannelApiKey(channel)
+	if err != nil {
+		return 0, err
+	}
 	url := "https://aiproxy.io/api/report/getUserOverview"
 	headers := http.Header{}
-	headers.Add("Api-Key", channel.Key)
+	headers.Add("Api-Key", key)
 	body, err := GetResponseBody("GET", url, channel, headers)
 	if err != nil {
 		return 0, err
@@ -210,8 +230,12 @@ func updateChannelAIProxyBalance(channel *model.Channel) (float64, error) {
 }
 
 func updateChannelAPI2GPTBalance(channel *model.Channel) (float64, error) {
+	key, err := getChannelApiKey(channel)
+	if err != nil {
+		return 0, err
+	}
 	url := "https://api.api2gpt.com/dashboard/billing/credit_grants"
-	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(channel.Key))
+	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(key))
 
 	if err != nil {
 		return 0, err
@@ -226,8 +250,12 @@ func updateChannelAPI2GPTBalance(channel *model.Channel) (float64, error) {
 }
 
 func updateChannelSiliconFlowBalance(channel *model.Channel) (float64, error) {
+	key, err := getChannelApiKey(channel)
+	if err != nil {
+		return 0, err
+	}
 	url := "https://api.siliconflow.cn/v1/user/info"
-	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(channel.Key))
+	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(key))
 	if err != nil {
 		return 0, err
 	}
@@ -248,8 +276,12 @@ func updateChannelSiliconFlowBalance(channel *model.Channel) (float64, error) {
 }
 
 func updateChannelDeepSeekBalance(channel *model.Channel) (float64, error) {
+	key, err := getChannelApiKey(channel)
+	if err != nil {
+		return 0, err
+	}
 	url := "https://api.deepseek.com/user/balance"
-	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(channel.Key))
+	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(key))
 	if err != nil {
 		return 0, err
 	}
@@ -277,8 +309,12 @@ func updateChannelDeepSeekBalance(channel *model.Channel) (float64, error) {
 }
 
 func updateChannelAIGC2DBalance(channel *model.Channel) (float64, error) {
+	key, err := getChannelApiKey(channel)
+	if err != nil {
+		return 0, err
+	}
 	url := "https://api.aigc2d.com/dashboard/billing/credit_grants"
-	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(channel.Key))
+	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(key))
 	if err != nil {
 		return 0, err
 	}
@@ -292,8 +328,12 @@ func updateChannelAIGC2DBalance(channel *model.Channel) (float64, error) {
 }
 
 func updateChannelOpenRouterBalance(channel *model.Channel) (float64, error) {
+	key, err := getChannelApiKey(channel)
+	if err != nil {
+		return 0, err
+	}
 	url := "https://openrouter.ai/api/v1/credits"
-	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(channel.Key))
+	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(key))
 	if err != nil {
 		return 0, err
 	}
@@ -308,8 +348,12 @@ func updateChannelOpenRouterBalance(channel *model.Channel) (float64, error) {
 }
 
 func updateChannelMoonshotBalance(channel *model.Channel) (float64, error) {
+	key, err := getChannelApiKey(channel)
+	if err != nil {
+		return 0, err
+	}
 	url := "https://api.moonshot.cn/v1/users/me/balance"
-	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(channel.Key))
+	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(key))
 	if err != nil {
 		return 0, err
 	}
@@ -374,9 +418,13 @@ func updateChannelBalance(channel *model.Channel) (float64, error) {
 	default:
 		return 0, errors.New("尚未实现")
 	}
+	key, err := getChannelApiKey(channel)
+	if err != nil {
+		return 0, err
+	}
 	url := fmt.Sprintf("%s/v1/dashboard/billing/subscription", baseURL)
 
-	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(channel.Key))
+	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(key))
 	if err != nil {
 		return 0, err
 	}
@@ -392,7 +440,7 @@ func updateChannelBalance(channel *model.Channel) (float64, error) {
 		startDate = now.AddDate(0, 0, -100).Format("2006-01-02")
 	}
 	url = fmt.Sprintf("%s/v1/dashboard/billing/usage?start_date=%s&end_date=%s", baseURL, startDate, endDate)
-	body, err = GetResponseBody("GET", url, channel, GetAuthHeader(channel.Key))
+	body, err = GetResponseBody("GET", url, channel, GetAuthHeader(key))
 	if err != nil {
 		return 0, err
 	}
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -29,7 +30,7 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func testChannel(channel *model.Channel, testModel string) (err error, openAIErrorWithStatusCode *dto.OpenAIErrorWithStatusCode) {
+func testChannel(ctx context.Context, channel *model.Channel, testModel string) (err error, openAIErrorWithStatusCode *dto.OpenAIErrorWithStatusCode) {
 	tik := time.Now()
 	if channel.Type == constant.ChannelTypeMidjourney {
 		return errors.New("midjourney channel test is not supported"), nil
@@ -66,6 +67,7 @@ func testChannel(channel *model.Channel, testModel string) (err error, openAIErr
 		Body:   nil,
 		Header: make(http.Header),
 	}
+	c.Request = c.Request.WithContext(ctx)
 
 	if testModel == "" {
 		if channel.TestModel != nil && *channel.TestModel != "" {
@@ -85,14 +87,16 @@ func testChannel(channel *model.Channel, testModel string) (err error, openAIErr
 	}
 	cache.WriteContext(c)
 
-	c.Request.Header.Set("Authorization", "Bearer "+channel.Key)
 	c.Request.Header.Set("Content-Type", "application/json")
 	c.Set("channel", channel.Type)
 	c.Set("base_url", channel.GetBaseURL())
 	group, _ := model.GetUserGroup(1, false)
 	c.Set("group", group)
 
-	middleware.SetupContextForSelectedChannel(c, channel, testModel)
+	err = middleware.SetupContextForSelectedChannel(c, channel, testModel)
+	if err != nil {
+		return err, nil
+	}
 
 	info := relaycommon.GenRelayInfo(c)
 
@@ -246,7 +250,7 @@ func TestChannel(c *gin.Context) {
 	}
 	testModel := c.Query("model")
 	tik := time.Now()
-	err, _ = testChannel(channel, testModel)
+	err, _ = testChannel(c.Request.Context(), channel, testModel)
 	tok := time.Now()
 	milliseconds := tok.Sub(tik).Milliseconds()
 	go channel.UpdateResponseTime(milliseconds)
@@ -295,40 +299,25 @@ func testAllChannels(notify bool) error {
 			testAllChannelsLock.Unlock()
 		}()
 
+		concurrency := common.ChannelTestConcurrency
+		if concurrency <= 0 {
+			concurrency = 1
+		}
+		sem := make(chan struct{}, concurrency)
+		var wg sync.WaitGroup
 		for _, channel := range channels {
-			isChannelEnabled := channel.Status == common.ChannelStatusEnabled
-			tik := time.Now()
-			err, openaiWithStatusErr := testChannel(channel, "")
-			tok := time.Now()
-			milliseconds := tok.Sub(tik).Milliseconds()
-
-			shouldBanChannel := false
-
-			// request error disables the channel
-			if openaiWithStatusErr != nil {
-				oaiErr := openaiWithStatusErr.Error
-				err = errors.New(fmt.Sprintf("type %s, httpCode %d, code %v, message %s", oaiErr.Type, openaiWithStatusErr.StatusCode, oaiErr.Code, oaiErr.Message))
-				shouldBanChannel = service.ShouldDisableChannel(channel.Type, openaiWithStatusErr)
-			}
-
-			if milliseconds > disableThreshold {
-				err = errors.New(fmt.Sprintf("响应时间 %.2fs 超过阈值 %.2fs", float64(milliseconds)/1000.0, float64(disableThreshold)/1000.0))
-				shouldBanChannel = true
-			}
-
-			// disable channel
-			if isChannelEnabled && shouldBanChannel && channel.GetAutoBan() {
-				service.DisableChannel(channel.Id, channel.Name, err.Error())
-			}
-
-			// enable channel
-			if !isChannelEnabled && service.ShouldEnableChannel(err, openaiWithStatusErr, channel.Status) {
-				service.EnableChannel(channel.Id, channel.Name)
-			}
-
-			channel.UpdateResponseTime(milliseconds)
+			sem <- struct{}{}
+			wg.Add(1)
+			gopool.Go(func() {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				testChannelAndUpdateStatus(channel, disableThreshold)
+			})
 			time.Sleep(common.RequestInterval)
 		}
+		wg.Wait()
 
 		if notify {
 			service.NotifyRootUser(dto.NotifyTypeChannelTest, "通道测试完成", "所有通道测试已完成")
@@ -337,6 +326,55 @@ func testAllChannels(notify bool) error {
 	return nil
 }
 
+// testChannelWithTimeout cancels a channel test after CHANNEL_TEST_TIMEOUT
+// seconds so a hung upstream does not hold a worker.
+func testChannelWithTimeout(channel *model.Channel) (error, *dto.OpenAIErrorWithStatusCode) {
+	if common.ChannelTestTimeout <= 0 {
+		return testChannel(context.Background(), channel, "")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(common.ChannelTestTimeout)*time.Second)
+	defer cancel()
+	err, openaiErr := testChannel(ctx, channel, "")
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("测试超时，超过 %d 秒未响应", common.ChannelTestTimeout), nil
+	}
+	return err, openaiErr
+}
+
+func testChannelAndUpdateStatus(channel *model.Channel, disableThreshold int64) {
+	isChannelEnabled := channel.Status == common.ChannelStatusEnabled
+	tik := time.Now()
+	err, openaiWithStatusErr := testChannelWithTimeout(channel)
+	tok := time.Now()
+	milliseconds := tok.Sub(tik).Milliseconds()
+
+	shouldBanChannel := false
+
+	// request error disables the channel
+	if openaiWithStatusErr != nil {
+		oaiErr := openaiWithStatusErr.Error
+		err = errors.New(fmt.Sprintf("type %s, httpCode %d, code %v, message %s", oaiErr.Type, openaiWithStatusErr.StatusCode, oaiErr.Code, oaiErr.Message))
+		shouldBanChannel = service.ShouldDisableChannel(channel.Type, openaiWithStatusErr)
+	}
+
+	if milliseconds > disableThreshold {
+		err = errors.New(fmt.Sprintf("响应时间 %.2fs 超过阈值 %.2fs", float64(milliseconds)/1000.0, float64(disableThreshold)/1000.0))
+		shouldBanChannel = true
+	}
+
+	// disable channel
+	if isChannelEnabled && shouldBanChannel && channel.GetAutoBan() {
+		service.DisableChannel(channel.Id, channel.Name, err.Error())
+	}
+
+	// enable channel
+	if !isChannelEnabled && service.ShouldEnableChannel(err, openaiWithStatusErr, channel.Status) {
+		service.EnableChannel(channel.Id, channel.Name)
+	}
+
+	channel.UpdateResponseTime(milliseconds)
+}
+
 func TestAllChannels(c *gin.Context) {
 	err := testAllChannels(true)
 	if err != nil {
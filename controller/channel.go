@@ -7,6 +7,7 @@ import (
 	"one-api/common"
 	"one-api/constant"
 	"one-api/model"
+	"one-api/service"
 	"strconv"
 	"strings"
 
@@ -149,6 +150,7 @@ func GetAllChannels(c *gin.Context) {
 		typeCounts[r.Type] = r.Count
 	}
 
+	model.MaskChannelsSecretSettings(channelData)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -193,7 +195,15 @@ func FetchUpstreamModels(c *gin.Context) {
 	case constant.ChannelTypeAli:
 		url = fmt.Sprintf("%s/compatible-mode/v1/models", baseURL)
 	}
-	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(channel.Key))
+	key, err := getChannelApiKey(channel)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(key))
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -342,6 +352,7 @@ func SearchChannels(c *gin.Context) {
 	}
 
 	pagedData := channelData[startIdx:endIdx]
+	model.MaskChannelsSecretSettings(pagedData)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -372,6 +383,7 @@ func GetChannel(c *gin.Context) {
 		})
 		return
 	}
+	channel.MaskSecretSettings()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -380,6 +392,31 @@ func GetChannel(c *gin.Context) {
 	return
 }
 
+// GetChannelKeyHealth 返回多密钥渠道中每个密钥的冷却状态
+func GetChannelKeyHealth(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	channel, err := model.GetChannelById(id, true)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    model.GetChannelKeyHealth(channel),
+	})
+}
+
 func AddChannel(c *gin.Context) {
 	channel := model.Channel{}
 	err := c.ShouldBindJSON(&channel)
@@ -450,6 +487,9 @@ func AddChannel(c *gin.Context) {
 		})
 		return
 	}
+	for i := range channels {
+		service.RecordAudit(c, service.AuditActionChannelCreate, service.AuditTargetChannel, channels[i].Id, nil, &channels[i])
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -459,6 +499,7 @@ func AddChannel(c *gin.Context) {
 
 func DeleteChannel(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
+	origin, _ := model.GetChannelById(id, true)
 	channel := model.Channel{Id: id}
 	err := channel.Delete()
 	if err != nil {
@@ -468,6 +509,11 @@ func DeleteChannel(c *gin.Context) {
 		})
 		return
 	}
+	service.RecordAudit(c, service.AuditActionChannelDelete, service.AuditTargetChannel, id, origin, nil)
+	if origin != nil {
+		service.EvictChannelCertClient(origin.GetSetting())
+	}
+	model.ResetChannelKeyStates(id)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -476,6 +522,7 @@ func DeleteChannel(c *gin.Context) {
 }
 
 func DeleteDisabledChannel(c *gin.Context) {
+	disabledSettings, _ := model.GetDisabledChannelSettings()
 	rows, err := model.DeleteDisabledChannel()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -484,6 +531,10 @@ func DeleteDisabledChannel(c *gin.Context) {
 		})
 		return
 	}
+	service.RecordAudit(c, service.AuditActionChannelDelete, service.AuditTargetChannel, "disabled", gin.H{"deleted_count": rows}, nil)
+	for _, setting := range disabledSettings {
+		service.EvictChannelCertClient(setting)
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -599,6 +650,10 @@ func DeleteChannelBatch(c *gin.Context) {
 		})
 		return
 	}
+	origins := make(map[int]*model.Channel, len(channelBatch.Ids))
+	for _, id := range channelBatch.Ids {
+		origins[id], _ = model.GetChannelById(id, true)
+	}
 	err = model.BatchDeleteChannels(channelBatch.Ids)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -607,6 +662,13 @@ func DeleteChannelBatch(c *gin.Context) {
 		})
 		return
 	}
+	for _, id := range channelBatch.Ids {
+		service.RecordAudit(c, service.AuditActionChannelDelete, service.AuditTargetChannel, id, origins[id], nil)
+		if origins[id] != nil {
+			service.EvictChannelCertClient(origins[id].GetSetting())
+		}
+		model.ResetChannelKeyStates(id)
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -625,6 +687,8 @@ func UpdateChannel(c *gin.Context) {
 		})
 		return
 	}
+	origin, _ := model.GetChannelById(channel.Id, true)
+	channel.RestoreMaskedSecretSettings(origin)
 	err = channel.ValidateSettings()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -662,7 +726,13 @@ func UpdateChannel(c *gin.Context) {
 		})
 		return
 	}
+	service.RecordAudit(c, service.AuditActionChannelUpdate, service.AuditTargetChannel, channel.Id, origin, &channel)
+	if origin != nil {
+		service.EvictChannelCertClient(origin.GetSetting())
+	}
+	model.ResetChannelKeyStates(channel.Id)
 	channel.Key = ""
+	channel.MaskSecretSettings()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"one-api/service"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestUpdateChannelRecordsAuditDiff(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sqlite handle: %v", err)
+	}
+	// 内存数据库每个连接相互独立，限制为单连接以共享同一份数据
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&model.Channel{}, &model.Ability{}, &model.AuditLog{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	mainDB, auditLogEnabled := model.DB, constant.AuditLogEnabled
+	model.DB, constant.AuditLogEnabled = db, true
+	t.Cleanup(func() { model.DB, constant.AuditLogEnabled = mainDB, auditLogEnabled })
+
+	channel := &model.Channel{Id: 1, Name: "old name", Type: constant.ChannelTypeOpenAI, Key: "sk-old",
+		Status: common.ChannelStatusEnabled, Group: "default", Models: "gpt-4o"}
+	if err := channel.Insert(); err != nil {
+		t.Fatalf("insert channel: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPut, "/api/channel/", strings.NewReader(
+		`{"id":1,"name":"new name","type":1,"key":"sk-new","group":"default","models":"gpt-4o"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("id", 9)
+	c.Set("username", "root")
+	UpdateChannel(c)
+	if !strings.Contains(recorder.Body.String(), `"success":true`) {
+		t.Fatalf("UpdateChannel response = %s", recorder.Body.String())
+	}
+
+	var logs []model.AuditLog
+	if err := db.Find(&logs).Error; err != nil {
+		t.Fatalf("load audit logs: %v", err)
+	}
+	if len(logs) != 1 {
+		t.Fatalf("audit logs = %d, want 1", len(logs))
+	}
+	entry := logs[0]
+	if entry.Action != service.AuditActionChannelUpdate || entry.TargetType != service.AuditTargetChannel || entry.TargetId != "1" ||
+		entry.ActorId != 9 || entry.ActorName != "root" {
+		t.Errorf("audit entry = %+v, want channel.update of channel 1 by root", entry)
+	}
+	var diff map[string]service.AuditDiffEntry
+	if err := json.Unmarshal([]byte(entry.Diff), &diff); err != nil {
+		t.Fatalf("decode diff %q: %v", entry.Diff, err)
+	}
+	if name := diff["name"]; name.Before != "old name" || name.After != "new name" {
+		t.Errorf("name diff = %+v, want old name -> new name", name)
+	}
+	// 密钥变更仍出现在差异中，但不泄露内容
+	if key, ok := diff["key"]; !ok || key.Before != "***" || key.After != "***" {
+		t.Errorf("key diff = %+v, want a redacted change", key)
+	}
+	if _, ok := diff["models"]; ok {
+		t.Error("unchanged models appear in the diff")
+	}
+	for _, stored := range []string{entry.Before, entry.After, entry.Diff} {
+		if strings.Contains(stored, "sk-old") || strings.Contains(stored, "sk-new") {
+			t.Errorf("audit entry leaks the channel key: %s", stored)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting/ratio_setting"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTestAllChannelsBoundsConcurrencyAndDeadline(t *testing.T) {
+	// 渠道测试按列名查询用户分组，通过 InitDB 初始化列名并迁移数据表
+	t.Setenv("SQL_DSN", "")
+	mainDB, sqlitePath, usingSQLite := model.DB, common.SQLitePath, common.UsingSQLite
+	redisEnabled, isMasterNode := common.RedisEnabled, common.IsMasterNode
+	concurrency, timeout, interval := common.ChannelTestConcurrency, common.ChannelTestTimeout, common.RequestInterval
+	common.SQLitePath, common.RedisEnabled, common.IsMasterNode = filepath.Join(t.TempDir(), "one-api.db"), false, true
+	common.ChannelTestConcurrency, common.ChannelTestTimeout, common.RequestInterval = 2, 1, 0
+	t.Cleanup(func() {
+		model.DB, common.SQLitePath, common.UsingSQLite = mainDB, sqlitePath, usingSQLite
+		common.RedisEnabled, common.IsMasterNode = redisEnabled, isMasterNode
+		common.ChannelTestConcurrency, common.ChannelTestTimeout, common.RequestInterval = concurrency, timeout, interval
+	})
+	if err := model.InitDB(); err != nil {
+		t.Fatalf("init db: %v", err)
+	}
+	db := model.DB
+	service.InitHttpClient()
+	ratio_setting.InitRatioSettings()
+
+	// 上游一直不响应，直到测试的截止时间取消请求
+	var mu sync.Mutex
+	var arrivals []time.Time
+	cancelled := make(chan struct{}, 8)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 读完请求体后服务端才能感知客户端断开
+		_, _ = io.Copy(io.Discard, r.Body)
+		mu.Lock()
+		arrivals = append(arrivals, time.Now())
+		mu.Unlock()
+		select {
+		case <-r.Context().Done():
+			cancelled <- struct{}{}
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	if err := db.Create(&model.User{Id: 1, Username: "root", AffCode: "root", Role: common.RoleRootUser,
+		Status: common.UserStatusEnabled, Group: "default"}).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	const channelCount = 4
+	baseURL := server.URL
+	for i := 1; i <= channelCount; i++ {
+		channel := &model.Channel{Id: i, Name: fmt.Sprintf("hung %d", i), Type: constant.ChannelTypeOpenAI, Key: "sk-test",
+			Status: common.ChannelStatusEnabled, Group: "default", Models: "gpt-4o-mini", BaseURL: &baseURL}
+		if err := db.Create(channel).Error; err != nil {
+			t.Fatalf("create channel: %v", err)
+		}
+	}
+
+	start := time.Now()
+	if err := testAllChannels(false); err != nil {
+		t.Fatalf("testAllChannels() error = %v", err)
+	}
+	for {
+		testAllChannelsLock.Lock()
+		running := testAllChannelsRunning
+		testAllChannelsLock.Unlock()
+		if !running {
+			break
+		}
+		if time.Since(start) > 10*time.Second {
+			t.Fatal("channel tests did not finish before the deadline")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	elapsed := time.Since(start)
+
+	// 4 个渠道、并发 2、每个 1 秒超时，约两轮即可完成
+	if elapsed < 2*time.Second || elapsed > 4*time.Second {
+		t.Errorf("channel tests took %v, want about two rounds of the 1s deadline", elapsed)
+	}
+	for i := 0; i < channelCount; i++ {
+		select {
+		case <-cancelled:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%d of %d upstream requests were cancelled at the deadline", i, channelCount)
+		}
+	}
+	mu.Lock()
+	if len(arrivals) != channelCount {
+		t.Fatalf("upstream requests = %d, want %d", len(arrivals), channelCount)
+	}
+	// 第一轮之后的请求要等前面的测试超时才会发出
+	firstRound := 0
+	for _, arrival := range arrivals {
+		if arrival.Sub(start) < 500*time.Millisecond {
+			firstRound++
+		}
+	}
+	if firstRound != common.ChannelTestConcurrency {
+		t.Errorf("channel tests started before the first deadline = %d, want %d", firstRound, common.ChannelTestConcurrency)
+	}
+	mu.Unlock()
+
+	channel, err := model.GetChannelById(1, true)
+	if err != nil {
+		t.Fatalf("get channel: %v", err)
+	}
+	start = time.Now()
+	err, _ = testChannelWithTimeout(channel)
+	if err == nil || !strings.Contains(err.Error(), "测试超时") {
+		t.Errorf("testChannelWithTimeout() error = %v, want the timeout error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("testChannelWithTimeout() returned after %v, want the 1s deadline", elapsed)
+	}
+}
@@ -90,7 +90,13 @@ func UpdateMidjourneyTaskBulk() {
 			// 使用带有超时的 context 创建新的请求
 			req = req.WithContext(ctx)
 			req.Header.Set("Content-Type", "application/json")
-			req.Header.Set("mj-api-secret", midjourneyChannel.Key)
+			key, err := service.ResolveChannelKey(ctx, midjourneyChannel.Key)
+			if err != nil {
+				cancel()
+				common.LogError(ctx, fmt.Sprintf("Get Task channel key error: %v", err))
+				continue
+			}
+			req.Header.Set("mj-api-secret", key)
 			resp, err := service.GetHttpClient().Do(req)
 			if err != nil {
 				common.LogError(ctx, fmt.Sprintf("Get Task Do req error: %v", err))
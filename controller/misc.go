@@ -8,6 +8,7 @@ import (
 	"one-api/constant"
 	"one-api/middleware"
 	"one-api/model"
+	"one-api/service"
 	"one-api/setting"
 	"one-api/setting/console_setting"
 	"one-api/setting/operation_setting"
@@ -36,6 +37,14 @@ func TestStatus(c *gin.Context) {
 	return
 }
 
+func GetLiveStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    service.GetLiveStats(),
+	})
+}
+
 func GetStatus(c *gin.Context) {
 
 	cs := console_setting.GetConsoleSetting()
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/samber/lo"
@@ -16,6 +17,8 @@ import (
 	"one-api/relay/channel/moonshot"
 	relaycommon "one-api/relay/common"
 	"one-api/setting"
+	"sort"
+	"strings"
 )
 
 // https://platform.openai.com/docs/api-reference/models/list
@@ -171,12 +174,41 @@ func ListModels(c *gin.Context) {
 			}
 		}
 	}
-	c.JSON(200, gin.H{
+	// 令牌模型限制是 map、分组模型来自缓存，排序后列表顺序稳定，ETag 才不会随机变化
+	sort.Slice(userOpenAiModels, func(i, j int) bool {
+		return userOpenAiModels[i].Id < userOpenAiModels[j].Id
+	})
+	writeJSONWithETag(c, gin.H{
 		"success": true,
 		"data":    userOpenAiModels,
 	})
 }
 
+// writeJSONWithETag writes the JSON response with an ETag computed from its
+// content, and answers 304 when the client already holds the same content.
+// Channel or option changes alter the content and therefore the ETag.
+func writeJSONWithETag(c *gin.Context, data any) {
+	body, err := common.EncodeJson(data)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	etag := fmt.Sprintf(`"%x"`, sha256.Sum256(body))
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-cache")
+	for _, match := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		match = strings.TrimPrefix(strings.TrimSpace(match), "W/")
+		if match == etag || match == "*" {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
 func ChannelListModels(c *gin.Context) {
 	c.JSON(200, gin.H{
 		"success": true,
@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type modelDenyListRequest struct {
+	Models []string `json:"models"`
+}
+
+func GetModelDenyList(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    operation_setting.GetModelDenySetting().Models,
+	})
+}
+
+// UpdateModelDenyList replaces the global model deny-list. It is saved as an
+// option, so it takes effect immediately and is synced to other nodes.
+func UpdateModelDenyList(c *gin.Context) {
+	var req modelDenyListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	models := make([]string, 0, len(req.Models))
+	for _, m := range req.Models {
+		m = strings.TrimSpace(m)
+		if m != "" {
+			models = append(models, m)
+		}
+	}
+	value, err := json.Marshal(models)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := model.UpdateOption("model_deny_setting.models", string(value)); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    models,
+	})
+}
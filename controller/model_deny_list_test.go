@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestUpdateModelDenyList(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sqlite handle: %v", err)
+	}
+	// 内存数据库每个连接相互独立，限制为单连接以共享同一份数据
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&model.Option{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	setting := operation_setting.GetModelDenySetting()
+	mainDB, savedModels, savedOptions := model.DB, setting.Models, common.OptionMap
+	model.DB, common.OptionMap = db, map[string]string{}
+	t.Cleanup(func() { model.DB, setting.Models, common.OptionMap = mainDB, savedModels, savedOptions })
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPut, "/api/model_deny_list", strings.NewReader(`{"models":[" o1-pro ","","gpt-4-32k"]}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	UpdateModelDenyList(c)
+	if !strings.Contains(recorder.Body.String(), `"success":true`) {
+		t.Fatalf("UpdateModelDenyList response = %s", recorder.Body.String())
+	}
+
+	// 保存后立即生效，并持久化为配置项
+	want := []string{"o1-pro", "gpt-4-32k"}
+	if !slices.Equal(setting.Models, want) {
+		t.Errorf("deny-list = %v, want %v", setting.Models, want)
+	}
+	if !operation_setting.IsModelDenied("O1-PRO") {
+		t.Error("IsModelDenied(O1-PRO) = false after the update")
+	}
+	var option model.Option
+	if err := db.First(&option, "key = ?", "model_deny_setting.models").Error; err != nil {
+		t.Fatalf("load option: %v", err)
+	}
+	if option.Value != `["o1-pro","gpt-4-32k"]` {
+		t.Errorf("stored option = %s, want the trimmed list", option.Value)
+	}
+}
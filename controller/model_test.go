@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listModelsForToken calls ListModels for a token limited to the models.
+func listModelsForToken(t *testing.T, models map[string]bool, ifNoneMatch string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	if ifNoneMatch != "" {
+		c.Request.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	common.SetContextKey(c, constant.ContextKeyTokenModelLimitEnabled, true)
+	common.SetContextKey(c, constant.ContextKeyTokenModelLimit, models)
+	ListModels(c)
+	// 与 gin 引擎一样在处理结束后写出响应头
+	c.Writer.WriteHeaderNow()
+	return recorder
+}
+
+func TestListModelsETag(t *testing.T) {
+	models := map[string]bool{"custom-b": true, "custom-a": true, "custom-c": true}
+	first := listModelsForToken(t, models, "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" || first.Body.Len() == 0 {
+		t.Fatalf("first response = %d, ETag %q, body %q, want 200 with an ETag", first.Code, etag, first.Body.String())
+	}
+	if first.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("Cache-Control = %q, want no-cache", first.Header().Get("Cache-Control"))
+	}
+	// map 遍历顺序随机，多次请求的 ETag 仍应一致
+	for i := 0; i < 5; i++ {
+		if got := listModelsForToken(t, models, "").Header().Get("ETag"); got != etag {
+			t.Fatalf("ETag changed between identical requests: %s != %s", got, etag)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		models      map[string]bool
+		ifNoneMatch string
+		want        int
+	}{
+		{name: "matching ETag", models: models, ifNoneMatch: etag, want: http.StatusNotModified},
+		{name: "weak ETag in a list", models: models, ifNoneMatch: `"other", W/` + etag, want: http.StatusNotModified},
+		{name: "wildcard", models: models, ifNoneMatch: "*", want: http.StatusNotModified},
+		{name: "stale ETag", models: models, ifNoneMatch: `"stale"`, want: http.StatusOK},
+		{name: "model list changed", models: map[string]bool{"custom-a": true}, ifNoneMatch: etag, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := listModelsForToken(t, tt.models, tt.ifNoneMatch)
+			if recorder.Code != tt.want {
+				t.Fatalf("status = %d, want %d", recorder.Code, tt.want)
+			}
+			if tt.want == http.StatusNotModified && recorder.Body.Len() != 0 {
+				t.Errorf("304 response has a body: %q", recorder.Body.String())
+			}
+			if tt.want == http.StatusOK && recorder.Header().Get("ETag") == "" {
+				t.Error("200 response has no ETag")
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/model"
+	"one-api/service"
 	"one-api/setting"
 	"one-api/setting/console_setting"
 	"one-api/setting/ratio_setting"
@@ -158,6 +159,9 @@ func UpdateOption(c *gin.Context) {
 			return
 		}
 	}
+	common.OptionMapRWMutex.RLock()
+	originValue := common.OptionMap[option.Key]
+	common.OptionMapRWMutex.RUnlock()
 	err = model.UpdateOption(option.Key, option.Value)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -166,9 +170,18 @@ func UpdateOption(c *gin.Context) {
 		})
 		return
 	}
+	if isAuditedOption(option.Key) {
+		service.RecordAudit(c, service.AuditActionOptionUpdate, service.AuditTargetOption, option.Key, originValue, option.Value)
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
 	})
 	return
 }
+
+// isAuditedOption reports whether changes to the option are recorded in the
+// audit log. Only billing ratios and prices are audited.
+func isAuditedOption(key string) bool {
+	return strings.HasSuffix(key, "Ratio") || key == "ModelPrice" || key == "Price"
+}
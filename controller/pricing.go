@@ -46,6 +46,21 @@ func GetPricing(c *gin.Context) {
 	})
 }
 
+// ReloadPricing 立即从数据库重新加载倍率并重建定价缓存
+func ReloadPricing(c *gin.Context) {
+	pricing := model.ReloadPricing()
+	c.JSON(200, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"models":            len(pricing),
+			"model_ratios":      len(ratio_setting.GetModelRatioCopy()),
+			"model_prices":      len(ratio_setting.GetModelPriceCopy()),
+			"completion_ratios": len(ratio_setting.GetCompletionRatioCopy()),
+		},
+	})
+}
+
 func ResetModelRatio(c *gin.Context) {
 	defaultStr := ratio_setting.DefaultModelRatio2JSONString()
 	err := model.UpdateOption("ModelRatio", defaultStr)
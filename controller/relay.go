@@ -1,10 +1,8 @@
 package controller
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"one-api/common"
@@ -17,12 +15,17 @@ import (
 	relayconstant "one-api/relay/constant"
 	"one-api/relay/helper"
 	"one-api/service"
+	"one-api/setting/operation_setting"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
 
+// relayModeHandler serves one relay attempt; tests replace it to stub the
+// upstream.
+var relayModeHandler = relayHandler
+
 func relayHandler(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusCode {
 	var err *dto.OpenAIErrorWithStatusCode
 	switch relayMode {
@@ -46,7 +49,9 @@ func relayHandler(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusCode
 		err = relay.TextHelper(c)
 	}
 
-	if constant2.ErrorLogEnabled && err != nil {
+	// 被 JSON 模式策略拒绝的请求总是记录，便于分组管理员排查
+	jsonModeRejected := c.GetBool("json_mode_rejected")
+	if (constant2.ErrorLogEnabled || jsonModeRejected) && err != nil {
 		// 保存错误日志到mysql中
 		userId := c.GetInt("id")
 		tokenName := c.GetString("token_name")
@@ -61,6 +66,9 @@ func relayHandler(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusCode
 		other["channel_id"] = channelId
 		other["channel_name"] = c.GetString("channel_name")
 		other["channel_type"] = c.GetInt("channel_type")
+		if jsonModeRejected {
+			other["json_mode_rejected"] = true
+		}
 
 		model.RecordErrorLog(c, userId, channelId, modelName, tokenName, err.Error.Message, tokenId, 0, false, userGroup, other)
 	}
@@ -68,13 +76,91 @@ func relayHandler(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusCode
 	return err
 }
 
+// checkRelayCapability rejects capabilities disabled by the relay endpoint
+// allowlist before any channel is used.
+func checkRelayCapability(capability string) error {
+	if operation_setting.IsRelayCapabilityEnabled(capability) {
+		return nil
+	}
+	return fmt.Errorf("接口 %s 已被管理员禁用", capability)
+}
+
+// groupErrorKind classifies the final error of a relay request for the group
+// error overrides; it returns "" for errors that cannot be overridden.
+func groupErrorKind(statusCode int, code any) string {
+	if statusCode == http.StatusTooManyRequests {
+		return operation_setting.GroupErrorSaturated
+	}
+	if code == "insufficient_user_quota" || code == "pre_consume_token_quota_failed" {
+		return operation_setting.GroupErrorInsufficientQuota
+	}
+	return ""
+}
+
+// applyGroupErrorOverride replaces the user-facing message and status code
+// with the group's customized ones, if configured.
+func applyGroupErrorOverride(group string, errorKind string, message *string, statusCode *int) {
+	override, ok := operation_setting.GetGroupErrorOverride(group, errorKind)
+	if !ok {
+		return
+	}
+	if override.Message != "" {
+		*message = override.Message
+	}
+	if override.StatusCode >= 400 && override.StatusCode < 600 {
+		*statusCode = override.StatusCode
+	}
+}
+
 func Relay(c *gin.Context) {
+	service.RelayRequestStarted()
+	defer service.RelayRequestFinished(c)
 	relayMode := relayconstant.Path2RelayMode(c.Request.URL.Path)
 	requestId := c.GetString(common.RequestIdKey)
 	group := c.GetString("group")
 	originalModel := c.GetString("original_model")
 	var openaiErr *dto.OpenAIErrorWithStatusCode
 
+	if err := checkRelayCapability(relayconstant.RelayModeCapability(relayMode)); err != nil {
+		openaiErr = service.OpenAIErrorWrapperLocal(err, "relay_mode_disabled", operation_setting.GetRelayDisabledStatusCode())
+		openaiErr.Error.Message = common.MessageWithRequestId(openaiErr.Error.Message, requestId)
+		c.JSON(openaiErr.StatusCode, gin.H{
+			"error": openaiErr.Error,
+		})
+		return
+	}
+
+	// 敏感词与提示词注入检查每个请求只执行一次，不随重试重复执行
+	if openaiErr = relay.CheckPromptPolicy(c, relayMode, group); openaiErr != nil {
+		openaiErr.Error.Message = common.MessageWithRequestId(openaiErr.Error.Message, requestId)
+		c.JSON(openaiErr.StatusCode, gin.H{
+			"error": openaiErr.Error,
+		})
+		return
+	}
+
+	releaseSlot, err := service.AcquireModelSlot(c.Request.Context(), originalModel, c.GetInt("id"), group, common.GetContextKeyInt(c, constant.ContextKeyUserQuota))
+	if err != nil {
+		openaiErr = service.OpenAIErrorWrapperLocal(err, "model_concurrency_limited", http.StatusTooManyRequests)
+		openaiErr.Error.Message = common.MessageWithRequestId(openaiErr.Error.Message, requestId)
+		c.JSON(openaiErr.StatusCode, gin.H{
+			"error": openaiErr.Error,
+		})
+		return
+	}
+	defer releaseSlot()
+
+	releaseGlobalSlot, err := service.AcquireGlobalSlot(c.Request.Context())
+	if err != nil {
+		openaiErr = service.OpenAIErrorWrapperLocal(err, "global_concurrency_limited", http.StatusTooManyRequests)
+		openaiErr.Error.Message = common.MessageWithRequestId(openaiErr.Error.Message, requestId)
+		c.JSON(openaiErr.StatusCode, gin.H{
+			"error": openaiErr.Error,
+		})
+		return
+	}
+	defer releaseGlobalSlot()
+
 	for i := 0; i <= common.RetryTimes; i++ {
 		channel, err := getChannel(c, group, originalModel, i)
 		if err != nil {
@@ -83,13 +169,46 @@ func Relay(c *gin.Context) {
 			break
 		}
 
-		openaiErr = relayRequest(c, relayMode, channel)
+		c.Set(helper.TruncationRetryAllowedContextKey, truncationRetryAllowed(c, common.RetryTimes-i))
+		// 对冲请求由胜出的渠道及其密钥计入成功
+		successChannel, channelKey := channel, ""
+		if i == 0 && shouldHedgeRequest(c, relayMode, group) {
+			successChannel, channelKey, openaiErr = relayHedged(c, relayMode, group, originalModel, channel)
+		} else {
+			openaiErr = relayRequest(c, relayMode, channel)
+			channelKey = common.GetContextKeyString(c, constant.ContextKeyChannelKey)
+		}
 
 		if openaiErr == nil {
+			model.RecordChannelWeightSuccess(successChannel.Id)
+			model.RecordChannelKeySuccess(successChannel.Id, channelKey)
 			return // 成功处理请求，直接返回
 		}
 
-		go processChannelError(c, channel.Id, channel.Type, channel.Name, channel.GetAutoBan(), openaiErr)
+		if c.Request.Context().Err() != nil {
+			// 客户端已断开，上游请求随之取消，不计入渠道错误也不再重试
+			break
+		}
+
+		if openaiErr.Error.Code == helper.ErrorCodeResponseTruncated {
+			// 响应被截断，调大 max_tokens 后换渠道重试一次，不计入渠道错误
+			common.LogInfo(c, openaiErr.Error.Message)
+			c.Set("truncation_retried", true)
+			c.Set("truncation_exclude_channel", channel.Id)
+			continue
+		}
+
+		if contextSummaryAllowed(c, relayMode, openaiErr, common.RetryTimes-i) {
+			// 上下文超长，摘要较早的消息后重试一次，不计入渠道错误
+			if err := summarizeContext(c, group); err != nil {
+				common.LogError(c, fmt.Sprintf("context summary failed: %s", err.Error()))
+			} else {
+				common.LogInfo(c, fmt.Sprintf("context length exceeded, retrying with %d older messages summarized", c.GetInt(service.ContextSummarizedMessagesContextKey)))
+				continue
+			}
+		}
+
+		go processChannelError(c, channel.Id, channel.Type, channel.Name, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan(), openaiErr)
 
 		if !shouldRetry(c, openaiErr, common.RetryTimes-i) {
 			break
@@ -106,6 +225,9 @@ func Relay(c *gin.Context) {
 			common.LogError(c, fmt.Sprintf("origin 429 error: %s", openaiErr.Error.Message))
 			openaiErr.Error.Message = "当前分组上游负载已饱和，请稍后再试"
 		}
+		if errorKind := groupErrorKind(openaiErr.StatusCode, openaiErr.Error.Code); errorKind != "" {
+			applyGroupErrorOverride(group, errorKind, &openaiErr.Error.Message, &openaiErr.StatusCode)
+		}
 		openaiErr.Error.Message = common.MessageWithRequestId(openaiErr.Error.Message, requestId)
 		c.JSON(openaiErr.StatusCode, gin.H{
 			"error": openaiErr.Error,
@@ -121,6 +243,25 @@ var upgrader = websocket.Upgrader{
 }
 
 func WssRelay(c *gin.Context) {
+	if err := checkRelayCapability(relayconstant.RelayCapabilityRealtime); err != nil {
+		openaiErr := service.OpenAIErrorWrapperLocal(err, "relay_mode_disabled", operation_setting.GetRelayDisabledStatusCode())
+		c.JSON(openaiErr.StatusCode, gin.H{
+			"error": openaiErr.Error,
+		})
+		return
+	}
+	// 分组连接数已满时在升级前拒绝
+	releaseConnection, err := service.AcquireRealtimeConnection(c.GetString("group"))
+	if err != nil {
+		openaiErr := service.OpenAIErrorWrapperLocal(err, "realtime_connection_limit", http.StatusTooManyRequests)
+		c.JSON(openaiErr.StatusCode, gin.H{
+			"error": openaiErr.Error,
+		})
+		return
+	}
+	defer releaseConnection()
+	service.WsConnectionStarted()
+	defer service.WsConnectionFinished(c)
 	// 将 HTTP 连接升级为 WebSocket 连接
 
 	ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
@@ -150,10 +291,12 @@ func WssRelay(c *gin.Context) {
 		openaiErr = wssRequest(c, ws, relayMode, channel)
 
 		if openaiErr == nil {
+			model.RecordChannelWeightSuccess(channel.Id)
+			model.RecordChannelKeySuccess(channel.Id, common.GetContextKeyString(c, constant.ContextKeyChannelKey))
 			return // 成功处理请求，直接返回
 		}
 
-		go processChannelError(c, channel.Id, channel.Type, channel.Name, channel.GetAutoBan(), openaiErr)
+		go processChannelError(c, channel.Id, channel.Type, channel.Name, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan(), openaiErr)
 
 		if !shouldRetry(c, openaiErr, common.RetryTimes-i) {
 			break
@@ -175,12 +318,58 @@ func WssRelay(c *gin.Context) {
 }
 
 func RelayClaude(c *gin.Context) {
+	service.RelayRequestStarted()
+	defer service.RelayRequestFinished(c)
 	//relayMode := constant.Path2RelayMode(c.Request.URL.Path)
 	requestId := c.GetString(common.RequestIdKey)
 	group := c.GetString("group")
 	originalModel := c.GetString("original_model")
 	var claudeErr *dto.ClaudeErrorWithStatusCode
 
+	if err := checkRelayCapability(relayconstant.RelayCapabilityClaude); err != nil {
+		claudeErr = service.ClaudeErrorWrapperLocal(err, "relay_mode_disabled", operation_setting.GetRelayDisabledStatusCode())
+		claudeErr.Error.Message = common.MessageWithRequestId(claudeErr.Error.Message, requestId)
+		c.JSON(claudeErr.StatusCode, gin.H{
+			"type":  "error",
+			"error": claudeErr.Error,
+		})
+		return
+	}
+
+	// 敏感词与提示词注入检查每个请求只执行一次，不随重试重复执行
+	if claudeErr = relay.CheckClaudePromptPolicy(c, group); claudeErr != nil {
+		claudeErr.Error.Message = common.MessageWithRequestId(claudeErr.Error.Message, requestId)
+		c.JSON(claudeErr.StatusCode, gin.H{
+			"type":  "error",
+			"error": claudeErr.Error,
+		})
+		return
+	}
+
+	releaseSlot, err := service.AcquireModelSlot(c.Request.Context(), originalModel, c.GetInt("id"), group, common.GetContextKeyInt(c, constant.ContextKeyUserQuota))
+	if err != nil {
+		claudeErr = service.ClaudeErrorWrapperLocal(err, "model_concurrency_limited", http.StatusTooManyRequests)
+		claudeErr.Error.Message = common.MessageWithRequestId(claudeErr.Error.Message, requestId)
+		c.JSON(claudeErr.StatusCode, gin.H{
+			"type":  "error",
+			"error": claudeErr.Error,
+		})
+		return
+	}
+	defer releaseSlot()
+
+	releaseGlobalSlot, err := service.AcquireGlobalSlot(c.Request.Context())
+	if err != nil {
+		claudeErr = service.ClaudeErrorWrapperLocal(err, "global_concurrency_limited", http.StatusTooManyRequests)
+		claudeErr.Error.Message = common.MessageWithRequestId(claudeErr.Error.Message, requestId)
+		c.JSON(claudeErr.StatusCode, gin.H{
+			"type":  "error",
+			"error": claudeErr.Error,
+		})
+		return
+	}
+	defer releaseGlobalSlot()
+
 	for i := 0; i <= common.RetryTimes; i++ {
 		channel, err := getChannel(c, group, originalModel, i)
 		if err != nil {
@@ -192,12 +381,19 @@ func RelayClaude(c *gin.Context) {
 		claudeErr = claudeRequest(c, channel)
 
 		if claudeErr == nil {
+			model.RecordChannelWeightSuccess(channel.Id)
+			model.RecordChannelKeySuccess(channel.Id, common.GetContextKeyString(c, constant.ContextKeyChannelKey))
 			return // 成功处理请求，直接返回
 		}
 
+		if c.Request.Context().Err() != nil {
+			// 客户端已断开，上游请求随之取消，不计入渠道错误也不再重试
+			break
+		}
+
 		openaiErr := service.ClaudeErrorToOpenAIError(claudeErr)
 
-		go processChannelError(c, channel.Id, channel.Type, channel.Name, channel.GetAutoBan(), openaiErr)
+		go processChannelError(c, channel.Id, channel.Type, channel.Name, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan(), openaiErr)
 
 		if !shouldRetry(c, openaiErr, common.RetryTimes-i) {
 			break
@@ -210,6 +406,9 @@ func RelayClaude(c *gin.Context) {
 	}
 
 	if claudeErr != nil {
+		if errorKind := groupErrorKind(claudeErr.StatusCode, claudeErr.Code); errorKind != "" {
+			applyGroupErrorOverride(group, errorKind, &claudeErr.Error.Message, &claudeErr.StatusCode)
+		}
 		claudeErr.Error.Message = common.MessageWithRequestId(claudeErr.Error.Message, requestId)
 		c.JSON(claudeErr.StatusCode, gin.H{
 			"type":  "error",
@@ -220,25 +419,51 @@ func RelayClaude(c *gin.Context) {
 
 func relayRequest(c *gin.Context, relayMode int, channel *model.Channel) *dto.OpenAIErrorWithStatusCode {
 	addUsedChannel(c, channel.Id)
-	requestBody, _ := common.GetRequestBody(c)
-	c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
-	return relayHandler(c, relayMode)
+	for attempt := 0; ; attempt++ {
+		resetRequestBody(c)
+		common.SetContextKey(c, constant.ContextKeyUpstreamConnectionFailed, false)
+		openaiErr := relayModeHandler(c, relayMode)
+		// 计费前失败的请求仍需写出被暂存的响应
+		service.ReleaseBilledResponse(c)
+		if openaiErr == nil || attempt >= constant.InChannelRetry || !shouldRetryInChannel(c) {
+			return openaiErr
+		}
+		common.LogWarn(c, fmt.Sprintf("upstream connection to channel #%d failed, retrying on the same channel (%d/%d): %s",
+			channel.Id, attempt+1, constant.InChannelRetry, openaiErr.Error.Message))
+	}
+}
+
+// shouldRetryInChannel reports whether the last attempt failed to connect to
+// the upstream before anything was written to the client. Requests that
+// have started streaming are never retried.
+func shouldRetryInChannel(c *gin.Context) bool {
+	return common.GetContextKeyBool(c, constant.ContextKeyUpstreamConnectionFailed) && !c.Writer.Written()
 }
 
 func wssRequest(c *gin.Context, ws *websocket.Conn, relayMode int, channel *model.Channel) *dto.OpenAIErrorWithStatusCode {
 	addUsedChannel(c, channel.Id)
-	requestBody, _ := common.GetRequestBody(c)
-	c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+	resetRequestBody(c)
 	return relay.WssHelper(c, ws)
 }
 
 func claudeRequest(c *gin.Context, channel *model.Channel) *dto.ClaudeErrorWithStatusCode {
 	addUsedChannel(c, channel.Id)
-	requestBody, _ := common.GetRequestBody(c)
-	c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+	resetRequestBody(c)
+	defer service.ReleaseBilledResponse(c)
 	return relay.ClaudeHelper(c)
 }
 
+// resetRequestBody rewinds the request body for the next relay attempt. A
+// spooled body is streamed from its temp file again rather than read back
+// into memory.
+func resetRequestBody(c *gin.Context) {
+	body, err := common.GetRequestBodyReader(c)
+	if err != nil {
+		body = http.NoBody
+	}
+	c.Request.Body = body
+}
+
 func addUsedChannel(c *gin.Context, channelId int) {
 	useChannel := c.GetStringSlice("use_channel")
 	useChannel = append(useChannel, fmt.Sprintf("%d", channelId))
@@ -260,13 +485,31 @@ func getChannel(c *gin.Context, group, originalModel string, retryCount int) (*m
 		}, nil
 	}
 	channel, _, err := model.CacheGetRandomSatisfiedChannel(c, group, originalModel, retryCount)
+	// 截断重试时尽量避开产生截断的渠道
+	excludeId := c.GetInt("truncation_exclude_channel")
+	for attempt := 0; err == nil && excludeId != 0 && channel.Id == excludeId && attempt < 3; attempt++ {
+		channel, _, err = model.CacheGetRandomSatisfiedChannel(c, group, originalModel, retryCount)
+	}
 	if err != nil {
 		return nil, errors.New(fmt.Sprintf("获取重试渠道失败: %s", err.Error()))
 	}
-	middleware.SetupContextForSelectedChannel(c, channel, originalModel)
+	if err := middleware.SetupContextForSelectedChannel(c, channel, originalModel); err != nil {
+		return nil, err
+	}
 	return channel, nil
 }
 
+// truncationRetryAllowed 截断重试开启、仍有重试次数且未指定渠道时，每个请求最多重试一次
+func truncationRetryAllowed(c *gin.Context, retryTimes int) bool {
+	if !operation_setting.GetTruncationSetting().RetryEnabled || retryTimes <= 0 {
+		return false
+	}
+	if _, ok := c.Get("specific_channel_id"); ok {
+		return false
+	}
+	return !c.GetBool("truncation_retried")
+}
+
 func shouldRetry(c *gin.Context, openaiErr *dto.OpenAIErrorWithStatusCode, retryTimes int) bool {
 	if openaiErr == nil {
 		return false
@@ -280,6 +523,10 @@ func shouldRetry(c *gin.Context, openaiErr *dto.OpenAIErrorWithStatusCode, retry
 	if _, ok := c.Get("specific_channel_id"); ok {
 		return false
 	}
+	if retry, matched := operation_setting.MatchRetryRule(c.GetInt("channel_type"), fmt.Sprintf("%v", openaiErr.Error.Code),
+		openaiErr.Error.Type, openaiErr.Error.Message); matched {
+		return retry
+	}
 	if openaiErr.StatusCode == http.StatusTooManyRequests {
 		return true
 	}
@@ -310,17 +557,34 @@ func shouldRetry(c *gin.Context, openaiErr *dto.OpenAIErrorWithStatusCode, retry
 	return true
 }
 
-func processChannelError(c *gin.Context, channelId int, channelType int, channelName string, autoBan bool, err *dto.OpenAIErrorWithStatusCode) {
+func processChannelError(c *gin.Context, channelId int, channelType int, channelName string, channelKey string, autoBan bool, err *dto.OpenAIErrorWithStatusCode) {
 	// 不要使用context获取渠道信息，异步处理时可能会出现渠道信息不一致的情况
 	// do not use context to get channel info, there may be inconsistent channel info when processing asynchronously
 	common.LogError(c, fmt.Sprintf("relay error (channel #%d, status code: %d): %s", channelId, err.StatusCode, err.Error.Message))
+	if !err.LocalError {
+		model.RecordChannelWeightError(channelId)
+		model.RecordChannelKeyError(channelId, channelKey, err.StatusCode)
+	}
 	if service.ShouldDisableChannel(channelType, err) && autoBan {
+		// 多密钥渠道只禁用出错的密钥，所有密钥都被禁用后才禁用渠道
+		if model.DisableChannelKey(channelId, channelKey) {
+			common.LogWarn(c, fmt.Sprintf("channel #%d key disabled: %s", channelId, err.Error.Message))
+			return
+		}
 		service.DisableChannel(channelId, channelName, err.Error.Message)
 	}
 }
 
 func RelayMidjourney(c *gin.Context) {
 	relayMode := c.GetInt("relay_mode")
+	if err := checkRelayCapability(relayconstant.RelayCapabilityMidjourney); err != nil {
+		c.JSON(operation_setting.GetRelayDisabledStatusCode(), gin.H{
+			"description": err.Error(),
+			"type":        "new_api_error",
+			"code":        constant.MjRequestError,
+		})
+		return
+	}
 	var err *dto.MidjourneyResponse
 	switch relayMode {
 	case relayconstant.RelayModeMidjourneyNotify:
@@ -382,6 +646,11 @@ func RelayTask(c *gin.Context) {
 	relayMode := c.GetInt("relay_mode")
 	group := c.GetString("group")
 	originalModel := c.GetString("original_model")
+	if err := checkRelayCapability(relayconstant.RelayModeCapability(relayMode)); err != nil {
+		taskErr := service.TaskErrorWrapperLocal(err, "relay_mode_disabled", operation_setting.GetRelayDisabledStatusCode())
+		c.JSON(taskErr.StatusCode, taskErr)
+		return
+	}
 	c.Set("use_channel", []string{fmt.Sprintf("%d", channelId)})
 	taskErr := taskRelayHandler(c, relayMode)
 	if taskErr == nil {
@@ -398,10 +667,12 @@ func RelayTask(c *gin.Context) {
 		useChannel = append(useChannel, fmt.Sprintf("%d", channelId))
 		c.Set("use_channel", useChannel)
 		common.LogInfo(c, fmt.Sprintf("using channel #%d to retry (remain times %d)", channel.Id, i))
-		middleware.SetupContextForSelectedChannel(c, channel, originalModel)
+		if err := middleware.SetupContextForSelectedChannel(c, channel, originalModel); err != nil {
+			common.LogError(c, err.Error())
+			break
+		}
 
-		requestBody, err := common.GetRequestBody(c)
-		c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+		resetRequestBody(c)
 		taskErr = taskRelayHandler(c, relayMode)
 	}
 	useChannel := c.GetStringSlice("use_channel")
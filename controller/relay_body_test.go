@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestResetRequestBodyStreamsSpooledBody(t *testing.T) {
+	spoolLargeBodies, threshold := constant.SpoolLargeBodies, constant.SpoolBodyThreshold
+	constant.SpoolLargeBodies, constant.SpoolBodyThreshold = true, 8
+	t.Cleanup(func() {
+		constant.SpoolLargeBodies, constant.SpoolBodyThreshold = spoolLargeBodies, threshold
+	})
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":"hello"}]}`
+	c.Request = httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	var request map[string]any
+	if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+		t.Fatalf("UnmarshalBodyReusable: %v", err)
+	}
+	t.Cleanup(func() { common.CleanupRequestBody(c) })
+
+	// 每次重试都从临时文件重新读取，而不是整体读入内存
+	for attempt := 0; attempt < 2; attempt++ {
+		resetRequestBody(c)
+		if typ := fmt.Sprintf("%T", c.Request.Body); !strings.Contains(typ, "spooledBodyReader") {
+			t.Fatalf("attempt %d: request body is %s, want the spooled file reader", attempt, typ)
+		}
+		got, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			t.Fatalf("attempt %d: read body: %v", attempt, err)
+		}
+		if string(got) != body {
+			t.Fatalf("attempt %d: body = %q, want %q", attempt, got, body)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	relayconstant "one-api/relay/constant"
+	"one-api/setting/operation_setting"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCheckRelayCapability(t *testing.T) {
+	setting := operation_setting.GetRelayEndpointSetting()
+	saved := *setting
+	t.Cleanup(func() { *setting = saved })
+
+	tests := []struct {
+		name       string
+		enabled    bool
+		allowed    []string
+		capability string
+		wantErr    bool
+	}{
+		{name: "allowlist off", capability: relayconstant.RelayCapabilityEmbeddings},
+		{name: "allowed capability", enabled: true, allowed: []string{"chat", "embeddings"}, capability: relayconstant.RelayCapabilityEmbeddings},
+		{name: "disabled capability", enabled: true, allowed: []string{"chat"}, capability: relayconstant.RelayCapabilityEmbeddings, wantErr: true},
+		{name: "empty allowlist disables all", enabled: true, capability: relayconstant.RelayCapabilityChat, wantErr: true},
+		{name: "unknown capability is not restricted", enabled: true, allowed: []string{"chat"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setting.Enabled, setting.EnabledCapabilities = tt.enabled, tt.allowed
+			if err := checkRelayCapability(tt.capability); (err != nil) != tt.wantErr {
+				t.Errorf("checkRelayCapability(%q) error = %v, wantErr %v", tt.capability, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRelayRejectsDisabledCapability(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setting := operation_setting.GetRelayEndpointSetting()
+	saved := *setting
+	setting.Enabled, setting.EnabledCapabilities = true, []string{relayconstant.RelayCapabilityChat}
+	t.Cleanup(func() { *setting = saved })
+
+	for _, tt := range []struct {
+		statusCode int
+		want       int
+	}{
+		{statusCode: http.StatusForbidden, want: http.StatusForbidden},
+		{statusCode: http.StatusNotFound, want: http.StatusNotFound},
+		{statusCode: http.StatusBadRequest, want: http.StatusNotFound},
+	} {
+		setting.DisabledStatusCode = tt.statusCode
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(`{"model":"text-embedding-3-small","input":"hi"}`))
+		Relay(c)
+
+		if recorder.Code != tt.want {
+			t.Errorf("configured %d: status = %d, want %d", tt.statusCode, recorder.Code, tt.want)
+		}
+		if !strings.Contains(recorder.Body.String(), `"code":"relay_mode_disabled"`) {
+			t.Errorf("configured %d: body = %s, want the relay_mode_disabled code", tt.statusCode, recorder.Body.String())
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"one-api/constant"
+	"one-api/service"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRelayRejectsWhenGlobalConcurrencyQueueTimesOut(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// 全局并发位在首次使用时按配置创建，本包中此前没有请求占用过
+	limit, wait := constant.GlobalMaxConcurrency, constant.GlobalConcurrencyWaitSeconds
+	constant.GlobalMaxConcurrency, constant.GlobalConcurrencyWaitSeconds = 1, 1
+	t.Cleanup(func() { constant.GlobalMaxConcurrency, constant.GlobalConcurrencyWaitSeconds = limit, wait })
+
+	release, err := service.AcquireGlobalSlot(context.Background())
+	if err != nil {
+		t.Fatalf("AcquireGlobalSlot() error = %v", err)
+	}
+	defer release()
+	if stats := service.GetLiveStats(); stats.GlobalConcurrencyLimit != 1 || stats.GlobalConcurrencyInUse != 1 {
+		t.Fatalf("global concurrency = %d/%d, want the only slot taken", stats.GlobalConcurrencyInUse, stats.GlobalConcurrencyLimit)
+	}
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions",
+		strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	start := time.Now()
+	Relay(c)
+
+	if waited := time.Since(start); waited < time.Second {
+		t.Errorf("request was rejected after %v, want it to wait for a slot first", waited)
+	}
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusTooManyRequests)
+	}
+	if !strings.Contains(recorder.Body.String(), `"code":"global_concurrency_limited"`) {
+		t.Errorf("body = %s, want the global_concurrency_limited code", recorder.Body.String())
+	}
+}
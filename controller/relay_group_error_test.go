@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"testing"
+)
+
+func TestGroupErrorOverride(t *testing.T) {
+	setting := operation_setting.GetGroupErrorSetting()
+	saved := setting.Overrides
+	setting.Overrides = map[string]map[string]operation_setting.GroupErrorOverride{
+		"vip": {
+			operation_setting.GroupErrorSaturated:         {Message: "busy, retry later", StatusCode: http.StatusServiceUnavailable},
+			operation_setting.GroupErrorInsufficientQuota: {Message: "please top up"},
+		},
+	}
+	t.Cleanup(func() { setting.Overrides = saved })
+
+	quotaErr := service.OpenAIErrorWrapperLocal(errors.New("user quota is not enough"), "insufficient_user_quota", http.StatusForbidden)
+	// Claude 接口的额度错误由 OpenAI 错误转换而来
+	claudeQuotaErr := service.OpenAIErrorToClaudeError(quotaErr)
+	claudeRateErr := service.ClaudeErrorWrapper(errors.New("rate limited"), "rate_limited", http.StatusTooManyRequests)
+
+	tests := []struct {
+		name       string
+		group      string
+		statusCode int
+		code       any
+		message    string
+		wantStatus int
+		wantMsg    string
+	}{
+		{name: "openai insufficient quota", group: "vip", statusCode: quotaErr.StatusCode, code: quotaErr.Error.Code,
+			message: quotaErr.Error.Message, wantStatus: http.StatusForbidden, wantMsg: "please top up"},
+		{name: "claude insufficient quota", group: "vip", statusCode: claudeQuotaErr.StatusCode, code: claudeQuotaErr.Code,
+			message: claudeQuotaErr.Error.Message, wantStatus: http.StatusForbidden, wantMsg: "please top up"},
+		{name: "claude saturated", group: "vip", statusCode: claudeRateErr.StatusCode, code: claudeRateErr.Code,
+			message: claudeRateErr.Error.Message, wantStatus: http.StatusServiceUnavailable, wantMsg: "busy, retry later"},
+		{name: "group without overrides", group: "default", statusCode: http.StatusTooManyRequests,
+			message: "rate limited", wantStatus: http.StatusTooManyRequests, wantMsg: "rate limited"},
+		{name: "other errors are kept", group: "vip", statusCode: http.StatusBadRequest, code: "invalid_request",
+			message: "bad request", wantStatus: http.StatusBadRequest, wantMsg: "bad request"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, statusCode := tt.message, tt.statusCode
+			if errorKind := groupErrorKind(statusCode, tt.code); errorKind != "" {
+				applyGroupErrorOverride(tt.group, errorKind, &message, &statusCode)
+			}
+			if message != tt.wantMsg || statusCode != tt.wantStatus {
+				t.Errorf("got %d %q, want %d %q", statusCode, message, tt.wantStatus, tt.wantMsg)
+			}
+		})
+	}
+}
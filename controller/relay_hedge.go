@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/middleware"
+	"one-api/model"
+	relayconstant "one-api/relay/constant"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+// hedgeResponseWriter buffers an attempt's response until it wins the race.
+type hedgeResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newHedgeResponseWriter() *hedgeResponseWriter {
+	return &hedgeResponseWriter{header: make(http.Header), status: http.StatusOK}
+}
+
+func (w *hedgeResponseWriter) Header() http.Header { return w.header }
+
+func (w *hedgeResponseWriter) Write(data []byte) (int, error) { return w.body.Write(data) }
+
+func (w *hedgeResponseWriter) WriteString(s string) (int, error) { return w.body.WriteString(s) }
+
+func (w *hedgeResponseWriter) WriteHeader(code int) {
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *hedgeResponseWriter) WriteHeaderNow() {}
+
+func (w *hedgeResponseWriter) Status() int { return w.status }
+
+func (w *hedgeResponseWriter) Size() int { return w.body.Len() }
+
+func (w *hedgeResponseWriter) Written() bool { return w.body.Len() > 0 }
+
+func (w *hedgeResponseWriter) Flush() {}
+
+func (w *hedgeResponseWriter) CloseNotify() <-chan bool { return make(chan bool) }
+
+func (w *hedgeResponseWriter) Pusher() http.Pusher { return nil }
+
+func (w *hedgeResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("hedged response does not support hijacking")
+}
+
+func (w *hedgeResponseWriter) copyTo(dst gin.ResponseWriter) {
+	// 覆盖中间件已写入的同名响应头，避免重复
+	for key, values := range w.header {
+		for i, value := range values {
+			if i == 0 {
+				dst.Header().Set(key, value)
+			} else {
+				dst.Header().Add(key, value)
+			}
+		}
+	}
+	dst.WriteHeader(w.status)
+	_, _ = dst.Write(w.body.Bytes())
+}
+
+type hedgeResult struct {
+	ctx     *gin.Context
+	channel *model.Channel
+	writer  *hedgeResponseWriter
+	err     *dto.OpenAIErrorWithStatusCode
+}
+
+// shouldHedgeRequest 仅对启用分组中显式请求对冲的非流式文本请求生效
+func shouldHedgeRequest(c *gin.Context, relayMode int, group string) bool {
+	if relayMode != relayconstant.RelayModeChatCompletions && relayMode != relayconstant.RelayModeCompletions {
+		return false
+	}
+	if c.GetHeader("X-Hedge-Request") != "true" || !operation_setting.IsHedgeEnabledForGroup(group) {
+		return false
+	}
+	if _, ok := c.Get("specific_channel_id"); ok {
+		return false
+	}
+	var request struct {
+		Stream bool `json:"stream"`
+	}
+	if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+		return false
+	}
+	return !request.Stream
+}
+
+// pickHedgeChannel 选择与首个渠道不同的渠道，找不到时返回 nil
+func pickHedgeChannel(c *gin.Context, group string, originalModel string, excludeId int) *model.Channel {
+	for attempt := 0; attempt < 3; attempt++ {
+		channel, _, err := model.CacheGetRandomSatisfiedChannel(c, group, originalModel, 0)
+		if err != nil {
+			return nil
+		}
+		if channel.Id != excludeId {
+			return channel
+		}
+	}
+	return nil
+}
+
+// relayHedged relays the request on the selected channel and, if it has not
+// finished after the configured delay, races the same request on a second
+// channel. Each attempt runs on a copy of the context with a buffered writer;
+// the first successful attempt is written to the client and the other one is
+// cancelled. Only the winner is billed, see helper.ClaimHedgeWin. On
+// success it returns the winning channel and the key the winner used.
+func relayHedged(c *gin.Context, relayMode int, group string, originalModel string, channel *model.Channel) (*model.Channel, string, *dto.OpenAIErrorWithStatusCode) {
+	race := &helper.HedgeRace{}
+	results := make(chan hedgeResult, 2)
+	var cancels []context.CancelFunc
+	defer func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}()
+
+	start := func(id int32, ch *model.Channel) {
+		ctx, cancel := context.WithCancel(c.Request.Context())
+		cancels = append(cancels, cancel)
+		attemptCtx := c.Copy()
+		writer := newHedgeResponseWriter()
+		attemptCtx.Writer = writer
+		attemptCtx.Request = c.Request.Clone(ctx)
+		attemptCtx.Set(helper.HedgeAttemptContextKey, &helper.HedgeAttempt{Race: race, Id: id})
+		if id > 1 {
+			if err := middleware.SetupContextForSelectedChannel(attemptCtx, ch, originalModel); err != nil {
+				results <- hedgeResult{ctx: attemptCtx, channel: ch, writer: writer, err: service.OpenAIErrorWrapperLocal(err, "get_channel_failed", http.StatusInternalServerError)}
+				return
+			}
+		}
+		gopool.Go(func() {
+			results <- hedgeResult{ctx: attemptCtx, channel: ch, writer: writer, err: relayRequest(attemptCtx, relayMode, ch)}
+		})
+	}
+
+	start(1, channel)
+	timer := time.NewTimer(time.Duration(operation_setting.GetHedgeSetting().DelayMs) * time.Millisecond)
+	defer timer.Stop()
+
+	pending := 1
+	var firstErr *dto.OpenAIErrorWithStatusCode
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if second := pickHedgeChannel(c, group, originalModel, channel.Id); second != nil {
+				common.LogInfo(c, fmt.Sprintf("hedging request on channel #%d after channel #%d did not respond", second.Id, channel.Id))
+				start(2, second)
+				pending++
+			}
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				if result.channel.Id != channel.Id {
+					addUsedChannel(c, result.channel.Id)
+				}
+				result.writer.copyTo(c.Writer)
+				return result.channel, common.GetContextKeyString(result.ctx, constant.ContextKeyChannelKey), nil
+			}
+			if result.channel.Id == channel.Id {
+				firstErr = result.err
+			} else {
+				// 调用方只按首个渠道处理返回的错误，对冲渠道的错误在此单独记录
+				go processChannelError(result.ctx, result.channel.Id, result.channel.Type, result.channel.Name,
+					common.GetContextKeyString(result.ctx, constant.ContextKeyChannelKey), result.channel.GetAutoBan(), result.err)
+			}
+			if pending == 0 {
+				// 首个渠道在对冲前已失败，交给常规重试处理
+				timer.Stop()
+			}
+		}
+	}
+	return channel, common.GetContextKeyString(c, constant.ContextKeyChannelKey), firstErr
+}
@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/middleware"
+	"one-api/model"
+	relayconstant "one-api/relay/constant"
+	"one-api/setting/operation_setting"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupChannelCacheTestDB points the main database at an in-memory SQLite
+// database holding the given channels and loads them into the memory cache.
+func setupChannelCacheTestDB(t *testing.T, channels ...*model.Channel) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sqlite handle: %v", err)
+	}
+	// 内存数据库每个连接相互独立，限制为单连接以共享同一份数据
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&model.Channel{}, &model.Ability{}); err != nil {
+		t.Fatalf("migrate channels: %v", err)
+	}
+	mainDB, memoryCacheEnabled := model.DB, common.MemoryCacheEnabled
+	model.DB, common.MemoryCacheEnabled = db, true
+	t.Cleanup(func() {
+		model.DB, common.MemoryCacheEnabled = mainDB, memoryCacheEnabled
+	})
+	for _, channel := range channels {
+		if err := channel.Insert(); err != nil {
+			t.Fatalf("insert channel: %v", err)
+		}
+	}
+	model.InitChannelCache()
+}
+
+// stubRelayModeHandler replaces the upstream call of every relay attempt.
+func stubRelayModeHandler(t *testing.T, handler func(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusCode) {
+	t.Helper()
+	saved := relayModeHandler
+	relayModeHandler = handler
+	t.Cleanup(func() { relayModeHandler = saved })
+}
+
+func newRelayTestContext(t *testing.T, path string, body string) (*gin.Context, *httptest.ResponseRecorder) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	return c, recorder
+}
+
+func TestRelayHedgedReturnsSecondChannelWhenItWins(t *testing.T) {
+	hedgeSetting := operation_setting.GetHedgeSetting()
+	delayMs := hedgeSetting.DelayMs
+	hedgeSetting.DelayMs = 10
+	t.Cleanup(func() { hedgeSetting.DelayMs = delayMs })
+
+	first := &model.Channel{Id: 1, Name: "slow", Type: constant.ChannelTypeOpenAI, Key: "key-1", Status: common.ChannelStatusEnabled}
+	// 只有对冲渠道在缓存中，保证对冲时选中它
+	second := &model.Channel{Id: 2, Name: "fast", Type: constant.ChannelTypeOpenAI, Key: "key-2", Status: common.ChannelStatusEnabled,
+		Group: "default", Models: "gpt-4o"}
+	setupChannelCacheTestDB(t, second)
+
+	stubRelayModeHandler(t, func(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusCode {
+		if c.GetInt("channel_id") == first.Id {
+			<-c.Request.Context().Done()
+			return nil
+		}
+		c.JSON(http.StatusOK, gin.H{"channel": c.GetInt("channel_id")})
+		return nil
+	})
+
+	c, recorder := newRelayTestContext(t, "/v1/chat/completions", `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)
+	if err := middleware.SetupContextForSelectedChannel(c, first, "gpt-4o"); err != nil {
+		t.Fatalf("SetupContextForSelectedChannel: %v", err)
+	}
+	// 与 shouldHedge 一样先缓存请求体，各次尝试复制上下文后共享缓存而不是同时读取原始请求体
+	if _, err := common.GetRequestBody(c); err != nil {
+		t.Fatalf("GetRequestBody: %v", err)
+	}
+
+	done := make(chan struct{})
+	var winner *model.Channel
+	var winnerKey string
+	var openaiErr *dto.OpenAIErrorWithStatusCode
+	go func() {
+		defer close(done)
+		winner, winnerKey, openaiErr = relayHedged(c, relayconstant.RelayModeChatCompletions, "default", "gpt-4o", first)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("relayHedged did not return after the hedge channel answered")
+	}
+
+	if openaiErr != nil {
+		t.Fatalf("relayHedged() error = %v", openaiErr.Error)
+	}
+	if winner == nil || winner.Id != second.Id {
+		t.Fatalf("winner = %v, want channel #%d", winner, second.Id)
+	}
+	if winnerKey != "key-2" {
+		t.Errorf("winner key = %q, want the hedge channel's key", winnerKey)
+	}
+	if got := recorder.Body.String(); got != `{"channel":2}` {
+		t.Errorf("response body = %s, want the hedge channel's response", got)
+	}
+}
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	relayconstant "one-api/relay/constant"
+	"one-api/setting/operation_setting"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestRelayHandlerRecordsJsonModeRejection(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sqlite handle: %v", err)
+	}
+	// 内存数据库每个连接相互独立，限制为单连接以共享同一份数据
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&model.User{}, &model.Log{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	mainDB, logDB, errorLogEnabled, redisEnabled := model.DB, model.LOG_DB, constant.ErrorLogEnabled, common.RedisEnabled
+	model.DB, model.LOG_DB, constant.ErrorLogEnabled, common.RedisEnabled = db, db, false, false
+	jsonModeSetting := operation_setting.GetJsonModeSetting()
+	policies := jsonModeSetting.GroupPolicies
+	jsonModeSetting.GroupPolicies = map[string]string{"default": operation_setting.JsonModeActionRequire}
+	t.Cleanup(func() {
+		model.DB, model.LOG_DB, constant.ErrorLogEnabled, common.RedisEnabled = mainDB, logDB, errorLogEnabled, redisEnabled
+		jsonModeSetting.GroupPolicies = policies
+	})
+
+	c, _ := newRelayTestContext(t, "/v1/chat/completions", `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)
+	common.SetContextKey(c, constant.ContextKeyUserId, 7)
+	common.SetContextKey(c, constant.ContextKeyUsingGroup, "default")
+	c.Set("original_model", "gpt-4o")
+
+	openaiErr := relayHandler(c, relayconstant.RelayModeChatCompletions)
+	if openaiErr == nil || openaiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("relayHandler() = %v, want a 400 rejection", openaiErr)
+	}
+
+	// 即使关闭了错误日志，策略拒绝也要写入请求日志
+	var logs []model.Log
+	if err := db.Find(&logs).Error; err != nil {
+		t.Fatalf("query logs: %v", err)
+	}
+	if len(logs) != 1 {
+		t.Fatalf("recorded %d logs, want 1", len(logs))
+	}
+	if logs[0].Type != model.LogTypeError || logs[0].UserId != 7 || logs[0].ModelName != "gpt-4o" {
+		t.Errorf("log = %+v, want an error log for user 7 and gpt-4o", logs[0])
+	}
+	if !strings.Contains(logs[0].Other, `"json_mode_rejected":true`) {
+		t.Errorf("log other = %s, want json_mode_rejected", logs[0].Other)
+	}
+}
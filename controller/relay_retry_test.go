@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRelayRequestRetriesConnectionFailuresInChannel(t *testing.T) {
+	const requestBody = `{"model":"gpt-4o","messages":[]}`
+	connectionErr := service.OpenAIErrorWrapper(errors.New("read: connection reset by peer"), "do_request_failed", http.StatusInternalServerError)
+	tests := []struct {
+		name           string
+		inChannelRetry int
+		failures       int
+		writeFirst     bool
+		connection     bool
+		wantAttempts   int
+		wantErr        bool
+	}{
+		{name: "connection reset once, then success", inChannelRetry: 1, failures: 1, connection: true, wantAttempts: 2},
+		{name: "retries are capped", inChannelRetry: 2, failures: 5, connection: true, wantAttempts: 3, wantErr: true},
+		{name: "disabled", inChannelRetry: 0, failures: 1, connection: true, wantAttempts: 1, wantErr: true},
+		{name: "other errors escalate to the next channel", inChannelRetry: 2, failures: 1, wantAttempts: 1, wantErr: true},
+		{name: "partially streamed requests are not retried", inChannelRetry: 2, failures: 1, connection: true, writeFirst: true,
+			wantAttempts: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inChannelRetry := constant.InChannelRetry
+			constant.InChannelRetry = tt.inChannelRetry
+			t.Cleanup(func() { constant.InChannelRetry = inChannelRetry })
+
+			attempts := 0
+			stubRelayModeHandler(t, func(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusCode {
+				attempts++
+				// 每次重试都重新发送完整的请求体
+				if body, _ := io.ReadAll(c.Request.Body); string(body) != requestBody {
+					t.Errorf("attempt %d: request body = %q, want the original body", attempts, body)
+				}
+				if attempts > tt.failures {
+					c.JSON(http.StatusOK, gin.H{"attempt": attempts})
+					return nil
+				}
+				if tt.writeFirst {
+					c.Writer.WriteHeader(http.StatusOK)
+					_, _ = c.Writer.Write([]byte("data: {}\n\n"))
+				}
+				// 与 doRequest 一样标记连接级错误
+				common.SetContextKey(c, constant.ContextKeyUpstreamConnectionFailed, tt.connection)
+				return connectionErr
+			})
+
+			c, _ := newRelayTestContext(t, "/v1/chat/completions", requestBody)
+			openaiErr := relayRequest(c, relayconstant.RelayModeChatCompletions, &model.Channel{Id: 1})
+			if (openaiErr != nil) != tt.wantErr {
+				t.Fatalf("relayRequest() error = %v, want error %v", openaiErr, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+			if used := c.GetStringSlice("use_channel"); len(used) != 1 {
+				t.Errorf("use_channel = %v, want the channel recorded once", used)
+			}
+		})
+	}
+}
+
+func TestShouldRetryRulesTakePrecedence(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setting := operation_setting.GetRetrySetting()
+	saved := setting.Rules
+	setting.Rules = []operation_setting.RetryRule{
+		// 缺少 code/type/message 的规则被忽略
+		{Retry: false},
+		{Code: "content_filter", Retry: false},
+		{Type: "overloaded_error", ChannelTypes: []int{constant.ChannelTypeOpenAI}, Retry: true},
+		{MessageContains: "Try Again Later", Retry: true},
+	}
+	t.Cleanup(func() { setting.Rules = saved })
+
+	tests := []struct {
+		name        string
+		channelType int
+		status      int
+		code        string
+		errType     string
+		message     string
+		want        bool
+	}{
+		{name: "code rule stops a retry on 500", status: http.StatusInternalServerError, code: "content_filter", want: false},
+		{name: "type rule retries a 400", channelType: constant.ChannelTypeOpenAI, status: http.StatusBadRequest,
+			errType: "overloaded_error", want: true},
+		{name: "type rule is limited to its channel types", channelType: constant.ChannelTypeAzure, status: http.StatusBadRequest,
+			errType: "overloaded_error", want: false},
+		{name: "message rule ignores case", status: http.StatusBadRequest, message: "upstream busy, try again later", want: true},
+		{name: "no rule matches a 500", status: http.StatusInternalServerError, code: "server_error", want: true},
+		{name: "no rule matches a 504", status: http.StatusGatewayTimeout, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(nil)
+			c.Set("channel_type", tt.channelType)
+			openaiErr := &dto.OpenAIErrorWithStatusCode{StatusCode: tt.status,
+				Error: dto.OpenAIError{Code: tt.code, Type: tt.errType, Message: tt.message}}
+
+			if got := shouldRetry(c, openaiErr, 1); got != tt.want {
+				t.Errorf("shouldRetry() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
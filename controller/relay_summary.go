@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/middleware"
+	"one-api/model"
+	relayconstant "one-api/relay/constant"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// contextSummaryAllowed 仅对聊天请求在上下文超长时摘要一次，且需要剩余重试次数
+func contextSummaryAllowed(c *gin.Context, relayMode int, openaiErr *dto.OpenAIErrorWithStatusCode, retryTimes int) bool {
+	if !operation_setting.GetContextSummarySetting().Enabled || relayMode != relayconstant.RelayModeChatCompletions || retryTimes <= 0 {
+		return false
+	}
+	if _, ok := c.Get("specific_channel_id"); ok {
+		return false
+	}
+	return !c.GetBool("context_summarized") && service.IsContextLengthError(openaiErr)
+}
+
+// summarizeContext asks the configured summary model to condense the older
+// messages of the request and replaces them with the summary in the cached
+// request body, so the next attempt sends the shorter conversation. The
+// summary call goes through the regular relay on a copy of the context and is
+// billed and logged as its own request.
+func summarizeContext(c *gin.Context, group string) error {
+	c.Set("context_summarized", true)
+	summarySetting := operation_setting.GetContextSummarySetting()
+	if summarySetting.Model == "" {
+		return errors.New("context summary model is not configured")
+	}
+	if err := middleware.CheckModelAccess(c, summarySetting.Model); err != nil {
+		return err
+	}
+	body, err := common.GetRequestBody(c)
+	if err != nil {
+		return err
+	}
+	var request dto.GeneralOpenAIRequest
+	if err := common.UnmarshalJson(body, &request); err != nil {
+		return err
+	}
+	head, older, recent := service.SplitMessagesForSummary(request.Messages, summarySetting.KeepRecentMessages)
+	if len(older) == 0 {
+		return errors.New("no older messages to summarize")
+	}
+	summaryBody, err := common.EncodeJson(service.BuildContextSummaryRequest(summarySetting.Model, older, summarySetting.MaxSummaryTokens))
+	if err != nil {
+		return err
+	}
+
+	channel, _, err := model.CacheGetRandomSatisfiedChannel(c, group, summarySetting.Model, 0)
+	if err != nil {
+		return fmt.Errorf("no channel for summary model %s: %w", summarySetting.Model, err)
+	}
+	summaryCtx := c.Copy()
+	writer := newHedgeResponseWriter()
+	summaryCtx.Writer = writer
+	summaryCtx.Request = c.Request.Clone(c.Request.Context())
+	summaryCtx.Set(common.KeyRequestBody, summaryBody)
+	// 副本沿用了原请求的 prompt_tokens，需按摘要请求重新计算
+	summaryCtx.Set("prompt_tokens", nil)
+	summaryCtx.Set(helper.TruncationRetryAllowedContextKey, false)
+	summaryCtx.Set(service.ContextSummaryRequestContextKey, true)
+	if err := middleware.SetupContextForSelectedChannel(summaryCtx, channel, summarySetting.Model); err != nil {
+		return err
+	}
+	if openaiErr := relayRequest(summaryCtx, relayconstant.RelayModeChatCompletions, channel); openaiErr != nil {
+		return fmt.Errorf("summary request failed: %s", openaiErr.Error.Message)
+	}
+	if writer.status != http.StatusOK {
+		return fmt.Errorf("summary request failed with status %d", writer.status)
+	}
+	var response dto.OpenAITextResponse
+	if err := common.UnmarshalJson(writer.body.Bytes(), &response); err != nil {
+		return err
+	}
+	if len(response.Choices) == 0 || response.Choices[0].Message.StringContent() == "" {
+		return errors.New("summary response is empty")
+	}
+
+	newBody, err := service.ReplaceMessagesWithSummary(body, head, response.Choices[0].Message.StringContent(), recent)
+	if err != nil {
+		return err
+	}
+	common.SetRequestBody(c, newBody)
+	c.Set(service.ContextSummarizedMessagesContextKey, len(older))
+	// 请求体已变化，重试时重新计算 prompt_tokens
+	c.Set("prompt_tokens", nil)
+	return nil
+}
@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSummarizeContextReplacesOlderMessages(t *testing.T) {
+	summarySetting := operation_setting.GetContextSummarySetting()
+	saved := *summarySetting
+	*summarySetting = operation_setting.ContextSummarySetting{Enabled: true, Model: "gpt-4o-mini", KeepRecentMessages: 2, MaxSummaryTokens: 256}
+	t.Cleanup(func() { *summarySetting = saved })
+
+	setupChannelCacheTestDB(t, &model.Channel{Id: 3, Name: "summary", Type: constant.ChannelTypeOpenAI, Key: "key-3",
+		Status: common.ChannelStatusEnabled, Group: "default", Models: "gpt-4o-mini"})
+
+	var summaryRequest dto.GeneralOpenAIRequest
+	stubRelayModeHandler(t, func(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusCode {
+		if c.GetInt("channel_id") != 3 {
+			t.Errorf("summary sent to channel #%d, want the summary model's channel", c.GetInt("channel_id"))
+		}
+		body, _ := io.ReadAll(c.Request.Body)
+		if err := common.UnmarshalJson(body, &summaryRequest); err != nil {
+			t.Errorf("decode summary request %s: %v", body, err)
+		}
+		c.JSON(http.StatusOK, dto.OpenAITextResponse{Choices: []dto.OpenAITextResponseChoice{
+			{Message: dto.Message{Role: "assistant", Content: "they talked about cats"}},
+		}})
+		return nil
+	})
+
+	c, _ := newRelayTestContext(t, "/v1/chat/completions", `{"model":"gpt-4o","temperature":0.5,"messages":[`+
+		`{"role":"system","content":"be nice"},`+
+		`{"role":"user","content":"tell me about cats"},`+
+		`{"role":"assistant","content":"cats purr"},`+
+		`{"role":"user","content":"and dogs?"},`+
+		`{"role":"assistant","content":"dogs bark"}]}`)
+	if err := summarizeContext(c, "default"); err != nil {
+		t.Fatalf("summarizeContext() error = %v", err)
+	}
+
+	if summaryRequest.Model != "gpt-4o-mini" || summaryRequest.MaxTokens != 256 || len(summaryRequest.Messages) != 2 {
+		t.Fatalf("summary request = %+v, want a two-message request to the summary model", summaryRequest)
+	}
+	if transcript := summaryRequest.Messages[1].StringContent(); !strings.Contains(transcript, "tell me about cats") ||
+		!strings.Contains(transcript, "cats purr") || strings.Contains(transcript, "dogs") {
+		t.Errorf("summary transcript = %q, want only the older messages", transcript)
+	}
+
+	body, err := common.GetRequestBody(c)
+	if err != nil {
+		t.Fatalf("GetRequestBody: %v", err)
+	}
+	var retried dto.GeneralOpenAIRequest
+	if err := common.UnmarshalJson(body, &retried); err != nil {
+		t.Fatalf("decode rewritten request %s: %v", body, err)
+	}
+	var contents []string
+	for _, message := range retried.Messages {
+		contents = append(contents, message.Role+": "+message.StringContent())
+	}
+	want := []string{
+		"system: be nice",
+		"system: Summary of the earlier conversation:\nthey talked about cats",
+		"user: and dogs?",
+		"assistant: dogs bark",
+	}
+	if strings.Join(contents, "|") != strings.Join(want, "|") {
+		t.Errorf("rewritten messages = %q, want %q", contents, want)
+	}
+	if retried.Model != "gpt-4o" || retried.Temperature == nil || *retried.Temperature != 0.5 {
+		t.Errorf("rewritten request = %s, want the other fields kept", body)
+	}
+	if got := c.GetInt(service.ContextSummarizedMessagesContextKey); got != 2 {
+		t.Errorf("summarized messages = %d, want 2", got)
+	}
+	if !c.GetBool("context_summarized") {
+		t.Error("request not marked as summarized")
+	}
+}
+
+func TestContextSummaryAllowed(t *testing.T) {
+	summarySetting := operation_setting.GetContextSummarySetting()
+	saved := summarySetting.Enabled
+	summarySetting.Enabled = true
+	t.Cleanup(func() { summarySetting.Enabled = saved })
+
+	contextErr := &dto.OpenAIErrorWithStatusCode{StatusCode: http.StatusBadRequest,
+		Error: dto.OpenAIError{Code: "context_length_exceeded", Message: "This model's maximum context length is 8192 tokens"}}
+	otherErr := &dto.OpenAIErrorWithStatusCode{StatusCode: http.StatusBadRequest, Error: dto.OpenAIError{Message: "invalid request"}}
+	tests := []struct {
+		name       string
+		err        *dto.OpenAIErrorWithStatusCode
+		retryTimes int
+		summarized bool
+		specific   bool
+		want       bool
+	}{
+		{name: "context length error", err: contextErr, retryTimes: 1, want: true},
+		{name: "other error", err: otherErr, retryTimes: 1},
+		{name: "no retries left", err: contextErr},
+		{name: "already summarized once", err: contextErr, retryTimes: 1, summarized: true},
+		{name: "specific channel", err: contextErr, retryTimes: 1, specific: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newRelayTestContext(t, "/v1/chat/completions", `{}`)
+			if tt.summarized {
+				c.Set("context_summarized", true)
+			}
+			if tt.specific {
+				c.Set("specific_channel_id", "1")
+			}
+			if got := contextSummaryAllowed(c, relayconstant.RelayModeChatCompletions, tt.err, tt.retryTimes); got != tt.want {
+				t.Errorf("contextSummaryAllowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"one-api/dto"
 	"one-api/model"
 	"one-api/relay"
+	"one-api/service"
 	"sort"
 	"strconv"
 	"time"
@@ -113,7 +114,12 @@ func updateSunoTaskAll(ctx context.Context, channelId int, taskIds []string, tas
 	if adaptor == nil {
 		return errors.New("adaptor not found")
 	}
-	resp, err := adaptor.FetchTask(*channel.BaseURL, channel.Key, map[string]any{
+	key, err := service.ResolveChannelKey(ctx, channel.Key)
+	if err != nil {
+		common.SysError(fmt.Sprintf("Get Task channel key error: %v", err))
+		return err
+	}
+	resp, err := adaptor.FetchTask(*channel.BaseURL, key, map[string]any{
 		"ids": taskIds,
 	})
 	if err != nil {
@@ -9,6 +9,7 @@ import (
 	"one-api/model"
 	"one-api/relay"
 	"one-api/relay/channel"
+	"one-api/service"
 	"time"
 )
 
@@ -61,7 +62,11 @@ func updateVideoSingleTask(ctx context.Context, adaptor channel.TaskAdaptor, cha
 		common.LogError(ctx, fmt.Sprintf("Task %s not found in taskM", taskId))
 		return fmt.Errorf("task %s not found", taskId)
 	}
-	resp, err := adaptor.FetchTask(baseURL, channel.Key, map[string]any{
+	key, err := service.ResolveChannelKey(ctx, channel.Key)
+	if err != nil {
+		return err
+	}
+	resp, err := adaptor.FetchTask(baseURL, key, map[string]any{
 		"task_id": taskId,
 		"action":  task.Action,
 	})
@@ -131,6 +131,13 @@ func AddToken(c *gin.Context) {
 		})
 		return
 	}
+	if token.SkipPreConsume && c.GetInt("role") < common.RoleAdminUser {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "仅管理员可设置跳过预扣费",
+		})
+		return
+	}
 	key, err := common.GenerateKey()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -153,6 +160,8 @@ func AddToken(c *gin.Context) {
 		ModelLimits:        token.ModelLimits,
 		AllowIps:           token.AllowIps,
 		Group:              token.Group,
+		SkipPreConsume:     token.SkipPreConsume,
+		QuotaHeaderEnabled: token.QuotaHeaderEnabled,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
@@ -187,11 +196,19 @@ func DeleteToken(c *gin.Context) {
 	return
 }
 
+// tokenUpdateRequest tells whether the update carries skip_pre_consume; when
+// it is omitted the token keeps its current value.
+type tokenUpdateRequest struct {
+	model.Token
+	SkipPreConsume *bool `json:"skip_pre_consume"`
+}
+
 func UpdateToken(c *gin.Context) {
 	userId := c.GetInt("id")
 	statusOnly := c.Query("status_only")
-	token := model.Token{}
-	err := c.ShouldBindJSON(&token)
+	request := tokenUpdateRequest{}
+	err := c.ShouldBindJSON(&request)
+	token := request.Token
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -230,6 +247,18 @@ func UpdateToken(c *gin.Context) {
 			return
 		}
 	}
+	// 仅在修改跳过预扣费标记时要求管理员权限，普通用户编辑其他字段时保留原值
+	skipPreConsume := cleanToken.SkipPreConsume
+	if request.SkipPreConsume != nil {
+		if *request.SkipPreConsume != cleanToken.SkipPreConsume && statusOnly == "" && c.GetInt("role") < common.RoleAdminUser {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "仅管理员可设置跳过预扣费",
+			})
+			return
+		}
+		skipPreConsume = *request.SkipPreConsume
+	}
 	if statusOnly != "" {
 		cleanToken.Status = token.Status
 	} else {
@@ -242,6 +271,8 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.ModelLimits = token.ModelLimits
 		cleanToken.AllowIps = token.AllowIps
 		cleanToken.Group = token.Group
+		cleanToken.SkipPreConsume = skipPreConsume
+		cleanToken.QuotaHeaderEnabled = token.QuotaHeaderEnabled
 	}
 	err = cleanToken.Update()
 	if err != nil {
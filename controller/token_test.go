@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/model"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTokenTestDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sqlite handle: %v", err)
+	}
+	// 内存数据库每个连接相互独立，限制为单连接以共享同一份数据
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&model.Token{}); err != nil {
+		t.Fatalf("migrate tokens: %v", err)
+	}
+	mainDB, redisEnabled := model.DB, common.RedisEnabled
+	model.DB, common.RedisEnabled = db, false
+	t.Cleanup(func() { model.DB, common.RedisEnabled = mainDB, redisEnabled })
+}
+
+func TestUpdateTokenSkipPreConsume(t *testing.T) {
+	setupTokenTestDB(t)
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		role    int
+		current bool
+		field   string
+		success bool
+		want    bool
+	}{
+		{name: "user edits other fields of a trusted token", role: common.RoleCommonUser, current: true, success: true, want: true},
+		{name: "user sends the unchanged flag", role: common.RoleCommonUser, current: true, field: `,"skip_pre_consume":true`, success: true, want: true},
+		{name: "user cannot set the flag", role: common.RoleCommonUser, field: `,"skip_pre_consume":true`},
+		{name: "user cannot clear the flag", role: common.RoleCommonUser, current: true, field: `,"skip_pre_consume":false`, want: true},
+		{name: "admin sets the flag", role: common.RoleAdminUser, field: `,"skip_pre_consume":true`, success: true, want: true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := &model.Token{UserId: 1, Key: fmt.Sprintf("update-token-key-%d", i), Name: "old", Status: common.TokenStatusEnabled,
+				ExpiredTime: -1, UnlimitedQuota: true, SkipPreConsume: tt.current}
+			if err := token.Insert(); err != nil {
+				t.Fatalf("insert token: %v", err)
+			}
+
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			body := fmt.Sprintf(`{"id":%d,"name":"new","status":1,"expired_time":-1,"unlimited_quota":true%s}`, token.Id, tt.field)
+			c.Request = httptest.NewRequest(http.MethodPut, "/api/token/", strings.NewReader(body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Set("id", 1)
+			c.Set("role", tt.role)
+			UpdateToken(c)
+
+			var response struct {
+				Success bool   `json:"success"`
+				Message string `json:"message"`
+			}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if response.Success != tt.success {
+				t.Fatalf("success = %v (%s), want %v", response.Success, response.Message, tt.success)
+			}
+			saved, err := model.GetTokenByIds(token.Id, 1)
+			if err != nil {
+				t.Fatalf("load token: %v", err)
+			}
+			if saved.SkipPreConsume != tt.want {
+				t.Errorf("skip_pre_consume = %v, want %v", saved.SkipPreConsume, tt.want)
+			}
+			if tt.success && saved.Name != "new" {
+				t.Errorf("name = %q, want the update applied", saved.Name)
+			}
+		})
+	}
+}
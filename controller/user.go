@@ -8,7 +8,9 @@ import (
 	"one-api/common"
 	"one-api/dto"
 	"one-api/model"
+	"one-api/service"
 	"one-api/setting"
+	"one-api/setting/operation_setting"
 	"strconv"
 	"strings"
 	"sync"
@@ -558,6 +560,7 @@ func UpdateUser(c *gin.Context) {
 	}
 	if originUser.Quota != updatedUser.Quota {
 		model.RecordLog(originUser.Id, model.LogTypeManage, fmt.Sprintf("管理员将用户额度从 %s修改为 %s", common.LogQuota(originUser.Quota), common.LogQuota(updatedUser.Quota)))
+		service.RecordAudit(c, service.AuditActionUserQuota, service.AuditTargetUser, originUser.Id, gin.H{"quota": originUser.Quota}, gin.H{"quota": updatedUser.Quota})
 	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -672,6 +675,232 @@ func DeleteUser(c *gin.Context) {
 	}
 }
 
+// DeleteUserData purges the data stored for a user. Log rows are only removed
+// when the logs query parameter is true; deletion is chunked and safe to
+// repeat, also after the user account itself has been deleted.
+func DeleteUserData(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	// 用户被删除后仍可清理其数据，重复调用不会报错
+	role, found, err := model.GetUserRoleUnscoped(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	myRole := c.GetInt("role")
+	if found && myRole <= role {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权删除同权限等级或更高权限等级的用户数据",
+		})
+		return
+	}
+	var logCount int64
+	if c.Query("logs") == "true" {
+		logCount, err = model.DeleteUserLogs(c.Request.Context(), id, 100)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"logs": logCount,
+		},
+	})
+}
+
+// UpdateUserModelAlias replaces the user's model alias map, which routes a
+// requested model name to another model before channel model mapping.
+func UpdateUserModelAlias(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	var modelAlias map[string]string
+	if err := c.ShouldBindJSON(&modelAlias); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	user, err := model.GetUserById(id, true)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	myRole := c.GetInt("role")
+	if myRole <= user.Role && myRole != common.RoleRootUser {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权更新同权限等级或更高权限等级的用户信息",
+		})
+		return
+	}
+	settings := user.GetSetting()
+	settings.ModelAlias = modelAlias
+	user.SetSetting(settings)
+	if err := user.Update(false); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "更新设置失败: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+type UpdateUserStreamPolicyRequest struct {
+	StreamPolicy string `json:"stream_policy"`
+}
+
+// UpdateUserStreamPolicy sets whether the user may stream responses. An
+// empty policy falls back to the policy of the user's group.
+func UpdateUserStreamPolicy(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	var req UpdateUserStreamPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	if req.StreamPolicy != "" && !operation_setting.IsValidStreamPolicy(req.StreamPolicy) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "流式模式限制只能为 allow、disallow 或 force",
+		})
+		return
+	}
+	user, err := model.GetUserById(id, true)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	myRole := c.GetInt("role")
+	if myRole <= user.Role && myRole != common.RoleRootUser {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权更新同权限等级或更高权限等级的用户信息",
+		})
+		return
+	}
+	settings := user.GetSetting()
+	settings.StreamPolicy = req.StreamPolicy
+	user.SetSetting(settings)
+	if err := user.Update(false); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "更新设置失败: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+type UpdateUserQuotaAlertsRequest struct {
+	Thresholds      []int `json:"thresholds"`
+	IntervalMinutes int   `json:"interval_minutes"`
+}
+
+// UpdateUserQuotaAlerts lets an admin set the balance thresholds that notify
+// the user when crossed downward.
+func UpdateUserQuotaAlerts(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	var req UpdateUserQuotaAlertsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	if message := validateQuotaAlerts(req.Thresholds, req.IntervalMinutes); message != "" {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": message,
+		})
+		return
+	}
+	user, err := model.GetUserById(id, true)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	myRole := c.GetInt("role")
+	if myRole <= user.Role && myRole != common.RoleRootUser {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权更新同权限等级或更高权限等级的用户信息",
+		})
+		return
+	}
+	settings := user.GetSetting()
+	settings.QuotaAlertThresholds = req.Thresholds
+	settings.QuotaAlertIntervalMinutes = req.IntervalMinutes
+	user.SetSetting(settings)
+	if err := user.Update(false); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "更新设置失败: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
 func DeleteSelf(c *gin.Context) {
 	id := c.GetInt("id")
 	user, _ := model.GetUserById(id, false)
@@ -949,6 +1178,21 @@ type UpdateUserSettingRequest struct {
 	NotificationEmail          string  `json:"notification_email,omitempty"`
 	AcceptUnsetModelRatioModel bool    `json:"accept_unset_model_ratio_model"`
 	RecordIpLog                bool    `json:"record_ip_log"`
+	QuotaAlertThresholds       []int   `json:"quota_alert_thresholds,omitempty"`
+	QuotaAlertIntervalMinutes  int     `json:"quota_alert_interval_minutes,omitempty"`
+}
+
+// validateQuotaAlerts 校验余额预警阈值与通知间隔
+func validateQuotaAlerts(thresholds []int, intervalMinutes int) string {
+	for _, threshold := range thresholds {
+		if threshold <= 0 {
+			return "余额预警阈值必须大于0"
+		}
+	}
+	if intervalMinutes < 0 {
+		return "余额预警间隔不能为负数"
+	}
+	return ""
 }
 
 func UpdateUserSetting(c *gin.Context) {
@@ -979,6 +1223,14 @@ func UpdateUserSetting(c *gin.Context) {
 		return
 	}
 
+	if message := validateQuotaAlerts(req.QuotaAlertThresholds, req.QuotaAlertIntervalMinutes); message != "" {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": message,
+		})
+		return
+	}
+
 	// 如果是webhook类型,验证webhook地址
 	if req.QuotaWarningType == dto.NotifyTypeWebhook {
 		if req.WebhookUrl == "" {
@@ -1026,6 +1278,16 @@ func UpdateUserSetting(c *gin.Context) {
 		QuotaWarningThreshold: req.QuotaWarningThreshold,
 		AcceptUnsetRatioModel: req.AcceptUnsetModelRatioModel,
 		RecordIpLog:           req.RecordIpLog,
+		// 模型别名和流式模式限制由管理员配置，用户更新通知设置时保留
+		ModelAlias:                user.GetSetting().ModelAlias,
+		StreamPolicy:              user.GetSetting().StreamPolicy,
+		QuotaAlertThresholds:      req.QuotaAlertThresholds,
+		QuotaAlertIntervalMinutes: req.QuotaAlertIntervalMinutes,
+	}
+	if req.QuotaAlertThresholds == nil {
+		// 未提交余额预警配置时保留原有配置
+		settings.QuotaAlertThresholds = user.GetSetting().QuotaAlertThresholds
+		settings.QuotaAlertIntervalMinutes = user.GetSetting().QuotaAlertIntervalMinutes
 	}
 
 	// 如果是webhook类型,添加webhook相关设置
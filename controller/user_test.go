@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestDeleteUserDataIsIdempotent(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sqlite handle: %v", err)
+	}
+	// 内存数据库每个连接相互独立，限制为单连接以共享同一份数据
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&model.User{}, &model.Log{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	mainDB, logDB := model.DB, model.LOG_DB
+	model.DB, model.LOG_DB = db, db
+	t.Cleanup(func() { model.DB, model.LOG_DB = mainDB, logDB })
+
+	user := &model.User{Id: 5, Username: "purged", AffCode: "purged", Role: common.RoleCommonUser}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	other := &model.User{Id: 6, Username: "kept", AffCode: "kept", Role: common.RoleCommonUser}
+	if err := db.Create(other).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	for i := 0; i < 250; i++ {
+		db.Create(&model.Log{UserId: user.Id, Type: model.LogTypeConsume, Content: fmt.Sprintf("log %d", i)})
+	}
+	db.Create(&model.Log{UserId: other.Id, Type: model.LogTypeConsume})
+
+	gin.SetMode(gin.TestMode)
+	deleteUserData := func() int64 {
+		t.Helper()
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/api/user/%d/data?logs=true", user.Id), nil)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(user.Id)}}
+		c.Set("role", common.RoleAdminUser)
+		DeleteUserData(c)
+		var response struct {
+			Success bool   `json:"success"`
+			Message string `json:"message"`
+			Data    struct {
+				Logs int64 `json:"logs"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if !response.Success {
+			t.Fatalf("DeleteUserData failed: %s", response.Message)
+		}
+		return response.Data.Logs
+	}
+
+	if deleted := deleteUserData(); deleted != 250 {
+		t.Errorf("first deletion removed %d logs, want 250", deleted)
+	}
+	if deleted := deleteUserData(); deleted != 0 {
+		t.Errorf("repeated deletion removed %d logs, want 0", deleted)
+	}
+
+	// 删除账号后再次清理仍然成功
+	if err := model.HardDeleteUserById(user.Id); err != nil {
+		t.Fatalf("delete user: %v", err)
+	}
+	db.Create(&model.Log{UserId: user.Id, Type: model.LogTypeConsume})
+	if deleted := deleteUserData(); deleted != 1 {
+		t.Errorf("deletion after the account was removed deleted %d logs, want 1", deleted)
+	}
+
+	var remaining int64
+	db.Model(&model.Log{}).Count(&remaining)
+	if remaining != 1 {
+		t.Errorf("%d logs remain, want only the other user's log", remaining)
+	}
+}
+
+func TestUpdateUserModelAlias(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sqlite handle: %v", err)
+	}
+	// 内存数据库每个连接相互独立，限制为单连接以共享同一份数据
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&model.User{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	mainDB, redisEnabled := model.DB, common.RedisEnabled
+	model.DB, common.RedisEnabled = db, false
+	t.Cleanup(func() { model.DB, common.RedisEnabled = mainDB, redisEnabled })
+
+	user := &model.User{Id: 7, Username: "aliased", AffCode: "aliased", Role: common.RoleCommonUser}
+	user.SetSetting(dto.UserSetting{ModelAlias: map[string]string{"old": "gpt-3.5-turbo"}})
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	updateModelAlias := func(role int, body string) bool {
+		t.Helper()
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodPut, fmt.Sprintf("/api/user/%d/model_alias", user.Id), strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(user.Id)}}
+		c.Set("role", role)
+		UpdateUserModelAlias(c)
+		var response struct {
+			Success bool `json:"success"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		return response.Success
+	}
+	modelAlias := func() map[string]string {
+		t.Helper()
+		stored, err := model.GetUserById(user.Id, true)
+		if err != nil {
+			t.Fatalf("get user: %v", err)
+		}
+		return stored.GetSetting().ModelAlias
+	}
+
+	// 整体替换原有别名
+	if !updateModelAlias(common.RoleAdminUser, `{"smart":"gpt-4o","fast":"gpt-4o-mini"}`) {
+		t.Fatal("UpdateUserModelAlias() failed for an admin")
+	}
+	want := map[string]string{"smart": "gpt-4o", "fast": "gpt-4o-mini"}
+	if got := modelAlias(); !maps.Equal(got, want) {
+		t.Errorf("model alias = %v, want %v", got, want)
+	}
+
+	if updateModelAlias(common.RoleAdminUser, `["smart"]`) {
+		t.Error("UpdateUserModelAlias() accepted a body that is not a map")
+	}
+	if updateModelAlias(common.RoleCommonUser, `{"smart":"claude-3-5-sonnet"}`) {
+		t.Error("UpdateUserModelAlias() allowed a user of the same role")
+	}
+	if got := modelAlias(); !maps.Equal(got, want) {
+		t.Errorf("model alias after rejected updates = %v, want %v", got, want)
+	}
+
+	if !updateModelAlias(common.RoleAdminUser, `{}`) {
+		t.Fatal("UpdateUserModelAlias() failed to clear the aliases")
+	}
+	if got := modelAlias(); len(got) != 0 {
+		t.Errorf("model alias after clearing = %v, want none", got)
+	}
+}
@@ -1,7 +1,196 @@
 package dto
 
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
 type ChannelSettings struct {
 	ForceFormat       bool   `json:"force_format,omitempty"`
 	ThinkingToContent bool   `json:"thinking_to_content,omitempty"`
 	Proxy             string `json:"proxy"`
+	// HeaderOverride 会覆盖上游请求头，值中的 {model} 会替换为实际请求的模型名
+	HeaderOverride map[string]string `json:"header_override,omitempty"`
+	// AzureDeployments 模型名到 Azure 部署名的映射，未配置的模型使用模型名作为部署名
+	AzureDeployments map[string]string `json:"azure_deployments,omitempty"`
+	AzureApiVersion  string            `json:"azure_api_version,omitempty"`
+	// Transform 声明式的请求/响应改写规则，用于适配行为特殊的上游
+	Transform *ChannelTransform `json:"transform,omitempty"`
+	// KeyRotation 多密钥轮换方式（round_robin 或 random），开启后 key 按逗号拆分为多个密钥
+	KeyRotation string `json:"key_rotation,omitempty"`
+	// KeyCooldownSeconds 密钥返回鉴权失败或 429 后暂停使用的秒数，默认 60
+	KeyCooldownSeconds int `json:"key_cooldown_seconds,omitempty"`
+	// SupportedApi 渠道仅支持的文本接口（chat_completions 或 responses），为空时两者都支持。
+	// 设置后另一种接口的请求会自动转换
+	SupportedApi string `json:"supported_api,omitempty"`
+	// ClientCert/ClientKey 上游要求双向 TLS 时使用的客户端证书与私钥，
+	// 值为 PEM 内容或 secret:<路径>[#<字段>] 形式的外部密钥引用
+	ClientCert string `json:"client_cert,omitempty"`
+	ClientKey  string `json:"client_key,omitempty"`
+	// PathTemplate 替换标准接口路径中的 /v1 前缀，用于路径不标准的上游，如 /openai/v1、/us/v1。
+	// 可使用 {model}（实际请求的模型名）与 {deployment}（AzureDeployments 中的部署名，未配置时为模型名）
+	PathTemplate string `json:"path_template,omitempty"`
+}
+
+var pathTemplatePlaceholders = []string{"{model}", "{deployment}"}
+
+// ValidatePathTemplate 检查路径模板以 / 开头，不含查询串、空白字符和未知占位符
+func (s *ChannelSettings) ValidatePathTemplate() error {
+	if s.PathTemplate == "" {
+		return nil
+	}
+	if !strings.HasPrefix(s.PathTemplate, "/") {
+		return fmt.Errorf("invalid path template %q: must start with /", s.PathTemplate)
+	}
+	if strings.ContainsAny(s.PathTemplate, "?# \t\r\n") {
+		return fmt.Errorf("invalid path template %q: must not contain query, fragment or whitespace", s.PathTemplate)
+	}
+	rest := s.PathTemplate
+	for _, placeholder := range pathTemplatePlaceholders {
+		rest = strings.ReplaceAll(rest, placeholder, "")
+	}
+	if strings.ContainsAny(rest, "{}") {
+		return fmt.Errorf("invalid path template %q: only {model} and {deployment} placeholders are supported", s.PathTemplate)
+	}
+	return nil
+}
+
+// clientCertSecretPrefix 与 service.ChannelSecretPrefix 一致，引用在请求时才能解析
+const clientCertSecretPrefix = "secret:"
+
+func (s *ChannelSettings) HasClientCert() bool {
+	return s.ClientCert != "" || s.ClientKey != ""
+}
+
+// ValidateClientCert 检查证书与私钥成对配置；两者均为 PEM 内容时校验能否配对且证书未过期
+func (s *ChannelSettings) ValidateClientCert() error {
+	if !s.HasClientCert() {
+		return nil
+	}
+	if s.ClientCert == "" || s.ClientKey == "" {
+		return errors.New("client cert and client key must be set together")
+	}
+	if strings.HasPrefix(s.ClientCert, clientCertSecretPrefix) || strings.HasPrefix(s.ClientKey, clientCertSecretPrefix) {
+		return nil
+	}
+	pair, err := tls.X509KeyPair([]byte(s.ClientCert), []byte(s.ClientKey))
+	if err != nil {
+		return fmt.Errorf("invalid client cert: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("invalid client cert: %w", err)
+	}
+	if time.Now().After(leaf.NotAfter) {
+		return fmt.Errorf("client cert expired at %s", leaf.NotAfter.Format(time.RFC3339))
+	}
+	return nil
+}
+
+const (
+	KeyRotationRoundRobin = "round_robin"
+	KeyRotationRandom     = "random"
+)
+
+const (
+	SupportedApiChatCompletions = "chat_completions"
+	SupportedApiResponses       = "responses"
+)
+
+const defaultKeyCooldownSeconds = 60
+
+func (s *ChannelSettings) GetKeyCooldownSeconds() int {
+	if s.KeyCooldownSeconds > 0 {
+		return s.KeyCooldownSeconds
+	}
+	return defaultKeyCooldownSeconds
+}
+
+// TransformRules 一组改写规则，字段名只作用于 JSON 顶层
+type TransformRules struct {
+	SetHeaders    map[string]string `json:"set_headers,omitempty"`
+	RemoveHeaders []string          `json:"remove_headers,omitempty"`
+	// RenameFields 旧字段名到新字段名的映射
+	RenameFields map[string]string `json:"rename_fields,omitempty"`
+	// DefaultFields 字段不存在时写入的默认值
+	DefaultFields map[string]any `json:"default_fields,omitempty"`
+}
+
+type ChannelTransform struct {
+	Request  TransformRules `json:"request"`
+	Response TransformRules `json:"response"`
+}
+
+func (r *TransformRules) HasFieldRules() bool {
+	return len(r.RenameFields) > 0 || len(r.DefaultFields) > 0
+}
+
+func (r *TransformRules) HasHeaderRules() bool {
+	return len(r.SetHeaders) > 0 || len(r.RemoveHeaders) > 0
+}
+
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, ch := range name {
+		if ch <= ' ' || ch >= 0x7f || strings.ContainsRune("\"(),/:;<=>?@[\\]{}", ch) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *TransformRules) Validate(scope string) error {
+	for name, value := range r.SetHeaders {
+		if !validHeaderName(name) {
+			return fmt.Errorf("transform %s: invalid header name %q", scope, name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("transform %s: header %q value contains line break", scope, name)
+		}
+	}
+	for _, name := range r.RemoveHeaders {
+		if !validHeaderName(name) {
+			return fmt.Errorf("transform %s: invalid header name %q", scope, name)
+		}
+		for setName := range r.SetHeaders {
+			if strings.EqualFold(setName, name) {
+				return fmt.Errorf("transform %s: header %q is both set and removed", scope, name)
+			}
+		}
+	}
+	targets := make(map[string]string, len(r.RenameFields))
+	for from, to := range r.RenameFields {
+		if from == "" || to == "" {
+			return fmt.Errorf("transform %s: rename field name cannot be empty", scope)
+		}
+		if from == to {
+			return fmt.Errorf("transform %s: field %q is renamed to itself", scope, from)
+		}
+		if _, ok := r.RenameFields[to]; ok {
+			return fmt.Errorf("transform %s: field %q is renamed to %q which is itself renamed", scope, from, to)
+		}
+		if other, ok := targets[to]; ok {
+			return fmt.Errorf("transform %s: fields %q and %q are both renamed to %q", scope, other, from, to)
+		}
+		targets[to] = from
+	}
+	for name := range r.DefaultFields {
+		if name == "" {
+			return fmt.Errorf("transform %s: default field name cannot be empty", scope)
+		}
+	}
+	return nil
+}
+
+func (t *ChannelTransform) Validate() error {
+	if err := t.Request.Validate("request"); err != nil {
+		return err
+	}
+	return t.Response.Validate("response")
 }
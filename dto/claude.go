@@ -218,6 +218,7 @@ type ClaudeErrorWithStatusCode struct {
 	Error      ClaudeError `json:"error"`
 	StatusCode int         `json:"status_code"`
 	LocalError bool
+	Code       string `json:"-"` // 由 OpenAI 错误转换而来时保留原错误码，不返回给用户
 }
 
 type ClaudeResponse struct {
@@ -13,6 +13,9 @@ const (
 	NotifyTypeQuotaExceed   = "quota_exceed"
 	NotifyTypeChannelUpdate = "channel_update"
 	NotifyTypeChannelTest   = "channel_test"
+	NotifyTypeQueueAlert    = "queue_alert"
+	NotifyTypeGroupBudget   = "group_budget"
+	NotifyTypeQuotaAlert    = "quota_alert"
 )
 
 func NewNotify(t string, title string, content string, values []interface{}) Notify {
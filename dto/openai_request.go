@@ -38,6 +38,7 @@ type GeneralOpenAIRequest struct {
 	Instruction         string            `json:"instruction,omitempty"`
 	Size                string            `json:"size,omitempty"`
 	Functions           json.RawMessage   `json:"functions,omitempty"`
+	FunctionCall        json.RawMessage   `json:"function_call,omitempty"`
 	FrequencyPenalty    float64           `json:"frequency_penalty,omitempty"`
 	PresencePenalty     float64           `json:"presence_penalty,omitempty"`
 	ResponseFormat      *ResponseFormat   `json:"response_format,omitempty"`
@@ -47,7 +48,7 @@ type GeneralOpenAIRequest struct {
 	Tools               []ToolCallRequest `json:"tools,omitempty"`
 	ToolChoice          any               `json:"tool_choice,omitempty"`
 	User                string            `json:"user,omitempty"`
-	LogProbs            bool              `json:"logprobs,omitempty"`
+	LogProbs            json.RawMessage   `json:"logprobs,omitempty"` // chat 为 bool，旧版 completions 为整数
 	TopLogProbs         int               `json:"top_logprobs,omitempty"`
 	Dimensions          int               `json:"dimensions,omitempty"`
 	Modalities          json.RawMessage   `json:"modalities,omitempty"`
@@ -56,6 +57,7 @@ type GeneralOpenAIRequest struct {
 	THINKING            json.RawMessage   `json:"thinking,omitempty"`        // doubao
 	ExtraBody           json.RawMessage   `json:"extra_body,omitempty"`
 	WebSearchOptions    *WebSearchOptions `json:"web_search_options,omitempty"`
+	Prediction          *Prediction       `json:"prediction,omitempty"`
 	// OpenRouter Params
 	Usage     json.RawMessage `json:"usage,omitempty"`
 	Reasoning json.RawMessage `json:"reasoning,omitempty"`
@@ -63,6 +65,12 @@ type GeneralOpenAIRequest struct {
 	VlHighResolutionImages json.RawMessage `json:"vl_high_resolution_images,omitempty"`
 }
 
+// Prediction 预测输出，content 为字符串或文本内容数组
+type Prediction struct {
+	Type    string          `json:"type"`
+	Content json.RawMessage `json:"content"`
+}
+
 func (r *GeneralOpenAIRequest) ToMap() map[string]any {
 	result := make(map[string]any)
 	data, _ := common.EncodeJson(r)
@@ -110,6 +118,28 @@ func (r *GeneralOpenAIRequest) ParseInput() []string {
 	return input
 }
 
+// ParseStop returns the stop sequences of the request, which the client may
+// send as a string or an array of strings.
+func (r *GeneralOpenAIRequest) ParseStop() []string {
+	switch stop := r.Stop.(type) {
+	case string:
+		if stop != "" {
+			return []string{stop}
+		}
+	case []string:
+		return stop
+	case []any:
+		stops := make([]string, 0, len(stop))
+		for _, item := range stop {
+			if str, ok := item.(string); ok && str != "" {
+				stops = append(stops, str)
+			}
+		}
+		return stops
+	}
+	return nil
+}
+
 type Message struct {
 	Role             string          `json:"role"`
 	Content          any             `json:"content"`
@@ -117,8 +147,10 @@ type Message struct {
 	Prefix           *bool           `json:"prefix,omitempty"`
 	ReasoningContent string          `json:"reasoning_content,omitempty"`
 	Reasoning        string          `json:"reasoning,omitempty"`
+	Refusal          string          `json:"refusal,omitempty"`
 	ToolCalls        json.RawMessage `json:"tool_calls,omitempty"`
 	ToolCallId       string          `json:"tool_call_id,omitempty"`
+	FunctionCall     json.RawMessage `json:"function_call,omitempty"`
 	parsedContent    []MediaContent
 	//parsedStringContent *string
 }
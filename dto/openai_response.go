@@ -56,6 +56,7 @@ type ChatCompletionsStreamResponseChoiceDelta struct {
 	Content          *string            `json:"content,omitempty"`
 	ReasoningContent *string            `json:"reasoning_content,omitempty"`
 	Reasoning        *string            `json:"reasoning,omitempty"`
+	Refusal          *string            `json:"refusal,omitempty"`
 	Role             string             `json:"role,omitempty"`
 	ToolCalls        []ToolCallResponse `json:"tool_calls,omitempty"`
 }
@@ -173,11 +174,12 @@ type Usage struct {
 	TotalTokens          int `json:"total_tokens"`
 	PromptCacheHitTokens int `json:"prompt_cache_hit_tokens,omitempty"`
 
-	PromptTokensDetails    InputTokenDetails  `json:"prompt_tokens_details"`
-	CompletionTokenDetails OutputTokenDetails `json:"completion_tokens_details"`
-	InputTokens            int                `json:"input_tokens"`
-	OutputTokens           int                `json:"output_tokens"`
-	InputTokensDetails     *InputTokenDetails `json:"input_tokens_details"`
+	PromptTokensDetails    InputTokenDetails   `json:"prompt_tokens_details"`
+	CompletionTokenDetails OutputTokenDetails  `json:"completion_tokens_details"`
+	InputTokens            int                 `json:"input_tokens"`
+	OutputTokens           int                 `json:"output_tokens"`
+	InputTokensDetails     *InputTokenDetails  `json:"input_tokens_details"`
+	OutputTokensDetails    *OutputTokenDetails `json:"output_tokens_details,omitempty"`
 	// OpenRouter Params
 	Cost float64 `json:"cost,omitempty"`
 }
@@ -193,7 +195,11 @@ type InputTokenDetails struct {
 type OutputTokenDetails struct {
 	TextTokens      int `json:"text_tokens"`
 	AudioTokens     int `json:"audio_tokens"`
+	ImageTokens     int `json:"image_tokens,omitempty"`
 	ReasoningTokens int `json:"reasoning_tokens"`
+	// 预测输出中被采纳/拒绝的 token，均已计入 completion_tokens
+	AcceptedPredictionTokens int `json:"accepted_prediction_tokens,omitempty"`
+	RejectedPredictionTokens int `json:"rejected_prediction_tokens,omitempty"`
 }
 
 type OpenAIResponsesResponse struct {
@@ -229,8 +235,14 @@ type ResponsesOutput struct {
 	Type    string                   `json:"type"`
 	ID      string                   `json:"id"`
 	Status  string                   `json:"status"`
-	Role    string                   `json:"role"`
-	Content []ResponsesOutputContent `json:"content"`
+	Role    string                   `json:"role,omitempty"`
+	Content []ResponsesOutputContent `json:"content,omitempty"`
+	// function_call
+	CallId    string `json:"call_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+	// reasoning
+	Summary []ResponsesOutputContent `json:"summary,omitempty"`
 }
 
 type ResponsesOutputContent struct {
@@ -253,10 +265,35 @@ const (
 	ResponsesOutputTypeItemDone  = "response.output_item.done"
 )
 
+const (
+	ResponsesStreamTypeCreated                = "response.created"
+	ResponsesStreamTypeCompleted              = "response.completed"
+	ResponsesStreamTypeIncomplete             = "response.incomplete"
+	ResponsesStreamTypeContentPartAdded       = "response.content_part.added"
+	ResponsesStreamTypeContentPartDone        = "response.content_part.done"
+	ResponsesStreamTypeOutputTextDelta        = "response.output_text.delta"
+	ResponsesStreamTypeOutputTextDone         = "response.output_text.done"
+	ResponsesStreamTypeReasoningSummaryDelta  = "response.reasoning_summary_text.delta"
+	ResponsesStreamTypeFunctionArgumentsDelta = "response.function_call_arguments.delta"
+	ResponsesStreamTypeFunctionArgumentsDone  = "response.function_call_arguments.done"
+)
+
+const (
+	ResponsesOutputItemMessage      = "message"
+	ResponsesOutputItemFunctionCall = "function_call"
+	ResponsesOutputItemReasoning    = "reasoning"
+)
+
 // ResponsesStreamResponse 用于处理 /v1/responses 流式响应
 type ResponsesStreamResponse struct {
-	Type     string                   `json:"type"`
-	Response *OpenAIResponsesResponse `json:"response,omitempty"`
-	Delta    string                   `json:"delta,omitempty"`
-	Item     *ResponsesOutput         `json:"item,omitempty"`
+	Type         string                   `json:"type"`
+	Response     *OpenAIResponsesResponse `json:"response,omitempty"`
+	Delta        string                   `json:"delta,omitempty"`
+	Item         *ResponsesOutput         `json:"item,omitempty"`
+	ItemId       string                   `json:"item_id,omitempty"`
+	OutputIndex  *int                     `json:"output_index,omitempty"`
+	ContentIndex *int                     `json:"content_index,omitempty"`
+	Part         *ResponsesOutputContent  `json:"part,omitempty"`
+	Text         string                   `json:"text,omitempty"`
+	Arguments    string                   `json:"arguments,omitempty"`
 }
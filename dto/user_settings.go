@@ -1,13 +1,20 @@
 package dto
 
 type UserSetting struct {
-	NotifyType            string  `json:"notify_type,omitempty"`                    // QuotaWarningType 额度预警类型
-	QuotaWarningThreshold float64 `json:"quota_warning_threshold,omitempty"`        // QuotaWarningThreshold 额度预警阈值
-	WebhookUrl            string  `json:"webhook_url,omitempty"`                    // WebhookUrl webhook地址
-	WebhookSecret         string  `json:"webhook_secret,omitempty"`                 // WebhookSecret webhook密钥
-	NotificationEmail     string  `json:"notification_email,omitempty"`             // NotificationEmail 通知邮箱地址
-	AcceptUnsetRatioModel bool    `json:"accept_unset_model_ratio_model,omitempty"` // AcceptUnsetRatioModel 是否接受未设置价格的模型
-	RecordIpLog           bool    `json:"record_ip_log,omitempty"`                  // 是否记录请求和错误日志IP
+	NotifyType            string            `json:"notify_type,omitempty"`                    // QuotaWarningType 额度预警类型
+	QuotaWarningThreshold float64           `json:"quota_warning_threshold,omitempty"`        // QuotaWarningThreshold 额度预警阈值
+	WebhookUrl            string            `json:"webhook_url,omitempty"`                    // WebhookUrl webhook地址
+	WebhookSecret         string            `json:"webhook_secret,omitempty"`                 // WebhookSecret webhook密钥
+	NotificationEmail     string            `json:"notification_email,omitempty"`             // NotificationEmail 通知邮箱地址
+	AcceptUnsetRatioModel bool              `json:"accept_unset_model_ratio_model,omitempty"` // AcceptUnsetRatioModel 是否接受未设置价格的模型
+	RecordIpLog           bool              `json:"record_ip_log,omitempty"`                  // 是否记录请求和错误日志IP
+	ModelAlias            map[string]string `json:"model_alias,omitempty"`                    // ModelAlias 用户级模型别名，优先于分组别名
+	// QuotaAlertThresholds 余额预警阈值（额度），扣费后余额向下越过任一阈值时发送通知
+	QuotaAlertThresholds []int `json:"quota_alert_thresholds,omitempty"`
+	// QuotaAlertIntervalMinutes 同一阈值两次通知的最小间隔（分钟），默认 1440
+	QuotaAlertIntervalMinutes int `json:"quota_alert_interval_minutes,omitempty"`
+	// StreamPolicy 流式模式限制：allow、disallow 或 force，为空时使用分组配置
+	StreamPolicy string `json:"stream_policy,omitempty"`
 }
 
 var (
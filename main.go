@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -14,7 +16,10 @@ import (
 	"one-api/service"
 	"one-api/setting/ratio_setting"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/bytedance/gopkg/util/gopool"
 	"github.com/gin-contrib/sessions"
@@ -83,6 +88,9 @@ func main() {
 	// 热更新配置
 	go model.SyncOptions(common.SyncFrequency)
 
+	model.InitRateLimitTierCache()
+	go model.SyncRateLimitTierCache(common.SyncFrequency)
+
 	// 数据看板
 	go model.UpdateQuotaData()
 
@@ -110,9 +118,16 @@ func main() {
 	}
 	if os.Getenv("BATCH_UPDATE_ENABLED") == "true" {
 		common.BatchUpdateEnabled = true
-		common.SysLog("batch update enabled with interval " + strconv.Itoa(common.BatchUpdateInterval) + "s")
+		common.SysLog("batch update enabled with interval " + strconv.Itoa(common.BatchUpdateInterval) + "s and " + strconv.Itoa(common.BatchUpdateWorkers) + " workers")
 		model.InitBatchUpdater()
 	}
+	if common.BatchLogInsertEnabled {
+		common.SysLog("batch log insert enabled with size " + strconv.Itoa(common.BatchLogInsertSize))
+		model.InitLogBatchInserter()
+	}
+	service.InitEventSink()
+	service.InitLogExporter()
+	service.InitGroupBudgetChecker()
 
 	if os.Getenv("ENABLE_PPROF") == "true" {
 		gopool.Go(func() {
@@ -124,6 +139,12 @@ func main() {
 
 	// Initialize HTTP server
 	server := gin.New()
+	// 仅信任指定代理转发的客户端 IP，未设置时保持 gin 默认行为
+	if len(constant.TrustedProxies) > 0 {
+		if err := server.SetTrustedProxies(constant.TrustedProxies); err != nil {
+			common.FatalLog("failed to set trusted proxies: " + err.Error())
+		}
+	}
 	server.Use(gin.CustomRecovery(func(c *gin.Context, err any) {
 		common.SysError(fmt.Sprintf("panic detected: %v", err))
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -153,10 +174,31 @@ func main() {
 	if port == "" {
 		port = strconv.Itoa(*common.Port)
 	}
-	err = server.Run(":" + port)
-	if err != nil {
-		common.FatalLog("failed to start HTTP server: " + err.Error())
+	srv := &http.Server{
+		Addr:    ":" + port,
+		Handler: server,
+	}
+	go func() {
+		err := srv.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			common.FatalLog("failed to start HTTP server: " + err.Error())
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	common.SysLog("shutting down server...")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		common.SysError("server forced to shutdown: " + err.Error())
 	}
+	// 写入缓冲中的额度更新和日志，避免丢失
+	model.FlushBatchUpdates()
+	model.SaveGroupSpend()
+	model.FlushLogBatch()
+	service.FlushEventSink()
 }
 
 func InitResources() error {
@@ -178,6 +220,8 @@ func InitResources() error {
 
 	service.InitHttpClient()
 
+	service.InitSecretBackend()
+
 	service.InitTokenEncoders()
 
 	// Initialize SQL Database
@@ -201,6 +245,11 @@ func InitResources() error {
 		return err
 	}
 
+	err = model.InitLogReplicaDB()
+	if err != nil {
+		return err
+	}
+
 	// Initialize Redis
 	err = common.InitRedisClient()
 	if err != nil {
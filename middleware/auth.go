@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/model"
+	"one-api/service"
 	"strconv"
 	"strings"
 
@@ -247,8 +248,19 @@ func TokenAuth() func(c *gin.Context) {
 		} else {
 			c.Set("token_model_limit_enabled", false)
 		}
-		c.Set("allow_ips", token.GetIpLimitsMap())
+		allowIps := token.GetIpLimitsMap()
+		if len(allowIps) != 0 && !common.IsIPAllowed(c.ClientIP(), allowIps) {
+			abortWithOpenAiMessage(c, http.StatusForbidden, "您的 IP 不在令牌允许访问的列表中")
+			return
+		}
+		c.Set("allow_ips", allowIps)
+		if err := service.CheckTokenFingerprint(c, token.UserId, token.Id, token.Name); err != nil {
+			abortWithOpenAiMessage(c, http.StatusForbidden, err.Error())
+			return
+		}
 		c.Set("token_group", token.Group)
+		c.Set("token_skip_pre_consume", token.SkipPreConsume)
+		c.Set("token_quota_header", token.QuotaHeaderEnabled)
 		if len(parts) > 1 {
 			if model.IsAdmin(token.UserId) {
 				c.Set("specific_channel_id", parts[1])
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/model"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTokenAuthIPAllowlist(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// 令牌按 key 列查询，通过 InitDB 初始化列名并迁移数据表
+	t.Setenv("SQL_DSN", "")
+	mainDB, sqlitePath, usingSQLite := model.DB, common.SQLitePath, common.UsingSQLite
+	redisEnabled, isMasterNode := common.RedisEnabled, common.IsMasterNode
+	common.SQLitePath, common.RedisEnabled, common.IsMasterNode = filepath.Join(t.TempDir(), "one-api.db"), false, true
+	t.Cleanup(func() {
+		model.DB, common.SQLitePath, common.UsingSQLite = mainDB, sqlitePath, usingSQLite
+		common.RedisEnabled, common.IsMasterNode = redisEnabled, isMasterNode
+	})
+	if err := model.InitDB(); err != nil {
+		t.Fatalf("init db: %v", err)
+	}
+	db := model.DB
+
+	user := &model.User{Id: 1, Username: "ip-user", Status: common.UserStatusEnabled, Group: "default"}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	tokens := map[string]string{
+		"iponlytoken": "203.0.113.7",
+		"cidrtoken":   "10.0.0.0/8\n203.0.113.7",
+		"opentoken":   "",
+	}
+	for key, allowIps := range tokens {
+		allowIps := allowIps
+		token := &model.Token{UserId: user.Id, Name: key, Key: key, Status: common.TokenStatusEnabled,
+			ExpiredTime: -1, UnlimitedQuota: true, AllowIps: &allowIps}
+		if err := db.Create(token).Error; err != nil {
+			t.Fatalf("create token: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		key      string
+		clientIp string
+		want     int
+	}{
+		{name: "listed ip", key: "iponlytoken", clientIp: "203.0.113.7", want: http.StatusOK},
+		{name: "unlisted ip", key: "iponlytoken", clientIp: "203.0.113.8", want: http.StatusForbidden},
+		{name: "ip in cidr range", key: "cidrtoken", clientIp: "10.1.2.3", want: http.StatusOK},
+		{name: "ip outside cidr range", key: "cidrtoken", clientIp: "192.168.1.1", want: http.StatusForbidden},
+		{name: "token without allowlist", key: "opentoken", clientIp: "192.168.1.1", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			// 鉴权在 TokenAuth 中完成，不依赖渠道分发
+			router.GET("/v1/models", TokenAuth(), func(c *gin.Context) { c.Status(http.StatusOK) })
+			req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+			req.RemoteAddr = tt.clientIp + ":12345"
+			req.Header.Set("Authorization", "Bearer sk-"+tt.key)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.want {
+				t.Errorf("status = %d, want %d, body = %s", recorder.Code, tt.want, recorder.Body.String())
+			}
+		})
+	}
+}
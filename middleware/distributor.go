@@ -8,9 +8,12 @@ import (
 	"one-api/constant"
 	"one-api/dto"
 	"one-api/model"
+	relaycommon "one-api/relay/common"
 	relayconstant "one-api/relay/constant"
 	"one-api/service"
 	"one-api/setting"
+	"one-api/setting/model_setting"
+	"one-api/setting/operation_setting"
 	"one-api/setting/ratio_setting"
 	"strconv"
 	"strings"
@@ -25,14 +28,6 @@ type ModelRequest struct {
 
 func Distribute() func(c *gin.Context) {
 	return func(c *gin.Context) {
-		allowIpsMap := common.GetContextKeyStringMap(c, constant.ContextKeyTokenAllowIps)
-		if len(allowIpsMap) != 0 {
-			clientIp := c.ClientIP()
-			if _, ok := allowIpsMap[clientIp]; !ok {
-				abortWithOpenAiMessage(c, http.StatusForbidden, "您的 IP 不在令牌允许访问的列表中")
-				return
-			}
-		}
 		var channel *model.Channel
 		channelId, ok := common.GetContextKey(c, constant.ContextKeyTokenSpecificChannelId)
 		modelRequest, shouldSelectChannel, err := getModelRequest(c)
@@ -40,7 +35,15 @@ func Distribute() func(c *gin.Context) {
 			abortWithOpenAiMessage(c, http.StatusBadRequest, "Invalid request, "+err.Error())
 			return
 		}
+		if abortIfModelDenied(c, common.GetContextKeyString(c, constant.ContextKeyRequestModel), modelRequest.Model) {
+			return
+		}
 		userGroup := common.GetContextKeyString(c, constant.ContextKeyUserGroup)
+		if userGroup == "" {
+			userGroup = constant.DefaultGroup
+			common.SetContextKey(c, constant.ContextKeyUserGroup, userGroup)
+			common.LogWarn(c, fmt.Sprintf("user %d has no group, falling back to default group %s", c.GetInt("id"), userGroup))
+		}
 		tokenGroup := common.GetContextKeyString(c, constant.ContextKeyTokenGroup)
 		if tokenGroup != "" {
 			// check common.UserUsableGroups[userGroup]
@@ -58,6 +61,10 @@ func Distribute() func(c *gin.Context) {
 			userGroup = tokenGroup
 		}
 		common.SetContextKey(c, constant.ContextKeyUsingGroup, userGroup)
+		if service.IsGroupBudgetExceeded(userGroup) {
+			abortWithOpenAiMessage(c, http.StatusForbidden, fmt.Sprintf("分组 %s 本周期消费已达上限", userGroup))
+			return
+		}
 		if ok {
 			id, err := strconv.Atoi(channelId.(string))
 			if err != nil {
@@ -73,6 +80,9 @@ func Distribute() func(c *gin.Context) {
 				abortWithOpenAiMessage(c, http.StatusForbidden, "该渠道已被禁用")
 				return
 			}
+			if !applyModelAlias(c, modelRequest, userGroup) || abortIfContextLimitExceeded(c, modelRequest.Model) {
+				return
+			}
 		} else {
 			// Select a channel for the user
 			// check token model mapping
@@ -96,10 +106,18 @@ func Distribute() func(c *gin.Context) {
 					return
 				}
 			}
+			if !applyModelAlias(c, modelRequest, userGroup) || abortIfContextLimitExceeded(c, modelRequest.Model) {
+				return
+			}
 
 			if shouldSelectChannel {
 				var selectGroup string
 				channel, selectGroup, err = model.CacheGetRandomSatisfiedChannel(c, userGroup, modelRequest.Model, 0)
+				if err != nil && channel == nil {
+					if downgradeChannel, downgradeGroup, ok := downgradeUnavailableModel(c, modelRequest, userGroup); ok {
+						channel, selectGroup, err = downgradeChannel, downgradeGroup, nil
+					}
+				}
 				if err != nil {
 					showGroup := userGroup
 					if userGroup == "auto" {
@@ -122,7 +140,10 @@ func Distribute() func(c *gin.Context) {
 			}
 		}
 		common.SetContextKey(c, constant.ContextKeyRequestStartTime, time.Now())
-		SetupContextForSelectedChannel(c, channel, modelRequest.Model)
+		if err := SetupContextForSelectedChannel(c, channel, modelRequest.Model); err != nil {
+			abortWithOpenAiMessage(c, http.StatusInternalServerError, err.Error())
+			return
+		}
 		c.Next()
 	}
 }
@@ -222,6 +243,9 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 	} else if strings.HasPrefix(c.Request.URL.Path, "/v1/images/edits") {
 		modelRequest.Model = common.GetStringIfEmpty(c.PostForm("model"), "gpt-image-1")
 	}
+	// 归一化前保留客户端请求的模型名称，用于响应中还原
+	common.SetContextKey(c, constant.ContextKeyRequestModel, modelRequest.Model)
+	modelRequest.Model = model_setting.NormalizeModelName(modelRequest.Model)
 	if strings.HasPrefix(c.Request.URL.Path, "/v1/audio") {
 		relayMode := relayconstant.RelayModeAudioSpeech
 		if strings.HasPrefix(c.Request.URL.Path, "/v1/audio/speech") {
@@ -240,10 +264,137 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 	return &modelRequest, shouldSelectChannel, nil
 }
 
-func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, modelName string) {
+// applyModelAlias routes the request to the user's or group's alias target,
+// then to an A/B variant when the result is an A/B alias. The client-facing
+// name is kept in ContextKeyRequestModel for logs and response rewriting. It
+// aborts and returns false if the target is denied.
+func applyModelAlias(c *gin.Context, modelRequest *ModelRequest, group string) bool {
+	userSetting, _ := common.GetContextKeyType[dto.UserSetting](c, constant.ContextKeyUserSetting)
+	modelRequest.Model = model_setting.ResolveModelAlias(group, userSetting.ModelAlias, modelRequest.Model)
+	if variant, variantModel, ok := model_setting.PickABVariant(modelRequest.Model, c.GetInt("id")); ok {
+		common.SetContextKey(c, constant.ContextKeyABTest, modelRequest.Model)
+		common.SetContextKey(c, constant.ContextKeyABVariant, variant)
+		modelRequest.Model = variantModel
+	}
+	return !abortIfModelDenied(c, modelRequest.Model)
+}
+
+// downgradeUnavailableModel switches the request to the model's configured
+// downgrade when none of the model's channels is available. It reports false
+// when no downgrade applies or the downgrade has no channel either.
+func downgradeUnavailableModel(c *gin.Context, modelRequest *ModelRequest, group string) (*model.Channel, string, bool) {
+	target, ok := model_setting.GetModelDowngrade(modelRequest.Model)
+	if !ok || operation_setting.IsModelDenied(target) || !tokenAllowsModel(c, target) {
+		return nil, group, false
+	}
+	channel, selectGroup, err := model.CacheGetRandomSatisfiedChannel(c, group, target, 0)
+	if err != nil || channel == nil {
+		return nil, group, false
+	}
+	common.LogInfo(c, fmt.Sprintf("model %s has no available channel, downgraded to %s", modelRequest.Model, target))
+	common.SetContextKey(c, constant.ContextKeyDowngradedFrom, modelRequest.Model)
+	c.Header("X-Model-Downgraded-From", modelRequest.Model)
+	modelRequest.Model = target
+	return channel, selectGroup, true
+}
+
+// tokenAllowsModel reports whether the token's model limit, if any, covers the model.
+func tokenAllowsModel(c *gin.Context, modelName string) bool {
+	if !common.GetContextKeyBool(c, constant.ContextKeyTokenModelLimitEnabled) {
+		return true
+	}
+	tokenModelLimit, _ := common.GetContextKeyType[map[string]bool](c, constant.ContextKeyTokenModelLimit)
+	_, ok := tokenModelLimit[modelName]
+	return ok
+}
+
+// CheckModelAccess reports whether the request may use the model: it must
+// not be on the global deny-list and must be covered by the token's model
+// limit. It is used for models the gateway calls on behalf of the request.
+func CheckModelAccess(c *gin.Context, modelName string) error {
+	if operation_setting.IsModelDenied(modelName) {
+		return fmt.Errorf("模型 %s 已被禁用", modelName)
+	}
+	if !tokenAllowsModel(c, modelName) {
+		return fmt.Errorf("该令牌无权访问模型 %s", modelName)
+	}
+	return nil
+}
+
+// abortIfModelDenied rejects models on the global deny-list.
+func abortIfModelDenied(c *gin.Context, modelNames ...string) bool {
+	for _, modelName := range modelNames {
+		if operation_setting.IsModelDenied(modelName) {
+			abortWithOpenAiMessage(c, http.StatusForbidden, fmt.Sprintf("模型 %s 已被禁用", modelName))
+			return true
+		}
+	}
+	return false
+}
+
+// abortIfContextLimitExceeded rejects chat, completions and responses
+// requests whose prompt tokens plus requested max tokens exceed the max
+// context tokens configured for the model. It runs before a channel is
+// selected, so no upstream request is made and no quota is reserved.
+func abortIfContextLimitExceeded(c *gin.Context, modelName string) bool {
+	limit, ok := model_setting.GetModelMaxContextTokens(modelName)
+	if !ok {
+		return false
+	}
+	promptTokens, maxTokens, ok := countContextTokens(c, modelName)
+	if !ok || promptTokens+maxTokens <= limit {
+		return false
+	}
+	abortWithOpenAiMessage(c, http.StatusBadRequest, fmt.Sprintf("this model's maximum context length is %d tokens, but the request has %d prompt tokens and %d max tokens (%d in total); please reduce the messages or max_tokens",
+		limit, promptTokens, maxTokens, promptTokens+maxTokens))
+	return true
+}
+
+// countContextTokens counts the prompt tokens and requested max tokens of a
+// text request. Requests that cannot be decoded or counted are left to the
+// relay, which reports the error.
+func countContextTokens(c *gin.Context, modelName string) (int, int, bool) {
+	switch relayMode := relayconstant.Path2RelayMode(c.Request.URL.Path); relayMode {
+	case relayconstant.RelayModeChatCompletions, relayconstant.RelayModeCompletions:
+		var request dto.GeneralOpenAIRequest
+		if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+			return 0, 0, false
+		}
+		request.Model = modelName
+		maxTokens := int(max(request.MaxTokens, request.MaxCompletionTokens))
+		if relayMode == relayconstant.RelayModeCompletions {
+			return service.CountTokenInput(request.Prompt, modelName), maxTokens, true
+		}
+		promptTokens, err := service.CountTokenChatRequest(&relaycommon.RelayInfo{}, request)
+		if err != nil {
+			return 0, 0, false
+		}
+		return promptTokens, maxTokens, true
+	case relayconstant.RelayModeResponses:
+		var request dto.OpenAIResponsesRequest
+		if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+			return 0, 0, false
+		}
+		return service.CountTokenInput(request.Input, modelName), int(request.MaxOutputTokens), true
+	}
+	return 0, 0, false
+}
+
+// SetupContextForSelectedChannel writes the selected channel into the request
+// context. It fails only when the channel key cannot be resolved from the
+// secrets backend.
+func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, modelName string) error {
 	c.Set("original_model", modelName) // for retry
 	if channel == nil {
-		return
+		return nil
+	}
+	channelKey := model.SelectChannelKey(channel)
+	common.SetContextKey(c, constant.ContextKeyChannelKey, channelKey)
+	key, err := service.ResolveChannelKey(c.Request.Context(), channelKey)
+	if err != nil {
+		// 错误中包含密钥路径和密钥后端的响应，只记录到日志，不返回给客户端
+		common.LogError(c, fmt.Sprintf("渠道 #%d 密钥获取失败: %s", channel.Id, err.Error()))
+		return errors.New("渠道密钥获取失败，请联系管理员")
 	}
 	c.Set("channel_id", channel.Id)
 	c.Set("channel_name", channel.Name)
@@ -257,7 +408,7 @@ func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, mode
 	c.Set("auto_ban", channel.GetAutoBan())
 	c.Set("model_mapping", channel.GetModelMapping())
 	c.Set("status_code_mapping", channel.GetStatusCodeMapping())
-	c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", channel.Key))
+	c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", key))
 	common.SetContextKey(c, constant.ContextKeyBaseUrl, channel.GetBaseURL())
 	// TODO: api_version统一
 	switch channel.Type {
@@ -278,6 +429,7 @@ func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, mode
 	case constant.ChannelTypeCoze:
 		c.Set("bot_id", channel.Other)
 	}
+	return nil
 }
 
 // extractModelNameFromGeminiPath 从 Gemini API URL 路径中提取模型名
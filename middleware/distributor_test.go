@@ -0,0 +1,231 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"one-api/setting/model_setting"
+	"one-api/setting/operation_setting"
+	"one-api/setting/ratio_setting"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAbortIfContextLimitExceeded(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	settings := model_setting.GetModelContextLimitSettings()
+	saved := settings.Models
+	settings.Models = map[string]int{"gpt-4o": 100}
+	t.Cleanup(func() { settings.Models = saved })
+
+	longPrompt := strings.Repeat("hello ", 200)
+	tests := []struct {
+		name    string
+		path    string
+		model   string
+		body    string
+		aborted bool
+	}{
+		{name: "chat within limit", path: "/v1/chat/completions", model: "gpt-4o",
+			body: `{"model":"gpt-4o","max_tokens":50,"messages":[{"role":"user","content":"hi"}]}`},
+		{name: "chat prompt over limit", path: "/v1/chat/completions", model: "gpt-4o",
+			body: `{"model":"gpt-4o","messages":[{"role":"user","content":"` + longPrompt + `"}]}`, aborted: true},
+		{name: "chat max tokens over limit", path: "/v1/chat/completions", model: "gpt-4o",
+			body: `{"model":"gpt-4o","max_completion_tokens":200,"messages":[{"role":"user","content":"hi"}]}`, aborted: true},
+		{name: "completions over limit", path: "/v1/completions", model: "gpt-4o",
+			body: `{"model":"gpt-4o","prompt":"hi","max_tokens":120}`, aborted: true},
+		{name: "responses within limit", path: "/v1/responses", model: "gpt-4o",
+			body: `{"model":"gpt-4o","input":"hi","max_output_tokens":50}`},
+		{name: "responses over limit", path: "/v1/responses", model: "gpt-4o",
+			body: `{"model":"gpt-4o","input":"` + longPrompt + `"}`, aborted: true},
+		{name: "model without limit", path: "/v1/chat/completions", model: "gpt-4o-mini",
+			body: `{"model":"gpt-4o-mini","max_tokens":100000,"messages":[{"role":"user","content":"hi"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			c.Request = httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			if got := abortIfContextLimitExceeded(c, tt.model); got != tt.aborted {
+				t.Fatalf("abortIfContextLimitExceeded() = %v, want %v", got, tt.aborted)
+			}
+			if !tt.aborted {
+				return
+			}
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", recorder.Code, http.StatusBadRequest)
+			}
+			if !strings.Contains(recorder.Body.String(), "maximum context length is 100 tokens") {
+				t.Errorf("body = %s, want the configured limit in the message", recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestDistributeFallsBackToDefaultGroup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupDowngradeTestChannels(t,
+		&model.Channel{Id: 1, Name: "default", Type: constant.ChannelTypeOpenAI, Key: "key",
+			Status: common.ChannelStatusEnabled, Group: "default", Models: "gpt-4o"},
+		&model.Channel{Id: 2, Name: "vip", Type: constant.ChannelTypeOpenAI, Key: "key",
+			Status: common.ChannelStatusEnabled, Group: "vip", Models: "gpt-4o"})
+	defaultGroup, groupRatio := constant.DefaultGroup, ratio_setting.GroupRatio2JSONString()
+	constant.DefaultGroup = "vip"
+	if err := ratio_setting.UpdateGroupRatioByJSONString(`{"default":1,"vip":2}`); err != nil {
+		t.Fatalf("update group ratio: %v", err)
+	}
+	t.Cleanup(func() {
+		constant.DefaultGroup = defaultGroup
+		_ = ratio_setting.UpdateGroupRatioByJSONString(groupRatio)
+	})
+
+	tests := []struct {
+		name      string
+		userGroup string
+		wantGroup string
+		channelId int
+		ratio     float64
+	}{
+		{name: "user without a group uses DEFAULT_GROUP", wantGroup: "vip", channelId: 2, ratio: 2},
+		{name: "user group is kept", userGroup: "default", wantGroup: "default", channelId: 1, ratio: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var info *relaycommon.RelayInfo
+			var groupRatio float64
+			router := gin.New()
+			router.POST("/v1/chat/completions", func(c *gin.Context) {
+				common.SetContextKey(c, constant.ContextKeyUserGroup, tt.userGroup)
+			}, Distribute(), func(c *gin.Context) {
+				info = relaycommon.GenRelayInfo(c)
+				groupRatio = helper.HandleGroupRatio(c, info).GroupRatio
+			})
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions",
+				strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if info == nil {
+				t.Fatalf("request aborted: %d %s", recorder.Code, recorder.Body.String())
+			}
+			if info.UsingGroup != tt.wantGroup || info.UserGroup != tt.wantGroup {
+				t.Errorf("using group = %q, user group = %q, want %q", info.UsingGroup, info.UserGroup, tt.wantGroup)
+			}
+			if info.ChannelId != tt.channelId {
+				t.Errorf("channel = %d, want %d from group %s", info.ChannelId, tt.channelId, tt.wantGroup)
+			}
+			if groupRatio != tt.ratio {
+				t.Errorf("group ratio = %v, want %v", groupRatio, tt.ratio)
+			}
+		})
+	}
+}
+
+func TestApplyModelAliasPrefersUserAlias(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	settings := model_setting.GetGlobalSettings()
+	saved := settings.GroupModelAlias
+	settings.GroupModelAlias = map[string]map[string]string{"vip": {"smart": "gpt-4o", "fast": "gpt-4o-mini"}}
+	t.Cleanup(func() { settings.GroupModelAlias = saved })
+
+	tests := []struct {
+		name      string
+		userAlias map[string]string
+		model     string
+		want      string
+	}{
+		{name: "user alias wins over group alias", userAlias: map[string]string{"smart": "claude-3-5-sonnet"}, model: "smart", want: "claude-3-5-sonnet"},
+		{name: "group alias applies when the user has none for the model", userAlias: map[string]string{"smart": "claude-3-5-sonnet"}, model: "fast", want: "gpt-4o-mini"},
+		{name: "group alias for a user without aliases", model: "smart", want: "gpt-4o"},
+		{name: "unaliased model is kept", userAlias: map[string]string{"smart": "claude-3-5-sonnet"}, model: "gpt-4o", want: "gpt-4o"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			common.SetContextKey(c, constant.ContextKeyUserSetting, dto.UserSetting{ModelAlias: tt.userAlias})
+
+			modelRequest := &ModelRequest{Model: tt.model}
+			if !applyModelAlias(c, modelRequest, "vip") {
+				t.Fatal("applyModelAlias() aborted the request")
+			}
+			if modelRequest.Model != tt.want {
+				t.Errorf("model = %q, want %q", modelRequest.Model, tt.want)
+			}
+		})
+	}
+}
+
+func TestAbortIfModelDenied(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setting := operation_setting.GetModelDenySetting()
+	saved := setting.Models
+	setting.Models = []string{"gpt-4-32k", "o1-pro"}
+	t.Cleanup(func() { setting.Models = saved })
+
+	tests := []struct {
+		name       string
+		modelNames []string
+		denied     string
+	}{
+		{name: "allowed model", modelNames: []string{"gpt-4o"}},
+		{name: "denied model", modelNames: []string{"o1-pro"}, denied: "o1-pro"},
+		{name: "comparison ignores case", modelNames: []string{"GPT-4-32K"}, denied: "GPT-4-32K"},
+		{name: "first denied name is reported", modelNames: []string{"O1-Pro", "gpt-4o"}, denied: "O1-Pro"},
+		{name: "resolved model denied", modelNames: []string{"gpt-4o", "gpt-4-32k"}, denied: "gpt-4-32k"},
+		{name: "empty names are ignored", modelNames: []string{"", "gpt-4o"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+			if got := abortIfModelDenied(c, tt.modelNames...); got != (tt.denied != "") {
+				t.Fatalf("abortIfModelDenied(%v) = %v, want %v", tt.modelNames, got, tt.denied != "")
+			}
+			if tt.denied == "" {
+				if c.IsAborted() {
+					t.Error("allowed request was aborted")
+				}
+				return
+			}
+			if !c.IsAborted() || recorder.Code != http.StatusForbidden {
+				t.Errorf("aborted = %v, status = %d, want an aborted 403", c.IsAborted(), recorder.Code)
+			}
+			if !strings.Contains(recorder.Body.String(), "模型 "+tt.denied+" 已被禁用") {
+				t.Errorf("body = %s, want the denied model in the message", recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestApplyModelAliasRejectsDeniedTarget(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	denySetting := operation_setting.GetModelDenySetting()
+	savedDenied := denySetting.Models
+	denySetting.Models = []string{"o1-pro"}
+	t.Cleanup(func() { denySetting.Models = savedDenied })
+
+	// 别名不能绕过禁用列表
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	common.SetContextKey(c, constant.ContextKeyUserSetting, dto.UserSetting{ModelAlias: map[string]string{"smart": "o1-pro"}})
+	if applyModelAlias(c, &ModelRequest{Model: "smart"}, "default") {
+		t.Fatal("applyModelAlias() allowed an alias to a denied model")
+	}
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusForbidden)
+	}
+}
@@ -41,7 +41,7 @@ func KlingRequestConvert() func(c *gin.Context) {
 		}
 
 		// We have to reset the request body for the next handlers
-		c.Set(common.KeyRequestBody, jsonData)
+		common.SetRequestBody(c, jsonData)
 		c.Next()
 	}
 }
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"one-api/setting/model_setting"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupDowngradeTestChannels(t *testing.T, channels ...*model.Channel) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sqlite handle: %v", err)
+	}
+	// 内存数据库每个连接相互独立，限制为单连接以共享同一份数据
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&model.Channel{}, &model.Ability{}); err != nil {
+		t.Fatalf("migrate channels: %v", err)
+	}
+	mainDB, memoryCacheEnabled := model.DB, common.MemoryCacheEnabled
+	model.DB, common.MemoryCacheEnabled = db, true
+	t.Cleanup(func() {
+		model.DB, common.MemoryCacheEnabled = mainDB, memoryCacheEnabled
+	})
+	for _, channel := range channels {
+		if err := channel.Insert(); err != nil {
+			t.Fatalf("insert channel: %v", err)
+		}
+	}
+	model.InitChannelCache()
+}
+
+func TestDowngradeUnavailableModel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupDowngradeTestChannels(t, &model.Channel{Id: 1, Name: "mini", Type: constant.ChannelTypeOpenAI, Key: "key",
+		Status: common.ChannelStatusEnabled, Group: "default", Models: "gpt-4o-mini"})
+	settings := model_setting.GetModelDowngradeSettings()
+	savedEnabled, savedModels := settings.Enabled, settings.Models
+	settings.Models = map[string]string{"gpt-4o": "gpt-4o-mini", "o1": "o1-mini"}
+	t.Cleanup(func() { settings.Enabled, settings.Models = savedEnabled, savedModels })
+
+	tests := []struct {
+		name        string
+		enabled     bool
+		model       string
+		tokenModels map[string]bool
+		downgraded  bool
+	}{
+		{name: "downgrades to the configured model", enabled: true, model: "gpt-4o", downgraded: true},
+		{name: "disabled downgrade is ignored", model: "gpt-4o"},
+		{name: "model without downgrade", enabled: true, model: "claude-3-opus"},
+		{name: "downgrade without a channel", enabled: true, model: "o1"},
+		{name: "token may not use the downgrade", enabled: true, model: "gpt-4o", tokenModels: map[string]bool{"gpt-4o": true}},
+		{name: "token may use the downgrade", enabled: true, model: "gpt-4o",
+			tokenModels: map[string]bool{"gpt-4o": true, "gpt-4o-mini": true}, downgraded: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings.Enabled = tt.enabled
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			if tt.tokenModels != nil {
+				common.SetContextKey(c, constant.ContextKeyTokenModelLimitEnabled, true)
+				common.SetContextKey(c, constant.ContextKeyTokenModelLimit, tt.tokenModels)
+			}
+			modelRequest := &ModelRequest{Model: tt.model}
+
+			channel, _, ok := downgradeUnavailableModel(c, modelRequest, "default")
+			if ok != tt.downgraded {
+				t.Fatalf("downgradeUnavailableModel() ok = %v, want %v", ok, tt.downgraded)
+			}
+			if !tt.downgraded {
+				if modelRequest.Model != tt.model || recorder.Header().Get("X-Model-Downgraded-From") != "" {
+					t.Errorf("model = %q, header = %q, want the request left alone", modelRequest.Model, recorder.Header().Get("X-Model-Downgraded-From"))
+				}
+				return
+			}
+			if channel == nil || channel.Id != 1 {
+				t.Errorf("channel = %v, want the downgrade model's channel", channel)
+			}
+			if modelRequest.Model != "gpt-4o-mini" {
+				t.Errorf("model = %q, want gpt-4o-mini", modelRequest.Model)
+			}
+			if got := recorder.Header().Get("X-Model-Downgraded-From"); got != "gpt-4o" {
+				t.Errorf("X-Model-Downgraded-From = %q, want gpt-4o", got)
+			}
+			if got := common.GetContextKeyString(c, constant.ContextKeyDowngradedFrom); got != "gpt-4o" {
+				t.Errorf("downgraded from = %q, want gpt-4o", got)
+			}
+		})
+	}
+}
@@ -4,8 +4,15 @@ import (
 	"context"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"math"
 	"net/http"
 	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -17,16 +24,15 @@ var defNext = func(c *gin.Context) {
 	c.Next()
 }
 
-func redisRateLimiter(c *gin.Context, maxRequestNum int, duration int64, mark string) {
+// redisRateLimitRequest records the request when the limit allows it.
+// Otherwise it returns how long until the oldest request leaves the window.
+func redisRateLimitRequest(maxRequestNum int, duration int64, limitKey string) (bool, time.Duration, error) {
 	ctx := context.Background()
 	rdb := common.RDB
-	key := "rateLimit:" + mark + c.ClientIP()
+	key := "rateLimit:" + limitKey
 	listLength, err := rdb.LLen(ctx, key).Result()
 	if err != nil {
-		fmt.Println(err.Error())
-		c.Status(http.StatusInternalServerError)
-		c.Abort()
-		return
+		return false, 0, err
 	}
 	if listLength < int64(maxRequestNum) {
 		rdb.LPush(ctx, key, time.Now().Format(timeFormat))
@@ -35,79 +41,175 @@ func redisRateLimiter(c *gin.Context, maxRequestNum int, duration int64, mark st
 		oldTimeStr, _ := rdb.LIndex(ctx, key, -1).Result()
 		oldTime, err := time.Parse(timeFormat, oldTimeStr)
 		if err != nil {
-			fmt.Println(err)
-			c.Status(http.StatusInternalServerError)
-			c.Abort()
-			return
+			return false, 0, err
 		}
 		nowTimeStr := time.Now().Format(timeFormat)
 		nowTime, err := time.Parse(timeFormat, nowTimeStr)
 		if err != nil {
-			fmt.Println(err)
-			c.Status(http.StatusInternalServerError)
-			c.Abort()
-			return
+			return false, 0, err
 		}
 		// time.Since will return negative number!
 		// See: https://stackoverflow.com/questions/50970900/why-is-time-since-returning-negative-durations-on-windows
 		if int64(nowTime.Sub(oldTime).Seconds()) < duration {
 			rdb.Expire(ctx, key, common.RateLimitKeyExpirationDuration)
-			c.Status(http.StatusTooManyRequests)
-			c.Abort()
-			return
+			return false, oldTime.Add(time.Duration(duration) * time.Second).Sub(nowTime), nil
 		} else {
 			rdb.LPush(ctx, key, time.Now().Format(timeFormat))
 			rdb.LTrim(ctx, key, 0, int64(maxRequestNum-1))
 			rdb.Expire(ctx, key, common.RateLimitKeyExpirationDuration)
 		}
 	}
+	return true, 0, nil
+}
+
+func memoryRateLimitRequest(maxRequestNum int, duration int64, limitKey string) (bool, time.Duration, error) {
+	allowed, retryAfter := inMemoryRateLimiter.RequestWithRetry(limitKey, maxRequestNum, duration)
+	return allowed, retryAfter, nil
+}
+
+// minRateLimitRetryInterval keeps queued requests from retrying in a busy loop.
+const minRateLimitRetryInterval = 50 * time.Millisecond
+
+// rateLimitQueueLengths 记录每个限流器在本节点排队中的请求数
+var rateLimitQueueLengths sync.Map
+
+func rateLimitQueueLength(limiterName string) *atomic.Int64 {
+	length, _ := rateLimitQueueLengths.LoadOrStore(limiterName, &atomic.Int64{})
+	return length.(*atomic.Int64)
 }
 
-func memoryRateLimiter(c *gin.Context, maxRequestNum int, duration int64, mark string) {
-	key := mark + c.ClientIP()
-	if !inMemoryRateLimiter.Request(key, maxRequestNum, duration) {
-		c.Status(http.StatusTooManyRequests)
-		c.Abort()
-		return
+// waitRateLimit retries an over-limit request until a slot frees up or the
+// limiter's queue wait budget runs out. Without a budget, or when the
+// limiter's queue is full, it rejects at once. A rejected request gets the
+// time until the next slot frees up.
+func waitRateLimit(c *gin.Context, limiterName string, request func() (bool, time.Duration, error)) (bool, time.Duration, error) {
+	allowed, retryAfter, err := request()
+	if allowed || err != nil {
+		return allowed, retryAfter, err
+	}
+	maxWait := operation_setting.GetRateLimitMaxWait(limiterName)
+	if maxWait <= 0 {
+		return false, retryAfter, nil
+	}
+	queued := rateLimitQueueLength(limiterName)
+	if queued.Add(1) > int64(operation_setting.GetRateLimitMaxQueued(limiterName)) {
+		queued.Add(-1)
+		return false, retryAfter, nil
+	}
+	defer queued.Add(-1)
+	deadline := time.Now().Add(time.Duration(maxWait) * time.Second)
+	for {
+		if retryAfter < minRateLimitRetryInterval {
+			retryAfter = minRateLimitRetryInterval
+		}
+		remaining := time.Until(deadline)
+		if retryAfter > remaining {
+			// 等待期内不会有空位
+			return false, retryAfter, nil
+		}
+		timer := time.NewTimer(retryAfter)
+		select {
+		case <-timer.C:
+		case <-c.Request.Context().Done():
+			timer.Stop()
+			return false, 0, c.Request.Context().Err()
+		}
+		allowed, retryAfter, err = request()
+		if allowed || err != nil {
+			return allowed, retryAfter, err
+		}
 	}
 }
 
-func rateLimitFactory(maxRequestNum int, duration int64, mark string) func(c *gin.Context) {
+// setRetryAfter tells the client how many whole seconds to wait before
+// retrying, at least one.
+func setRetryAfter(c *gin.Context, retryAfter time.Duration) {
+	seconds := int64(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.FormatInt(seconds, 10))
+}
+
+// rateLimitResolver returns the limiter key and limit for the request. ok is
+// false when the request is not limited.
+type rateLimitResolver func(c *gin.Context) (key string, maxRequestNum int, duration int64, ok bool)
+
+// resolvedRateLimitFactory limits requests with the resolved limit. Over-limit
+// requests queue for up to the wait configured for limiterName before 429.
+func resolvedRateLimitFactory(limiterName string, resolve rateLimitResolver) func(c *gin.Context) {
+	limiter := memoryRateLimitRequest
 	if common.RedisEnabled {
-		return func(c *gin.Context) {
-			redisRateLimiter(c, maxRequestNum, duration, mark)
-		}
+		limiter = redisRateLimitRequest
 	} else {
 		// It's safe to call multi times.
 		inMemoryRateLimiter.Init(common.RateLimitKeyExpirationDuration)
-		return func(c *gin.Context) {
-			memoryRateLimiter(c, maxRequestNum, duration, mark)
+	}
+	return func(c *gin.Context) {
+		key, maxRequestNum, duration, ok := resolve(c)
+		if !ok {
+			return
+		}
+		allowed, retryAfter, err := waitRateLimit(c, limiterName, func() (bool, time.Duration, error) {
+			return limiter(maxRequestNum, duration, key)
+		})
+		if err != nil {
+			if c.Request.Context().Err() == nil {
+				fmt.Println(err.Error())
+				c.Status(http.StatusInternalServerError)
+			}
+			c.Abort()
+			return
+		}
+		if !allowed {
+			setRetryAfter(c, retryAfter)
+			c.Status(http.StatusTooManyRequests)
+			c.Abort()
 		}
 	}
 }
 
+func rateLimitFactory(limiterName string, maxRequestNum int, duration int64, mark string) func(c *gin.Context) {
+	return resolvedRateLimitFactory(limiterName, func(c *gin.Context) (string, int, int64, bool) {
+		return mark + c.ClientIP(), maxRequestNum, duration, true
+	})
+}
+
 func GlobalWebRateLimit() func(c *gin.Context) {
 	if common.GlobalWebRateLimitEnable {
-		return rateLimitFactory(common.GlobalWebRateLimitNum, common.GlobalWebRateLimitDuration, "GW")
+		return rateLimitFactory(operation_setting.RateLimiterGlobalWeb, common.GlobalWebRateLimitNum, common.GlobalWebRateLimitDuration, "GW")
 	}
 	return defNext
 }
 
 func GlobalAPIRateLimit() func(c *gin.Context) {
 	if common.GlobalApiRateLimitEnable {
-		return rateLimitFactory(common.GlobalApiRateLimitNum, common.GlobalApiRateLimitDuration, "GA")
+		return rateLimitFactory(operation_setting.RateLimiterGlobalApi, common.GlobalApiRateLimitNum, common.GlobalApiRateLimitDuration, "GA")
 	}
 	return defNext
 }
 
 func CriticalRateLimit() func(c *gin.Context) {
-	return rateLimitFactory(common.CriticalRateLimitNum, common.CriticalRateLimitDuration, "CT")
+	return rateLimitFactory(operation_setting.RateLimiterCritical, common.CriticalRateLimitNum, common.CriticalRateLimitDuration, "CT")
 }
 
 func DownloadRateLimit() func(c *gin.Context) {
-	return rateLimitFactory(common.DownloadRateLimitNum, common.DownloadRateLimitDuration, "DW")
+	return rateLimitFactory(operation_setting.RateLimiterDownload, common.DownloadRateLimitNum, common.DownloadRateLimitDuration, "DW")
 }
 
 func UploadRateLimit() func(c *gin.Context) {
-	return rateLimitFactory(common.UploadRateLimitNum, common.UploadRateLimitDuration, "UP")
+	return rateLimitFactory(operation_setting.RateLimiterUpload, common.UploadRateLimitNum, common.UploadRateLimitDuration, "UP")
+}
+
+// UserTierRateLimit limits each user with the rate-limit tier assigned to the
+// user or to the user's group. Users without a tier are not limited here.
+func UserTierRateLimit() func(c *gin.Context) {
+	return resolvedRateLimitFactory(operation_setting.RateLimiterUserTier, func(c *gin.Context) (string, int, int64, bool) {
+		tier, ok := model.GetUserRateLimitTier(common.GetContextKeyString(c, constant.ContextKeyUserRateLimitTier),
+			common.GetContextKeyString(c, constant.ContextKeyUserGroup))
+		if !ok {
+			return "", 0, 0, false
+		}
+		return fmt.Sprintf("UT%d", c.GetInt("id")), tier.MaxRequests, tier.DurationSeconds, true
+	})
 }
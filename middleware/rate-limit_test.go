@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"context"
+	"net/http/httptest"
+	"one-api/setting/operation_setting"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeRateLimiter allows limit requests per window, like the sliding window
+// limiters, and reports when the oldest request leaves the window.
+type fakeRateLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	times  []time.Time
+}
+
+func (l *fakeRateLimiter) request() (bool, time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	for len(l.times) > 0 && now.Sub(l.times[0]) >= l.window {
+		l.times = l.times[1:]
+	}
+	if len(l.times) < l.limit {
+		l.times = append(l.times, now)
+		return true, 0, nil
+	}
+	return false, l.times[0].Add(l.window).Sub(now), nil
+}
+
+func setRateLimitQueue(t *testing.T, limiterName string, maxWait int, maxQueued int) {
+	gin.SetMode(gin.TestMode)
+	setting := operation_setting.GetRateLimitQueueSetting()
+	saved := *setting
+	*setting = operation_setting.RateLimitQueueSetting{
+		MaxWaitSeconds:   map[string]int{limiterName: maxWait},
+		MaxQueued:        map[string]int{limiterName: maxQueued},
+		DefaultMaxQueued: 100,
+	}
+	t.Cleanup(func() { *setting = saved })
+}
+
+func newRateLimitTestContext(ctx context.Context) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	return c
+}
+
+func TestWaitRateLimitBurstQueuesAndDrains(t *testing.T) {
+	setRateLimitQueue(t, "test_burst", 5, 10)
+	limiter := &fakeRateLimiter{limit: 2, window: 200 * time.Millisecond}
+
+	const burst = 6
+	var wg sync.WaitGroup
+	results := make([]bool, burst)
+	start := time.Now()
+	for i := 0; i < burst; i++ {
+		c := newRateLimitTestContext(context.Background())
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			allowed, _, err := waitRateLimit(c, "test_burst", limiter.request)
+			results[i] = allowed && err == nil
+		}(i)
+	}
+	wg.Wait()
+	for i, allowed := range results {
+		if !allowed {
+			t.Fatalf("request %d was rejected, want every queued request to drain", i)
+		}
+	}
+	// 6 requests at 2 per window need at least two more windows.
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("burst drained in %v, faster than the limit allows", elapsed)
+	}
+	if length := rateLimitQueueLength("test_burst").Load(); length != 0 {
+		t.Errorf("queue length = %d after draining, want 0", length)
+	}
+}
+
+func TestWaitRateLimitQueueFull(t *testing.T) {
+	setRateLimitQueue(t, "test_full", 5, 1)
+	limiter := &fakeRateLimiter{limit: 1, window: time.Hour}
+	limiter.request()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	queuedCtx := newRateLimitTestContext(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _, _ = waitRateLimit(queuedCtx, "test_full", func() (bool, time.Duration, error) {
+			_, _, err := limiter.request()
+			return false, 100 * time.Millisecond, err
+		})
+	}()
+	for rateLimitQueueLength("test_full").Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	allowed, retryAfter, err := waitRateLimit(newRateLimitTestContext(context.Background()), "test_full", limiter.request)
+	if allowed || err != nil {
+		t.Fatalf("waitRateLimit() = %v, %v; want rejected with a full queue", allowed, err)
+	}
+	if retryAfter <= 0 {
+		t.Errorf("retryAfter = %v, want the time until the next slot", retryAfter)
+	}
+	cancel()
+	<-done
+}
+
+func TestWaitRateLimitWithoutBudget(t *testing.T) {
+	setRateLimitQueue(t, "test_none", 0, 10)
+	limiter := &fakeRateLimiter{limit: 1, window: time.Minute}
+	limiter.request()
+	allowed, retryAfter, err := waitRateLimit(newRateLimitTestContext(context.Background()), "test_none", limiter.request)
+	if allowed || err != nil || retryAfter <= 0 {
+		t.Fatalf("waitRateLimit() = %v, %v, %v; want an immediate rejection with a retry delay", allowed, retryAfter, err)
+	}
+}
+
+func TestSetRetryAfter(t *testing.T) {
+	tests := []struct {
+		retryAfter time.Duration
+		want       string
+	}{
+		{retryAfter: 1500 * time.Millisecond, want: "2"},
+		{retryAfter: 0, want: "1"},
+		{retryAfter: 30 * time.Second, want: "30"},
+	}
+	for _, tt := range tests {
+		c := newRateLimitTestContext(context.Background())
+		setRetryAfter(c, tt.retryAfter)
+		if got := c.Writer.Header().Get("Retry-After"); got != tt.want {
+			t.Errorf("setRetryAfter(%v) = %q, want %q", tt.retryAfter, got, tt.want)
+		}
+	}
+}
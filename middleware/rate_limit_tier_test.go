@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestUserTierRateLimit(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sqlite handle: %v", err)
+	}
+	// 内存数据库每个连接相互独立，限制为单连接以共享同一份数据
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&model.RateLimitTier{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	mainDB, redisEnabled := model.DB, common.RedisEnabled
+	model.DB, common.RedisEnabled = db, false
+	t.Cleanup(func() {
+		// 清空档位缓存，避免影响其他测试
+		db.Where("1 = 1").Delete(&model.RateLimitTier{})
+		model.InitRateLimitTierCache()
+		model.DB, common.RedisEnabled = mainDB, redisEnabled
+	})
+	for _, tier := range []*model.RateLimitTier{
+		{Name: "basic", MaxRequests: 2, DurationSeconds: 60},
+		{Name: "vip", MaxRequests: 3, DurationSeconds: 60},
+	} {
+		if err := tier.Insert(); err != nil {
+			t.Fatalf("insert tier %s: %v", tier.Name, err)
+		}
+	}
+	setRateLimitQueue(t, operation_setting.RateLimiterUserTier, 0, 0)
+	limit := UserTierRateLimit()
+
+	tests := []struct {
+		name    string
+		userId  int
+		tier    string
+		group   string
+		allowed int
+	}{
+		{name: "assigned tier", userId: 101, tier: "basic", group: "vip", allowed: 2},
+		{name: "tier named after the group", userId: 102, group: "vip", allowed: 3},
+		{name: "unknown tier falls back to the group", userId: 103, tier: "gone", group: "vip", allowed: 3},
+		{name: "no tier is not limited", userId: 104, group: "default", allowed: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed := 0
+			for i := 0; i < 10; i++ {
+				c := newRateLimitTestContext(context.Background())
+				c.Set("id", tt.userId)
+				common.SetContextKey(c, constant.ContextKeyUserRateLimitTier, tt.tier)
+				common.SetContextKey(c, constant.ContextKeyUserGroup, tt.group)
+				limit(c)
+				if c.IsAborted() {
+					if status := c.Writer.Status(); status != http.StatusTooManyRequests {
+						t.Fatalf("request %d status = %d, want %d", i+1, status, http.StatusTooManyRequests)
+					}
+					continue
+				}
+				allowed++
+			}
+			if allowed != tt.allowed {
+				t.Errorf("allowed %d of 10 requests, want %d", allowed, tt.allowed)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	"one-api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestBodyCleanup 请求结束后删除落盘的请求体临时文件
+func RequestBodyCleanup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer common.CleanupRequestBody(c)
+		c.Next()
+	}
+}
@@ -118,14 +118,21 @@ func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 	channel := Channel{}
 	if len(abilities) > 0 {
 		// Randomly choose one
-		weightSum := uint(0)
-		for _, ability_ := range abilities {
-			weightSum += ability_.Weight + 10
+		weightSum := 0
+		weights := make([]int, len(abilities))
+		channelIds := make([]int, len(abilities))
+		for i, ability_ := range abilities {
+			weights[i] = effectiveChannelWeight(ability_.ChannelId, int(ability_.Weight)+10)
+			channelIds[i] = ability_.ChannelId
+		}
+		applyChannelLatencyScores(channelIds, weights)
+		for _, w := range weights {
+			weightSum += w
 		}
 		// Randomly choose one
-		weight := common.GetRandomInt(int(weightSum))
-		for _, ability_ := range abilities {
-			weight -= int(ability_.Weight) + 10
+		weight := common.GetRandomInt(weightSum)
+		for i, ability_ := range abilities {
+			weight -= weights[i]
 			//log.Printf("weight: %d, ability weight: %d", weight, *ability_.Weight)
 			if weight <= 0 {
 				channel.Id = ability_.ChannelId
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"one-api/common"
+)
+
+// AuditLog 管理操作审计日志，记录操作者、操作、对象及变更前后的内容
+type AuditLog struct {
+	Id        int    `json:"id"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint;index"`
+	ActorId   int    `json:"actor_id" gorm:"index"`
+	ActorName string `json:"actor_name" gorm:"type:varchar(64)"`
+	Action    string `json:"action" gorm:"type:varchar(64);index"`
+	// 操作对象类型，例如 channel、option、user
+	TargetType string `json:"target_type" gorm:"type:varchar(32);index"`
+	TargetId   string `json:"target_id" gorm:"type:varchar(128);index"`
+	// 变更前后的内容及字段级差异，均为 JSON，敏感字段已脱敏
+	Before string `json:"before" gorm:"type:text"`
+	After  string `json:"after" gorm:"type:text"`
+	Diff   string `json:"diff" gorm:"type:text"`
+	Ip     string `json:"ip" gorm:"type:varchar(64)"`
+}
+
+type AuditLogFilter struct {
+	ActorId        int
+	Action         string
+	TargetType     string
+	TargetId       string
+	StartTimestamp int64
+	EndTimestamp   int64
+}
+
+func (log *AuditLog) Insert() error {
+	if log.CreatedAt == 0 {
+		log.CreatedAt = common.GetTimestamp()
+	}
+	return DB.Create(log).Error
+}
+
+func GetAuditLogs(filter AuditLogFilter, pageInfo *common.PageInfo) (logs []*AuditLog, total int64, err error) {
+	tx := DB.Model(&AuditLog{})
+	if filter.ActorId != 0 {
+		tx = tx.Where("actor_id = ?", filter.ActorId)
+	}
+	if filter.Action != "" {
+		tx = tx.Where("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		tx = tx.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetId != "" {
+		tx = tx.Where("target_id = ?", filter.TargetId)
+	}
+	if filter.StartTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", filter.StartTimestamp)
+	}
+	if filter.EndTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", filter.EndTimestamp)
+	}
+	err = tx.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Find(&logs).Error
+	return logs, total, err
+}
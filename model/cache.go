@@ -6,6 +6,7 @@ import (
 	"math/rand"
 	"one-api/common"
 	"one-api/setting"
+	"one-api/setting/operation_setting"
 	"sort"
 	"strings"
 	"sync"
@@ -158,19 +159,35 @@ func getRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 		}
 	}
 
+	if operation_setting.GetChannelSelectionStrategy(model) == operation_setting.ChannelSelectionRoundRobin {
+		if channel := selectRoundRobinChannel(group, model, targetPriority, targetChannels); channel != nil {
+			return channel, nil
+		}
+		return nil, errors.New("channel not found")
+	}
+
+	targetChannels = deprioritizeNearLimitChannels(targetChannels)
+
 	// 平滑系数
 	smoothingFactor := 10
 	// Calculate the total weight of all channels up to endIdx
 	totalWeight := 0
-	for _, channel := range targetChannels {
-		totalWeight += channel.GetWeight() + smoothingFactor
+	weights := make([]int, len(targetChannels))
+	channelIds := make([]int, len(targetChannels))
+	for i, channel := range targetChannels {
+		weights[i] = effectiveChannelWeight(channel.Id, channel.GetWeight()+smoothingFactor)
+		channelIds[i] = channel.Id
+	}
+	applyChannelLatencyScores(channelIds, weights)
+	for _, w := range weights {
+		totalWeight += w
 	}
 	// Generate a random value in the range [0, totalWeight)
 	randomWeight := rand.Intn(totalWeight)
 
 	// Find a channel based on its weight
-	for _, channel := range targetChannels {
-		randomWeight -= channel.GetWeight() + smoothingFactor
+	for i, channel := range targetChannels {
+		randomWeight -= weights[i]
 		if randomWeight < 0 {
 			return channel, nil
 		}
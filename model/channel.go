@@ -2,7 +2,9 @@ package model
 
 import (
 	"encoding/json"
+	"fmt"
 	"one-api/common"
+	"one-api/constant"
 	"one-api/dto"
 	"strings"
 	"sync"
@@ -356,6 +358,9 @@ func UpdateChannelStatusById(id int, status int, reason string) bool {
 			return false
 		}
 	}
+	if status == common.ChannelStatusEnabled {
+		ResetChannelKeyStates(id)
+	}
 	return true
 }
 
@@ -447,6 +452,21 @@ func DeleteChannelByStatus(status int64) (int64, error) {
 	return result.RowsAffected, result.Error
 }
 
+// GetDisabledChannelSettings returns the settings of the disabled channels,
+// read before they are deleted so cached clients can be released.
+func GetDisabledChannelSettings() ([]dto.ChannelSettings, error) {
+	var channels []*Channel
+	err := DB.Select("id", "setting").Where("status = ? or status = ?", common.ChannelStatusAutoDisabled, common.ChannelStatusManuallyDisabled).Find(&channels).Error
+	if err != nil {
+		return nil, err
+	}
+	settings := make([]dto.ChannelSettings, 0, len(channels))
+	for _, channel := range channels {
+		settings = append(settings, channel.GetSetting())
+	}
+	return settings, nil
+}
+
 func DeleteDisabledChannel() (int64, error) {
 	result := DB.Where("status = ? or status = ?", common.ChannelStatusAutoDisabled, common.ChannelStatusManuallyDisabled).Delete(&Channel{})
 	return result.RowsAffected, result.Error
@@ -523,7 +543,31 @@ func (channel *Channel) ValidateSettings() error {
 			return err
 		}
 	}
-	return nil
+	if channelParams.AzureApiVersion != "" && !constant.AzureAPIVersionRegex.MatchString(channelParams.AzureApiVersion) {
+		return fmt.Errorf("invalid azure api version: %s", channelParams.AzureApiVersion)
+	}
+	if channelParams.Transform != nil {
+		if err := channelParams.Transform.Validate(); err != nil {
+			return err
+		}
+	}
+	switch channelParams.KeyRotation {
+	case "", dto.KeyRotationRoundRobin, dto.KeyRotationRandom:
+	default:
+		return fmt.Errorf("invalid key rotation: %s", channelParams.KeyRotation)
+	}
+	if channelParams.KeyCooldownSeconds < 0 {
+		return fmt.Errorf("invalid key cooldown seconds: %d", channelParams.KeyCooldownSeconds)
+	}
+	switch channelParams.SupportedApi {
+	case "", dto.SupportedApiChatCompletions, dto.SupportedApiResponses:
+	default:
+		return fmt.Errorf("invalid supported api: %s", channelParams.SupportedApi)
+	}
+	if err := channelParams.ValidatePathTemplate(); err != nil {
+		return err
+	}
+	return channelParams.ValidateClientCert()
 }
 
 func (channel *Channel) GetSetting() dto.ChannelSettings {
@@ -546,6 +590,41 @@ func (channel *Channel) SetSetting(setting dto.ChannelSettings) {
 	channel.Setting = common.GetPointer[string](string(settingBytes))
 }
 
+// ChannelClientKeyMask 返回给前端的渠道设置中替代内联客户端私钥的占位值
+const ChannelClientKeyMask = "******"
+
+// MaskSecretSettings hides an inline client private key in the channel
+// settings before the channel is returned to the admin UI. secret:
+// references are kept as they do not contain the key itself.
+func (channel *Channel) MaskSecretSettings() {
+	setting := channel.GetSetting()
+	if setting.ClientKey == "" || setting.ClientKey == ChannelClientKeyMask || strings.HasPrefix(setting.ClientKey, "secret:") {
+		return
+	}
+	setting.ClientKey = ChannelClientKeyMask
+	channel.SetSetting(setting)
+}
+
+func MaskChannelsSecretSettings(channels []*Channel) {
+	for _, channel := range channels {
+		channel.MaskSecretSettings()
+	}
+}
+
+// RestoreMaskedSecretSettings keeps the stored client private key when an
+// update sends back the masked placeholder.
+func (channel *Channel) RestoreMaskedSecretSettings(origin *Channel) {
+	setting := channel.GetSetting()
+	if setting.ClientKey != ChannelClientKeyMask {
+		return
+	}
+	setting.ClientKey = ""
+	if origin != nil {
+		setting.ClientKey = origin.GetSetting().ClientKey
+	}
+	channel.SetSetting(setting)
+}
+
 func (channel *Channel) GetParamOverride() map[string]interface{} {
 	paramOverride := make(map[string]interface{})
 	if channel.ParamOverride != nil && *channel.ParamOverride != "" {
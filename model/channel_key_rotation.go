@@ -0,0 +1,220 @@
+package model
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"one-api/dto"
+	"strings"
+	"sync"
+	"time"
+)
+
+type channelKeyState struct {
+	cooldownUntil  time.Time
+	failures       int
+	lastStatusCode int
+	disabled       bool
+}
+
+// ChannelKeyHealth 多密钥渠道中单个密钥的健康状态
+type ChannelKeyHealth struct {
+	Index          int    `json:"index"`
+	Key            string `json:"key"`
+	Disabled       bool   `json:"disabled"`
+	CoolingDown    bool   `json:"cooling_down"`
+	CooldownUntil  int64  `json:"cooldown_until"`
+	Failures       int    `json:"failures"`
+	LastStatusCode int    `json:"last_status_code"`
+}
+
+// 渠道 id -> 密钥 -> 状态
+var channelKeyStates = make(map[int]map[string]*channelKeyState)
+
+// 渠道 id -> 轮询计数
+var channelKeyCursors = make(map[int]int)
+var channelKeyLock sync.Mutex
+
+// GetKeys returns the channel's upstream keys. The key is split on commas
+// only when key rotation is enabled, since some channel types store a
+// single composite key containing commas.
+func (channel *Channel) GetKeys() []string {
+	setting := channel.GetSetting()
+	if setting.KeyRotation == "" {
+		return []string{channel.Key}
+	}
+	var keys []string
+	for _, key := range strings.Split(channel.Key, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return []string{channel.Key}
+	}
+	return keys
+}
+
+// SelectChannelKey picks the key to use for one request, skipping disabled
+// keys and keys that are cooling down. When every enabled key is cooling
+// down, the one that recovers first is used.
+func SelectChannelKey(channel *Channel) string {
+	keys := channel.GetKeys()
+	if len(keys) == 1 {
+		return keys[0]
+	}
+
+	channelKeyLock.Lock()
+	defer channelKeyLock.Unlock()
+	var start int
+	if channel.GetSetting().KeyRotation == dto.KeyRotationRandom {
+		start = rand.Intn(len(keys))
+	} else {
+		start = channelKeyCursors[channel.Id] % len(keys)
+		channelKeyCursors[channel.Id] = start + 1
+	}
+
+	now := time.Now()
+	states := channelKeyStates[channel.Id]
+	fallback := ""
+	var fallbackUntil time.Time
+	for i := 0; i < len(keys); i++ {
+		key := keys[(start+i)%len(keys)]
+		state, ok := states[key]
+		if ok && state.disabled {
+			continue
+		}
+		if !ok || !now.Before(state.cooldownUntil) {
+			return key
+		}
+		if fallback == "" || state.cooldownUntil.Before(fallbackUntil) {
+			fallback = key
+			fallbackUntil = state.cooldownUntil
+		}
+	}
+	if fallback == "" {
+		// 所有密钥都已禁用，渠道随后会被禁用
+		return keys[start]
+	}
+	return fallback
+}
+
+// shouldCooldownChannelKey 鉴权失败与限流视为密钥级别的错误
+func shouldCooldownChannelKey(statusCode int) bool {
+	return statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden || statusCode == http.StatusTooManyRequests
+}
+
+// RecordChannelKeyError puts the key on cooldown when the upstream rejected
+// it with an auth or rate-limit error.
+func RecordChannelKeyError(channelId int, key string, statusCode int) {
+	if key == "" || !shouldCooldownChannelKey(statusCode) {
+		return
+	}
+	channel, err := CacheGetChannel(channelId)
+	if err != nil {
+		return
+	}
+	setting := channel.GetSetting()
+	if setting.KeyRotation == "" {
+		return
+	}
+
+	channelKeyLock.Lock()
+	defer channelKeyLock.Unlock()
+	states, ok := channelKeyStates[channelId]
+	if !ok {
+		states = make(map[string]*channelKeyState)
+		channelKeyStates[channelId] = states
+	}
+	state, ok := states[key]
+	if !ok {
+		state = &channelKeyState{}
+		states[key] = state
+	}
+	state.failures++
+	state.lastStatusCode = statusCode
+	state.cooldownUntil = time.Now().Add(time.Duration(setting.GetKeyCooldownSeconds()) * time.Second)
+}
+
+// RecordChannelKeySuccess resets the failure count of the key.
+func RecordChannelKeySuccess(channelId int, key string) {
+	channelKeyLock.Lock()
+	defer channelKeyLock.Unlock()
+	if states, ok := channelKeyStates[channelId]; ok {
+		delete(states, key)
+	}
+}
+
+// DisableChannelKey disables one key of a multi-key channel and reports
+// whether the channel still has enabled keys. It returns false for channels
+// without key rotation, whose only key is the channel itself.
+func DisableChannelKey(channelId int, key string) bool {
+	if key == "" {
+		return false
+	}
+	channel, err := CacheGetChannel(channelId)
+	if err != nil {
+		return false
+	}
+	keys := channel.GetKeys()
+	if len(keys) == 1 {
+		return false
+	}
+
+	channelKeyLock.Lock()
+	defer channelKeyLock.Unlock()
+	states, ok := channelKeyStates[channelId]
+	if !ok {
+		states = make(map[string]*channelKeyState)
+		channelKeyStates[channelId] = states
+	}
+	state, ok := states[key]
+	if !ok {
+		state = &channelKeyState{}
+		states[key] = state
+	}
+	state.disabled = true
+	for _, k := range keys {
+		if state, ok := states[k]; !ok || !state.disabled {
+			return true
+		}
+	}
+	return false
+}
+
+// ResetChannelKeyStates 清除渠道所有密钥的冷却与禁用状态，在渠道被修改或重新启用时调用
+func ResetChannelKeyStates(channelId int) {
+	channelKeyLock.Lock()
+	defer channelKeyLock.Unlock()
+	delete(channelKeyStates, channelId)
+}
+
+// maskChannelKey 只保留密钥首尾各 4 位
+func maskChannelKey(key string) string {
+	if len(key) <= 8 {
+		return strings.Repeat("*", len(key))
+	}
+	return fmt.Sprintf("%s****%s", key[:4], key[len(key)-4:])
+}
+
+func GetChannelKeyHealth(channel *Channel) []ChannelKeyHealth {
+	keys := channel.GetKeys()
+	now := time.Now()
+
+	channelKeyLock.Lock()
+	defer channelKeyLock.Unlock()
+	states := channelKeyStates[channel.Id]
+	health := make([]ChannelKeyHealth, 0, len(keys))
+	for i, key := range keys {
+		item := ChannelKeyHealth{Index: i, Key: maskChannelKey(key)}
+		if state, ok := states[key]; ok {
+			item.Disabled = state.disabled
+			item.CoolingDown = now.Before(state.cooldownUntil)
+			item.CooldownUntil = state.cooldownUntil.Unix()
+			item.Failures = state.failures
+			item.LastStatusCode = state.lastStatusCode
+		}
+		health = append(health, item)
+	}
+	return health
+}
@@ -0,0 +1,170 @@
+package model
+
+import (
+	"net/http"
+	"one-api/common"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func setupKeyRotationChannel(t *testing.T, id int, key string, setting string) *Channel {
+	channel := &Channel{Id: id, Key: key, Setting: &setting}
+	memoryCacheEnabled := common.MemoryCacheEnabled
+	common.MemoryCacheEnabled = true
+	channelSyncLock.Lock()
+	idm := channelsIDM
+	channelsIDM = map[int]*Channel{id: channel}
+	channelSyncLock.Unlock()
+	t.Cleanup(func() {
+		common.MemoryCacheEnabled = memoryCacheEnabled
+		channelSyncLock.Lock()
+		channelsIDM = idm
+		channelSyncLock.Unlock()
+		ResetChannelKeyStates(id)
+	})
+	return channel
+}
+
+func TestDisableChannelKey(t *testing.T) {
+	channel := setupKeyRotationChannel(t, 1, "sk-a,sk-b,sk-c", `{"key_rotation":"round_robin"}`)
+
+	tests := []struct {
+		key           string
+		wantRemaining bool
+	}{
+		{key: "sk-a", wantRemaining: true},
+		{key: "sk-a", wantRemaining: true},
+		{key: "sk-c", wantRemaining: true},
+		{key: "sk-b", wantRemaining: false},
+	}
+	for _, tt := range tests {
+		if got := DisableChannelKey(channel.Id, tt.key); got != tt.wantRemaining {
+			t.Fatalf("DisableChannelKey(%q) = %v, want %v", tt.key, got, tt.wantRemaining)
+		}
+	}
+}
+
+func TestSelectChannelKeySkipsDisabledKeys(t *testing.T) {
+	channel := setupKeyRotationChannel(t, 2, "sk-a,sk-b,sk-c", `{"key_rotation":"round_robin"}`)
+	DisableChannelKey(channel.Id, "sk-b")
+	for i := 0; i < 6; i++ {
+		if key := SelectChannelKey(channel); key == "sk-b" {
+			t.Fatalf("SelectChannelKey() returned disabled key %q", key)
+		}
+	}
+
+	ResetChannelKeyStates(channel.Id)
+	selected := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		selected[SelectChannelKey(channel)] = true
+	}
+	if !selected["sk-b"] {
+		t.Fatalf("SelectChannelKey() did not return sk-b after reset, got %v", selected)
+	}
+}
+
+func TestDisableChannelKeySingleKey(t *testing.T) {
+	channel := setupKeyRotationChannel(t, 3, "sk-a,sk-b", `{}`)
+	if DisableChannelKey(channel.Id, "sk-a,sk-b") {
+		t.Fatal("DisableChannelKey() kept a channel without key rotation enabled")
+	}
+}
+
+func selectChannelKeys(channel *Channel, n int) []string {
+	keys := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		keys = append(keys, SelectChannelKey(channel))
+	}
+	return keys
+}
+
+func TestSelectChannelKeyRoundRobin(t *testing.T) {
+	channel := setupKeyRotationChannel(t, 4, "sk-a, sk-b,sk-c", `{"key_rotation":"round_robin"}`)
+	t.Cleanup(func() {
+		channelKeyLock.Lock()
+		delete(channelKeyCursors, channel.Id)
+		channelKeyLock.Unlock()
+	})
+
+	want := []string{"sk-a", "sk-b", "sk-c", "sk-a", "sk-b", "sk-c"}
+	if got := selectChannelKeys(channel, len(want)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("SelectChannelKey() order = %v, want %v", got, want)
+	}
+}
+
+func TestRecordChannelKeyErrorCooldown(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		skipped    bool
+	}{
+		{statusCode: http.StatusUnauthorized, skipped: true},
+		{statusCode: http.StatusForbidden, skipped: true},
+		{statusCode: http.StatusTooManyRequests, skipped: true},
+		{statusCode: http.StatusInternalServerError},
+		{statusCode: http.StatusBadRequest},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(tt.statusCode), func(t *testing.T) {
+			channel := setupKeyRotationChannel(t, 10+i, "sk-a,sk-b,sk-c", `{"key_rotation":"round_robin","key_cooldown_seconds":60}`)
+			t.Cleanup(func() {
+				channelKeyLock.Lock()
+				delete(channelKeyCursors, channel.Id)
+				channelKeyLock.Unlock()
+			})
+
+			RecordChannelKeyError(channel.Id, "sk-b", tt.statusCode)
+			got := selectChannelKeys(channel, 3)
+			want := []string{"sk-a", "sk-b", "sk-c"}
+			if tt.skipped {
+				// 冷却中的密钥由下一个密钥代替
+				want = []string{"sk-a", "sk-c", "sk-c"}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("keys after a %d error = %v, want %v", tt.statusCode, got, want)
+			}
+			health := GetChannelKeyHealth(channel)
+			if health[1].CoolingDown != tt.skipped {
+				t.Errorf("sk-b cooling down = %v, want %v", health[1].CoolingDown, tt.skipped)
+			}
+			if tt.skipped && (health[1].Failures != 1 || health[1].LastStatusCode != tt.statusCode) {
+				t.Errorf("sk-b health = %+v, want 1 failure with status %d", health[1], tt.statusCode)
+			}
+		})
+	}
+}
+
+func TestChannelKeyCooldownRecovery(t *testing.T) {
+	channel := setupKeyRotationChannel(t, 20, "sk-a,sk-b", `{"key_rotation":"round_robin"}`)
+	t.Cleanup(func() {
+		channelKeyLock.Lock()
+		delete(channelKeyCursors, channel.Id)
+		channelKeyLock.Unlock()
+	})
+
+	RecordChannelKeyError(channel.Id, "sk-a", http.StatusTooManyRequests)
+	RecordChannelKeyError(channel.Id, "sk-b", http.StatusTooManyRequests)
+	// 所有密钥都在冷却时使用最先恢复的密钥
+	channelKeyLock.Lock()
+	channelKeyStates[channel.Id]["sk-a"].cooldownUntil = time.Now().Add(time.Minute)
+	channelKeyStates[channel.Id]["sk-b"].cooldownUntil = time.Now().Add(time.Second)
+	channelKeyLock.Unlock()
+	if got := selectChannelKeys(channel, 2); !reflect.DeepEqual(got, []string{"sk-b", "sk-b"}) {
+		t.Fatalf("keys while all cool down = %v, want the key recovering first", got)
+	}
+
+	// 冷却结束的密钥重新参与轮换，仍在冷却的密钥被跳过
+	channelKeyLock.Lock()
+	channelKeyStates[channel.Id]["sk-a"].cooldownUntil = time.Now().Add(-time.Second)
+	channelKeyLock.Unlock()
+	if got := selectChannelKeys(channel, 2); !reflect.DeepEqual(got, []string{"sk-a", "sk-a"}) {
+		t.Fatalf("keys after the sk-a cooldown = %v, want [sk-a sk-a]", got)
+	}
+
+	// 请求成功后清除失败记录
+	RecordChannelKeySuccess(channel.Id, "sk-b")
+	if health := GetChannelKeyHealth(channel); health[1].CoolingDown || health[1].Failures != 0 {
+		t.Errorf("sk-b health after success = %+v, want reset", health[1])
+	}
+}
@@ -0,0 +1,106 @@
+package model
+
+import (
+	"math"
+	"one-api/setting/operation_setting"
+	"sort"
+	"sync"
+	"time"
+)
+
+// channelLatencyWindow 渠道最近若干次成功请求的响应延迟（毫秒），环形缓冲
+type channelLatencyWindow struct {
+	samples []float64
+	next    int
+}
+
+// 渠道 id -> 延迟样本，状态仅保存在本节点
+var channelLatencies = make(map[int]*channelLatencyWindow)
+var channelLatencyLock sync.RWMutex
+
+// RecordChannelLatency records the time the channel took to answer a
+// successful request.
+func RecordChannelLatency(channelId int, latency time.Duration) {
+	setting := operation_setting.GetChannelLatencyScoreSetting()
+	if !setting.Enabled || setting.WindowSize <= 0 {
+		return
+	}
+	channelLatencyLock.Lock()
+	defer channelLatencyLock.Unlock()
+	window, ok := channelLatencies[channelId]
+	if !ok {
+		window = &channelLatencyWindow{}
+		channelLatencies[channelId] = window
+	}
+	ms := float64(latency.Milliseconds())
+	if len(window.samples) < setting.WindowSize {
+		window.samples = append(window.samples, ms)
+		return
+	}
+	if len(window.samples) > setting.WindowSize {
+		window.samples = window.samples[len(window.samples)-setting.WindowSize:]
+		window.next = 0
+	}
+	window.samples[window.next%len(window.samples)] = ms
+	window.next = (window.next + 1) % len(window.samples)
+}
+
+// GetChannelLatency returns the channel's rolling average and p95 latency in
+// milliseconds and the number of samples they are based on.
+func GetChannelLatency(channelId int) (avg float64, p95 float64, samples int) {
+	channelLatencyLock.RLock()
+	window, ok := channelLatencies[channelId]
+	var values []float64
+	if ok {
+		values = append(values, window.samples...)
+	}
+	channelLatencyLock.RUnlock()
+	if len(values) == 0 {
+		return 0, 0, 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	sort.Float64s(values)
+	index := int(math.Ceil(float64(len(values))*0.95)) - 1
+	return sum / float64(len(values)), values[max(index, 0)], len(values)
+}
+
+// applyChannelLatencyScores scales the selection weights of channels by how
+// much slower they answer than the fastest scored channel among them.
+// Channels with too few samples keep their weight.
+func applyChannelLatencyScores(channelIds []int, weights []int) {
+	setting := operation_setting.GetChannelLatencyScoreSetting()
+	if !setting.Enabled || setting.LatencyWeight <= 0 || len(channelIds) < 2 {
+		return
+	}
+	latencies := make([]float64, len(channelIds))
+	fastest := 0.0
+	for i, channelId := range channelIds {
+		avg, p95, samples := GetChannelLatency(channelId)
+		if samples < max(setting.MinSamples, 1) {
+			continue
+		}
+		latency := p95
+		if setting.Metric == operation_setting.ChannelLatencyMetricAvg {
+			latency = avg
+		}
+		latencies[i] = max(latency, 1)
+		if fastest == 0 || latencies[i] < fastest {
+			fastest = latencies[i]
+		}
+	}
+	if fastest == 0 {
+		return
+	}
+	for i := range weights {
+		if latencies[i] == 0 {
+			continue
+		}
+		factor := math.Max(math.Pow(fastest/latencies[i], setting.LatencyWeight), setting.MinFactor)
+		if factor < 1 {
+			weights[i] = max(int(float64(weights[i])*factor), 1)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package model
+
+import (
+	"one-api/setting/operation_setting"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func setupChannelLatencies(t *testing.T, setting operation_setting.ChannelLatencyScoreSetting, latencies map[int][]time.Duration) {
+	t.Helper()
+	current := operation_setting.GetChannelLatencyScoreSetting()
+	saved := *current
+	*current = setting
+	channelLatencyLock.Lock()
+	savedLatencies := channelLatencies
+	channelLatencies = make(map[int]*channelLatencyWindow)
+	channelLatencyLock.Unlock()
+	t.Cleanup(func() {
+		*current = saved
+		channelLatencyLock.Lock()
+		channelLatencies = savedLatencies
+		channelLatencyLock.Unlock()
+	})
+	for channelId, samples := range latencies {
+		for _, latency := range samples {
+			RecordChannelLatency(channelId, latency)
+		}
+	}
+}
+
+func TestRecordChannelLatencyWindow(t *testing.T) {
+	samples := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 1000 * time.Millisecond}
+	setupChannelLatencies(t, operation_setting.ChannelLatencyScoreSetting{Enabled: true, WindowSize: 3},
+		map[int][]time.Duration{1: samples})
+
+	// 只保留最近 3 个样本
+	avg, p95, count := GetChannelLatency(1)
+	if avg != 500 || p95 != 1000 || count != 3 {
+		t.Errorf("GetChannelLatency() = %v, %v, %d, want 500, 1000, 3", avg, p95, count)
+	}
+}
+
+func TestApplyChannelLatencyScores(t *testing.T) {
+	fast := []time.Duration{100 * time.Millisecond, 100 * time.Millisecond, 100 * time.Millisecond}
+	slow := []time.Duration{400 * time.Millisecond, 400 * time.Millisecond, 400 * time.Millisecond}
+	base := operation_setting.ChannelLatencyScoreSetting{Enabled: true, Metric: operation_setting.ChannelLatencyMetricAvg,
+		LatencyWeight: 1, WindowSize: 10, MinSamples: 3}
+
+	tests := []struct {
+		name      string
+		setting   func(s *operation_setting.ChannelLatencyScoreSetting)
+		latencies map[int][]time.Duration
+		want      []int
+	}{
+		{name: "slow channel loses weight", latencies: map[int][]time.Duration{1: fast, 2: slow},
+			want: []int{100, 25}},
+		{name: "latency weight is an exponent", setting: func(s *operation_setting.ChannelLatencyScoreSetting) { s.LatencyWeight = 0.5 },
+			latencies: map[int][]time.Duration{1: fast, 2: slow}, want: []int{100, 50}},
+		{name: "factor is bounded", setting: func(s *operation_setting.ChannelLatencyScoreSetting) { s.MinFactor = 0.5 },
+			latencies: map[int][]time.Duration{1: fast, 2: slow}, want: []int{100, 50}},
+		{name: "channels without enough samples keep their weight", latencies: map[int][]time.Duration{1: fast, 2: slow[:2]},
+			want: []int{100, 100}},
+		{name: "disabled", setting: func(s *operation_setting.ChannelLatencyScoreSetting) { s.Enabled = false },
+			latencies: map[int][]time.Duration{1: fast, 2: slow}, want: []int{100, 100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setting := base
+			if tt.setting != nil {
+				tt.setting(&setting)
+			}
+			// 先以启用状态记录样本，再应用测试的配置
+			setupChannelLatencies(t, base, tt.latencies)
+			*operation_setting.GetChannelLatencyScoreSetting() = setting
+
+			weights := []int{100, 100}
+			applyChannelLatencyScores([]int{1, 2}, weights)
+			if !reflect.DeepEqual(weights, tt.want) {
+				t.Errorf("weights = %v, want %v", weights, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package model
+
+import (
+	"one-api/setting/operation_setting"
+	"sync"
+	"time"
+)
+
+// ChannelRateLimitState 上游通过 x-ratelimit-* 响应头报告的渠道剩余额度，-1 表示未知
+type ChannelRateLimitState struct {
+	LimitRequests     int64
+	RemainingRequests int64
+	LimitTokens       int64
+	RemainingTokens   int64
+	ResetAt           time.Time
+}
+
+var channelRateLimitStates sync.Map // channel id -> ChannelRateLimitState
+
+func UpdateChannelRateLimitState(channelId int, state ChannelRateLimitState) {
+	channelRateLimitStates.Store(channelId, state)
+}
+
+// isChannelNearRateLimit reports whether the channel's last reported
+// remaining requests or tokens dropped below the configured ratio. Expired
+// states are discarded.
+func isChannelNearRateLimit(channelId int) bool {
+	value, ok := channelRateLimitStates.Load(channelId)
+	if !ok {
+		return false
+	}
+	state := value.(ChannelRateLimitState)
+	if time.Now().After(state.ResetAt) {
+		channelRateLimitStates.Delete(channelId)
+		return false
+	}
+	ratio := operation_setting.GetChannelRateLimitSetting().RemainingRatioThreshold
+	return nearLimit(state.RemainingRequests, state.LimitRequests, ratio) ||
+		nearLimit(state.RemainingTokens, state.LimitTokens, ratio)
+}
+
+func nearLimit(remaining int64, limit int64, ratio float64) bool {
+	if remaining < 0 {
+		return false
+	}
+	if limit <= 0 {
+		return remaining == 0
+	}
+	return float64(remaining) <= float64(limit)*ratio
+}
+
+// deprioritizeNearLimitChannels drops channels close to their upstream rate
+// limit, unless that would leave no channel to choose from.
+func deprioritizeNearLimitChannels(channels []*Channel) []*Channel {
+	available := make([]*Channel, 0, len(channels))
+	for _, channel := range channels {
+		if !isChannelNearRateLimit(channel.Id) {
+			available = append(available, channel)
+		}
+	}
+	if len(available) == 0 {
+		return channels
+	}
+	return available
+}
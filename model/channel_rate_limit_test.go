@@ -0,0 +1,70 @@
+package model
+
+import (
+	"one-api/setting/operation_setting"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestNearLimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		remaining int64
+		limit     int64
+		want      bool
+	}{
+		{name: "plenty left", remaining: 500, limit: 1000},
+		{name: "at the threshold", remaining: 100, limit: 1000, want: true},
+		{name: "below the threshold", remaining: 20, limit: 1000, want: true},
+		{name: "unknown remaining", remaining: -1, limit: 1000},
+		{name: "unknown limit with some left", remaining: 5, limit: -1},
+		{name: "unknown limit and exhausted", remaining: 0, limit: -1, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nearLimit(tt.remaining, tt.limit, 0.1); got != tt.want {
+				t.Errorf("nearLimit(%d, %d) = %v, want %v", tt.remaining, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeprioritizeNearLimitChannels(t *testing.T) {
+	setting := operation_setting.GetChannelRateLimitSetting()
+	saved := setting.RemainingRatioThreshold
+	setting.RemainingRatioThreshold = 0.1
+	t.Cleanup(func() {
+		setting.RemainingRatioThreshold = saved
+		for _, id := range []int{91001, 91002, 91003} {
+			channelRateLimitStates.Delete(id)
+		}
+	})
+
+	resetAt := time.Now().Add(time.Minute)
+	UpdateChannelRateLimitState(91001, ChannelRateLimitState{LimitRequests: 100, RemainingRequests: 50,
+		LimitTokens: 10000, RemainingTokens: 500, ResetAt: resetAt})
+	UpdateChannelRateLimitState(91002, ChannelRateLimitState{LimitRequests: 100, RemainingRequests: 90,
+		LimitTokens: -1, RemainingTokens: -1, ResetAt: resetAt})
+	// 已过重置时间的状态不再生效
+	UpdateChannelRateLimitState(91003, ChannelRateLimitState{LimitRequests: 100, RemainingRequests: 0,
+		LimitTokens: -1, RemainingTokens: -1, ResetAt: time.Now().Add(-time.Second)})
+
+	channels := []*Channel{{Id: 91001}, {Id: 91002}, {Id: 91003}, {Id: 91004}}
+	var got []int
+	for _, channel := range deprioritizeNearLimitChannels(channels) {
+		got = append(got, channel.Id)
+	}
+	if want := []int{91002, 91003, 91004}; !slices.Equal(got, want) {
+		t.Errorf("channels = %v, want %v without the token-limited channel", got, want)
+	}
+	if _, ok := channelRateLimitStates.Load(91003); ok {
+		t.Error("expired rate limit state was not discarded")
+	}
+
+	// 全部接近限额时保留原列表，避免无渠道可选
+	limited := []*Channel{{Id: 91001}}
+	if got := deprioritizeNearLimitChannels(limited); len(got) != 1 || got[0].Id != 91001 {
+		t.Errorf("channels = %v, want the only channel kept", got)
+	}
+}
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"one-api/common"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+var roundRobinCursors sync.Map // group:model:priority -> *uint64
+
+// nextRoundRobinCursor returns an increasing counter for the key, shared
+// across instances through Redis when it is enabled.
+func nextRoundRobinCursor(key string) uint64 {
+	if common.RedisEnabled {
+		value, err := common.RDB.Incr(context.Background(), "channel_rr:"+key).Result()
+		if err == nil {
+			return uint64(value)
+		}
+		common.SysError("failed to increase round robin cursor: " + err.Error())
+	}
+	cursor, _ := roundRobinCursors.LoadOrStore(key, new(uint64))
+	return atomic.AddUint64(cursor.(*uint64), 1)
+}
+
+// selectRoundRobinChannel cycles through the channels ordered by id. The
+// cursor advances over the whole list, so disabled channels and channels near
+// their upstream rate limit are skipped without shifting the turn of the
+// others. If every enabled channel is near its limit, the turn is kept.
+func selectRoundRobinChannel(group string, model string, priority int64, channels []*Channel) *Channel {
+	if len(channels) == 0 {
+		return nil
+	}
+	ordered := append([]*Channel(nil), channels...)
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].Id < ordered[j].Id })
+	// 渠道状态可能被 CacheUpdateChannelStatus 并发修改，需在锁内读取
+	enabled := make([]bool, len(ordered))
+	channelSyncLock.RLock()
+	for i, channel := range ordered {
+		enabled[i] = channel.Status == common.ChannelStatusEnabled
+	}
+	channelSyncLock.RUnlock()
+	cursor := nextRoundRobinCursor(fmt.Sprintf("%s:%s:%d", group, model, priority))
+	start := int((cursor - 1) % uint64(len(ordered)))
+	var fallback *Channel
+	for i := 0; i < len(ordered); i++ {
+		index := (start + i) % len(ordered)
+		if !enabled[index] {
+			continue
+		}
+		channel := ordered[index]
+		if !isChannelNearRateLimit(channel.Id) {
+			return channel
+		}
+		if fallback == nil {
+			fallback = channel
+		}
+	}
+	return fallback
+}
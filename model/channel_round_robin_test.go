@@ -0,0 +1,57 @@
+package model
+
+import (
+	"one-api/common"
+	"sync"
+	"testing"
+)
+
+func TestSelectRoundRobinChannel(t *testing.T) {
+	redisEnabled, memoryCacheEnabled := common.RedisEnabled, common.MemoryCacheEnabled
+	common.RedisEnabled, common.MemoryCacheEnabled = false, true
+	channelSyncLock.Lock()
+	idMap := channelsIDM
+	channels := []*Channel{
+		{Id: 3, Status: common.ChannelStatusEnabled},
+		{Id: 1, Status: common.ChannelStatusEnabled},
+		{Id: 2, Status: common.ChannelStatusManuallyDisabled},
+	}
+	channelsIDM = map[int]*Channel{1: channels[1], 2: channels[2], 3: channels[0]}
+	channelSyncLock.Unlock()
+	t.Cleanup(func() {
+		common.RedisEnabled, common.MemoryCacheEnabled = redisEnabled, memoryCacheEnabled
+		channelSyncLock.Lock()
+		channelsIDM = idMap
+		channelSyncLock.Unlock()
+		roundRobinCursors.Delete("rr-test:m:0")
+	})
+
+	// 禁用的渠道被跳过，其余按 id 轮流
+	var got []int
+	for i := 0; i < 4; i++ {
+		got = append(got, selectRoundRobinChannel("rr-test", "m", 0, channels).Id)
+	}
+	want := []int{1, 3, 3, 1}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("selected channels = %v, want %v", got, want)
+		}
+	}
+
+	// 状态更新与选择并发进行
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			CacheUpdateChannelStatus(1, common.ChannelStatusAutoDisabled)
+			CacheUpdateChannelStatus(1, common.ChannelStatusEnabled)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if channel := selectRoundRobinChannel("rr-test", "m", 0, channels); channel == nil || channel.Id == 2 {
+			t.Fatalf("selected %v, want an enabled channel", channel)
+		}
+	}
+	wg.Wait()
+}
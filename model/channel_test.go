@@ -0,0 +1,27 @@
+package model
+
+import "testing"
+
+func TestValidateSettingsAzureApiVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		setting string
+		wantErr bool
+	}{
+		{name: "no api version", setting: `{"azure_deployments":{"gpt-4o":"prod"}}`},
+		{name: "dated version", setting: `{"azure_api_version":"2024-10-21"}`},
+		{name: "dated preview version", setting: `{"azure_api_version":"2025-04-01-preview"}`},
+		{name: "preview", setting: `{"azure_api_version":"preview"}`},
+		{name: "query injection", setting: `{"azure_api_version":"2024-10-21&foo=bar"}`, wantErr: true},
+		{name: "free text", setting: `{"azure_api_version":"newest"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setting := tt.setting
+			channel := &Channel{Setting: &setting}
+			if err := channel.ValidateSettings(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSettings() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
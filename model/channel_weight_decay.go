@@ -0,0 +1,83 @@
+package model
+
+import (
+	"one-api/setting/operation_setting"
+	"sync"
+	"time"
+)
+
+// 渠道 id -> 权重系数，未记录的渠道系数为 1
+var channelWeightFactors = make(map[int]float64)
+
+// 渠道 id -> 最近一次出错后首次成功的时间，出错时清除
+var channelWeightSuccessSince = make(map[int]time.Time)
+var channelWeightFactorLock sync.RWMutex
+
+// RecordChannelWeightError multiplicatively decreases the channel's weight
+// factor.
+func RecordChannelWeightError(channelId int) {
+	setting := operation_setting.GetChannelWeightDecaySetting()
+	if !setting.Enabled || setting.DecreaseFactor <= 0 || setting.DecreaseFactor >= 1 {
+		return
+	}
+	channelWeightFactorLock.Lock()
+	defer channelWeightFactorLock.Unlock()
+	factor, ok := channelWeightFactors[channelId]
+	if !ok {
+		factor = 1
+	}
+	channelWeightFactors[channelId] = max(factor*setting.DecreaseFactor, setting.MinFactor)
+	delete(channelWeightSuccessSince, channelId)
+}
+
+// RecordChannelWeightSuccess additively restores the channel's weight factor,
+// forgetting the channel once it is fully recovered. A channel that has only
+// succeeded for the recovery grace period since its last error is forgotten
+// at once, so a recovered channel is not penalized for old errors.
+func RecordChannelWeightSuccess(channelId int) {
+	setting := operation_setting.GetChannelWeightDecaySetting()
+	if !setting.Enabled {
+		return
+	}
+	channelWeightFactorLock.Lock()
+	defer channelWeightFactorLock.Unlock()
+	factor, ok := channelWeightFactors[channelId]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	since, ok := channelWeightSuccessSince[channelId]
+	if !ok {
+		since = now
+		channelWeightSuccessSince[channelId] = now
+	}
+	factor += setting.IncreaseStep
+	grace := time.Duration(setting.RecoveryGraceSeconds) * time.Second
+	if factor >= 1 || setting.IncreaseStep <= 0 || (grace > 0 && now.Sub(since) >= grace) {
+		delete(channelWeightFactors, channelId)
+		delete(channelWeightSuccessSince, channelId)
+		return
+	}
+	channelWeightFactors[channelId] = factor
+}
+
+func GetChannelWeightFactor(channelId int) float64 {
+	if !operation_setting.GetChannelWeightDecaySetting().Enabled {
+		return 1
+	}
+	channelWeightFactorLock.RLock()
+	defer channelWeightFactorLock.RUnlock()
+	if factor, ok := channelWeightFactors[channelId]; ok {
+		return factor
+	}
+	return 1
+}
+
+// effectiveChannelWeight 渠道在加权随机选择中的权重，至少为 1
+func effectiveChannelWeight(channelId int, weight int) int {
+	factor := GetChannelWeightFactor(channelId)
+	if factor >= 1 {
+		return weight
+	}
+	return max(int(float64(weight)*factor), 1)
+}
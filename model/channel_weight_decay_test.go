@@ -0,0 +1,157 @@
+package model
+
+import (
+	"math"
+	"one-api/setting/operation_setting"
+	"testing"
+	"time"
+)
+
+func setupChannelWeightDecay(t *testing.T, setting operation_setting.ChannelWeightDecaySetting) {
+	t.Helper()
+	current := operation_setting.GetChannelWeightDecaySetting()
+	saved := *current
+	*current = setting
+	channelWeightFactorLock.Lock()
+	savedFactors, savedSince := channelWeightFactors, channelWeightSuccessSince
+	channelWeightFactors, channelWeightSuccessSince = make(map[int]float64), make(map[int]time.Time)
+	channelWeightFactorLock.Unlock()
+	t.Cleanup(func() {
+		*current = saved
+		channelWeightFactorLock.Lock()
+		channelWeightFactors, channelWeightSuccessSince = savedFactors, savedSince
+		channelWeightFactorLock.Unlock()
+	})
+}
+
+func TestChannelWeightDecayAndRecovery(t *testing.T) {
+	setupChannelWeightDecay(t, operation_setting.ChannelWeightDecaySetting{
+		Enabled: true, DecreaseFactor: 0.5, IncreaseStep: 0.2, MinFactor: 0.1,
+	})
+
+	steps := []struct {
+		name   string
+		failed bool
+		want   float64
+	}{
+		{name: "first error halves the weight", failed: true, want: 0.5},
+		{name: "second error halves it again", failed: true, want: 0.25},
+		{name: "third error", failed: true, want: 0.125},
+		{name: "errors stop at the minimum", failed: true, want: 0.1},
+		{name: "success adds the step", want: 0.3},
+		{name: "another success", want: 0.5},
+		{name: "error after recovery started", failed: true, want: 0.25},
+		{name: "success", want: 0.45},
+		{name: "success", want: 0.65},
+		{name: "success", want: 0.85},
+		{name: "fully recovered", want: 1},
+	}
+	for _, step := range steps {
+		if step.failed {
+			RecordChannelWeightError(1)
+		} else {
+			RecordChannelWeightSuccess(1)
+		}
+		if got := GetChannelWeightFactor(1); math.Abs(got-step.want) > 1e-9 {
+			t.Fatalf("%s: factor = %v, want %v", step.name, got, step.want)
+		}
+	}
+	channelWeightFactorLock.RLock()
+	_, tracked := channelWeightFactors[1]
+	channelWeightFactorLock.RUnlock()
+	if tracked {
+		t.Error("recovered channel is still tracked")
+	}
+	if got := GetChannelWeightFactor(2); got != 1 {
+		t.Errorf("untouched channel factor = %v, want 1", got)
+	}
+}
+
+func TestChannelWeightRecoveryGrace(t *testing.T) {
+	setupChannelWeightDecay(t, operation_setting.ChannelWeightDecaySetting{
+		Enabled: true, DecreaseFactor: 0.5, IncreaseStep: 0.01, MinFactor: 0.1, RecoveryGraceSeconds: 60,
+	})
+	RecordChannelWeightError(1)
+	RecordChannelWeightError(1)
+	RecordChannelWeightSuccess(1)
+	if got := GetChannelWeightFactor(1); math.Abs(got-0.26) > 1e-9 {
+		t.Fatalf("factor = %v, want 0.26", got)
+	}
+
+	// 出错后持续成功超过宽限期，直接恢复
+	channelWeightFactorLock.Lock()
+	channelWeightSuccessSince[1] = time.Now().Add(-time.Minute)
+	channelWeightFactorLock.Unlock()
+	RecordChannelWeightSuccess(1)
+	if got := GetChannelWeightFactor(1); got != 1 {
+		t.Errorf("factor after the grace period = %v, want 1", got)
+	}
+}
+
+func TestChannelWeightRecoveryGraceStreak(t *testing.T) {
+	tests := []struct {
+		name       string
+		grace      int
+		errorAfter bool
+		want       float64
+	}{
+		// 0.25 → 0.26 → 出错 0.13 → 新一轮 0.14 → 0.15
+		{name: "error after a long streak restarts it", grace: 60, errorAfter: true, want: 0.15},
+		{name: "zero grace only recovers by steps", grace: 0, want: 0.27},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupChannelWeightDecay(t, operation_setting.ChannelWeightDecaySetting{
+				Enabled: true, DecreaseFactor: 0.5, IncreaseStep: 0.01, MinFactor: 0.1, RecoveryGraceSeconds: tt.grace,
+			})
+			RecordChannelWeightError(1)
+			RecordChannelWeightError(1)
+			RecordChannelWeightSuccess(1)
+
+			// 将连续成功的起点移到宽限期之前
+			channelWeightFactorLock.Lock()
+			channelWeightSuccessSince[1] = time.Now().Add(-time.Minute)
+			channelWeightFactorLock.Unlock()
+			if tt.errorAfter {
+				RecordChannelWeightError(1)
+				RecordChannelWeightSuccess(1)
+			}
+			RecordChannelWeightSuccess(1)
+			if got := GetChannelWeightFactor(1); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("factor = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEffectiveChannelWeight(t *testing.T) {
+	setupChannelWeightDecay(t, operation_setting.ChannelWeightDecaySetting{
+		Enabled: true, DecreaseFactor: 0.5, IncreaseStep: 0.1, MinFactor: 0.01,
+	})
+	RecordChannelWeightError(1)
+	for i := 0; i < 10; i++ {
+		RecordChannelWeightError(2)
+	}
+
+	tests := []struct {
+		name      string
+		channelId int
+		weight    int
+		want      int
+	}{
+		{name: "healthy channel keeps its weight", channelId: 3, weight: 100, want: 100},
+		{name: "decayed channel", channelId: 1, weight: 100, want: 50},
+		{name: "heavily decayed channel keeps at least 1", channelId: 2, weight: 10, want: 1},
+	}
+	for _, tt := range tests {
+		if got := effectiveChannelWeight(tt.channelId, tt.weight); got != tt.want {
+			t.Errorf("%s: effectiveChannelWeight() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	// 关闭后不再按系数调整权重
+	operation_setting.GetChannelWeightDecaySetting().Enabled = false
+	if got := effectiveChannelWeight(1, 100); got != 100 {
+		t.Errorf("disabled: effectiveChannelWeight() = %d, want 100", got)
+	}
+}
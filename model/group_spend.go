@@ -0,0 +1,91 @@
+package model
+
+import (
+	"fmt"
+	"one-api/common"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// GroupSpend 分组每小时的消费额度，供分组消费上限统计
+type GroupSpend struct {
+	Id        int    `json:"id"`
+	GroupName string `json:"group_name" gorm:"uniqueIndex:idx_group_spend_hour,priority:1;size:64"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint;uniqueIndex:idx_group_spend_hour,priority:2"`
+	Quota     int    `json:"quota" gorm:"default:0"`
+}
+
+type groupSpendKey struct {
+	group     string
+	createdAt int64
+}
+
+// 尚未写入数据库的分组消费
+var (
+	groupSpendCache = make(map[groupSpendKey]int)
+	groupSpendLock  sync.Mutex
+)
+
+// RecordGroupSpend adds the quota to the spend of the group in the hour of
+// createdAt. The spend is kept in memory until SaveGroupSpend writes it.
+func RecordGroupSpend(group string, quota int, createdAt int64) {
+	if quota <= 0 {
+		return
+	}
+	key := groupSpendKey{group: group, createdAt: createdAt - createdAt%3600}
+	groupSpendLock.Lock()
+	groupSpendCache[key] += quota
+	groupSpendLock.Unlock()
+}
+
+// SaveGroupSpend writes the cached spend to the database so that every node
+// sees it. Spend that fails to be written is kept for the next call.
+func SaveGroupSpend() {
+	groupSpendLock.Lock()
+	cache := groupSpendCache
+	groupSpendCache = make(map[groupSpendKey]int)
+	groupSpendLock.Unlock()
+	for key, quota := range cache {
+		if err := increaseGroupSpend(key.group, key.createdAt, quota); err != nil {
+			common.SysError(fmt.Sprintf("failed to save spend of group %s: %s", key.group, err.Error()))
+			RecordGroupSpend(key.group, quota, key.createdAt)
+		}
+	}
+}
+
+func increaseGroupSpend(group string, createdAt int64, quota int) error {
+	update := func() (bool, error) {
+		result := DB.Model(&GroupSpend{}).Where("group_name = ? and created_at = ?", group, createdAt).
+			Update("quota", gorm.Expr("quota + ?", quota))
+		return result.RowsAffected > 0, result.Error
+	}
+	if updated, err := update(); err != nil || updated {
+		return err
+	}
+	if err := DB.Create(&GroupSpend{GroupName: group, CreatedAt: createdAt, Quota: quota}).Error; err != nil {
+		// 其他节点已插入同一小时的记录
+		if updated, updateErr := update(); updateErr != nil || !updated {
+			return err
+		}
+	}
+	return nil
+}
+
+// SumGroupSpend 统计分组自 startTime 起的消费额度，包含尚未写入数据库的部分
+func SumGroupSpend(group string, startTime int64) (int, error) {
+	var quota int
+	err := DB.Model(&GroupSpend{}).Select("coalesce(sum(quota), 0)").
+		Where("group_name = ? and created_at >= ?", group, startTime).Scan(&quota).Error
+	if err != nil {
+		return 0, err
+	}
+	groupSpendLock.Lock()
+	defer groupSpendLock.Unlock()
+	for key, pending := range groupSpendCache {
+		if key.group == group && key.createdAt >= startTime {
+			quota += pending
+		}
+	}
+	return quota, nil
+}
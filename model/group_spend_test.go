@@ -0,0 +1,116 @@
+package model
+
+import (
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/setting/operation_setting"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupGroupSpendTestDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sqlite handle: %v", err)
+	}
+	// 内存数据库每个连接相互独立，限制为单连接以共享同一份数据
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&GroupSpend{}); err != nil {
+		t.Fatalf("migrate group_spends: %v", err)
+	}
+	mainDB := DB
+	DB = db
+	groupSpendLock.Lock()
+	cache := groupSpendCache
+	groupSpendCache = make(map[groupSpendKey]int)
+	groupSpendLock.Unlock()
+	t.Cleanup(func() {
+		DB = mainDB
+		groupSpendLock.Lock()
+		groupSpendCache = cache
+		groupSpendLock.Unlock()
+	})
+}
+
+func TestSumGroupSpend(t *testing.T) {
+	setupGroupSpendTestDB(t)
+	RecordGroupSpend("vip", 100, 3600)
+	RecordGroupSpend("vip", 50, 7300)
+	RecordGroupSpend("default", 1000, 7300)
+	SaveGroupSpend()
+	// 同一小时再次写入时累加到已有记录
+	RecordGroupSpend("vip", 20, 7400)
+	SaveGroupSpend()
+	// 尚未写入数据库的消费同样计入
+	RecordGroupSpend("vip", 7, 7500)
+
+	tests := []struct {
+		name      string
+		group     string
+		startTime int64
+		want      int
+	}{
+		{name: "whole period", group: "vip", startTime: 0, want: 177},
+		{name: "from start time", group: "vip", startTime: 7200, want: 77},
+		{name: "other group", group: "default", startTime: 0, want: 1000},
+		{name: "no spend", group: "free", startTime: 0, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SumGroupSpend(tt.group, tt.startTime)
+			if err != nil || got != tt.want {
+				t.Errorf("SumGroupSpend(%q, %d) = %d, %v; want %d", tt.group, tt.startTime, got, err, tt.want)
+			}
+		})
+	}
+	var rows int64
+	DB.Model(&GroupSpend{}).Where("group_name = ?", "vip").Count(&rows)
+	if rows != 2 {
+		t.Errorf("vip rows = %d, want one per hour", rows)
+	}
+}
+
+func TestRecordConsumeLogCountsGroupSpendWithoutConsumeLogs(t *testing.T) {
+	setupGroupSpendTestDB(t)
+	budgetSetting := operation_setting.GetGroupBudgetSetting()
+	budgets, logConsumeEnabled, dataExportEnabled := budgetSetting.Budgets, common.LogConsumeEnabled, common.DataExportEnabled
+	budgetSetting.Budgets = map[string]operation_setting.GroupBudget{"vip": {Quota: 1000}}
+	common.LogConsumeEnabled, common.DataExportEnabled = false, true
+	CacheQuotaDataLock.Lock()
+	quotaData := CacheQuotaData
+	CacheQuotaData = make(map[string]*QuotaData)
+	CacheQuotaDataLock.Unlock()
+	t.Cleanup(func() {
+		budgetSetting.Budgets = budgets
+		common.LogConsumeEnabled, common.DataExportEnabled = logConsumeEnabled, dataExportEnabled
+		CacheQuotaDataLock.Lock()
+		CacheQuotaData = quotaData
+		CacheQuotaDataLock.Unlock()
+	})
+
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	RecordConsumeLog(c, 1, RecordConsumeLogParams{ModelName: "gpt-4o", Quota: 300, Group: "vip"})
+	RecordConsumeLog(c, 1, RecordConsumeLogParams{ModelName: "gpt-4o", Quota: 500, Group: "default"})
+
+	if got, _ := SumGroupSpend("vip", 0); got != 300 {
+		t.Errorf("vip spend = %d, want 300 with consume logs disabled", got)
+	}
+	if got, _ := SumGroupSpend("default", 0); got != 0 {
+		t.Errorf("spend of a group without budget = %d, want 0", got)
+	}
+	CacheQuotaDataLock.Lock()
+	defer CacheQuotaDataLock.Unlock()
+	if len(CacheQuotaData) != 0 {
+		t.Errorf("quota data recorded with consume logs disabled: %v", CacheQuotaData)
+	}
+}
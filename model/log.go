@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"one-api/common"
+	"one-api/setting/operation_setting"
 	"os"
 	"strings"
 	"time"
@@ -65,9 +66,9 @@ func GetLogByKey(key string) (logs []*Log, err error) {
 		if err = DB.Model(&Token{}).Where(logKeyCol+"=?", strings.TrimPrefix(key, "sk-")).First(&tk).Error; err != nil {
 			return nil, err
 		}
-		err = LOG_DB.Model(&Log{}).Where("token_id=?", tk.Id).Find(&logs).Error
+		err = getLogDB(true).Model(&Log{}).Where("token_id=?", tk.Id).Find(&logs).Error
 	} else {
-		err = LOG_DB.Joins("left join tokens on tokens.id = logs.token_id").Where("tokens.key = ?", strings.TrimPrefix(key, "sk-")).Find(&logs).Error
+		err = getLogDB(true).Joins("left join tokens on tokens.id = logs.token_id").Where("tokens.key = ?", strings.TrimPrefix(key, "sk-")).Find(&logs).Error
 	}
 	formatUserLogs(logs)
 	return logs, err
@@ -149,8 +150,19 @@ type RecordConsumeLogParams struct {
 	Other            map[string]interface{} `json:"other"`
 }
 
+// ConsumeLogHook is called for every consume record, even when consume logs
+// are disabled. It is used to stream request events to external sinks.
+var ConsumeLogHook func(c *gin.Context, userId int, params RecordConsumeLogParams)
+
 func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams) {
 	common.LogInfo(c, fmt.Sprintf("record consume log: userId=%d, params=%s", userId, common.GetJsonString(params)))
+	if ConsumeLogHook != nil {
+		ConsumeLogHook(c, userId, params)
+	}
+	// 分组消费上限单独统计，不受消费日志和数据看板开关影响
+	if _, ok := operation_setting.GetGroupBudgetSetting().Budgets[params.Group]; ok {
+		RecordGroupSpend(params.Group, params.Quota, common.GetTimestamp())
+	}
 	if !common.LogConsumeEnabled {
 		return
 	}
@@ -187,9 +199,13 @@ func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams)
 		}(),
 		Other: otherStr,
 	}
-	err := LOG_DB.Create(log).Error
-	if err != nil {
-		common.LogError(c, "failed to record log: "+err.Error())
+	if common.BatchLogInsertEnabled {
+		addLogToBatch(log)
+	} else {
+		err := LOG_DB.Create(log).Error
+		if err != nil {
+			common.LogError(c, "failed to record log: "+err.Error())
+		}
 	}
 	if common.DataExportEnabled {
 		gopool.Go(func() {
@@ -198,12 +214,35 @@ func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams)
 	}
 }
 
-func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, startIdx int, num int, channel int, group string) (logs []*Log, total int64, err error) {
+// likeEscaper escapes the LIKE wildcards and the escape character itself,
+// for patterns used with ESCAPE '!'.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// clientMetadataPattern builds a LIKE pattern, used with ESCAPE '!', matching
+// a "key:value" client metadata tag in the log's other field. The pattern is
+// anchored to the client_metadata object, so the search cannot match values
+// of other fields such as admin_info, and wildcards in the tag match
+// literally.
+func clientMetadataPattern(metadata string) (string, bool) {
+	key, value, found := strings.Cut(metadata, ":")
+	if !found || key == "" {
+		return "", false
+	}
+	tag, err := common.EncodeJson(map[string]string{key: value})
+	if err != nil {
+		return "", false
+	}
+	// {"key":"value"} -> %"client_metadata":{%"key":"value"%
+	tagText := strings.TrimSuffix(strings.TrimPrefix(string(tag), "{"), "}")
+	return `%"client_metadata":{%` + likeEscaper.Replace(tagText) + "%", true
+}
+
+func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, startIdx int, num int, channel int, group string, metadata string) (logs []*Log, total int64, err error) {
 	var tx *gorm.DB
 	if logType == LogTypeUnknown {
-		tx = LOG_DB
+		tx = getLogDB(true)
 	} else {
-		tx = LOG_DB.Where("logs.type = ?", logType)
+		tx = getLogDB(true).Where("logs.type = ?", logType)
 	}
 
 	if modelName != "" {
@@ -227,6 +266,9 @@ func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName
 	if group != "" {
 		tx = tx.Where("logs."+logGroupCol+" = ?", group)
 	}
+	if pattern, ok := clientMetadataPattern(metadata); ok {
+		tx = tx.Where("logs.other like ? escape '!'", pattern)
+	}
 	err = tx.Model(&Log{}).Count(&total).Error
 	if err != nil {
 		return nil, 0, err
@@ -267,12 +309,12 @@ func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName
 	return logs, total, err
 }
 
-func GetUserLogs(userId int, logType int, startTimestamp int64, endTimestamp int64, modelName string, tokenName string, startIdx int, num int, group string) (logs []*Log, total int64, err error) {
+func GetUserLogs(userId int, logType int, startTimestamp int64, endTimestamp int64, modelName string, tokenName string, startIdx int, num int, group string, metadata string) (logs []*Log, total int64, err error) {
 	var tx *gorm.DB
 	if logType == LogTypeUnknown {
-		tx = LOG_DB.Where("logs.user_id = ?", userId)
+		tx = getLogDB(true).Where("logs.user_id = ?", userId)
 	} else {
-		tx = LOG_DB.Where("logs.user_id = ? and logs.type = ?", userId, logType)
+		tx = getLogDB(true).Where("logs.user_id = ? and logs.type = ?", userId, logType)
 	}
 
 	if modelName != "" {
@@ -290,6 +332,9 @@ func GetUserLogs(userId int, logType int, startTimestamp int64, endTimestamp int
 	if group != "" {
 		tx = tx.Where("logs."+logGroupCol+" = ?", group)
 	}
+	if pattern, ok := clientMetadataPattern(metadata); ok {
+		tx = tx.Where("logs.other like ? escape '!'", pattern)
+	}
 	err = tx.Model(&Log{}).Count(&total).Error
 	if err != nil {
 		return nil, 0, err
@@ -304,12 +349,12 @@ func GetUserLogs(userId int, logType int, startTimestamp int64, endTimestamp int
 }
 
 func SearchAllLogs(keyword string) (logs []*Log, err error) {
-	err = LOG_DB.Where("type = ? or content LIKE ?", keyword, keyword+"%").Order("id desc").Limit(common.MaxRecentItems).Find(&logs).Error
+	err = getLogDB(true).Where("type = ? or content LIKE ?", keyword, keyword+"%").Order("id desc").Limit(common.MaxRecentItems).Find(&logs).Error
 	return logs, err
 }
 
 func SearchUserLogs(userId int, keyword string) (logs []*Log, err error) {
-	err = LOG_DB.Where("user_id = ? and type = ?", userId, keyword).Order("id desc").Limit(common.MaxRecentItems).Find(&logs).Error
+	err = getLogDB(true).Where("user_id = ? and type = ?", userId, keyword).Order("id desc").Limit(common.MaxRecentItems).Find(&logs).Error
 	formatUserLogs(logs)
 	return logs, err
 }
@@ -321,10 +366,10 @@ type Stat struct {
 }
 
 func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, channel int, group string) (stat Stat) {
-	tx := LOG_DB.Table("logs").Select("sum(quota) quota")
+	tx := getLogDB(true).Table("logs").Select("sum(quota) quota")
 
 	// 为rpm和tpm创建单独的查询
-	rpmTpmQuery := LOG_DB.Table("logs").Select("count(*) rpm, sum(prompt_tokens) + sum(completion_tokens) tpm")
+	rpmTpmQuery := getLogDB(true).Table("logs").Select("count(*) rpm, sum(prompt_tokens) + sum(completion_tokens) tpm")
 
 	if username != "" {
 		tx = tx.Where("username = ?", username)
@@ -367,7 +412,7 @@ func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelNa
 }
 
 func SumUsedToken(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string) (token int) {
-	tx := LOG_DB.Table("logs").Select("ifnull(sum(prompt_tokens),0) + ifnull(sum(completion_tokens),0)")
+	tx := getLogDB(true).Table("logs").Select("ifnull(sum(prompt_tokens),0) + ifnull(sum(completion_tokens),0)")
 	if username != "" {
 		tx = tx.Where("username = ?", username)
 	}
@@ -409,3 +454,26 @@ func DeleteOldLog(ctx context.Context, targetTimestamp int64, limit int) (int64,
 
 	return total, nil
 }
+
+func DeleteUserLogs(ctx context.Context, userId int, limit int) (int64, error) {
+	var total int64 = 0
+
+	for {
+		if nil != ctx.Err() {
+			return total, ctx.Err()
+		}
+
+		result := LOG_DB.Where("user_id = ?", userId).Limit(limit).Delete(&Log{})
+		if nil != result.Error {
+			return total, result.Error
+		}
+
+		total += result.RowsAffected
+
+		if result.RowsAffected < int64(limit) {
+			break
+		}
+	}
+
+	return total, nil
+}
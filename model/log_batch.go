@@ -0,0 +1,64 @@
+package model
+
+import (
+	"fmt"
+	"one-api/common"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+var logBatchBuffer []*Log
+var logBatchLock sync.Mutex
+
+func InitLogBatchInserter() {
+	gopool.Go(func() {
+		runLogBatchFlusher(time.Duration(common.BatchUpdateInterval)*time.Second, nil)
+	})
+}
+
+// runLogBatchFlusher flushes the buffered logs every interval until stop is
+// closed.
+func runLogBatchFlusher(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			FlushLogBatch()
+		case <-stop:
+			return
+		}
+	}
+}
+
+func addLogToBatch(log *Log) {
+	logBatchLock.Lock()
+	logBatchBuffer = append(logBatchBuffer, log)
+	full := len(logBatchBuffer) >= common.BatchLogInsertSize
+	logBatchLock.Unlock()
+	if full {
+		gopool.Go(FlushLogBatch)
+	}
+}
+
+// FlushLogBatch writes all buffered consume logs with multi-row inserts.
+// It must also be called on shutdown so that no buffered logs are lost.
+func FlushLogBatch() {
+	logBatchLock.Lock()
+	logs := logBatchBuffer
+	logBatchBuffer = nil
+	logBatchLock.Unlock()
+	if len(logs) == 0 {
+		return
+	}
+	batchSize := common.BatchLogInsertSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	err := LOG_DB.CreateInBatches(logs, batchSize).Error
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to batch insert %d logs: %s", len(logs), err.Error()))
+	}
+}
@@ -0,0 +1,67 @@
+package model
+
+import (
+	"one-api/common"
+	"testing"
+	"time"
+)
+
+func TestLogBatchFlush(t *testing.T) {
+	db := openLogTestDB(t)
+	logDB, batchSize := LOG_DB, common.BatchLogInsertSize
+	LOG_DB, common.BatchLogInsertSize = db, 3
+	t.Cleanup(func() {
+		FlushLogBatch()
+		LOG_DB, common.BatchLogInsertSize = logDB, batchSize
+	})
+
+	countLogs := func() int64 {
+		var count int64
+		db.Model(&Log{}).Count(&count)
+		return count
+	}
+	waitForLogs := func(want int64) int64 {
+		deadline := time.Now().Add(2 * time.Second)
+		for countLogs() < want && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		return countLogs()
+	}
+	addLogs := func(n int) {
+		for i := 0; i < n; i++ {
+			addLogToBatch(&Log{UserId: 1, Type: LogTypeConsume, CreatedAt: common.GetTimestamp()})
+		}
+	}
+
+	// 未写满一批时只缓冲
+	addLogs(2)
+	if got := countLogs(); got != 0 {
+		t.Fatalf("%d logs written before the batch was full, want 0", got)
+	}
+	addLogs(1)
+	if got := waitForLogs(3); got != 3 {
+		t.Fatalf("%d logs written after the batch filled up, want 3", got)
+	}
+
+	// 定时刷新写入不足一批的日志
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		runLogBatchFlusher(10*time.Millisecond, stop)
+		close(done)
+	}()
+	addLogs(1)
+	got := waitForLogs(4)
+	close(stop)
+	<-done
+	if got != 4 {
+		t.Fatalf("%d logs written after the flush interval, want 4", got)
+	}
+
+	// 关闭时写入缓冲中剩余的日志
+	addLogs(2)
+	FlushLogBatch()
+	if got := countLogs(); got != 6 {
+		t.Errorf("%d logs written after the shutdown flush, want 6", got)
+	}
+}
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"errors"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// 日志导出水位线（已导出的最大日志 id）保存在 options 表中，重启后继续导出
+const logExportWatermarkKey = "LogExportWatermark"
+
+func GetLogExportWatermark() (int, error) {
+	option := Option{}
+	err := DB.First(&option, Option{Key: logExportWatermarkKey}).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(option.Value)
+}
+
+func SaveLogExportWatermark(id int) error {
+	return DB.Save(&Option{Key: logExportWatermarkKey, Value: strconv.Itoa(id)}).Error
+}
+
+// GetConsumeLogsForExport returns up to limit consume logs with an id above
+// afterId, oldest first. The batch stops before the first log created after
+// createdBefore instead of skipping it: exporting later ids would move the
+// watermark past that log and it would never be exported.
+func GetConsumeLogsForExport(afterId int, createdBefore int64, limit int) (logs []*Log, err error) {
+	err = LOG_DB.Where("id > ? AND type = ?", afterId, LogTypeConsume).
+		Order("id asc").Limit(limit).Find(&logs).Error
+	if err != nil {
+		return nil, err
+	}
+	for i, log := range logs {
+		if log.CreatedAt > createdBefore {
+			return logs[:i], nil
+		}
+	}
+	return logs, nil
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupLogExportTestDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&Log{}); err != nil {
+		t.Fatalf("migrate logs: %v", err)
+	}
+	logDB := LOG_DB
+	LOG_DB = db
+	t.Cleanup(func() { LOG_DB = logDB })
+}
+
+func TestGetConsumeLogsForExport(t *testing.T) {
+	setupLogExportTestDB(t)
+	// id 3 was inserted after id 2 but is still too new; id 4 is old enough
+	// but must wait until id 3 has been exported.
+	rows := []*Log{
+		{Id: 1, Type: LogTypeConsume, CreatedAt: 100},
+		{Id: 2, Type: LogTypeTopup, CreatedAt: 100},
+		{Id: 3, Type: LogTypeConsume, CreatedAt: 200},
+		{Id: 4, Type: LogTypeConsume, CreatedAt: 110},
+		{Id: 5, Type: LogTypeConsume, CreatedAt: 120},
+	}
+	if err := LOG_DB.Create(&rows).Error; err != nil {
+		t.Fatalf("insert logs: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		afterId       int
+		createdBefore int64
+		limit         int
+		want          []int
+	}{
+		{name: "stops at newer log", afterId: 0, createdBefore: 150, limit: 10, want: []int{1}},
+		{name: "newer log first", afterId: 1, createdBefore: 150, limit: 10, want: []int{}},
+		{name: "all old enough", afterId: 0, createdBefore: 200, limit: 10, want: []int{1, 3, 4, 5}},
+		{name: "limit", afterId: 0, createdBefore: 200, limit: 2, want: []int{1, 3}},
+		{name: "after watermark", afterId: 3, createdBefore: 150, limit: 10, want: []int{4, 5}},
+		{name: "nothing left", afterId: 5, createdBefore: 200, limit: 10, want: []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs, err := GetConsumeLogsForExport(tt.afterId, tt.createdBefore, tt.limit)
+			if err != nil {
+				t.Fatalf("GetConsumeLogsForExport() error = %v", err)
+			}
+			ids := make([]int, 0, len(logs))
+			for _, log := range logs {
+				ids = append(ids, log.Id)
+			}
+			if len(ids) != len(tt.want) {
+				t.Fatalf("GetConsumeLogsForExport() ids = %v, want %v", ids, tt.want)
+			}
+			for i := range ids {
+				if ids[i] != tt.want[i] {
+					t.Fatalf("GetConsumeLogsForExport() ids = %v, want %v", ids, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package model
+
+import (
+	"context"
+	"one-api/common"
+	"strconv"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func openLogTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sqlite handle: %v", err)
+	}
+	// 内存数据库每个连接相互独立，限制为单连接以共享同一份数据
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&Log{}, &User{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func TestLogReplicaRouting(t *testing.T) {
+	primary, replica := openLogTestDB(t), openLogTestDB(t)
+	mainDB, logDB, replicaDB := DB, LOG_DB, LOG_REPLICA_DB
+	DB, LOG_DB, LOG_REPLICA_DB = primary, primary, replica
+	t.Cleanup(func() { DB, LOG_DB, LOG_REPLICA_DB = mainDB, logDB, replicaDB })
+
+	if err := replica.Create(&Log{Id: 1, UserId: 1, Type: LogTypeSystem, Content: "replicated", CreatedAt: 100}).Error; err != nil {
+		t.Fatalf("insert replica log: %v", err)
+	}
+	RecordLog(1, LogTypeSystem, "written")
+
+	countLogs := func(db *gorm.DB) int64 {
+		var count int64
+		db.Model(&Log{}).Count(&count)
+		return count
+	}
+	if primaryCount, replicaCount := countLogs(primary), countLogs(replica); primaryCount != 1 || replicaCount != 1 {
+		t.Fatalf("primary has %d logs, replica has %d, want the write only on the primary", primaryCount, replicaCount)
+	}
+
+	logs, total, err := GetAllLogs(LogTypeUnknown, 0, 0, "", "", "", 0, 10, 0, "", "")
+	if err != nil || total != 1 || logs[0].Content != "replicated" {
+		t.Errorf("GetAllLogs() = %d logs, err %v, want the replica's log", total, err)
+	}
+	logs, total, err = GetUserLogs(1, LogTypeUnknown, 0, 0, "", "", 0, 10, "", "")
+	if err != nil || total != 1 || logs[0].Content != "replicated" {
+		t.Errorf("GetUserLogs() = %d logs, err %v, want the replica's log", total, err)
+	}
+	if logs, err := SearchUserLogs(1, strconv.Itoa(LogTypeSystem)); err != nil || len(logs) != 1 {
+		t.Errorf("SearchUserLogs() = %d logs, err %v, want the replica's log", len(logs), err)
+	}
+
+	deleted, err := DeleteOldLog(context.Background(), common.GetTimestamp()+1, 100)
+	if err != nil || deleted != 1 {
+		t.Errorf("DeleteOldLog() = %d, %v, want the primary's log deleted", deleted, err)
+	}
+	if countLogs(replica) != 1 {
+		t.Errorf("replica has %d logs, want deletes to go to the primary", countLogs(replica))
+	}
+
+	// 未配置副本时读取主库
+	LOG_REPLICA_DB = nil
+	RecordLog(1, LogTypeSystem, "written")
+	if logs, total, err := GetAllLogs(LogTypeUnknown, 0, 0, "", "", "", 0, 10, 0, "", ""); err != nil || total != 1 || logs[0].Content != "written" {
+		t.Errorf("GetAllLogs() without a replica = %d logs, err %v, want the primary's log", total, err)
+	}
+}
+
+func TestClientMetadataLogSearch(t *testing.T) {
+	setupLogExportTestDB(t)
+	rows := []*Log{
+		{Id: 1, UserId: 1, Type: LogTypeConsume, Other: `{"client_metadata":{"team":"a_b"}}`},
+		{Id: 2, UserId: 1, Type: LogTypeConsume, Other: `{"client_metadata":{"team":"axb"}}`},
+		{Id: 3, UserId: 1, Type: LogTypeConsume, Other: `{"admin_info":{"team":"a_b"},"client_metadata":{"env":"prod"}}`},
+		{Id: 4, UserId: 2, Type: LogTypeConsume, Other: `{"client_metadata":{"team":"a_b"}}`},
+		{Id: 5, UserId: 1, Type: LogTypeConsume, Other: `{"client_metadata":{"team":"100%"}}`},
+	}
+	if err := LOG_DB.Create(&rows).Error; err != nil {
+		t.Fatalf("insert logs: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		metadata  string
+		wantUser  int64
+		wantAdmin int64
+	}{
+		{name: "underscore matches literally", metadata: "team:a_b", wantUser: 1, wantAdmin: 2},
+		{name: "percent matches literally", metadata: "team:100%", wantUser: 1, wantAdmin: 1},
+		{name: "percent is not a wildcard", metadata: "team:%", wantUser: 0, wantAdmin: 0},
+		{name: "other key", metadata: "env:prod", wantUser: 1, wantAdmin: 1},
+		{name: "no tag", metadata: "team", wantUser: 4, wantAdmin: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, total, err := GetUserLogs(1, LogTypeUnknown, 0, 0, "", "", 0, 10, "", tt.metadata)
+			if err != nil {
+				t.Fatalf("GetUserLogs: %v", err)
+			}
+			if total != tt.wantUser {
+				t.Errorf("GetUserLogs(%q) total = %d, want %d", tt.metadata, total, tt.wantUser)
+			}
+			_, total, err = GetAllLogs(LogTypeUnknown, 0, 0, "", "", "", 0, 10, 0, "", tt.metadata)
+			if err != nil {
+				t.Fatalf("GetAllLogs: %v", err)
+			}
+			if total != tt.wantAdmin {
+				t.Errorf("GetAllLogs(%q) total = %d, want %d", tt.metadata, total, tt.wantAdmin)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"database/sql"
 	"fmt"
 	"log"
 	"one-api/common"
@@ -64,6 +65,18 @@ var DB *gorm.DB
 
 var LOG_DB *gorm.DB
 
+// LOG_REPLICA_DB 用于只读的日志查询，未配置 LOG_DB_REPLICA_DSN 时与 LOG_DB 相同
+var LOG_REPLICA_DB *gorm.DB
+
+// getLogDB returns the connection for log queries, routing reads to the
+// replica when one is configured.
+func getLogDB(readOnly bool) *gorm.DB {
+	if readOnly && LOG_REPLICA_DB != nil {
+		return LOG_REPLICA_DB
+	}
+	return LOG_DB
+}
+
 func createRootAccountIfNeed() error {
 	var user User
 	//if user.Status != common.UserStatusEnabled {
@@ -173,6 +186,20 @@ func chooseDB(envName string, isLog bool) (*gorm.DB, error) {
 	})
 }
 
+func setDBConnPool(sqlDB *sql.DB, name string) {
+	maxIdleConns := common.GetEnvOrDefault("SQL_MAX_IDLE_CONNS", 100)
+	maxOpenConns := common.GetEnvOrDefault("SQL_MAX_OPEN_CONNS", 1000)
+	maxLifetime := common.GetEnvOrDefault("SQL_MAX_LIFETIME", 60)
+	maxIdleTime := common.GetEnvOrDefault("SQL_MAX_IDLE_TIME", 0)
+	sqlDB.SetMaxIdleConns(maxIdleConns)
+	sqlDB.SetMaxOpenConns(maxOpenConns)
+	sqlDB.SetConnMaxLifetime(time.Second * time.Duration(maxLifetime))
+	// 0 表示不限制空闲时间
+	sqlDB.SetConnMaxIdleTime(time.Second * time.Duration(maxIdleTime))
+	common.SysLog(fmt.Sprintf("%s database pool: max_open_conns=%d, max_idle_conns=%d, conn_max_lifetime=%ds, conn_max_idle_time=%ds",
+		name, maxOpenConns, maxIdleConns, maxLifetime, maxIdleTime))
+}
+
 func InitDB() (err error) {
 	db, err := chooseDB("SQL_DSN", false)
 	if err == nil {
@@ -184,9 +211,7 @@ func InitDB() (err error) {
 		if err != nil {
 			return err
 		}
-		sqlDB.SetMaxIdleConns(common.GetEnvOrDefault("SQL_MAX_IDLE_CONNS", 100))
-		sqlDB.SetMaxOpenConns(common.GetEnvOrDefault("SQL_MAX_OPEN_CONNS", 1000))
-		sqlDB.SetConnMaxLifetime(time.Second * time.Duration(common.GetEnvOrDefault("SQL_MAX_LIFETIME", 60)))
+		setDBConnPool(sqlDB, "main")
 
 		if !common.IsMasterNode {
 			return nil
@@ -218,9 +243,7 @@ func InitLogDB() (err error) {
 		if err != nil {
 			return err
 		}
-		sqlDB.SetMaxIdleConns(common.GetEnvOrDefault("SQL_MAX_IDLE_CONNS", 100))
-		sqlDB.SetMaxOpenConns(common.GetEnvOrDefault("SQL_MAX_OPEN_CONNS", 1000))
-		sqlDB.SetConnMaxLifetime(time.Second * time.Duration(common.GetEnvOrDefault("SQL_MAX_LIFETIME", 60)))
+		setDBConnPool(sqlDB, "log")
 
 		if !common.IsMasterNode {
 			return nil
@@ -240,6 +263,28 @@ func InitLogDB() (err error) {
 	return err
 }
 
+func InitLogReplicaDB() (err error) {
+	if os.Getenv("LOG_DB_REPLICA_DSN") == "" {
+		LOG_REPLICA_DB = LOG_DB
+		return nil
+	}
+	db, err := chooseDB("LOG_DB_REPLICA_DSN", true)
+	if err != nil {
+		return err
+	}
+	if common.DebugEnabled {
+		db = db.Debug()
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	setDBConnPool(sqlDB, "log replica")
+	LOG_REPLICA_DB = db
+	common.SysLog("log queries will be served by the read replica")
+	return nil
+}
+
 func migrateDB() error {
 	if !common.UsingPostgreSQL {
 		return migrateDBFast()
@@ -257,6 +302,9 @@ func migrateDB() error {
 		&QuotaData{},
 		&Task{},
 		&Setup{},
+		&RateLimitTier{},
+		&AuditLog{},
+		&GroupSpend{},
 	)
 	if err != nil {
 		return err
@@ -266,7 +314,7 @@ func migrateDB() error {
 
 func migrateDBFast() error {
 	var wg sync.WaitGroup
-	errChan := make(chan error, 12) // Buffer size matches number of migrations
+	errChan := make(chan error, 15) // Buffer size matches number of migrations
 
 	migrations := []struct {
 		model interface{}
@@ -284,6 +332,9 @@ func migrateDBFast() error {
 		{&QuotaData{}, "QuotaData"},
 		{&Task{}, "Task"},
 		{&Setup{}, "Setup"},
+		{&RateLimitTier{}, "RateLimitTier"},
+		{&AuditLog{}, "AuditLog"},
+		{&GroupSpend{}, "GroupSpend"},
 	}
 
 	for _, m := range migrations {
@@ -328,6 +379,12 @@ func closeDB(db *gorm.DB) error {
 }
 
 func CloseDB() error {
+	if LOG_REPLICA_DB != nil && LOG_REPLICA_DB != LOG_DB && LOG_REPLICA_DB != DB {
+		err := closeDB(LOG_REPLICA_DB)
+		if err != nil {
+			return err
+		}
+	}
 	if LOG_DB != DB {
 		err := closeDB(LOG_DB)
 		if err != nil {
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"one-api/common"
+	"os"
+	"testing"
+
+	_ "github.com/glebarez/sqlite"
+)
+
+func TestMain(m *testing.M) {
+	// 测试环境没有 Redis，缓存相关的异步更新也不能访问 Redis
+	common.RedisEnabled = false
+	os.Exit(m.Run())
+}
+
+func TestSetDBConnPool(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		wantMaxOpen int
+		wantIdle    int
+	}{
+		{name: "defaults", wantMaxOpen: 1000, wantIdle: 3},
+		{name: "configured", env: map[string]string{"SQL_MAX_OPEN_CONNS": "3", "SQL_MAX_IDLE_CONNS": "1",
+			"SQL_MAX_LIFETIME": "120", "SQL_MAX_IDLE_TIME": "30"}, wantMaxOpen: 3, wantIdle: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"SQL_MAX_OPEN_CONNS", "SQL_MAX_IDLE_CONNS", "SQL_MAX_LIFETIME", "SQL_MAX_IDLE_TIME"} {
+				t.Setenv(key, tt.env[key])
+			}
+			sqlDB, err := sql.Open("sqlite", "file::memory:")
+			if err != nil {
+				t.Fatalf("open sqlite: %v", err)
+			}
+			defer sqlDB.Close()
+
+			setDBConnPool(sqlDB, "test")
+
+			if got := sqlDB.Stats().MaxOpenConnections; got != tt.wantMaxOpen {
+				t.Errorf("max open conns = %d, want %d", got, tt.wantMaxOpen)
+			}
+			// 同时占用三个连接后归还，空闲连接数受 SQL_MAX_IDLE_CONNS 限制
+			var conns []*sql.Conn
+			for i := 0; i < 3; i++ {
+				conn, err := sqlDB.Conn(context.Background())
+				if err != nil {
+					t.Fatalf("get conn: %v", err)
+				}
+				conns = append(conns, conn)
+			}
+			for _, conn := range conns {
+				_ = conn.Close()
+			}
+			if got := sqlDB.Stats().Idle; got != tt.wantIdle {
+				t.Errorf("idle conns = %d, want %d", got, tt.wantIdle)
+			}
+		})
+	}
+}
@@ -109,6 +109,9 @@ func InitOptionMap() {
 	//common.OptionMap["ChatLink"] = common.ChatLink
 	//common.OptionMap["ChatLink2"] = common.ChatLink2
 	common.OptionMap["QuotaPerUnit"] = strconv.FormatFloat(common.QuotaPerUnit, 'f', -1, 64)
+	common.OptionMap["QuotaDisplayCurrencySymbol"] = common.QuotaDisplayCurrencySymbol
+	common.OptionMap["QuotaDisplayDecimals"] = strconv.Itoa(common.QuotaDisplayDecimals)
+	common.OptionMap["QuotaDisplayExchangeRate"] = strconv.FormatFloat(common.QuotaDisplayExchangeRate, 'f', -1, 64)
 	common.OptionMap["RetryTimes"] = strconv.Itoa(common.RetryTimes)
 	common.OptionMap["DataExportInterval"] = strconv.Itoa(common.DataExportInterval)
 	common.OptionMap["DataExportDefaultTime"] = common.DataExportDefaultTime
@@ -386,6 +389,12 @@ func updateOptionMap(key string, value string) (err error) {
 		common.ChannelDisableThreshold, _ = strconv.ParseFloat(value, 64)
 	case "QuotaPerUnit":
 		common.QuotaPerUnit, _ = strconv.ParseFloat(value, 64)
+	case "QuotaDisplayCurrencySymbol":
+		common.QuotaDisplayCurrencySymbol = value
+	case "QuotaDisplayDecimals":
+		common.QuotaDisplayDecimals, _ = strconv.Atoi(value)
+	case "QuotaDisplayExchangeRate":
+		common.QuotaDisplayExchangeRate, _ = strconv.ParseFloat(value, 64)
 	case "SensitiveWords":
 		setting.SensitiveWordsFromString(value)
 	case "AutomaticDisableKeywords":
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"one-api/common"
+	"testing"
+)
+
+func TestUpdateOptionMapQuotaDisplay(t *testing.T) {
+	savedSymbol, savedDecimals, savedRate := common.QuotaDisplayCurrencySymbol, common.QuotaDisplayDecimals, common.QuotaDisplayExchangeRate
+	savedOptions := common.OptionMap
+	common.OptionMap = map[string]string{}
+	t.Cleanup(func() {
+		common.QuotaDisplayCurrencySymbol, common.QuotaDisplayDecimals, common.QuotaDisplayExchangeRate = savedSymbol, savedDecimals, savedRate
+		common.OptionMap = savedOptions
+	})
+
+	for key, value := range map[string]string{
+		"QuotaDisplayCurrencySymbol": "¥",
+		"QuotaDisplayDecimals":       "2",
+		"QuotaDisplayExchangeRate":   "7.2",
+	} {
+		if err := updateOptionMap(key, value); err != nil {
+			t.Fatalf("updateOptionMap(%s) error = %v", key, err)
+		}
+	}
+	if common.QuotaDisplayCurrencySymbol != "¥" || common.QuotaDisplayDecimals != 2 || common.QuotaDisplayExchangeRate != 7.2 {
+		t.Errorf("quota display = %q, %d, %v, want ¥, 2, 7.2",
+			common.QuotaDisplayCurrencySymbol, common.QuotaDisplayDecimals, common.QuotaDisplayExchangeRate)
+	}
+}
@@ -46,6 +46,18 @@ func GetPricing() []Pricing {
 	return pricingMap
 }
 
+// ReloadPricing reloads the ratio settings from the stored options and
+// rebuilds the pricing cache immediately.
+func ReloadPricing() []Pricing {
+	ratio_setting.ReloadRatioSettings(loadOptionsFromDatabase)
+	updatePricingLock.Lock()
+	defer updatePricingLock.Unlock()
+	modelSupportEndpointsLock.Lock()
+	defer modelSupportEndpointsLock.Unlock()
+	updatePricing()
+	return pricingMap
+}
+
 func GetModelSupportEndpointTypes(model string) []constant.EndpointType {
 	if model == "" {
 		return make([]constant.EndpointType, 0)
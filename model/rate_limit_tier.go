@@ -0,0 +1,115 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+	"sync"
+	"time"
+)
+
+// RateLimitTier 用户请求频率档位。用户未指定档位时，使用与其分组同名的档位
+type RateLimitTier struct {
+	Id   int    `json:"id"`
+	Name string `json:"name" gorm:"type:varchar(64);uniqueIndex"`
+	// 时间窗口内允许的最大请求数
+	MaxRequests int `json:"max_requests" gorm:"default:60"`
+	// 时间窗口（秒）
+	DurationSeconds int64  `json:"duration_seconds" gorm:"bigint;default:60"`
+	Remark          string `json:"remark" gorm:"type:varchar(255)"`
+	CreatedTime     int64  `json:"created_time" gorm:"bigint"`
+}
+
+var rateLimitTiers map[string]*RateLimitTier
+var rateLimitTierLock sync.RWMutex
+
+func (tier *RateLimitTier) validate() error {
+	if tier.Name == "" {
+		return errors.New("档位名称不能为空")
+	}
+	if tier.MaxRequests <= 0 || tier.DurationSeconds <= 0 {
+		return errors.New("请求数和时间窗口必须大于 0")
+	}
+	return nil
+}
+
+func GetAllRateLimitTiers() (tiers []*RateLimitTier, err error) {
+	err = DB.Order("id asc").Find(&tiers).Error
+	return tiers, err
+}
+
+func GetRateLimitTierById(id int) (*RateLimitTier, error) {
+	tier := RateLimitTier{Id: id}
+	err := DB.First(&tier, "id = ?", id).Error
+	return &tier, err
+}
+
+func (tier *RateLimitTier) Insert() error {
+	if err := tier.validate(); err != nil {
+		return err
+	}
+	tier.CreatedTime = common.GetTimestamp()
+	if err := DB.Create(tier).Error; err != nil {
+		return err
+	}
+	InitRateLimitTierCache()
+	return nil
+}
+
+func (tier *RateLimitTier) Update() error {
+	if err := tier.validate(); err != nil {
+		return err
+	}
+	err := DB.Model(tier).Select("name", "max_requests", "duration_seconds", "remark").Updates(tier).Error
+	if err != nil {
+		return err
+	}
+	InitRateLimitTierCache()
+	return nil
+}
+
+func (tier *RateLimitTier) Delete() error {
+	if err := DB.Delete(tier).Error; err != nil {
+		return err
+	}
+	InitRateLimitTierCache()
+	return nil
+}
+
+// InitRateLimitTierCache loads all tiers into memory. Tiers are changed
+// rarely, so they are reloaded on every change and periodically for other
+// nodes.
+func InitRateLimitTierCache() {
+	tiers, err := GetAllRateLimitTiers()
+	if err != nil {
+		common.SysError("failed to load rate limit tiers: " + err.Error())
+		return
+	}
+	newTiers := make(map[string]*RateLimitTier, len(tiers))
+	for _, tier := range tiers {
+		newTiers[tier.Name] = tier
+	}
+	rateLimitTierLock.Lock()
+	rateLimitTiers = newTiers
+	rateLimitTierLock.Unlock()
+}
+
+func SyncRateLimitTierCache(frequency int) {
+	for {
+		time.Sleep(time.Duration(frequency) * time.Second)
+		InitRateLimitTierCache()
+	}
+}
+
+// GetUserRateLimitTier returns the user's tier, falling back to the tier
+// named after the user's group.
+func GetUserRateLimitTier(tierName string, group string) (*RateLimitTier, bool) {
+	rateLimitTierLock.RLock()
+	defer rateLimitTierLock.RUnlock()
+	if tierName != "" {
+		if tier, ok := rateLimitTiers[tierName]; ok {
+			return tier, true
+		}
+	}
+	tier, ok := rateLimitTiers[group]
+	return tier, ok
+}
@@ -26,6 +26,8 @@ type Token struct {
 	AllowIps           *string        `json:"allow_ips" gorm:"default:''"`
 	UsedQuota          int            `json:"used_quota" gorm:"default:0"` // used quota
 	Group              string         `json:"group" gorm:"default:''"`
+	SkipPreConsume     bool           `json:"skip_pre_consume" gorm:"default:false"`     // 受信任的内部服务，跳过预扣费，仅管理员可设置
+	QuotaHeaderEnabled bool           `json:"quota_header_enabled" gorm:"default:false"` // 在响应中返回剩余额度，需管理员开启剩余额度响应头
 	DeletedAt          gorm.DeletedAt `gorm:"index"`
 }
 
@@ -48,7 +50,7 @@ func (token *Token) GetIpLimitsMap() map[string]any {
 	for _, ip := range ips {
 		ip = strings.TrimSpace(ip)
 		ip = strings.ReplaceAll(ip, ",", "")
-		if common.IsIP(ip) {
+		if common.IsIP(ip) || common.IsCIDR(ip) {
 			ipLimitsMap[ip] = true
 		}
 	}
@@ -184,7 +186,7 @@ func (token *Token) Update() (err error) {
 		}
 	}()
 	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota",
-		"model_limits_enabled", "model_limits", "allow_ips", "group").Updates(token).Error
+		"model_limits_enabled", "model_limits", "allow_ips", "group", "skip_pre_consume", "quota_header_enabled").Updates(token).Error
 	return err
 }
 
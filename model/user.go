@@ -43,6 +43,7 @@ type User struct {
 	LinuxDOId        string         `json:"linux_do_id" gorm:"column:linux_do_id;index"`
 	Setting          string         `json:"setting" gorm:"type:text;column:setting"`
 	Remark           string         `json:"remark,omitempty" gorm:"type:varchar(255)" validate:"max=255"`
+	RateLimitTier    string         `json:"rate_limit_tier" gorm:"type:varchar(64);column:rate_limit_tier"` // 请求频率档位，为空时使用与分组同名的档位
 }
 
 func (user *User) ToBaseUser() *UserBase {
@@ -54,6 +55,8 @@ func (user *User) ToBaseUser() *UserBase {
 		Username: user.Username,
 		Setting:  user.Setting,
 		Email:    user.Email,
+
+		RateLimitTier: user.RateLimitTier,
 	}
 	return cache
 }
@@ -234,6 +237,20 @@ func GetUserById(id int, selectAll bool) (*User, error) {
 	return &user, err
 }
 
+// GetUserRoleUnscoped returns the role of a user, soft-deleted users
+// included; found is false once the user has been removed entirely.
+func GetUserRoleUnscoped(id int) (role int, found bool, err error) {
+	var user User
+	err = DB.Unscoped().Select("id", "role").First(&user, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return user.Role, true, nil
+}
+
 func GetUserIdByAffCode(affCode string) (int, error) {
 	if affCode == "" {
 		return 0, errors.New("affCode 为空！")
@@ -373,6 +390,8 @@ func (user *User) Edit(updatePassword bool) error {
 		"group":        newUser.Group,
 		"quota":        newUser.Quota,
 		"remark":       newUser.Remark,
+
+		"rate_limit_tier": newUser.RateLimitTier,
 	}
 	if updatePassword {
 		updates["password"] = newUser.Password
@@ -711,6 +730,83 @@ func decreaseUserQuota(id int, quota int) (err error) {
 	return err
 }
 
+// ErrInsufficientUserQuota 原子扣减时用户额度不足
+var ErrInsufficientUserQuota = errors.New("user quota is not enough")
+
+// DecreaseUserQuotaIfEnough atomically decreases the user's quota only when
+// at least quota remains, returning ErrInsufficientUserQuota otherwise. The
+// database row is the source of truth, except with batch updates enabled,
+// where pending deltas live in memory: the Redis cache is checked when
+// available, otherwise the user's pending delta is written together with
+// the conditional decrease.
+func DecreaseUserQuotaIfEnough(id int, quota int) error {
+	if quota < 0 {
+		return errors.New("quota 不能为负数！")
+	}
+	if common.BatchUpdateEnabled && common.RedisEnabled {
+		ok, err := cacheDecrUserQuotaIfEnough(id, int64(quota))
+		if err == nil {
+			if !ok {
+				return ErrInsufficientUserQuota
+			}
+			addNewRecord(BatchUpdateTypeUserQuota, id, -quota)
+			return nil
+		}
+		// 缓存不存在时回退到数据库条件更新
+	}
+	var err error
+	if common.BatchUpdateEnabled {
+		err = decreaseUserQuotaWithPending(id, quota)
+	} else {
+		err = decreaseUserQuotaIfEnough(id, quota, 0)
+	}
+	if err != nil {
+		return err
+	}
+	gopool.Go(func() {
+		if err := cacheDecrUserQuota(id, int64(quota)); err != nil {
+			common.SysError("failed to decrease user quota: " + err.Error())
+		}
+	})
+	return nil
+}
+
+// decreaseUserQuotaWithPending applies the user's pending batched quota delta
+// and the conditional decrease in one statement. Holding the user's quota
+// lock keeps a running flush from having taken the delta out of the store
+// without having written it yet. If the decrease is rejected the delta goes
+// back to the store.
+func decreaseUserQuotaWithPending(id int, quota int) error {
+	lock := userQuotaLock(id)
+	lock.Lock()
+	defer lock.Unlock()
+	batchUpdateLocks[BatchUpdateTypeUserQuota].Lock()
+	pending := batchUpdateStores[BatchUpdateTypeUserQuota][id]
+	delete(batchUpdateStores[BatchUpdateTypeUserQuota], id)
+	batchUpdateLocks[BatchUpdateTypeUserQuota].Unlock()
+	if err := decreaseUserQuotaIfEnough(id, quota, pending); err != nil {
+		if pending != 0 {
+			addNewRecord(BatchUpdateTypeUserQuota, id, pending)
+		}
+		return err
+	}
+	return nil
+}
+
+// decreaseUserQuotaIfEnough adds pending and subtracts quota when the result
+// stays non-negative.
+func decreaseUserQuotaIfEnough(id int, quota int, pending int) error {
+	result := DB.Model(&User{}).Where("id = ? AND quota + ? >= ?", id, pending, quota).
+		Update("quota", gorm.Expr("quota + ?", pending-quota))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInsufficientUserQuota
+	}
+	return nil
+}
+
 func DeltaUpdateUserQuota(id int, delta int) (err error) {
 	if delta == 0 {
 		return nil
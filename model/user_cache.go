@@ -1,6 +1,7 @@
 package model
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"one-api/common"
@@ -11,6 +12,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/go-redis/redis/v8"
 )
 
 // UserBase struct remains the same as it represents the cached data structure
@@ -22,6 +24,8 @@ type UserBase struct {
 	Status   int    `json:"status"`
 	Username string `json:"username"`
 	Setting  string `json:"setting"`
+
+	RateLimitTier string `json:"rate_limit_tier"`
 }
 
 func (user *UserBase) WriteContext(c *gin.Context) {
@@ -31,6 +35,7 @@ func (user *UserBase) WriteContext(c *gin.Context) {
 	common.SetContextKey(c, constant.ContextKeyUserEmail, user.Email)
 	common.SetContextKey(c, constant.ContextKeyUserName, user.Username)
 	common.SetContextKey(c, constant.ContextKeyUserSetting, user.GetSetting())
+	common.SetContextKey(c, constant.ContextKeyUserRateLimitTier, user.RateLimitTier)
 }
 
 func (user *UserBase) GetSetting() dto.UserSetting {
@@ -107,6 +112,8 @@ func GetUserCache(userId int) (userCache *UserBase, err error) {
 		Username: user.Username,
 		Setting:  user.Setting,
 		Email:    user.Email,
+
+		RateLimitTier: user.RateLimitTier,
 	}
 
 	return userCache, nil
@@ -137,6 +144,35 @@ func cacheDecrUserQuota(userId int, delta int64) error {
 	return cacheIncrUserQuota(userId, -delta)
 }
 
+// 额度充足时扣减并返回 1，不足返回 0，缓存不存在返回 -1
+var decrQuotaIfEnoughScript = redis.NewScript(`
+local quota = redis.call("HGET", KEYS[1], "Quota")
+if not quota then
+	return -1
+end
+if tonumber(quota) < tonumber(ARGV[1]) then
+	return 0
+end
+redis.call("HINCRBY", KEYS[1], "Quota", -tonumber(ARGV[1]))
+return 1
+`)
+
+// cacheDecrUserQuotaIfEnough atomically checks and decreases the cached
+// quota. It returns an error when the user is not cached.
+func cacheDecrUserQuotaIfEnough(userId int, delta int64) (bool, error) {
+	if !common.RedisEnabled {
+		return false, fmt.Errorf("redis is not enabled")
+	}
+	result, err := decrQuotaIfEnoughScript.Run(context.Background(), common.RDB, []string{getUserCacheKey(userId)}, delta).Int()
+	if err != nil {
+		return false, err
+	}
+	if result < 0 {
+		return false, fmt.Errorf("user %d quota is not cached", userId)
+	}
+	return result == 1, nil
+}
+
 // Helper functions to get individual fields if needed
 func getUserGroupCache(userId int) (string, error) {
 	cache, err := GetUserCache(userId)
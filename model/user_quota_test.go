@@ -0,0 +1,171 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupUserQuotaTestDB(t *testing.T, quota int) *User {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sqlite handle: %v", err)
+	}
+	// 内存数据库每个连接相互独立，限制为单连接以共享同一份数据
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&User{}); err != nil {
+		t.Fatalf("migrate users: %v", err)
+	}
+	mainDB := DB
+	batchUpdateEnabled, batchUpdateSize := common.BatchUpdateEnabled, common.BatchUpdateSize
+	DB = db
+	t.Cleanup(func() {
+		DB = mainDB
+		common.BatchUpdateEnabled, common.BatchUpdateSize = batchUpdateEnabled, batchUpdateSize
+		batchUpdateLocks[BatchUpdateTypeUserQuota].Lock()
+		batchUpdateStores[BatchUpdateTypeUserQuota] = make(map[int]int)
+		batchUpdateLocks[BatchUpdateTypeUserQuota].Unlock()
+	})
+	user := &User{Username: "quota-test", Password: "password", Quota: quota}
+	if err := DB.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+func userQuota(t *testing.T, id int) int {
+	var quota int
+	if err := DB.Model(&User{}).Where("id = ?", id).Select("quota").Find(&quota).Error; err != nil {
+		t.Fatalf("read quota: %v", err)
+	}
+	return quota
+}
+
+// preConsumeConcurrently fires n concurrent pre-consumes of cost each and
+// returns how many were admitted.
+func preConsumeConcurrently(t *testing.T, id int, n int, cost int) int {
+	var admitted atomic.Int64
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			err := DecreaseUserQuotaIfEnough(id, cost)
+			switch {
+			case err == nil:
+				admitted.Add(1)
+			case !errors.Is(err, ErrInsufficientUserQuota):
+				t.Errorf("DecreaseUserQuotaIfEnough: %v", err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	return int(admitted.Load())
+}
+
+func TestDecreaseUserQuotaIfEnoughConcurrent(t *testing.T) {
+	user := setupUserQuotaTestDB(t, 95)
+	common.BatchUpdateEnabled = false
+
+	if admitted := preConsumeConcurrently(t, user.Id, 50, 10); admitted != 9 {
+		t.Errorf("admitted %d pre-consumes of 10 against 95, want 9", admitted)
+	}
+	if quota := userQuota(t, user.Id); quota != 5 {
+		t.Errorf("quota after pre-consumes = %d, want 5", quota)
+	}
+}
+
+func TestDecreaseUserQuotaIfEnoughWithPendingBatch(t *testing.T) {
+	user := setupUserQuotaTestDB(t, 100)
+	common.BatchUpdateEnabled = true
+	common.BatchUpdateSize = 0
+	// 已结算但尚未写入数据库的消费，数据库中的额度仍为 100
+	addNewRecord(BatchUpdateTypeUserQuota, user.Id, -65)
+
+	if admitted := preConsumeConcurrently(t, user.Id, 50, 10); admitted != 3 {
+		t.Errorf("admitted %d pre-consumes of 10 against 35 remaining, want 3", admitted)
+	}
+	FlushBatchUpdates()
+	if quota := userQuota(t, user.Id); quota != 5 {
+		t.Errorf("quota after flush = %d, want 5", quota)
+	}
+}
+
+func TestDecreaseUserQuotaWithPendingKeepsDeltaOnReject(t *testing.T) {
+	user := setupUserQuotaTestDB(t, 100)
+	common.BatchUpdateEnabled = true
+	common.BatchUpdateSize = 0
+	addNewRecord(BatchUpdateTypeUserQuota, user.Id, -95)
+
+	if err := DecreaseUserQuotaIfEnough(user.Id, 10); !errors.Is(err, ErrInsufficientUserQuota) {
+		t.Fatalf("DecreaseUserQuotaIfEnough() error = %v, want ErrInsufficientUserQuota", err)
+	}
+	batchUpdateLocks[BatchUpdateTypeUserQuota].Lock()
+	pending := batchUpdateStores[BatchUpdateTypeUserQuota][user.Id]
+	batchUpdateLocks[BatchUpdateTypeUserQuota].Unlock()
+	if pending != -95 {
+		t.Errorf("pending delta after the rejected decrease = %d, want -95", pending)
+	}
+	if quota := userQuota(t, user.Id); quota != 100 {
+		t.Errorf("quota after the rejected decrease = %d, want 100", quota)
+	}
+}
+
+func TestDecreaseUserQuotaWithPendingDuringFlushes(t *testing.T) {
+	user := setupUserQuotaTestDB(t, 1000)
+	common.BatchUpdateEnabled = true
+	common.BatchUpdateSize = 0
+
+	stop := make(chan struct{})
+	flusherDone := make(chan struct{})
+	go func() {
+		defer close(flusherDone)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				FlushBatchUpdates()
+			}
+		}
+	}()
+	// 每次扣减 10 并结算返还 5，实际每次消费 5
+	var wg sync.WaitGroup
+	var admitted atomic.Int64
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if err := DecreaseUserQuotaIfEnough(user.Id, 10); err != nil {
+					if !errors.Is(err, ErrInsufficientUserQuota) {
+						t.Errorf("DecreaseUserQuotaIfEnough: %v", err)
+					}
+					continue
+				}
+				admitted.Add(1)
+				addNewRecord(BatchUpdateTypeUserQuota, user.Id, 5)
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	<-flusherDone
+	FlushBatchUpdates()
+
+	if quota, want := userQuota(t, user.Id), 1000-5*int(admitted.Load()); quota != want || quota < 0 {
+		t.Errorf("quota = %d after %d pre-consumes, want %d with no delta lost or applied twice", quota, admitted.Load(), want)
+	}
+}
@@ -2,8 +2,11 @@ package model
 
 import (
 	"errors"
+	"maps"
 	"one-api/common"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bytedance/gopkg/util/gopool"
@@ -29,26 +32,61 @@ func init() {
 	}
 }
 
+// 同一时间只进行一次批量写入，保证前一批次完全写入后才写入下一批次
+var batchFlushLock sync.Mutex
+
+// 批次写满时已安排的提前写入，避免每条新记录都再启动一个写入协程
+var batchFlushPending atomic.Bool
+
+// 按用户分片的额度写入锁：批量写入在持有用户锁时才取出并写入该用户的额度增量，
+// 条件扣减持有同一把锁，因此不会遇到已从暂存中取出但尚未写入数据库的增量
+var userQuotaLocks [64]sync.Mutex
+
+func userQuotaLock(id int) *sync.Mutex {
+	i := id % len(userQuotaLocks)
+	if i < 0 {
+		i += len(userQuotaLocks)
+	}
+	return &userQuotaLocks[i]
+}
+
 func InitBatchUpdater() {
 	gopool.Go(func() {
 		for {
 			time.Sleep(time.Duration(common.BatchUpdateInterval) * time.Second)
-			batchUpdate()
+			FlushBatchUpdates()
 		}
 	})
 }
 
 func addNewRecord(type_ int, id int, value int) {
 	batchUpdateLocks[type_].Lock()
-	defer batchUpdateLocks[type_].Unlock()
 	if _, ok := batchUpdateStores[type_][id]; !ok {
 		batchUpdateStores[type_][id] = value
 	} else {
 		batchUpdateStores[type_][id] += value
 	}
+	full := common.BatchUpdateSize > 0 && len(batchUpdateStores[type_]) >= common.BatchUpdateSize
+	batchUpdateLocks[type_].Unlock()
+	if full && batchFlushPending.CompareAndSwap(false, true) {
+		gopool.Go(func() {
+			defer batchFlushPending.Store(false)
+			FlushBatchUpdates()
+		})
+	}
 }
 
-func batchUpdate() {
+// FlushBatchUpdates writes all pending batched updates. Updates for the same
+// id are summed into one delta, so an increase and a decrease recorded in
+// the same batch never hit the database separately. Flushes never overlap,
+// and within a flush each id is written by exactly one worker, in ascending
+// id order. User quota deltas are taken out of the store one user at a time
+// under the user's quota lock. It must also be called on shutdown so that no
+// updates are lost.
+func FlushBatchUpdates() {
+	batchFlushLock.Lock()
+	defer batchFlushLock.Unlock()
+
 	// check if there's any data to update
 	hasData := false
 	for i := 0; i < BatchUpdateTypeCount; i++ {
@@ -69,33 +107,84 @@ func batchUpdate() {
 	for i := 0; i < BatchUpdateTypeCount; i++ {
 		batchUpdateLocks[i].Lock()
 		store := batchUpdateStores[i]
-		batchUpdateStores[i] = make(map[int]int)
+		if i == BatchUpdateTypeUserQuota {
+			// 只取出 id，增量在写入时按用户加锁取出，见 flushUserQuota
+			store = maps.Clone(store)
+		} else {
+			batchUpdateStores[i] = make(map[int]int)
+		}
 		batchUpdateLocks[i].Unlock()
-		// TODO: maybe we can combine updates with same key?
-		for key, value := range store {
-			switch i {
-			case BatchUpdateTypeUserQuota:
-				err := increaseUserQuota(key, value)
-				if err != nil {
-					common.SysError("failed to batch update user quota: " + err.Error())
-				}
-			case BatchUpdateTypeTokenQuota:
-				err := increaseTokenQuota(key, value)
-				if err != nil {
-					common.SysError("failed to batch update token quota: " + err.Error())
-				}
-			case BatchUpdateTypeUsedQuota:
-				updateUserUsedQuota(key, value)
-			case BatchUpdateTypeRequestCount:
-				updateUserRequestCount(key, value)
-			case BatchUpdateTypeChannelUsedQuota:
-				updateChannelUsedQuota(key, value)
+		if len(store) == 0 {
+			continue
+		}
+		workers := max(common.BatchUpdateWorkers, 1)
+		shards := make([][]int, workers)
+		for key := range store {
+			shard := key % workers
+			if shard < 0 {
+				shard += workers
 			}
+			shards[shard] = append(shards[shard], key)
 		}
+		var wg sync.WaitGroup
+		for _, keys := range shards {
+			if len(keys) == 0 {
+				continue
+			}
+			sort.Ints(keys)
+			wg.Add(1)
+			go func(type_ int, keys []int) {
+				defer wg.Done()
+				for _, key := range keys {
+					if type_ == BatchUpdateTypeUserQuota {
+						flushUserQuota(key)
+						continue
+					}
+					applyBatchUpdate(type_, key, store[key])
+				}
+			}(i, keys)
+		}
+		wg.Wait()
 	}
 	common.SysLog("batch update finished")
 }
 
+// flushUserQuota takes the user's pending quota delta out of the store and
+// writes it while holding the user's quota lock.
+func flushUserQuota(id int) {
+	lock := userQuotaLock(id)
+	lock.Lock()
+	defer lock.Unlock()
+	batchUpdateLocks[BatchUpdateTypeUserQuota].Lock()
+	delta, ok := batchUpdateStores[BatchUpdateTypeUserQuota][id]
+	delete(batchUpdateStores[BatchUpdateTypeUserQuota], id)
+	batchUpdateLocks[BatchUpdateTypeUserQuota].Unlock()
+	if ok {
+		applyBatchUpdate(BatchUpdateTypeUserQuota, id, delta)
+	}
+}
+
+func applyBatchUpdate(type_ int, key int, value int) {
+	switch type_ {
+	case BatchUpdateTypeUserQuota:
+		err := increaseUserQuota(key, value)
+		if err != nil {
+			common.SysError("failed to batch update user quota: " + err.Error())
+		}
+	case BatchUpdateTypeTokenQuota:
+		err := increaseTokenQuota(key, value)
+		if err != nil {
+			common.SysError("failed to batch update token quota: " + err.Error())
+		}
+	case BatchUpdateTypeUsedQuota:
+		updateUserUsedQuota(key, value)
+	case BatchUpdateTypeRequestCount:
+		updateUserRequestCount(key, value)
+	case BatchUpdateTypeChannelUsedQuota:
+		updateChannelUsedQuota(key, value)
+	}
+}
+
 func RecordExist(err error) (bool, error) {
 	if err == nil {
 		return true, nil
@@ -0,0 +1,218 @@
+package model
+
+import (
+	"fmt"
+	"one-api/common"
+	"sync"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestAddNewRecordSkipsFlushWhilePending(t *testing.T) {
+	batchUpdateSize := common.BatchUpdateSize
+	common.BatchUpdateSize = 1
+	batchFlushPending.Store(true)
+	t.Cleanup(func() {
+		common.BatchUpdateSize = batchUpdateSize
+		batchFlushPending.Store(false)
+		batchUpdateLocks[BatchUpdateTypeRequestCount].Lock()
+		batchUpdateStores[BatchUpdateTypeRequestCount] = make(map[int]int)
+		batchUpdateLocks[BatchUpdateTypeRequestCount].Unlock()
+	})
+
+	for i := 0; i < 3; i++ {
+		addNewRecord(BatchUpdateTypeRequestCount, 1, 1)
+	}
+	batchUpdateLocks[BatchUpdateTypeRequestCount].Lock()
+	got := batchUpdateStores[BatchUpdateTypeRequestCount][1]
+	batchUpdateLocks[BatchUpdateTypeRequestCount].Unlock()
+	if got != 3 {
+		t.Fatalf("pending count = %d, want 3 records kept until the scheduled flush runs", got)
+	}
+}
+
+func setupBatchUpdateTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sqlite handle: %v", err)
+	}
+	// 内存数据库每个连接相互独立，限制为单连接以共享同一份数据
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&User{}, &Token{}, &Channel{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	mainDB := DB
+	batchUpdateSize, batchUpdateWorkers := common.BatchUpdateSize, common.BatchUpdateWorkers
+	DB = db
+	common.BatchUpdateSize = 0
+	t.Cleanup(func() {
+		DB = mainDB
+		common.BatchUpdateSize, common.BatchUpdateWorkers = batchUpdateSize, batchUpdateWorkers
+		for i := 0; i < BatchUpdateTypeCount; i++ {
+			batchUpdateLocks[i].Lock()
+			batchUpdateStores[i] = make(map[int]int)
+			batchUpdateLocks[i].Unlock()
+		}
+	})
+	return db
+}
+
+func createBatchTestUsers(t *testing.T, n int, quota int) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		user := &User{Id: i, Username: fmt.Sprintf("batch-%d", i), Password: "password", AffCode: fmt.Sprintf("batch-%d", i), Quota: quota}
+		if err := DB.Create(user).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+}
+
+func TestFlushBatchUpdatesAppliesUserUpdatesInOrder(t *testing.T) {
+	db := setupBatchUpdateTestDB(t)
+	common.BatchUpdateWorkers = 3
+	createBatchTestUsers(t, 9, 100)
+
+	// 记录每次写入用户额度的用户 id，按写入顺序
+	var writesLock sync.Mutex
+	var writes []int
+	err := db.Callback().Update().After("gorm:update").Register("test:record_quota_writes", func(tx *gorm.DB) {
+		if tx.Statement.Table == "users" && len(tx.Statement.Vars) > 0 {
+			if id, ok := tx.Statement.Vars[len(tx.Statement.Vars)-1].(int); ok {
+				writesLock.Lock()
+				writes = append(writes, id)
+				writesLock.Unlock()
+			}
+		}
+	})
+	if err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+
+	for round := 0; round < 2; round++ {
+		writes = nil
+		for id := 9; id >= 1; id-- {
+			// 同一批次内的扣减与返还合并为一次写入
+			addNewRecord(BatchUpdateTypeUserQuota, id, -30)
+			addNewRecord(BatchUpdateTypeUserQuota, id, 10+id)
+		}
+		FlushBatchUpdates()
+
+		seen := make(map[int]int)
+		lastInShard := make(map[int]int)
+		for _, id := range writes {
+			seen[id]++
+			shard := id % common.BatchUpdateWorkers
+			if id < lastInShard[shard] {
+				t.Errorf("round %d: user %d written after user %d of the same worker, writes %v", round, id, lastInShard[shard], writes)
+			}
+			lastInShard[shard] = id
+		}
+		for id := 1; id <= 9; id++ {
+			if seen[id] != 1 {
+				t.Errorf("round %d: user %d written %d times, want once", round, id, seen[id])
+			}
+		}
+	}
+	for id := 1; id <= 9; id++ {
+		if quota := userQuota(t, id); quota != 100+2*(id-20) {
+			t.Errorf("user %d quota = %d, want %d", id, quota, 100+2*(id-20))
+		}
+	}
+}
+
+func TestFlushBatchUpdatesConcurrentWithRecords(t *testing.T) {
+	setupBatchUpdateTestDB(t)
+	common.BatchUpdateWorkers = 4
+	createBatchTestUsers(t, 8, 0)
+
+	stop := make(chan struct{})
+	flusherDone := make(chan struct{})
+	go func() {
+		defer close(flusherDone)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				FlushBatchUpdates()
+			}
+		}
+	}()
+	var wg sync.WaitGroup
+	for id := 1; id <= 8; id++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				addNewRecord(BatchUpdateTypeUserQuota, id, 2)
+				addNewRecord(BatchUpdateTypeUserQuota, id, -1)
+			}
+		}(id)
+	}
+	wg.Wait()
+	close(stop)
+	<-flusherDone
+	FlushBatchUpdates()
+
+	for id := 1; id <= 8; id++ {
+		if quota := userQuota(t, id); quota != 200 {
+			t.Errorf("user %d quota = %d, want 200 with no update lost or applied twice", id, quota)
+		}
+	}
+}
+
+// 优雅退出时调用 FlushBatchUpdates，所有类型的待写入更新都必须落库
+func TestFlushBatchUpdatesOnShutdownWritesPendingBatches(t *testing.T) {
+	setupBatchUpdateTestDB(t)
+	createBatchTestUsers(t, 1, 100)
+	if err := DB.Create(&Token{Id: 1, UserId: 1, Key: "batch-token", RemainQuota: 50}).Error; err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+	if err := DB.Create(&Channel{Id: 1, Name: "batch-channel", Key: "sk"}).Error; err != nil {
+		t.Fatalf("create channel: %v", err)
+	}
+
+	addNewRecord(BatchUpdateTypeUserQuota, 1, -40)
+	addNewRecord(BatchUpdateTypeTokenQuota, 1, -20)
+	addNewRecord(BatchUpdateTypeUsedQuota, 1, 40)
+	addNewRecord(BatchUpdateTypeRequestCount, 1, 2)
+	addNewRecord(BatchUpdateTypeChannelUsedQuota, 1, 40)
+	FlushBatchUpdates()
+
+	var user User
+	if err := DB.First(&user, 1).Error; err != nil {
+		t.Fatalf("read user: %v", err)
+	}
+	if user.Quota != 60 || user.UsedQuota != 40 || user.RequestCount != 2 {
+		t.Errorf("user quota/used/requests = %d/%d/%d, want 60/40/2", user.Quota, user.UsedQuota, user.RequestCount)
+	}
+	var token Token
+	if err := DB.First(&token, 1).Error; err != nil {
+		t.Fatalf("read token: %v", err)
+	}
+	if token.RemainQuota != 30 || token.UsedQuota != 20 {
+		t.Errorf("token remain/used = %d/%d, want 30/20", token.RemainQuota, token.UsedQuota)
+	}
+	var channel Channel
+	if err := DB.First(&channel, 1).Error; err != nil {
+		t.Fatalf("read channel: %v", err)
+	}
+	if channel.UsedQuota != 40 {
+		t.Errorf("channel used quota = %d, want 40", channel.UsedQuota)
+	}
+	for i := 0; i < BatchUpdateTypeCount; i++ {
+		batchUpdateLocks[i].Lock()
+		pending := len(batchUpdateStores[i])
+		batchUpdateLocks[i].Unlock()
+		if pending != 0 {
+			t.Errorf("batch type %d still has %d pending ids after the flush", i, pending)
+		}
+	}
+}
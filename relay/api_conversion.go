@@ -0,0 +1,67 @@
+package relay
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// shouldRelayChatViaResponses reports whether a chat completions request has
+// to be converted because the selected channel only supports the responses API.
+func shouldRelayChatViaResponses(info *relaycommon.RelayInfo) bool {
+	return info.RelayMode == relayconstant.RelayModeChatCompletions && info.RelayFormat == relaycommon.RelayFormatOpenAI &&
+		info.ApiType == constant.APITypeOpenAI && info.ChannelSetting.SupportedApi == dto.SupportedApiResponses
+}
+
+// shouldRelayResponsesViaChat reports whether a responses request has to be
+// converted because the selected channel only supports chat completions.
+func shouldRelayResponsesViaChat(info *relaycommon.RelayInfo) bool {
+	return info.ApiType == constant.APITypeOpenAI && info.ChannelSetting.SupportedApi == dto.SupportedApiChatCompletions
+}
+
+// relayChatViaResponses sends a chat completions request to the responses
+// API of the channel; the OpenAI adaptor converts the response back. The
+// chat request policies run before the conversion.
+func relayChatViaResponses(c *gin.Context, chatInfo *relaycommon.RelayInfo, textRequest *dto.GeneralOpenAIRequest) *dto.OpenAIErrorWithStatusCode {
+	if openaiErr := checkTextRequestPolicy(c, chatInfo, textRequest); openaiErr != nil {
+		return openaiErr
+	}
+	responsesRequest, err := service.ChatCompletionsToResponsesRequest(textRequest)
+	if err != nil {
+		common.LogError(c, fmt.Sprintf("convert chat completions request to responses failed: %s", err.Error()))
+		return service.OpenAIErrorWrapperLocal(err, "unsupported_api_conversion", http.StatusBadRequest)
+	}
+	relayInfo := relaycommon.GenRelayInfoResponses(c, responsesRequest)
+	relayInfo.ApiConversion = relaycommon.ApiConversionChatToResponses
+	relayInfo.RequestURLPath = "/v1/responses"
+	relayInfo.ShouldIncludeUsage = textRequest.StreamOptions != nil && textRequest.StreamOptions.IncludeUsage
+	return relayResponsesRequest(c, relayInfo, responsesRequest)
+}
+
+// relayResponsesViaChat sends a responses request to the chat completions API
+// of the channel; the OpenAI adaptor converts the response back. The
+// converted request goes through the same validation as native chat requests.
+func relayResponsesViaChat(c *gin.Context, responsesRequest *dto.OpenAIResponsesRequest) *dto.OpenAIErrorWithStatusCode {
+	textRequest, err := service.ResponsesToChatCompletionsRequest(responsesRequest)
+	if err != nil {
+		common.LogError(c, fmt.Sprintf("convert responses request to chat completions failed: %s", err.Error()))
+		return service.OpenAIErrorWrapperLocal(err, "unsupported_api_conversion", http.StatusBadRequest)
+	}
+	relayInfo := relaycommon.GenRelayInfo(c)
+	relayInfo.RelayMode = relayconstant.RelayModeChatCompletions
+	relayInfo.RelayFormat = relaycommon.RelayFormatOpenAIResponses
+	relayInfo.ApiConversion = relaycommon.ApiConversionResponsesToChat
+	relayInfo.RequestURLPath = "/v1/chat/completions"
+	if err := validateTextRequest(c, relayInfo, textRequest); err != nil {
+		common.LogError(c, fmt.Sprintf("validateTextRequest failed: %s", err.Error()))
+		return service.OpenAIErrorWrapperLocal(err, "invalid_text_request", validationErrorStatus(err))
+	}
+	return relayTextRequest(c, relayInfo, textRequest)
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"math"
 	"net/http"
 	"one-api/common"
 	"one-api/dto"
@@ -12,9 +13,20 @@ import (
 	"one-api/relay/helper"
 	"one-api/service"
 	"one-api/setting"
+	"one-api/setting/model_setting"
 	"strings"
+	"unicode/utf8"
 )
 
+var speechResponseFormats = map[string]bool{
+	"mp3":  true,
+	"opus": true,
+	"aac":  true,
+	"flac": true,
+	"wav":  true,
+	"pcm":  true,
+}
+
 func getAndValidAudioRequest(c *gin.Context, info *relaycommon.RelayInfo) (*dto.AudioRequest, error) {
 	audioRequest := &dto.AudioRequest{}
 	err := common.UnmarshalBodyReusable(c, audioRequest)
@@ -26,6 +38,18 @@ func getAndValidAudioRequest(c *gin.Context, info *relaycommon.RelayInfo) (*dto.
 		if audioRequest.Model == "" {
 			return nil, errors.New("model is required")
 		}
+		if audioRequest.Input == "" {
+			return nil, errors.New("input is required")
+		}
+		if audioRequest.Voice == "" {
+			return nil, errors.New("voice is required")
+		}
+		if audioRequest.ResponseFormat != "" && !speechResponseFormats[audioRequest.ResponseFormat] {
+			return nil, fmt.Errorf("response_format must be one of mp3, opus, aac, flac, wav or pcm, got %s", audioRequest.ResponseFormat)
+		}
+		if audioRequest.Speed != 0 && (audioRequest.Speed < 0.25 || audioRequest.Speed > 4.0) {
+			return nil, errors.New("speed must be between 0.25 and 4.0")
+		}
 		if setting.ShouldCheckPromptSensitive() {
 			words, err := service.CheckSensitiveInput(audioRequest.Input)
 			if err != nil {
@@ -76,6 +100,8 @@ func AudioHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 		return service.OpenAIErrorWrapperLocal(err, "model_price_error", http.StatusInternalServerError)
 	}
 
+	logContent, _ := applySpeechCharPrice(c, relayInfo, audioRequest.Input, &priceData)
+
 	preConsumedQuota, userQuota, openaiErr := preConsumeQuota(c, priceData.ShouldPreConsumedQuota, relayInfo)
 	if openaiErr != nil {
 		return openaiErr
@@ -126,7 +152,56 @@ func AudioHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 		return openaiErr
 	}
 
-	postConsumeQuota(c, relayInfo, usage.(*dto.Usage), preConsumedQuota, userQuota, priceData, "")
+	if durationLogContent, ok := applyAudioDurationPrice(c, relayInfo, &priceData); ok {
+		logContent = durationLogContent
+	}
+	postConsumeQuota(c, relayInfo, usage.(*dto.Usage), preConsumedQuota, userQuota, priceData, logContent)
 
 	return nil
 }
+
+// applySpeechCharPrice switches speech requests of models with a
+// per-character price to character billing, including the pre-consumed
+// quota. It returns the log content and false when the model keeps token
+// billing.
+func applySpeechCharPrice(c *gin.Context, info *relaycommon.RelayInfo, input string, priceData *helper.PriceData) (string, bool) {
+	if info.RelayMode != relayconstant.RelayModeAudioSpeech {
+		return "", false
+	}
+	pricePerMillion, ok := model_setting.GetSpeechPricePerMillionChars(info.OriginModelName)
+	if !ok {
+		return "", false
+	}
+	// 按输入字符数计费
+	chars := utf8.RuneCountInString(input)
+	priceData.UsePrice = true
+	priceData.ModelPrice = pricePerMillion * float64(chars) / 1000000
+	priceData.ShouldPreConsumedQuota = int(priceData.ModelPrice * common.QuotaPerUnit * priceData.GroupRatioInfo.GroupRatio)
+	c.Set("audio_price_breakdown", map[string]interface{}{
+		"characters":              chars,
+		"price_per_million_chars": pricePerMillion,
+	})
+	return fmt.Sprintf("输入 %d 字符, 每百万字符 $%.4f", chars, pricePerMillion), true
+}
+
+// applyAudioDurationPrice switches transcription and translation requests of
+// models with a per-minute price to duration billing. It returns the log
+// content and false when the model keeps token billing.
+func applyAudioDurationPrice(c *gin.Context, info *relaycommon.RelayInfo, priceData *helper.PriceData) (string, bool) {
+	if info.RelayMode == relayconstant.RelayModeAudioSpeech || info.AudioDurationSeconds <= 0 {
+		return "", false
+	}
+	pricePerMinute, ok := model_setting.GetAudioPricePerMinute(info.OriginModelName)
+	if !ok {
+		return "", false
+	}
+	// 按音频时长计费，不足 1 秒按 1 秒计
+	minutes := math.Ceil(info.AudioDurationSeconds) / 60
+	priceData.UsePrice = true
+	priceData.ModelPrice = pricePerMinute * minutes
+	c.Set("audio_price_breakdown", map[string]interface{}{
+		"duration_seconds": info.AudioDurationSeconds,
+		"price_per_minute": pricePerMinute,
+	})
+	return fmt.Sprintf("音频时长 %.2f 秒, 每分钟 $%.4f", info.AudioDurationSeconds, pricePerMinute), true
+}
@@ -0,0 +1,118 @@
+package relay
+
+import (
+	"math"
+	"net/http/httptest"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/relay/helper"
+	"one-api/setting/model_setting"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setAudioPrices(t *testing.T, perMinute map[string]float64, perMillionChars map[string]float64) {
+	t.Helper()
+	settings := model_setting.GetAudioSettings()
+	saved := *settings
+	settings.PricePerMinute, settings.SpeechPricePerMillionChars = perMinute, perMillionChars
+	t.Cleanup(func() { *settings = saved })
+}
+
+func TestApplyAudioDurationPrice(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setAudioPrices(t, map[string]float64{"whisper-1": 0.006}, nil)
+
+	tests := []struct {
+		name      string
+		model     string
+		relayMode int
+		duration  float64
+		price     float64
+		applied   bool
+	}{
+		{name: "whole minutes", model: "whisper-1", relayMode: relayconstant.RelayModeAudioTranscription, duration: 120, price: 0.012, applied: true},
+		{name: "partial seconds round up", model: "whisper-1", relayMode: relayconstant.RelayModeAudioTranslation, duration: 29.2, price: 0.003, applied: true},
+		{name: "model without per-minute price", model: "gpt-4o-transcribe", relayMode: relayconstant.RelayModeAudioTranscription, duration: 60},
+		{name: "unknown duration", model: "whisper-1", relayMode: relayconstant.RelayModeAudioTranscription},
+		{name: "speech is not billed by duration", model: "whisper-1", relayMode: relayconstant.RelayModeAudioSpeech, duration: 60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			info := &relaycommon.RelayInfo{OriginModelName: tt.model, RelayMode: tt.relayMode, AudioDurationSeconds: tt.duration}
+			priceData := helper.PriceData{ModelRatio: 15}
+
+			logContent, applied := applyAudioDurationPrice(c, info, &priceData)
+			if applied != tt.applied || priceData.UsePrice != tt.applied {
+				t.Fatalf("applied = %v, UsePrice = %v, want %v", applied, priceData.UsePrice, tt.applied)
+			}
+			_, hasBreakdown := c.Get("audio_price_breakdown")
+			if !tt.applied {
+				if logContent != "" || hasBreakdown || priceData.ModelPrice != 0 {
+					t.Errorf("token billing changed: log %q, breakdown %v, price %v", logContent, hasBreakdown, priceData.ModelPrice)
+				}
+				return
+			}
+			if math.Abs(priceData.ModelPrice-tt.price) > 1e-9 {
+				t.Errorf("ModelPrice = %v, want %v", priceData.ModelPrice, tt.price)
+			}
+			if logContent == "" || !hasBreakdown {
+				t.Errorf("log %q, breakdown %v, want both recorded", logContent, hasBreakdown)
+			}
+		})
+	}
+}
+
+func TestApplySpeechCharPrice(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setAudioPrices(t, nil, map[string]float64{"tts-1": 15})
+
+	tests := []struct {
+		name       string
+		model      string
+		relayMode  int
+		input      string
+		groupRatio float64
+		price      float64
+		preConsume int
+		applied    bool
+	}{
+		{name: "ascii input", model: "tts-1", relayMode: relayconstant.RelayModeAudioSpeech, input: "hello world",
+			groupRatio: 1, price: 15 * 11 / 1e6, preConsume: 82, applied: true},
+		// 按字符而非字节计数
+		{name: "multibyte characters count once", model: "tts-1", relayMode: relayconstant.RelayModeAudioSpeech, input: "你好世界",
+			groupRatio: 2, price: 15 * 4 / 1e6, preConsume: 60, applied: true},
+		{name: "model without per-character price", model: "gpt-4o-mini-tts", relayMode: relayconstant.RelayModeAudioSpeech, input: "hello"},
+		{name: "transcription is not billed by characters", model: "tts-1", relayMode: relayconstant.RelayModeAudioTranscription, input: "hello"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			info := &relaycommon.RelayInfo{OriginModelName: tt.model, RelayMode: tt.relayMode}
+			priceData := helper.PriceData{ModelRatio: 7.5, ShouldPreConsumedQuota: 1000,
+				GroupRatioInfo: helper.GroupRatioInfo{GroupRatio: tt.groupRatio}}
+
+			logContent, applied := applySpeechCharPrice(c, info, tt.input, &priceData)
+			if applied != tt.applied || priceData.UsePrice != tt.applied {
+				t.Fatalf("applied = %v, UsePrice = %v, want %v", applied, priceData.UsePrice, tt.applied)
+			}
+			if !tt.applied {
+				if logContent != "" || priceData.ShouldPreConsumedQuota != 1000 {
+					t.Errorf("token billing changed: log %q, pre-consume %d", logContent, priceData.ShouldPreConsumedQuota)
+				}
+				return
+			}
+			if math.Abs(priceData.ModelPrice-tt.price) > 1e-12 {
+				t.Errorf("ModelPrice = %v, want %v", priceData.ModelPrice, tt.price)
+			}
+			if priceData.ShouldPreConsumedQuota != tt.preConsume {
+				t.Errorf("pre-consumed quota = %d, want %d", priceData.ShouldPreConsumedQuota, tt.preConsume)
+			}
+			if breakdown, ok := c.Get("audio_price_breakdown"); !ok || breakdown.(map[string]interface{})["characters"] != len([]rune(tt.input)) {
+				t.Errorf("breakdown = %v, want the character count", breakdown)
+			}
+		})
+	}
+}
@@ -7,12 +7,17 @@ import (
 	"io"
 	"net/http"
 	common2 "one-api/common"
+	constant2 "one-api/constant"
+	"one-api/model"
 	"one-api/relay/common"
 	"one-api/relay/constant"
 	"one-api/relay/helper"
 	"one-api/service"
+	"one-api/setting/model_setting"
 	"one-api/setting/operation_setting"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/bytedance/gopkg/util/gopool"
@@ -34,6 +39,16 @@ func SetupApiRequestHeader(info *common.RelayInfo, c *gin.Context, req *http.Hea
 	}
 }
 
+// applyHeaderOverride sets the channel's custom headers after the adaptor
+// defaults so that channel values take precedence.
+func applyHeaderOverride(info *common.RelayInfo, header *http.Header) {
+	for key, value := range info.ChannelSetting.HeaderOverride {
+		value = strings.ReplaceAll(value, "{model}", info.UpstreamModelName)
+		value = strings.ReplaceAll(value, "{api_version}", info.ApiVersion)
+		header.Set(key, value)
+	}
+}
+
 func DoApiRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody io.Reader) (*http.Response, error) {
 	fullRequestURL, err := a.GetRequestURL(info)
 	if err != nil {
@@ -42,14 +57,25 @@ func DoApiRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody
 	if common2.DebugEnabled {
 		println("fullRequestURL:", fullRequestURL)
 	}
-	req, err := http.NewRequest(c.Request.Method, fullRequestURL, requestBody)
+	requestBody, err = transformRequestBody(info, requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("transform request body failed: %w", err)
+	}
+	// 绑定客户端连接的 context，客户端断开时取消上游请求
+	req, err := http.NewRequestWithContext(c.Request.Context(), c.Request.Method, fullRequestURL, requestBody)
 	if err != nil {
 		return nil, fmt.Errorf("new request failed: %w", err)
 	}
+	// 落盘的请求体以文件流转发，需要显式设置 Content-Length
+	if sized, ok := requestBody.(interface{ Size() int64 }); ok {
+		req.ContentLength = sized.Size()
+	}
 	err = a.SetupRequestHeader(c, &req.Header, info)
 	if err != nil {
 		return nil, fmt.Errorf("setup request header failed: %w", err)
 	}
+	applyHeaderOverride(info, &req.Header)
+	transformRequestHeader(info, req.Header)
 	resp, err := doRequest(c, req, info)
 	if err != nil {
 		return nil, fmt.Errorf("do request failed: %w", err)
@@ -65,7 +91,7 @@ func DoFormRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBod
 	if common2.DebugEnabled {
 		println("fullRequestURL:", fullRequestURL)
 	}
-	req, err := http.NewRequest(c.Request.Method, fullRequestURL, requestBody)
+	req, err := http.NewRequestWithContext(c.Request.Context(), c.Request.Method, fullRequestURL, requestBody)
 	if err != nil {
 		return nil, fmt.Errorf("new request failed: %w", err)
 	}
@@ -76,6 +102,8 @@ func DoFormRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBod
 	if err != nil {
 		return nil, fmt.Errorf("setup request header failed: %w", err)
 	}
+	applyHeaderOverride(info, &req.Header)
+	transformRequestHeader(info, req.Header)
 	resp, err := doRequest(c, req, info)
 	if err != nil {
 		return nil, fmt.Errorf("do request failed: %w", err)
@@ -93,6 +121,8 @@ func DoWssRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody
 	if err != nil {
 		return nil, fmt.Errorf("setup request header failed: %w", err)
 	}
+	applyHeaderOverride(info, &targetHeader)
+	transformRequestHeader(info, targetHeader)
 	targetHeader.Set("Content-Type", c.Request.Header.Get("Content-Type"))
 	targetConn, _, err := websocket.DefaultDialer.Dial(fullRequestURL, targetHeader)
 	if err != nil {
@@ -203,20 +233,47 @@ func sendPingData(c *gin.Context, mutex *sync.Mutex) error {
 	}
 }
 
+// isUpstreamConnectionError reports whether the request failed at the
+// connection level before any response was received, so sending it again
+// cannot duplicate a response the client has already seen.
+func isUpstreamConnectionError(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// recordChannelLatency records how long the channel took to answer a
+// successful request. Stream responses count until the first token arrives,
+// since the response header alone says nothing about generation speed.
+func recordChannelLatency(info *common.RelayInfo, requestStart time.Time) {
+	if !info.IsStream {
+		model.RecordChannelLatency(info.ChannelId, time.Since(requestStart))
+		return
+	}
+	channelId := info.ChannelId
+	info.OnFirstResponse = func() {
+		model.RecordChannelLatency(channelId, time.Since(requestStart))
+	}
+}
+
 func doRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http.Response, error) {
-	var client *http.Client
-	var err error
-	if info.ChannelSetting.Proxy != "" {
-		client, err = service.NewProxyHttpClient(info.ChannelSetting.Proxy)
-		if err != nil {
-			return nil, fmt.Errorf("new proxy http client failed: %w", err)
+	client, err := service.GetChannelHttpClient(c.Request.Context(), info.ChannelSetting)
+	if err != nil {
+		return nil, fmt.Errorf("new channel http client failed: %w", err)
+	}
+
+	var firstToken *firstTokenDeadline
+	if info.IsStream {
+		if timeoutMs := operation_setting.GetRetrySetting().StreamFirstTokenTimeoutMs; timeoutMs > 0 {
+			firstToken = newFirstTokenDeadline(c, time.Duration(timeoutMs)*time.Millisecond)
+			// 在 ping 保活停止后恢复原始 writer，避免重试时层层包装
+			defer firstToken.restoreWriter()
+			req = req.WithContext(firstToken.ctx)
 		}
-	} else {
-		client = service.GetHttpClient()
 	}
 
 	var stopPinger context.CancelFunc
-	if info.IsStream {
+	// 强制流式上游时客户端期望非流式响应，不设置 SSE 头也不发送 ping
+	if info.IsStream && info.StreamModeForced != model_setting.StreamModeForceStream {
 		helper.SetEventStreamHeaders(c)
 		// 处理流式请求的 ping 保活
 		generalSettings := operation_setting.GetGeneralSetting()
@@ -235,14 +292,39 @@ func doRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http
 		}
 	}
 
-	resp, err := client.Do(req)
+	if constant2.TraceUpstreamTiming {
+		info.UpstreamTiming = common.NewUpstreamTiming()
+		req = req.WithContext(info.UpstreamTiming.WithClientTrace(req.Context()))
+	}
+
+	requestStart := time.Now()
+	resp, err := doUpstreamRequest(client, req, info)
 
 	if err != nil {
+		if isUpstreamConnectionError(err) {
+			common2.SetContextKey(c, constant2.ContextKeyUpstreamConnectionFailed, true)
+		}
+		if firstToken != nil {
+			err = firstToken.wrapError(err)
+		}
 		return nil, err
 	}
 	if resp == nil {
 		return nil, errors.New("resp is nil")
 	}
+	if firstToken != nil {
+		if err = firstToken.waitFirstByte(resp); err != nil {
+			return nil, err
+		}
+	}
+	service.RecordChannelRateLimitHeaders(info, resp.Header)
+	if resp.StatusCode/100 == 2 {
+		recordChannelLatency(info, requestStart)
+	}
+	common2.LimitResponseBody(resp, constant2.MaxUpstreamResponseSize)
+	if err = transformResponse(c, info, resp); err != nil {
+		return nil, fmt.Errorf("transform response failed: %w", err)
+	}
 
 	_ = req.Body.Close()
 	_ = c.Request.Body.Close()
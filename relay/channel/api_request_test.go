@@ -0,0 +1,288 @@
+package channel
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDoRequestRecordsChannelLatency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service.InitHttpClient()
+	setting := operation_setting.GetChannelLatencyScoreSetting()
+	saved := *setting
+	setting.Enabled, setting.WindowSize = true, 10
+	t.Cleanup(func() { *setting = saved })
+
+	const firstTokenDelay = 200 * time.Millisecond
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 立即返回响应头，首个 token 延迟到达
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(firstTokenDelay)
+		_, _ = w.Write([]byte("data: {}\n\n"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		channelId int
+		stream    bool
+	}{
+		{name: "non-stream counts until the response header", channelId: 90001},
+		{name: "stream counts until the first token", channelId: 90002, stream: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			info := relaycommon.GenRelayInfo(c)
+			info.ChannelId, info.IsStream = tt.channelId, tt.stream
+
+			req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{}`))
+			resp, err := doRequest(c, req, info)
+			if err != nil {
+				t.Fatalf("doRequest() error = %v", err)
+			}
+			defer resp.Body.Close()
+			_, _, samples := model.GetChannelLatency(tt.channelId)
+			if tt.stream && samples != 0 {
+				t.Fatalf("stream latency recorded before the first token")
+			}
+
+			// 与流式处理器一样，读到首个数据后记录首响时间
+			if _, err := bufio.NewReader(resp.Body).ReadString('\n'); err != nil {
+				t.Fatalf("read first chunk: %v", err)
+			}
+			info.SetFirstResponseTime()
+
+			avg, _, samples := model.GetChannelLatency(tt.channelId)
+			if samples != 1 {
+				t.Fatalf("recorded %d latency samples, want 1", samples)
+			}
+			if slow := avg >= float64(firstTokenDelay.Milliseconds()); slow != tt.stream {
+				t.Errorf("latency = %vms, includes the first token delay = %v, want %v", avg, slow, tt.stream)
+			}
+		})
+	}
+}
+
+func TestIsUpstreamConnectionError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "connection reset", err: &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, want: true},
+		{name: "connection refused", err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, want: true},
+		{name: "broken pipe", err: fmt.Errorf("write body: %w", syscall.EPIPE), want: true},
+		{name: "closed before the response", err: &url.Error{Op: "Post", URL: "http://upstream", Err: io.EOF}, want: true},
+		{name: "unexpected EOF", err: io.ErrUnexpectedEOF, want: true},
+		{name: "timeout", err: context.DeadlineExceeded},
+		{name: "cancelled", err: context.Canceled},
+		{name: "other", err: errors.New("tls: handshake failure")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isUpstreamConnectionError(tt.err); got != tt.want {
+				t.Errorf("isUpstreamConnectionError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// resetOnceListener resets the first accepted connection before anything is
+// sent back, then serves normally.
+type resetOnceListener struct {
+	net.Listener
+	reset sync.Once
+}
+
+func (l *resetOnceListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		resetNow := false
+		l.reset.Do(func() { resetNow = true })
+		if !resetNow {
+			return conn, nil
+		}
+		// 读到请求后以 RST 关闭连接
+		_, _ = conn.Read(make([]byte, 1024))
+		_ = conn.(*net.TCPConn).SetLinger(0)
+		_ = conn.Close()
+	}
+}
+
+func TestDoRequestMarksUpstreamConnectionFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service.InitHttpClient()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	server.Listener = &resetOnceListener{Listener: server.Listener}
+	server.Start()
+	defer server.Close()
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	for attempt, wantFailed := range []bool{true, false} {
+		common.SetContextKey(c, constant.ContextKeyUpstreamConnectionFailed, false)
+		req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{}`))
+		resp, err := doRequest(c, req, &relaycommon.RelayInfo{})
+		if failed := common.GetContextKeyBool(c, constant.ContextKeyUpstreamConnectionFailed); failed != wantFailed {
+			t.Fatalf("attempt %d: connection failure marked = %v (err = %v), want %v", attempt, failed, err, wantFailed)
+		}
+		if wantFailed {
+			if err == nil {
+				t.Fatalf("attempt %d: doRequest() succeeded on a reset connection", attempt)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("attempt %d: doRequest() error = %v", attempt, err)
+		}
+		_ = resp.Body.Close()
+	}
+}
+
+// headerTestAdaptor sets default headers like a real adaptor and sends every
+// request to url.
+type headerTestAdaptor struct {
+	Adaptor
+	url string
+}
+
+func (a *headerTestAdaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
+	return a.url, nil
+}
+
+func (a *headerTestAdaptor) SetupRequestHeader(c *gin.Context, header *http.Header, info *relaycommon.RelayInfo) error {
+	SetupApiRequestHeader(info, c, header)
+	header.Set("Authorization", "Bearer "+info.ApiKey)
+	return nil
+}
+
+func TestHeaderOverrideTakesPrecedence(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service.InitHttpClient()
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	adaptor := &headerTestAdaptor{url: server.URL}
+
+	tests := []struct {
+		name     string
+		override map[string]string
+		form     bool
+		want     map[string]string
+	}{
+		{name: "adaptor defaults without overrides", want: map[string]string{"Authorization": "Bearer sk-test"}},
+		{name: "override replaces an adaptor default", override: map[string]string{"Authorization": "Bearer sk-override"},
+			want: map[string]string{"Authorization": "Bearer sk-override"}},
+		{name: "placeholders are filled in", override: map[string]string{"X-Model": "{model}", "X-Api-Version": "v-{api_version}"},
+			want: map[string]string{"X-Model": "gpt-4o", "X-Api-Version": "v-2024-06-01", "Authorization": "Bearer sk-test"}},
+		{name: "form requests are overridden too", form: true, override: map[string]string{"Authorization": "Bearer sk-override"},
+			want: map[string]string{"Authorization": "Bearer sk-override"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = nil
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			c.Request.Header.Set("Content-Type", "application/json")
+			info := &relaycommon.RelayInfo{ApiKey: "sk-test", UpstreamModelName: "gpt-4o", ApiVersion: "2024-06-01"}
+			info.ChannelSetting.HeaderOverride = tt.override
+
+			var resp *http.Response
+			var err error
+			if tt.form {
+				resp, err = DoFormRequest(adaptor, c, info, strings.NewReader(`{}`))
+			} else {
+				resp, err = DoApiRequest(adaptor, c, info, strings.NewReader(`{}`))
+			}
+			if err != nil {
+				t.Fatalf("request error = %v", err)
+			}
+			_ = resp.Body.Close()
+			for key, want := range tt.want {
+				if got := received.Get(key); got != want {
+					t.Errorf("upstream header %s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDoRequestTracesUpstreamTiming(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service.InitHttpClient()
+	saved := constant.TraceUpstreamTiming
+	t.Cleanup(func() { constant.TraceUpstreamTiming = saved })
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	for _, enabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("enabled=%v", enabled), func(t *testing.T) {
+			constant.TraceUpstreamTiming = enabled
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			info := relaycommon.GenRelayInfo(c)
+
+			req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{}`))
+			resp, err := doRequest(c, req, info)
+			if err != nil {
+				t.Fatalf("doRequest() error = %v", err)
+			}
+			_ = resp.Body.Close()
+
+			if !enabled {
+				if info.UpstreamTiming != nil {
+					t.Fatal("UpstreamTiming recorded while tracing is disabled")
+				}
+				return
+			}
+			if info.UpstreamTiming == nil {
+				t.Fatal("UpstreamTiming = nil while tracing is enabled")
+			}
+			timing := info.UpstreamTiming.ToMap()
+			for _, phase := range []string{"dns", "connect", "tls", "ttfb", "total"} {
+				if _, ok := timing[phase]; !ok {
+					t.Errorf("timing is missing %q: %v", phase, timing)
+				}
+			}
+			if timing["total"].(int64) < timing["ttfb"].(int64) {
+				t.Errorf("total = %v, want at least ttfb %v", timing["total"], timing["ttfb"])
+			}
+		})
+	}
+}
@@ -47,9 +47,9 @@ func (a *Adaptor) Init(info *relaycommon.RelayInfo) {
 
 func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 	if a.RequestMode == RequestModeMessage {
-		return fmt.Sprintf("%s/v1/messages", info.BaseUrl), nil
+		return fmt.Sprintf("%s%s", info.BaseUrl, relaycommon.ApplyPathTemplate(info, "/v1/messages")), nil
 	} else {
-		return fmt.Sprintf("%s/v1/complete", info.BaseUrl), nil
+		return fmt.Sprintf("%s%s", info.BaseUrl, relaycommon.ApplyPathTemplate(info, "/v1/complete")), nil
 	}
 }
 
@@ -469,6 +469,7 @@ type ClaudeResponseInfo struct {
 	Created      int64
 	Model        string
 	ResponseText strings.Builder
+	ThinkingText strings.Builder
 	Usage        *dto.Usage
 	Done         bool
 }
@@ -492,6 +493,7 @@ func FormatClaudeResponseInfo(requestMode int, claudeResponse *dto.ClaudeRespons
 			}
 			if claudeResponse.Delta.Thinking != "" {
 				claudeInfo.ResponseText.WriteString(claudeResponse.Delta.Thinking)
+				claudeInfo.ThinkingText.WriteString(claudeResponse.Delta.Thinking)
 			}
 		} else if claudeResponse.Type == "message_delta" {
 			// 最终的usage获取
@@ -517,6 +519,20 @@ func FormatClaudeResponseInfo(requestMode int, claudeResponse *dto.ClaudeRespons
 	return true
 }
 
+// setThinkingTokens records the aggregated thinking text as reasoning tokens.
+// Anthropic counts thinking in output_tokens without a breakdown, so the
+// count is estimated and capped at the completion tokens.
+func setThinkingTokens(claudeInfo *ClaudeResponseInfo, modelName string) {
+	if claudeInfo.ThinkingText.Len() == 0 {
+		return
+	}
+	thinkingTokens := service.CountTextToken(claudeInfo.ThinkingText.String(), modelName)
+	if thinkingTokens > claudeInfo.Usage.CompletionTokens {
+		thinkingTokens = claudeInfo.Usage.CompletionTokens
+	}
+	claudeInfo.Usage.CompletionTokenDetails.ReasoningTokens = thinkingTokens
+}
+
 func HandleStreamResponseData(c *gin.Context, info *relaycommon.RelayInfo, claudeInfo *ClaudeResponseInfo, data string, requestMode int) *dto.OpenAIErrorWithStatusCode {
 	var claudeResponse dto.ClaudeResponse
 	err := common.UnmarshalJsonStr(data, &claudeResponse)
@@ -576,6 +592,7 @@ func HandleStreamFinalResponse(c *gin.Context, info *relaycommon.RelayInfo, clau
 			}
 			claudeInfo.Usage = service.ResponseText2Usage(claudeInfo.ResponseText.String(), info.UpstreamModelName, claudeInfo.Usage.PromptTokens)
 		}
+		setThinkingTokens(claudeInfo, info.UpstreamModelName)
 	}
 
 	if info.RelayFormat == relaycommon.RelayFormatClaude {
@@ -644,6 +661,29 @@ func HandleClaudeResponseData(c *gin.Context, info *relaycommon.RelayInfo, claud
 		claudeInfo.Usage.TotalTokens = claudeResponse.Usage.InputTokens + claudeResponse.Usage.OutputTokens
 		claudeInfo.Usage.PromptTokensDetails.CachedTokens = claudeResponse.Usage.CacheReadInputTokens
 		claudeInfo.Usage.PromptTokensDetails.CachedCreationTokens = claudeResponse.Usage.CacheCreationInputTokens
+		for _, content := range claudeResponse.Content {
+			if content.Type == "thinking" {
+				claudeInfo.ThinkingText.WriteString(content.Thinking)
+			}
+		}
+		setThinkingTokens(claudeInfo, info.UpstreamModelName)
+	}
+	if claudeInfo.Usage.CompletionTokens == 0 && len(claudeResponse.Content) == 0 && claudeResponse.Completion == "" {
+		if emptyErr := helper.EmptyResponseError(info); emptyErr != nil {
+			return emptyErr
+		}
+	}
+	helper.RecordFinishReason(info, claudeResponse.StopReason, claudeInfo.Usage.CompletionTokens)
+	var claudeText strings.Builder
+	claudeText.WriteString(claudeResponse.Completion)
+	for _, content := range claudeResponse.Content {
+		if content.Type == "text" {
+			claudeText.WriteString(content.GetText())
+		}
+	}
+	helper.RecordRefusal(info, claudeResponse.StopReason, "", claudeText.String(), claudeInfo.Usage.CompletionTokens)
+	if truncationErr := helper.TruncationRetryError(c, info, claudeInfo.Usage.CompletionTokens); truncationErr != nil {
+		return truncationErr
 	}
 	var responseData []byte
 	switch info.RelayFormat {
@@ -654,8 +694,9 @@ func HandleClaudeResponseData(c *gin.Context, info *relaycommon.RelayInfo, claud
 		if err != nil {
 			return service.OpenAIErrorWrapper(err, "marshal_response_body_failed", http.StatusInternalServerError)
 		}
+		responseData = helper.RedactOpenAIResponse(c, responseData, "message")
 	case relaycommon.RelayFormatClaude:
-		responseData = data
+		responseData = helper.RedactClaudeResponse(c, data)
 	}
 
 	common.IOCopyBytesGracefully(c, nil, responseData)
@@ -679,8 +720,13 @@ func ClaudeHandler(c *gin.Context, resp *http.Response, requestMode int, info *r
 	if common.DebugEnabled {
 		println("responseBody: ", string(responseBody))
 	}
+	responseBody = helper.RepairUpstreamJson(c, responseBody)
 	handleErr := HandleClaudeResponseData(c, info, claudeInfo, responseBody, requestMode)
 	if handleErr != nil {
+		if handleErr.Error.Code == helper.ErrorCodeResponseTruncated {
+			// 截断的响应被丢弃重试，仍需返回用量用于计费
+			return handleErr, claudeInfo.Usage
+		}
 		return handleErr, nil
 	}
 	return nil, claudeInfo.Usage
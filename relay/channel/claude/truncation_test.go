@@ -0,0 +1,69 @@
+package claude
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const truncatedClaudeResponse = `{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-sonnet",` +
+	`"content":[{"type":"text","text":"partial"}],"stop_reason":"max_tokens",` +
+	`"usage":{"input_tokens":10,"output_tokens":16}}`
+
+func TestClaudeHandlerTruncatedResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name         string
+		retryAllowed bool
+		relayFormat  string
+	}{
+		// 对话接口转发到 Claude 渠道时允许截断重试
+		{name: "retry allowed", retryAllowed: true, relayFormat: relaycommon.RelayFormatOpenAI},
+		{name: "retry not allowed", relayFormat: relaycommon.RelayFormatClaude},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+			c.Set(helper.TruncationRetryAllowedContextKey, tt.retryAllowed)
+			info := &relaycommon.RelayInfo{RequestMaxTokens: 16, RelayFormat: tt.relayFormat}
+			resp := &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(truncatedClaudeResponse))}
+
+			openaiErr, usage := ClaudeHandler(c, resp, RequestModeMessage, info)
+			if !info.Truncated || info.UpstreamFinishReason != "length" {
+				t.Errorf("truncated = %v, finish reason = %q, want a length truncation", info.Truncated, info.UpstreamFinishReason)
+			}
+			if usage == nil || usage.PromptTokens != 10 || usage.CompletionTokens != 16 {
+				t.Fatalf("usage = %+v, want the upstream usage", usage)
+			}
+			if !tt.retryAllowed {
+				if openaiErr != nil {
+					t.Fatalf("ClaudeHandler() error = %v, want the truncated response relayed", openaiErr)
+				}
+				if !strings.Contains(recorder.Body.String(), "partial") {
+					t.Errorf("response body = %q, want the truncated response", recorder.Body.String())
+				}
+				return
+			}
+			if openaiErr == nil || openaiErr.Error.Code != helper.ErrorCodeResponseTruncated {
+				t.Fatalf("ClaudeHandler() error = %v, want %s", openaiErr, helper.ErrorCodeResponseTruncated)
+			}
+			if helper.TruncatedUsage(openaiErr, usage) != usage {
+				t.Error("TruncatedUsage() does not return the usage of the discarded response")
+			}
+			if recorder.Body.Len() != 0 {
+				t.Errorf("truncated response was written to the client: %q", recorder.Body.String())
+			}
+			if got := c.GetInt(helper.TruncationRetryMaxTokensContextKey); got != 32 {
+				t.Errorf("retry max_tokens = %d, want 32", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	common2 "one-api/common"
+	constant2 "one-api/constant"
+	"one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+var upstreamFlight singleflight.Group
+
+const defaultDedupTimeout = 300 * time.Second
+
+// sharedResponse 一次上游调用的完整响应，供合并的请求各自重建 http.Response
+type sharedResponse struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+}
+
+// dedupSamplingParams 判断请求结果是否确定所需的采样参数，兼容 Gemini 的 generationConfig
+type dedupSamplingParams struct {
+	Temperature      *float64 `json:"temperature"`
+	N                *int     `json:"n"`
+	GenerationConfig *struct {
+		Temperature    *float64 `json:"temperature"`
+		CandidateCount *int     `json:"candidateCount"`
+	} `json:"generationConfig"`
+}
+
+// isDeterministicRequest reports whether identical requests are expected to
+// get identical responses: the temperature is explicitly 0 and at most one
+// choice is requested. Without a temperature the upstream default applies,
+// which is usually above 0.
+func isDeterministicRequest(body []byte) bool {
+	var params dedupSamplingParams
+	if err := common2.UnmarshalJson(body, &params); err != nil {
+		return false
+	}
+	temperature, n := params.Temperature, params.N
+	if config := params.GenerationConfig; config != nil {
+		if temperature == nil {
+			temperature = config.Temperature
+		}
+		if n == nil {
+			n = config.CandidateCount
+		}
+	}
+	return temperature != nil && *temperature == 0 && (n == nil || *n <= 1)
+}
+
+func dedupTimeout() time.Duration {
+	if seconds := operation_setting.GetRequestDedupSetting().TimeoutSeconds; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultDedupTimeout
+}
+
+// dedupKey identifies requests that may share an upstream call. Besides the
+// method, URL and body it covers the channel, its key and every outgoing
+// header, including header overrides and transforms, so requests that would
+// reach the upstream with different credentials or options never share one.
+func dedupKey(req *http.Request, body []byte, info *common.RelayInfo) string {
+	hash := sha256.New()
+	hash.Write([]byte(fmt.Sprintf("%s %s\n%d %s\n", req.Method, req.URL.String(), info.ChannelId, info.ApiKey)))
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		hash.Write([]byte(name + ": " + strings.Join(req.Header[name], ", ") + "\n"))
+	}
+	hash.Write([]byte("\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// doUpstreamRequest sends the request upstream. With request deduplication
+// enabled, concurrent deterministic non-streaming requests with the same
+// dedup key share a single upstream call and each receive a copy
+// of its response. The shared call runs on a context detached from the
+// request that started it, so a disconnecting client does not fail the
+// others; each request still stops waiting when its own context ends.
+func doUpstreamRequest(client *http.Client, req *http.Request, info *common.RelayInfo) (*http.Response, error) {
+	if !operation_setting.GetRequestDedupSetting().Enabled || info.IsStream || req.Body == nil || req.Method != http.MethodPost {
+		return client.Do(req)
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	_ = req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	if !isDeterministicRequest(body) {
+		return client.Do(req)
+	}
+
+	key := dedupKey(req, body, info)
+
+	leader := false
+	resultChan := upstreamFlight.DoChan(key, func() (interface{}, error) {
+		leader = true
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(req.Context()), dedupTimeout())
+		defer cancel()
+		sharedReq := req.Clone(ctx)
+		sharedReq.Body = io.NopCloser(bytes.NewReader(body))
+		resp, err := client.Do(sharedReq)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		common2.LimitResponseBody(resp, constant2.MaxUpstreamResponseSize)
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return &sharedResponse{statusCode: resp.StatusCode, header: resp.Header, body: respBody}, nil
+	})
+	var result singleflight.Result
+	select {
+	case result = <-resultChan:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	info.DedupShared = result.Shared && !leader
+	response := result.Val.(*sharedResponse)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", response.statusCode, http.StatusText(response.statusCode)),
+		StatusCode:    response.statusCode,
+		Header:        response.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(response.body)),
+		ContentLength: int64(len(response.body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,215 @@
+package channel
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIsDeterministicRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{name: "temperature zero", body: `{"model":"m","temperature":0}`, want: true},
+		{name: "temperature zero n one", body: `{"temperature":0,"n":1}`, want: true},
+		{name: "no temperature", body: `{"model":"m"}`, want: false},
+		{name: "temperature above zero", body: `{"temperature":0.7}`, want: false},
+		{name: "several choices", body: `{"temperature":0,"n":2}`, want: false},
+		{name: "gemini generation config", body: `{"generationConfig":{"temperature":0}}`, want: true},
+		{name: "gemini candidates", body: `{"generationConfig":{"temperature":0,"candidateCount":3}}`, want: false},
+		{name: "invalid json", body: `not json`, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isDeterministicRequest([]byte(tt.body)); got != tt.want {
+				t.Errorf("isDeterministicRequest(%s) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func enableRequestDedup(t *testing.T) {
+	setting := operation_setting.GetRequestDedupSetting()
+	enabled := setting.Enabled
+	setting.Enabled = true
+	t.Cleanup(func() { setting.Enabled = enabled })
+}
+
+func TestDoUpstreamRequestSurvivesLeaderCancel(t *testing.T) {
+	enableRequestDedup(t)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	body := `{"model":"m","temperature":0}`
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	newRequest := func(ctx context.Context) *http.Request {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, strings.NewReader(body))
+		return req
+	}
+
+	leaderDone := make(chan error, 1)
+	go func() {
+		_, err := doUpstreamRequest(server.Client(), newRequest(leaderCtx), &common.RelayInfo{})
+		leaderDone <- err
+	}()
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	var wg sync.WaitGroup
+	followers := make([]*common.RelayInfo, 3)
+	bodies := make([]string, 3)
+	for i := range followers {
+		followers[i] = &common.RelayInfo{}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := doUpstreamRequest(server.Client(), newRequest(context.Background()), followers[i])
+			if err != nil {
+				t.Errorf("follower %d: %v", i, err)
+				return
+			}
+			data, _ := io.ReadAll(resp.Body)
+			bodies[i] = string(data)
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	cancelLeader()
+	if err := <-leaderDone; err == nil {
+		t.Error("canceled leader got no error")
+	}
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("upstream calls = %d, want 1", calls.Load())
+	}
+	for i, info := range followers {
+		if bodies[i] != `{"ok":true}` || !info.DedupShared {
+			t.Errorf("follower %d body = %q, shared = %v", i, bodies[i], info.DedupShared)
+		}
+	}
+}
+
+func TestDoUpstreamRequestSkipsNonDeterministic(t *testing.T) {
+	enableRequestDedup(t)
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{"temperature":1}`))
+			resp, err := doUpstreamRequest(server.Client(), req, &common.RelayInfo{})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_ = resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+	if calls.Load() != 3 {
+		t.Errorf("upstream calls = %d, want 3", calls.Load())
+	}
+}
+
+func TestDedupKey(t *testing.T) {
+	body := []byte(`{"model":"m","temperature":0}`)
+	newRequest := func(headers map[string]string) *http.Request {
+		req, _ := http.NewRequest(http.MethodPost, "https://upstream.example.com/v1/chat/completions", nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		return req
+	}
+	base := dedupKey(newRequest(map[string]string{"Authorization": "Bearer sk-1", "X-Tenant": "a"}), body,
+		&common.RelayInfo{ChannelId: 1, ApiKey: "sk-1"})
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		info    *common.RelayInfo
+		same    bool
+	}{
+		{name: "identical request", headers: map[string]string{"X-Tenant": "a", "Authorization": "Bearer sk-1"},
+			info: &common.RelayInfo{ChannelId: 1, ApiKey: "sk-1"}, same: true},
+		{name: "different credentials", headers: map[string]string{"Authorization": "Bearer sk-2", "X-Tenant": "a"},
+			info: &common.RelayInfo{ChannelId: 1, ApiKey: "sk-1"}},
+		{name: "different transformed header", headers: map[string]string{"Authorization": "Bearer sk-1", "X-Tenant": "b"},
+			info: &common.RelayInfo{ChannelId: 1, ApiKey: "sk-1"}},
+		{name: "extra header", headers: map[string]string{"Authorization": "Bearer sk-1", "X-Tenant": "a", "X-Debug": "1"},
+			info: &common.RelayInfo{ChannelId: 1, ApiKey: "sk-1"}},
+		{name: "different channel key", headers: map[string]string{"Authorization": "Bearer sk-1", "X-Tenant": "a"},
+			info: &common.RelayInfo{ChannelId: 1, ApiKey: "sk-3"}},
+		{name: "different channel", headers: map[string]string{"Authorization": "Bearer sk-1", "X-Tenant": "a"},
+			info: &common.RelayInfo{ChannelId: 2, ApiKey: "sk-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := dedupKey(newRequest(tt.headers), body, tt.info)
+			if (got == base) != tt.same {
+				t.Errorf("dedupKey() equal to base = %v, want %v", got == base, tt.same)
+			}
+		})
+	}
+}
+
+func TestDoUpstreamRequestDoesNotShareAcrossKeys(t *testing.T) {
+	enableRequestDedup(t)
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 2)
+	for i, key := range []string{"sk-1", "sk-2"} {
+		wg.Add(1)
+		go func(i int, key string) {
+			defer wg.Done()
+			req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{"temperature":0}`))
+			req.Header.Set("Authorization", "Bearer "+key)
+			resp, err := doUpstreamRequest(server.Client(), req, &common.RelayInfo{ApiKey: key})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			data, _ := io.ReadAll(resp.Body)
+			bodies[i] = string(data)
+		}(i, key)
+	}
+	wg.Wait()
+	if calls.Load() != 2 {
+		t.Errorf("upstream calls = %d, want 2", calls.Load())
+	}
+	if bodies[0] != "Bearer sk-1" || bodies[1] != "Bearer sk-2" {
+		t.Errorf("responses = %q, want each request's own credentials", bodies)
+	}
+}
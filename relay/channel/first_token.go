@@ -0,0 +1,134 @@
+package channel
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// firstTokenDeadline cancels a streaming upstream request whose first byte
+// has not arrived in time, so the relay can fail over to another channel.
+// It never fires once anything has been written to the client.
+type firstTokenDeadline struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	timer   *time.Timer
+	timeout time.Duration
+	fired   atomic.Bool
+	c       *gin.Context
+	writer  *firstTokenWriter
+}
+
+// newFirstTokenDeadline wraps the response writer to observe writes from
+// other goroutines, so it must be called before the ping keep-alive starts.
+// The caller must call restoreWriter once the attempt ends.
+func newFirstTokenDeadline(c *gin.Context, timeout time.Duration) *firstTokenDeadline {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	writer := &firstTokenWriter{ResponseWriter: c.Writer}
+	writer.written.Store(c.Writer.Written())
+	c.Writer = writer
+	d := &firstTokenDeadline{ctx: ctx, cancel: cancel, timeout: timeout, c: c, writer: writer}
+	d.timer = time.AfterFunc(timeout, func() {
+		// 已向客户端写入内容（如 ping 保活）时无法再换渠道，继续等待上游
+		if writer.written.Load() {
+			return
+		}
+		d.fired.Store(true)
+		cancel()
+	})
+	return d
+}
+
+// restoreWriter puts back the response writer wrapped by
+// newFirstTokenDeadline, so that retries do not nest wrappers. It must be
+// called after the ping keep-alive has stopped.
+func (d *firstTokenDeadline) restoreWriter() {
+	if d.c.Writer == d.writer {
+		d.c.Writer = d.writer.ResponseWriter
+	}
+}
+
+func (d *firstTokenDeadline) timeoutError() error {
+	return fmt.Errorf("upstream did not send the first token within %s", d.timeout)
+}
+
+// wrapError replaces the cancellation error caused by the deadline.
+func (d *firstTokenDeadline) wrapError(err error) error {
+	d.timer.Stop()
+	if d.fired.Load() {
+		return d.timeoutError()
+	}
+	d.cancel()
+	return err
+}
+
+// waitFirstByte blocks until the first byte of a successful response body
+// arrives or the deadline fires. The request context stays alive until the
+// returned body is closed.
+func (d *firstTokenDeadline) waitFirstByte(resp *http.Response) error {
+	if resp.StatusCode != http.StatusOK {
+		d.timer.Stop()
+		resp.Body = &firstTokenBody{Reader: resp.Body, body: resp.Body, cancel: d.cancel}
+		return nil
+	}
+	reader := bufio.NewReader(resp.Body)
+	_, err := reader.Peek(1)
+	d.timer.Stop()
+	if d.fired.Load() {
+		_ = resp.Body.Close()
+		d.cancel()
+		return d.timeoutError()
+	}
+	if err != nil && err != io.EOF {
+		_ = resp.Body.Close()
+		d.cancel()
+		return err
+	}
+	resp.Body = &firstTokenBody{Reader: reader, body: resp.Body, cancel: d.cancel}
+	return nil
+}
+
+type firstTokenBody struct {
+	io.Reader
+	body   io.Closer
+	cancel context.CancelFunc
+}
+
+func (b *firstTokenBody) Close() error {
+	err := b.body.Close()
+	b.cancel()
+	return err
+}
+
+// firstTokenWriter records whether anything has been written to the client,
+// so the deadline timer can check it without racing the writing goroutine.
+type firstTokenWriter struct {
+	gin.ResponseWriter
+	written atomic.Bool
+}
+
+func (w *firstTokenWriter) Write(data []byte) (int, error) {
+	w.written.Store(true)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *firstTokenWriter) WriteString(s string) (int, error) {
+	w.written.Store(true)
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *firstTokenWriter) WriteHeaderNow() {
+	w.written.Store(true)
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *firstTokenWriter) Flush() {
+	w.written.Store(true)
+	w.ResponseWriter.Flush()
+}
@@ -0,0 +1,83 @@
+package channel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestFirstTokenDeadline(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name      string
+		write     bool
+		wantFired bool
+	}{
+		{name: "nothing written", write: false, wantFired: true},
+		{name: "ping written", write: true, wantFired: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			d := newFirstTokenDeadline(c, 20*time.Millisecond)
+			writer := c.Writer
+			done := make(chan struct{})
+			// 模拟 ping 保活在其他 goroutine 中写入
+			go func() {
+				defer close(done)
+				if tt.write {
+					_, _ = writer.WriteString(": PING\n\n")
+					writer.Flush()
+				}
+			}()
+			<-done
+			select {
+			case <-d.ctx.Done():
+			case <-time.After(200 * time.Millisecond):
+			}
+			if got := d.fired.Load(); got != tt.wantFired {
+				t.Errorf("fired = %v, want %v", got, tt.wantFired)
+			}
+			d.wrapError(nil)
+		})
+	}
+}
+
+func TestDoRequestRestoresWriterAcrossRetries(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service.InitHttpClient()
+	retrySetting := operation_setting.GetRetrySetting()
+	timeoutMs := retrySetting.StreamFirstTokenTimeoutMs
+	retrySetting.StreamFirstTokenTimeoutMs = 1000
+	t.Cleanup(func() { retrySetting.StreamFirstTokenTimeoutMs = timeoutMs })
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {}\n\n"))
+	}))
+	defer server.Close()
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	original := c.Writer
+	// 每次重试都经过一次 doRequest
+	for attempt := 0; attempt < 3; attempt++ {
+		req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{"stream":true}`))
+		resp, err := doRequest(c, req, &relaycommon.RelayInfo{IsStream: true})
+		if err != nil {
+			t.Fatalf("attempt %d: doRequest() error = %v", attempt, err)
+		}
+		_ = resp.Body.Close()
+		if c.Writer != original {
+			t.Fatalf("attempt %d: response writer is %T, want the original writer restored", attempt, c.Writer)
+		}
+	}
+}
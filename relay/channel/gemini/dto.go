@@ -171,6 +171,8 @@ type GeminiUsageMetadata struct {
 	TotalTokenCount      int                         `json:"totalTokenCount"`
 	ThoughtsTokenCount   int                         `json:"thoughtsTokenCount"`
 	PromptTokensDetails  []GeminiPromptTokensDetails `json:"promptTokensDetails"`
+	// 输出 token 按模态的明细，用于单独计费生成的音频/图片
+	CandidatesTokensDetails []GeminiPromptTokensDetails `json:"candidatesTokensDetails,omitempty"`
 }
 
 type GeminiPromptTokensDetails struct {
@@ -49,12 +49,20 @@ func GeminiTextGenerationHandler(c *gin.Context, resp *http.Response, info *rela
 			usage.PromptTokensDetails.TextTokens = detail.TokenCount
 		}
 	}
+	for _, detail := range geminiResponse.UsageMetadata.CandidatesTokensDetails {
+		if detail.Modality == "AUDIO" {
+			usage.CompletionTokenDetails.AudioTokens = detail.TokenCount
+		} else if detail.Modality == "IMAGE" {
+			usage.CompletionTokenDetails.ImageTokens = detail.TokenCount
+		}
+	}
 
 	// 直接返回 Gemini 原生格式的 JSON 响应
 	jsonResponse, err := common.EncodeJson(geminiResponse)
 	if err != nil {
 		return nil, service.OpenAIErrorWrapper(err, "marshal_response_body_failed", http.StatusInternalServerError)
 	}
+	jsonResponse = helper.RedactGeminiResponse(c, jsonResponse)
 
 	common.IOCopyBytesGracefully(c, resp, jsonResponse)
 
@@ -102,6 +110,13 @@ func GeminiTextGenerationStreamHandler(c *gin.Context, resp *http.Response, info
 					usage.PromptTokensDetails.TextTokens = detail.TokenCount
 				}
 			}
+			for _, detail := range geminiResponse.UsageMetadata.CandidatesTokensDetails {
+				if detail.Modality == "AUDIO" {
+					usage.CompletionTokenDetails.AudioTokens = detail.TokenCount
+				} else if detail.Modality == "IMAGE" {
+					usage.CompletionTokenDetails.ImageTokens = detail.TokenCount
+				}
+			}
 		}
 
 		// 直接发送 GeminiChatResponse 响应
@@ -112,6 +127,9 @@ func GeminiTextGenerationStreamHandler(c *gin.Context, resp *http.Response, info
 
 		return true
 	})
+	if err := helper.FlushOutputRedaction(c); err != nil {
+		common.LogError(c, err.Error())
+	}
 
 	if imageCount != 0 {
 		if usage.CompletionTokens == 0 {
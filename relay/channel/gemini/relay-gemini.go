@@ -143,6 +143,7 @@ func CovertGemini2OpenAI(textRequest dto.GeneralOpenAIRequest, info *relaycommon
 			TopP:            textRequest.TopP,
 			MaxOutputTokens: textRequest.MaxTokens,
 			Seed:            int64(textRequest.Seed),
+			StopSequences:   textRequest.ParseStop(),
 		},
 	}
 
@@ -826,6 +827,13 @@ func GeminiChatStreamHandler(c *gin.Context, resp *http.Response, info *relaycom
 					usage.PromptTokensDetails.TextTokens = detail.TokenCount
 				}
 			}
+			for _, detail := range geminiResponse.UsageMetadata.CandidatesTokensDetails {
+				if detail.Modality == "AUDIO" {
+					usage.CompletionTokenDetails.AudioTokens = detail.TokenCount
+				} else if detail.Modality == "IMAGE" {
+					usage.CompletionTokenDetails.ImageTokens = detail.TokenCount
+				}
+			}
 		}
 		err = helper.ObjectData(c, response)
 		if err != nil {
@@ -870,6 +878,7 @@ func GeminiChatHandler(c *gin.Context, resp *http.Response, info *relaycommon.Re
 	if common.DebugEnabled {
 		println(string(responseBody))
 	}
+	responseBody = helper.RepairUpstreamJson(c, responseBody)
 	var geminiResponse GeminiChatResponse
 	err = common.UnmarshalJson(responseBody, &geminiResponse)
 	if err != nil {
@@ -904,12 +913,20 @@ func GeminiChatHandler(c *gin.Context, resp *http.Response, info *relaycommon.Re
 			usage.PromptTokensDetails.TextTokens = detail.TokenCount
 		}
 	}
+	for _, detail := range geminiResponse.UsageMetadata.CandidatesTokensDetails {
+		if detail.Modality == "AUDIO" {
+			usage.CompletionTokenDetails.AudioTokens = detail.TokenCount
+		} else if detail.Modality == "IMAGE" {
+			usage.CompletionTokenDetails.ImageTokens = detail.TokenCount
+		}
+	}
 
 	fullTextResponse.Usage = usage
 	jsonResponse, err := json.Marshal(fullTextResponse)
 	if err != nil {
 		return service.OpenAIErrorWrapper(err, "marshal_response_body_failed", http.StatusInternalServerError), nil
 	}
+	jsonResponse = helper.RedactOpenAIResponse(c, jsonResponse, "message")
 	c.Writer.Header().Set("Content-Type", "application/json")
 	c.Writer.WriteHeader(resp.StatusCode)
 	_, err = c.Writer.Write(jsonResponse)
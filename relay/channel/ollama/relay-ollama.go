@@ -41,13 +41,7 @@ func requestOpenAI2Ollama(request dto.GeneralOpenAIRequest) (*OllamaRequest, err
 			ToolCallId: message.ToolCallId,
 		})
 	}
-	str, ok := request.Stop.(string)
-	var Stop []string
-	if ok {
-		Stop = []string{str}
-	} else {
-		Stop, _ = request.Stop.([]string)
-	}
+	Stop := request.ParseStop()
 	return &OllamaRequest{
 		Model:            request.Model,
 		Messages:         messages,
@@ -21,7 +21,9 @@ import (
 	relaycommon "one-api/relay/common"
 	"one-api/relay/common_handler"
 	relayconstant "one-api/relay/constant"
+	"one-api/relay/helper"
 	"one-api/service"
+	"one-api/setting/model_setting"
 	"path/filepath"
 	"strings"
 
@@ -64,7 +66,7 @@ func (a *Adaptor) Init(info *relaycommon.RelayInfo) {
 
 func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 	if info.RelayFormat == relaycommon.RelayFormatClaude {
-		return fmt.Sprintf("%s/v1/chat/completions", info.BaseUrl), nil
+		return fmt.Sprintf("%s%s", info.BaseUrl, relaycommon.ApplyPathTemplate(info, "/v1/chat/completions")), nil
 	}
 	if info.RelayMode == relayconstant.RelayModeRealtime {
 		if strings.HasPrefix(info.BaseUrl, "https://") {
@@ -80,9 +82,15 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 	switch info.ChannelType {
 	case constant.ChannelTypeAzure:
 		apiVersion := info.ApiVersion
+		if info.ChannelSetting.AzureApiVersion != "" {
+			apiVersion = info.ChannelSetting.AzureApiVersion
+		}
 		if apiVersion == "" {
 			apiVersion = constant.AzureDefaultAPIVersion
 		}
+		if !constant.AzureAPIVersionRegex.MatchString(apiVersion) {
+			return "", fmt.Errorf("invalid azure api version: %s", apiVersion)
+		}
 		// https://learn.microsoft.com/en-us/azure/cognitive-services/openai/chatgpt-quickstart?pivots=rest-api&tabs=command-line#rest-api
 		requestURL := strings.Split(info.RequestURLPath, "?")[0]
 		requestURL = fmt.Sprintf("%s?api-version=%s", requestURL, apiVersion)
@@ -95,8 +103,11 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 		}
 
 		model_ := info.UpstreamModelName
-		// 2025年5月10日后创建的渠道不移除.
-		if info.ChannelCreateTime < constant.AzureNoRemoveDotTime {
+		if deployment, ok := info.ChannelSetting.AzureDeployments[model_]; ok && deployment != "" {
+			// 显式配置的部署名原样使用
+			model_ = deployment
+		} else if info.ChannelCreateTime < constant.AzureNoRemoveDotTime {
+			// 2025年5月10日后创建的渠道不移除.
 			model_ = strings.Replace(model_, ".", "", -1)
 		}
 		// https://github.com/songquanpeng/one-api/issues/67
@@ -112,7 +123,7 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 		url = strings.Replace(url, "{model}", info.UpstreamModelName, -1)
 		return url, nil
 	default:
-		return relaycommon.GetFullRequestURL(info.BaseUrl, info.RequestURLPath, info.ChannelType), nil
+		return relaycommon.GetFullRequestURL(info.BaseUrl, relaycommon.ApplyPathTemplate(info, info.RequestURLPath), info.ChannelType), nil
 	}
 }
 
@@ -158,6 +169,11 @@ func (a *Adaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayIn
 	if info.ChannelType != constant.ChannelTypeOpenAI && info.ChannelType != constant.ChannelTypeAzure {
 		request.StreamOptions = nil
 	}
+	if info.RelayMode == relayconstant.RelayModeChatCompletions && model_setting.ShouldUpgradeLegacyRequest(info.ChannelType) {
+		if err := helper.UpgradeLegacyRequest(c, request); err != nil {
+			return nil, err
+		}
+	}
 	if info.ChannelType == constant.ChannelTypeOpenRouter {
 		if len(request.Usage) == 0 {
 			request.Usage = json.RawMessage(`{"include":true}`)
@@ -436,13 +452,29 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 	case relayconstant.RelayModeRerank:
 		err, usage = common_handler.RerankHandler(c, info, resp)
 	case relayconstant.RelayModeResponses:
-		if info.IsStream {
+		if info.ApiConversion == relaycommon.ApiConversionChatToResponses {
+			if info.IsStream {
+				err, usage = OaiResponsesToChatStreamHandler(c, resp, info)
+			} else {
+				err, usage = OaiResponsesToChatHandler(c, resp, info)
+			}
+		} else if info.IsStream {
 			err, usage = OaiResponsesStreamHandler(c, resp, info)
 		} else {
 			err, usage = OaiResponsesHandler(c, resp, info)
 		}
 	default:
-		if info.IsStream {
+		if info.ApiConversion == relaycommon.ApiConversionResponsesToChat {
+			if info.IsStream {
+				err, usage = OaiChatToResponsesStreamHandler(c, resp, info)
+			} else {
+				err, usage = OaiChatToResponsesHandler(c, resp, info)
+			}
+		} else if info.StreamModeForced == model_setting.StreamModeForceStream && info.IsStream {
+			err, usage = OaiStreamToNonStreamHandler(c, resp, info)
+		} else if info.StreamModeForced == model_setting.StreamModeForceNonStream && !info.IsStream {
+			err, usage = OaiNonStreamToStreamHandler(c, resp, info)
+		} else if info.IsStream {
 			err, usage = OaiStreamHandler(c, resp, info)
 		} else {
 			err, usage = OpenaiHandler(c, resp, info)
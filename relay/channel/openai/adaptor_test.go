@@ -0,0 +1,62 @@
+package openai
+
+import (
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"testing"
+)
+
+func TestGetRequestURLAzureDeployments(t *testing.T) {
+	defaultAPIVersion := constant.AzureDefaultAPIVersion
+	constant.AzureDefaultAPIVersion = "2024-12-01-preview"
+	t.Cleanup(func() { constant.AzureDefaultAPIVersion = defaultAPIVersion })
+	newChannel := constant.AzureNoRemoveDotTime + 1
+	oldChannel := constant.AzureNoRemoveDotTime - 1
+	tests := []struct {
+		name       string
+		model      string
+		createTime int64
+		apiVersion string
+		setting    dto.ChannelSettings
+		want       string
+		wantErr    bool
+	}{
+		{name: "mapped deployment is used as is", model: "gpt-4.1", createTime: oldChannel, apiVersion: "2024-06-01",
+			setting: dto.ChannelSettings{AzureDeployments: map[string]string{"gpt-4.1": "prod-gpt-4.1"}},
+			want:    "https://azure.test/openai/deployments/prod-gpt-4.1/chat/completions?api-version=2024-06-01"},
+		{name: "unmapped model on an old channel drops dots", model: "gpt-4.1", createTime: oldChannel, apiVersion: "2024-06-01",
+			want: "https://azure.test/openai/deployments/gpt-41/chat/completions?api-version=2024-06-01"},
+		{name: "unmapped model on a new channel keeps dots", model: "gpt-4.1", createTime: newChannel, apiVersion: "2024-06-01",
+			want: "https://azure.test/openai/deployments/gpt-4.1/chat/completions?api-version=2024-06-01"},
+		{name: "channel api version overrides the request", model: "gpt-4o", createTime: newChannel, apiVersion: "2024-06-01",
+			setting: dto.ChannelSettings{AzureApiVersion: "2025-04-01-preview"},
+			want:    "https://azure.test/openai/deployments/gpt-4o/chat/completions?api-version=2025-04-01-preview"},
+		{name: "default api version", model: "gpt-4o", createTime: newChannel,
+			want: "https://azure.test/openai/deployments/gpt-4o/chat/completions?api-version=" + constant.AzureDefaultAPIVersion},
+		{name: "invalid api version", model: "gpt-4o", createTime: newChannel, apiVersion: "2024-06-01&x=1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := &relaycommon.RelayInfo{RelayFormat: relaycommon.RelayFormatOpenAI, RelayMode: relayconstant.RelayModeChatCompletions,
+				ChannelType: constant.ChannelTypeAzure, BaseUrl: "https://azure.test", RequestURLPath: "/v1/chat/completions",
+				UpstreamModelName: tt.model, ApiVersion: tt.apiVersion, ChannelCreateTime: tt.createTime}
+			info.ChannelSetting = tt.setting
+
+			got, err := (&Adaptor{}).GetRequestURL(info)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("GetRequestURL() = %s, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetRequestURL() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GetRequestURL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
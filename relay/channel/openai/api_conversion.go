@@ -0,0 +1,425 @@
+package openai
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"one-api/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OaiResponsesToChatHandler converts a non-streaming responses API response
+// to a chat completion for a client that called /v1/chat/completions.
+func OaiResponsesToChatHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
+	defer common.CloseResponseBodyGracefully(resp)
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError), nil
+	}
+	var responsesResponse dto.OpenAIResponsesResponse
+	if err = common.UnmarshalJson(responseBody, &responsesResponse); err != nil {
+		return service.OpenAIErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError), nil
+	}
+	if responsesResponse.Error != nil {
+		return &dto.OpenAIErrorWithStatusCode{
+			Error: dto.OpenAIError{
+				Message: responsesResponse.Error.Message,
+				Type:    "openai_error",
+				Code:    responsesResponse.Error.Code,
+			},
+			StatusCode: resp.StatusCode,
+		}, nil
+	}
+
+	response := service.ResponseResponses2OpenAI(&responsesResponse)
+	if response.Usage.TotalTokens == 0 {
+		completionTokens := 0
+		for _, choice := range response.Choices {
+			completionTokens += service.CountTextToken(choice.Message.StringContent()+choice.Message.ReasoningContent, info.UpstreamModelName)
+		}
+		response.Usage = dto.Usage{
+			PromptTokens:     info.PromptTokens,
+			CompletionTokens: completionTokens,
+			TotalTokens:      info.PromptTokens + completionTokens,
+		}
+	}
+	for _, choice := range response.Choices {
+		helper.RecordFinishReason(info, choice.FinishReason, response.Usage.CompletionTokens)
+	}
+	if restoreModel := requestModelToRestore(info); restoreModel != "" {
+		response.Model = restoreModel
+	}
+
+	responseBody, err = common.EncodeJson(response)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "marshal_response_body_failed", http.StatusInternalServerError), nil
+	}
+	responseBody = helper.RedactOpenAIResponse(c, responseBody, "message")
+	c.Data(http.StatusOK, "application/json", responseBody)
+	return nil, &response.Usage
+}
+
+// OaiResponsesToChatStreamHandler converts responses API stream events to
+// chat completion chunks for a client that called /v1/chat/completions.
+func OaiResponsesToChatStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
+	if resp == nil || resp.Body == nil {
+		common.LogError(c, "invalid response or response body")
+		return service.OpenAIErrorWrapper(fmt.Errorf("invalid response"), "invalid_response", http.StatusInternalServerError), nil
+	}
+
+	var (
+		responseId   = helper.GetResponseID(c)
+		createAt     = common.GetTimestamp()
+		model        = info.UpstreamModelName
+		usage        *dto.Usage
+		incomplete   bool
+		responseText strings.Builder
+		// output_index of a function_call item -> index of the tool call
+		toolIndexes = map[int]int{}
+	)
+	if restoreModel := requestModelToRestore(info); restoreModel != "" {
+		model = restoreModel
+	}
+	sendDelta := func(delta dto.ChatCompletionsStreamResponseChoiceDelta) {
+		_ = helper.ObjectData(c, &dto.ChatCompletionsStreamResponse{
+			Id:      responseId,
+			Object:  "chat.completion.chunk",
+			Created: createAt,
+			Model:   model,
+			Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: delta}},
+		})
+	}
+
+	helper.StreamScannerHandler(c, resp, info, func(data string) bool {
+		var event dto.ResponsesStreamResponse
+		if err := common.UnmarshalJsonStr(data, &event); err != nil {
+			common.SysError("error unmarshalling responses stream event: " + err.Error())
+			return true
+		}
+		switch event.Type {
+		case dto.ResponsesStreamTypeCreated:
+			sendDelta(dto.ChatCompletionsStreamResponseChoiceDelta{Role: "assistant"})
+		case dto.ResponsesStreamTypeOutputTextDelta:
+			responseText.WriteString(event.Delta)
+			delta := dto.ChatCompletionsStreamResponseChoiceDelta{}
+			delta.SetContentString(event.Delta)
+			sendDelta(delta)
+		case dto.ResponsesStreamTypeReasoningSummaryDelta:
+			responseText.WriteString(event.Delta)
+			delta := dto.ChatCompletionsStreamResponseChoiceDelta{}
+			delta.SetReasoningContent(event.Delta)
+			sendDelta(delta)
+		case dto.ResponsesOutputTypeItemAdded:
+			if event.Item == nil || event.Item.Type != dto.ResponsesOutputItemFunctionCall || event.OutputIndex == nil {
+				return true
+			}
+			toolCall := dto.ToolCallResponse{
+				ID:       event.Item.CallId,
+				Type:     "function",
+				Function: dto.FunctionResponse{Name: event.Item.Name},
+			}
+			toolCall.SetIndex(len(toolIndexes))
+			toolIndexes[*event.OutputIndex] = len(toolIndexes)
+			responseText.WriteString(event.Item.Name)
+			sendDelta(dto.ChatCompletionsStreamResponseChoiceDelta{ToolCalls: []dto.ToolCallResponse{toolCall}})
+		case dto.ResponsesStreamTypeFunctionArgumentsDelta:
+			if event.OutputIndex == nil {
+				return true
+			}
+			toolIndex, ok := toolIndexes[*event.OutputIndex]
+			if !ok {
+				return true
+			}
+			toolCall := dto.ToolCallResponse{Function: dto.FunctionResponse{Arguments: event.Delta}}
+			toolCall.SetIndex(toolIndex)
+			responseText.WriteString(event.Delta)
+			sendDelta(dto.ChatCompletionsStreamResponseChoiceDelta{ToolCalls: []dto.ToolCallResponse{toolCall}})
+		case dto.ResponsesStreamTypeCompleted, dto.ResponsesStreamTypeIncomplete:
+			incomplete = event.Type == dto.ResponsesStreamTypeIncomplete
+			if event.Response != nil && event.Response.Usage != nil {
+				responsesUsage := service.ResponsesUsage2OpenAI(event.Response.Usage)
+				usage = &responsesUsage
+			}
+		}
+		return true
+	})
+
+	if usage == nil || usage.TotalTokens == 0 {
+		usage = service.ResponseText2Usage(responseText.String(), info.UpstreamModelName, info.PromptTokens)
+	}
+	finishReason := constant.FinishReasonStop
+	if incomplete {
+		finishReason = constant.FinishReasonLength
+	} else if len(toolIndexes) > 0 {
+		finishReason = constant.FinishReasonToolCalls
+	}
+	helper.RecordFinishReason(info, finishReason, usage.CompletionTokens)
+
+	_ = helper.ObjectData(c, helper.GenerateStopResponse(responseId, createAt, model, finishReason))
+	if info.ShouldIncludeUsage {
+		_ = helper.ObjectData(c, helper.GenerateFinalUsageResponse(responseId, createAt, model, *usage))
+	}
+	helper.Done(c)
+	return nil, usage
+}
+
+// OaiChatToResponsesHandler converts a non-streaming chat completion to a
+// responses API response for a client that called /v1/responses.
+func OaiChatToResponsesHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
+	defer common.CloseResponseBodyGracefully(resp)
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError), nil
+	}
+	responseBody = helper.RepairUpstreamJson(c, responseBody)
+	var simpleResponse dto.OpenAITextResponse
+	if err = common.UnmarshalJson(responseBody, &simpleResponse); err != nil {
+		return service.OpenAIErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError), nil
+	}
+	if simpleResponse.Error != nil && simpleResponse.Error.Type != "" {
+		return &dto.OpenAIErrorWithStatusCode{
+			Error:      *simpleResponse.Error,
+			StatusCode: resp.StatusCode,
+		}, nil
+	}
+	if simpleResponse.Usage.TotalTokens == 0 || (simpleResponse.Usage.PromptTokens == 0 && simpleResponse.Usage.CompletionTokens == 0) {
+		completionTokens := 0
+		for _, choice := range simpleResponse.Choices {
+			completionTokens += service.CountTextToken(choice.Message.StringContent()+choice.Message.ReasoningContent+choice.Message.Reasoning, info.UpstreamModelName)
+		}
+		simpleResponse.Usage = dto.Usage{
+			PromptTokens:     info.PromptTokens,
+			CompletionTokens: completionTokens,
+			TotalTokens:      info.PromptTokens + completionTokens,
+		}
+	}
+	for _, choice := range simpleResponse.Choices {
+		helper.RecordFinishReason(info, choice.FinishReason, simpleResponse.Usage.CompletionTokens)
+	}
+	if restoreModel := requestModelToRestore(info); restoreModel != "" {
+		simpleResponse.Model = restoreModel
+	}
+
+	responseBody, err = common.EncodeJson(service.ResponseOpenAI2Responses(&simpleResponse))
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "marshal_response_body_failed", http.StatusInternalServerError), nil
+	}
+	c.Data(http.StatusOK, "application/json", responseBody)
+	return nil, &simpleResponse.Usage
+}
+
+// chatToResponsesStream turns chat completion chunks into responses API
+// stream events. Output items are numbered in the order they first appear.
+type chatToResponsesStream struct {
+	c          *gin.Context
+	response   dto.OpenAIResponsesResponse
+	text       strings.Builder
+	textIndex  int
+	toolCalls  []*dto.ResponsesOutput
+	toolIndex  []int
+	nextIndex  int
+	hasMessage bool
+}
+
+func (s *chatToResponsesStream) send(event dto.ResponsesStreamResponse) {
+	data, err := common.EncodeJson(event)
+	if err != nil {
+		common.SysError("error marshalling responses stream event: " + err.Error())
+		return
+	}
+	helper.ResponseChunkData(s.c, event, string(data))
+}
+
+func (s *chatToResponsesStream) messageItem(status string) *dto.ResponsesOutput {
+	item := &dto.ResponsesOutput{
+		Type:    dto.ResponsesOutputItemMessage,
+		ID:      "msg_" + s.response.ID,
+		Status:  status,
+		Role:    "assistant",
+		Content: []dto.ResponsesOutputContent{},
+	}
+	if status == "completed" {
+		item.Content = append(item.Content, dto.ResponsesOutputContent{Type: "output_text", Text: s.text.String(), Annotations: []interface{}{}})
+	}
+	return item
+}
+
+func (s *chatToResponsesStream) appendText(text string) {
+	if !s.hasMessage {
+		s.hasMessage = true
+		s.textIndex = s.nextIndex
+		s.nextIndex++
+		s.send(dto.ResponsesStreamResponse{Type: dto.ResponsesOutputTypeItemAdded, OutputIndex: common.GetPointer(s.textIndex), Item: s.messageItem("in_progress")})
+		s.send(dto.ResponsesStreamResponse{
+			Type:         dto.ResponsesStreamTypeContentPartAdded,
+			ItemId:       "msg_" + s.response.ID,
+			OutputIndex:  common.GetPointer(s.textIndex),
+			ContentIndex: common.GetPointer(0),
+			Part:         &dto.ResponsesOutputContent{Type: "output_text", Annotations: []interface{}{}},
+		})
+	}
+	s.text.WriteString(text)
+	s.send(dto.ResponsesStreamResponse{
+		Type:         dto.ResponsesStreamTypeOutputTextDelta,
+		ItemId:       "msg_" + s.response.ID,
+		OutputIndex:  common.GetPointer(s.textIndex),
+		ContentIndex: common.GetPointer(0),
+		Delta:        text,
+	})
+}
+
+func (s *chatToResponsesStream) appendToolCall(toolCall dto.ToolCallResponse) {
+	index := len(s.toolCalls)
+	if toolCall.Index != nil {
+		index = *toolCall.Index
+	}
+	for len(s.toolCalls) <= index {
+		s.toolCalls = append(s.toolCalls, nil)
+		s.toolIndex = append(s.toolIndex, 0)
+	}
+	item := s.toolCalls[index]
+	if item == nil {
+		item = &dto.ResponsesOutput{
+			Type:   dto.ResponsesOutputItemFunctionCall,
+			ID:     "fc_" + toolCall.ID,
+			Status: "in_progress",
+			CallId: toolCall.ID,
+			Name:   toolCall.Function.Name,
+		}
+		s.toolCalls[index] = item
+		s.toolIndex[index] = s.nextIndex
+		s.nextIndex++
+		s.send(dto.ResponsesStreamResponse{Type: dto.ResponsesOutputTypeItemAdded, OutputIndex: common.GetPointer(s.toolIndex[index]), Item: item})
+	}
+	if toolCall.Function.Arguments != "" {
+		item.Arguments += toolCall.Function.Arguments
+		s.send(dto.ResponsesStreamResponse{
+			Type:        dto.ResponsesStreamTypeFunctionArgumentsDelta,
+			ItemId:      item.ID,
+			OutputIndex: common.GetPointer(s.toolIndex[index]),
+			Delta:       toolCall.Function.Arguments,
+		})
+	}
+}
+
+// finish closes the open output items and sends the completed response.
+func (s *chatToResponsesStream) finish(finishReason string, usage *dto.Usage) {
+	output := make([]dto.ResponsesOutput, s.nextIndex)
+	if s.hasMessage {
+		item := s.messageItem("completed")
+		s.send(dto.ResponsesStreamResponse{Type: dto.ResponsesStreamTypeOutputTextDone, ItemId: item.ID, OutputIndex: common.GetPointer(s.textIndex), ContentIndex: common.GetPointer(0), Text: s.text.String()})
+		s.send(dto.ResponsesStreamResponse{Type: dto.ResponsesStreamTypeContentPartDone, ItemId: item.ID, OutputIndex: common.GetPointer(s.textIndex), ContentIndex: common.GetPointer(0), Part: &item.Content[0]})
+		s.send(dto.ResponsesStreamResponse{Type: dto.ResponsesOutputTypeItemDone, OutputIndex: common.GetPointer(s.textIndex), Item: item})
+		output[s.textIndex] = *item
+	}
+	for i, item := range s.toolCalls {
+		if item == nil {
+			continue
+		}
+		item.Status = "completed"
+		s.send(dto.ResponsesStreamResponse{Type: dto.ResponsesStreamTypeFunctionArgumentsDone, ItemId: item.ID, OutputIndex: common.GetPointer(s.toolIndex[i]), Arguments: item.Arguments})
+		s.send(dto.ResponsesStreamResponse{Type: dto.ResponsesOutputTypeItemDone, OutputIndex: common.GetPointer(s.toolIndex[i]), Item: item})
+		output[s.toolIndex[i]] = *item
+	}
+
+	s.response.Output = output
+	s.response.Status = "completed"
+	s.response.Usage = service.OpenAIUsage2Responses(usage)
+	eventType := dto.ResponsesStreamTypeCompleted
+	if finishReason == constant.FinishReasonLength {
+		s.response.Status = "incomplete"
+		s.response.IncompleteDetails = &dto.IncompleteDetails{Reasoning: "max_output_tokens"}
+		eventType = dto.ResponsesStreamTypeIncomplete
+	}
+	s.send(dto.ResponsesStreamResponse{Type: eventType, Response: &s.response})
+}
+
+// OaiChatToResponsesStreamHandler converts chat completion chunks to
+// responses API stream events for a client that called /v1/responses.
+func OaiChatToResponsesStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
+	if resp == nil || resp.Body == nil {
+		common.LogError(c, "invalid response or response body")
+		return service.OpenAIErrorWrapper(fmt.Errorf("invalid response"), "invalid_response", http.StatusInternalServerError), nil
+	}
+
+	stream := &chatToResponsesStream{c: c}
+	stream.response = dto.OpenAIResponsesResponse{
+		ID:        "resp_" + helper.GetResponseID(c),
+		Object:    "response",
+		CreatedAt: int(common.GetTimestamp()),
+		Status:    "in_progress",
+		Model:     info.UpstreamModelName,
+		Output:    make([]dto.ResponsesOutput, 0),
+	}
+	if restoreModel := requestModelToRestore(info); restoreModel != "" {
+		stream.response.Model = restoreModel
+	}
+	var (
+		usage        *dto.Usage
+		finishReason string
+		started      bool
+		responseText strings.Builder
+		toolCount    int
+	)
+
+	helper.StreamScannerHandler(c, resp, info, func(data string) bool {
+		var chunk dto.ChatCompletionsStreamResponse
+		if err := common.UnmarshalJsonStr(data, &chunk); err != nil {
+			common.SysError("error unmarshalling stream response: " + err.Error())
+			return true
+		}
+		if !started {
+			started = true
+			created := stream.response
+			stream.send(dto.ResponsesStreamResponse{Type: dto.ResponsesStreamTypeCreated, Response: &created})
+		}
+		if service.ValidUsage(chunk.Usage) {
+			usage = chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Index != 0 {
+				continue
+			}
+			if content := choice.Delta.GetContentString(); content != "" {
+				responseText.WriteString(content)
+				stream.appendText(content)
+			}
+			responseText.WriteString(choice.Delta.GetReasoningContent())
+			for _, toolCall := range choice.Delta.ToolCalls {
+				responseText.WriteString(toolCall.Function.Name + toolCall.Function.Arguments)
+				stream.appendToolCall(toolCall)
+			}
+			if choice.FinishReason != nil && *choice.FinishReason != "" {
+				finishReason = *choice.FinishReason
+			}
+		}
+		return true
+	})
+
+	for _, item := range stream.toolCalls {
+		if item != nil {
+			toolCount++
+		}
+	}
+	if usage == nil {
+		usage = service.ResponseText2Usage(responseText.String(), info.UpstreamModelName, info.PromptTokens)
+		usage.CompletionTokens += toolCount * 7
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	helper.RecordFinishReason(info, finishReason, usage.CompletionTokens)
+	if !started {
+		created := stream.response
+		stream.send(dto.ResponsesStreamResponse{Type: dto.ResponsesStreamTypeCreated, Response: &created})
+	}
+	stream.finish(finishReason, usage)
+	return nil, usage
+}
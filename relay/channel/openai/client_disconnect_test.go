@@ -0,0 +1,112 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// disconnectingWriter cancels the client request after a number of data
+// frames have been delivered, as if the client went away mid-stream.
+type disconnectingWriter struct {
+	*httptest.ResponseRecorder
+	frames int
+	after  int
+	cancel context.CancelFunc
+}
+
+func (w *disconnectingWriter) Write(data []byte) (int, error) {
+	n, err := w.ResponseRecorder.Write(data)
+	if strings.HasPrefix(string(data), "data: {") {
+		w.frames++
+		if w.frames == w.after {
+			w.cancel()
+		}
+	}
+	return n, err
+}
+
+func TestClientDisconnectCancelsUpstreamStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service.InitHttpClient()
+	service.InitTokenEncoders()
+	streamingTimeout := constant.StreamingTimeout
+	constant.StreamingTimeout = 60
+	t.Cleanup(func() { constant.StreamingTimeout = streamingTimeout })
+
+	contents := []string{"Hello", " there", " my", " old", " friend"}
+	upstreamCancelled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		for _, content := range contents {
+			_, _ = fmt.Fprintf(w, "data: {\"id\":\"1\",\"model\":\"gpt-4o\",\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", content)
+			w.(http.Flusher).Flush()
+		}
+		// 其余内容迟迟未生成，直到请求被取消
+		select {
+		case <-r.Context().Done():
+			close(upstreamCancelled)
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	writer := &disconnectingWriter{ResponseRecorder: httptest.NewRecorder(), after: 2, cancel: cancel}
+	c, _ := gin.CreateTestContext(writer)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil).WithContext(ctx)
+	info := &relaycommon.RelayInfo{RelayFormat: relaycommon.RelayFormatOpenAI, RelayMode: relayconstant.RelayModeChatCompletions,
+		ChannelType: constant.ChannelTypeOpenAI, BaseUrl: server.URL, RequestURLPath: "/v1/chat/completions", ApiKey: "sk-test",
+		IsStream: true, OriginModelName: "gpt-4o", UpstreamModelName: "gpt-4o", StartTime: time.Now()}
+
+	adaptor := &Adaptor{}
+	resp, err := adaptor.DoRequest(c, info, strings.NewReader(`{"model":"gpt-4o","stream":true}`))
+	if err != nil {
+		t.Fatalf("DoRequest() error = %v", err)
+	}
+	_, usage := OaiStreamHandler(c, resp.(*http.Response), info)
+
+	select {
+	case <-upstreamCancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("upstream request was not cancelled after the client disconnected")
+	}
+
+	// 只按已送达客户端的内容计费
+	var delivered strings.Builder
+	for _, line := range strings.Split(writer.Body.String(), "\n") {
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+		}
+		if common.UnmarshalJsonStr(strings.TrimPrefix(line, "data: "), &chunk) == nil && len(chunk.Choices) > 0 {
+			delivered.WriteString(chunk.Choices[0].Delta.Content)
+		}
+	}
+	full := strings.Join(contents, "")
+	if delivered.Len() == 0 || delivered.Len() >= len(full) || !strings.HasPrefix(full, delivered.String()) {
+		t.Fatalf("delivered content = %q, want a part of %q cut at the disconnect", delivered.String(), full)
+	}
+	if usage == nil {
+		t.Fatal("OaiStreamHandler() usage = nil")
+	}
+	if want := service.CountTextToken(delivered.String(), info.UpstreamModelName); usage.CompletionTokens != want {
+		t.Errorf("completion tokens = %d, want %d for the delivered content", usage.CompletionTokens, want)
+	}
+}
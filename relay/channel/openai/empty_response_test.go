@@ -0,0 +1,72 @@
+package openai
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOpenaiHandlerEmptyResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service.InitTokenEncoders()
+	retrySetting := operation_setting.GetRetrySetting()
+	saved := retrySetting.RetryOnEmptyResponse
+	t.Cleanup(func() { retrySetting.RetryOnEmptyResponse = saved })
+
+	const emptyBody = `{"id":"x","object":"chat.completion","choices":[{"index":0,` +
+		`"message":{"role":"assistant","content":""},"finish_reason":"stop"}],"usage":{"prompt_tokens":10,"completion_tokens":0,"total_tokens":10}}`
+	tests := []struct {
+		name      string
+		enabled   bool
+		isStream  bool
+		body      string
+		wantRetry bool
+	}{
+		{name: "empty response is retried", enabled: true, body: emptyBody, wantRetry: true},
+		{name: "retry disabled", body: emptyBody},
+		{name: "streaming request is not retried", enabled: true, isStream: true, body: emptyBody},
+		{name: "text content", enabled: true, body: `{"id":"x","choices":[{"index":0,"message":{"role":"assistant","content":"hi"},` +
+			`"finish_reason":"stop"}],"usage":{"prompt_tokens":10,"completion_tokens":0,"total_tokens":10}}`},
+		{name: "tool calls only", enabled: true, body: `{"id":"x","choices":[{"index":0,"message":{"role":"assistant","content":null,` +
+			`"tool_calls":[{"id":"call_1","type":"function","function":{"name":"f","arguments":"{}"}}]},"finish_reason":"tool_calls"}],` +
+			`"usage":{"prompt_tokens":10,"completion_tokens":0,"total_tokens":10}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retrySetting.RetryOnEmptyResponse = tt.enabled
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+			info := &relaycommon.RelayInfo{IsStream: tt.isStream, RelayFormat: relaycommon.RelayFormatOpenAI}
+			resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(tt.body))}
+
+			openaiErr, _ := OpenaiHandler(c, resp, info)
+			if !tt.wantRetry {
+				if openaiErr != nil {
+					t.Fatalf("OpenaiHandler() error = %v", openaiErr.Error)
+				}
+				if recorder.Body.Len() == 0 {
+					t.Error("response was not written to the client")
+				}
+				return
+			}
+			if openaiErr == nil || openaiErr.Error.Code != helper.ErrorCodeEmptyResponse || openaiErr.StatusCode != http.StatusBadGateway {
+				t.Fatalf("OpenaiHandler() error = %v, want a retryable %s", openaiErr, helper.ErrorCodeEmptyResponse)
+			}
+			if openaiErr.LocalError {
+				t.Error("empty response error is local, the relay would not retry it")
+			}
+			if recorder.Body.Len() != 0 {
+				t.Errorf("empty response was written to the client: %q", recorder.Body.String())
+			}
+		})
+	}
+}
@@ -46,9 +46,8 @@ func ProcessStreamResponse(streamResponse dto.ChatCompletionsStreamResponse, res
 		responseTextBuilder.WriteString(choice.Delta.GetContentString())
 		responseTextBuilder.WriteString(choice.Delta.GetReasoningContent())
 		if choice.Delta.ToolCalls != nil {
-			if len(choice.Delta.ToolCalls) > *toolCount {
-				*toolCount = len(choice.Delta.ToolCalls)
-			}
+			updateToolCount(choice.Delta.ToolCalls, toolCount)
+			// 工具调用参数以增量形式返回，需要全部计入补全 token
 			for _, tool := range choice.Delta.ToolCalls {
 				responseTextBuilder.WriteString(tool.Function.Name)
 				responseTextBuilder.WriteString(tool.Function.Arguments)
@@ -58,6 +57,20 @@ func ProcessStreamResponse(streamResponse dto.ChatCompletionsStreamResponse, res
 	return nil
 }
 
+// updateToolCount tracks the number of distinct tool calls in a stream. Parallel
+// tool calls usually arrive one per chunk, so the count is derived from the
+// highest index seen rather than the size of a single delta.
+func updateToolCount(toolCalls []dto.ToolCallResponse, toolCount *int) {
+	if len(toolCalls) > *toolCount {
+		*toolCount = len(toolCalls)
+	}
+	for _, tool := range toolCalls {
+		if tool.Index != nil && *tool.Index+1 > *toolCount {
+			*toolCount = *tool.Index + 1
+		}
+	}
+}
+
 func processTokens(relayMode int, streamItems []string, responseTextBuilder *strings.Builder, toolCount *int) error {
 	streamResp := "[" + strings.Join(streamItems, ",") + "]"
 
@@ -70,6 +83,53 @@ func processTokens(relayMode int, streamItems []string, responseTextBuilder *str
 	return nil
 }
 
+// isEmptyTextResponse 响应中没有任何文本、推理内容或工具调用
+func isEmptyTextResponse(response *dto.OpenAITextResponse) bool {
+	for _, choice := range response.Choices {
+		if choice.Message.StringContent() != "" || choice.Message.ReasoningContent != "" ||
+			choice.Message.Reasoning != "" || len(choice.Message.ToolCalls) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// streamFinishReason 从后往前查找流式响应中的 finish_reason
+func streamFinishReason(streamItems []string) string {
+	for i := len(streamItems) - 1; i >= 0; i-- {
+		var streamResponse dto.ChatCompletionsStreamResponse
+		if err := json.Unmarshal(common.StringToByteSlice(streamItems[i]), &streamResponse); err != nil {
+			continue
+		}
+		for _, choice := range streamResponse.Choices {
+			if choice.FinishReason != nil && *choice.FinishReason != "" {
+				return *choice.FinishReason
+			}
+		}
+	}
+	return ""
+}
+
+// streamRefusal 拼接流式响应中的 refusal 内容
+func streamRefusal(streamItems []string) string {
+	var refusal strings.Builder
+	for _, item := range streamItems {
+		if !strings.Contains(item, `"refusal"`) {
+			continue
+		}
+		var streamResponse dto.ChatCompletionsStreamResponse
+		if err := json.Unmarshal(common.StringToByteSlice(item), &streamResponse); err != nil {
+			continue
+		}
+		for _, choice := range streamResponse.Choices {
+			if choice.Delta.Refusal != nil {
+				refusal.WriteString(*choice.Delta.Refusal)
+			}
+		}
+	}
+	return refusal.String()
+}
+
 func processChatCompletions(streamResp string, streamItems []string, responseTextBuilder *strings.Builder, toolCount *int) error {
 	var streamResponses []dto.ChatCompletionsStreamResponse
 	if err := json.Unmarshal(common.StringToByteSlice(streamResp), &streamResponses); err != nil {
@@ -89,18 +149,8 @@ func processChatCompletions(streamResp string, streamItems []string, responseTex
 
 	// 批量处理所有响应
 	for _, streamResponse := range streamResponses {
-		for _, choice := range streamResponse.Choices {
-			responseTextBuilder.WriteString(choice.Delta.GetContentString())
-			responseTextBuilder.WriteString(choice.Delta.GetReasoningContent())
-			if choice.Delta.ToolCalls != nil {
-				if len(choice.Delta.ToolCalls) > *toolCount {
-					*toolCount = len(choice.Delta.ToolCalls)
-				}
-				for _, tool := range choice.Delta.ToolCalls {
-					responseTextBuilder.WriteString(tool.Function.Name)
-					responseTextBuilder.WriteString(tool.Function.Arguments)
-				}
-			}
+		if err := ProcessStreamResponse(streamResponse, responseTextBuilder, toolCount); err != nil {
+			common.SysError("error processing stream response: " + err.Error())
 		}
 	}
 	return nil
@@ -132,6 +182,41 @@ func processCompletions(streamResp string, streamItems []string, responseTextBui
 	return nil
 }
 
+// replaceResponseModel replaces the top-level "model" field of a JSON chunk
+// and keeps the rest of it byte for byte. Chunks without a string model field
+// are returned unchanged.
+func replaceResponseModel(data string, modelName string) string {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return data
+	}
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return data
+		}
+		// 键之后的偏移量包含冒号与空白，替换时一并重写
+		start := decoder.InputOffset()
+		if key != "model" {
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return data
+			}
+			continue
+		}
+		var current string
+		if err := decoder.Decode(&current); err != nil {
+			return data
+		}
+		quoted, err := json.Marshal(modelName)
+		if err != nil {
+			return data
+		}
+		return data[:start] + ":" + string(quoted) + data[decoder.InputOffset():]
+	}
+	return data
+}
+
 func handleLastResponse(lastStreamData string, responseId *string, createAt *int64,
 	systemFingerprint *string, model *string, usage **dto.Usage,
 	containStreamUsage *bool, info *relaycommon.RelayInfo,
@@ -0,0 +1,45 @@
+package openai
+
+import (
+	relayconstant "one-api/relay/constant"
+	"strings"
+	"testing"
+)
+
+func TestProcessTokensCountsStreamedToolCalls(t *testing.T) {
+	// 并行工具调用逐块返回，每块只包含一个调用的增量
+	toolCallItems := []string{
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"a","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"b","type":"function","function":{"name":"get_time","arguments":""}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"function":{"arguments":"{\"tz\":\"CET\"}"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+	}
+	tests := []struct {
+		name    string
+		items   []string
+		wantErr bool
+	}{
+		{name: "batch", items: toolCallItems},
+		// 存在无法解析的数据块时逐条处理，遇到该数据块时停止
+		{name: "per item", items: append(append([]string{}, toolCallItems...), `{"choices":[`), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var responseTextBuilder strings.Builder
+			toolCount := 0
+			err := processTokens(relayconstant.RelayModeChatCompletions, tt.items, &responseTextBuilder, &toolCount)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("processTokens() error = %v, want error %v", err, tt.wantErr)
+			}
+			if toolCount != 2 {
+				t.Errorf("tool count = %d, want 2", toolCount)
+			}
+			want := `get_weather{"city":"Paris"}get_time{"tz":"CET"}`
+			if got := responseTextBuilder.String(); got != want {
+				t.Errorf("response text = %s, want %s", got, want)
+			}
+		})
+	}
+}
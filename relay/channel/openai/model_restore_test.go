@@ -0,0 +1,88 @@
+package openai
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"one-api/constant"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReplaceResponseModel(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "compact chunk", data: `{"id":"1","model":"gpt-4o","choices":[]}`,
+			want: `{"id":"1","model":"OpenAI/GPT-4o","choices":[]}`},
+		{name: "spaces around the colon", data: `{"id": "1", "model" : "gpt-4o", "choices": []}`,
+			want: `{"id": "1", "model":"OpenAI/GPT-4o", "choices": []}`},
+		{name: "nested model fields are kept", data: `{"meta":{"model":"x"},"model":"gpt-4o"}`,
+			want: `{"meta":{"model":"x"},"model":"OpenAI/GPT-4o"}`},
+		{name: "chunk without a model", data: `{"id":"1"}`, want: `{"id":"1"}`},
+		{name: "not JSON", data: `[DONE]`, want: `[DONE]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := replaceResponseModel(tt.data, "OpenAI/GPT-4o"); got != tt.want {
+				t.Errorf("replaceResponseModel() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlersRestoreRequestModelName(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service.InitTokenEncoders()
+	streamingTimeout := constant.StreamingTimeout
+	constant.StreamingTimeout = 60
+	t.Cleanup(func() { constant.StreamingTimeout = streamingTimeout })
+
+	const streamBody = "data: {\"id\":\"1\",\"model\":\"gpt-4o-2024-08-06\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"}}]}\n\n" +
+		"data: {\"id\":\"1\",\"model\":\"gpt-4o-2024-08-06\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n" +
+		"data: [DONE]\n\n"
+	const body = `{"id":"1","model":"gpt-4o-2024-08-06","choices":[{"index":0,"message":{"role":"assistant","content":"hi"},` +
+		`"finish_reason":"stop"}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`
+	tests := []struct {
+		name         string
+		requestModel string
+		stream       bool
+		want         string
+	}{
+		{name: "non-stream prefixed name", requestModel: "OpenAI/gpt-4o", want: `"model":"OpenAI/gpt-4o"`},
+		{name: "stream mixed-case name", requestModel: "GPT-4o", stream: true, want: `"model":"GPT-4o"`},
+		{name: "already normalized name keeps the upstream model", requestModel: "gpt-4o", stream: true, want: `"model":"gpt-4o-2024-08-06"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			info := &relaycommon.RelayInfo{RelayFormat: relaycommon.RelayFormatOpenAI, RelayMode: relayconstant.RelayModeChatCompletions,
+				IsStream: tt.stream, ShouldIncludeUsage: true, RequestModelName: tt.requestModel, OriginModelName: "gpt-4o", UpstreamModelName: "gpt-4o"}
+			upstream := body
+			if tt.stream {
+				upstream = streamBody
+			}
+			resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(upstream))}
+
+			if tt.stream {
+				OaiStreamHandler(c, resp, info)
+			} else if openaiErr, _ := OpenaiHandler(c, resp, info); openaiErr != nil {
+				t.Fatalf("OpenaiHandler() error = %v", openaiErr.Error)
+			}
+
+			got := recorder.Body.String()
+			if count := strings.Count(got, `"model":`); count == 0 || strings.Count(got, tt.want) != count {
+				t.Errorf("response = %s, want every model field to be %s", got, tt.want)
+			}
+		})
+	}
+}
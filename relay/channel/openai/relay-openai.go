@@ -13,6 +13,7 @@ import (
 	relaycommon "one-api/relay/common"
 	"one-api/relay/helper"
 	"one-api/service"
+	"one-api/setting/model_setting"
 	"os"
 	"path/filepath"
 	"strings"
@@ -28,6 +29,10 @@ func sendStreamData(c *gin.Context, info *relaycommon.RelayInfo, data string, fo
 		return nil
 	}
 
+	if restoreModel := requestModelToRestore(info); restoreModel != "" {
+		data = replaceResponseModel(data, restoreModel)
+	}
+
 	if !forceFormat && !thinkToContent {
 		return helper.StringData(c, data)
 	}
@@ -104,6 +109,18 @@ func sendStreamData(c *gin.Context, info *relaycommon.RelayInfo, data string, fo
 	return helper.ObjectData(c, lastStreamResponse)
 }
 
+// requestModelToRestore returns the client's model name when it was changed
+// by normalization and should be shown in the response instead.
+func requestModelToRestore(info *relaycommon.RelayInfo) string {
+	if !model_setting.GetGlobalSettings().RestoreRequestModelName {
+		return ""
+	}
+	if info.RequestModelName == "" || info.RequestModelName == info.OriginModelName {
+		return ""
+	}
+	return info.RequestModelName
+}
+
 func OaiStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
 	if resp == nil || resp.Body == nil {
 		common.LogError(c, "invalid response or response body")
@@ -154,6 +171,9 @@ func OaiStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 		&containStreamUsage, info, &shouldSendLastResp); err != nil {
 		common.SysError("error handling last response: " + err.Error())
 	}
+	if restoreModel := requestModelToRestore(info); restoreModel != "" {
+		model = restoreModel
+	}
 
 	if shouldSendLastResp && info.RelayFormat == relaycommon.RelayFormatOpenAI {
 		_ = sendStreamData(c, info, lastStreamData, forceFormat, thinkToContent)
@@ -175,6 +195,10 @@ func OaiStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 		}
 	}
 
+	finishReason := streamFinishReason(streamItems)
+	helper.RecordFinishReason(info, finishReason, usage.CompletionTokens)
+	helper.RecordRefusal(info, finishReason, streamRefusal(streamItems), responseTextBuilder.String(), usage.CompletionTokens)
+
 	handleFinalResponse(c, info, lastStreamData, responseId, createAt, model, systemFingerprint, usage, containStreamUsage)
 
 	return nil, usage
@@ -188,6 +212,9 @@ func OpenaiHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayI
 	if err != nil {
 		return service.OpenAIErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError), nil
 	}
+	responseBody = helper.RepairUpstreamJson(c, responseBody)
+	responseBody = helper.RedactOpenAIResponse(c, responseBody, "message")
+	responseBody = helper.DowngradeToolCallsResponse(c, responseBody, "message")
 	err = common.UnmarshalJson(responseBody, &simpleResponse)
 	if err != nil {
 		return service.OpenAIErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError), nil
@@ -204,6 +231,12 @@ func OpenaiHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayI
 		forceFormat = true
 	}
 
+	if simpleResponse.Usage.CompletionTokens == 0 && isEmptyTextResponse(&simpleResponse) {
+		if emptyErr := helper.EmptyResponseError(info); emptyErr != nil {
+			return emptyErr, nil
+		}
+	}
+
 	if simpleResponse.Usage.TotalTokens == 0 || (simpleResponse.Usage.PromptTokens == 0 && simpleResponse.Usage.CompletionTokens == 0) {
 		completionTokens := 0
 		for _, choice := range simpleResponse.Choices {
@@ -217,6 +250,19 @@ func OpenaiHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayI
 		}
 	}
 
+	for _, choice := range simpleResponse.Choices {
+		helper.RecordFinishReason(info, choice.FinishReason, simpleResponse.Usage.CompletionTokens)
+		helper.RecordRefusal(info, choice.FinishReason, choice.Message.Refusal, choice.Message.StringContent(), simpleResponse.Usage.CompletionTokens)
+	}
+	if truncationErr := helper.TruncationRetryError(c, info, simpleResponse.Usage.CompletionTokens); truncationErr != nil {
+		return truncationErr, &simpleResponse.Usage
+	}
+
+	if restoreModel := requestModelToRestore(info); restoreModel != "" {
+		simpleResponse.Model = restoreModel
+		forceFormat = true
+	}
+
 	switch info.RelayFormat {
 	case relaycommon.RelayFormatOpenAI:
 		if forceFormat {
@@ -268,10 +314,11 @@ func OpenaiSTTHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 	defer common.CloseResponseBodyGracefully(resp)
 
 	// count tokens by audio file duration
-	audioTokens, err := countAudioTokens(c)
+	audioTokens, duration, err := countAudioTokens(c)
 	if err != nil {
 		return service.OpenAIErrorWrapper(err, "count_audio_tokens_failed", http.StatusInternalServerError), nil
 	}
+	info.AudioDurationSeconds = duration
 	responseBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return service.OpenAIErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError), nil
@@ -286,10 +333,11 @@ func OpenaiSTTHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 	return nil, usage
 }
 
-func countAudioTokens(c *gin.Context) (int, error) {
+// countAudioTokens returns the token count and duration in seconds of the uploaded audio file.
+func countAudioTokens(c *gin.Context) (int, float64, error) {
 	body, err := common.GetRequestBody(c)
 	if err != nil {
-		return 0, errors.WithStack(err)
+		return 0, 0, errors.WithStack(err)
 	}
 
 	var reqBody struct {
@@ -297,35 +345,35 @@ func countAudioTokens(c *gin.Context) (int, error) {
 	}
 	c.Request.Body = io.NopCloser(bytes.NewReader(body))
 	if err = c.ShouldBind(&reqBody); err != nil {
-		return 0, errors.WithStack(err)
+		return 0, 0, errors.WithStack(err)
 	}
 	ext := filepath.Ext(reqBody.File.Filename) // 获取文件扩展名
 	reqFp, err := reqBody.File.Open()
 	if err != nil {
-		return 0, errors.WithStack(err)
+		return 0, 0, errors.WithStack(err)
 	}
 	defer reqFp.Close()
 
 	tmpFp, err := os.CreateTemp("", "audio-*"+ext)
 	if err != nil {
-		return 0, errors.WithStack(err)
+		return 0, 0, errors.WithStack(err)
 	}
 	defer os.Remove(tmpFp.Name())
 
 	_, err = io.Copy(tmpFp, reqFp)
 	if err != nil {
-		return 0, errors.WithStack(err)
+		return 0, 0, errors.WithStack(err)
 	}
 	if err = tmpFp.Close(); err != nil {
-		return 0, errors.WithStack(err)
+		return 0, 0, errors.WithStack(err)
 	}
 
 	duration, err := common.GetAudioDuration(c.Request.Context(), tmpFp.Name(), ext)
 	if err != nil {
-		return 0, errors.WithStack(err)
+		return 0, 0, errors.WithStack(err)
 	}
 
-	return int(math.Round(math.Ceil(duration) / 60.0 * 1000)), nil // 1 minute 相当于 1k tokens
+	return int(math.Round(math.Ceil(duration) / 60.0 * 1000)), duration, nil // 1 minute 相当于 1k tokens
 }
 
 func OpenaiRealtimeHandler(c *gin.Context, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.RealtimeUsage) {
@@ -46,6 +46,9 @@ func OaiResponsesHandler(c *gin.Context, resp *http.Response, info *relaycommon.
 	usage.PromptTokens = responsesResponse.Usage.InputTokens
 	usage.CompletionTokens = responsesResponse.Usage.OutputTokens
 	usage.TotalTokens = responsesResponse.Usage.TotalTokens
+	if responsesResponse.Usage.OutputTokensDetails != nil {
+		usage.CompletionTokenDetails.ReasoningTokens = responsesResponse.Usage.OutputTokensDetails.ReasoningTokens
+	}
 	// 解析 Tools 用量
 	for _, tool := range responsesResponse.Tools {
 		info.ResponsesUsageInfo.BuiltInTools[tool.Type].CallCount++
@@ -73,6 +76,9 @@ func OaiResponsesStreamHandler(c *gin.Context, resp *http.Response, info *relayc
 				usage.PromptTokens = streamResponse.Response.Usage.InputTokens
 				usage.CompletionTokens = streamResponse.Response.Usage.OutputTokens
 				usage.TotalTokens = streamResponse.Response.Usage.TotalTokens
+				if streamResponse.Response.Usage.OutputTokensDetails != nil {
+					usage.CompletionTokenDetails.ReasoningTokens = streamResponse.Response.Usage.OutputTokensDetails.ReasoningTokens
+				}
 			case "response.output_text.delta":
 				// 处理输出文本
 				responseTextBuilder.WriteString(streamResponse.Delta)
@@ -0,0 +1,281 @@
+package openai
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"one-api/service"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamChoiceBuffer accumulates the deltas of one choice of a streamed chat
+// completion.
+type streamChoiceBuffer struct {
+	content      strings.Builder
+	reasoning    strings.Builder
+	refusal      strings.Builder
+	toolCalls    []dto.ToolCallResponse
+	finishReason string
+}
+
+func (b *streamChoiceBuffer) appendToolCalls(toolCalls []dto.ToolCallResponse) {
+	for _, toolCall := range toolCalls {
+		index := len(b.toolCalls)
+		if toolCall.Index != nil {
+			index = *toolCall.Index
+		}
+		for len(b.toolCalls) <= index {
+			b.toolCalls = append(b.toolCalls, dto.ToolCallResponse{Type: "function"})
+		}
+		merged := &b.toolCalls[index]
+		if toolCall.ID != "" {
+			merged.ID = toolCall.ID
+		}
+		if toolCall.Type != nil && toolCall.Type != "" {
+			merged.Type = toolCall.Type
+		}
+		if toolCall.Function.Name != "" {
+			merged.Function.Name = toolCall.Function.Name
+		}
+		merged.Function.Arguments += toolCall.Function.Arguments
+	}
+}
+
+// OaiStreamToNonStreamHandler buffers a streamed upstream chat completion
+// into a single chat.completion response, for models forced to stream
+// upstream while the client asked for a non-streaming response.
+func OaiStreamToNonStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
+	defer common.CloseResponseBodyGracefully(resp)
+
+	var (
+		response = dto.OpenAITextResponse{Object: "chat.completion"}
+		choices  = map[int]*streamChoiceBuffer{}
+		usage    *dto.Usage
+	)
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, helper.InitialScannerBufferSize), helper.MaxScannerBufferSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+		var chunk dto.ChatCompletionsStreamResponse
+		if err := common.UnmarshalJsonStr(data, &chunk); err != nil {
+			common.SysError("error unmarshalling stream response: " + err.Error())
+			continue
+		}
+		if chunk.Id != "" {
+			response.Id = chunk.Id
+		}
+		if chunk.Model != "" {
+			response.Model = chunk.Model
+		}
+		if chunk.Created != 0 {
+			response.Created = chunk.Created
+		}
+		if service.ValidUsage(chunk.Usage) {
+			usage = chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			buffer, ok := choices[choice.Index]
+			if !ok {
+				buffer = &streamChoiceBuffer{}
+				choices[choice.Index] = buffer
+			}
+			buffer.content.WriteString(choice.Delta.GetContentString())
+			buffer.reasoning.WriteString(choice.Delta.GetReasoningContent())
+			if choice.Delta.Refusal != nil {
+				buffer.refusal.WriteString(*choice.Delta.Refusal)
+			}
+			buffer.appendToolCalls(choice.Delta.ToolCalls)
+			if choice.FinishReason != nil && *choice.FinishReason != "" {
+				buffer.finishReason = *choice.FinishReason
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return service.OpenAIErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError), nil
+	}
+
+	var responseText strings.Builder
+	toolCount := 0
+	indexes := make([]int, 0, len(choices))
+	for index := range choices {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	for _, index := range indexes {
+		buffer := choices[index]
+		message := dto.Message{Role: "assistant", ReasoningContent: buffer.reasoning.String(), Refusal: buffer.refusal.String()}
+		message.SetStringContent(buffer.content.String())
+		if len(buffer.toolCalls) > 0 {
+			message.SetToolCalls(buffer.toolCalls)
+			toolCount += len(buffer.toolCalls)
+			for _, toolCall := range buffer.toolCalls {
+				responseText.WriteString(toolCall.Function.Name + toolCall.Function.Arguments)
+			}
+		}
+		finishReason := buffer.finishReason
+		if finishReason == "" {
+			finishReason = constant.FinishReasonStop
+		}
+		response.Choices = append(response.Choices, dto.OpenAITextResponseChoice{
+			Index:        index,
+			Message:      message,
+			FinishReason: finishReason,
+		})
+		responseText.WriteString(buffer.content.String() + buffer.reasoning.String())
+	}
+
+	if usage == nil {
+		usage = service.ResponseText2Usage(responseText.String(), info.UpstreamModelName, info.PromptTokens)
+		usage.CompletionTokens += toolCount * 7
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	response.Usage = *usage
+
+	if usage.CompletionTokens == 0 && isEmptyTextResponse(&response) {
+		if emptyErr := helper.EmptyResponseError(info); emptyErr != nil {
+			return emptyErr, nil
+		}
+	}
+	for _, choice := range response.Choices {
+		helper.RecordFinishReason(info, choice.FinishReason, usage.CompletionTokens)
+		helper.RecordRefusal(info, choice.FinishReason, choice.Message.Refusal, choice.Message.StringContent(), usage.CompletionTokens)
+	}
+	if truncationErr := helper.TruncationRetryError(c, info, usage.CompletionTokens); truncationErr != nil {
+		return truncationErr, usage
+	}
+
+	if response.Id == "" {
+		response.Id = helper.GetResponseID(c)
+	}
+	if response.Created == nil {
+		response.Created = common.GetTimestamp()
+	}
+	if restoreModel := requestModelToRestore(info); restoreModel != "" {
+		response.Model = restoreModel
+	}
+
+	responseBody, err := common.EncodeJson(response)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "marshal_response_body_failed", http.StatusInternalServerError), nil
+	}
+	responseBody = helper.RedactOpenAIResponse(c, responseBody, "message")
+	responseBody = helper.DowngradeToolCallsResponse(c, responseBody, "message")
+	c.Data(http.StatusOK, "application/json", responseBody)
+	return nil, usage
+}
+
+// OaiNonStreamToStreamHandler replays a non-streaming upstream chat
+// completion as server-sent events, for models forced to be non-streaming
+// upstream while the client asked for a streaming response.
+func OaiNonStreamToStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
+	defer common.CloseResponseBodyGracefully(resp)
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError), nil
+	}
+	responseBody = helper.RepairUpstreamJson(c, responseBody)
+	var simpleResponse dto.OpenAITextResponse
+	if err = common.UnmarshalJson(responseBody, &simpleResponse); err != nil {
+		return service.OpenAIErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError), nil
+	}
+	if simpleResponse.Error != nil && simpleResponse.Error.Type != "" {
+		return &dto.OpenAIErrorWithStatusCode{
+			Error:      *simpleResponse.Error,
+			StatusCode: resp.StatusCode,
+		}, nil
+	}
+
+	if simpleResponse.Usage.CompletionTokens == 0 && isEmptyTextResponse(&simpleResponse) {
+		if emptyErr := helper.EmptyResponseError(info); emptyErr != nil {
+			return emptyErr, nil
+		}
+	}
+	if simpleResponse.Usage.TotalTokens == 0 || (simpleResponse.Usage.PromptTokens == 0 && simpleResponse.Usage.CompletionTokens == 0) {
+		completionTokens := 0
+		for _, choice := range simpleResponse.Choices {
+			completionTokens += service.CountTextToken(choice.Message.StringContent()+choice.Message.ReasoningContent+choice.Message.Reasoning, info.UpstreamModelName)
+		}
+		simpleResponse.Usage = dto.Usage{
+			PromptTokens:     info.PromptTokens,
+			CompletionTokens: completionTokens,
+			TotalTokens:      info.PromptTokens + completionTokens,
+		}
+	}
+	for _, choice := range simpleResponse.Choices {
+		helper.RecordFinishReason(info, choice.FinishReason, simpleResponse.Usage.CompletionTokens)
+		helper.RecordRefusal(info, choice.FinishReason, choice.Message.Refusal, choice.Message.StringContent(), simpleResponse.Usage.CompletionTokens)
+	}
+	if truncationErr := helper.TruncationRetryError(c, info, simpleResponse.Usage.CompletionTokens); truncationErr != nil {
+		return truncationErr, &simpleResponse.Usage
+	}
+
+	responseId := simpleResponse.Id
+	if responseId == "" {
+		responseId = helper.GetResponseID(c)
+	}
+	createAt := common.GetTimestamp()
+	if created, ok := simpleResponse.Created.(float64); ok && created > 0 {
+		createAt = int64(created)
+	}
+	model := simpleResponse.Model
+	if restoreModel := requestModelToRestore(info); restoreModel != "" {
+		model = restoreModel
+	}
+
+	helper.SetEventStreamHeaders(c)
+	info.SetFirstResponseTime()
+	for _, choice := range simpleResponse.Choices {
+		delta := dto.ChatCompletionsStreamResponseChoiceDelta{Role: "assistant"}
+		if content := choice.Message.StringContent(); content != "" {
+			delta.SetContentString(content)
+		}
+		if reasoning := choice.Message.ReasoningContent + choice.Message.Reasoning; reasoning != "" {
+			delta.SetReasoningContent(reasoning)
+		}
+		if len(choice.Message.ToolCalls) > 0 {
+			var toolCalls []dto.ToolCallResponse
+			if err := common.UnmarshalJson(choice.Message.ToolCalls, &toolCalls); err == nil {
+				for i := range toolCalls {
+					toolCalls[i].SetIndex(i)
+				}
+				delta.ToolCalls = toolCalls
+			}
+		}
+		_ = helper.ObjectData(c, &dto.ChatCompletionsStreamResponse{
+			Id:      responseId,
+			Object:  "chat.completion.chunk",
+			Created: createAt,
+			Model:   model,
+			Choices: []dto.ChatCompletionsStreamResponseChoice{{Index: choice.Index, Delta: delta}},
+		})
+		finishReason := choice.FinishReason
+		if finishReason == "" {
+			finishReason = constant.FinishReasonStop
+		}
+		stopResponse := helper.GenerateStopResponse(responseId, createAt, model, finishReason)
+		stopResponse.Choices[0].Index = choice.Index
+		_ = helper.ObjectData(c, stopResponse)
+	}
+	if info.ShouldIncludeUsage {
+		_ = helper.ObjectData(c, helper.GenerateFinalUsageResponse(responseId, createAt, model, simpleResponse.Usage))
+	}
+	helper.Done(c)
+
+	return nil, &simpleResponse.Usage
+}
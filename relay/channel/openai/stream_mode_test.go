@@ -0,0 +1,105 @@
+package openai
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOaiStreamToNonStreamHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	info := &relaycommon.RelayInfo{RelayFormat: relaycommon.RelayFormatOpenAI, StreamModeForced: "stream"}
+	upstream := strings.Join([]string{
+		`data: {"id":"chatcmpl-1","model":"gpt-4o","created":1700000000,"choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}`,
+		`data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{"content":"lo"}}]}`,
+		`data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"f","arguments":"{\"a\":"}}]}}]}`,
+		`data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"1}"}}]},"finish_reason":"tool_calls"}]}`,
+		`data: {"id":"chatcmpl-1","choices":[],"usage":{"prompt_tokens":5,"completion_tokens":7,"total_tokens":12}}`,
+		`data: [DONE]`,
+	}, "\n\n")
+	resp := &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(upstream))}
+
+	openaiErr, usage := OaiStreamToNonStreamHandler(c, resp, info)
+	if openaiErr != nil {
+		t.Fatalf("OaiStreamToNonStreamHandler() error = %v", openaiErr.Error)
+	}
+	if usage == nil || usage.PromptTokens != 5 || usage.CompletionTokens != 7 {
+		t.Errorf("usage = %+v, want the upstream usage", usage)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "application/json") {
+		t.Errorf("Content-Type = %q, want a JSON response", contentType)
+	}
+	var response dto.OpenAITextResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode response %q: %v", recorder.Body.String(), err)
+	}
+	if response.Id != "chatcmpl-1" || response.Object != "chat.completion" || len(response.Choices) != 1 {
+		t.Fatalf("response = %+v, want one chat.completion choice", response)
+	}
+	choice := response.Choices[0]
+	if choice.Message.StringContent() != "Hello" || choice.FinishReason != "tool_calls" {
+		t.Errorf("choice content=%q finish_reason=%q, want Hello tool_calls", choice.Message.StringContent(), choice.FinishReason)
+	}
+	toolCalls := choice.Message.ParseToolCalls()
+	if len(toolCalls) != 1 || toolCalls[0].Function.Name != "f" || toolCalls[0].Function.Arguments != `{"a":1}` {
+		t.Errorf("tool calls = %+v, want the merged call", toolCalls)
+	}
+}
+
+func TestOaiNonStreamToStreamHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	info := &relaycommon.RelayInfo{RelayFormat: relaycommon.RelayFormatOpenAI, StreamModeForced: "non_stream", ShouldIncludeUsage: true}
+	resp := &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(
+		`{"id":"chatcmpl-2","object":"chat.completion","created":1700000000,"model":"o1","choices":[{"index":0,` +
+			`"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}],` +
+			`"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}`))}
+
+	openaiErr, usage := OaiNonStreamToStreamHandler(c, resp, info)
+	if openaiErr != nil {
+		t.Fatalf("OaiNonStreamToStreamHandler() error = %v", openaiErr.Error)
+	}
+	if usage == nil || usage.TotalTokens != 7 {
+		t.Errorf("usage = %+v, want the upstream usage", usage)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", contentType)
+	}
+
+	var events []string
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			events = append(events, data)
+		}
+	}
+	if len(events) != 4 || events[3] != "[DONE]" {
+		t.Fatalf("events = %q, want content, stop, usage and [DONE]", events)
+	}
+	var content, stop, final dto.ChatCompletionsStreamResponse
+	for i, chunk := range []*dto.ChatCompletionsStreamResponse{&content, &stop, &final} {
+		if err := json.Unmarshal([]byte(events[i]), chunk); err != nil {
+			t.Fatalf("decode event %d %q: %v", i, events[i], err)
+		}
+	}
+	if content.Id != "chatcmpl-2" || content.Object != "chat.completion.chunk" || content.Choices[0].Delta.GetContentString() != "Hello" {
+		t.Errorf("content chunk = %s, want the whole message as one delta", events[0])
+	}
+	if stop.Choices[0].FinishReason == nil || *stop.Choices[0].FinishReason != "stop" {
+		t.Errorf("stop chunk = %s, want finish_reason stop", events[1])
+	}
+	if final.Usage == nil || final.Usage.TotalTokens != 7 {
+		t.Errorf("usage chunk = %s, want the upstream usage", events[2])
+	}
+}
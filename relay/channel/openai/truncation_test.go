@@ -0,0 +1,43 @@
+package openai
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOpenaiHandlerReturnsTruncatedUsage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	c.Set(helper.TruncationRetryAllowedContextKey, true)
+	info := &relaycommon.RelayInfo{RequestMaxTokens: 16, RelayFormat: relaycommon.RelayFormatOpenAI}
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body: io.NopCloser(strings.NewReader(`{"id":"x","object":"chat.completion","choices":[{"index":0,` +
+			`"message":{"role":"assistant","content":"partial"},"finish_reason":"length"}],` +
+			`"usage":{"prompt_tokens":10,"completion_tokens":16,"total_tokens":26}}`)),
+	}
+
+	openaiErr, usage := OpenaiHandler(c, resp, info)
+	if openaiErr == nil || openaiErr.Error.Code != helper.ErrorCodeResponseTruncated {
+		t.Fatalf("OpenaiHandler() error = %v, want %s", openaiErr, helper.ErrorCodeResponseTruncated)
+	}
+	truncatedUsage := helper.TruncatedUsage(openaiErr, usage)
+	if truncatedUsage == nil || truncatedUsage.PromptTokens != 10 || truncatedUsage.CompletionTokens != 16 {
+		t.Fatalf("TruncatedUsage() = %+v, want the upstream usage", truncatedUsage)
+	}
+	if recorder.Body.Len() != 0 {
+		t.Errorf("truncated response was written to the client: %q", recorder.Body.String())
+	}
+	if c.GetInt(helper.TruncationRetryMaxTokensContextKey) != 32 {
+		t.Errorf("retry max_tokens = %d, want 32", c.GetInt(helper.TruncationRetryMaxTokensContextKey))
+	}
+}
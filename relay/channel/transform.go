@@ -0,0 +1,97 @@
+package channel
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	common2 "one-api/common"
+	"one-api/dto"
+	"one-api/relay/common"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func applyHeaderRules(rules *dto.TransformRules, header http.Header) {
+	for _, name := range rules.RemoveHeaders {
+		header.Del(name)
+	}
+	for name, value := range rules.SetHeaders {
+		header.Set(name, value)
+	}
+}
+
+// applyFieldRules 改写 JSON 对象顶层字段，非 JSON 对象的内容原样返回
+func applyFieldRules(rules *dto.TransformRules, data []byte) ([]byte, bool) {
+	var body map[string]any
+	if err := common2.UnmarshalJson(data, &body); err != nil || body == nil {
+		return data, false
+	}
+	for from, to := range rules.RenameFields {
+		if value, ok := body[from]; ok {
+			delete(body, from)
+			body[to] = value
+		}
+	}
+	for name, value := range rules.DefaultFields {
+		if _, ok := body[name]; !ok {
+			body[name] = value
+		}
+	}
+	newData, err := common2.EncodeJson(body)
+	if err != nil {
+		return data, false
+	}
+	return newData, true
+}
+
+// transformRequestBody 按渠道规则改写请求体，读取失败时返回错误
+func transformRequestBody(info *common.RelayInfo, requestBody io.Reader) (io.Reader, error) {
+	transform := info.ChannelSetting.Transform
+	if transform == nil || !transform.Request.HasFieldRules() || requestBody == nil {
+		return requestBody, nil
+	}
+	data, err := io.ReadAll(requestBody)
+	if err != nil {
+		return nil, err
+	}
+	data, _ = applyFieldRules(&transform.Request, data)
+	return bytes.NewReader(data), nil
+}
+
+func transformRequestHeader(info *common.RelayInfo, header http.Header) {
+	if transform := info.ChannelSetting.Transform; transform != nil {
+		applyHeaderRules(&transform.Request, header)
+	}
+}
+
+// transformResponse 按渠道规则改写上游响应；流式响应只改写响应头
+func transformResponse(c *gin.Context, info *common.RelayInfo, resp *http.Response) error {
+	transform := info.ChannelSetting.Transform
+	if transform == nil {
+		return nil
+	}
+	rules := &transform.Response
+	if rules.HasHeaderRules() {
+		applyHeaderRules(rules, resp.Header)
+		if info.IsStream {
+			// 流式响应不会拷贝上游响应头，直接写到客户端响应上
+			applyHeaderRules(rules, c.Writer.Header())
+		}
+	}
+	if info.IsStream || !rules.HasFieldRules() || !strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		return nil
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	data, changed := applyFieldRules(rules, data)
+	if changed {
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = int64(len(data))
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return nil
+}
@@ -0,0 +1,108 @@
+package channel
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTransformTestInfo(transform *dto.ChannelTransform, isStream bool) *relaycommon.RelayInfo {
+	return &relaycommon.RelayInfo{IsStream: isStream, ChannelSetting: dto.ChannelSettings{Transform: transform}}
+}
+
+func TestTransformRequest(t *testing.T) {
+	info := newTransformTestInfo(&dto.ChannelTransform{Request: dto.TransformRules{
+		SetHeaders:    map[string]string{"X-Api-Version": "2"},
+		RemoveHeaders: []string{"OpenAI-Organization"},
+		RenameFields:  map[string]string{"max_tokens": "max_output_tokens"},
+		DefaultFields: map[string]any{"safe_mode": true, "temperature": 1},
+	}}, false)
+
+	body, err := transformRequestBody(info, strings.NewReader(`{"model":"m","max_tokens":64,"temperature":0.2}`))
+	if err != nil {
+		t.Fatalf("transformRequestBody() error = %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("decode body %s: %v", data, err)
+	}
+	if _, ok := fields["max_tokens"]; ok || fields["max_output_tokens"] != float64(64) {
+		t.Errorf("body = %s, want max_tokens renamed to max_output_tokens", data)
+	}
+	if fields["temperature"] != 0.2 || fields["safe_mode"] != true {
+		t.Errorf("body = %s, want defaults only for absent fields", data)
+	}
+
+	header := http.Header{"Openai-Organization": {"org"}, "Authorization": {"Bearer k"}}
+	transformRequestHeader(info, header)
+	if header.Get("X-Api-Version") != "2" || header.Get("OpenAI-Organization") != "" || header.Get("Authorization") != "Bearer k" {
+		t.Errorf("header = %v, want the rules applied and other headers kept", header)
+	}
+
+	// 非 JSON 对象的请求体原样发送
+	body, err = transformRequestBody(info, strings.NewReader(`[1,2]`))
+	if err != nil {
+		t.Fatalf("transformRequestBody() error = %v", err)
+	}
+	if data, _ := io.ReadAll(body); string(data) != `[1,2]` {
+		t.Errorf("non-object body = %s, want it unchanged", data)
+	}
+}
+
+func TestTransformResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	transform := &dto.ChannelTransform{Response: dto.TransformRules{
+		SetHeaders:    map[string]string{"X-Channel": "custom"},
+		RemoveHeaders: []string{"X-Upstream-Secret"},
+		RenameFields:  map[string]string{"outputs": "choices"},
+	}}
+	const upstream = `{"id":"1","outputs":[]}`
+	tests := []struct {
+		name        string
+		isStream    bool
+		contentType string
+		wantBody    string
+	}{
+		{name: "json response is rewritten", contentType: "application/json", wantBody: `{"choices":[],"id":"1"}`},
+		{name: "stream response only gets header rules", isStream: true, contentType: "text/event-stream", wantBody: upstream},
+		{name: "non-json response is left alone", contentType: "text/plain", wantBody: upstream},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			resp := &http.Response{
+				StatusCode:    http.StatusOK,
+				Header:        http.Header{"Content-Type": {tt.contentType}, "X-Upstream-Secret": {"s"}, "Content-Length": {"24"}},
+				ContentLength: int64(len(upstream)),
+				Body:          io.NopCloser(strings.NewReader(upstream)),
+			}
+
+			if err := transformResponse(c, newTransformTestInfo(transform, tt.isStream), resp); err != nil {
+				t.Fatalf("transformResponse() error = %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if string(body) != tt.wantBody {
+				t.Errorf("body = %s, want %s", body, tt.wantBody)
+			}
+			if resp.ContentLength != int64(len(body)) {
+				t.Errorf("content length = %d, want %d", resp.ContentLength, len(body))
+			}
+			if resp.Header.Get("X-Channel") != "custom" || resp.Header.Get("X-Upstream-Secret") != "" {
+				t.Errorf("response header = %v, want the header rules applied", resp.Header)
+			}
+			// 流式响应头需直接写到客户端
+			if got := recorder.Header().Get("X-Channel"); (got == "custom") != tt.isStream {
+				t.Errorf("client X-Channel = %q, stream %v", got, tt.isStream)
+			}
+		})
+	}
+}
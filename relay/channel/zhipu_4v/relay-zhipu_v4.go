@@ -92,13 +92,7 @@ func requestOpenAI2Zhipu(request dto.GeneralOpenAIRequest) *dto.GeneralOpenAIReq
 			ToolCallId: message.ToolCallId,
 		})
 	}
-	str, ok := request.Stop.(string)
-	var Stop []string
-	if ok {
-		Stop = []string{str}
-	} else {
-		Stop, _ = request.Stop.([]string)
-	}
+	Stop := request.ParseStop()
 	return &dto.GeneralOpenAIRequest{
 		Model:       request.Model,
 		Stream:      request.Stream,
@@ -0,0 +1,58 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+const (
+	// ClientMetadataHeader 客户端自定义标签，值为 JSON 对象
+	ClientMetadataHeader = "X-Metadata"
+
+	maxClientMetadataBytes  = 2048
+	maxClientMetadataKeys   = 16
+	maxClientMetadataValues = 128
+)
+
+var clientMetadataKeyRegex = regexp.MustCompile(`^[A-Za-z0-9_.:-]{1,64}$`)
+var clientMetadataValueRegex = regexp.MustCompile(`^[A-Za-z0-9_.:@/ -]*$`)
+
+// ParseClientMetadata turns a JSON object into sanitized string tags. Keys
+// and values are restricted to a small character set so they can be stored
+// in the log and matched by the log search; anything else is dropped.
+func ParseClientMetadata(raw []byte) map[string]string {
+	if len(raw) == 0 || len(raw) > maxClientMetadataBytes {
+		return nil
+	}
+	var values map[string]any
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return nil
+	}
+	metadata := make(map[string]string)
+	for key, value := range values {
+		if len(metadata) >= maxClientMetadataKeys {
+			break
+		}
+		if !clientMetadataKeyRegex.MatchString(key) {
+			continue
+		}
+		var str string
+		switch v := value.(type) {
+		case string:
+			str = v
+		case float64, bool:
+			str = fmt.Sprint(v)
+		default:
+			continue
+		}
+		if len(str) > maxClientMetadataValues || !clientMetadataValueRegex.MatchString(str) {
+			continue
+		}
+		metadata[key] = str
+	}
+	if len(metadata) == 0 {
+		return nil
+	}
+	return metadata
+}
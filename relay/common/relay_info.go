@@ -18,6 +18,11 @@ type ThinkingContentInfo struct {
 	HasSentThinkingContent  bool
 }
 
+const (
+	ApiConversionChatToResponses = "chat_to_responses" // 客户端使用 chat completions，上游使用 responses
+	ApiConversionResponsesToChat = "responses_to_chat" // 客户端使用 responses，上游使用 chat completions
+)
+
 const (
 	LastMessageTypeNone     = "none"
 	LastMessageTypeText     = "text"
@@ -71,6 +76,7 @@ type RelayInfo struct {
 	StartTime         time.Time
 	FirstResponseTime time.Time
 	isFirstResponse   bool
+	OnFirstResponse   func() // 首个响应数据到达时调用一次
 	//SendLastReasoningResponse bool
 	ApiType           int
 	IsStream          bool
@@ -79,6 +85,7 @@ type RelayInfo struct {
 	RelayMode         int
 	UpstreamModelName string
 	OriginModelName   string
+	RequestModelName  string // 客户端请求的模型名称（归一化之前）
 	//RecodeModelName      string
 	RequestURLPath       string
 	ApiVersion           string
@@ -105,6 +112,23 @@ type RelayInfo struct {
 	RelayFormat          string
 	SendResponseCount    int
 	ChannelCreateTime    int64
+	UpstreamTiming       *UpstreamTiming   // 上游请求耗时分布，未开启追踪时为 nil
+	UpstreamRateLimit    map[string]string // 上游返回的 x-ratelimit-* 响应头
+	ParamAdjustments     []string          // 按分组参数策略截断的请求参数
+	ParamDefaults        []string          // 客户端未设置、按分组默认值补全的请求参数
+	JsonModeInjected     bool              // 按分组 JSON 模式策略加入了 response_format
+	StopInjected         bool              // 按分组/模型策略加入了 stop 序列
+	DedupShared          bool              // 复用了并发相同请求的上游响应
+	StreamModeForced     string            // 按模型强制的上游流式模式，客户端期望与上游相反
+	ApiConversion        string            // chat completions 与 responses 接口之间的自动转换方向
+	UpstreamFinishReason string            // 上游返回的结束原因
+	Truncated            bool              // 补全被截断（length 或补全 token 过少）
+	Refusal              bool              // 模型拒绝回答
+	RequestMaxTokens     int               // 请求的 max_tokens，截断重试时据此调大
+	AudioDurationSeconds float64           // 上传音频的时长（秒），语音转写/翻译时记录
+	// PromptTokensEstimated 输入 token 计算失败，按字符数估算
+	PromptTokensEstimated bool
+	ClientMetadata        map[string]string // 客户端自定义标签，记录到日志
 	ThinkingContentInfo
 	*ClaudeConvertInfo
 	*RerankerInfo
@@ -243,6 +267,7 @@ func GenRelayInfo(c *gin.Context) *RelayInfo {
 		FirstResponseTime: startTime.Add(-time.Second),
 		OriginModelName:   common.GetContextKeyString(c, constant.ContextKeyOriginalModel),
 		UpstreamModelName: common.GetContextKeyString(c, constant.ContextKeyOriginalModel),
+		RequestModelName:  common.GetContextKeyString(c, constant.ContextKeyRequestModel),
 		//RecodeModelName:   c.GetString("original_model"),
 		IsModelMapped: false,
 		ApiType:       apiType,
@@ -284,6 +309,7 @@ func GenRelayInfo(c *gin.Context) *RelayInfo {
 	if ok {
 		info.UserSetting = userSetting
 	}
+	info.ClientMetadata = ParseClientMetadata([]byte(c.Request.Header.Get(ClientMetadataHeader)))
 
 	return info
 }
@@ -300,6 +326,9 @@ func (info *RelayInfo) SetFirstResponseTime() {
 	if info.isFirstResponse {
 		info.FirstResponseTime = time.Now()
 		info.isFirstResponse = false
+		if info.OnFirstResponse != nil {
+			info.OnFirstResponse()
+		}
 	}
 }
 
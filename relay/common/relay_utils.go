@@ -6,6 +6,7 @@ import (
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
+	"net/url"
 	"one-api/constant"
 	"strings"
 )
@@ -24,6 +25,27 @@ func GetFullRequestURL(baseURL string, requestURL string, channelType int) strin
 	return fullRequestURL
 }
 
+// ApplyPathTemplate 按渠道的路径模板改写标准接口路径：模板替换开头的 /v1，
+// 占位符替换为模型名与部署名。未配置模板时原样返回
+func ApplyPathTemplate(info *RelayInfo, requestURL string) string {
+	template := info.ChannelSetting.PathTemplate
+	if template == "" {
+		return requestURL
+	}
+	deployment := info.UpstreamModelName
+	if mapped, ok := info.ChannelSetting.AzureDeployments[deployment]; ok && mapped != "" {
+		deployment = mapped
+	}
+	prefix := strings.NewReplacer(
+		"{model}", url.PathEscape(info.UpstreamModelName),
+		"{deployment}", url.PathEscape(deployment),
+	).Replace(strings.TrimRight(template, "/"))
+	if requestURL == "/v1" || strings.HasPrefix(requestURL, "/v1/") || strings.HasPrefix(requestURL, "/v1?") {
+		return prefix + strings.TrimPrefix(requestURL, "/v1")
+	}
+	return prefix + requestURL
+}
+
 func GetAPIVersion(c *gin.Context) string {
 	query := c.Request.URL.Query()
 	apiVersion := query.Get("api-version")
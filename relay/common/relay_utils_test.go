@@ -0,0 +1,39 @@
+package common
+
+import (
+	"one-api/dto"
+	"testing"
+)
+
+func TestApplyPathTemplate(t *testing.T) {
+	tests := []struct {
+		name        string
+		template    string
+		deployments map[string]string
+		model       string
+		requestURL  string
+		want        string
+	}{
+		{name: "no template", model: "gpt-4o", requestURL: "/v1/chat/completions", want: "/v1/chat/completions"},
+		{name: "replaces v1", template: "/api/openai/v2", model: "gpt-4o", requestURL: "/v1/chat/completions", want: "/api/openai/v2/chat/completions"},
+		{name: "trailing slash", template: "/api/", model: "gpt-4o", requestURL: "/v1/chat/completions", want: "/api/chat/completions"},
+		{name: "model placeholder", template: "/models/{model}/v1", model: "gpt-4o", requestURL: "/v1/chat/completions", want: "/models/gpt-4o/v1/chat/completions"},
+		{name: "model escaped", template: "/models/{model}", model: "org/model name", requestURL: "/v1/embeddings", want: "/models/org%2Fmodel%20name/embeddings"},
+		{name: "deployment mapped", template: "/deployments/{deployment}", deployments: map[string]string{"gpt-4o": "prod-4o"}, model: "gpt-4o", requestURL: "/v1/chat/completions", want: "/deployments/prod-4o/chat/completions"},
+		{name: "deployment defaults to model", template: "/deployments/{deployment}", model: "gpt-4o", requestURL: "/v1/chat/completions", want: "/deployments/gpt-4o/chat/completions"},
+		{name: "query kept", template: "/api", model: "gpt-4o", requestURL: "/v1?x=1", want: "/api?x=1"},
+		{name: "path without v1", template: "/api", model: "gpt-4o", requestURL: "/chat/completions", want: "/api/chat/completions"},
+		{name: "v1 prefix only", template: "/api", model: "gpt-4o", requestURL: "/v1beta/models", want: "/api/v1beta/models"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := &RelayInfo{
+				UpstreamModelName: tt.model,
+				ChannelSetting:    dto.ChannelSettings{PathTemplate: tt.template, AzureDeployments: tt.deployments},
+			}
+			if got := ApplyPathTemplate(info, tt.requestURL); got != tt.want {
+				t.Errorf("ApplyPathTemplate(%q) = %q, want %q", tt.requestURL, got, tt.want)
+			}
+		})
+	}
+}
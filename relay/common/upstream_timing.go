@@ -0,0 +1,77 @@
+package common
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// UpstreamTiming 记录一次上游请求的耗时分布，仅在开启 TRACE_UPSTREAM_TIMING 时采集
+type UpstreamTiming struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+}
+
+func NewUpstreamTiming() *UpstreamTiming {
+	return &UpstreamTiming{start: time.Now()}
+}
+
+// WithClientTrace returns a context that records connection phases into t.
+func (t *UpstreamTiming) WithClientTrace(ctx context.Context) context.Context {
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.mark(&t.dnsDone) },
+		ConnectStart: func(string, string) {
+			t.mark(&t.connectStart)
+		},
+		ConnectDone: func(string, string, error) {
+			t.mark(&t.connectDone)
+		},
+		TLSHandshakeStart: func() { t.mark(&t.tlsStart) },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mark(&t.tlsDone)
+		},
+		GotFirstResponseByte: func() { t.mark(&t.firstByte) },
+	}
+	return httptrace.WithClientTrace(ctx, trace)
+}
+
+// mark only keeps the first occurrence, e.g. the first dialed address when
+// several are tried.
+func (t *UpstreamTiming) mark(field *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if field.IsZero() {
+		*field = time.Now()
+	}
+}
+
+func phaseMillis(from, to time.Time) int64 {
+	if from.IsZero() || to.IsZero() {
+		return 0
+	}
+	return to.Sub(from).Milliseconds()
+}
+
+// ToMap returns the breakdown in milliseconds. Phases skipped because of a
+// reused connection are reported as 0; total is measured up to now.
+func (t *UpstreamTiming) ToMap() map[string]interface{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return map[string]interface{}{
+		"dns":     phaseMillis(t.dnsStart, t.dnsDone),
+		"connect": phaseMillis(t.connectStart, t.connectDone),
+		"tls":     phaseMillis(t.tlsStart, t.tlsDone),
+		"ttfb":    phaseMillis(t.start, t.firstByte),
+		"total":   time.Since(t.start).Milliseconds(),
+	}
+}
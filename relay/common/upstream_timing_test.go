@@ -0,0 +1,65 @@
+package common
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpstreamTimingRecordsPhases(t *testing.T) {
+	const firstByteDelay = 50 * time.Millisecond
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(firstByteDelay)
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	client := server.Client()
+	// 通过主机名访问以触发 DNS 解析，测试证书只签发给 example.com
+	client.Transport.(*http.Transport).TLSClientConfig.ServerName = "example.com"
+	url := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	send := func() *UpstreamTiming {
+		timing := NewUpstreamTiming()
+		req, _ := http.NewRequestWithContext(timing.WithClientTrace(context.Background()), http.MethodGet, url, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("request error = %v", err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		return timing
+	}
+
+	timing := send()
+	for name, phase := range map[string][2]time.Time{
+		"dns":     {timing.dnsStart, timing.dnsDone},
+		"connect": {timing.connectStart, timing.connectDone},
+		"tls":     {timing.tlsStart, timing.tlsDone},
+	} {
+		if phase[0].IsZero() || phase[1].Before(phase[0]) {
+			t.Errorf("%s phase = %v, want it recorded on a new connection", name, phase)
+		}
+	}
+	breakdown := timing.ToMap()
+	if ttfb := breakdown["ttfb"].(int64); ttfb < firstByteDelay.Milliseconds() {
+		t.Errorf("ttfb = %dms, want at least %dms", ttfb, firstByteDelay.Milliseconds())
+	}
+	if breakdown["total"].(int64) < breakdown["ttfb"].(int64) {
+		t.Errorf("total = %v, want at least ttfb %v", breakdown["total"], breakdown["ttfb"])
+	}
+
+	// 复用连接时跳过的阶段记为 0
+	breakdown = send().ToMap()
+	for _, phase := range []string{"dns", "connect", "tls"} {
+		if breakdown[phase].(int64) != 0 {
+			t.Errorf("%s = %v on a reused connection, want 0", phase, breakdown[phase])
+		}
+	}
+	if ttfb := breakdown["ttfb"].(int64); ttfb < firstByteDelay.Milliseconds() {
+		t.Errorf("ttfb = %dms on a reused connection, want at least %dms", ttfb, firstByteDelay.Milliseconds())
+	}
+}
@@ -55,6 +55,63 @@ const (
 	RelayModeGemini
 )
 
+const (
+	RelayCapabilityChat        = "chat"
+	RelayCapabilityCompletions = "completions"
+	RelayCapabilityEmbeddings  = "embeddings"
+	RelayCapabilityModerations = "moderations"
+	RelayCapabilityImages      = "images"
+	RelayCapabilityEdits       = "edits"
+	RelayCapabilityMidjourney  = "midjourney"
+	RelayCapabilityAudio       = "audio"
+	RelayCapabilitySuno        = "suno"
+	RelayCapabilityKling       = "kling"
+	RelayCapabilityJimeng      = "jimeng"
+	RelayCapabilityRerank      = "rerank"
+	RelayCapabilityResponses   = "responses"
+	RelayCapabilityRealtime    = "realtime"
+	RelayCapabilityGemini      = "gemini"
+	RelayCapabilityClaude      = "claude"
+)
+
+// RelayModeCapability groups relay modes into the capability names used by
+// the relay endpoint allowlist. Unknown modes map to "".
+func RelayModeCapability(relayMode int) string {
+	switch {
+	case relayMode == RelayModeChatCompletions:
+		return RelayCapabilityChat
+	case relayMode == RelayModeCompletions:
+		return RelayCapabilityCompletions
+	case relayMode == RelayModeEmbeddings:
+		return RelayCapabilityEmbeddings
+	case relayMode == RelayModeModerations:
+		return RelayCapabilityModerations
+	case relayMode == RelayModeImagesGenerations, relayMode == RelayModeImagesEdits:
+		return RelayCapabilityImages
+	case relayMode == RelayModeEdits:
+		return RelayCapabilityEdits
+	case relayMode >= RelayModeMidjourneyImagine && relayMode <= RelayModeMidjourneyEdits:
+		return RelayCapabilityMidjourney
+	case relayMode >= RelayModeAudioSpeech && relayMode <= RelayModeAudioTranslation:
+		return RelayCapabilityAudio
+	case relayMode >= RelayModeSunoFetch && relayMode <= RelayModeSunoSubmit:
+		return RelayCapabilitySuno
+	case relayMode == RelayModeKlingFetchByID, relayMode == RelayModeKlingSubmit:
+		return RelayCapabilityKling
+	case relayMode == RelayModeJimengFetchByID, relayMode == RelayModeJimengSubmit:
+		return RelayCapabilityJimeng
+	case relayMode == RelayModeRerank:
+		return RelayCapabilityRerank
+	case relayMode == RelayModeResponses:
+		return RelayCapabilityResponses
+	case relayMode == RelayModeRealtime:
+		return RelayCapabilityRealtime
+	case relayMode == RelayModeGemini:
+		return RelayCapabilityGemini
+	}
+	return ""
+}
+
 func Path2RelayMode(path string) int {
 	relayMode := RelayModeUnknown
 	if strings.HasPrefix(path, "/v1/chat/completions") || strings.HasPrefix(path, "/pg/chat/completions") {
@@ -0,0 +1,40 @@
+package constant
+
+import "testing"
+
+func TestRelayModeCapability(t *testing.T) {
+	tests := []struct {
+		name      string
+		relayMode int
+		want      string
+	}{
+		{name: "chat", relayMode: RelayModeChatCompletions, want: RelayCapabilityChat},
+		{name: "completions", relayMode: RelayModeCompletions, want: RelayCapabilityCompletions},
+		{name: "embeddings", relayMode: RelayModeEmbeddings, want: RelayCapabilityEmbeddings},
+		{name: "moderations", relayMode: RelayModeModerations, want: RelayCapabilityModerations},
+		{name: "image generations", relayMode: RelayModeImagesGenerations, want: RelayCapabilityImages},
+		{name: "image edits", relayMode: RelayModeImagesEdits, want: RelayCapabilityImages},
+		{name: "edits", relayMode: RelayModeEdits, want: RelayCapabilityEdits},
+		{name: "first midjourney mode", relayMode: RelayModeMidjourneyImagine, want: RelayCapabilityMidjourney},
+		{name: "last midjourney mode", relayMode: RelayModeMidjourneyEdits, want: RelayCapabilityMidjourney},
+		{name: "speech", relayMode: RelayModeAudioSpeech, want: RelayCapabilityAudio},
+		{name: "transcription", relayMode: RelayModeAudioTranscription, want: RelayCapabilityAudio},
+		{name: "translation", relayMode: RelayModeAudioTranslation, want: RelayCapabilityAudio},
+		{name: "suno fetch", relayMode: RelayModeSunoFetch, want: RelayCapabilitySuno},
+		{name: "suno submit", relayMode: RelayModeSunoSubmit, want: RelayCapabilitySuno},
+		{name: "kling", relayMode: RelayModeKlingSubmit, want: RelayCapabilityKling},
+		{name: "jimeng", relayMode: RelayModeJimengFetchByID, want: RelayCapabilityJimeng},
+		{name: "rerank", relayMode: RelayModeRerank, want: RelayCapabilityRerank},
+		{name: "responses", relayMode: RelayModeResponses, want: RelayCapabilityResponses},
+		{name: "realtime", relayMode: RelayModeRealtime, want: RelayCapabilityRealtime},
+		{name: "gemini", relayMode: RelayModeGemini, want: RelayCapabilityGemini},
+		{name: "unknown mode", relayMode: RelayModeUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RelayModeCapability(tt.relayMode); got != tt.want {
+				t.Errorf("RelayModeCapability(%d) = %q, want %q", tt.relayMode, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"one-api/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// isDryRunRequest reports whether the client asked to validate the request
+// without calling the upstream, via the X-Dry-Run header or dry_run query.
+func isDryRunRequest(c *gin.Context) bool {
+	value := c.GetHeader("X-Dry-Run")
+	if value == "" {
+		value = c.Query("dry_run")
+	}
+	value = strings.ToLower(value)
+	return value == "true" || value == "1"
+}
+
+// respondDryRun checks the quota the request would need and returns the
+// resolved channel, model and estimated cost. Nothing is consumed.
+func respondDryRun(c *gin.Context, info *relaycommon.RelayInfo, priceData helper.PriceData) *dto.OpenAIErrorWithStatusCode {
+	if info.TokenId == 0 {
+		return service.OpenAIErrorWrapperLocal(errors.New("dry run requires a token"), "dry_run_not_allowed", http.StatusForbidden)
+	}
+	userQuota, err := model.GetUserQuota(info.UserId, false)
+	if err != nil {
+		return service.OpenAIErrorWrapperLocal(err, "get_user_quota_failed", http.StatusInternalServerError)
+	}
+	needQuota := priceData.ShouldPreConsumedQuota
+	if userQuota <= 0 || userQuota < needQuota {
+		return service.OpenAIErrorWrapperLocal(fmt.Errorf("user quota is not enough, user quota: %s, need quota: %s", common.FormatQuota(userQuota), common.FormatQuota(needQuota)), "insufficient_user_quota", http.StatusForbidden)
+	}
+	if !info.TokenUnlimited && c.GetInt("token_quota") < needQuota {
+		return service.OpenAIErrorWrapperLocal(fmt.Errorf("token quota is not enough, need quota: %s", common.FormatQuota(needQuota)), "pre_consume_token_quota_failed", http.StatusForbidden)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"dry_run":         true,
+		"model":           info.OriginModelName,
+		"upstream_model":  info.UpstreamModelName,
+		"channel_id":      info.ChannelId,
+		"channel_type":    info.ChannelType,
+		"group":           info.UsingGroup,
+		"prompt_tokens":   info.PromptTokens,
+		"estimated_quota": needQuota,
+		"estimated_cost":  common.FormatQuota(needQuota),
+	})
+	return nil
+}
@@ -0,0 +1,127 @@
+package relay
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestIsDryRunRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		want   bool
+	}{
+		{name: "header", path: "/v1/chat/completions", header: "true", want: true},
+		{name: "header is case insensitive", path: "/v1/chat/completions", header: "TRUE", want: true},
+		{name: "query", path: "/v1/chat/completions?dry_run=1", want: true},
+		{name: "header takes precedence", path: "/v1/chat/completions?dry_run=true", header: "false"},
+		{name: "absent", path: "/v1/chat/completions"},
+		{name: "other value", path: "/v1/chat/completions?dry_run=yes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, tt.path, nil)
+			if tt.header != "" {
+				c.Request.Header.Set("X-Dry-Run", tt.header)
+			}
+			if got := isDryRunRequest(c); got != tt.want {
+				t.Errorf("isDryRunRequest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRespondDryRun(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sqlite handle: %v", err)
+	}
+	// 内存数据库每个连接相互独立，限制为单连接以共享同一份数据
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&model.User{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	mainDB, redisEnabled := model.DB, common.RedisEnabled
+	model.DB, common.RedisEnabled = db, false
+	t.Cleanup(func() { model.DB, common.RedisEnabled = mainDB, redisEnabled })
+	if err := db.Create(&model.User{Id: 1, Username: "dry", AffCode: "dry", Quota: 1000}).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		tokenId        int
+		tokenQuota     int
+		tokenUnlimited bool
+		needQuota      int
+		wantCode       string
+	}{
+		{name: "enough quota", tokenId: 1, tokenQuota: 500, needQuota: 300},
+		{name: "unlimited token", tokenId: 1, tokenUnlimited: true, needQuota: 800},
+		{name: "request without token", needQuota: 300, wantCode: "dry_run_not_allowed"},
+		{name: "user quota is not enough", tokenId: 1, tokenUnlimited: true, needQuota: 2000, wantCode: "insufficient_user_quota"},
+		{name: "token quota is not enough", tokenId: 1, tokenQuota: 100, needQuota: 300, wantCode: "pre_consume_token_quota_failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{}`))
+			c.Set("token_quota", tt.tokenQuota)
+			info := &relaycommon.RelayInfo{UserId: 1, TokenId: tt.tokenId, TokenUnlimited: tt.tokenUnlimited, UsingGroup: "default",
+				OriginModelName: "gpt-4o", UpstreamModelName: "gpt-4o-2024-08-06", ChannelId: 7, PromptTokens: 12}
+
+			openaiErr := respondDryRun(c, info, helper.PriceData{ShouldPreConsumedQuota: tt.needQuota})
+			if tt.wantCode != "" {
+				if openaiErr == nil || openaiErr.Error.Code != tt.wantCode {
+					t.Fatalf("respondDryRun() error = %v, want code %s", openaiErr, tt.wantCode)
+				}
+				if recorder.Body.Len() != 0 {
+					t.Errorf("rejected dry run wrote %s", recorder.Body.String())
+				}
+				return
+			}
+			if openaiErr != nil {
+				t.Fatalf("respondDryRun() error = %v", openaiErr.Error)
+			}
+			var response struct {
+				DryRun         bool   `json:"dry_run"`
+				UpstreamModel  string `json:"upstream_model"`
+				ChannelId      int    `json:"channel_id"`
+				PromptTokens   int    `json:"prompt_tokens"`
+				EstimatedQuota int    `json:"estimated_quota"`
+			}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode response %s: %v", recorder.Body.String(), err)
+			}
+			if !response.DryRun || response.UpstreamModel != "gpt-4o-2024-08-06" || response.ChannelId != 7 ||
+				response.PromptTokens != 12 || response.EstimatedQuota != tt.needQuota {
+				t.Errorf("response = %s, want the resolved channel and estimate", recorder.Body.String())
+			}
+		})
+	}
+
+	// 试运行不扣除额度
+	if quota, _ := model.GetUserQuota(1, true); quota != 1000 {
+		t.Errorf("user quota = %d after dry runs, want 1000", quota)
+	}
+}
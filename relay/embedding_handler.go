@@ -44,13 +44,14 @@ func EmbeddingHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode)
 
 	err = validateEmbeddingRequest(c, relayInfo, *embeddingRequest)
 	if err != nil {
-		return service.OpenAIErrorWrapperLocal(err, "invalid_embedding_request", http.StatusBadRequest)
+		return service.OpenAIErrorWrapperLocal(err, "invalid_embedding_request", validationErrorStatus(err))
 	}
 
 	err = helper.ModelMappedHelper(c, relayInfo, embeddingRequest)
 	if err != nil {
 		return service.OpenAIErrorWrapperLocal(err, "model_mapped_error", http.StatusInternalServerError)
 	}
+	windows := splitEmbeddingInput(c, relayInfo, embeddingRequest)
 
 	promptToken := getEmbeddingPromptToken(*embeddingRequest)
 	relayInfo.PromptTokens = promptToken
@@ -101,6 +102,9 @@ func EmbeddingHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode)
 			service.ResetStatusCode(openaiErr, statusCodeMappingStr)
 			return openaiErr
 		}
+		if windows != nil {
+			poolEmbeddingResponse(c, httpResp, windows)
+		}
 	}
 
 	usage, openaiErr := adaptor.DoResponse(c, httpResp, relayInfo)
@@ -0,0 +1,147 @@
+package relay
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"one-api/setting/model_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// embeddingWindows maps each original input to its [start, end) range of
+// windows in the input sent upstream.
+type embeddingWindows struct {
+	ranges [][2]int
+	total  int
+}
+
+// splitEmbeddingInput truncates or splits inputs longer than the model's
+// token limit. Windows are returned only when inputs were split for pooling;
+// pooling needs float embeddings in the OpenAI format, otherwise inputs are
+// truncated instead.
+func splitEmbeddingInput(c *gin.Context, info *relaycommon.RelayInfo, request *dto.EmbeddingRequest) *embeddingWindows {
+	settings := model_setting.GetEmbeddingSettings()
+	maxTokens := settings.ModelMaxInputTokens[info.UpstreamModelName]
+	if settings.SplitMode == model_setting.EmbeddingSplitModeOff || maxTokens <= 0 {
+		return nil
+	}
+	var inputs []string
+	isList := false
+	switch input := request.Input.(type) {
+	case string:
+		inputs = []string{input}
+	case []any:
+		isList = true
+		for _, item := range input {
+			text, ok := item.(string)
+			if !ok {
+				return nil
+			}
+			inputs = append(inputs, text)
+		}
+	default:
+		return nil
+	}
+
+	pool := settings.SplitMode == model_setting.EmbeddingSplitModePool &&
+		info.ApiType == constant.APITypeOpenAI && request.EncodingFormat != "base64"
+	windows := &embeddingWindows{}
+	expanded := make([]string, 0, len(inputs))
+	truncated, split := 0, 0
+	for _, text := range inputs {
+		parts := service.SplitTextByTokens(text, info.UpstreamModelName, maxTokens, settings.WindowOverlapTokens)
+		if len(parts) > 1 {
+			if pool {
+				split++
+			} else {
+				parts = parts[:1]
+				truncated++
+			}
+		}
+		windows.ranges = append(windows.ranges, [2]int{len(expanded), len(expanded) + len(parts)})
+		expanded = append(expanded, parts...)
+	}
+	windows.total = len(expanded)
+	if truncated == 0 && split == 0 {
+		return nil
+	}
+	if truncated > 0 {
+		common.LogInfo(c, fmt.Sprintf("truncated %d embedding inputs to %d tokens", truncated, maxTokens))
+	}
+	if split > 0 {
+		common.LogInfo(c, fmt.Sprintf("split %d embedding inputs into %d windows of %d tokens", split, len(expanded), maxTokens))
+	}
+	if !isList && len(expanded) == 1 {
+		request.Input = expanded[0]
+	} else {
+		list := make([]any, len(expanded))
+		for i, text := range expanded {
+			list[i] = text
+		}
+		request.Input = list
+	}
+	if split == 0 {
+		return nil
+	}
+	return windows
+}
+
+// poolEmbeddingResponse rewrites the upstream response so that each original
+// input gets the normalized mean of its window embeddings. The response is
+// left untouched when it does not match the windows.
+func poolEmbeddingResponse(c *gin.Context, resp *http.Response, windows *embeddingWindows) {
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	var embeddingResponse dto.OpenAIEmbeddingResponse
+	if err := common.UnmarshalJson(body, &embeddingResponse); err != nil || len(embeddingResponse.Data) != windows.total {
+		common.LogWarn(c, "embedding response does not match the split windows, skip pooling")
+		return
+	}
+	vectors := make(map[int][]float64, len(embeddingResponse.Data))
+	for _, item := range embeddingResponse.Data {
+		vectors[item.Index] = item.Embedding
+	}
+	data := make([]dto.OpenAIEmbeddingResponseItem, 0, len(windows.ranges))
+	for i, r := range windows.ranges {
+		var pooled []float64
+		for w := r[0]; w < r[1]; w++ {
+			vector := vectors[w]
+			if pooled == nil {
+				pooled = make([]float64, len(vector))
+			}
+			for j := 0; j < len(pooled) && j < len(vector); j++ {
+				pooled[j] += vector[j]
+			}
+		}
+		norm := 0.0
+		for _, v := range pooled {
+			norm += v * v
+		}
+		if norm = math.Sqrt(norm); norm > 0 {
+			for j := range pooled {
+				pooled[j] /= norm
+			}
+		}
+		data = append(data, dto.OpenAIEmbeddingResponseItem{Object: "embedding", Index: i, Embedding: pooled})
+	}
+	embeddingResponse.Data = data
+	pooledBody, err := common.EncodeJson(embeddingResponse)
+	if err != nil {
+		return
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(pooledBody))
+	resp.ContentLength = int64(len(pooledBody))
+	resp.Header.Del("Content-Length")
+}
@@ -0,0 +1,133 @@
+package relay
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"one-api/setting/model_setting"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setEmbeddingSplit(t *testing.T, mode string, maxTokens int) {
+	t.Helper()
+	settings := model_setting.GetEmbeddingSettings()
+	saved := *settings
+	settings.SplitMode = mode
+	settings.ModelMaxInputTokens = map[string]int{"text-embedding-3-small": maxTokens}
+	settings.WindowOverlapTokens = 0
+	t.Cleanup(func() { *settings = saved })
+}
+
+func TestSplitEmbeddingInput(t *testing.T) {
+	service.InitTokenEncoders()
+	gin.SetMode(gin.TestMode)
+	long := strings.Repeat("hello ", 10)
+	parts := service.SplitTextByTokens(long, "text-embedding-3-small", 4, 0)
+	if len(parts) < 2 {
+		t.Fatalf("long input split into %d windows, want several", len(parts))
+	}
+
+	tests := []struct {
+		name      string
+		mode      string
+		input     any
+		format    string
+		wantInput any
+		wantRange [][2]int
+	}{
+		{name: "off leaves the input alone", mode: model_setting.EmbeddingSplitModeOff, input: long, wantInput: long},
+		{name: "short input is left alone", mode: model_setting.EmbeddingSplitModePool, input: "hello", wantInput: "hello"},
+		{name: "truncate keeps the first window", mode: model_setting.EmbeddingSplitModeTruncate, input: long, wantInput: parts[0]},
+		{name: "pool splits into windows", mode: model_setting.EmbeddingSplitModePool, input: []any{"hi", long},
+			wantInput: append([]any{"hi"}, toAnySlice(parts)...), wantRange: [][2]int{{0, 1}, {1, 1 + len(parts)}}},
+		{name: "base64 responses cannot be pooled", mode: model_setting.EmbeddingSplitModePool, input: []any{long}, format: "base64",
+			wantInput: []any{parts[0]}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEmbeddingSplit(t, tt.mode, 4)
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/embeddings", nil)
+			info := &relaycommon.RelayInfo{UpstreamModelName: "text-embedding-3-small", ApiType: constant.APITypeOpenAI}
+			request := &dto.EmbeddingRequest{Model: "text-embedding-3-small", Input: tt.input, EncodingFormat: tt.format}
+
+			windows := splitEmbeddingInput(c, info, request)
+			got, _ := json.Marshal(request.Input)
+			want, _ := json.Marshal(tt.wantInput)
+			if string(got) != string(want) {
+				t.Errorf("input = %s, want %s", got, want)
+			}
+			if tt.wantRange == nil {
+				if windows != nil {
+					t.Errorf("windows = %+v, want none", windows)
+				}
+				return
+			}
+			if windows == nil || len(windows.ranges) != len(tt.wantRange) || windows.total != tt.wantRange[len(tt.wantRange)-1][1] {
+				t.Fatalf("windows = %+v, want ranges %v", windows, tt.wantRange)
+			}
+			for i, r := range tt.wantRange {
+				if windows.ranges[i] != r {
+					t.Errorf("range %d = %v, want %v", i, windows.ranges[i], r)
+				}
+			}
+		})
+	}
+}
+
+func toAnySlice(values []string) []any {
+	list := make([]any, len(values))
+	for i, value := range values {
+		list[i] = value
+	}
+	return list
+}
+
+func TestPoolEmbeddingResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/embeddings", nil)
+	windows := &embeddingWindows{ranges: [][2]int{{0, 1}, {1, 3}}, total: 3}
+	upstream := `{"object":"list","model":"text-embedding-3-small","data":[` +
+		`{"object":"embedding","index":0,"embedding":[0.6,0.8]},` +
+		`{"object":"embedding","index":2,"embedding":[0,1]},` +
+		`{"object":"embedding","index":1,"embedding":[1,0]}],` +
+		`"usage":{"prompt_tokens":9,"total_tokens":9}}`
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Length": {"1"}}, Body: io.NopCloser(strings.NewReader(upstream))}
+
+	poolEmbeddingResponse(c, resp, windows)
+	body, _ := io.ReadAll(resp.Body)
+	var pooled dto.OpenAIEmbeddingResponse
+	if err := json.Unmarshal(body, &pooled); err != nil {
+		t.Fatalf("decode pooled response %s: %v", body, err)
+	}
+	if len(pooled.Data) != 2 || pooled.Usage.PromptTokens != 9 {
+		t.Fatalf("pooled response = %s, want one embedding per original input and the usage kept", body)
+	}
+	want := [][]float64{{0.6, 0.8}, {math.Sqrt2 / 2, math.Sqrt2 / 2}}
+	for i, item := range pooled.Data {
+		if item.Index != i || len(item.Embedding) != 2 ||
+			math.Abs(item.Embedding[0]-want[i][0]) > 1e-9 || math.Abs(item.Embedding[1]-want[i][1]) > 1e-9 {
+			t.Errorf("embedding %d = %+v, want %v", i, item, want[i])
+		}
+	}
+	if resp.ContentLength != int64(len(body)) || resp.Header.Get("Content-Length") != "" {
+		t.Errorf("content length = %d, header %q, want the pooled body length", resp.ContentLength, resp.Header.Get("Content-Length"))
+	}
+
+	// 窗口数与响应不符时原样返回
+	resp.Body = io.NopCloser(strings.NewReader(upstream))
+	poolEmbeddingResponse(c, resp, &embeddingWindows{ranges: [][2]int{{0, 2}}, total: 2})
+	if body, _ := io.ReadAll(resp.Body); string(body) != upstream {
+		t.Errorf("mismatched response was rewritten: %s", body)
+	}
+}
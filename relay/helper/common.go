@@ -44,6 +44,11 @@ func ClaudeData(c *gin.Context, resp dto.ClaudeResponse) error {
 }
 
 func ClaudeChunkData(c *gin.Context, resp dto.ClaudeResponse, data string) {
+	data, flush := redactClaudeChunk(c, resp.Type, data)
+	if flush != "" {
+		c.Render(-1, common.CustomEvent{Data: "event: content_block_delta\n"})
+		c.Render(-1, common.CustomEvent{Data: fmt.Sprintf("data: %s\n", flush)})
+	}
 	c.Render(-1, common.CustomEvent{Data: fmt.Sprintf("event: %s\n", resp.Type)})
 	c.Render(-1, common.CustomEvent{Data: fmt.Sprintf("data: %s\n", data)})
 	if flusher, ok := c.Writer.(http.Flusher); ok {
@@ -62,6 +67,22 @@ func ResponseChunkData(c *gin.Context, resp dto.ResponsesStreamResponse, data st
 func StringData(c *gin.Context, str string) error {
 	//str = strings.TrimPrefix(str, "data: ")
 	//str = strings.TrimSuffix(str, "\r")
+	if str != "[DONE]" {
+		str = redactStreamChunk(c, str)
+	} else if err := FlushOutputRedaction(c); err != nil {
+		return err
+	}
+	return writeStringData(c, str)
+}
+
+func writeStringData(c *gin.Context, str string) error {
+	if str != "[DONE]" {
+		str = string(DowngradeToolCallsResponse(c, []byte(str), "delta"))
+	}
+	str, ok := transformSSEData(c, str)
+	if !ok {
+		return nil
+	}
 	c.Render(-1, common.CustomEvent{Data: "data: " + str})
 	if flusher, ok := c.Writer.(http.Flusher); ok {
 		flusher.Flush()
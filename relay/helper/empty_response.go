@@ -0,0 +1,25 @@
+package helper
+
+import (
+	"net/http"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+)
+
+const ErrorCodeEmptyResponse = "empty_response"
+
+// EmptyResponseError 非流式响应为空时返回可重试的错误，此时响应尚未写回客户端
+func EmptyResponseError(info *relaycommon.RelayInfo) *dto.OpenAIErrorWithStatusCode {
+	if info.IsStream || !operation_setting.GetRetrySetting().RetryOnEmptyResponse {
+		return nil
+	}
+	return &dto.OpenAIErrorWithStatusCode{
+		Error: dto.OpenAIError{
+			Message: "upstream returned an empty response",
+			Type:    "new_api_error",
+			Code:    ErrorCodeEmptyResponse,
+		},
+		StatusCode: http.StatusBadGateway,
+	}
+}
@@ -0,0 +1,30 @@
+package helper
+
+import (
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+const HedgeAttemptContextKey = "hedge_attempt"
+
+// HedgeRace 同一对冲请求的各次尝试共享，第一个完成响应的尝试成为胜者
+type HedgeRace struct {
+	winner atomic.Int32
+}
+
+type HedgeAttempt struct {
+	Race *HedgeRace
+	Id   int32
+}
+
+// ClaimHedgeWin reports whether the request may be billed. It is always true
+// for normal requests; for hedged attempts only the first caller wins.
+func ClaimHedgeWin(c *gin.Context) bool {
+	value, ok := c.Get(HedgeAttemptContextKey)
+	if !ok {
+		return true
+	}
+	attempt := value.(*HedgeAttempt)
+	return attempt.Race.winner.CompareAndSwap(0, attempt.Id) || attempt.Race.winner.Load() == attempt.Id
+}
@@ -0,0 +1,24 @@
+package helper
+
+import (
+	"one-api/common"
+	"one-api/constant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RepairUpstreamJson returns a repaired copy of a malformed non-streaming
+// upstream response body when REPAIR_UPSTREAM_JSON is enabled. Valid or
+// unrepairable bodies are returned unchanged.
+func RepairUpstreamJson(c *gin.Context, body []byte) []byte {
+	if !constant.RepairUpstreamJson || common.ValidJson(body) {
+		return body
+	}
+	repaired, ok := common.RepairJson(body)
+	if !ok {
+		common.LogWarn(c, "upstream response is malformed json and could not be repaired")
+		return body
+	}
+	common.LogWarn(c, "upstream response was malformed json, repaired before parsing")
+	return repaired
+}
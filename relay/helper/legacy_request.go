@@ -0,0 +1,153 @@
+package helper
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// legacyFunctionCallContextKey 客户端使用旧版 functions 调用时，响应中的 tool_calls 需改写回 function_call
+const legacyFunctionCallContextKey = "legacy_function_call"
+
+// UpgradeLegacyRequest rewrites deprecated chat completion fields in place:
+// functions/function_call become tools/tool_choice, assistant function_call
+// messages become tool_calls with matching tool role replies, and an integer
+// logprobs becomes logprobs=true plus top_logprobs. When functions were
+// upgraded, the response is later rewritten back to the function_call shape.
+func UpgradeLegacyRequest(c *gin.Context, request *dto.GeneralOpenAIRequest) error {
+	if len(request.Functions) > 0 && len(request.Tools) == 0 {
+		var functions []dto.FunctionRequest
+		if err := common.UnmarshalJson(request.Functions, &functions); err != nil {
+			return fmt.Errorf("invalid functions: %w", err)
+		}
+		for _, function := range functions {
+			request.Tools = append(request.Tools, dto.ToolCallRequest{Type: "function", Function: function})
+		}
+		request.Functions = nil
+		c.Set(legacyFunctionCallContextKey, true)
+	}
+	if len(request.FunctionCall) > 0 {
+		if request.ToolChoice == nil {
+			toolChoice, err := upgradeFunctionCallChoice(request.FunctionCall)
+			if err != nil {
+				return err
+			}
+			request.ToolChoice = toolChoice
+		}
+		request.FunctionCall = nil
+	}
+	upgradeLegacyMessages(request.Messages)
+
+	if len(request.LogProbs) > 0 {
+		if topLogProbs, err := strconv.Atoi(string(request.LogProbs)); err == nil {
+			request.LogProbs = json.RawMessage("true")
+			if request.TopLogProbs == 0 {
+				request.TopLogProbs = topLogProbs
+			}
+		}
+	}
+	return nil
+}
+
+// upgradeFunctionCallChoice 将 "auto"/"none"/{"name":...} 转换为 tool_choice
+func upgradeFunctionCallChoice(functionCall json.RawMessage) (any, error) {
+	var mode string
+	if err := common.UnmarshalJson(functionCall, &mode); err == nil {
+		return mode, nil
+	}
+	var named struct {
+		Name string `json:"name"`
+	}
+	if err := common.UnmarshalJson(functionCall, &named); err != nil || named.Name == "" {
+		return nil, fmt.Errorf("invalid function_call: %s", string(functionCall))
+	}
+	return map[string]any{
+		"type":     "function",
+		"function": map[string]any{"name": named.Name},
+	}, nil
+}
+
+// upgradeLegacyMessages 为每个 function_call 生成 tool call id，并关联到随后同名的 function 消息
+func upgradeLegacyMessages(messages []dto.Message) {
+	pending := make(map[string]string)
+	for i := range messages {
+		message := &messages[i]
+		if len(message.FunctionCall) > 0 && len(message.ToolCalls) == 0 {
+			var call struct {
+				Name      string `json:"name"`
+				Arguments string `json:"arguments"`
+			}
+			if err := common.UnmarshalJson(message.FunctionCall, &call); err == nil {
+				id := fmt.Sprintf("call_legacy_%d", i)
+				toolCalls, _ := common.EncodeJson([]map[string]any{{
+					"id":       id,
+					"type":     "function",
+					"function": map[string]any{"name": call.Name, "arguments": call.Arguments},
+				}})
+				message.ToolCalls = toolCalls
+				message.FunctionCall = nil
+				pending[call.Name] = id
+			}
+		}
+		if message.Role == "function" && message.Name != nil {
+			if id, ok := pending[*message.Name]; ok {
+				message.Role = "tool"
+				message.ToolCallId = id
+				delete(pending, *message.Name)
+			}
+		}
+	}
+}
+
+// DowngradeToolCallsResponse rewrites the first tool call of every choice's
+// message (non-streaming) or delta (streaming) back to function_call for
+// clients that sent legacy functions. The body is returned unchanged when
+// there is nothing to rewrite.
+func DowngradeToolCallsResponse(c *gin.Context, body []byte, field string) []byte {
+	if !c.GetBool(legacyFunctionCallContextKey) {
+		return body
+	}
+	var response map[string]any
+	if err := common.UnmarshalJson(body, &response); err != nil {
+		return body
+	}
+	choices, _ := response["choices"].([]any)
+	changed := false
+	for _, choice := range choices {
+		choiceMap, _ := choice.(map[string]any)
+		if choiceMap == nil {
+			continue
+		}
+		if choiceMap["finish_reason"] == "tool_calls" {
+			choiceMap["finish_reason"] = "function_call"
+			changed = true
+		}
+		message, _ := choiceMap[field].(map[string]any)
+		if message == nil {
+			continue
+		}
+		toolCalls, _ := message["tool_calls"].([]any)
+		if len(toolCalls) == 0 {
+			continue
+		}
+		if toolCall, ok := toolCalls[0].(map[string]any); ok {
+			if function, ok := toolCall["function"]; ok {
+				message["function_call"] = function
+			}
+		}
+		delete(message, "tool_calls")
+		changed = true
+	}
+	if !changed {
+		return body
+	}
+	downgraded, err := common.EncodeJson(response)
+	if err != nil {
+		return body
+	}
+	return downgraded
+}
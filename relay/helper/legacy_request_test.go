@@ -0,0 +1,143 @@
+package helper
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"one-api/dto"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func decodeLegacyTestRequest(t *testing.T, body string) *dto.GeneralOpenAIRequest {
+	t.Helper()
+	var request dto.GeneralOpenAIRequest
+	if err := json.Unmarshal([]byte(body), &request); err != nil {
+		t.Fatalf("decode request: %v", err)
+	}
+	return &request
+}
+
+func TestUpgradeLegacyRequestFunctions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	request := decodeLegacyTestRequest(t, `{
+		"model": "gpt-4o",
+		"functions": [{"name": "get_weather", "parameters": {"type": "object"}}],
+		"function_call": {"name": "get_weather"},
+		"messages": [
+			{"role": "user", "content": "weather?"},
+			{"role": "assistant", "content": null, "function_call": {"name": "get_weather", "arguments": "{\"city\":\"Paris\"}"}},
+			{"role": "function", "name": "get_weather", "content": "sunny"}
+		]
+	}`)
+
+	if err := UpgradeLegacyRequest(c, request); err != nil {
+		t.Fatalf("UpgradeLegacyRequest() error = %v", err)
+	}
+	if request.Functions != nil || request.FunctionCall != nil {
+		t.Errorf("legacy fields kept: functions=%s function_call=%s", request.Functions, request.FunctionCall)
+	}
+	if len(request.Tools) != 1 || request.Tools[0].Type != "function" || request.Tools[0].Function.Name != "get_weather" {
+		t.Errorf("tools = %+v, want the function as a tool", request.Tools)
+	}
+	wantChoice := map[string]any{"type": "function", "function": map[string]any{"name": "get_weather"}}
+	if !reflect.DeepEqual(request.ToolChoice, wantChoice) {
+		t.Errorf("tool_choice = %v, want %v", request.ToolChoice, wantChoice)
+	}
+
+	assistant, reply := request.Messages[1], request.Messages[2]
+	var toolCalls []struct {
+		Id       string `json:"id"`
+		Function struct {
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(assistant.ToolCalls, &toolCalls); err != nil || len(toolCalls) != 1 {
+		t.Fatalf("assistant tool_calls = %s, want one call", assistant.ToolCalls)
+	}
+	if toolCalls[0].Function.Name != "get_weather" || toolCalls[0].Function.Arguments != `{"city":"Paris"}` || assistant.FunctionCall != nil {
+		t.Errorf("assistant message = %+v, want the function_call moved to tool_calls", assistant)
+	}
+	if reply.Role != "tool" || reply.ToolCallId != toolCalls[0].Id {
+		t.Errorf("function reply role=%q tool_call_id=%q, want a tool reply to %q", reply.Role, reply.ToolCallId, toolCalls[0].Id)
+	}
+	if !c.GetBool(legacyFunctionCallContextKey) {
+		t.Error("response rewrite was not requested for legacy functions")
+	}
+}
+
+func TestUpgradeLegacyRequestFunctionCallMode(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	request := decodeLegacyTestRequest(t, `{"model":"gpt-4o","functions":[{"name":"f"}],"function_call":"none","messages":[]}`)
+	if err := UpgradeLegacyRequest(c, request); err != nil {
+		t.Fatalf("UpgradeLegacyRequest() error = %v", err)
+	}
+	if request.ToolChoice != "none" {
+		t.Errorf("tool_choice = %v, want none", request.ToolChoice)
+	}
+
+	request = decodeLegacyTestRequest(t, `{"model":"gpt-4o","functions":[{"name":"f"}],"function_call":{"arguments":"{}"},"messages":[]}`)
+	if err := UpgradeLegacyRequest(c, request); err == nil {
+		t.Error("function_call without a name was accepted")
+	}
+}
+
+func TestUpgradeLegacyRequestLogProbs(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		logProbs    string
+		topLogProbs int
+	}{
+		{name: "integer logprobs", body: `{"logprobs":3}`, logProbs: "true", topLogProbs: 3},
+		{name: "explicit top_logprobs wins", body: `{"logprobs":3,"top_logprobs":5}`, logProbs: "true", topLogProbs: 5},
+		{name: "boolean logprobs is kept", body: `{"logprobs":true,"top_logprobs":2}`, logProbs: "true", topLogProbs: 2},
+		{name: "absent", body: `{}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			request := decodeLegacyTestRequest(t, tt.body)
+			if err := UpgradeLegacyRequest(c, request); err != nil {
+				t.Fatalf("UpgradeLegacyRequest() error = %v", err)
+			}
+			if string(request.LogProbs) != tt.logProbs || request.TopLogProbs != tt.topLogProbs {
+				t.Errorf("logprobs=%s top_logprobs=%d, want %s %d", request.LogProbs, request.TopLogProbs, tt.logProbs, tt.topLogProbs)
+			}
+			if c.GetBool(legacyFunctionCallContextKey) {
+				t.Error("response rewrite requested without legacy functions")
+			}
+		})
+	}
+}
+
+func TestDowngradeToolCallsResponse(t *testing.T) {
+	body := `{"choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"f","arguments":"{}"}}]}}]}`
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if got := string(DowngradeToolCallsResponse(c, []byte(body), "message")); got != body {
+		t.Errorf("response rewritten for a client using tools: %s", got)
+	}
+
+	c.Set(legacyFunctionCallContextKey, true)
+	var response struct {
+		Choices []struct {
+			FinishReason string `json:"finish_reason"`
+			Message      struct {
+				ToolCalls    any               `json:"tool_calls"`
+				FunctionCall map[string]string `json:"function_call"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(DowngradeToolCallsResponse(c, []byte(body), "message"), &response); err != nil {
+		t.Fatalf("decode downgraded response: %v", err)
+	}
+	choice := response.Choices[0]
+	if choice.FinishReason != "function_call" || choice.Message.ToolCalls != nil ||
+		choice.Message.FunctionCall["name"] != "f" || choice.Message.FunctionCall["arguments"] != "{}" {
+		t.Errorf("downgraded choice = %+v, want a function_call", choice)
+	}
+}
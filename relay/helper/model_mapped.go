@@ -63,6 +63,9 @@ func ModelMappedHelper(c *gin.Context, info *common.RelayInfo, request any) erro
 		case common.RelayFormatOpenAIResponses:
 			if openAIResponsesRequest, ok := request.(*dto.OpenAIResponsesRequest); ok {
 				openAIResponsesRequest.Model = info.UpstreamModelName
+			} else if openAIRequest, ok := request.(*dto.GeneralOpenAIRequest); ok {
+				// responses 请求转换为 chat completions 后转发
+				openAIRequest.Model = info.UpstreamModelName
 			}
 		case common.RelayFormatOpenAIAudio:
 			if openAIAudioRequest, ok := request.(*dto.AudioRequest); ok {
@@ -0,0 +1,453 @@
+package helper
+
+import (
+	"one-api/common"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	outputRedactionContextKey       = "output_redaction"
+	outputRedactionCountContextKey  = "output_redaction_count"
+	outputRedactionStreamContextKey = "output_redaction_stream"
+)
+
+// SetupOutputRedaction enables output redaction for the request when its
+// group has patterns configured.
+func SetupOutputRedaction(c *gin.Context, info *relaycommon.RelayInfo) {
+	regexps := operation_setting.GetGroupRedactionRegexps(info.UsingGroup)
+	if len(regexps) == 0 {
+		return
+	}
+	c.Set(outputRedactionContextKey, regexps)
+}
+
+// GetOutputRedactionCount returns how many matches were masked so far.
+func GetOutputRedactionCount(c *gin.Context) int {
+	return c.GetInt(outputRedactionCountContextKey)
+}
+
+func getRedactionRegexps(c *gin.Context) []*regexp.Regexp {
+	value, ok := c.Get(outputRedactionContextKey)
+	if !ok {
+		return nil
+	}
+	return value.([]*regexp.Regexp)
+}
+
+func addRedactionCount(c *gin.Context, count int) {
+	if count > 0 {
+		c.Set(outputRedactionCountContextKey, c.GetInt(outputRedactionCountContextKey)+count)
+	}
+}
+
+// RedactOpenAIResponse masks the configured patterns in the content of every
+// choice's message of a non-streaming response and returns the re-encoded
+// body. The body is returned unchanged when nothing matched, so unrelated
+// fields keep their original encoding.
+func RedactOpenAIResponse(c *gin.Context, body []byte, field string) []byte {
+	regexps := getRedactionRegexps(c)
+	if regexps == nil {
+		return body
+	}
+	var response map[string]any
+	if err := common.UnmarshalJson(body, &response); err != nil {
+		return body
+	}
+	choices, _ := response["choices"].([]any)
+	count := 0
+	for _, choice := range choices {
+		choiceMap, _ := choice.(map[string]any)
+		message, _ := choiceMap[field].(map[string]any)
+		if message == nil {
+			continue
+		}
+		for _, key := range []string{"content", "reasoning_content"} {
+			message[key] = redactContent(message[key], regexps, &count)
+		}
+	}
+	return encodeRedactedBody(c, body, response, count)
+}
+
+// RedactClaudeResponse masks the configured patterns in the text and thinking
+// blocks of a non-streaming Claude response.
+func RedactClaudeResponse(c *gin.Context, body []byte) []byte {
+	regexps := getRedactionRegexps(c)
+	if regexps == nil {
+		return body
+	}
+	var response map[string]any
+	if err := common.UnmarshalJson(body, &response); err != nil {
+		return body
+	}
+	contents, _ := response["content"].([]any)
+	count := 0
+	for _, content := range contents {
+		contentMap, _ := content.(map[string]any)
+		for _, key := range []string{"text", "thinking"} {
+			if text, ok := contentMap[key].(string); ok {
+				contentMap[key] = redactString(text, regexps, &count)
+			}
+		}
+	}
+	return encodeRedactedBody(c, body, response, count)
+}
+
+// RedactGeminiResponse masks the configured patterns in the text parts of a
+// non-streaming Gemini response.
+func RedactGeminiResponse(c *gin.Context, body []byte) []byte {
+	regexps := getRedactionRegexps(c)
+	if regexps == nil {
+		return body
+	}
+	var response map[string]any
+	if err := common.UnmarshalJson(body, &response); err != nil {
+		return body
+	}
+	count := 0
+	for _, part := range geminiTextParts(response) {
+		part["text"] = redactString(part["text"].(string), regexps, &count)
+	}
+	return encodeRedactedBody(c, body, response, count)
+}
+
+func encodeRedactedBody(c *gin.Context, body []byte, response map[string]any, count int) []byte {
+	if count == 0 {
+		return body
+	}
+	redacted, err := common.EncodeJson(response)
+	if err != nil {
+		return body
+	}
+	addRedactionCount(c, count)
+	return redacted
+}
+
+func geminiTextParts(response map[string]any) []map[string]any {
+	var parts []map[string]any
+	candidates, _ := response["candidates"].([]any)
+	for _, candidate := range candidates {
+		candidateMap, _ := candidate.(map[string]any)
+		content, _ := candidateMap["content"].(map[string]any)
+		contentParts, _ := content["parts"].([]any)
+		for _, part := range contentParts {
+			partMap, _ := part.(map[string]any)
+			if _, ok := partMap["text"].(string); ok {
+				parts = append(parts, partMap)
+			}
+		}
+	}
+	return parts
+}
+
+func redactContent(content any, regexps []*regexp.Regexp, count *int) any {
+	switch v := content.(type) {
+	case string:
+		return redactString(v, regexps, count)
+	case []any:
+		for _, part := range v {
+			if partMap, ok := part.(map[string]any); ok {
+				if text, ok := partMap["text"].(string); ok {
+					partMap["text"] = redactString(text, regexps, count)
+				}
+			}
+		}
+	}
+	return content
+}
+
+func redactString(s string, regexps []*regexp.Regexp, count *int) string {
+	replacement := operation_setting.GetOutputRedactionSetting().Replacement
+	for _, re := range regexps {
+		s = re.ReplaceAllStringFunc(s, func(string) string {
+			*count++
+			return replacement
+		})
+	}
+	return s
+}
+
+// redactStreamText redacts the text that is safe to send and returns the
+// tail to hold back until the next chunk. The last holdback bytes are held
+// so that a match split across chunks is still found, and the cut is moved
+// before any match that reaches it, since more input could extend it.
+func redactStreamText(text string, regexps []*regexp.Regexp, holdback int, final bool, count *int) (emit string, hold string) {
+	if final {
+		return redactString(text, regexps, count), ""
+	}
+	cut := len(text) - holdback
+	if cut <= 0 {
+		return "", text
+	}
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	for moved := true; moved && cut > 0; {
+		moved = false
+		for _, re := range regexps {
+			for _, loc := range re.FindAllStringIndex(text, -1) {
+				if loc[0] < cut && loc[1] >= cut {
+					cut = loc[0]
+					moved = true
+				}
+			}
+		}
+	}
+	return redactString(text[:cut], regexps, count), text[cut:]
+}
+
+// redactionSlot identifies one text stream of a response: a choice or
+// candidate index, or a Claude content block index, and its field.
+type redactionSlot struct {
+	index int
+	field string
+}
+
+type streamRedactionState struct {
+	pending  map[redactionSlot]string
+	order    []redactionSlot
+	template map[string]any
+}
+
+func getStreamRedactionState(c *gin.Context) *streamRedactionState {
+	if value, ok := c.Get(outputRedactionStreamContextKey); ok {
+		return value.(*streamRedactionState)
+	}
+	state := &streamRedactionState{pending: make(map[redactionSlot]string)}
+	c.Set(outputRedactionStreamContextKey, state)
+	return state
+}
+
+// next joins the held tail of the slot with new text and returns the part
+// to send now.
+func (s *streamRedactionState) next(slot redactionSlot, text string, final bool, regexps []*regexp.Regexp, count *int) string {
+	pending, ok := s.pending[slot]
+	if !ok && text == "" {
+		return ""
+	}
+	holdback := operation_setting.GetOutputRedactionSetting().MaxMatchLength
+	emit, hold := redactStreamText(pending+text, regexps, holdback, final, count)
+	if hold == "" {
+		delete(s.pending, slot)
+	} else {
+		if !ok {
+			s.order = append(s.order, slot)
+		}
+		s.pending[slot] = hold
+	}
+	return emit
+}
+
+// take removes and returns the held tails in the order they were first held.
+func (s *streamRedactionState) take(regexps []*regexp.Regexp, count *int) []redactionSlot {
+	var slots []redactionSlot
+	for _, slot := range s.order {
+		if text, ok := s.pending[slot]; ok {
+			s.pending[slot] = redactString(text, regexps, count)
+			slots = append(slots, slot)
+		}
+	}
+	s.order = nil
+	return slots
+}
+
+// redactStreamChunk masks the configured patterns in an OpenAI or Gemini
+// stream chunk, holding back the tail of every text field until the next
+// chunk or the end of the choice.
+func redactStreamChunk(c *gin.Context, data string) string {
+	regexps := getRedactionRegexps(c)
+	if regexps == nil {
+		return data
+	}
+	var response map[string]any
+	if err := common.UnmarshalJsonStr(data, &response); err != nil {
+		return data
+	}
+	state := getStreamRedactionState(c)
+	state.template = response
+	count := 0
+	changed := false
+	if choices, ok := response["choices"].([]any); ok {
+		for i, choice := range choices {
+			choiceMap, _ := choice.(map[string]any)
+			index := redactionIndex(choiceMap, i)
+			final := choiceMap["finish_reason"] != nil && choiceMap["finish_reason"] != ""
+			delta, _ := choiceMap["delta"].(map[string]any)
+			if delta == nil {
+				continue
+			}
+			for _, key := range []string{"content", "reasoning_content"} {
+				text, isString := delta[key].(string)
+				if delta[key] != nil && !isString {
+					continue
+				}
+				slot := redactionSlot{index: index, field: key}
+				if _, held := state.pending[slot]; !held && text == "" {
+					continue
+				}
+				delta[key] = state.next(slot, text, final, regexps, &count)
+				changed = true
+			}
+		}
+	} else if candidates, ok := response["candidates"].([]any); ok {
+		for i, candidate := range candidates {
+			candidateMap, _ := candidate.(map[string]any)
+			index := redactionIndex(candidateMap, i)
+			final := candidateMap["finishReason"] != nil && candidateMap["finishReason"] != ""
+			content, _ := candidateMap["content"].(map[string]any)
+			parts, _ := content["parts"].([]any)
+			for _, part := range parts {
+				partMap, _ := part.(map[string]any)
+				text, ok := partMap["text"].(string)
+				if !ok {
+					continue
+				}
+				slot := redactionSlot{index: index, field: geminiPartField(partMap)}
+				partMap["text"] = state.next(slot, text, final, regexps, &count)
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return data
+	}
+	addRedactionCount(c, count)
+	redacted, err := common.EncodeJson(response)
+	if err != nil {
+		return data
+	}
+	return string(redacted)
+}
+
+// takeStreamRedactionChunk builds a chunk carrying the tails still held back
+// at the end of an OpenAI or Gemini stream.
+func takeStreamRedactionChunk(c *gin.Context) (string, bool) {
+	regexps := getRedactionRegexps(c)
+	value, ok := c.Get(outputRedactionStreamContextKey)
+	if regexps == nil || !ok {
+		return "", false
+	}
+	state := value.(*streamRedactionState)
+	count := 0
+	slots := state.take(regexps, &count)
+	if len(slots) == 0 {
+		return "", false
+	}
+	addRedactionCount(c, count)
+	chunk := make(map[string]any)
+	if _, ok := state.template["candidates"]; ok {
+		candidates := make([]any, 0, len(slots))
+		for _, slot := range slots {
+			part := map[string]any{"text": state.pending[slot]}
+			if slot.field == "thought" {
+				part["thought"] = true
+			}
+			candidates = append(candidates, map[string]any{
+				"index":   slot.index,
+				"content": map[string]any{"role": "model", "parts": []any{part}},
+			})
+		}
+		chunk["candidates"] = candidates
+	} else {
+		for _, key := range []string{"id", "object", "created", "model", "system_fingerprint"} {
+			if value, ok := state.template[key]; ok {
+				chunk[key] = value
+			}
+		}
+		choices := make([]any, 0, len(slots))
+		for _, slot := range slots {
+			choices = append(choices, map[string]any{
+				"index": slot.index,
+				"delta": map[string]any{slot.field: state.pending[slot]},
+			})
+		}
+		chunk["choices"] = choices
+	}
+	for _, slot := range slots {
+		delete(state.pending, slot)
+	}
+	data, err := common.EncodeJson(chunk)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// FlushOutputRedaction sends the text still held back by stream redaction.
+// Streams that end with [DONE] are flushed by Done; others must call it
+// after the last chunk.
+func FlushOutputRedaction(c *gin.Context) error {
+	data, ok := takeStreamRedactionChunk(c)
+	if !ok {
+		return nil
+	}
+	return writeStringData(c, data)
+}
+
+// redactClaudeChunk masks the configured patterns in a Claude stream event.
+// Text deltas hold back their tail until the next delta of the block; the
+// held text is returned as an extra delta event when the block stops.
+func redactClaudeChunk(c *gin.Context, eventType string, data string) (string, string) {
+	regexps := getRedactionRegexps(c)
+	if regexps == nil || (eventType != "content_block_delta" && eventType != "content_block_stop") {
+		return data, ""
+	}
+	var event map[string]any
+	if err := common.UnmarshalJsonStr(data, &event); err != nil {
+		return data, ""
+	}
+	index := redactionIndex(event, 0)
+	state := getStreamRedactionState(c)
+	count := 0
+	if eventType == "content_block_stop" {
+		var flush string
+		for _, field := range []string{"text", "thinking"} {
+			slot := redactionSlot{index: index, field: field}
+			if text, ok := state.pending[slot]; ok {
+				delete(state.pending, slot)
+				delta := map[string]any{"type": field + "_delta", field: redactString(text, regexps, &count)}
+				encoded, err := common.EncodeJson(map[string]any{"type": "content_block_delta", "index": index, "delta": delta})
+				if err == nil {
+					flush = string(encoded)
+				}
+			}
+		}
+		addRedactionCount(c, count)
+		return data, flush
+	}
+	delta, _ := event["delta"].(map[string]any)
+	changed := false
+	for _, field := range []string{"text", "thinking"} {
+		if text, ok := delta[field].(string); ok {
+			delta[field] = state.next(redactionSlot{index: index, field: field}, text, false, regexps, &count)
+			changed = true
+		}
+	}
+	if !changed {
+		return data, ""
+	}
+	addRedactionCount(c, count)
+	redacted, err := common.EncodeJson(event)
+	if err != nil {
+		return data, ""
+	}
+	return string(redacted), ""
+}
+
+func redactionIndex(item map[string]any, fallback int) int {
+	if index, ok := item["index"].(float64); ok {
+		return int(index)
+	}
+	return fallback
+}
+
+func geminiPartField(part map[string]any) string {
+	if thought, _ := part["thought"].(bool); thought {
+		return "thought"
+	}
+	return "text"
+}
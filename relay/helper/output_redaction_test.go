@@ -0,0 +1,134 @@
+package helper
+
+import (
+	"net/http/httptest"
+	"one-api/common"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+var testRedactionRegexps = []*regexp.Regexp{regexp.MustCompile(`\d{6}`)}
+
+func newRedactionTestContext() *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set(outputRedactionContextKey, testRedactionRegexps)
+	return c
+}
+
+func TestRedactStreamTextAcrossChunks(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   string
+	}{
+		{name: "split match", chunks: []string{"id 123", "456 ok"}, want: "id [REDACTED] ok"},
+		{name: "split in three", chunks: []string{"a 12", "34", "56 b"}, want: "a [REDACTED] b"},
+		{name: "no match", chunks: []string{"hello ", "world"}, want: "hello world"},
+		{name: "multibyte", chunks: []string{"号码：123", "456。"}, want: "号码：[REDACTED]。"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			hold := ""
+			count := 0
+			for i, chunk := range tt.chunks {
+				var emit string
+				emit, hold = redactStreamText(hold+chunk, testRedactionRegexps, 4, i == len(tt.chunks)-1, &count)
+				out.WriteString(emit)
+			}
+			if hold != "" || out.String() != tt.want {
+				t.Errorf("redacted stream = %q (held %q), want %q", out.String(), hold, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactStreamTextHoldsMatchAtCut(t *testing.T) {
+	count := 0
+	emit, hold := redactStreamText("abc 1234567", testRedactionRegexps, 2, false, &count)
+	if emit != "abc " || hold != "1234567" {
+		t.Errorf("redactStreamText() = %q, %q; want %q, %q", emit, hold, "abc ", "1234567")
+	}
+}
+
+func TestRedactStreamChunkOpenAI(t *testing.T) {
+	c := newRedactionTestContext()
+	chunks := []string{
+		`{"id":"x","choices":[{"index":0,"delta":{"content":"card 123"}}]}`,
+		`{"id":"x","choices":[{"index":0,"delta":{"content":"456 end"}}]}`,
+	}
+	var out strings.Builder
+	for _, chunk := range chunks {
+		out.WriteString(streamChunkContent(t, redactStreamChunk(c, chunk)))
+	}
+	flush, ok := takeStreamRedactionChunk(c)
+	if !ok {
+		t.Fatal("takeStreamRedactionChunk() returned no held text")
+	}
+	out.WriteString(streamChunkContent(t, flush))
+	if out.String() != "card [REDACTED] end" {
+		t.Errorf("redacted stream = %q", out.String())
+	}
+	if GetOutputRedactionCount(c) != 1 {
+		t.Errorf("GetOutputRedactionCount() = %d, want 1", GetOutputRedactionCount(c))
+	}
+}
+
+func TestRedactStreamChunkFlushesOnFinish(t *testing.T) {
+	c := newRedactionTestContext()
+	data := redactStreamChunk(c, `{"choices":[{"index":0,"delta":{"content":"pin 123456"}}]}`)
+	if got := streamChunkContent(t, data); got != "" {
+		t.Errorf("first chunk content = %q, want it held back", got)
+	}
+	data = redactStreamChunk(c, `{"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`)
+	if got := streamChunkContent(t, data); got != "pin [REDACTED]" {
+		t.Errorf("finish chunk content = %q, want %q", got, "pin [REDACTED]")
+	}
+	if _, ok := takeStreamRedactionChunk(c); ok {
+		t.Error("takeStreamRedactionChunk() returned text after the choice finished")
+	}
+}
+
+func TestRedactClaudeChunk(t *testing.T) {
+	c := newRedactionTestContext()
+	first, _ := redactClaudeChunk(c, "content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"no 123"}}`)
+	second, _ := redactClaudeChunk(c, "content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"456"}}`)
+	_, flush := redactClaudeChunk(c, "content_block_stop", `{"type":"content_block_stop","index":0}`)
+	var out strings.Builder
+	for _, data := range []string{first, second, flush} {
+		var event struct {
+			Delta struct {
+				Text string `json:"text"`
+			} `json:"delta"`
+		}
+		if err := common.UnmarshalJsonStr(data, &event); err != nil {
+			t.Fatalf("unmarshal %q: %v", data, err)
+		}
+		out.WriteString(event.Delta.Text)
+	}
+	if out.String() != "no [REDACTED]" {
+		t.Errorf("redacted stream = %q", out.String())
+	}
+}
+
+func streamChunkContent(t *testing.T, data string) string {
+	var chunk struct {
+		Choices []struct {
+			Delta struct {
+				Content string `json:"content"`
+			} `json:"delta"`
+		} `json:"choices"`
+	}
+	if err := common.UnmarshalJsonStr(data, &chunk); err != nil {
+		t.Fatalf("unmarshal %q: %v", data, err)
+	}
+	var content strings.Builder
+	for _, choice := range chunk.Choices {
+		content.WriteString(choice.Delta.Content)
+	}
+	return content.String()
+}
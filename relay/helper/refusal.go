@@ -0,0 +1,37 @@
+package helper
+
+import (
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"strings"
+)
+
+const finishReasonContentFilter = "content_filter"
+
+// RecordRefusal 判断上游响应是否为拒绝回答：带有 refusal 字段、结束原因为
+// content_filter/refusal，或是补全很短且以拒绝话术开头
+func RecordRefusal(info *relaycommon.RelayInfo, finishReason string, refusal string, content string, completionTokens int) {
+	setting := operation_setting.GetRefusalSetting()
+	if !setting.Enabled || info.Refusal {
+		return
+	}
+	if strings.TrimSpace(refusal) != "" || finishReason == finishReasonContentFilter || finishReason == "refusal" {
+		info.Refusal = true
+		return
+	}
+	if setting.MaxCompletionTokens > 0 && completionTokens > setting.MaxCompletionTokens {
+		return
+	}
+	content = normalizeRefusalText(content)
+	for _, pattern := range setting.Patterns {
+		// 只匹配开头，避免正常回答中引用的拒绝话术被误判
+		if pattern = normalizeRefusalText(pattern); pattern != "" && strings.HasPrefix(content, pattern) {
+			info.Refusal = true
+			return
+		}
+	}
+}
+
+func normalizeRefusalText(text string) string {
+	return strings.ToLower(strings.TrimSpace(strings.ReplaceAll(text, "’", "'")))
+}
@@ -0,0 +1,55 @@
+package helper
+
+import (
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"testing"
+)
+
+func TestRecordRefusal(t *testing.T) {
+	setting := operation_setting.GetRefusalSetting()
+	enabled := setting.Enabled
+	setting.Enabled = true
+	t.Cleanup(func() { setting.Enabled = enabled })
+
+	tests := []struct {
+		name             string
+		finishReason     string
+		refusal          string
+		content          string
+		completionTokens int
+		want             bool
+	}{
+		{name: "refusal field", refusal: "I can't help with that.", content: "", completionTokens: 500, want: true},
+		{name: "content filter", finishReason: "content_filter", completionTokens: 500, want: true},
+		{name: "refusal finish reason", finishReason: "refusal", want: true},
+		{name: "prefix", content: "I'm sorry, but I can't help with that.", completionTokens: 12, want: true},
+		{name: "prefix with curly apostrophe", content: "  I’m sorry, but I cannot do this", completionTokens: 10, want: true},
+		{name: "chinese prefix", content: "抱歉，我无法提供该信息。", completionTokens: 10, want: true},
+		{name: "pattern quoted in answer", content: "A typical refusal reads \"I can't help with that\".", completionTokens: 20, want: false},
+		{name: "long completion", content: "I'm sorry, but I can't stop here. Let me explain.", completionTokens: 1000, want: false},
+		{name: "normal answer", finishReason: "stop", content: "Sure, here is the answer.", completionTokens: 10, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := &relaycommon.RelayInfo{}
+			RecordRefusal(info, tt.finishReason, tt.refusal, tt.content, tt.completionTokens)
+			if info.Refusal != tt.want {
+				t.Errorf("RecordRefusal() = %v, want %v", info.Refusal, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordRefusalDisabled(t *testing.T) {
+	setting := operation_setting.GetRefusalSetting()
+	enabled := setting.Enabled
+	setting.Enabled = false
+	t.Cleanup(func() { setting.Enabled = enabled })
+
+	info := &relaycommon.RelayInfo{}
+	RecordRefusal(info, "content_filter", "no", "I'm sorry, but I can't", 1)
+	if info.Refusal {
+		t.Error("RecordRefusal() marked a refusal while disabled")
+	}
+}
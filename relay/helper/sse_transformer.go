@@ -0,0 +1,102 @@
+package helper
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-api/common"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/model_setting"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	SSETransformerPassthrough = "passthrough"
+	SSETransformerEnvelope    = "envelope"
+
+	sseTransformerContextKey = "sse_transformer"
+)
+
+// SSETransformer reshapes the payload of one OpenAI-format SSE data line
+// before it is written to the client. It is also called with "[DONE]" and
+// with the final usage chunk. Returning false drops the line.
+type SSETransformer func(c *gin.Context, data string) (string, bool)
+
+var (
+	sseTransformersLock sync.RWMutex
+	sseTransformers     = map[string]SSETransformer{
+		SSETransformerPassthrough: passthroughSSETransformer,
+		SSETransformerEnvelope:    envelopeSSETransformer,
+	}
+)
+
+// RegisterSSETransformer adds or replaces a named transformer.
+func RegisterSSETransformer(name string, transformer SSETransformer) {
+	sseTransformersLock.Lock()
+	defer sseTransformersLock.Unlock()
+	sseTransformers[name] = transformer
+}
+
+func getSSETransformer(name string) (SSETransformer, bool) {
+	sseTransformersLock.RLock()
+	defer sseTransformersLock.RUnlock()
+	transformer, ok := sseTransformers[name]
+	return transformer, ok
+}
+
+// SetupSSETransformer selects the transformer configured for the request's
+// model or group. Unknown names fall back to passthrough.
+func SetupSSETransformer(c *gin.Context, info *relaycommon.RelayInfo) {
+	if !info.IsStream {
+		return
+	}
+	name := model_setting.GetSSETransformerName(info.UsingGroup, info.OriginModelName)
+	if name == "" || name == SSETransformerPassthrough {
+		return
+	}
+	transformer, ok := getSSETransformer(name)
+	if !ok {
+		common.LogWarn(c, fmt.Sprintf("unknown sse transformer %s, using passthrough", name))
+		return
+	}
+	c.Set(sseTransformerContextKey, transformer)
+}
+
+func transformSSEData(c *gin.Context, data string) (string, bool) {
+	value, ok := c.Get(sseTransformerContextKey)
+	if !ok {
+		return data, true
+	}
+	return value.(SSETransformer)(c, data)
+}
+
+func passthroughSSETransformer(c *gin.Context, data string) (string, bool) {
+	return data, true
+}
+
+// envelopeSSETransformer wraps each chunk as {"type":"chunk","data":...}.
+// The usage-only chunk gets type "usage"; "[DONE]" is left unchanged. Lines
+// that are not JSON are embedded as a JSON string.
+func envelopeSSETransformer(c *gin.Context, data string) (string, bool) {
+	if data == "[DONE]" {
+		return data, true
+	}
+	var chunk struct {
+		Choices []any `json:"choices"`
+		Usage   any   `json:"usage"`
+	}
+	chunkType := "chunk"
+	payload := data
+	if !json.Valid(common.StringToByteSlice(data)) {
+		// 上游错误信息等非 JSON 内容按字符串嵌入，保证输出仍是合法 JSON
+		quoted, err := common.EncodeJson(data)
+		if err != nil {
+			return data, true
+		}
+		payload = string(quoted)
+	} else if err := common.UnmarshalJsonStr(data, &chunk); err == nil && len(chunk.Choices) == 0 && chunk.Usage != nil {
+		chunkType = "usage"
+	}
+	return fmt.Sprintf(`{"type":"%s","data":%s}`, chunkType, payload), true
+}
@@ -0,0 +1,97 @@
+package helper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/model_setting"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newSSETransformerTestContext(t *testing.T, modelName string, transformer string) (*gin.Context, *httptest.ResponseRecorder) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	settings := model_setting.GetGlobalSettings()
+	saved := settings.ModelSSETransformer
+	settings.ModelSSETransformer = map[string]string{modelName: transformer}
+	t.Cleanup(func() { settings.ModelSSETransformer = saved })
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	SetupSSETransformer(c, &relaycommon.RelayInfo{IsStream: true, UsingGroup: "default", OriginModelName: modelName})
+	return c, recorder
+}
+
+func TestRegisteredSSETransformerReshapesStream(t *testing.T) {
+	// 只保留增量文本，丢弃用量块和结束标记
+	RegisterSSETransformer("content-only", func(c *gin.Context, data string) (string, bool) {
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+		}
+		if data == "[DONE]" || common.UnmarshalJsonStr(data, &chunk) != nil || len(chunk.Choices) == 0 {
+			return "", false
+		}
+		quoted, _ := common.EncodeJson(map[string]string{"text": chunk.Choices[0].Delta.Content})
+		return string(quoted), true
+	})
+	t.Cleanup(func() {
+		sseTransformersLock.Lock()
+		delete(sseTransformers, "content-only")
+		sseTransformersLock.Unlock()
+	})
+	c, recorder := newSSETransformerTestContext(t, "gpt-4o", "content-only")
+
+	for _, data := range []string{
+		`{"choices":[{"delta":{"content":"Hel"}}]}`,
+		`{"choices":[{"delta":{"content":"lo"}}]}`,
+		`{"choices":[],"usage":{"total_tokens":3}}`,
+	} {
+		if err := StringData(c, data); err != nil {
+			t.Fatalf("StringData(%s): %v", data, err)
+		}
+	}
+	Done(c)
+
+	want := "data: {\"text\":\"Hel\"}\n\ndata: {\"text\":\"lo\"}\n\n"
+	if got := recorder.Body.String(); got != want {
+		t.Errorf("stream = %q, want %q", got, want)
+	}
+}
+
+func TestEnvelopeSSETransformer(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "chunk", data: `{"choices":[{"delta":{"content":"hi"}}]}`,
+			want: `{"type":"chunk","data":{"choices":[{"delta":{"content":"hi"}}]}}`},
+		{name: "usage chunk", data: `{"choices":[],"usage":{"total_tokens":3}}`,
+			want: `{"type":"usage","data":{"choices":[],"usage":{"total_tokens":3}}}`},
+		{name: "done is left unchanged", data: "[DONE]", want: "[DONE]"},
+		{name: "data that is not JSON is embedded as a string", data: `upstream error: "bad gateway"`,
+			want: `{"type":"chunk","data":"upstream error: \"bad gateway\""}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, recorder := newSSETransformerTestContext(t, "gpt-4o", SSETransformerEnvelope)
+			if err := writeStringData(c, tt.data); err != nil {
+				t.Fatalf("writeStringData(): %v", err)
+			}
+
+			got := strings.TrimSuffix(strings.TrimPrefix(recorder.Body.String(), "data: "), "\n\n")
+			if got != tt.want {
+				t.Errorf("frame = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,15 +3,19 @@ package helper
 import (
 	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"one-api/common"
 	"one-api/constant"
+	"one-api/dto"
 	relaycommon "one-api/relay/common"
 	"one-api/setting/operation_setting"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bytedance/gopkg/util/gopool"
@@ -106,12 +110,43 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 
 	ctx = context.WithValue(ctx, "stop_chan", stopChan)
 
+	// 启用输出限速时，上游数据读入缓冲区后由单独的 goroutine 按速率下发
+	var pacer *streamPacer
+	// 上游响应超出大小上限时置位，由最后写出数据的 goroutine 发送错误事件
+	var tooLarge atomic.Bool
+	if rate := streamThrottleRate(c); rate > 0 {
+		pacer = newStreamPacer(rate, time.Duration(operation_setting.GetStreamThrottleSetting().MaxDelaySeconds)*time.Second)
+	}
+
+	// writeData 调用 dataHandler 写出一条数据，返回 false 时停止读取
+	writeData := func(data string) bool {
+		// 使用超时机制防止写操作阻塞
+		done := make(chan bool, 1)
+		go func() {
+			writeMutex.Lock()
+			defer writeMutex.Unlock()
+			done <- dataHandler(data)
+		}()
+
+		select {
+		case success := <-done:
+			return success
+		case <-time.After(10 * time.Second):
+			common.LogError(c, "data handler timeout")
+			return false
+		case <-ctx.Done():
+			return false
+		case <-stopChan:
+			return false
+		}
+	}
+
 	// Handle ping data sending with improved error handling
 	if pingEnabled && pingTicker != nil {
 		wg.Add(1)
 		gopool.Go(func() {
+			defer wg.Done()
 			defer func() {
-				wg.Done()
 				if r := recover(); r != nil {
 					common.LogError(c, fmt.Sprintf("ping goroutine panic: %v", r))
 					common.SafeSendBool(stopChan, true)
@@ -169,15 +204,57 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 		})
 	}
 
+	// Pacer goroutine delivering buffered data at the throttled rate
+	if pacer != nil {
+		wg.Add(1)
+		gopool.Go(func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					common.LogError(c, fmt.Sprintf("pacer goroutine panic: %v", r))
+				}
+				cancel()
+				common.SafeSendBool(stopChan, true)
+				if common.DebugEnabled {
+					println("pacer goroutine exited")
+				}
+			}()
+
+			for {
+				data, ok := pacer.next(ctx)
+				if !ok {
+					if tooLarge.Load() && ctx.Err() == nil {
+						writeMutex.Lock()
+						writeStreamTooLargeError(c, info)
+						writeMutex.Unlock()
+					}
+					return
+				}
+				if !writeData(data) {
+					return
+				}
+				// 上游已读完时仍在下发，避免被判定为流超时
+				ticker.Reset(streamingTimeout)
+			}
+		})
+	}
+
 	// Scanner goroutine with improved error handling
 	wg.Add(1)
 	common.RelayCtxGo(ctx, func() {
+		upstreamDone := false
+		defer wg.Done()
 		defer func() {
-			wg.Done()
 			if r := recover(); r != nil {
 				common.LogError(c, fmt.Sprintf("scanner goroutine panic: %v", r))
 			}
-			common.SafeSendBool(stopChan, true)
+			if pacer != nil {
+				pacer.close()
+			}
+			// 限速时由 pacer 在缓冲数据发送完后结束流
+			if pacer == nil || !upstreamDone {
+				common.SafeSendBool(stopChan, true)
+			}
 			if common.DebugEnabled {
 				println("scanner goroutine exited")
 			}
@@ -212,33 +289,35 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 			data = strings.TrimSuffix(data, "\r")
 			if !strings.HasPrefix(data, "[DONE]") {
 				info.SetFirstResponseTime()
-
-				// 使用超时机制防止写操作阻塞
-				done := make(chan bool, 1)
-				go func() {
-					writeMutex.Lock()
-					defer writeMutex.Unlock()
-					done <- dataHandler(data)
-				}()
-
-				select {
-				case success := <-done:
-					if !success {
-						return
-					}
-				case <-time.After(10 * time.Second):
-					common.LogError(c, "data handler timeout")
-					return
-				case <-ctx.Done():
-					return
-				case <-stopChan:
+				if pacer != nil {
+					pacer.push(data)
+				} else if !writeData(data) {
 					return
 				}
 			}
 		}
+		upstreamDone = true
+		if pacer != nil && resp.Body != nil {
+			// 数据已全部缓冲，尽早释放上游连接
+			resp.Body.Close()
+		}
 
 		if err := scanner.Err(); err != nil {
-			if err != io.EOF {
+			if c.Request.Context().Err() != nil {
+				// 客户端断开后上游请求被取消，仅按已发送的内容计费
+				common.LogInfo(c, "upstream stream cancelled after client disconnected")
+			} else if errors.Is(err, common.ErrResponseTooLarge) {
+				// 上游响应超出大小上限，中断读取，仅按已发送的内容计费
+				common.LogWarn(c, fmt.Sprintf("upstream stream aborted: %s (%d bytes)", err.Error(), constant.MaxUpstreamResponseSize))
+				if pacer != nil {
+					// 限速时在缓冲数据发送完后再结束流
+					tooLarge.Store(true)
+				} else {
+					writeMutex.Lock()
+					writeStreamTooLargeError(c, info)
+					writeMutex.Unlock()
+				}
+			} else if err != io.EOF {
 				common.LogError(c, "scanner error: "+err.Error())
 			}
 		}
@@ -257,3 +336,25 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 		common.LogInfo(c, "client disconnected")
 	}
 }
+
+// writeStreamTooLargeError ends the stream with an error event, so that the
+// client can tell a response cut at the size limit from a complete one.
+func writeStreamTooLargeError(c *gin.Context, info *relaycommon.RelayInfo) {
+	message := fmt.Sprintf("%s (%d bytes)", common.ErrResponseTooLarge.Error(), constant.MaxUpstreamResponseSize)
+	if info.RelayFormat == relaycommon.RelayFormatClaude {
+		data, _ := json.Marshal(map[string]any{
+			"type":  "error",
+			"error": dto.ClaudeError{Type: "response_too_large", Message: message},
+		})
+		c.Render(-1, common.CustomEvent{Data: "event: error\n"})
+		c.Render(-1, common.CustomEvent{Data: fmt.Sprintf("data: %s", data)})
+	} else {
+		data, _ := json.Marshal(map[string]any{
+			"error": dto.OpenAIError{Message: message, Type: "new_api_error", Code: "response_too_large"},
+		})
+		c.Render(-1, common.CustomEvent{Data: fmt.Sprintf("data: %s", data)})
+	}
+	if flusher, ok := c.Writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,58 @@
+package helper
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	relaycommon "one-api/relay/common"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStreamScannerHandlerEndsOversizedStreamWithError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	streamingTimeout := constant.StreamingTimeout
+	constant.StreamingTimeout = 60
+	t.Cleanup(func() { constant.StreamingTimeout = streamingTimeout })
+	chunk := "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\n"
+	upstream := strings.Repeat(chunk, 10)
+
+	tests := []struct {
+		name   string
+		format string
+		want   string
+	}{
+		{name: "openai", format: relaycommon.RelayFormatOpenAI, want: `"code":"response_too_large"`},
+		{name: "claude", format: relaycommon.RelayFormatClaude, want: "event: error\ndata: {\"error\":{\"type\":\"response_too_large\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			resp := &http.Response{Body: io.NopCloser(strings.NewReader(upstream))}
+			common.LimitResponseBody(resp, int64(len(chunk)*3))
+
+			var received int
+			StreamScannerHandler(c, resp, &relaycommon.RelayInfo{RelayFormat: tt.format}, func(data string) bool {
+				received++
+				return StringData(c, data) == nil
+			})
+
+			if received != 3 {
+				t.Errorf("received %d chunks, want the 3 within the limit", received)
+			}
+			body := recorder.Body.String()
+			if !strings.Contains(body, tt.want) {
+				t.Fatalf("body = %q, want a terminal error event containing %q", body, tt.want)
+			}
+			if !strings.HasSuffix(body, "\n\n") || strings.LastIndex(body, "response_too_large") < strings.LastIndex(body, `"content":"hi"`) {
+				t.Errorf("error event is not the last event: %q", body)
+			}
+		})
+	}
+}
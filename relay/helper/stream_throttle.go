@@ -0,0 +1,186 @@
+package helper
+
+import (
+	"context"
+	"one-api/common"
+	"one-api/setting/operation_setting"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamThrottleRate returns the tokens per second the stream of the request
+// should be paced at, or 0 when it is not throttled. The client picks the
+// rate with the X-Stream-Tokens-Per-Second header, clamped to the range of
+// the setting.
+func streamThrottleRate(c *gin.Context) int {
+	setting := operation_setting.GetStreamThrottleSetting()
+	if !setting.Enabled {
+		return 0
+	}
+	rate := setting.DefaultTokensPerSecond
+	if value := c.GetHeader("X-Stream-Tokens-Per-Second"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			rate = parsed
+		}
+	}
+	if rate <= 0 {
+		return 0
+	}
+	if setting.MinTokensPerSecond > 0 && rate < setting.MinTokensPerSecond {
+		rate = setting.MinTokensPerSecond
+	}
+	if setting.MaxTokensPerSecond > 0 && rate > setting.MaxTokensPerSecond {
+		rate = setting.MaxTokensPerSecond
+	}
+	return rate
+}
+
+// estimateChunkTokens roughly counts the tokens of the text carried by a
+// chunk: four ASCII characters or one other character per token. Only string
+// values are counted, so chunks with just a role or usage cost nothing.
+func estimateChunkTokens(data string) int {
+	var chunk any
+	if err := common.UnmarshalJsonStr(data, &chunk); err != nil {
+		return 0
+	}
+	ascii, other := 0, 0
+	var walk func(value any)
+	walk = func(value any) {
+		switch v := value.(type) {
+		case string:
+			for _, r := range v {
+				if r < utf8.RuneSelf {
+					ascii++
+				} else {
+					other++
+				}
+			}
+		case map[string]any:
+			for key, item := range v {
+				switch key {
+				case "id", "object", "model", "role", "type", "finish_reason", "stop_reason", "system_fingerprint", "usage", "usageMetadata", "signature":
+					continue
+				}
+				walk(item)
+			}
+		case []any:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	walk(chunk)
+	return (ascii+3)/4 + other
+}
+
+// streamPacer buffers the chunks read from upstream and hands them out to
+// the writer, so the upstream body can be read to the end at full speed
+// while the client receives the chunks at the throttled rate. Once the
+// chunks have been held back for maxDelay in total, the rest is sent
+// without pacing.
+type streamPacer struct {
+	rate     int
+	maxDelay time.Duration
+	delayed  time.Duration
+	mu       sync.Mutex
+	queue    []string
+	closed   bool
+	notify   chan struct{}
+	ready    time.Time
+}
+
+func newStreamPacer(rate int, maxDelay time.Duration) *streamPacer {
+	return &streamPacer{
+		rate:     rate,
+		maxDelay: maxDelay,
+		notify:   make(chan struct{}, 1),
+	}
+}
+
+func (p *streamPacer) push(data string) {
+	p.mu.Lock()
+	p.queue = append(p.queue, data)
+	p.mu.Unlock()
+	p.signal()
+}
+
+// close marks the end of the upstream stream; the buffered chunks are still
+// delivered.
+func (p *streamPacer) close() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	p.signal()
+}
+
+func (p *streamPacer) signal() {
+	select {
+	case p.notify <- struct{}{}:
+	default:
+	}
+}
+
+// next waits for the next chunk and until it may be sent. It returns false
+// once the stream is closed and drained, or when ctx is done.
+func (p *streamPacer) next(ctx context.Context) (string, bool) {
+	for {
+		p.mu.Lock()
+		if len(p.queue) > 0 {
+			data := p.queue[0]
+			p.queue[0] = ""
+			p.queue = p.queue[1:]
+			p.mu.Unlock()
+			if !p.wait(ctx) {
+				return "", false
+			}
+			p.advance(estimateChunkTokens(data))
+			return data, true
+		}
+		closed := p.closed
+		p.mu.Unlock()
+		if closed {
+			return "", false
+		}
+		select {
+		case <-p.notify:
+		case <-ctx.Done():
+			return "", false
+		}
+	}
+}
+
+// wait blocks until the tokens of the previously sent chunks have been paid
+// for at the configured rate, as long as the delay budget is not used up.
+func (p *streamPacer) wait(ctx context.Context) bool {
+	delay := time.Until(p.ready)
+	if p.maxDelay > 0 {
+		delay = min(delay, p.maxDelay-p.delayed)
+	}
+	if delay <= 0 {
+		return true
+	}
+	p.delayed += delay
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (p *streamPacer) advance(tokens int) {
+	if tokens <= 0 {
+		return
+	}
+	now := time.Now()
+	if p.ready.Before(now) {
+		p.ready = now
+	}
+	p.ready = p.ready.Add(time.Duration(tokens) * time.Second / time.Duration(p.rate))
+}
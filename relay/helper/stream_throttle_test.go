@@ -0,0 +1,73 @@
+package helper
+
+import (
+	"context"
+	"net/http/httptest"
+	"one-api/setting/operation_setting"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStreamThrottleRate(t *testing.T) {
+	setting := operation_setting.GetStreamThrottleSetting()
+	saved := *setting
+	*setting = operation_setting.StreamThrottleSetting{Enabled: true, DefaultTokensPerSecond: 50, MaxTokensPerSecond: 200, MinTokensPerSecond: 10}
+	t.Cleanup(func() { *setting = saved })
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{name: "default", header: "", want: 50},
+		{name: "client rate", header: "80", want: 80},
+		{name: "above max", header: "1000", want: 200},
+		{name: "below min", header: "1", want: 10},
+		{name: "disabled by client", header: "0", want: 0},
+	}
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+			if tt.header != "" {
+				c.Request.Header.Set("X-Stream-Tokens-Per-Second", tt.header)
+			}
+			if got := streamThrottleRate(c); got != tt.want {
+				t.Errorf("streamThrottleRate() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamPacerMaxDelay(t *testing.T) {
+	// Each chunk costs 100 tokens, i.e. 10s at 10 tokens/s, but the whole
+	// stream may only be held back for 50ms.
+	chunk := `{"choices":[{"delta":{"content":"` + strings.Repeat("字", 100) + `"}}]}`
+	pacer := newStreamPacer(10, 50*time.Millisecond)
+	for i := 0; i < 4; i++ {
+		pacer.push(chunk)
+	}
+	pacer.close()
+
+	start := time.Now()
+	sent := 0
+	for {
+		if _, ok := pacer.next(context.Background()); !ok {
+			break
+		}
+		sent++
+	}
+	if sent != 4 {
+		t.Fatalf("sent %d chunks, want 4", sent)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("pacing took %v, want it bounded by the delay budget", elapsed)
+	}
+	if pacer.delayed != 50*time.Millisecond {
+		t.Errorf("delayed = %v, want 50ms", pacer.delayed)
+	}
+}
@@ -0,0 +1,82 @@
+package helper
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ErrorCodeResponseTruncated = "response_truncated"
+
+	// 由 controller 设置：本次请求截断时是否允许换渠道重试
+	TruncationRetryAllowedContextKey = "truncation_retry_allowed"
+	// 截断重试时使用的 max_tokens
+	TruncationRetryMaxTokensContextKey = "truncation_retry_max_tokens"
+)
+
+const finishReasonLength = "length"
+
+// RecordFinishReason 记录上游的结束原因，并按配置判断补全是否被截断
+func RecordFinishReason(info *relaycommon.RelayInfo, reason string, completionTokens int) {
+	if reason == "max_tokens" {
+		reason = finishReasonLength
+	}
+	if info.UpstreamFinishReason != finishReasonLength {
+		info.UpstreamFinishReason = reason
+	}
+	if info.UpstreamFinishReason == finishReasonLength {
+		info.Truncated = true
+		return
+	}
+	minTokens := operation_setting.GetTruncationSetting().MinCompletionTokens
+	if minTokens > 0 && info.UpstreamFinishReason != "tool_calls" && completionTokens < minTokens {
+		info.Truncated = true
+	}
+}
+
+// SetupTruncationRetry 记录请求的 max_tokens，截断重试时按重试值调大
+func SetupTruncationRetry(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) {
+	if retryMaxTokens := c.GetInt(TruncationRetryMaxTokensContextKey); retryMaxTokens > 0 {
+		if request.MaxCompletionTokens > 0 {
+			request.MaxCompletionTokens = uint(retryMaxTokens)
+		} else {
+			request.MaxTokens = uint(retryMaxTokens)
+		}
+	}
+	info.RequestMaxTokens = int(max(request.MaxTokens, request.MaxCompletionTokens))
+}
+
+// TruncationRetryError 非流式响应因 length 截断且允许重试时返回重试错误，此时响应尚未写回客户端。
+// 返回该错误时 handler 同时返回用量，调用方在重试前按实际用量计费
+func TruncationRetryError(c *gin.Context, info *relaycommon.RelayInfo, completionTokens int) *dto.OpenAIErrorWithStatusCode {
+	if info.IsStream || info.UpstreamFinishReason != finishReasonLength || !c.GetBool(TruncationRetryAllowedContextKey) {
+		return nil
+	}
+	retryMaxTokens := operation_setting.GetTruncationRetryMaxTokens(info.RequestMaxTokens, completionTokens)
+	if retryMaxTokens <= info.RequestMaxTokens {
+		return nil
+	}
+	c.Set(TruncationRetryMaxTokensContextKey, retryMaxTokens)
+	return &dto.OpenAIErrorWithStatusCode{
+		Error: dto.OpenAIError{
+			Message: fmt.Sprintf("response truncated at %d completion tokens, retrying with max_tokens %d", completionTokens, retryMaxTokens),
+			Type:    "new_api_error",
+			Code:    ErrorCodeResponseTruncated,
+		},
+		StatusCode: http.StatusBadGateway,
+	}
+}
+
+// TruncatedUsage 返回被截断丢弃的响应的用量，非截断重试错误时返回 nil
+func TruncatedUsage(openaiErr *dto.OpenAIErrorWithStatusCode, usage any) *dto.Usage {
+	if openaiErr == nil || openaiErr.Error.Code != ErrorCodeResponseTruncated {
+		return nil
+	}
+	truncatedUsage, _ := usage.(*dto.Usage)
+	return truncatedUsage
+}
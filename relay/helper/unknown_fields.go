@@ -0,0 +1,74 @@
+package helper
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var knownFieldsCache sync.Map // reflect.Type -> map[string]bool
+
+// knownJSONFields returns the json keys a struct type understands, including
+// the keys of embedded structs.
+func knownJSONFields(t reflect.Type) map[string]bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if cached, ok := knownFieldsCache.Load(t); ok {
+		return cached.(map[string]bool)
+	}
+	fields := make(map[string]bool)
+	if t.Kind() == reflect.Struct {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := field.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name := strings.Split(tag, ",")[0]
+			if field.Anonymous && name == "" {
+				for key := range knownJSONFields(field.Type) {
+					fields[key] = true
+				}
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			fields[name] = true
+		}
+	}
+	knownFieldsCache.Store(t, fields)
+	return fields
+}
+
+// PreserveUnknownFields copies the keys of the original request body that the
+// request struct does not declare into the converted body, keeping their raw
+// JSON so values such as large integers are not altered.
+func PreserveUnknownFields(original []byte, converted []byte, request any) ([]byte, error) {
+	var originalMap map[string]json.RawMessage
+	if err := json.Unmarshal(original, &originalMap); err != nil {
+		return nil, err
+	}
+	known := knownJSONFields(reflect.TypeOf(request))
+	var convertedMap map[string]json.RawMessage
+	if err := json.Unmarshal(converted, &convertedMap); err != nil {
+		return nil, err
+	}
+	added := false
+	for key, value := range originalMap {
+		if known[key] {
+			continue
+		}
+		if _, exists := convertedMap[key]; exists {
+			continue
+		}
+		convertedMap[key] = value
+		added = true
+	}
+	if !added {
+		return converted, nil
+	}
+	return json.Marshal(convertedMap)
+}
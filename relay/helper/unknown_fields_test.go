@@ -0,0 +1,73 @@
+package helper
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type unknownFieldsBase struct {
+	Model string `json:"model"`
+}
+
+type unknownFieldsRequest struct {
+	unknownFieldsBase
+	Stream    bool   `json:"stream,omitempty"`
+	MaxTokens int    `json:"max_tokens"`
+	Internal  string `json:"-"`
+	Untagged  string
+}
+
+func TestPreserveUnknownFields(t *testing.T) {
+	tests := []struct {
+		name      string
+		original  string
+		converted string
+		want      string
+	}{
+		{name: "unknown keys keep their raw value", original: `{"model":"gpt-4o","seed":12345678901234567890,"extra":{"a":[1,2]}}`,
+			converted: `{"model":"gpt-4o"}`, want: `{"model":"gpt-4o","seed":12345678901234567890,"extra":{"a":[1,2]}}`},
+		{name: "known keys dropped by the conversion stay dropped", original: `{"model":"gpt-4o","max_tokens":10,"stream":true}`,
+			converted: `{"model":"gpt-4o"}`, want: `{"model":"gpt-4o"}`},
+		{name: "converted value wins", original: `{"model":"gpt-4o","extra":"original"}`,
+			converted: `{"model":"gpt-4o","extra":"converted"}`, want: `{"model":"gpt-4o","extra":"converted"}`},
+		{name: "untagged field uses the field name", original: `{"model":"gpt-4o","Untagged":"x"}`,
+			converted: `{"model":"gpt-4o"}`, want: `{"model":"gpt-4o"}`},
+		{name: "ignored field is unknown", original: `{"model":"gpt-4o","Internal":"x","-":"y"}`,
+			converted: `{"model":"gpt-4o"}`, want: `{"model":"gpt-4o","Internal":"x","-":"y"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PreserveUnknownFields([]byte(tt.original), []byte(tt.converted), &unknownFieldsRequest{})
+			if err != nil {
+				t.Fatalf("PreserveUnknownFields() error = %v", err)
+			}
+			var gotMap, wantMap map[string]json.RawMessage
+			if err := json.Unmarshal(got, &gotMap); err != nil {
+				t.Fatalf("decode result %s: %v", got, err)
+			}
+			_ = json.Unmarshal([]byte(tt.want), &wantMap)
+			if !reflect.DeepEqual(gotMap, wantMap) {
+				t.Errorf("PreserveUnknownFields() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// 没有未知字段时原样返回转换结果
+	converted := []byte(`{"model": "gpt-4o"}`)
+	got, err := PreserveUnknownFields([]byte(`{"model":"gpt-4o"}`), converted, unknownFieldsRequest{})
+	if err != nil || string(got) != string(converted) {
+		t.Errorf("PreserveUnknownFields() without unknown keys = %s, %v, want the converted body unchanged", got, err)
+	}
+	if _, err := PreserveUnknownFields([]byte(`{"model":`), converted, unknownFieldsRequest{}); err == nil {
+		t.Error("PreserveUnknownFields() with an invalid original body returned no error")
+	}
+}
+
+func TestKnownJSONFields(t *testing.T) {
+	got := knownJSONFields(reflect.TypeOf(&unknownFieldsRequest{}))
+	want := map[string]bool{"model": true, "stream": true, "max_tokens": true, "Untagged": true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("knownJSONFields() = %v, want %v", got, want)
+	}
+}
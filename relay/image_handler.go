@@ -16,6 +16,7 @@ import (
 	"one-api/relay/helper"
 	"one-api/service"
 	"one-api/setting"
+	"one-api/setting/model_setting"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -121,10 +122,20 @@ func ImageHelper(c *gin.Context) *dto.OpenAIErrorWithStatusCode {
 		return service.OpenAIErrorWrapperLocal(err, "model_mapped_error", http.StatusInternalServerError)
 	}
 
+	imagePrice, hasImagePrice, err := model_setting.GetImagePrice(relayInfo.OriginModelName, imageRequest.Size, imageRequest.Quality)
+	if err != nil {
+		return service.OpenAIErrorWrapperLocal(err, "invalid_image_request", http.StatusBadRequest)
+	}
+
 	priceData, err := helper.ModelPriceHelper(c, relayInfo, len(imageRequest.Prompt), 0)
 	if err != nil {
 		return service.OpenAIErrorWrapperLocal(err, "model_price_error", http.StatusInternalServerError)
 	}
+	if hasImagePrice {
+		// 按尺寸与品质价格表计费，覆盖模型倍率或固定价格
+		priceData.UsePrice = true
+		priceData.ModelPrice = imagePrice
+	}
 	var preConsumedQuota int
 	var quota int
 	var userQuota int
@@ -145,22 +156,24 @@ func ImageHelper(c *gin.Context) *dto.OpenAIErrorWithStatusCode {
 
 	} else {
 		sizeRatio := 1.0
-		// Size
-		if imageRequest.Size == "256x256" {
-			sizeRatio = 0.4
-		} else if imageRequest.Size == "512x512" {
-			sizeRatio = 0.45
-		} else if imageRequest.Size == "1024x1024" {
-			sizeRatio = 1
-		} else if imageRequest.Size == "1024x1792" || imageRequest.Size == "1792x1024" {
-			sizeRatio = 2
-		}
-
 		qualityRatio := 1.0
-		if imageRequest.Model == "dall-e-3" && imageRequest.Quality == "hd" {
-			qualityRatio = 2.0
-			if imageRequest.Size == "1024x1792" || imageRequest.Size == "1792x1024" {
-				qualityRatio = 1.5
+		if !hasImagePrice {
+			// Size
+			if imageRequest.Size == "256x256" {
+				sizeRatio = 0.4
+			} else if imageRequest.Size == "512x512" {
+				sizeRatio = 0.45
+			} else if imageRequest.Size == "1024x1024" {
+				sizeRatio = 1
+			} else if imageRequest.Size == "1024x1792" || imageRequest.Size == "1792x1024" {
+				sizeRatio = 2
+			}
+
+			if imageRequest.Model == "dall-e-3" && imageRequest.Quality == "hd" {
+				qualityRatio = 2.0
+				if imageRequest.Size == "1024x1792" || imageRequest.Size == "1792x1024" {
+					qualityRatio = 1.5
+				}
 			}
 		}
 
@@ -239,6 +252,15 @@ func ImageHelper(c *gin.Context) *dto.OpenAIErrorWithStatusCode {
 	}
 
 	logContent := fmt.Sprintf("大小 %s, 品质 %s", imageRequest.Size, quality)
+	if hasImagePrice {
+		logContent += fmt.Sprintf(", 单价 $%.4f x %d 张", imagePrice, imageRequest.N)
+		c.Set("image_price_breakdown", map[string]interface{}{
+			"size":        imageRequest.Size,
+			"quality":     imageRequest.Quality,
+			"n":           imageRequest.N,
+			"image_price": imagePrice,
+		})
+	}
 	postConsumeQuota(c, relayInfo, usage.(*dto.Usage), preConsumedQuota, userQuota, priceData, logContent)
 	return nil
 }
@@ -0,0 +1,41 @@
+package relay
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+func isJsonResponseFormat(format *dto.ResponseFormat) bool {
+	return format != nil && (format.Type == "json_object" || format.Type == "json_schema")
+}
+
+// applyJsonModePolicy enforces the group's JSON mode policy on chat
+// completion requests, injecting a json_object response format or rejecting
+// requests that do not ask for JSON output. Rejections are marked on the
+// context so that the relay controller records them in the request log.
+func applyJsonModePolicy(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) error {
+	if info.RelayMode != relayconstant.RelayModeChatCompletions {
+		return nil
+	}
+	action := operation_setting.GetGroupJsonModePolicy(info.UsingGroup)
+	if action == "" || isJsonResponseFormat(request.ResponseFormat) {
+		return nil
+	}
+	switch action {
+	case operation_setting.JsonModeActionRequire:
+		common.LogInfo(c, fmt.Sprintf("rejected request without JSON response_format for group %s", info.UsingGroup))
+		c.Set("json_mode_rejected", true)
+		return fmt.Errorf("group %s requires response_format of type json_object or json_schema", info.UsingGroup)
+	case operation_setting.JsonModeActionInject:
+		request.ResponseFormat = &dto.ResponseFormat{Type: "json_object"}
+		info.JsonModeInjected = true
+		common.LogInfo(c, "injected response_format json_object")
+	}
+	return nil
+}
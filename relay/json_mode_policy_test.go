@@ -0,0 +1,64 @@
+package relay
+
+import (
+	relayconstant "one-api/relay/constant"
+	"one-api/setting/operation_setting"
+	"testing"
+)
+
+func setGroupJsonModePolicy(t *testing.T, action string) {
+	t.Helper()
+	setting := operation_setting.GetJsonModeSetting()
+	saved := setting.GroupPolicies
+	setting.GroupPolicies = map[string]string{"default": action}
+	t.Cleanup(func() { setting.GroupPolicies = saved })
+}
+
+func TestApplyJsonModePolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		action   string
+		body     string
+		format   string
+		injected bool
+		rejected bool
+	}{
+		{name: "inject adds json_object", action: operation_setting.JsonModeActionInject,
+			body: `{"model":"gpt-4o","messages":[]}`, format: "json_object", injected: true},
+		{name: "inject keeps json_schema", action: operation_setting.JsonModeActionInject,
+			body: `{"model":"gpt-4o","messages":[],"response_format":{"type":"json_schema"}}`, format: "json_schema"},
+		{name: "require rejects text output", action: operation_setting.JsonModeActionRequire,
+			body: `{"model":"gpt-4o","messages":[],"response_format":{"type":"text"}}`, rejected: true},
+		{name: "require accepts json_object", action: operation_setting.JsonModeActionRequire,
+			body: `{"model":"gpt-4o","messages":[],"response_format":{"type":"json_object"}}`, format: "json_object"},
+		{name: "no policy", body: `{"model":"gpt-4o","messages":[]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setGroupJsonModePolicy(t, tt.action)
+			c, info, request := newParamPolicyTestRequest(t, tt.body)
+			info.RelayMode = relayconstant.RelayModeChatCompletions
+
+			err := applyJsonModePolicy(c, info, request)
+			if (err != nil) != tt.rejected {
+				t.Fatalf("applyJsonModePolicy() error = %v, rejected want %v", err, tt.rejected)
+			}
+			if got := c.GetBool("json_mode_rejected"); got != tt.rejected {
+				t.Errorf("json_mode_rejected = %v, want %v", got, tt.rejected)
+			}
+			if tt.rejected {
+				return
+			}
+			if info.JsonModeInjected != tt.injected {
+				t.Errorf("JsonModeInjected = %v, want %v", info.JsonModeInjected, tt.injected)
+			}
+			format := ""
+			if request.ResponseFormat != nil {
+				format = request.ResponseFormat.Type
+			}
+			if format != tt.format {
+				t.Errorf("response_format = %q, want %q", format, tt.format)
+			}
+		})
+	}
+}
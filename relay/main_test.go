@@ -0,0 +1,13 @@
+package relay
+
+import (
+	"one-api/common"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// 测试环境没有 Redis，计费后的异步缓存更新也不能访问 Redis
+	common.RedisEnabled = false
+	os.Exit(m.Run())
+}
@@ -0,0 +1,157 @@
+package relay
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+type paramAccessor struct {
+	get func(r *dto.GeneralOpenAIRequest) float64
+	set func(r *dto.GeneralOpenAIRequest, v float64)
+}
+
+var paramAccessors = map[string]paramAccessor{
+	"temperature": {
+		get: func(r *dto.GeneralOpenAIRequest) float64 {
+			if r.Temperature == nil {
+				return 0
+			}
+			return *r.Temperature
+		},
+		set: func(r *dto.GeneralOpenAIRequest, v float64) { r.Temperature = &v },
+	},
+	"top_p": {
+		get: func(r *dto.GeneralOpenAIRequest) float64 { return r.TopP },
+		set: func(r *dto.GeneralOpenAIRequest, v float64) { r.TopP = v },
+	},
+	"top_k": {
+		get: func(r *dto.GeneralOpenAIRequest) float64 { return float64(r.TopK) },
+		set: func(r *dto.GeneralOpenAIRequest, v float64) { r.TopK = int(v) },
+	},
+	"n": {
+		get: func(r *dto.GeneralOpenAIRequest) float64 { return float64(r.N) },
+		set: func(r *dto.GeneralOpenAIRequest, v float64) { r.N = int(v) },
+	},
+	"max_tokens": {
+		get: func(r *dto.GeneralOpenAIRequest) float64 { return float64(r.MaxTokens) },
+		set: func(r *dto.GeneralOpenAIRequest, v float64) { r.MaxTokens = uint(max(v, 0)) },
+	},
+	"max_completion_tokens": {
+		get: func(r *dto.GeneralOpenAIRequest) float64 { return float64(r.MaxCompletionTokens) },
+		set: func(r *dto.GeneralOpenAIRequest, v float64) { r.MaxCompletionTokens = uint(max(v, 0)) },
+	},
+	"frequency_penalty": {
+		get: func(r *dto.GeneralOpenAIRequest) float64 { return r.FrequencyPenalty },
+		set: func(r *dto.GeneralOpenAIRequest, v float64) { r.FrequencyPenalty = v },
+	},
+	"presence_penalty": {
+		get: func(r *dto.GeneralOpenAIRequest) float64 { return r.PresencePenalty },
+		set: func(r *dto.GeneralOpenAIRequest, v float64) { r.PresencePenalty = v },
+	},
+}
+
+// applyParamPolicy enforces the group's parameter bounds on parameters the
+// client set, explicit zeros included, and on applied defaults. Out-of-range
+// values are clamped or rejected depending on the configured action; clamps
+// are logged and recorded on the relay info.
+func applyParamPolicy(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) error {
+	policy := operation_setting.GetGroupParamPolicy(info.UsingGroup)
+	if len(policy) == 0 {
+		return nil
+	}
+	keys := requestParamKeys(c, info)
+	defaults := operation_setting.GetGroupParamDefaults(info.UsingGroup)
+	names := make([]string, 0, len(policy))
+	for name := range policy {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		bound := policy[name]
+		accessor, ok := paramAccessors[name]
+		if !ok {
+			continue
+		}
+		if _, defaulted := defaults[name]; !keys[name] && !defaulted {
+			continue
+		}
+		value := accessor.get(request)
+		target := value
+		if bound.Min != nil && target < *bound.Min {
+			target = *bound.Min
+		}
+		if bound.Max != nil && target > *bound.Max {
+			target = *bound.Max
+		}
+		if target == value {
+			continue
+		}
+		if bound.Action == operation_setting.ParamPolicyActionReject {
+			return fmt.Errorf("parameter %s=%v is out of the allowed range", name, value)
+		}
+		accessor.set(request, target)
+		adjustment := fmt.Sprintf("%s: %v -> %v", name, value, target)
+		info.ParamAdjustments = append(info.ParamAdjustments, adjustment)
+		common.LogInfo(c, "clamped request parameter "+adjustment)
+	}
+	return nil
+}
+
+// applyParamDefaults fills the group's default values into parameters the
+// client did not set. Explicit values, including zeros, are left alone;
+// applied defaults are logged and recorded on the relay info.
+func applyParamDefaults(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) {
+	defaults := operation_setting.GetGroupParamDefaults(info.UsingGroup)
+	if len(defaults) == 0 {
+		return
+	}
+	keys := requestParamKeys(c, info)
+	names := make([]string, 0, len(defaults))
+	for name := range defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		accessor, ok := paramAccessors[name]
+		if !ok || keys[name] {
+			continue
+		}
+		accessor.set(request, defaults[name])
+		applied := fmt.Sprintf("%s=%v", name, defaults[name])
+		info.ParamDefaults = append(info.ParamDefaults, applied)
+		common.LogInfo(c, "applied default request parameter "+applied)
+	}
+}
+
+// requestParamKeys returns the top-level fields the client set in the
+// request body. The decoded request cannot tell an explicit zero from an
+// omitted field, so presence is read from the raw JSON. A responses request
+// converted to chat completions reports max_output_tokens under its chat
+// name.
+func requestParamKeys(c *gin.Context, info *relaycommon.RelayInfo) map[string]bool {
+	keys := make(map[string]bool)
+	body, err := common.GetRequestBody(c)
+	if err != nil {
+		return keys
+	}
+	var fields map[string]json.RawMessage
+	if err := common.UnmarshalJson(body, &fields); err != nil {
+		return keys
+	}
+	for key, value := range fields {
+		if string(value) != "null" {
+			keys[key] = true
+		}
+	}
+	if info.ApiConversion == relaycommon.ApiConversionResponsesToChat && keys["max_output_tokens"] {
+		keys["max_completion_tokens"] = true
+	}
+	return keys
+}
@@ -0,0 +1,138 @@
+package relay
+
+import (
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newParamPolicyTestRequest(t *testing.T, body string) (*gin.Context, *relaycommon.RelayInfo, *dto.GeneralOpenAIRequest) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	var request dto.GeneralOpenAIRequest
+	if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+		t.Fatalf("decode request: %v", err)
+	}
+	return c, &relaycommon.RelayInfo{UsingGroup: "default"}, &request
+}
+
+func setGroupParamDefaults(t *testing.T, defaults map[string]float64) {
+	t.Helper()
+	setting := operation_setting.GetParamPolicySetting()
+	saved := setting.GroupDefaults
+	setting.GroupDefaults = map[string]map[string]float64{"default": defaults}
+	t.Cleanup(func() { setting.GroupDefaults = saved })
+}
+
+func TestApplyParamDefaults(t *testing.T) {
+	setGroupParamDefaults(t, map[string]float64{"temperature": 0.7, "top_p": 0.9, "frequency_penalty": 0.5, "max_tokens": 512})
+
+	tests := []struct {
+		name                                string
+		body                                string
+		temperature, topP, frequencyPenalty float64
+		maxTokens                           uint
+		applied                             int
+	}{
+		{name: "absent fields get defaults", body: `{"model":"gpt-4o","messages":[]}`,
+			temperature: 0.7, topP: 0.9, frequencyPenalty: 0.5, maxTokens: 512, applied: 4},
+		{name: "explicit zeros are kept", body: `{"model":"gpt-4o","messages":[],"temperature":0,"top_p":0,"frequency_penalty":0}`,
+			temperature: 0, topP: 0, frequencyPenalty: 0, maxTokens: 512, applied: 1},
+		{name: "explicit values are kept", body: `{"model":"gpt-4o","messages":[],"temperature":1.2,"top_p":0.5,"max_tokens":64}`,
+			temperature: 1.2, topP: 0.5, frequencyPenalty: 0.5, maxTokens: 64, applied: 1},
+		{name: "null counts as absent", body: `{"model":"gpt-4o","messages":[],"top_p":null}`,
+			temperature: 0.7, topP: 0.9, frequencyPenalty: 0.5, maxTokens: 512, applied: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, info, request := newParamPolicyTestRequest(t, tt.body)
+			applyParamDefaults(c, info, request)
+
+			if request.Temperature == nil || *request.Temperature != tt.temperature {
+				t.Errorf("temperature = %v, want %v", request.Temperature, tt.temperature)
+			}
+			if request.TopP != tt.topP {
+				t.Errorf("top_p = %v, want %v", request.TopP, tt.topP)
+			}
+			if request.FrequencyPenalty != tt.frequencyPenalty {
+				t.Errorf("frequency_penalty = %v, want %v", request.FrequencyPenalty, tt.frequencyPenalty)
+			}
+			if request.MaxTokens != tt.maxTokens {
+				t.Errorf("max_tokens = %v, want %v", request.MaxTokens, tt.maxTokens)
+			}
+			if len(info.ParamDefaults) != tt.applied {
+				t.Errorf("applied defaults = %v, want %d", info.ParamDefaults, tt.applied)
+			}
+		})
+	}
+}
+
+func setGroupParamPolicy(t *testing.T, policy map[string]operation_setting.ParamBound) {
+	t.Helper()
+	setting := operation_setting.GetParamPolicySetting()
+	saved := setting.GroupPolicies
+	setting.GroupPolicies = map[string]map[string]operation_setting.ParamBound{"default": policy}
+	t.Cleanup(func() { setting.GroupPolicies = saved })
+}
+
+func TestApplyParamPolicy(t *testing.T) {
+	minTopP, minPenalty, maxTopP := 0.1, 0.2, 0.9
+	setGroupParamPolicy(t, map[string]operation_setting.ParamBound{
+		"top_p":             {Min: &minTopP, Max: &maxTopP, Action: operation_setting.ParamPolicyActionClamp},
+		"frequency_penalty": {Min: &minPenalty, Action: operation_setting.ParamPolicyActionClamp},
+		"presence_penalty":  {Min: &minPenalty, Action: operation_setting.ParamPolicyActionReject},
+	})
+
+	tests := []struct {
+		name                   string
+		body                   string
+		defaults               map[string]float64
+		topP, frequencyPenalty float64
+		adjustments            int
+		rejected               bool
+	}{
+		{name: "explicit zeros are raised to the minimum", body: `{"model":"gpt-4o","messages":[],"top_p":0,"frequency_penalty":0}`,
+			topP: 0.1, frequencyPenalty: 0.2, adjustments: 2},
+		{name: "values above the maximum are lowered", body: `{"model":"gpt-4o","messages":[],"top_p":1}`,
+			topP: 0.9, adjustments: 1},
+		{name: "absent fields are not clamped", body: `{"model":"gpt-4o","messages":[]}`},
+		{name: "values in range are kept", body: `{"model":"gpt-4o","messages":[],"top_p":0.5,"frequency_penalty":0.3}`,
+			topP: 0.5, frequencyPenalty: 0.3},
+		{name: "explicit zero is rejected", body: `{"model":"gpt-4o","messages":[],"presence_penalty":0}`, rejected: true},
+		{name: "zero default is clamped", body: `{"model":"gpt-4o","messages":[]}`, defaults: map[string]float64{"frequency_penalty": 0},
+			frequencyPenalty: 0.2, adjustments: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setGroupParamDefaults(t, tt.defaults)
+			c, info, request := newParamPolicyTestRequest(t, tt.body)
+			applyParamDefaults(c, info, request)
+			err := applyParamPolicy(c, info, request)
+
+			if (err != nil) != tt.rejected {
+				t.Fatalf("applyParamPolicy() error = %v, rejected want %v", err, tt.rejected)
+			}
+			if tt.rejected {
+				return
+			}
+			if request.TopP != tt.topP {
+				t.Errorf("top_p = %v, want %v", request.TopP, tt.topP)
+			}
+			if request.FrequencyPenalty != tt.frequencyPenalty {
+				t.Errorf("frequency_penalty = %v, want %v", request.FrequencyPenalty, tt.frequencyPenalty)
+			}
+			if len(info.ParamAdjustments) != tt.adjustments {
+				t.Errorf("adjustments = %v, want %d", info.ParamAdjustments, tt.adjustments)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/relay/channel/gemini"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+	"one-api/setting"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CheckPromptPolicy runs the sensitive word check and the prompt classifier
+// of the group on the prompt sent by the client. It is called once per
+// request before any channel is tried, so channel retries and API
+// conversions do not run the checks again. Bodies that fail to parse are
+// left to the relay helpers to reject.
+func CheckPromptPolicy(c *gin.Context, relayMode int, group string) *dto.OpenAIErrorWithStatusCode {
+	var words []string
+	var err error
+	switch relayMode {
+	case relayconstant.RelayModeChatCompletions, relayconstant.RelayModeCompletions,
+		relayconstant.RelayModeEmbeddings, relayconstant.RelayModeModerations:
+		textRequest := &dto.GeneralOpenAIRequest{}
+		if common.UnmarshalBodyReusable(c, textRequest) != nil {
+			return nil
+		}
+		words, err = checkPrompt(c, group, func() ([]string, error) {
+			return checkRequestSensitiveWords(textRequest, relayMode)
+		}, textRequestPromptText(textRequest, relayMode))
+	case relayconstant.RelayModeResponses:
+		request := &dto.OpenAIResponsesRequest{}
+		if common.UnmarshalBodyReusable(c, request) != nil {
+			return nil
+		}
+		words, err = checkPrompt(c, group, func() ([]string, error) {
+			return service.CheckSensitiveText(responsesInputText(request.Input, false))
+		}, responsesInputText(request.Input, true))
+	case relayconstant.RelayModeGemini:
+		request := &gemini.GeminiChatRequest{}
+		if common.UnmarshalBodyReusable(c, request) != nil {
+			return nil
+		}
+		words, err = checkPrompt(c, group, func() ([]string, error) {
+			return checkGeminiInputSensitive(request)
+		}, geminiUserText(request))
+	default:
+		return nil
+	}
+	return promptPolicyError(c, words, err)
+}
+
+// CheckClaudePromptPolicy is the Claude messages counterpart of
+// CheckPromptPolicy.
+func CheckClaudePromptPolicy(c *gin.Context, group string) *dto.ClaudeErrorWithStatusCode {
+	request := &dto.ClaudeRequest{}
+	if common.UnmarshalBodyReusable(c, request) != nil {
+		return nil
+	}
+	var allText, userText strings.Builder
+	for _, message := range request.Messages {
+		text := message.GetStringContent()
+		allText.WriteString(text)
+		allText.WriteString("\n")
+		if message.Role == "user" {
+			userText.WriteString(text)
+			userText.WriteString("\n")
+		}
+	}
+	words, err := checkPrompt(c, group, func() ([]string, error) {
+		return service.CheckSensitiveText(allText.String())
+	}, userText.String())
+	if openaiErr := promptPolicyError(c, words, err); openaiErr != nil {
+		return service.OpenAIErrorToClaudeError(openaiErr)
+	}
+	return nil
+}
+
+// checkPrompt checks the sensitive words when enabled, then runs the prompt
+// classifier of the group on the user text.
+func checkPrompt(c *gin.Context, group string, checkSensitive func() ([]string, error), userText string) ([]string, error) {
+	if setting.ShouldCheckPromptSensitive() {
+		if words, err := checkSensitive(); err != nil {
+			return words, err
+		}
+	}
+	return nil, service.ClassifyPrompt(c, group, userText)
+}
+
+func promptPolicyError(c *gin.Context, words []string, err error) *dto.OpenAIErrorWithStatusCode {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, service.ErrPromptInjectionDetected) {
+		return service.OpenAIErrorWrapperLocal(err, "prompt_injection_detected", http.StatusBadRequest)
+	}
+	common.LogWarn(c, fmt.Sprintf("user sensitive words detected: %s", strings.Join(words, ", ")))
+	return service.OpenAIErrorWrapperLocal(err, "sensitive_words_detected", http.StatusBadRequest)
+}
+
+func textRequestPromptText(textRequest *dto.GeneralOpenAIRequest, relayMode int) string {
+	switch relayMode {
+	case relayconstant.RelayModeChatCompletions:
+		var userText strings.Builder
+		for _, message := range textRequest.Messages {
+			if message.Role == "user" {
+				userText.WriteString(message.StringContent())
+				userText.WriteString("\n")
+			}
+		}
+		return userText.String()
+	case relayconstant.RelayModeCompletions:
+		if prompt, ok := textRequest.Prompt.(string); ok {
+			return prompt
+		}
+	}
+	return ""
+}
+
+// responsesInputText collects the text of a responses input, which is either
+// a string or a list of items whose content is a string or a list of parts.
+// With userOnly, items of other roles are skipped.
+func responsesInputText(input []byte, userOnly bool) string {
+	if len(input) == 0 {
+		return ""
+	}
+	var text string
+	if common.UnmarshalJson(input, &text) == nil {
+		return text
+	}
+	var items []struct {
+		Role    string `json:"role"`
+		Content any    `json:"content"`
+	}
+	if common.UnmarshalJson(input, &items) != nil {
+		return ""
+	}
+	var builder strings.Builder
+	for _, item := range items {
+		if userOnly && item.Role != "" && item.Role != "user" {
+			continue
+		}
+		switch content := item.Content.(type) {
+		case string:
+			builder.WriteString(content)
+			builder.WriteString("\n")
+		case []any:
+			for _, part := range content {
+				if partMap, ok := part.(map[string]any); ok {
+					if partText, ok := partMap["text"].(string); ok {
+						builder.WriteString(partText)
+						builder.WriteString("\n")
+					}
+				}
+			}
+		}
+	}
+	return builder.String()
+}
+
+func geminiUserText(request *gemini.GeminiChatRequest) string {
+	var userText strings.Builder
+	for _, content := range request.Contents {
+		if content.Role != "" && content.Role != "user" {
+			continue
+		}
+		for _, part := range content.Parts {
+			if part.Text != "" {
+				userText.WriteString(part.Text)
+				userText.WriteString("\n")
+			}
+		}
+	}
+	return userText.String()
+}
@@ -0,0 +1,73 @@
+package relay
+
+import (
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"testing"
+)
+
+func setRefusalBilling(t *testing.T, scope string, ratios map[string]float64) {
+	t.Helper()
+	setting := operation_setting.GetRefusalSetting()
+	savedScope, savedRatios := setting.BillingScope, setting.GroupBillingRatio
+	setting.BillingScope, setting.GroupBillingRatio = scope, ratios
+	t.Cleanup(func() {
+		setting.BillingScope, setting.GroupBillingRatio = savedScope, savedRatios
+	})
+}
+
+func TestApplyRefusalBilling(t *testing.T) {
+	tests := []struct {
+		name     string
+		scope    string
+		ratios   map[string]float64
+		refusal  bool
+		quota    int
+		want     int
+		adjusted bool
+	}{
+		{name: "refusal without adjustment configured", scope: operation_setting.RefusalBillingScopeTotal,
+			ratios: map[string]float64{"vip": 0}, refusal: true, quota: 1500, want: 1500},
+		{name: "answered request is billed as usual", scope: operation_setting.RefusalBillingScopeTotal,
+			ratios: map[string]float64{"default": 0}, quota: 1500, want: 1500},
+		{name: "ratio scales the whole quota", scope: operation_setting.RefusalBillingScopeTotal,
+			ratios: map[string]float64{"default": 0.5}, refusal: true, quota: 1501, want: 751, adjusted: true},
+		{name: "ratio 0 makes the refusal free", scope: operation_setting.RefusalBillingScopeTotal,
+			ratios: map[string]float64{"default": 0}, refusal: true, quota: 1500, want: 0, adjusted: true},
+		// 按次计费的额度为 模型价格 × QuotaPerUnit × 分组倍率
+		{name: "per-call price is scaled too", scope: "",
+			ratios: map[string]float64{"default": 0.1}, refusal: true, quota: 10000, want: 1000, adjusted: true},
+		{name: "completion scope leaves the total alone", scope: operation_setting.RefusalBillingScopeCompletion,
+			ratios: map[string]float64{"default": 0}, refusal: true, quota: 1500, want: 1500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRefusalBilling(t, tt.scope, tt.ratios)
+			info := &relaycommon.RelayInfo{UsingGroup: "default", Refusal: tt.refusal}
+
+			got, content, adjusted := applyRefusalBilling(info, tt.quota)
+			if got != tt.want || adjusted != tt.adjusted {
+				t.Fatalf("applyRefusalBilling() = %d, %v, want %d, %v", got, adjusted, tt.want, tt.adjusted)
+			}
+			if adjusted && content == "" {
+				t.Error("adjusted refusal has no log content")
+			}
+		})
+	}
+}
+
+func TestRefusalBillingRatioCompletionScope(t *testing.T) {
+	setRefusalBilling(t, operation_setting.RefusalBillingScopeCompletion, map[string]float64{"default": 0.2})
+	info := &relaycommon.RelayInfo{UsingGroup: "default", Refusal: true}
+
+	if ratio, ok := refusalBillingRatio(info, operation_setting.RefusalBillingScopeCompletion); !ok || ratio != 0.2 {
+		t.Errorf("completion scope ratio = %v, %v, want 0.2, true", ratio, ok)
+	}
+	if _, ok := refusalBillingRatio(info, operation_setting.RefusalBillingScopeTotal); ok {
+		t.Error("total scope ratio applied while the completion scope is configured")
+	}
+	info.Refusal = false
+	if _, ok := refusalBillingRatio(info, operation_setting.RefusalBillingScopeCompletion); ok {
+		t.Error("ratio applied to an answered request")
+	}
+}
@@ -16,7 +16,6 @@ import (
 	relayconstant "one-api/relay/constant"
 	"one-api/relay/helper"
 	"one-api/service"
-	"one-api/setting"
 	"one-api/setting/model_setting"
 	"one-api/setting/operation_setting"
 	"strings"
@@ -28,11 +27,42 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// malformedRequestError marks a request body that could not be parsed, as
+// opposed to a well-formed body that failed validation.
+type malformedRequestError struct {
+	err error
+}
+
+func (e *malformedRequestError) Error() string {
+	return e.err.Error()
+}
+
+func (e *malformedRequestError) Unwrap() error {
+	return e.err
+}
+
+// validationErrorStatus returns 400 for malformed bodies and the configured
+// VALIDATION_ERROR_STATUS for semantic validation failures.
+func validationErrorStatus(err error) int {
+	var malformedErr *malformedRequestError
+	if errors.As(err, &malformedErr) || constant.ValidationErrorStatus == 0 {
+		return http.StatusBadRequest
+	}
+	return constant.ValidationErrorStatus
+}
+
 func getAndValidateTextRequest(c *gin.Context, relayInfo *relaycommon.RelayInfo) (*dto.GeneralOpenAIRequest, error) {
 	textRequest := &dto.GeneralOpenAIRequest{}
 	err := common.UnmarshalBodyReusable(c, textRequest)
+	if err == nil && constant.StrictUnknownFields && strings.HasPrefix(c.Request.Header.Get("Content-Type"), "application/json") {
+		// 严格模式下再次解码以拒绝未知字段
+		var body []byte
+		if body, err = common.GetRequestBody(c); err == nil {
+			err = common.UnmarshalJsonStrict(body, &dto.GeneralOpenAIRequest{})
+		}
+	}
 	if err != nil {
-		return nil, err
+		return nil, &malformedRequestError{err: err}
 	}
 	if relayInfo.RelayMode == relayconstant.RelayModeModerations && textRequest.Model == "" {
 		textRequest.Model = "text-moderation-latest"
@@ -40,12 +70,21 @@ func getAndValidateTextRequest(c *gin.Context, relayInfo *relaycommon.RelayInfo)
 	if relayInfo.RelayMode == relayconstant.RelayModeEmbeddings && textRequest.Model == "" {
 		textRequest.Model = c.Param("model")
 	}
+	if err := validateTextRequest(c, relayInfo, textRequest); err != nil {
+		return nil, err
+	}
+	return textRequest, nil
+}
 
-	if textRequest.MaxTokens > math.MaxInt32/2 {
-		return nil, errors.New("max_tokens is invalid")
+// validateTextRequest validates a decoded text request and applies the
+// parameter, JSON mode and stop sequence policies. It is also used for
+// requests converted from another API format.
+func validateTextRequest(c *gin.Context, relayInfo *relaycommon.RelayInfo, textRequest *dto.GeneralOpenAIRequest) error {
+	if textRequest.MaxTokens > maxRequestTokens {
+		return errors.New("max_tokens is invalid")
 	}
 	if textRequest.Model == "" {
-		return nil, errors.New("model is required")
+		return errors.New("model is required")
 	}
 	if textRequest.WebSearchOptions != nil {
 		if textRequest.WebSearchOptions.SearchContextSize != "" {
@@ -55,33 +94,61 @@ func getAndValidateTextRequest(c *gin.Context, relayInfo *relaycommon.RelayInfo)
 				"low":    true,
 			}
 			if !validSizes[textRequest.WebSearchOptions.SearchContextSize] {
-				return nil, errors.New("invalid search_context_size, must be one of: high, medium, low")
+				return errors.New("invalid search_context_size, must be one of: high, medium, low")
 			}
 		} else {
 			textRequest.WebSearchOptions.SearchContextSize = "medium"
 		}
 	}
+	if textRequest.Prediction != nil {
+		if textRequest.Prediction.Type != "content" {
+			return errors.New("invalid prediction type, must be content")
+		}
+		if len(textRequest.Prediction.Content) == 0 || string(textRequest.Prediction.Content) == "null" {
+			return errors.New("field prediction.content is required")
+		}
+	}
 	switch relayInfo.RelayMode {
 	case relayconstant.RelayModeCompletions:
 		if textRequest.Prompt == "" {
-			return nil, errors.New("field prompt is required")
+			return errors.New("field prompt is required")
 		}
 	case relayconstant.RelayModeChatCompletions:
 		if len(textRequest.Messages) == 0 {
-			return nil, errors.New("field messages is required")
+			return errors.New("field messages is required")
+		}
+		if constant.MaxMessagesPerRequest > 0 && len(textRequest.Messages) > constant.MaxMessagesPerRequest {
+			return fmt.Errorf("too many messages: %d, the maximum is %d", len(textRequest.Messages), constant.MaxMessagesPerRequest)
+		}
+		if constant.MaxMessagesContentLength > 0 {
+			contentLength := 0
+			for i := range textRequest.Messages {
+				contentLength += len(textRequest.Messages[i].StringContent())
+			}
+			if contentLength > constant.MaxMessagesContentLength {
+				return fmt.Errorf("messages content is too long: %d, the maximum is %d", contentLength, constant.MaxMessagesContentLength)
+			}
 		}
 	case relayconstant.RelayModeEmbeddings:
 	case relayconstant.RelayModeModerations:
 		if textRequest.Input == nil || textRequest.Input == "" {
-			return nil, errors.New("field input is required")
+			return errors.New("field input is required")
 		}
 	case relayconstant.RelayModeEdits:
 		if textRequest.Instruction == "" {
-			return nil, errors.New("field instruction is required")
+			return errors.New("field instruction is required")
 		}
 	}
+	applyParamDefaults(c, relayInfo, textRequest)
+	if err := applyParamPolicy(c, relayInfo, textRequest); err != nil {
+		return err
+	}
+	if err := applyJsonModePolicy(c, relayInfo, textRequest); err != nil {
+		return err
+	}
+	applyStopSequencePolicy(c, relayInfo, textRequest)
 	relayInfo.IsStream = textRequest.Stream
-	return textRequest, nil
+	return nil
 }
 
 func TextHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
@@ -93,45 +160,77 @@ func TextHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 
 	if err != nil {
 		common.LogError(c, fmt.Sprintf("getAndValidateTextRequest failed: %s", err.Error()))
-		return service.OpenAIErrorWrapperLocal(err, "invalid_text_request", http.StatusBadRequest)
+		return service.OpenAIErrorWrapperLocal(err, "invalid_text_request", validationErrorStatus(err))
+	}
+
+	if shouldRelayChatViaResponses(relayInfo) {
+		return relayChatViaResponses(c, relayInfo, textRequest)
+	}
+	return relayTextRequest(c, relayInfo, textRequest)
+}
+
+// maxRequestTokens bounds the max tokens a request may ask for, so that
+// adding it to the prompt tokens cannot overflow an int32.
+const maxRequestTokens = math.MaxInt32 / 2
+
+// checkTextRequestPolicy runs the stream policy on a validated text request.
+// The sensitive word and prompt injection checks run once per request in
+// CheckPromptPolicy.
+func checkTextRequestPolicy(c *gin.Context, relayInfo *relaycommon.RelayInfo, textRequest *dto.GeneralOpenAIRequest) *dto.OpenAIErrorWithStatusCode {
+	if err := checkStreamPolicy(relayInfo, textRequest); err != nil {
+		return service.OpenAIErrorWrapperLocal(err, "stream_required", http.StatusBadRequest)
 	}
+	return nil
+}
+
+// relayTextRequest relays a validated text request to the selected channel
+// and bills the usage.
+func relayTextRequest(c *gin.Context, relayInfo *relaycommon.RelayInfo, textRequest *dto.GeneralOpenAIRequest) (openaiErr *dto.OpenAIErrorWithStatusCode) {
+	var err error
+	helper.SetupTruncationRetry(c, relayInfo, textRequest)
 
 	if textRequest.WebSearchOptions != nil {
 		c.Set("chat_completion_web_search_context_size", textRequest.WebSearchOptions.SearchContextSize)
 	}
 
-	if setting.ShouldCheckPromptSensitive() {
-		words, err := checkRequestSensitive(textRequest, relayInfo)
-		if err != nil {
-			common.LogWarn(c, fmt.Sprintf("user sensitive words detected: %s", strings.Join(words, ", ")))
-			return service.OpenAIErrorWrapperLocal(err, "sensitive_words_detected", http.StatusBadRequest)
-		}
+	if openaiErr = checkTextRequestPolicy(c, relayInfo, textRequest); openaiErr != nil {
+		return openaiErr
 	}
 
 	err = helper.ModelMappedHelper(c, relayInfo, textRequest)
 	if err != nil {
 		return service.OpenAIErrorWrapperLocal(err, "model_mapped_error", http.StatusInternalServerError)
 	}
+	helper.SetupSSETransformer(c, relayInfo)
+	helper.SetupOutputRedaction(c, relayInfo)
 
 	// 获取 promptTokens，如果上下文中已经存在，则直接使用
 	var promptTokens int
-	if value, exists := c.Get("prompt_tokens"); exists {
+	if value, exists := c.Get("prompt_tokens"); exists && value != nil {
 		promptTokens = value.(int)
 		relayInfo.PromptTokens = promptTokens
 	} else {
 		promptTokens, err = getPromptTokens(textRequest, relayInfo)
 		// count messages token error 计算promptTokens错误
+		if err != nil && constant.TokenCountFailMode == constant.TokenCountFailModeEstimate {
+			promptTokens, err = estimatePromptTokens(c, relayInfo, err)
+		}
 		if err != nil {
 			return service.OpenAIErrorWrapper(err, "count_token_messages_failed", http.StatusInternalServerError)
 		}
 		c.Set("prompt_tokens", promptTokens)
 	}
 
-	priceData, err := helper.ModelPriceHelper(c, relayInfo, promptTokens, int(math.Max(float64(textRequest.MaxTokens), float64(textRequest.MaxCompletionTokens))))
+	maxTokens := int(math.Max(float64(textRequest.MaxTokens), float64(textRequest.MaxCompletionTokens)))
+	priceData, err := helper.ModelPriceHelper(c, relayInfo, promptTokens, maxTokens)
 	if err != nil {
 		return service.OpenAIErrorWrapperLocal(err, "model_price_error", http.StatusInternalServerError)
 	}
 
+	if isDryRunRequest(c) {
+		return respondDryRun(c, relayInfo, priceData)
+	}
+
 	// pre-consume quota 预消耗配额
 	preConsumedQuota, userQuota, openaiErr := preConsumeQuota(c, priceData.ShouldPreConsumedQuota, relayInfo)
 	if openaiErr != nil {
@@ -163,6 +262,16 @@ func TextHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 	if includeUsage {
 		relayInfo.ShouldIncludeUsage = true
 	}
+	applyStreamModePolicy(c, relayInfo, textRequest)
+
+	if relayInfo.ClientMetadata == nil {
+		var metadataRequest struct {
+			Metadata json.RawMessage `json:"metadata"`
+		}
+		if err := common.UnmarshalBodyReusable(c, &metadataRequest); err == nil {
+			relayInfo.ClientMetadata = relaycommon.ParseClientMetadata(metadataRequest.Metadata)
+		}
+	}
 
 	adaptor := GetAdaptor(relayInfo.ApiType)
 	if adaptor == nil {
@@ -171,12 +280,12 @@ func TextHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 	adaptor.Init(relayInfo)
 	var requestBody io.Reader
 
-	if model_setting.GetGlobalSettings().PassThroughRequestEnabled {
-		body, err := common.GetRequestBody(c)
+	if model_setting.GetGlobalSettings().PassThroughRequestEnabled && relayInfo.ApiConversion == "" {
+		body, err := common.GetRequestBodyReader(c)
 		if err != nil {
 			return service.OpenAIErrorWrapperLocal(err, "get_request_body_failed", http.StatusInternalServerError)
 		}
-		requestBody = bytes.NewBuffer(body)
+		requestBody = body
 	} else {
 		convertedRequest, err := adaptor.ConvertOpenAIRequest(c, relayInfo, textRequest)
 		if err != nil {
@@ -187,6 +296,17 @@ func TextHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 			return service.OpenAIErrorWrapperLocal(err, "json_marshal_failed", http.StatusInternalServerError)
 		}
 
+		if model_setting.GetGlobalSettings().PreserveUnknownFields && relayInfo.ApiType == constant.APITypeOpenAI && relayInfo.ApiConversion == "" {
+			body, err := common.GetRequestBody(c)
+			if err != nil {
+				return service.OpenAIErrorWrapperLocal(err, "get_request_body_failed", http.StatusInternalServerError)
+			}
+			jsonData, err = helper.PreserveUnknownFields(body, jsonData, textRequest)
+			if err != nil {
+				return service.OpenAIErrorWrapperLocal(err, "preserve_unknown_fields_failed", http.StatusInternalServerError)
+			}
+		}
+
 		// apply param override
 		if len(relayInfo.ParamOverride) > 0 {
 			reqMap := make(map[string]interface{})
@@ -231,11 +351,21 @@ func TextHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 
 	usage, openaiErr := adaptor.DoResponse(c, httpResp, relayInfo)
 	if openaiErr != nil {
+		if truncatedUsage := helper.TruncatedUsage(openaiErr, usage); truncatedUsage != nil {
+			// 截断的响应已丢弃，上游用量仍按实际计费，不再退还预扣费
+			postConsumeQuota(c, relayInfo, truncatedUsage, preConsumedQuota, userQuota, priceData, "响应被截断，已丢弃并重试")
+			preConsumedQuota = 0
+		}
 		// reset status code 重置状态码
 		service.ResetStatusCode(openaiErr, statusCodeMappingStr)
 		return openaiErr
 	}
 
+	if !helper.ClaimHedgeWin(c) {
+		// 对冲请求中另一个渠道已先完成，本次不计费
+		return service.OpenAIErrorWrapperLocal(errors.New("hedged request lost the race"), "hedge_lost", http.StatusInternalServerError)
+	}
+
 	if strings.HasPrefix(relayInfo.OriginModelName, "gpt-4o-audio") {
 		service.PostAudioConsumeQuota(c, relayInfo, usage.(*dto.Usage), preConsumedQuota, userQuota, priceData, "")
 	} else {
@@ -264,10 +394,24 @@ func getPromptTokens(textRequest *dto.GeneralOpenAIRequest, info *relaycommon.Re
 	return promptTokens, err
 }
 
-func checkRequestSensitive(textRequest *dto.GeneralOpenAIRequest, info *relaycommon.RelayInfo) ([]string, error) {
+// estimatePromptTokens falls back to a character-based estimate of the raw
+// request body when token counting failed.
+func estimatePromptTokens(c *gin.Context, info *relaycommon.RelayInfo, countErr error) (int, error) {
+	body, err := common.GetRequestBody(c)
+	if err != nil {
+		return 0, err
+	}
+	promptTokens := service.EstimateTokenByChars(string(body))
+	common.LogWarn(c, fmt.Sprintf("count prompt tokens failed, using estimate %d: %s", promptTokens, countErr.Error()))
+	info.PromptTokens = promptTokens
+	info.PromptTokensEstimated = true
+	return promptTokens, nil
+}
+
+func checkRequestSensitiveWords(textRequest *dto.GeneralOpenAIRequest, relayMode int) ([]string, error) {
 	var err error
 	var words []string
-	switch info.RelayMode {
+	switch relayMode {
 	case relayconstant.RelayModeChatCompletions:
 		words, err = service.CheckSensitiveMessages(textRequest.Messages)
 	case relayconstant.RelayModeCompletions:
@@ -293,6 +437,8 @@ func preConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommo
 		return 0, 0, service.OpenAIErrorWrapperLocal(fmt.Errorf("chat pre-consumed quota failed, user quota: %s, need quota: %s", common.FormatQuota(userQuota), common.FormatQuota(preConsumedQuota)), "insufficient_user_quota", http.StatusForbidden)
 	}
 	relayInfo.UserQuota = userQuota
+	service.SetInFlightPromptTokens(c, relayInfo.PromptTokens)
+	service.HoldResponseForBilledHeaders(c, relayInfo)
 	if userQuota > 100*preConsumedQuota {
 		// 用户额度充足，判断令牌额度是否充足
 		if !relayInfo.TokenUnlimited {
@@ -311,7 +457,29 @@ func preConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommo
 		}
 	}
 
-	if preConsumedQuota > 0 {
+	if preConsumedQuota > 0 && c.GetBool("token_skip_pre_consume") {
+		// 受信任的内部令牌，跳过预扣费，仍在请求结束后按实际用量结算
+		preConsumedQuota = 0
+		common.LogInfo(c, fmt.Sprintf("token %d is trusted to skip pre-consume", relayInfo.TokenId))
+	}
+
+	if preConsumedQuota > 0 && constant.AtomicPreConsume {
+		// 先原子扣减用户额度，令牌额度扣减失败时退回
+		err := model.DecreaseUserQuotaIfEnough(relayInfo.UserId, preConsumedQuota)
+		if errors.Is(err, model.ErrInsufficientUserQuota) {
+			return 0, 0, service.OpenAIErrorWrapperLocal(fmt.Errorf("chat pre-consumed quota failed, need quota: %s", common.FormatQuota(preConsumedQuota)), "insufficient_user_quota", http.StatusForbidden)
+		}
+		if err != nil {
+			return 0, 0, service.OpenAIErrorWrapperLocal(err, "decrease_user_quota_failed", http.StatusInternalServerError)
+		}
+		err = service.PreConsumeTokenQuota(relayInfo, preConsumedQuota)
+		if err != nil {
+			if refundErr := model.IncreaseUserQuota(relayInfo.UserId, preConsumedQuota, false); refundErr != nil {
+				common.LogError(c, "failed to refund user quota: "+refundErr.Error())
+			}
+			return 0, 0, service.OpenAIErrorWrapperLocal(err, "pre_consume_token_quota_failed", http.StatusForbidden)
+		}
+	} else if preConsumedQuota > 0 {
 		err := service.PreConsumeTokenQuota(relayInfo, preConsumedQuota)
 		if err != nil {
 			return 0, 0, service.OpenAIErrorWrapperLocal(err, "pre_consume_token_quota_failed", http.StatusForbidden)
@@ -337,6 +505,25 @@ func returnPreConsumedQuota(c *gin.Context, relayInfo *relaycommon.RelayInfo, us
 	}
 }
 
+// refusalBillingRatio 返回拒绝回答在给定作用范围下的计费倍率，未判定为拒绝或范围不符时返回 false
+func refusalBillingRatio(relayInfo *relaycommon.RelayInfo, scope string) (float64, bool) {
+	if !relayInfo.Refusal || operation_setting.GetRefusalBillingScope() != scope {
+		return 0, false
+	}
+	return operation_setting.GetRefusalBillingRatio(relayInfo.UsingGroup)
+}
+
+// applyRefusalBilling scales the whole quota of a refused response, per-call
+// prices included, so a ratio of 0 makes the refusal free.
+func applyRefusalBilling(relayInfo *relaycommon.RelayInfo, quota int) (int, string, bool) {
+	refusalRatio, ok := refusalBillingRatio(relayInfo, operation_setting.RefusalBillingScopeTotal)
+	if !ok {
+		return quota, "", false
+	}
+	quota = int(decimal.NewFromInt(int64(quota)).Mul(decimal.NewFromFloat(refusalRatio)).Round(0).IntPart())
+	return quota, fmt.Sprintf("模型拒绝回答，按 %.2f 倍计费", refusalRatio), true
+}
+
 func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	usage *dto.Usage, preConsumedQuota int, userQuota int, priceData helper.PriceData, extraContent string) {
 	if usage == nil {
@@ -354,6 +541,25 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	audioTokens := usage.PromptTokensDetails.AudioTokens
 	completionTokens := usage.CompletionTokens
 	modelName := relayInfo.OriginModelName
+	thinkingTokens := usage.CompletionTokenDetails.ReasoningTokens
+	thinkingRatio := service.GetClaudeThinkingRatio(modelName, thinkingTokens)
+	// 非 Claude 模型的推理 token 按推理倍率计费
+	reasoningTokens, reasoningRatio := 0, 0.0
+	if thinkingTokens > 0 && !strings.HasPrefix(strings.ToLower(modelName), "claude") {
+		reasoningTokens, reasoningRatio = thinkingTokens, model_setting.GetReasoningTokenRatio()
+	}
+	acceptedPredictionTokens := usage.CompletionTokenDetails.AcceptedPredictionTokens
+	rejectedPredictionTokens := usage.CompletionTokenDetails.RejectedPredictionTokens
+	acceptedPredictionRatio, rejectedPredictionRatio := model_setting.GetPredictionTokenRatios()
+	// 生成的音频/图片 token 已包含在 completion tokens 中，配置了倍率的模型单独计费
+	audioOutputTokens, imageOutputTokens := 0, 0
+	audioOutputRatio, imageOutputRatio := model_setting.GetOutputModalityRatios(modelName)
+	if audioOutputRatio > 0 {
+		audioOutputTokens = usage.CompletionTokenDetails.AudioTokens
+	}
+	if imageOutputRatio > 0 {
+		imageOutputTokens = usage.CompletionTokenDetails.ImageTokens
+	}
 
 	tokenName := ctx.GetString("token_name")
 	completionRatio := priceData.CompletionRatio
@@ -450,7 +656,46 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		}
 		promptQuota := baseTokens.Add(cachedTokensWithRatio).Add(imageTokensWithRatio)
 
-		completionQuota := dCompletionTokens.Mul(dCompletionRatio)
+		// thinking、预测输出与多模态输出 token 已包含在 completion tokens 中，按各自倍率单独计费
+		baseCompletionTokens := dCompletionTokens
+		var specialCompletionQuota decimal.Decimal
+		if thinkingRatio > 0 {
+			dThinkingTokens := decimal.NewFromInt(int64(thinkingTokens))
+			baseCompletionTokens = baseCompletionTokens.Sub(dThinkingTokens)
+			specialCompletionQuota = specialCompletionQuota.Add(dThinkingTokens.Mul(dCompletionRatio).Mul(decimal.NewFromFloat(thinkingRatio)))
+		}
+		if reasoningTokens > 0 {
+			dReasoningTokens := decimal.NewFromInt(int64(reasoningTokens))
+			baseCompletionTokens = baseCompletionTokens.Sub(dReasoningTokens)
+			specialCompletionQuota = specialCompletionQuota.Add(dReasoningTokens.Mul(dCompletionRatio).Mul(decimal.NewFromFloat(reasoningRatio)))
+		}
+		if acceptedPredictionTokens > 0 || rejectedPredictionTokens > 0 {
+			dAcceptedTokens := decimal.NewFromInt(int64(acceptedPredictionTokens))
+			dRejectedTokens := decimal.NewFromInt(int64(rejectedPredictionTokens))
+			baseCompletionTokens = baseCompletionTokens.Sub(dAcceptedTokens).Sub(dRejectedTokens)
+			specialCompletionQuota = specialCompletionQuota.
+				Add(dAcceptedTokens.Mul(dCompletionRatio).Mul(decimal.NewFromFloat(acceptedPredictionRatio))).
+				Add(dRejectedTokens.Mul(dCompletionRatio).Mul(decimal.NewFromFloat(rejectedPredictionRatio)))
+		}
+		if audioOutputTokens > 0 {
+			dAudioOutputTokens := decimal.NewFromInt(int64(audioOutputTokens))
+			baseCompletionTokens = baseCompletionTokens.Sub(dAudioOutputTokens)
+			specialCompletionQuota = specialCompletionQuota.Add(dAudioOutputTokens.Mul(dCompletionRatio).Mul(decimal.NewFromFloat(audioOutputRatio)))
+		}
+		if imageOutputTokens > 0 {
+			dImageOutputTokens := decimal.NewFromInt(int64(imageOutputTokens))
+			baseCompletionTokens = baseCompletionTokens.Sub(dImageOutputTokens)
+			specialCompletionQuota = specialCompletionQuota.Add(dImageOutputTokens.Mul(dCompletionRatio).Mul(decimal.NewFromFloat(imageOutputRatio)))
+		}
+		if baseCompletionTokens.IsNegative() {
+			baseCompletionTokens = decimal.Zero
+		}
+		completionQuota := baseCompletionTokens.Mul(dCompletionRatio).Add(specialCompletionQuota)
+		// 按补全范围调整时，拒绝回答只调整补全部分，提示词照常计费
+		if refusalRatio, ok := refusalBillingRatio(relayInfo, operation_setting.RefusalBillingScopeCompletion); ok {
+			completionQuota = completionQuota.Mul(decimal.NewFromFloat(refusalRatio))
+			extraContent += fmt.Sprintf("模型拒绝回答，补全按 %.2f 倍计费", refusalRatio)
+		}
 
 		quotaCalculateDecimal = promptQuota.Add(completionQuota).Mul(ratio)
 
@@ -467,7 +712,15 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	quotaCalculateDecimal = quotaCalculateDecimal.Add(audioInputQuota)
 
 	quota := int(quotaCalculateDecimal.Round(0).IntPart())
+	if refusedQuota, content, ok := applyRefusalBilling(relayInfo, quota); ok {
+		quota = refusedQuota
+		extraContent += content
+	}
 	totalTokens := promptTokens + completionTokens
+	if relayInfo.DedupShared && !operation_setting.GetRequestDedupSetting().BillSharedResponses {
+		quota = 0
+		extraContent += "复用并发相同请求的上游响应，不计费"
+	}
 
 	var logContent string
 	if !priceData.UsePrice {
@@ -510,6 +763,28 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		logContent += ", " + extraContent
 	}
 	other := service.GenerateTextOtherInfo(ctx, relayInfo, modelRatio, groupRatio, completionRatio, cacheTokens, cacheRatio, modelPrice, priceData.GroupRatioInfo.GroupSpecialRatio)
+	if thinkingRatio > 0 {
+		other["thinking_tokens"] = thinkingTokens
+		other["thinking_ratio"] = thinkingRatio
+	}
+	if reasoningTokens > 0 {
+		other["reasoning_tokens"] = reasoningTokens
+		other["reasoning_ratio"] = reasoningRatio
+	}
+	if acceptedPredictionTokens > 0 || rejectedPredictionTokens > 0 {
+		other["accepted_prediction_tokens"] = acceptedPredictionTokens
+		other["rejected_prediction_tokens"] = rejectedPredictionTokens
+		other["accepted_prediction_ratio"] = acceptedPredictionRatio
+		other["rejected_prediction_ratio"] = rejectedPredictionRatio
+	}
+	if audioOutputTokens > 0 {
+		other["audio_output_tokens"] = audioOutputTokens
+		other["audio_output_ratio"] = audioOutputRatio
+	}
+	if imageOutputTokens > 0 {
+		other["image_output_tokens"] = imageOutputTokens
+		other["image_output_ratio"] = imageOutputRatio
+	}
 	if imageTokens != 0 {
 		other["image"] = true
 		other["image_ratio"] = imageRatio
@@ -540,6 +815,7 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		other["audio_input_token_count"] = audioTokens
 		other["audio_input_price"] = audioInputPrice
 	}
+	service.SetBilledHeaders(ctx, relayInfo, userQuota, quota)
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     promptTokens,
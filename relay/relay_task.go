@@ -92,7 +92,12 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 			}
 			c.Set("base_url", channel.GetBaseURL())
 			c.Set("channel_id", originTask.ChannelId)
-			c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", channel.Key))
+			key, err := service.ResolveChannelKey(c.Request.Context(), channel.Key)
+			if err != nil {
+				taskErr = service.TaskErrorWrapperLocal(err, "get_channel_key_failed", http.StatusInternalServerError)
+				return
+			}
+			c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", key))
 
 			relayInfo.BaseUrl = channel.GetBaseURL()
 			relayInfo.ChannelId = originTask.ChannelId
@@ -0,0 +1,483 @@
+package relay
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting/model_setting"
+	"one-api/setting/operation_setting"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestGetAndValidateTextRequestStrictUnknownFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	saved := constant.StrictUnknownFields
+	t.Cleanup(func() { constant.StrictUnknownFields = saved })
+
+	tests := []struct {
+		name    string
+		strict  bool
+		body    string
+		wantErr string
+	}{
+		{name: "typo is rejected in strict mode", strict: true,
+			body:    `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}],"temprature":0.2}`,
+			wantErr: `unknown field "temprature" in request body`},
+		{name: "typo is ignored by default",
+			body: `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}],"temprature":0.2}`},
+		{name: "known fields pass in strict mode", strict: true,
+			body: `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}],"temperature":0.2}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			constant.StrictUnknownFields = tt.strict
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			info := &relaycommon.RelayInfo{UsingGroup: "default", RelayMode: relayconstant.RelayModeChatCompletions}
+
+			_, err := getAndValidateTextRequest(c, info)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("getAndValidateTextRequest() error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("getAndValidateTextRequest() error = %v, want %q", err, tt.wantErr)
+			}
+			if status := validationErrorStatus(err); status != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d for a malformed body", status, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestValidationErrorStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	saved := constant.ValidationErrorStatus
+	t.Cleanup(func() { constant.ValidationErrorStatus = saved })
+
+	tests := []struct {
+		name       string
+		configured int
+		body       string
+		want       int
+	}{
+		{name: "malformed body", configured: http.StatusUnprocessableEntity, body: `{"model":"gpt-4o","messages":`,
+			want: http.StatusBadRequest},
+		{name: "wrong field type", configured: http.StatusUnprocessableEntity, body: `{"model":"gpt-4o","messages":"hi"}`,
+			want: http.StatusBadRequest},
+		{name: "validation failure with 422 configured", configured: http.StatusUnprocessableEntity, body: `{"model":"gpt-4o"}`,
+			want: http.StatusUnprocessableEntity},
+		{name: "validation failure with the default", configured: http.StatusBadRequest, body: `{"model":"gpt-4o"}`,
+			want: http.StatusBadRequest},
+		{name: "validation failure without a configured status", body: `{"model":"gpt-4o","messages":[]}`,
+			want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			constant.ValidationErrorStatus = tt.configured
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			info := &relaycommon.RelayInfo{UsingGroup: "default", RelayMode: relayconstant.RelayModeChatCompletions}
+
+			_, err := getAndValidateTextRequest(c, info)
+			if err == nil {
+				t.Fatal("getAndValidateTextRequest() error = nil")
+			}
+			if status := validationErrorStatus(err); status != tt.want {
+				t.Errorf("validationErrorStatus(%v) = %d, want %d", err, status, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetAndValidateTextRequestMessageCaps(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	maxMessages, maxContentLength := constant.MaxMessagesPerRequest, constant.MaxMessagesContentLength
+	t.Cleanup(func() {
+		constant.MaxMessagesPerRequest, constant.MaxMessagesContentLength = maxMessages, maxContentLength
+	})
+
+	const threeMessages = `{"model":"gpt-4o","messages":[{"role":"system","content":"be brief"},` +
+		`{"role":"user","content":"hello"},{"role":"user","content":[{"type":"text","text":"there"}]}]}`
+	tests := []struct {
+		name             string
+		maxMessages      int
+		maxContentLength int
+		wantErr          string
+	}{
+		{name: "caps disabled"},
+		{name: "message count at the cap", maxMessages: 3},
+		{name: "message count over the cap", maxMessages: 2, wantErr: "too many messages: 3, the maximum is 2"},
+		{name: "content length at the cap", maxContentLength: 18},
+		{name: "content length over the cap", maxContentLength: 17, wantErr: "messages content is too long: 18, the maximum is 17"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			constant.MaxMessagesPerRequest, constant.MaxMessagesContentLength = tt.maxMessages, tt.maxContentLength
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(threeMessages))
+			c.Request.Header.Set("Content-Type", "application/json")
+			info := &relaycommon.RelayInfo{UsingGroup: "default", RelayMode: relayconstant.RelayModeChatCompletions}
+
+			_, err := getAndValidateTextRequest(c, info)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("getAndValidateTextRequest() error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("getAndValidateTextRequest() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetAndValidateTextRequestPrediction(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name       string
+		prediction string
+		wantErr    string
+	}{
+		{name: "text content", prediction: `{"type":"content","content":"func main() {}"}`},
+		{name: "content parts", prediction: `{"type":"content","content":[{"type":"text","text":"func main() {}"}]}`},
+		{name: "unsupported type", prediction: `{"type":"diff","content":"x"}`, wantErr: "invalid prediction type, must be content"},
+		{name: "missing content", prediction: `{"type":"content"}`, wantErr: "field prediction.content is required"},
+		{name: "null content", prediction: `{"type":"content","content":null}`, wantErr: "field prediction.content is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}],"prediction":` + tt.prediction + `}`
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			info := &relaycommon.RelayInfo{UsingGroup: "default", RelayMode: relayconstant.RelayModeChatCompletions}
+
+			_, err := getAndValidateTextRequest(c, info)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("getAndValidateTextRequest() error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("getAndValidateTextRequest() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// setupConsumeQuotaTestDB points the main database at an in-memory SQLite
+// database holding user #1 and captures the consume log instead of writing it.
+func setupConsumeQuotaTestDB(t *testing.T) *model.RecordConsumeLogParams {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sqlite handle: %v", err)
+	}
+	// 内存数据库每个连接相互独立，限制为单连接以共享同一份数据
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&model.User{}, &model.Channel{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := db.Create(&model.User{Id: 1, Username: "consume", AffCode: "consume", Quota: 1000000}).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	mainDB, batchUpdateEnabled, logConsumeEnabled, hook := model.DB, common.BatchUpdateEnabled, common.LogConsumeEnabled, model.ConsumeLogHook
+	model.DB, common.BatchUpdateEnabled, common.LogConsumeEnabled = db, false, false
+	logged := &model.RecordConsumeLogParams{}
+	model.ConsumeLogHook = func(c *gin.Context, userId int, params model.RecordConsumeLogParams) { *logged = params }
+	t.Cleanup(func() {
+		model.DB, common.BatchUpdateEnabled, common.LogConsumeEnabled, model.ConsumeLogHook = mainDB, batchUpdateEnabled, logConsumeEnabled, hook
+	})
+	return logged
+}
+
+func TestPostConsumeQuotaBillsReasoningTokens(t *testing.T) {
+	logged := setupConsumeQuotaTestDB(t)
+	globalSettings := model_setting.GetGlobalSettings()
+	reasoningTokenRatio := globalSettings.ReasoningTokenRatio
+	t.Cleanup(func() { globalSettings.ReasoningTokenRatio = reasoningTokenRatio })
+
+	// 模型倍率 1、补全倍率 4：100 输入 token，1000 补全 token 中 800 为推理 token
+	tests := []struct {
+		name          string
+		model         string
+		ratio         float64
+		wantQuota     int
+		wantReasoning bool
+	}{
+		{name: "default ratio keeps completion billing", model: "o3", ratio: 1, wantQuota: 100 + 1000*4, wantReasoning: true},
+		{name: "discounted reasoning tokens", model: "o3", ratio: 0.5, wantQuota: 100 + 200*4 + 800*4*0.5, wantReasoning: true},
+		{name: "Claude thinking uses the Claude setting", model: "claude-3-7-sonnet", ratio: 0.5, wantQuota: 100 + 1000*4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			globalSettings.ReasoningTokenRatio = tt.ratio
+			*logged = model.RecordConsumeLogParams{}
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			info := &relaycommon.RelayInfo{UserId: 1, ChannelId: 1, OriginModelName: tt.model, UsingGroup: "default",
+				IsPlayground: true, UserQuota: 1000000, StartTime: time.Now()}
+			usage := &dto.Usage{PromptTokens: 100, CompletionTokens: 1000, TotalTokens: 1100,
+				CompletionTokenDetails: dto.OutputTokenDetails{ReasoningTokens: 800}}
+			priceData := helper.PriceData{ModelRatio: 1, CompletionRatio: 4, GroupRatioInfo: helper.GroupRatioInfo{GroupRatio: 1}}
+
+			postConsumeQuota(c, info, usage, 0, 1000000, priceData, "")
+			if logged.Quota != tt.wantQuota {
+				t.Errorf("quota = %d, want %d", logged.Quota, tt.wantQuota)
+			}
+			reasoningTokens, ok := logged.Other["reasoning_tokens"]
+			if ok != tt.wantReasoning {
+				t.Fatalf("log other = %v, reasoning recorded %v, want %v", logged.Other, ok, tt.wantReasoning)
+			}
+			if ok && (reasoningTokens != 800 || logged.Other["reasoning_ratio"] != tt.ratio) {
+				t.Errorf("log other = %v, want 800 reasoning tokens at ratio %v", logged.Other, tt.ratio)
+			}
+		})
+	}
+}
+
+func TestPostConsumeQuotaBillsOutputModalities(t *testing.T) {
+	logged := setupConsumeQuotaTestDB(t)
+	globalSettings := model_setting.GetGlobalSettings()
+	modalityRatio := globalSettings.ModelOutputModalityRatio
+	globalSettings.ModelOutputModalityRatio = map[string]model_setting.OutputModalityRatio{
+		"gemini-2.5-flash-image": {Image: 10},
+		"gemini-2.5-flash-tts":   {Audio: 2},
+	}
+	t.Cleanup(func() { globalSettings.ModelOutputModalityRatio = modalityRatio })
+
+	// 模型倍率 1、补全倍率 4：100 输入 token，1000 补全 token 中 600 为图片 token、300 为音频 token
+	tests := []struct {
+		name      string
+		model     string
+		wantQuota int
+		wantOther map[string]interface{}
+	}{
+		{name: "image tokens use the image ratio", model: "gemini-2.5-flash-image", wantQuota: 100 + 400*4 + 600*4*10,
+			wantOther: map[string]interface{}{"image_output_tokens": 600, "image_output_ratio": 10.0}},
+		{name: "audio tokens use the audio ratio", model: "gemini-2.5-flash-tts", wantQuota: 100 + 700*4 + 300*4*2,
+			wantOther: map[string]interface{}{"audio_output_tokens": 300, "audio_output_ratio": 2.0}},
+		{name: "model without ratios is billed as before", model: "gemini-2.5-flash", wantQuota: 100 + 1000*4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			*logged = model.RecordConsumeLogParams{}
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			info := &relaycommon.RelayInfo{UserId: 1, ChannelId: 1, OriginModelName: tt.model, UsingGroup: "default",
+				IsPlayground: true, UserQuota: 1000000, StartTime: time.Now()}
+			usage := &dto.Usage{PromptTokens: 100, CompletionTokens: 1000, TotalTokens: 1100,
+				CompletionTokenDetails: dto.OutputTokenDetails{ImageTokens: 600, AudioTokens: 300}}
+			priceData := helper.PriceData{ModelRatio: 1, CompletionRatio: 4, GroupRatioInfo: helper.GroupRatioInfo{GroupRatio: 1}}
+
+			postConsumeQuota(c, info, usage, 0, 1000000, priceData, "")
+			if logged.Quota != tt.wantQuota {
+				t.Errorf("quota = %d, want %d", logged.Quota, tt.wantQuota)
+			}
+			for _, key := range []string{"image_output_tokens", "image_output_ratio", "audio_output_tokens", "audio_output_ratio"} {
+				if got, want := logged.Other[key], tt.wantOther[key]; got != want {
+					t.Errorf("log other[%q] = %v, want %v", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPostConsumeQuotaBillsPredictionTokens(t *testing.T) {
+	logged := setupConsumeQuotaTestDB(t)
+	globalSettings := model_setting.GetGlobalSettings()
+	acceptedRatio, rejectedRatio := globalSettings.AcceptedPredictionTokenRatio, globalSettings.RejectedPredictionTokenRatio
+	t.Cleanup(func() {
+		globalSettings.AcceptedPredictionTokenRatio, globalSettings.RejectedPredictionTokenRatio = acceptedRatio, rejectedRatio
+	})
+
+	// 模型倍率 1、补全倍率 4：100 输入 token，1000 补全 token 中 300 为采纳、200 为拒绝的预测 token
+	tests := []struct {
+		name                  string
+		acceptedRatio         float64
+		rejectedRatio         float64
+		accepted, rejected    int
+		wantQuota             int
+		wantPredictionDetails bool
+	}{
+		{name: "default ratios keep completion billing", acceptedRatio: 1, rejectedRatio: 1, accepted: 300, rejected: 200,
+			wantQuota: 100 + 1000*4, wantPredictionDetails: true},
+		{name: "accepted tokens discounted", acceptedRatio: 0.5, rejectedRatio: 1, accepted: 300, rejected: 200,
+			wantQuota: 100 + 500*4 + 300*4*0.5 + 200*4, wantPredictionDetails: true},
+		{name: "rejected tokens free", acceptedRatio: 1, rejectedRatio: 0, accepted: 300, rejected: 200,
+			wantQuota: 100 + 500*4 + 300*4, wantPredictionDetails: true},
+		{name: "only rejected tokens", acceptedRatio: 0.5, rejectedRatio: 2, rejected: 200,
+			wantQuota: 100 + 800*4 + 200*4*2, wantPredictionDetails: true},
+		{name: "no prediction tokens", acceptedRatio: 0.5, rejectedRatio: 0, wantQuota: 100 + 1000*4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			globalSettings.AcceptedPredictionTokenRatio, globalSettings.RejectedPredictionTokenRatio = tt.acceptedRatio, tt.rejectedRatio
+			*logged = model.RecordConsumeLogParams{}
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			info := &relaycommon.RelayInfo{UserId: 1, ChannelId: 1, OriginModelName: "gpt-4o", UsingGroup: "default",
+				IsPlayground: true, UserQuota: 1000000, StartTime: time.Now()}
+			usage := &dto.Usage{PromptTokens: 100, CompletionTokens: 1000, TotalTokens: 1100,
+				CompletionTokenDetails: dto.OutputTokenDetails{AcceptedPredictionTokens: tt.accepted, RejectedPredictionTokens: tt.rejected}}
+			priceData := helper.PriceData{ModelRatio: 1, CompletionRatio: 4, GroupRatioInfo: helper.GroupRatioInfo{GroupRatio: 1}}
+
+			postConsumeQuota(c, info, usage, 0, 1000000, priceData, "")
+			if logged.Quota != tt.wantQuota {
+				t.Errorf("quota = %d, want %d", logged.Quota, tt.wantQuota)
+			}
+			_, ok := logged.Other["accepted_prediction_tokens"]
+			if ok != tt.wantPredictionDetails {
+				t.Fatalf("log other = %v, prediction recorded %v, want %v", logged.Other, ok, tt.wantPredictionDetails)
+			}
+			if ok && (logged.Other["accepted_prediction_tokens"] != tt.accepted || logged.Other["rejected_prediction_tokens"] != tt.rejected ||
+				logged.Other["accepted_prediction_ratio"] != tt.acceptedRatio || logged.Other["rejected_prediction_ratio"] != tt.rejectedRatio) {
+				t.Errorf("log other = %v, want %d/%d prediction tokens at ratios %v/%v", logged.Other,
+					tt.accepted, tt.rejected, tt.acceptedRatio, tt.rejectedRatio)
+			}
+		})
+	}
+}
+
+func TestConsumeQuotaBillsClaudeThinkingTokens(t *testing.T) {
+	logged := setupConsumeQuotaTestDB(t)
+	claudeSettings := model_setting.GetClaudeSettings()
+	thinkingTokenRatio := claudeSettings.ThinkingTokenRatio
+	t.Cleanup(func() { claudeSettings.ThinkingTokenRatio = thinkingTokenRatio })
+
+	// 模型倍率 1、补全倍率 4：100 输入 token，1000 补全 token 中 800 为思考 token，思考倍率乘在补全倍率之上
+	consumers := []struct {
+		name    string
+		consume func(c *gin.Context, info *relaycommon.RelayInfo, usage *dto.Usage, priceData helper.PriceData)
+	}{
+		{name: "openai format", consume: func(c *gin.Context, info *relaycommon.RelayInfo, usage *dto.Usage, priceData helper.PriceData) {
+			postConsumeQuota(c, info, usage, 0, 1000000, priceData, "")
+		}},
+		{name: "claude format", consume: func(c *gin.Context, info *relaycommon.RelayInfo, usage *dto.Usage, priceData helper.PriceData) {
+			service.PostClaudeConsumeQuota(c, info, usage, 0, 1000000, priceData, "")
+		}},
+	}
+	tests := []struct {
+		name      string
+		ratio     float64
+		wantQuota int
+	}{
+		{name: "unset ratio bills thinking as completion", wantQuota: 100 + 1000*4},
+		{name: "ratio scales the completion ratio", ratio: 1.5, wantQuota: 100 + 200*4 + 800*4*1.5},
+		{name: "discounted thinking tokens", ratio: 0.25, wantQuota: 100 + 200*4 + 800*4*0.25},
+	}
+	for _, consumer := range consumers {
+		for _, tt := range tests {
+			t.Run(consumer.name+"/"+tt.name, func(t *testing.T) {
+				gin.SetMode(gin.TestMode)
+				claudeSettings.ThinkingTokenRatio = tt.ratio
+				*logged = model.RecordConsumeLogParams{}
+				c, _ := gin.CreateTestContext(httptest.NewRecorder())
+				c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+				info := &relaycommon.RelayInfo{UserId: 1, ChannelId: 1, OriginModelName: "claude-3-7-sonnet", UsingGroup: "default",
+					IsPlayground: true, UserQuota: 1000000, StartTime: time.Now()}
+				usage := &dto.Usage{PromptTokens: 100, CompletionTokens: 1000, TotalTokens: 1100,
+					CompletionTokenDetails: dto.OutputTokenDetails{ReasoningTokens: 800}}
+				priceData := helper.PriceData{ModelRatio: 1, CompletionRatio: 4, GroupRatioInfo: helper.GroupRatioInfo{GroupRatio: 1}}
+
+				consumer.consume(c, info, usage, priceData)
+				if logged.Quota != tt.wantQuota {
+					t.Errorf("quota = %d, want %d", logged.Quota, tt.wantQuota)
+				}
+				if thinkingRatio, ok := logged.Other["thinking_ratio"]; ok != (tt.ratio > 0) || (ok && thinkingRatio != tt.ratio) {
+					t.Errorf("log other = %v, want thinking ratio %v", logged.Other, tt.ratio)
+				}
+			})
+		}
+	}
+}
+
+func TestPostConsumeQuotaSendsRequestCostHeader(t *testing.T) {
+	logged := setupConsumeQuotaTestDB(t)
+	setting := operation_setting.GetQuotaHeaderSetting()
+	saved := *setting
+	setting.RequestCostEnabled, setting.Groups = true, nil
+	t.Cleanup(func() { *setting = saved })
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	info := &relaycommon.RelayInfo{UserId: 1, ChannelId: 1, OriginModelName: "gpt-4o", UsingGroup: "default",
+		IsPlayground: true, UserQuota: 1000000, StartTime: time.Now()}
+	usage := &dto.Usage{PromptTokens: 100, CompletionTokens: 200, TotalTokens: 300}
+	priceData := helper.PriceData{ModelRatio: 1.25, CompletionRatio: 4, GroupRatioInfo: helper.GroupRatioInfo{GroupRatio: 1}}
+
+	service.HoldResponseForBilledHeaders(c, info)
+	// 非流式响应在计费前已由适配器写出
+	c.JSON(http.StatusOK, gin.H{"id": "chatcmpl-1"})
+	postConsumeQuota(c, info, usage, 0, 1000000, priceData, "")
+
+	if logged.Quota != (100+200*4)*1.25 {
+		t.Fatalf("logged quota = %d, want %v", logged.Quota, (100+200*4)*1.25)
+	}
+	if got := recorder.Header().Get(service.RequestCostHeader); got != strconv.Itoa(logged.Quota) {
+		t.Errorf("%s = %q, want the logged quota %d", service.RequestCostHeader, got, logged.Quota)
+	}
+	if recorder.Body.String() != `{"id":"chatcmpl-1"}` {
+		t.Errorf("body = %q, want the response written after billing", recorder.Body.String())
+	}
+}
+
+func TestEstimatePromptTokensFallback(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":"你好，世界"}]}`
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	info := &relaycommon.RelayInfo{RelayMode: relayconstant.RelayModeUnknown}
+
+	// 无法识别的中继模式无法计算 token，按请求体字符数估算
+	_, countErr := getPromptTokens(&dto.GeneralOpenAIRequest{Model: "gpt-4o"}, info)
+	if countErr == nil {
+		t.Fatal("getPromptTokens() returned no error for an unknown relay mode")
+	}
+	promptTokens, err := estimatePromptTokens(c, info, countErr)
+	if err != nil {
+		t.Fatalf("estimatePromptTokens() error = %v", err)
+	}
+	want := (len([]rune(body)) + 1) / 2
+	if promptTokens != want || info.PromptTokens != want {
+		t.Errorf("prompt tokens = %d, info %d, want %d", promptTokens, info.PromptTokens, want)
+	}
+	if !info.PromptTokensEstimated {
+		t.Error("PromptTokensEstimated = false, want the estimate flagged")
+	}
+	other := service.GenerateTextOtherInfo(c, info, 1, 1, 1, 0, 0, -1, -1)
+	if other["prompt_tokens_estimated"] != true {
+		t.Errorf("log other = %v, want prompt_tokens_estimated", other)
+	}
+}
@@ -12,7 +12,6 @@ import (
 	relaycommon "one-api/relay/common"
 	"one-api/relay/helper"
 	"one-api/service"
-	"one-api/setting"
 	"one-api/setting/model_setting"
 	"strings"
 
@@ -31,21 +30,32 @@ func getAndValidateResponsesRequest(c *gin.Context) (*dto.OpenAIResponsesRequest
 	if len(request.Input) == 0 {
 		return nil, errors.New("input is required")
 	}
+	if request.MaxOutputTokens > maxRequestTokens {
+		return nil, errors.New("max_output_tokens is invalid")
+	}
 	return request, nil
 
 }
 
-func checkInputSensitive(textRequest *dto.OpenAIResponsesRequest, info *relaycommon.RelayInfo) ([]string, error) {
-	sensitiveWords, err := service.CheckSensitiveInput(textRequest.Input)
-	return sensitiveWords, err
-}
-
 func getInputTokens(req *dto.OpenAIResponsesRequest, info *relaycommon.RelayInfo) int {
 	inputTokens := service.CountTokenInput(req.Input, req.Model)
 	info.PromptTokens = inputTokens
 	return inputTokens
 }
 
+// clampMaxOutputTokens lowers max_output_tokens to the model's configured cap
+// and records the adjustment like the parameter policy does.
+func clampMaxOutputTokens(c *gin.Context, req *dto.OpenAIResponsesRequest, info *relaycommon.RelayInfo) {
+	maxOutputTokens := model_setting.GetModelMaxOutputTokens(info.OriginModelName)
+	if maxOutputTokens <= 0 || req.MaxOutputTokens <= uint(maxOutputTokens) {
+		return
+	}
+	adjustment := fmt.Sprintf("max_output_tokens: %d -> %d", req.MaxOutputTokens, maxOutputTokens)
+	info.ParamAdjustments = append(info.ParamAdjustments, adjustment)
+	common.LogInfo(c, "clamped request parameter "+adjustment)
+	req.MaxOutputTokens = uint(maxOutputTokens)
+}
+
 func ResponsesHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode) {
 	req, err := getAndValidateResponsesRequest(c)
 	if err != nil {
@@ -54,21 +64,26 @@ func ResponsesHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode)
 	}
 
 	relayInfo := relaycommon.GenRelayInfoResponses(c, req)
-
-	if setting.ShouldCheckPromptSensitive() {
-		sensitiveWords, err := checkInputSensitive(req, relayInfo)
-		if err != nil {
-			common.LogWarn(c, fmt.Sprintf("user sensitive words detected: %s", strings.Join(sensitiveWords, ", ")))
-			return service.OpenAIErrorWrapperLocal(err, "check_request_sensitive_error", http.StatusBadRequest)
-		}
+	if shouldRelayResponsesViaChat(relayInfo) {
+		return relayResponsesViaChat(c, req)
 	}
+	return relayResponsesRequest(c, relayInfo, req)
+}
 
+// relayResponsesRequest relays a validated responses request to the selected
+// channel and bills the usage.
+func relayResponsesRequest(c *gin.Context, relayInfo *relaycommon.RelayInfo, req *dto.OpenAIResponsesRequest) (openaiErr *dto.OpenAIErrorWithStatusCode) {
+	var err error
 	err = helper.ModelMappedHelper(c, relayInfo, req)
 	if err != nil {
 		return service.OpenAIErrorWrapperLocal(err, "model_mapped_error", http.StatusBadRequest)
 	}
+	// 对话请求经 responses 接口转发时，转换回的对话输出同样需要打码
+	helper.SetupOutputRedaction(c, relayInfo)
+
+	clampMaxOutputTokens(c, req, relayInfo)
 
-	if value, exists := c.Get("prompt_tokens"); exists {
+	if value, exists := c.Get("prompt_tokens"); exists && value != nil {
 		promptTokens := value.(int)
 		relayInfo.SetPromptTokens(promptTokens)
 	} else {
@@ -96,12 +111,12 @@ func ResponsesHelper(c *gin.Context) (openaiErr *dto.OpenAIErrorWithStatusCode)
 	}
 	adaptor.Init(relayInfo)
 	var requestBody io.Reader
-	if model_setting.GetGlobalSettings().PassThroughRequestEnabled {
-		body, err := common.GetRequestBody(c)
+	if model_setting.GetGlobalSettings().PassThroughRequestEnabled && relayInfo.ApiConversion == "" {
+		body, err := common.GetRequestBodyReader(c)
 		if err != nil {
 			return service.OpenAIErrorWrapperLocal(err, "get_request_body_error", http.StatusInternalServerError)
 		}
-		requestBody = bytes.NewBuffer(body)
+		requestBody = body
 	} else {
 		convertedRequest, err := adaptor.ConvertOpenAIResponsesRequest(c, relayInfo, *req)
 		if err != nil {
@@ -0,0 +1,85 @@
+package relay
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/model_setting"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestClampMaxOutputTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	settings := model_setting.GetGlobalSettings()
+	saved := settings.ModelMaxOutputTokens
+	settings.ModelMaxOutputTokens = map[string]int{"gpt-4o": 4096}
+	t.Cleanup(func() { settings.ModelMaxOutputTokens = saved })
+
+	tests := []struct {
+		name       string
+		model      string
+		maxTokens  uint
+		want       uint
+		adjustment string
+	}{
+		{name: "within range", model: "gpt-4o", maxTokens: 1024, want: 1024},
+		{name: "at the cap", model: "gpt-4o", maxTokens: 4096, want: 4096},
+		{name: "over the cap is clamped", model: "gpt-4o", maxTokens: 100000, want: 4096, adjustment: "max_output_tokens: 100000 -> 4096"},
+		{name: "unset is left to the upstream", model: "gpt-4o", maxTokens: 0, want: 0},
+		{name: "model without a cap", model: "gpt-4o-mini", maxTokens: 100000, want: 100000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("POST", "/v1/responses", nil)
+			info := &relaycommon.RelayInfo{OriginModelName: tt.model}
+			req := &dto.OpenAIResponsesRequest{Model: tt.model, MaxOutputTokens: tt.maxTokens}
+			clampMaxOutputTokens(c, req, info)
+
+			if req.MaxOutputTokens != tt.want {
+				t.Errorf("max_output_tokens = %d, want %d", req.MaxOutputTokens, tt.want)
+			}
+			if tt.adjustment == "" && len(info.ParamAdjustments) != 0 {
+				t.Errorf("adjustments = %v, want none", info.ParamAdjustments)
+			}
+			if tt.adjustment != "" && !slices.Equal(info.ParamAdjustments, []string{tt.adjustment}) {
+				t.Errorf("adjustments = %v, want [%s]", info.ParamAdjustments, tt.adjustment)
+			}
+		})
+	}
+}
+
+func TestGetAndValidateResponsesRequestMaxOutputTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{name: "within range", body: `{"model":"gpt-4o","input":"hi","max_output_tokens":1024}`},
+		{name: "negative", body: `{"model":"gpt-4o","input":"hi","max_output_tokens":-1}`, wantErr: true},
+		{name: "absurdly large", body: `{"model":"gpt-4o","input":"hi","max_output_tokens":2000000000}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("POST", "/v1/responses", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			if _, err := getAndValidateResponsesRequest(c); (err != nil) != tt.wantErr {
+				t.Errorf("getAndValidateResponsesRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				return
+			}
+			// 无效取值在选择渠道前以本地 400 拒绝
+			if openaiErr := ResponsesHelper(c); openaiErr == nil || openaiErr.StatusCode != http.StatusBadRequest || !openaiErr.LocalError {
+				t.Errorf("ResponsesHelper() error = %+v, want a local 400", openaiErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package relay
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultChannelTypeMaxStops 上游接口允许的 stop 数量
+var defaultChannelTypeMaxStops = map[int]int{
+	constant.ChannelTypeOpenAI: 4,
+	constant.ChannelTypeAzure:  4,
+	constant.ChannelTypeGemini: 5,
+}
+
+func maxStopSequences(channelType int) int {
+	if limit, ok := operation_setting.GetChannelTypeMaxStops(channelType); ok {
+		return limit
+	}
+	return defaultChannelTypeMaxStops[channelType]
+}
+
+// mergeStopSequences puts the injected sequences before the client's,
+// drops duplicates and keeps at most limit sequences (0 means no limit).
+func mergeStopSequences(injected []string, client []string, limit int) []string {
+	seen := make(map[string]bool, len(injected)+len(client))
+	merged := make([]string, 0, len(injected)+len(client))
+	for _, stops := range [][]string{injected, client} {
+		for _, stop := range stops {
+			if stop == "" || seen[stop] {
+				continue
+			}
+			seen[stop] = true
+			merged = append(merged, stop)
+		}
+	}
+	if limit > 0 && len(merged) > limit {
+		merged = merged[:limit]
+	}
+	return merged
+}
+
+// applyStopSequencePolicy merges the stop sequences configured for the group
+// and model into the client's stop of completion requests.
+func applyStopSequencePolicy(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) {
+	if info.RelayMode != relayconstant.RelayModeChatCompletions && info.RelayMode != relayconstant.RelayModeCompletions {
+		return
+	}
+	injected := operation_setting.GetInjectedStopSequences(info.UsingGroup, info.OriginModelName)
+	if len(injected) == 0 {
+		return
+	}
+	client := request.ParseStop()
+	merged := mergeStopSequences(injected, client, maxStopSequences(info.ChannelType))
+	stops := make([]any, len(merged))
+	for i, stop := range merged {
+		stops[i] = stop
+	}
+	request.Stop = stops
+	info.StopInjected = true
+	common.LogInfo(c, fmt.Sprintf("injected stop sequences, %d client stops, %d sent", len(client), len(merged)))
+}
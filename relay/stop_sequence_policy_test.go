@@ -0,0 +1,100 @@
+package relay
+
+import (
+	"net/http/httptest"
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/setting/operation_setting"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMergeStopSequences(t *testing.T) {
+	tests := []struct {
+		name     string
+		injected []string
+		client   []string
+		limit    int
+		want     []string
+	}{
+		{name: "injected first", injected: []string{"###"}, client: []string{"a", "b"}, limit: 0, want: []string{"###", "a", "b"}},
+		{name: "duplicates dropped", injected: []string{"a", "###"}, client: []string{"a", "b", "###"}, limit: 0, want: []string{"a", "###", "b"}},
+		{name: "empty dropped", injected: []string{"", "###"}, client: []string{""}, limit: 0, want: []string{"###"}},
+		{name: "limit keeps injected", injected: []string{"x", "y"}, client: []string{"a", "b", "c"}, limit: 4, want: []string{"x", "y", "a", "b"}},
+		{name: "limit below injected", injected: []string{"x", "y", "z"}, client: []string{"a"}, limit: 2, want: []string{"x", "y"}},
+		{name: "no client stops", injected: []string{"x"}, client: nil, limit: 4, want: []string{"x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeStopSequences(tt.injected, tt.client, tt.limit); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeStopSequences() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxStopSequences(t *testing.T) {
+	setting := operation_setting.GetStopSequenceSetting()
+	maxStops := setting.ChannelTypeMaxStops
+	setting.ChannelTypeMaxStops = map[int]int{constant.ChannelTypeAnthropic: 8, constant.ChannelTypeAzure: 0}
+	t.Cleanup(func() { setting.ChannelTypeMaxStops = maxStops })
+
+	tests := []struct {
+		name        string
+		channelType int
+		want        int
+	}{
+		{name: "openai default", channelType: constant.ChannelTypeOpenAI, want: 4},
+		{name: "gemini default", channelType: constant.ChannelTypeGemini, want: 5},
+		{name: "configured", channelType: constant.ChannelTypeAnthropic, want: 8},
+		{name: "configured unlimited", channelType: constant.ChannelTypeAzure, want: 0},
+		{name: "unknown unlimited", channelType: constant.ChannelTypeOllama, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := maxStopSequences(tt.channelType); got != tt.want {
+				t.Errorf("maxStopSequences(%d) = %d, want %d", tt.channelType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyStopSequencePolicy(t *testing.T) {
+	setting := operation_setting.GetStopSequenceSetting()
+	groupStops := setting.GroupStops
+	setting.GroupStops = map[string][]string{"vip": {"<END>", "###"}}
+	t.Cleanup(func() { setting.GroupStops = groupStops })
+
+	tests := []struct {
+		name        string
+		relayMode   int
+		channelType int
+		stop        any
+		want        any
+	}{
+		{name: "string stop", relayMode: relayconstant.RelayModeChatCompletions, channelType: constant.ChannelTypeOpenAI,
+			stop: "a", want: []any{"<END>", "###", "a"}},
+		{name: "openai limit", relayMode: relayconstant.RelayModeChatCompletions, channelType: constant.ChannelTypeOpenAI,
+			stop: []any{"a", "b", "c"}, want: []any{"<END>", "###", "a", "b"}},
+		{name: "gemini limit", relayMode: relayconstant.RelayModeCompletions, channelType: constant.ChannelTypeGemini,
+			stop: []any{"a", "b", "c", "d"}, want: []any{"<END>", "###", "a", "b", "c"}},
+		{name: "embeddings untouched", relayMode: relayconstant.RelayModeEmbeddings, channelType: constant.ChannelTypeOpenAI,
+			stop: "a", want: "a"},
+	}
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			info := &relaycommon.RelayInfo{RelayMode: tt.relayMode, ChannelType: tt.channelType, UsingGroup: "vip"}
+			request := &dto.GeneralOpenAIRequest{Stop: tt.stop}
+			applyStopSequencePolicy(c, info, request)
+			if !reflect.DeepEqual(request.Stop, tt.want) {
+				t.Errorf("stop = %#v, want %#v", request.Stop, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/setting/model_setting"
+	"one-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkStreamPolicy rejects non-streaming requests from users or groups
+// whose streaming policy is force.
+func checkStreamPolicy(info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) error {
+	if request.Stream || operation_setting.GetStreamPolicy(info.UserSetting.StreamPolicy, info.UsingGroup) != operation_setting.StreamPolicyForce {
+		return nil
+	}
+	return errors.New("streaming is required for your plan, please set stream to true")
+}
+
+// applyStreamModePolicy switches the upstream request to the streaming mode
+// forced for the model. The client still receives the mode it asked for: the
+// OpenAI adaptor buffers or synthesizes the stream, see
+// openai.OaiStreamToNonStreamHandler and openai.OaiNonStreamToStreamHandler.
+//
+// Streaming requests from users or groups whose policy disallows streaming
+// are turned into non-streaming ones. Where the stream can be buffered the
+// upstream is still streamed, otherwise the upstream request is sent
+// without streaming; usage is billed from the upstream response either way.
+func applyStreamModePolicy(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) {
+	disallowed := request.Stream && operation_setting.GetStreamPolicy(info.UserSetting.StreamPolicy, info.UsingGroup) == operation_setting.StreamPolicyDisallow
+	if disallowed {
+		request.Stream = false
+		request.StreamOptions = nil
+		info.IsStream = false
+		common.LogInfo(c, "streaming is disallowed for the user, responding without streaming")
+	}
+	if info.RelayMode != relayconstant.RelayModeChatCompletions || info.ApiType != constant.APITypeOpenAI ||
+		info.RelayFormat != relaycommon.RelayFormatOpenAI || model_setting.GetGlobalSettings().PassThroughRequestEnabled {
+		return
+	}
+	mode := model_setting.GetForcedStreamMode(info.OriginModelName)
+	if disallowed && mode == "" {
+		mode = model_setting.StreamModeForceStream
+	}
+	switch {
+	case mode == model_setting.StreamModeForceStream && !request.Stream:
+		request.Stream = true
+		request.StreamOptions = nil
+		if info.SupportStreamOptions {
+			// 需要上游返回用量以正确计费
+			request.StreamOptions = &dto.StreamOptions{IncludeUsage: true}
+		}
+	case mode == model_setting.StreamModeForceNonStream && request.Stream:
+		request.Stream = false
+		request.StreamOptions = nil
+	default:
+		return
+	}
+	info.StreamModeForced = mode
+	info.IsStream = request.Stream
+	common.LogInfo(c, fmt.Sprintf("forced upstream stream mode %s for model %s", mode, info.OriginModelName))
+}
@@ -0,0 +1,143 @@
+package relay
+
+import (
+	"one-api/constant"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/setting/model_setting"
+	"one-api/setting/operation_setting"
+	"testing"
+)
+
+func TestApplyStreamModePolicy(t *testing.T) {
+	settings := model_setting.GetStreamModeSettings()
+	saved := settings.Models
+	settings.Models = map[string]string{
+		"o1":     model_setting.StreamModeForceNonStream,
+		"gpt-4o": model_setting.StreamModeForceStream,
+	}
+	t.Cleanup(func() { settings.Models = saved })
+
+	tests := []struct {
+		name          string
+		body          string
+		stream        bool
+		includeUsage  bool
+		forced        string
+		supportsUsage bool
+	}{
+		{name: "non-streaming request is streamed upstream", body: `{"model":"gpt-4o","messages":[]}`,
+			stream: true, includeUsage: true, forced: model_setting.StreamModeForceStream, supportsUsage: true},
+		{name: "stream options are only sent when supported", body: `{"model":"gpt-4o","messages":[]}`,
+			stream: true, forced: model_setting.StreamModeForceStream},
+		{name: "streaming request is sent without streaming", body: `{"model":"o1","messages":[],"stream":true,"stream_options":{"include_usage":true}}`,
+			forced: model_setting.StreamModeForceNonStream},
+		{name: "request already in the forced mode is left alone", body: `{"model":"gpt-4o","messages":[],"stream":true}`,
+			stream: true},
+		{name: "model without forced mode is left alone", body: `{"model":"gpt-4o-mini","messages":[],"stream":true}`,
+			stream: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, info, request := newParamPolicyTestRequest(t, tt.body)
+			info.RelayMode = relayconstant.RelayModeChatCompletions
+			info.ApiType = constant.APITypeOpenAI
+			info.RelayFormat = relaycommon.RelayFormatOpenAI
+			info.OriginModelName = request.Model
+			info.SupportStreamOptions = tt.supportsUsage
+			info.IsStream = request.Stream
+
+			applyStreamModePolicy(c, info, request)
+
+			if request.Stream != tt.stream || info.IsStream != tt.stream {
+				t.Errorf("stream = %v, info.IsStream = %v, want %v", request.Stream, info.IsStream, tt.stream)
+			}
+			if info.StreamModeForced != tt.forced {
+				t.Errorf("StreamModeForced = %q, want %q", info.StreamModeForced, tt.forced)
+			}
+			if includeUsage := request.StreamOptions != nil && request.StreamOptions.IncludeUsage; tt.forced != "" && includeUsage != tt.includeUsage {
+				t.Errorf("stream_options.include_usage = %v, want %v", includeUsage, tt.includeUsage)
+			}
+		})
+	}
+}
+
+func setGroupStreamPolicy(t *testing.T, groups map[string]string) {
+	t.Helper()
+	setting := operation_setting.GetStreamPolicySetting()
+	saved := setting.Groups
+	setting.Groups = groups
+	t.Cleanup(func() { setting.Groups = saved })
+}
+
+func TestCheckStreamPolicy(t *testing.T) {
+	setGroupStreamPolicy(t, map[string]string{"default": operation_setting.StreamPolicyForce})
+
+	tests := []struct {
+		name       string
+		body       string
+		userPolicy string
+		rejected   bool
+	}{
+		{name: "non-streaming request is rejected", body: `{"model":"gpt-4o","messages":[]}`, rejected: true},
+		{name: "streaming request is accepted", body: `{"model":"gpt-4o","messages":[],"stream":true}`},
+		{name: "user policy overrides the group", body: `{"model":"gpt-4o","messages":[]}`,
+			userPolicy: operation_setting.StreamPolicyAllow},
+		{name: "unknown user policy falls back to the group", body: `{"model":"gpt-4o","messages":[]}`,
+			userPolicy: "sometimes", rejected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, info, request := newParamPolicyTestRequest(t, tt.body)
+			info.UserSetting.StreamPolicy = tt.userPolicy
+
+			if err := checkStreamPolicy(info, request); (err != nil) != tt.rejected {
+				t.Errorf("checkStreamPolicy() error = %v, rejected want %v", err, tt.rejected)
+			}
+		})
+	}
+}
+
+func TestApplyStreamModePolicyDisallowedStream(t *testing.T) {
+	setGroupStreamPolicy(t, map[string]string{"default": operation_setting.StreamPolicyDisallow})
+	settings := model_setting.GetStreamModeSettings()
+	saved := settings.Models
+	settings.Models = map[string]string{"o1": model_setting.StreamModeForceNonStream}
+	t.Cleanup(func() { settings.Models = saved })
+
+	tests := []struct {
+		name     string
+		body     string
+		apiType  int
+		upstream bool
+		forced   string
+	}{
+		// 可以聚合流式响应时上游仍然流式
+		{name: "upstream is still streamed and buffered", body: `{"model":"gpt-4o","messages":[],"stream":true}`,
+			apiType: constant.APITypeOpenAI, upstream: true, forced: model_setting.StreamModeForceStream},
+		{name: "model forced to non-streaming is sent without streaming", body: `{"model":"o1","messages":[],"stream":true}`,
+			apiType: constant.APITypeOpenAI},
+		{name: "other adaptors are sent without streaming", body: `{"model":"claude-3-5-sonnet","messages":[],"stream":true}`,
+			apiType: constant.APITypeAnthropic},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, info, request := newParamPolicyTestRequest(t, tt.body)
+			info.RelayMode = relayconstant.RelayModeChatCompletions
+			info.ApiType = tt.apiType
+			info.RelayFormat = relaycommon.RelayFormatOpenAI
+			info.OriginModelName = request.Model
+			info.SupportStreamOptions = true
+			info.IsStream = request.Stream
+
+			applyStreamModePolicy(c, info, request)
+
+			if request.Stream != tt.upstream || info.IsStream != tt.upstream {
+				t.Errorf("stream = %v, info.IsStream = %v, want %v", request.Stream, info.IsStream, tt.upstream)
+			}
+			if info.StreamModeForced != tt.forced {
+				t.Errorf("StreamModeForced = %q, want %q", info.StreamModeForced, tt.forced)
+			}
+		})
+	}
+}
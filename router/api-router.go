@@ -19,11 +19,13 @@ func SetApiRouter(router *gin.Engine) {
 		apiRouter.GET("/uptime/status", controller.GetUptimeKumaStatus)
 		apiRouter.GET("/models", middleware.UserAuth(), controller.DashboardListModels)
 		apiRouter.GET("/status/test", middleware.AdminAuth(), controller.TestStatus)
+		apiRouter.GET("/status/live", middleware.AdminAuth(), controller.GetLiveStatus)
 		apiRouter.GET("/notice", controller.GetNotice)
 		apiRouter.GET("/about", controller.GetAbout)
 		//apiRouter.GET("/midjourney", controller.GetMidjourney)
 		apiRouter.GET("/home_page_content", controller.GetHomePageContent)
 		apiRouter.GET("/pricing", middleware.TryUserAuth(), controller.GetPricing)
+		apiRouter.POST("/pricing/reload", middleware.AdminAuth(), controller.ReloadPricing)
 		apiRouter.GET("/verification", middleware.CriticalRateLimit(), middleware.TurnstileCheck(), controller.SendEmailVerification)
 		apiRouter.GET("/reset_password", middleware.CriticalRateLimit(), middleware.TurnstileCheck(), controller.SendPasswordResetEmail)
 		apiRouter.POST("/user/reset", middleware.CriticalRateLimit(), controller.ResetPassword)
@@ -74,6 +76,10 @@ func SetApiRouter(router *gin.Engine) {
 				adminRoute.POST("/manage", controller.ManageUser)
 				adminRoute.PUT("/", controller.UpdateUser)
 				adminRoute.DELETE("/:id", controller.DeleteUser)
+				adminRoute.DELETE("/:id/data", controller.DeleteUserData)
+				adminRoute.PUT("/:id/model_alias", controller.UpdateUserModelAlias)
+				adminRoute.PUT("/:id/quota_alerts", controller.UpdateUserQuotaAlerts)
+				adminRoute.PUT("/:id/stream_policy", controller.UpdateUserStreamPolicy)
 			}
 		}
 		optionRoute := apiRouter.Group("/option")
@@ -90,6 +96,12 @@ func SetApiRouter(router *gin.Engine) {
 			ratioSyncRoute.GET("/channels", controller.GetSyncableChannels)
 			ratioSyncRoute.POST("/fetch", controller.FetchUpstreamRatios)
 		}
+		modelDenyRoute := apiRouter.Group("/model_deny_list")
+		modelDenyRoute.Use(middleware.AdminAuth())
+		{
+			modelDenyRoute.GET("/", controller.GetModelDenyList)
+			modelDenyRoute.PUT("/", controller.UpdateModelDenyList)
+		}
 		channelRoute := apiRouter.Group("/channel")
 		channelRoute.Use(middleware.AdminAuth())
 		{
@@ -100,6 +112,7 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.GET("/:id", controller.GetChannel)
 			channelRoute.GET("/test", controller.TestAllChannels)
 			channelRoute.GET("/test/:id", controller.TestChannel)
+			channelRoute.GET("/key_health/:id", controller.GetChannelKeyHealth)
 			channelRoute.GET("/update_balance", controller.UpdateAllChannelsBalance)
 			channelRoute.GET("/update_balance/:id", controller.UpdateChannelBalance)
 			channelRoute.POST("/", controller.AddChannel)
@@ -138,6 +151,19 @@ func SetApiRouter(router *gin.Engine) {
 			redemptionRoute.DELETE("/invalid", controller.DeleteInvalidRedemption)
 			redemptionRoute.DELETE("/:id", controller.DeleteRedemption)
 		}
+		rateLimitTierRoute := apiRouter.Group("/rate_limit_tier")
+		rateLimitTierRoute.Use(middleware.AdminAuth())
+		{
+			rateLimitTierRoute.GET("/", controller.GetAllRateLimitTiers)
+			rateLimitTierRoute.POST("/", controller.AddRateLimitTier)
+			rateLimitTierRoute.PUT("/", controller.UpdateRateLimitTier)
+			rateLimitTierRoute.DELETE("/:id", controller.DeleteRateLimitTier)
+		}
+		auditRoute := apiRouter.Group("/audit")
+		auditRoute.Use(middleware.AdminAuth())
+		{
+			auditRoute.GET("/", controller.GetAuditLogs)
+		}
 		logRoute := apiRouter.Group("/log")
 		logRoute.GET("/", middleware.AdminAuth(), controller.GetAllLogs)
 		logRoute.DELETE("/", middleware.AdminAuth(), controller.DeleteHistoryLogs)
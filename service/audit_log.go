@@ -0,0 +1,156 @@
+package service
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	AuditActionChannelCreate = "channel.create"
+	AuditActionChannelUpdate = "channel.update"
+	AuditActionChannelDelete = "channel.delete"
+	AuditActionOptionUpdate  = "option.update"
+	AuditActionUserQuota     = "user.quota"
+
+	AuditTargetChannel = "channel"
+	AuditTargetOption  = "option"
+	AuditTargetUser    = "user"
+)
+
+const auditRedacted = "***"
+
+// 审计日志中需要脱敏的字段，键名比较时忽略大小写
+var auditSensitiveFields = map[string]bool{
+	"key":           true,
+	"password":      true,
+	"access_token":  true,
+	"client_key":    true,
+	"secret":        true,
+	"client_secret": true,
+}
+
+// AuditDiffEntry 单个字段变更前后的值
+type AuditDiffEntry struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// RecordAudit writes an audit entry for an admin mutation made by the user
+// of the request. before and after may be nil for creations and deletions;
+// sensitive fields are redacted and only changed fields appear in the diff.
+// Failures are logged and never fail the admin request.
+func RecordAudit(c *gin.Context, action string, targetType string, targetId any, before any, after any) {
+	if !constant.AuditLogEnabled {
+		return
+	}
+	beforeMap := auditSnapshot(before)
+	afterMap := auditSnapshot(after)
+	entry := &model.AuditLog{
+		ActorId:    c.GetInt("id"),
+		ActorName:  c.GetString("username"),
+		Action:     action,
+		TargetType: targetType,
+		TargetId:   fmt.Sprintf("%v", targetId),
+		Before:     auditEncode(redactAuditFields(beforeMap)),
+		After:      auditEncode(redactAuditFields(afterMap)),
+		Diff:       auditEncode(DiffAuditSnapshots(beforeMap, afterMap)),
+		Ip:         c.ClientIP(),
+	}
+	if err := entry.Insert(); err != nil {
+		common.LogError(c, fmt.Sprintf("failed to record audit log %s %s#%s: %s", action, targetType, entry.TargetId, err.Error()))
+	}
+}
+
+// DiffAuditSnapshots returns the fields whose values differ between before
+// and after. Values of sensitive fields are redacted, so a changed secret is
+// reported without revealing it.
+func DiffAuditSnapshots(before map[string]any, after map[string]any) map[string]AuditDiffEntry {
+	diff := make(map[string]AuditDiffEntry)
+	for field, value := range before {
+		if other, ok := after[field]; !ok || !reflect.DeepEqual(value, other) {
+			diff[field] = AuditDiffEntry{Before: redactAuditValue(field, value), After: redactAuditValue(field, after[field])}
+		}
+	}
+	for field, value := range after {
+		if _, ok := before[field]; !ok {
+			diff[field] = AuditDiffEntry{After: redactAuditValue(field, value)}
+		}
+	}
+	return diff
+}
+
+// auditSnapshot converts a value to a map of its JSON fields. Values that
+// are not JSON objects are stored under the "value" field.
+func auditSnapshot(value any) map[string]any {
+	if value == nil || (reflect.ValueOf(value).Kind() == reflect.Ptr && reflect.ValueOf(value).IsNil()) {
+		return nil
+	}
+	data, err := common.EncodeJson(value)
+	if err != nil {
+		return map[string]any{"value": fmt.Sprintf("%v", value)}
+	}
+	var fields map[string]any
+	if err := common.UnmarshalJson(data, &fields); err != nil {
+		var raw any
+		_ = common.UnmarshalJson(data, &raw)
+		return map[string]any{"value": raw}
+	}
+	return fields
+}
+
+func redactAuditFields(fields map[string]any) map[string]any {
+	if fields == nil {
+		return nil
+	}
+	redacted := make(map[string]any, len(fields))
+	for field, value := range fields {
+		redacted[field] = redactAuditValue(field, value)
+	}
+	return redacted
+}
+
+// redactAuditValue hides sensitive fields, including those nested in JSON
+// objects or in strings holding a JSON object such as channel settings.
+func redactAuditValue(field string, value any) any {
+	if auditSensitiveFields[strings.ToLower(field)] {
+		if value == nil || value == "" {
+			return value
+		}
+		return auditRedacted
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		return redactAuditFields(v)
+	case string:
+		if !strings.HasPrefix(strings.TrimSpace(v), "{") {
+			return v
+		}
+		var nested map[string]any
+		if err := common.UnmarshalJsonStr(v, &nested); err != nil {
+			return v
+		}
+		encoded, err := common.EncodeJson(redactAuditFields(nested))
+		if err != nil {
+			return v
+		}
+		return string(encoded)
+	}
+	return value
+}
+
+func auditEncode(value any) string {
+	if value == nil || reflect.ValueOf(value).Len() == 0 {
+		return ""
+	}
+	data, err := common.EncodeJson(value)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
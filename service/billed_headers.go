@@ -0,0 +1,180 @@
+package service
+
+import (
+	"bytes"
+	"one-api/common"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// RequestCostHeader carries the final quota of a request.
+	RequestCostHeader = "X-Request-Cost"
+	// QuotaRemainingHeader carries the user's balance after the request is
+	// billed; QuotaWarningHeader flags it when below the warning threshold.
+	QuotaRemainingHeader = "X-Quota-Remaining"
+	QuotaWarningHeader   = "X-Quota-Warning"
+)
+
+// billedResponseWriter holds a non-streaming response until the request has
+// been billed, so headers computed from the final quota are sent with it.
+// The first flush marks the response as a stream: the held bytes are written
+// at once and the billed headers are sent as HTTP trailers instead.
+type billedResponseWriter struct {
+	gin.ResponseWriter
+	headers []string
+	status  int
+	body    bytes.Buffer
+	held    bool
+}
+
+func (w *billedResponseWriter) Write(data []byte) (int, error) {
+	if !w.held {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.body.Write(data)
+}
+
+func (w *billedResponseWriter) WriteString(s string) (int, error) {
+	if !w.held {
+		return w.ResponseWriter.WriteString(s)
+	}
+	return w.body.WriteString(s)
+}
+
+func (w *billedResponseWriter) WriteHeader(code int) {
+	if !w.held {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *billedResponseWriter) WriteHeaderNow() {
+	if !w.held {
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	if w.status == 0 {
+		w.status = w.ResponseWriter.Status()
+	}
+}
+
+func (w *billedResponseWriter) Status() int {
+	if w.held && w.status != 0 {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+func (w *billedResponseWriter) Size() int {
+	if w.held {
+		return w.body.Len()
+	}
+	return w.ResponseWriter.Size()
+}
+
+func (w *billedResponseWriter) Written() bool {
+	if w.held {
+		return w.status != 0 || w.body.Len() > 0
+	}
+	return w.ResponseWriter.Written()
+}
+
+func (w *billedResponseWriter) Flush() {
+	if w.held {
+		// 流式响应无法等到计费完成，改为在 trailer 中返回
+		header := w.ResponseWriter.Header()
+		for _, name := range w.headers {
+			if !slices.Contains(header.Values("Trailer"), name) {
+				header.Add("Trailer", name)
+			}
+		}
+		w.release()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// release writes the held response and passes later writes through.
+func (w *billedResponseWriter) release() {
+	if !w.held {
+		return
+	}
+	w.held = false
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.body.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.body.Bytes())
+		w.body.Reset()
+	} else if w.status != 0 {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+// billedHeaderNames returns the response headers that depend on the final
+// quota of the request.
+func billedHeaderNames(c *gin.Context, relayInfo *relaycommon.RelayInfo) []string {
+	var names []string
+	if operation_setting.ShouldSendRequestCost(relayInfo.UsingGroup) {
+		names = append(names, RequestCostHeader)
+	}
+	if operation_setting.ShouldSendQuotaHeader(relayInfo.UsingGroup, c.GetBool("token_quota_header")) {
+		names = append(names, QuotaRemainingHeader, QuotaWarningHeader)
+	}
+	return names
+}
+
+// HoldResponseForBilledHeaders holds the response until SetBilledHeaders or
+// ReleaseBilledResponse runs, if any billed header is enabled for the request.
+// Retries call it again and reuse the writer that is already installed.
+func HoldResponseForBilledHeaders(c *gin.Context, relayInfo *relaycommon.RelayInfo) {
+	names := billedHeaderNames(c, relayInfo)
+	if len(names) == 0 || c.IsWebsocket() {
+		return
+	}
+	if writer, ok := c.Writer.(*billedResponseWriter); ok && writer.held {
+		writer.headers = names
+		return
+	}
+	c.Writer = &billedResponseWriter{ResponseWriter: c.Writer, headers: names, held: true}
+}
+
+// ReleaseBilledResponse writes a response still held for billed headers, e.g.
+// when the request failed before it was billed.
+func ReleaseBilledResponse(c *gin.Context) {
+	writer, ok := c.Writer.(*billedResponseWriter)
+	if !ok {
+		return
+	}
+	writer.release()
+	c.Writer = writer.ResponseWriter
+}
+
+// SetBilledHeaders sets the headers derived from the final quota and releases
+// the held response. For streams that have already been flushed they are sent
+// as trailers. userQuota is the user's balance before the request.
+func SetBilledHeaders(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, userQuota int, quota int) {
+	header := ctx.Writer.Header()
+	if operation_setting.ShouldSendRequestCost(relayInfo.UsingGroup) {
+		header.Set(RequestCostHeader, strconv.Itoa(quota))
+	}
+	if operation_setting.ShouldSendQuotaHeader(relayInfo.UsingGroup, ctx.GetBool("token_quota_header")) {
+		remainingQuota := userQuota - quota
+		threshold := common.QuotaRemindThreshold
+		if relayInfo.UserSetting.QuotaWarningThreshold != 0 {
+			threshold = int(relayInfo.UserSetting.QuotaWarningThreshold)
+		}
+		header.Set(QuotaRemainingHeader, strconv.Itoa(remainingQuota))
+		if remainingQuota < threshold {
+			header.Set(QuotaWarningHeader, "low_quota")
+		}
+	}
+	ReleaseBilledResponse(ctx)
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"net/http"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var rateLimitHeaders = []string{
+	"x-ratelimit-limit-requests",
+	"x-ratelimit-remaining-requests",
+	"x-ratelimit-reset-requests",
+	"x-ratelimit-limit-tokens",
+	"x-ratelimit-remaining-tokens",
+	"x-ratelimit-reset-tokens",
+}
+
+// RecordChannelRateLimitHeaders captures the upstream x-ratelimit-* headers
+// for channel types that opted in, so channel selection can steer away from
+// channels about to hit their limit.
+func RecordChannelRateLimitHeaders(info *relaycommon.RelayInfo, header http.Header) {
+	if info == nil || header == nil || !operation_setting.IsChannelRateLimitTracked(info.ChannelType) {
+		return
+	}
+	values := make(map[string]string)
+	for _, key := range rateLimitHeaders {
+		if v := header.Get(key); v != "" {
+			values[key] = v
+		}
+	}
+	if len(values) == 0 {
+		return
+	}
+	info.UpstreamRateLimit = values
+
+	resetAfter := time.Duration(operation_setting.GetChannelRateLimitSetting().DefaultResetSeconds) * time.Second
+	if d, ok := parseRateLimitReset(values["x-ratelimit-reset-requests"], values["x-ratelimit-reset-tokens"]); ok {
+		resetAfter = d
+	}
+	model.UpdateChannelRateLimitState(info.ChannelId, model.ChannelRateLimitState{
+		LimitRequests:     parseRateLimitNumber(values["x-ratelimit-limit-requests"]),
+		RemainingRequests: parseRateLimitNumber(values["x-ratelimit-remaining-requests"]),
+		LimitTokens:       parseRateLimitNumber(values["x-ratelimit-limit-tokens"]),
+		RemainingTokens:   parseRateLimitNumber(values["x-ratelimit-remaining-tokens"]),
+		ResetAt:           time.Now().Add(resetAfter),
+	})
+}
+
+func parseRateLimitNumber(value string) int64 {
+	n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// parseRateLimitReset returns the longest of the given reset values, which
+// may be durations like "6m0s" or plain seconds.
+func parseRateLimitReset(values ...string) (time.Duration, bool) {
+	var longest time.Duration
+	found := false
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			d = time.Duration(seconds * float64(time.Second))
+		}
+		if d > longest {
+			longest = d
+		}
+		found = true
+	}
+	return longest, found
+}
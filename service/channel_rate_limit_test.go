@@ -0,0 +1,78 @@
+package service
+
+import (
+	"maps"
+	"net/http"
+	"one-api/constant"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"testing"
+	"time"
+)
+
+func TestRecordChannelRateLimitHeaders(t *testing.T) {
+	setting := operation_setting.GetChannelRateLimitSetting()
+	saved := setting.ChannelTypes
+	setting.ChannelTypes = []int{constant.ChannelTypeOpenAI}
+	t.Cleanup(func() { setting.ChannelTypes = saved })
+
+	header := http.Header{}
+	header.Set("X-Ratelimit-Limit-Requests", "100")
+	header.Set("X-Ratelimit-Remaining-Requests", "99")
+	header.Set("X-Ratelimit-Reset-Requests", "1s")
+	header.Set("X-Ratelimit-Remaining-Tokens", "5000")
+	header.Set("X-Request-Id", "req-1")
+
+	untracked := &relaycommon.RelayInfo{ChannelId: 91101, ChannelType: constant.ChannelTypeAnthropic}
+	RecordChannelRateLimitHeaders(untracked, header)
+	if untracked.UpstreamRateLimit != nil {
+		t.Errorf("untracked channel type recorded %v", untracked.UpstreamRateLimit)
+	}
+
+	tracked := &relaycommon.RelayInfo{ChannelId: 91101, ChannelType: constant.ChannelTypeOpenAI}
+	RecordChannelRateLimitHeaders(tracked, header)
+	want := map[string]string{
+		"x-ratelimit-limit-requests":     "100",
+		"x-ratelimit-remaining-requests": "99",
+		"x-ratelimit-reset-requests":     "1s",
+		"x-ratelimit-remaining-tokens":   "5000",
+	}
+	if !maps.Equal(tracked.UpstreamRateLimit, want) {
+		t.Errorf("recorded headers = %v, want %v", tracked.UpstreamRateLimit, want)
+	}
+
+	withoutLimits := &relaycommon.RelayInfo{ChannelId: 91101, ChannelType: constant.ChannelTypeOpenAI}
+	RecordChannelRateLimitHeaders(withoutLimits, http.Header{"Content-Type": {"application/json"}})
+	if withoutLimits.UpstreamRateLimit != nil {
+		t.Errorf("response without rate limit headers recorded %v", withoutLimits.UpstreamRateLimit)
+	}
+}
+
+func TestParseRateLimitReset(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   time.Duration
+		found  bool
+	}{
+		{name: "duration", values: []string{"6m0s"}, want: 6 * time.Minute, found: true},
+		{name: "plain seconds", values: []string{"1.5"}, want: 1500 * time.Millisecond, found: true},
+		{name: "longest of both", values: []string{"2s", "20ms"}, want: 2 * time.Second, found: true},
+		{name: "invalid value is skipped", values: []string{"soon", "30"}, want: 30 * time.Second, found: true},
+		{name: "missing", values: []string{"", " "}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := parseRateLimitReset(tt.values...)
+			if got != tt.want || found != tt.found {
+				t.Errorf("parseRateLimitReset(%q) = %v, %v, want %v, %v", tt.values, got, found, tt.want, tt.found)
+			}
+		})
+	}
+
+	for value, want := range map[string]int64{"42": 42, " 7 ": 7, "": -1, "n/a": -1} {
+		if got := parseRateLimitNumber(value); got != want {
+			t.Errorf("parseRateLimitNumber(%q) = %d, want %d", value, got, want)
+		}
+	}
+}
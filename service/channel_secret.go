@@ -0,0 +1,216 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// ChannelSecretPrefix 渠道密钥以该前缀开头时，视为外部密钥管理后端中的引用，格式为 secret:<路径>[#<字段>]
+const ChannelSecretPrefix = "secret:"
+
+// SecretBackend 外部密钥管理后端
+type SecretBackend interface {
+	// GetSecret 读取路径对应的密钥，field 为空时返回整个密钥值
+	GetSecret(ctx context.Context, path string, field string) (string, error)
+}
+
+type cachedSecret struct {
+	value     string
+	expiresAt time.Time
+}
+
+var (
+	secretBackend   SecretBackend
+	secretCache     = make(map[string]cachedSecret)
+	secretCacheLock sync.RWMutex
+)
+
+// InitSecretBackend 根据 SECRETS_BACKEND 初始化密钥管理后端
+func InitSecretBackend() {
+	switch constant.SecretsBackend {
+	case constant.SecretsBackendVault:
+		secretBackend = &vaultSecretBackend{addr: strings.TrimSuffix(constant.VaultAddr, "/"), token: constant.VaultToken}
+	case constant.SecretsBackendAws:
+		secretBackend = &awsSecretBackend{
+			region:    constant.AwsSecretsRegion,
+			accessKey: constant.AwsSecretsAccessKey,
+			secretKey: constant.AwsSecretsSecretKey,
+		}
+	case "":
+		return
+	default:
+		common.FatalLog(fmt.Sprintf("unknown secrets backend: %s", constant.SecretsBackend))
+	}
+	common.SysLog(fmt.Sprintf("channel secrets backend enabled: %s", constant.SecretsBackend))
+}
+
+// ResolveChannelKey returns the upstream key of a channel. Keys of the form
+// secret:<path>[#<field>] are read from the configured secrets backend and
+// cached for SECRETS_CACHE_TTL seconds; other keys, or any key when no
+// backend is configured, are returned as stored.
+func ResolveChannelKey(ctx context.Context, key string) (string, error) {
+	if !strings.HasPrefix(key, ChannelSecretPrefix) {
+		return key, nil
+	}
+	if secretBackend == nil {
+		return key, nil
+	}
+
+	secretCacheLock.RLock()
+	cached, ok := secretCache[key]
+	secretCacheLock.RUnlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.value, nil
+	}
+
+	path, field, _ := strings.Cut(strings.TrimPrefix(key, ChannelSecretPrefix), "#")
+	if path == "" {
+		return "", errors.New("secret reference has an empty path")
+	}
+	value, err := secretBackend.GetSecret(ctx, path, field)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve channel secret %s: %w", path, err)
+	}
+	if value == "" {
+		return "", fmt.Errorf("channel secret %s is empty", path)
+	}
+
+	secretCacheLock.Lock()
+	secretCache[key] = cachedSecret{
+		value:     value,
+		expiresAt: time.Now().Add(time.Duration(constant.SecretsCacheTTL) * time.Second),
+	}
+	secretCacheLock.Unlock()
+	return value, nil
+}
+
+// pickSecretField 从 JSON 格式的密钥值中取出指定字段
+func pickSecretField(data map[string]interface{}, field string) (string, error) {
+	value, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("field %s not found in secret", field)
+	}
+	str, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("field %s in secret is not a string", field)
+	}
+	return str, nil
+}
+
+// vaultSecretBackend 读取 Vault KV 引擎（v1 与 v2 均支持），path 为包含挂载点的完整 API 路径，如 secret/data/openai
+type vaultSecretBackend struct {
+	addr  string
+	token string
+}
+
+func (b *vaultSecretBackend) GetSecret(ctx context.Context, path string, field string) (string, error) {
+	if b.addr == "" {
+		return "", errors.New("VAULT_ADDR is not set")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.addr+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", b.token)
+	resp, err := GetHttpClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned status %d", resp.StatusCode)
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := common.UnmarshalJson(respBody, &body); err != nil {
+		return "", err
+	}
+	data := body.Data
+	// KV v2 将密钥放在 data.data 中
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, hasMeta := data["metadata"]; hasMeta {
+			data = inner
+		}
+	}
+	if field == "" {
+		field = "key"
+	}
+	return pickSecretField(data, field)
+}
+
+// awsSecretBackend 调用 AWS Secrets Manager 的 GetSecretValue，path 为密钥名称或 ARN
+type awsSecretBackend struct {
+	region    string
+	accessKey string
+	secretKey string
+}
+
+func (b *awsSecretBackend) GetSecret(ctx context.Context, path string, field string) (string, error) {
+	if b.region == "" {
+		return "", errors.New("AWS_SECRETS_REGION is not set")
+	}
+	payload, err := common.EncodeJson(map[string]string{"SecretId": path})
+	if err != nil {
+		return "", err
+	}
+	endpoint := fmt.Sprintf("https://secretsmanager.%s.amazonaws.com/", b.region)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+
+	hash := sha256.Sum256(payload)
+	credentials := aws.Credentials{AccessKeyID: b.accessKey, SecretAccessKey: b.secretKey}
+	err = v4.NewSigner().SignHTTP(ctx, credentials, req, hex.EncodeToString(hash[:]), "secretsmanager", b.region, time.Now())
+	if err != nil {
+		return "", err
+	}
+	resp, err := GetHttpClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("secrets manager returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	var body struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := common.UnmarshalJson(respBody, &body); err != nil {
+		return "", err
+	}
+	if field == "" {
+		return body.SecretString, nil
+	}
+	var data map[string]interface{}
+	if err := common.UnmarshalJsonStr(body.SecretString, &data); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object: %w", err)
+	}
+	return pickSecretField(data, field)
+}
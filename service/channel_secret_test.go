@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"one-api/constant"
+	"testing"
+	"time"
+)
+
+type fakeSecretBackend struct {
+	secrets map[string]string
+	calls   int
+}
+
+func (b *fakeSecretBackend) GetSecret(ctx context.Context, path string, field string) (string, error) {
+	b.calls++
+	return b.secrets[path+"#"+field], nil
+}
+
+func setSecretBackend(t *testing.T, backend SecretBackend, ttl int) {
+	t.Helper()
+	savedBackend, savedTTL := secretBackend, constant.SecretsCacheTTL
+	secretBackend, constant.SecretsCacheTTL = backend, ttl
+	secretCacheLock.Lock()
+	savedCache := secretCache
+	secretCache = make(map[string]cachedSecret)
+	secretCacheLock.Unlock()
+	t.Cleanup(func() {
+		secretBackend, constant.SecretsCacheTTL = savedBackend, savedTTL
+		secretCacheLock.Lock()
+		secretCache = savedCache
+		secretCacheLock.Unlock()
+	})
+}
+
+func TestResolveChannelKey(t *testing.T) {
+	backend := &fakeSecretBackend{secrets: map[string]string{"openai/prod#": "sk-prod", "openai/prod#backup": "sk-backup"}}
+	setSecretBackend(t, backend, 60)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain key", key: "sk-plain", want: "sk-plain"},
+		{name: "secret reference", key: "secret:openai/prod", want: "sk-prod"},
+		{name: "secret field", key: "secret:openai/prod#backup", want: "sk-backup"},
+		{name: "empty path", key: "secret:", wantErr: true},
+		{name: "empty secret", key: "secret:missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveChannelKey(ctx, tt.key)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("ResolveChannelKey(%q) = %q, %v, want %q, error %v", tt.key, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	// 缓存有效期内不再访问后端
+	calls := backend.calls
+	if got, _ := ResolveChannelKey(ctx, "secret:openai/prod"); got != "sk-prod" || backend.calls != calls {
+		t.Errorf("cached lookup = %q with %d backend calls, want sk-prod without calls", got, backend.calls-calls)
+	}
+	secretCacheLock.Lock()
+	cached := secretCache["secret:openai/prod"]
+	cached.expiresAt = time.Now().Add(-time.Second)
+	secretCache["secret:openai/prod"] = cached
+	secretCacheLock.Unlock()
+	backend.secrets["openai/prod#"] = "sk-rotated"
+	if got, _ := ResolveChannelKey(ctx, "secret:openai/prod"); got != "sk-rotated" {
+		t.Errorf("lookup after expiry = %q, want the rotated key", got)
+	}
+}
+
+func TestResolveChannelKeyWithoutBackend(t *testing.T) {
+	setSecretBackend(t, nil, 60)
+	if got, err := ResolveChannelKey(context.Background(), "secret:openai/prod"); err != nil || got != "secret:openai/prod" {
+		t.Errorf("ResolveChannelKey() = %q, %v, want the key as stored", got, err)
+	}
+}
+
+func TestVaultSecretBackend(t *testing.T) {
+	InitHttpClient()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "vault-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/openai":
+			_, _ = w.Write([]byte(`{"data":{"data":{"key":"sk-v2","org":"org-1"},"metadata":{"version":3}}}`))
+		case "/v1/kv/openai":
+			_, _ = w.Write([]byte(`{"data":{"key":"sk-v1"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	backend := &vaultSecretBackend{addr: server.URL, token: "vault-token"}
+
+	tests := []struct {
+		name    string
+		path    string
+		field   string
+		want    string
+		wantErr bool
+	}{
+		{name: "kv v2 default field", path: "secret/data/openai", want: "sk-v2"},
+		{name: "kv v2 named field", path: "/secret/data/openai", field: "org", want: "org-1"},
+		{name: "kv v1", path: "kv/openai", want: "sk-v1"},
+		{name: "missing field", path: "kv/openai", field: "org", wantErr: true},
+		{name: "missing secret", path: "kv/other", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := backend.GetSecret(context.Background(), tt.path, tt.field)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("GetSecret(%q, %q) = %q, %v, want %q, error %v", tt.path, tt.field, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
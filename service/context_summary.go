@@ -0,0 +1,113 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	"strings"
+)
+
+const (
+	// 被摘要替换的消息条数，记录在重试请求的日志中
+	ContextSummarizedMessagesContextKey = "context_summarized_messages"
+	// 标记摘要请求本身，便于在日志中区分单独计费的摘要调用
+	ContextSummaryRequestContextKey = "context_summary_request"
+)
+
+const contextSummaryPrompt = "Summarize the following conversation between a user and an assistant. " +
+	"Keep the facts, decisions, open questions and any details needed to continue the conversation. " +
+	"Reply with the summary only."
+
+var contextLengthErrorMarkers = []string{
+	"context_length_exceeded",
+	"maximum context length",
+	"context window",
+	"prompt is too long",
+	"input is too long",
+	"too many tokens",
+}
+
+// IsContextLengthError reports whether the upstream rejected the request
+// because the prompt does not fit into the model's context window.
+func IsContextLengthError(err *dto.OpenAIErrorWithStatusCode) bool {
+	if err == nil || err.LocalError {
+		return false
+	}
+	text := strings.ToLower(fmt.Sprintf("%v %s", err.Error.Code, err.Error.Message))
+	for _, marker := range contextLengthErrorMarkers {
+		if strings.Contains(text, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitMessagesForSummary splits messages into the leading system messages,
+// the older messages to summarize and the most recent messages kept as is.
+// The recent part never starts with a tool result, so it stays paired with
+// the assistant message that called the tool.
+func SplitMessagesForSummary(messages []dto.Message, keepRecent int) (head []dto.Message, older []dto.Message, recent []dto.Message) {
+	start := 0
+	for start < len(messages) && (messages[start].Role == "system" || messages[start].Role == "developer") {
+		start++
+	}
+	boundary := len(messages) - keepRecent
+	if boundary < start {
+		boundary = start
+	}
+	for boundary > start && boundary < len(messages) && messages[boundary].Role == "tool" {
+		boundary--
+	}
+	return messages[:start], messages[start:boundary], messages[boundary:]
+}
+
+// BuildContextSummaryRequest builds the non-streaming chat request asking the
+// summary model to condense the older messages.
+func BuildContextSummaryRequest(modelName string, older []dto.Message, maxTokens int) *dto.GeneralOpenAIRequest {
+	var transcript strings.Builder
+	for _, message := range older {
+		content := message.StringContent()
+		if content == "" && len(message.ToolCalls) > 0 {
+			content = string(message.ToolCalls)
+		}
+		if content == "" {
+			continue
+		}
+		transcript.WriteString(message.Role)
+		transcript.WriteString(": ")
+		transcript.WriteString(content)
+		transcript.WriteString("\n\n")
+	}
+	request := &dto.GeneralOpenAIRequest{
+		Model: modelName,
+		Messages: []dto.Message{
+			{Role: "system", Content: contextSummaryPrompt},
+			{Role: "user", Content: transcript.String()},
+		},
+	}
+	if maxTokens > 0 {
+		request.MaxTokens = uint(maxTokens)
+	}
+	return request
+}
+
+// ReplaceMessagesWithSummary rewrites the messages of the request body to the
+// head messages, a system message holding the summary and the recent
+// messages. Other fields of the body are kept unchanged.
+func ReplaceMessagesWithSummary(body []byte, head []dto.Message, summary string, recent []dto.Message) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := common.UnmarshalJson(body, &fields); err != nil {
+		return nil, err
+	}
+	messages := make([]dto.Message, 0, len(head)+1+len(recent))
+	messages = append(messages, head...)
+	messages = append(messages, dto.Message{Role: "system", Content: "Summary of the earlier conversation:\n" + summary})
+	messages = append(messages, recent...)
+	encoded, err := common.EncodeJson(messages)
+	if err != nil {
+		return nil, err
+	}
+	fields["messages"] = encoded
+	return common.EncodeJson(fields)
+}
@@ -193,9 +193,11 @@ func OpenAIErrorToClaudeError(openAIError *dto.OpenAIErrorWithStatusCode) *dto.C
 		Type:    "new_api_error",
 		Message: openAIError.Error.Message,
 	}
+	code, _ := openAIError.Error.Code.(string)
 	return &dto.ClaudeErrorWithStatusCode{
 		Error:      claudeError,
 		StatusCode: openAIError.StatusCode,
+		Code:       code,
 	}
 }
 
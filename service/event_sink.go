@@ -0,0 +1,163 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+// RequestEvent 每个计费请求推送给外部分析系统的事件
+type RequestEvent struct {
+	RequestId        string `json:"request_id"`
+	UserId           int    `json:"user_id"`
+	TokenId          int    `json:"token_id"`
+	ChannelId        int    `json:"channel_id"`
+	Model            string `json:"model"`
+	Group            string `json:"group"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	Quota            int    `json:"quota"`
+	LatencyMs        int64  `json:"latency_ms"`
+	Status           int    `json:"status"`
+	IsStream         bool   `json:"is_stream"`
+	Timestamp        int64  `json:"timestamp"`
+}
+
+// EventSink 事件接收端，可实现为 webhook、消息队列等
+type EventSink interface {
+	Send(events []RequestEvent) error
+}
+
+// WebhookEventSink posts each batch as {"events": [...]}. When a secret is
+// set, the body is signed like webhook notifications.
+type WebhookEventSink struct {
+	Url    string
+	Secret string
+}
+
+func (s *WebhookEventSink) Send(events []RequestEvent) error {
+	body, err := json.Marshal(map[string]any{"events": events})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.Secret != "" {
+		req.Header.Set("X-Webhook-Signature", generateSignature(s.Secret, body))
+	}
+	resp, err := GetHttpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("event sink responded with status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+const eventSinkMaxRetries = 3
+
+// eventSinkRetryDelay 首次重试前的等待时间，之后每次翻倍
+var eventSinkRetryDelay = time.Second
+
+var (
+	eventSink       EventSink
+	eventBuffer     []RequestEvent
+	eventBufferLock sync.Mutex
+	// 同一时间只有一个批次在发送，保证顺序且避免并发重试放大
+	eventSendLock sync.Mutex
+)
+
+// InitEventSink starts streaming request events to EVENT_SINK_URL. It does
+// nothing when the url is not configured.
+func InitEventSink() {
+	if constant.EventSinkUrl == "" {
+		return
+	}
+	eventSink = &WebhookEventSink{Url: constant.EventSinkUrl, Secret: constant.EventSinkSecret}
+	model.ConsumeLogHook = emitRequestEvent
+	gopool.Go(func() {
+		for {
+			time.Sleep(time.Duration(constant.EventSinkFlushInterval) * time.Second)
+			FlushEventSink()
+		}
+	})
+	common.SysLog("event sink enabled: " + constant.EventSinkUrl)
+}
+
+func emitRequestEvent(c *gin.Context, userId int, params model.RecordConsumeLogParams) {
+	event := RequestEvent{
+		RequestId:        c.GetString(common.RequestIdKey),
+		UserId:           userId,
+		TokenId:          params.TokenId,
+		ChannelId:        params.ChannelId,
+		Model:            params.ModelName,
+		Group:            params.Group,
+		PromptTokens:     params.PromptTokens,
+		CompletionTokens: params.CompletionTokens,
+		Quota:            params.Quota,
+		LatencyMs:        int64(params.UseTimeSeconds) * 1000,
+		Status:           c.Writer.Status(),
+		IsStream:         params.IsStream,
+		Timestamp:        common.GetTimestamp(),
+	}
+	if startTime, ok := common.GetContextKeyType[time.Time](c, constant.ContextKeyRequestStartTime); ok {
+		event.LatencyMs = time.Since(startTime).Milliseconds()
+	}
+	eventBufferLock.Lock()
+	if len(eventBuffer) >= constant.EventSinkMaxBuffer {
+		eventBufferLock.Unlock()
+		common.LogWarn(c, "event sink buffer is full, dropping event")
+		return
+	}
+	eventBuffer = append(eventBuffer, event)
+	full := len(eventBuffer) >= constant.EventSinkBatchSize
+	eventBufferLock.Unlock()
+	if full {
+		gopool.Go(FlushEventSink)
+	}
+}
+
+// FlushEventSink sends all buffered events in batches, retrying each batch
+// with exponential backoff. It must also be called on shutdown so that no
+// buffered events are lost.
+func FlushEventSink() {
+	eventSendLock.Lock()
+	defer eventSendLock.Unlock()
+	if eventSink == nil {
+		return
+	}
+	eventBufferLock.Lock()
+	events := eventBuffer
+	eventBuffer = nil
+	eventBufferLock.Unlock()
+	for start := 0; start < len(events); start += constant.EventSinkBatchSize {
+		end := min(start+constant.EventSinkBatchSize, len(events))
+		batch := events[start:end]
+		var err error
+		for attempt := 0; attempt < eventSinkMaxRetries; attempt++ {
+			if attempt > 0 {
+				time.Sleep(eventSinkRetryDelay << (attempt - 1))
+			}
+			if err = eventSink.Send(batch); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to send %d events to event sink: %s", len(batch), err.Error()))
+		}
+	}
+}
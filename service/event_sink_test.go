@@ -0,0 +1,97 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestEventSinkBatchesAndRetries(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	InitHttpClient()
+	var batchesLock sync.Mutex
+	var batches []map[string][]map[string]any
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("X-Webhook-Signature") != generateSignature("secret", body) {
+			t.Errorf("signature = %s, want the body signed with the secret", r.Header.Get("X-Webhook-Signature"))
+		}
+		batchesLock.Lock()
+		defer batchesLock.Unlock()
+		attempts++
+		if attempts == 1 {
+			// 首次发送失败，应当重试同一批次
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		var batch map[string][]map[string]any
+		if err := json.Unmarshal(body, &batch); err != nil {
+			t.Errorf("decode batch: %v", err)
+		}
+		batches = append(batches, batch)
+	}))
+	defer server.Close()
+
+	sink, batchSize, maxBuffer, retryDelay := eventSink, constant.EventSinkBatchSize, constant.EventSinkMaxBuffer, eventSinkRetryDelay
+	eventSink = &WebhookEventSink{Url: server.URL, Secret: "secret"}
+	constant.EventSinkBatchSize, constant.EventSinkMaxBuffer, eventSinkRetryDelay = 2, 100, time.Millisecond
+	t.Cleanup(func() {
+		// 后台发送可能仍在排队，持有发送锁再恢复
+		eventSendLock.Lock()
+		defer eventSendLock.Unlock()
+		eventSink, constant.EventSinkBatchSize, constant.EventSinkMaxBuffer, eventSinkRetryDelay = sink, batchSize, maxBuffer, retryDelay
+	})
+
+	for i := 1; i <= 5; i++ {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Set(common.RequestIdKey, "req-"+strconv.Itoa(i))
+		emitRequestEvent(c, 7, model.RecordConsumeLogParams{ChannelId: 3, ModelName: "gpt-4o", TokenId: 11, Group: "default",
+			PromptTokens: 10, CompletionTokens: 20, Quota: 30, UseTimeSeconds: 2, IsStream: true})
+	}
+	// 写满一批时在后台发送，剩余事件在 flush 时发送
+	FlushEventSink()
+
+	batchesLock.Lock()
+	defer batchesLock.Unlock()
+	var requestIds []string
+	for _, batch := range batches {
+		if len(batch["events"]) == 0 || len(batch["events"]) > 2 {
+			t.Errorf("batch has %d events, want 1 to 2", len(batch["events"]))
+		}
+		for _, event := range batch["events"] {
+			requestIds = append(requestIds, event["request_id"].(string))
+		}
+	}
+	sort.Strings(requestIds)
+	if want := []string{"req-1", "req-2", "req-3", "req-4", "req-5"}; !slices.Equal(requestIds, want) {
+		t.Fatalf("delivered request ids = %v, want each of %v once", requestIds, want)
+	}
+	if attempts != len(batches)+1 {
+		t.Errorf("attempts = %d, want %d (one failed attempt retried)", attempts, len(batches)+1)
+	}
+
+	event := batches[0]["events"][0]
+	for field, want := range map[string]any{"user_id": 7.0, "token_id": 11.0, "channel_id": 3.0, "model": "gpt-4o",
+		"group": "default", "prompt_tokens": 10.0, "completion_tokens": 20.0, "quota": 30.0, "latency_ms": 2000.0,
+		"status": 200.0, "is_stream": true} {
+		if event[field] != want {
+			t.Errorf("event[%s] = %v, want %v", field, event[field], want)
+		}
+	}
+	if _, ok := event["timestamp"]; !ok {
+		t.Errorf("event = %v, want a timestamp field", event)
+	}
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"one-api/constant"
+	"sync"
+	"time"
+)
+
+var ErrGlobalConcurrencyTimeout = errors.New("global concurrency queue timeout")
+
+var (
+	globalConcurrencyOnce sync.Once
+	globalConcurrencySem  chan struct{}
+)
+
+func getGlobalConcurrencySem() chan struct{} {
+	globalConcurrencyOnce.Do(func() {
+		if constant.GlobalMaxConcurrency > 0 {
+			globalConcurrencySem = make(chan struct{}, constant.GlobalMaxConcurrency)
+		}
+	})
+	return globalConcurrencySem
+}
+
+// AcquireGlobalSlot waits up to GLOBAL_CONCURRENCY_WAIT_SECONDS for one of the
+// GLOBAL_MAX_CONCURRENCY upstream request slots of this node. The returned
+// release function must be called once the request is done.
+func AcquireGlobalSlot(ctx context.Context) (func(), error) {
+	sem := getGlobalConcurrencySem()
+	if sem == nil {
+		return func() {}, nil
+	}
+	release := func() { <-sem }
+	select {
+	case sem <- struct{}{}:
+		queueAdmitted(QueueGlobalConcurrency)
+		return release, nil
+	default:
+	}
+	done := queueEnter(QueueGlobalConcurrency)
+	timer := time.NewTimer(time.Duration(constant.GlobalConcurrencyWaitSeconds) * time.Second)
+	defer timer.Stop()
+	select {
+	case sem <- struct{}{}:
+		done(queueOutcomeAdmitted)
+		return release, nil
+	case <-timer.C:
+		done(queueOutcomeDropped)
+		return nil, ErrGlobalConcurrencyTimeout
+	case <-ctx.Done():
+		done(queueOutcomeCancelled)
+		return nil, ctx.Err()
+	}
+}
+
+func globalConcurrencyUsage() (inUse int, limit int) {
+	sem := getGlobalConcurrencySem()
+	if sem == nil {
+		return 0, 0
+	}
+	return len(sem), cap(sem)
+}
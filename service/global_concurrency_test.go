@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"one-api/constant"
+	"sync"
+	"testing"
+	"time"
+)
+
+// setGlobalConcurrency rebuilds the global slot semaphore with the given
+// limit and queue wait; it is rebuilt from the restored settings afterwards.
+func setGlobalConcurrency(t *testing.T, limit int, waitSeconds int) {
+	t.Helper()
+	savedLimit, savedWait := constant.GlobalMaxConcurrency, constant.GlobalConcurrencyWaitSeconds
+	constant.GlobalMaxConcurrency, constant.GlobalConcurrencyWaitSeconds = limit, waitSeconds
+	globalConcurrencyOnce, globalConcurrencySem = sync.Once{}, nil
+	t.Cleanup(func() {
+		constant.GlobalMaxConcurrency, constant.GlobalConcurrencyWaitSeconds = savedLimit, savedWait
+		globalConcurrencyOnce, globalConcurrencySem = sync.Once{}, nil
+	})
+}
+
+func TestAcquireGlobalSlotQueuesThenTimesOut(t *testing.T) {
+	setGlobalConcurrency(t, 1, 1)
+	drops := GetQueueStats()[QueueGlobalConcurrency].Drops
+
+	release, err := AcquireGlobalSlot(context.Background())
+	if err != nil {
+		t.Fatalf("AcquireGlobalSlot() error = %v", err)
+	}
+	if inUse, limit := globalConcurrencyUsage(); inUse != 1 || limit != 1 {
+		t.Errorf("usage = %d/%d, want 1/1", inUse, limit)
+	}
+
+	// 排队的请求在并发位释放后获得执行
+	admitted := make(chan func(), 1)
+	go func() {
+		queuedRelease, err := AcquireGlobalSlot(context.Background())
+		if err != nil {
+			t.Errorf("queued AcquireGlobalSlot() error = %v", err)
+			close(admitted)
+			return
+		}
+		admitted <- queuedRelease
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for GetQueueStats()[QueueGlobalConcurrency].Depth == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("request was not queued while the slot was taken")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	release()
+	var queuedRelease func()
+	select {
+	case queuedRelease = <-admitted:
+		if queuedRelease == nil {
+			t.FailNow()
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("queued request was not admitted after the slot was released")
+	}
+	defer queuedRelease()
+
+	// 等待超过 GLOBAL_CONCURRENCY_WAIT_SECONDS 后放弃并计为丢弃
+	start := time.Now()
+	if _, err := AcquireGlobalSlot(context.Background()); !errors.Is(err, ErrGlobalConcurrencyTimeout) {
+		t.Fatalf("AcquireGlobalSlot() error = %v, want %v", err, ErrGlobalConcurrencyTimeout)
+	}
+	if waited := time.Since(start); waited < time.Second || waited > 3*time.Second {
+		t.Errorf("waited %v, want about the 1s queue wait", waited)
+	}
+	if got := GetQueueStats()[QueueGlobalConcurrency].Drops - drops; got != 1 {
+		t.Errorf("drops = %d, want 1", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := AcquireGlobalSlot(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("AcquireGlobalSlot() with a cancelled context error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestAcquireGlobalSlotUnlimited(t *testing.T) {
+	setGlobalConcurrency(t, 0, 1)
+	for i := 0; i < 3; i++ {
+		release, err := AcquireGlobalSlot(context.Background())
+		if err != nil {
+			t.Fatalf("AcquireGlobalSlot() error = %v", err)
+		}
+		defer release()
+	}
+	if inUse, limit := globalConcurrencyUsage(); inUse != 0 || limit != 0 {
+		t.Errorf("usage = %d/%d, want 0/0 without a limit", inUse, limit)
+	}
+}
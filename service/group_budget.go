@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+type groupBudgetState struct {
+	periodStart int64
+	spent       int
+	// 本周期已告警的百分比
+	alerted map[int]bool
+}
+
+var (
+	groupBudgetStates = make(map[string]*groupBudgetState)
+	groupBudgetLock   sync.RWMutex
+)
+
+func groupBudgetPeriodStart(period string, now time.Time) int64 {
+	year, month, day := now.Date()
+	if period == operation_setting.GroupBudgetPeriodDay {
+		return time.Date(year, month, day, 0, 0, 0, 0, now.Location()).Unix()
+	}
+	return time.Date(year, month, 1, 0, 0, 0, 0, now.Location()).Unix()
+}
+
+// InitGroupBudgetChecker periodically sums the spend of every group with a
+// budget from the group spend counters. Every node tracks spend for hard
+// caps, only the master node alerts.
+func InitGroupBudgetChecker() {
+	gopool.Go(func() {
+		for {
+			checkGroupBudgets()
+			interval := operation_setting.GetGroupBudgetSetting().CheckIntervalSeconds
+			if interval <= 0 {
+				interval = 60
+			}
+			time.Sleep(time.Duration(interval) * time.Second)
+		}
+	})
+}
+
+func checkGroupBudgets() {
+	setting := operation_setting.GetGroupBudgetSetting()
+	periodStart := groupBudgetPeriodStart(setting.Period, time.Now())
+	// 先写入本节点的消费，其他节点统计时才能看到
+	model.SaveGroupSpend()
+	for group, budget := range setting.Budgets {
+		if budget.Quota <= 0 {
+			continue
+		}
+		spent, err := model.SumGroupSpend(group, periodStart)
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to sum spend of group %s: %s", group, err.Error()))
+			continue
+		}
+		percents := updateGroupBudgetSpend(group, budget, periodStart, spent)
+		if !common.IsMasterNode {
+			continue
+		}
+		for _, percent := range percents {
+			if markGroupBudgetAlerted(group, periodStart, percent) {
+				sendGroupBudgetAlert(group, budget, percent, spent)
+			}
+		}
+	}
+
+	groupBudgetLock.Lock()
+	for group := range groupBudgetStates {
+		if _, ok := setting.Budgets[group]; !ok {
+			delete(groupBudgetStates, group)
+		}
+	}
+	groupBudgetLock.Unlock()
+}
+
+// updateGroupBudgetSpend records the spend of the group and returns the alert
+// percentages crossed for the first time in this period on this node.
+func updateGroupBudgetSpend(group string, budget operation_setting.GroupBudget, periodStart int64, spent int) []int {
+	groupBudgetLock.Lock()
+	defer groupBudgetLock.Unlock()
+	state, ok := groupBudgetStates[group]
+	if !ok || state.periodStart != periodStart {
+		state = &groupBudgetState{periodStart: periodStart, alerted: make(map[int]bool)}
+		groupBudgetStates[group] = state
+	}
+	state.spent = spent
+
+	var crossed []int
+	for _, percent := range budget.GetAlertPercents() {
+		if state.alerted[percent] || int64(spent)*100 < int64(budget.Quota)*int64(percent) {
+			continue
+		}
+		state.alerted[percent] = true
+		crossed = append(crossed, percent)
+	}
+	return crossed
+}
+
+// markGroupBudgetAlerted 使用 Redis 记录告警，节点重启后同一周期不重复告警
+func markGroupBudgetAlerted(group string, periodStart int64, percent int) bool {
+	if !common.RedisEnabled {
+		return true
+	}
+	key := fmt.Sprintf("group_budget_alert:%s:%d:%d", group, periodStart, percent)
+	ok, err := common.RDB.SetNX(context.Background(), key, "1", 32*24*time.Hour).Result()
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to record group budget alert: %s", err.Error()))
+		return true
+	}
+	return ok
+}
+
+func sendGroupBudgetAlert(group string, budget operation_setting.GroupBudget, percent int, spent int) {
+	setting := operation_setting.GetGroupBudgetSetting()
+	mode := "软上限，仅告警"
+	if budget.Mode == operation_setting.GroupBudgetModeHard {
+		mode = "硬上限，达到后拒绝请求"
+	}
+	subject := fmt.Sprintf("分组 %s 消费已达上限的 %d%%", group, percent)
+	content := fmt.Sprintf("分组 %s 本周期已消费 %s，上限 %s（%s）", group,
+		common.LogQuota(spent), common.LogQuota(budget.Quota), mode)
+	common.SysLog(subject + "：" + content)
+	// 发送前复制配置，避免异步发送期间读到正在重新加载的配置
+	webhookUrl, webhookSecret, notifyRoot := setting.WebhookUrl, setting.WebhookSecret, setting.NotifyRoot
+	gopool.Go(func() {
+		if webhookUrl != "" {
+			notify := dto.NewNotify(dto.NotifyTypeGroupBudget, subject, content, nil)
+			if err := SendWebhookNotify(webhookUrl, webhookSecret, notify); err != nil {
+				common.SysError(fmt.Sprintf("failed to send group budget webhook: %s", err.Error()))
+			}
+		}
+		if notifyRoot {
+			NotifyRootUser(dto.NotifyTypeGroupBudget, subject, content)
+		}
+	})
+}
+
+// IsGroupBudgetExceeded reports whether a group with a hard cap has spent its
+// budget for the current period.
+func IsGroupBudgetExceeded(group string) bool {
+	setting := operation_setting.GetGroupBudgetSetting()
+	budget, ok := setting.Budgets[group]
+	if !ok || budget.Mode != operation_setting.GroupBudgetModeHard || budget.Quota <= 0 {
+		return false
+	}
+	periodStart := groupBudgetPeriodStart(setting.Period, time.Now())
+	groupBudgetLock.RLock()
+	defer groupBudgetLock.RUnlock()
+	state, ok := groupBudgetStates[group]
+	return ok && state.periodStart == periodStart && state.spent >= budget.Quota
+}
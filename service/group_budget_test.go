@@ -0,0 +1,104 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestCheckGroupBudgetsAlertsOncePerThreshold(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sqlite handle: %v", err)
+	}
+	// 内存数据库每个连接相互独立，限制为单连接以共享同一份数据
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&model.GroupSpend{}); err != nil {
+		t.Fatalf("migrate group_spends: %v", err)
+	}
+
+	var alertsLock sync.Mutex
+	var alerts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload WebhookPayload
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		alertsLock.Lock()
+		alerts = append(alerts, payload.Title)
+		alertsLock.Unlock()
+	}))
+	defer server.Close()
+
+	InitHttpClient()
+	setting := operation_setting.GetGroupBudgetSetting()
+	saved := *setting
+	mainDB, redisEnabled, isMasterNode := model.DB, common.RedisEnabled, common.IsMasterNode
+	model.DB, common.RedisEnabled, common.IsMasterNode = db, false, true
+	setting.Period = operation_setting.GroupBudgetPeriodMonth
+	setting.Budgets = map[string]operation_setting.GroupBudget{"vip": {Quota: 1000, Mode: operation_setting.GroupBudgetModeHard}}
+	setting.WebhookUrl, setting.NotifyRoot = server.URL, false
+	t.Cleanup(func() {
+		*setting = saved
+		model.DB, common.RedisEnabled, common.IsMasterNode = mainDB, redisEnabled, isMasterNode
+		groupBudgetLock.Lock()
+		delete(groupBudgetStates, "vip")
+		groupBudgetLock.Unlock()
+	})
+
+	waitForAlerts := func(want int) []string {
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			alertsLock.Lock()
+			got := append([]string(nil), alerts...)
+			alertsLock.Unlock()
+			if len(got) >= want || time.Now().After(deadline) {
+				// 再等一会儿，确认没有多余的告警
+				time.Sleep(50 * time.Millisecond)
+				alertsLock.Lock()
+				got = append([]string(nil), alerts...)
+				alertsLock.Unlock()
+				return got
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	steps := []struct {
+		spend    int
+		alerts   int
+		exceeded bool
+	}{
+		{spend: 500, alerts: 0},
+		{spend: 350, alerts: 1}, // 85%
+		{spend: 50, alerts: 1},  // 90%
+		{spend: 100, alerts: 2, exceeded: true},
+		{spend: 500, alerts: 2, exceeded: true},
+		{spend: 0, alerts: 2, exceeded: true},
+	}
+	for i, step := range steps {
+		model.RecordGroupSpend("vip", step.spend, time.Now().Unix())
+		checkGroupBudgets()
+		got := waitForAlerts(step.alerts)
+		if len(got) != step.alerts {
+			t.Fatalf("step %d: alerts = %v, want %d", i, got, step.alerts)
+		}
+		if exceeded := IsGroupBudgetExceeded("vip"); exceeded != step.exceeded {
+			t.Errorf("step %d: IsGroupBudgetExceeded() = %v, want %v", i, exceeded, step.exceeded)
+		}
+	}
+	if alerts[0] != "分组 vip 消费已达上限的 80%" || alerts[1] != "分组 vip 消费已达上限的 100%" {
+		t.Errorf("alerts = %v, want the 80%% alert then the 100%% alert", alerts)
+	}
+}
@@ -2,11 +2,16 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"fmt"
 	"net"
 	"net/http"
 	"net/url"
 	"one-api/common"
+	"one-api/dto"
+	"sync"
 	"time"
 
 	"golang.org/x/net/proxy"
@@ -79,3 +84,82 @@ func NewProxyHttpClient(proxyURL string) (*http.Client, error) {
 		return nil, fmt.Errorf("unsupported proxy scheme: %s", parsedURL.Scheme)
 	}
 }
+
+// channelCertClients 按代理与证书内容缓存双向 TLS 客户端，相同证书的渠道复用连接池
+var channelCertClients sync.Map
+
+func channelCertCacheKey(ctx context.Context, setting dto.ChannelSettings) (cacheKey string, certPEM string, keyPEM string, err error) {
+	certPEM, err = ResolveChannelKey(ctx, setting.ClientCert)
+	if err != nil {
+		return "", "", "", err
+	}
+	keyPEM, err = ResolveChannelKey(ctx, setting.ClientKey)
+	if err != nil {
+		return "", "", "", err
+	}
+	hash := sha256.Sum256([]byte(setting.Proxy + "\x00" + certPEM + "\x00" + keyPEM))
+	return hex.EncodeToString(hash[:]), certPEM, keyPEM, nil
+}
+
+// EvictChannelCertClient drops the cached mutual-TLS client of a channel's
+// previous settings once the channel is updated or deleted, closing its idle
+// connections. Channels sharing the certificate recreate the client on their
+// next request.
+func EvictChannelCertClient(setting dto.ChannelSettings) {
+	if !setting.HasClientCert() {
+		return
+	}
+	cacheKey, _, _, err := channelCertCacheKey(context.Background(), setting)
+	if err != nil {
+		return
+	}
+	if client, ok := channelCertClients.LoadAndDelete(cacheKey); ok {
+		client.(*http.Client).CloseIdleConnections()
+	}
+}
+
+// GetChannelHttpClient returns the client for a channel's upstream requests:
+// the shared client, a proxy client, or, when the channel has a client
+// certificate, a cached mutual-TLS client honouring the proxy as well.
+func GetChannelHttpClient(ctx context.Context, setting dto.ChannelSettings) (*http.Client, error) {
+	if !setting.HasClientCert() {
+		if setting.Proxy != "" {
+			return NewProxyHttpClient(setting.Proxy)
+		}
+		return GetHttpClient(), nil
+	}
+	cacheKey, certPEM, keyPEM, err := channelCertCacheKey(ctx, setting)
+	if err != nil {
+		return nil, err
+	}
+	if client, ok := channelCertClients.Load(cacheKey); ok {
+		return client.(*http.Client), nil
+	}
+
+	certificate, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil {
+		return nil, fmt.Errorf("invalid client cert: %w", err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if setting.Proxy != "" {
+		proxyClient, err := NewProxyHttpClient(setting.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		if proxyTransport, ok := proxyClient.Transport.(*http.Transport); ok {
+			transport = proxyTransport
+		}
+	}
+	tlsConfig := &tls.Config{}
+	if transport.TLSClientConfig != nil {
+		tlsConfig = transport.TLSClientConfig.Clone()
+	}
+	tlsConfig.Certificates = []tls.Certificate{certificate}
+	transport.TLSClientConfig = tlsConfig
+	client := &http.Client{Transport: transport}
+	if httpClient != nil {
+		client.Timeout = httpClient.Timeout
+	}
+	actual, _ := channelCertClients.LoadOrStore(cacheKey, client)
+	return actual.(*http.Client), nil
+}
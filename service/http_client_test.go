@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"one-api/dto"
+	"testing"
+	"time"
+)
+
+// newTestClientCert returns a self-signed client certificate and its key as PEM.
+func newTestClientCert(t *testing.T, commonName string) (certPEM string, keyPEM string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+// trustServer makes the client accept the test server's self-signed certificate.
+func trustServer(client *http.Client, server *httptest.Server) {
+	transport := client.Transport.(*http.Transport)
+	transport.TLSClientConfig.RootCAs = x509.NewCertPool()
+	transport.TLSClientConfig.RootCAs.AddCert(server.Certificate())
+}
+
+func TestGetChannelHttpClientPresentsClientCert(t *testing.T) {
+	InitHttpClient()
+	certPEM, keyPEM := newTestClientCert(t, "channel-7")
+	block, _ := pem.Decode([]byte(certPEM))
+	clientCert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: x509.NewCertPool()}
+	server.TLS.ClientCAs.AddCert(clientCert)
+	server.StartTLS()
+	defer server.Close()
+
+	setting := dto.ChannelSettings{ClientCert: certPEM, ClientKey: keyPEM}
+	t.Cleanup(func() { EvictChannelCertClient(setting) })
+	client, err := GetChannelHttpClient(context.Background(), setting)
+	if err != nil {
+		t.Fatalf("GetChannelHttpClient() error = %v", err)
+	}
+	trustServer(client, server)
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request with client cert: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "channel-7" {
+		t.Errorf("response = %d %q, want 200 channel-7", resp.StatusCode, body)
+	}
+
+	// 未配置证书的渠道无法完成握手
+	plain := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{}}}
+	trustServer(plain, server)
+	if resp, err := plain.Get(server.URL); err == nil {
+		resp.Body.Close()
+		t.Error("request without client cert succeeded, want the handshake to fail")
+	}
+}
+
+func TestGetChannelHttpClientCachesByCert(t *testing.T) {
+	InitHttpClient()
+	certPEM, keyPEM := newTestClientCert(t, "channel-8")
+	setting := dto.ChannelSettings{ClientCert: certPEM, ClientKey: keyPEM}
+	t.Cleanup(func() { EvictChannelCertClient(setting) })
+
+	first, err := GetChannelHttpClient(context.Background(), setting)
+	if err != nil {
+		t.Fatalf("GetChannelHttpClient() error = %v", err)
+	}
+	if second, _ := GetChannelHttpClient(context.Background(), setting); second != first {
+		t.Error("channels with the same certificate got different clients")
+	}
+	EvictChannelCertClient(setting)
+	if third, _ := GetChannelHttpClient(context.Background(), setting); third == first {
+		t.Error("evicted client was reused")
+	}
+	if client, _ := GetChannelHttpClient(context.Background(), dto.ChannelSettings{}); client != GetHttpClient() {
+		t.Error("channel without certificate did not get the shared client")
+	}
+	if _, err := GetChannelHttpClient(context.Background(), dto.ChannelSettings{ClientCert: certPEM, ClientKey: "invalid"}); err == nil {
+		t.Error("mismatched key was accepted")
+	}
+}
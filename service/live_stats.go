@@ -0,0 +1,75 @@
+package service
+
+import (
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+const inFlightPromptTokensKey = "in_flight_prompt_tokens"
+
+// 实时负载统计：进行中的中继请求、WebSocket 连接及其预估的输入 token
+var (
+	inFlightRequests     int64
+	activeWsConnections  int64
+	inFlightPromptTokens int64
+)
+
+type LiveStats struct {
+	InFlightRequests     int64 `json:"in_flight_requests"`
+	ActiveWsConnections  int64 `json:"active_ws_connections"`
+	InFlightPromptTokens int64 `json:"in_flight_prompt_tokens"`
+	// 全局并发槽位占用情况，limit 为 0 表示未限制
+	GlobalConcurrencyInUse int `json:"global_concurrency_in_use"`
+	GlobalConcurrencyLimit int `json:"global_concurrency_limit"`
+	// 各排队队列的深度、超时丢弃数及等待时间分位
+	Queues map[string]QueueStats `json:"queues"`
+}
+
+func RelayRequestStarted() {
+	atomic.AddInt64(&inFlightRequests, 1)
+}
+
+func RelayRequestFinished(c *gin.Context) {
+	atomic.AddInt64(&inFlightRequests, -1)
+	releaseInFlightPromptTokens(c)
+}
+
+func WsConnectionStarted() {
+	atomic.AddInt64(&activeWsConnections, 1)
+}
+
+func WsConnectionFinished(c *gin.Context) {
+	atomic.AddInt64(&activeWsConnections, -1)
+	releaseInFlightPromptTokens(c)
+}
+
+// SetInFlightPromptTokens records the prompt tokens of the current request.
+// Retries call it again with the same request, so only the difference to the
+// previously recorded value is added.
+func SetInFlightPromptTokens(c *gin.Context, tokens int) {
+	previous := c.GetInt(inFlightPromptTokensKey)
+	c.Set(inFlightPromptTokensKey, tokens)
+	atomic.AddInt64(&inFlightPromptTokens, int64(tokens-previous))
+}
+
+func releaseInFlightPromptTokens(c *gin.Context) {
+	tokens := c.GetInt(inFlightPromptTokensKey)
+	if tokens == 0 {
+		return
+	}
+	c.Set(inFlightPromptTokensKey, 0)
+	atomic.AddInt64(&inFlightPromptTokens, -int64(tokens))
+}
+
+func GetLiveStats() LiveStats {
+	inUse, limit := globalConcurrencyUsage()
+	return LiveStats{
+		InFlightRequests:       atomic.LoadInt64(&inFlightRequests),
+		ActiveWsConnections:    atomic.LoadInt64(&activeWsConnections),
+		InFlightPromptTokens:   atomic.LoadInt64(&inFlightPromptTokens),
+		GlobalConcurrencyInUse: inUse,
+		GlobalConcurrencyLimit: limit,
+		Queues:                 GetQueueStats(),
+	}
+}
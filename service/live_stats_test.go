@@ -0,0 +1,56 @@
+package service
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLiveStatsTracksInFlightWork(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newContext := func() *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		return c
+	}
+	base := GetLiveStats()
+	check := func(step string, requests, wsConnections, promptTokens int64) {
+		t.Helper()
+		stats := GetLiveStats()
+		if got := stats.InFlightRequests - base.InFlightRequests; got != requests {
+			t.Errorf("%s: in-flight requests = %d, want %d", step, got, requests)
+		}
+		if got := stats.ActiveWsConnections - base.ActiveWsConnections; got != wsConnections {
+			t.Errorf("%s: active ws connections = %d, want %d", step, got, wsConnections)
+		}
+		if got := stats.InFlightPromptTokens - base.InFlightPromptTokens; got != promptTokens {
+			t.Errorf("%s: in-flight prompt tokens = %d, want %d", step, got, promptTokens)
+		}
+	}
+
+	first, second, ws := newContext(), newContext(), newContext()
+	RelayRequestStarted()
+	RelayRequestStarted()
+	SetInFlightPromptTokens(first, 100)
+	SetInFlightPromptTokens(second, 40)
+	check("two requests", 2, 0, 140)
+
+	// 重试时重新计算的 token 只计入差值
+	SetInFlightPromptTokens(first, 150)
+	check("retry", 2, 0, 190)
+
+	WsConnectionStarted()
+	SetInFlightPromptTokens(ws, 10)
+	check("ws connection", 2, 1, 200)
+
+	RelayRequestFinished(first)
+	check("first finished", 1, 1, 50)
+	WsConnectionFinished(ws)
+	check("ws finished", 1, 0, 40)
+	RelayRequestFinished(second)
+	check("all finished", 0, 0, 0)
+
+	// 重复释放不会把 token 计为负数
+	releaseInFlightPromptTokens(first)
+	check("released twice", 0, 0, 0)
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// LogExportSink 日志导出目标。logs 按 id 升序排列且非空
+type LogExportSink interface {
+	Export(ctx context.Context, logs []*model.Log) error
+}
+
+// WebhookLogExportSink posts each batch as {"from_id", "to_id", "logs"}. The
+// id range lets the receiver drop a batch it has already stored. When a
+// secret is set, the body is signed like webhook notifications.
+type WebhookLogExportSink struct {
+	Url    string
+	Secret string
+}
+
+func (s *WebhookLogExportSink) Export(ctx context.Context, logs []*model.Log) error {
+	body, err := common.EncodeJson(map[string]any{
+		"from_id": logs[0].Id,
+		"to_id":   logs[len(logs)-1].Id,
+		"logs":    logs,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.Secret != "" {
+		req.Header.Set("X-Webhook-Signature", generateSignature(s.Secret, body))
+	}
+	resp, err := GetHttpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("log export webhook responded with status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// S3LogExportSink uploads each batch as an NDJSON object named after the
+// date and id range of the batch, so re-exporting a batch overwrites the
+// same object instead of duplicating rows.
+type S3LogExportSink struct {
+	Bucket    string
+	Region    string
+	Endpoint  string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+}
+
+func (s *S3LogExportSink) objectKey(logs []*model.Log) string {
+	date := time.Unix(logs[0].CreatedAt, 0).UTC().Format("2006/01/02")
+	key := fmt.Sprintf("%s/consume-%d-%d.ndjson", date, logs[0].Id, logs[len(logs)-1].Id)
+	if prefix := strings.Trim(s.Prefix, "/"); prefix != "" {
+		key = prefix + "/" + key
+	}
+	return key
+}
+
+func (s *S3LogExportSink) objectUrl(key string) string {
+	if s.Endpoint != "" {
+		return fmt.Sprintf("%s/%s/%s", strings.TrimRight(s.Endpoint, "/"), s.Bucket, key)
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.Bucket, s.Region, key)
+}
+
+func (s *S3LogExportSink) Export(ctx context.Context, logs []*model.Log) error {
+	if s.Bucket == "" {
+		return errors.New("LOG_EXPORT_S3_BUCKET is not set")
+	}
+	var body bytes.Buffer
+	for _, log := range logs {
+		line, err := common.EncodeJson(log)
+		if err != nil {
+			return err
+		}
+		body.Write(line)
+		body.WriteByte('\n')
+	}
+	payload := body.Bytes()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectUrl(s.objectKey(logs)), bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	hash := sha256.Sum256(payload)
+	payloadHash := hex.EncodeToString(hash[:])
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	credentials := aws.Credentials{AccessKeyID: s.AccessKey, SecretAccessKey: s.SecretKey}
+	err = v4.NewSigner().SignHTTP(ctx, credentials, req, payloadHash, "s3", s.Region, time.Now())
+	if err != nil {
+		return err
+	}
+	resp, err := GetHttpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("s3 returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+var (
+	logExportSink LogExportSink
+	// 同一时间只运行一次导出，避免重复导出同一批日志
+	logExportLock sync.Mutex
+)
+
+// InitLogExporter periodically exports new consume logs to LOG_EXPORT_SINK.
+// It only runs on the master node so that each log is exported once.
+func InitLogExporter() {
+	if constant.LogExportSink == "" || !common.IsMasterNode {
+		return
+	}
+	switch constant.LogExportSink {
+	case constant.LogExportSinkWebhook:
+		if constant.LogExportWebhookUrl == "" {
+			common.SysError("log export disabled: LOG_EXPORT_WEBHOOK_URL is not set")
+			return
+		}
+		logExportSink = &WebhookLogExportSink{Url: constant.LogExportWebhookUrl, Secret: constant.LogExportWebhookSecret}
+	case constant.LogExportSinkS3:
+		logExportSink = &S3LogExportSink{
+			Bucket:    constant.LogExportS3Bucket,
+			Region:    constant.LogExportS3Region,
+			Endpoint:  constant.LogExportS3Endpoint,
+			Prefix:    constant.LogExportS3Prefix,
+			AccessKey: constant.LogExportS3AccessKey,
+			SecretKey: constant.LogExportS3SecretKey,
+		}
+	default:
+		common.SysError("log export disabled: unknown LOG_EXPORT_SINK " + constant.LogExportSink)
+		return
+	}
+	gopool.Go(func() {
+		for {
+			time.Sleep(time.Duration(constant.LogExportInterval) * time.Second)
+			if err := RunLogExport(context.Background()); err != nil {
+				common.SysError("failed to export logs: " + err.Error())
+			}
+		}
+	})
+	common.SysLog("log export enabled: " + constant.LogExportSink)
+}
+
+// RunLogExport exports the consume logs above the stored watermark in
+// batches. The watermark advances only after a batch has been accepted by
+// the sink, so a failed batch is retried on the next run and no rows are
+// skipped.
+func RunLogExport(ctx context.Context) error {
+	if logExportSink == nil {
+		return nil
+	}
+	logExportLock.Lock()
+	defer logExportLock.Unlock()
+	watermark, err := model.GetLogExportWatermark()
+	if err != nil {
+		return fmt.Errorf("read watermark: %w", err)
+	}
+	createdBefore := common.GetTimestamp() - int64(constant.LogExportDelay)
+	for {
+		logs, err := model.GetConsumeLogsForExport(watermark, createdBefore, constant.LogExportBatchSize)
+		if err != nil {
+			return fmt.Errorf("query logs after id %d: %w", watermark, err)
+		}
+		if len(logs) == 0 {
+			return nil
+		}
+		lastId := logs[len(logs)-1].Id
+		if err := logExportSink.Export(ctx, logs); err != nil {
+			return fmt.Errorf("export logs %d-%d: %w", logs[0].Id, lastId, err)
+		}
+		if err := model.SaveLogExportWatermark(lastId); err != nil {
+			return fmt.Errorf("save watermark %d: %w", lastId, err)
+		}
+		watermark = lastId
+		if len(logs) < constant.LogExportBatchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"reflect"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// fakeLogExportSink records the exported log ids and fails while err is set.
+type fakeLogExportSink struct {
+	exported []int
+	batches  int
+	err      error
+}
+
+func (s *fakeLogExportSink) Export(ctx context.Context, logs []*model.Log) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.batches++
+	for _, log := range logs {
+		s.exported = append(s.exported, log.Id)
+	}
+	return nil
+}
+
+func TestRunLogExport(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sqlite handle: %v", err)
+	}
+	// 内存数据库每个连接相互独立，限制为单连接以共享同一份数据
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&model.Option{}, &model.Log{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	sink := &fakeLogExportSink{}
+	mainDB, logDB, savedSink := model.DB, model.LOG_DB, logExportSink
+	batchSize, delay := constant.LogExportBatchSize, constant.LogExportDelay
+	model.DB, model.LOG_DB, logExportSink = db, db, sink
+	constant.LogExportBatchSize, constant.LogExportDelay = 2, 0
+	t.Cleanup(func() {
+		model.DB, model.LOG_DB, logExportSink = mainDB, logDB, savedSink
+		constant.LogExportBatchSize, constant.LogExportDelay = batchSize, delay
+	})
+
+	createdAt := common.GetTimestamp() - 10
+	insertLogs := func(ids ...int) {
+		t.Helper()
+		for _, id := range ids {
+			// 非消费日志不导出
+			logType := model.LogTypeConsume
+			if id%10 == 0 {
+				logType = model.LogTypeTopup
+			}
+			if err := db.Create(&model.Log{Id: id, Type: logType, CreatedAt: createdAt}).Error; err != nil {
+				t.Fatalf("insert log %d: %v", id, err)
+			}
+		}
+	}
+	assertExported := func(want []int, watermark int) {
+		t.Helper()
+		if !reflect.DeepEqual(sink.exported, want) {
+			t.Errorf("exported = %v, want %v", sink.exported, want)
+		}
+		if got, err := model.GetLogExportWatermark(); err != nil || got != watermark {
+			t.Errorf("watermark = %d, %v, want %d", got, err, watermark)
+		}
+	}
+
+	insertLogs(1, 2, 3, 10, 4, 5)
+	if err := RunLogExport(context.Background()); err != nil {
+		t.Fatalf("RunLogExport() error = %v", err)
+	}
+	assertExported([]int{1, 2, 3, 4, 5}, 5)
+	if sink.batches != 3 {
+		t.Errorf("exported in %d batches, want 3", sink.batches)
+	}
+
+	// 再次运行不会重复导出
+	if err := RunLogExport(context.Background()); err != nil {
+		t.Fatalf("second RunLogExport() error = %v", err)
+	}
+	assertExported([]int{1, 2, 3, 4, 5}, 5)
+
+	// 导出失败时水位线不前进，下次运行重新导出同一批
+	insertLogs(6, 7)
+	sink.err = errors.New("sink unavailable")
+	if err := RunLogExport(context.Background()); err == nil {
+		t.Fatal("RunLogExport() succeeded with a failing sink")
+	}
+	assertExported([]int{1, 2, 3, 4, 5}, 5)
+	sink.err = nil
+	if err := RunLogExport(context.Background()); err != nil {
+		t.Fatalf("RunLogExport() after recovery error = %v", err)
+	}
+	assertExported([]int{1, 2, 3, 4, 5, 6, 7}, 7)
+}
@@ -1,6 +1,8 @@
 package service
 
 import (
+	"one-api/common"
+	"one-api/constant"
 	"one-api/dto"
 	relaycommon "one-api/relay/common"
 	"one-api/relay/helper"
@@ -22,10 +24,81 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 	if relayInfo.ReasoningEffort != "" {
 		other["reasoning_effort"] = relayInfo.ReasoningEffort
 	}
+	if relayInfo.RequestModelName != "" && relayInfo.RequestModelName != relayInfo.OriginModelName {
+		other["request_model_name"] = relayInfo.RequestModelName
+	}
 	if relayInfo.IsModelMapped {
 		other["is_model_mapped"] = true
 		other["upstream_model_name"] = relayInfo.UpstreamModelName
 	}
+	if len(relayInfo.ClientMetadata) > 0 {
+		other["client_metadata"] = relayInfo.ClientMetadata
+	}
+	if relayInfo.PromptTokensEstimated {
+		other["prompt_tokens_estimated"] = true
+	}
+	if relayInfo.UpstreamTiming != nil {
+		other["upstream_timing"] = relayInfo.UpstreamTiming.ToMap()
+	}
+	if redactionCount := helper.GetOutputRedactionCount(ctx); redactionCount > 0 {
+		other["redaction_count"] = redactionCount
+	}
+	if len(relayInfo.ParamDefaults) > 0 {
+		other["param_defaults"] = relayInfo.ParamDefaults
+	}
+	if len(relayInfo.ParamAdjustments) > 0 {
+		other["param_adjustments"] = relayInfo.ParamAdjustments
+	}
+	if relayInfo.DedupShared {
+		other["dedup_shared"] = true
+	}
+	if relayInfo.Refusal {
+		other["refusal"] = true
+	}
+	if relayInfo.JsonModeInjected {
+		other["json_mode_injected"] = true
+	}
+	if relayInfo.StopInjected {
+		other["stop_injected"] = true
+	}
+	if relayInfo.StreamModeForced != "" {
+		other["stream_mode_forced"] = relayInfo.StreamModeForced
+	}
+	if relayInfo.ApiConversion != "" {
+		other["api_conversion"] = relayInfo.ApiConversion
+	}
+	if len(relayInfo.UpstreamRateLimit) > 0 {
+		other["upstream_rate_limit"] = relayInfo.UpstreamRateLimit
+	}
+	if variant := common.GetContextKeyString(ctx, constant.ContextKeyABVariant); variant != "" {
+		other["ab_test"] = common.GetContextKeyString(ctx, constant.ContextKeyABTest)
+		other["ab_variant"] = variant
+	}
+	if downgradedFrom := common.GetContextKeyString(ctx, constant.ContextKeyDowngradedFrom); downgradedFrom != "" {
+		other["downgraded_from"] = downgradedFrom
+	}
+	if breakdown, ok := ctx.Get("image_price_breakdown"); ok {
+		other["image_price_breakdown"] = breakdown
+	}
+	if breakdown, ok := ctx.Get("audio_price_breakdown"); ok {
+		other["audio_price_breakdown"] = breakdown
+	}
+	if relayInfo.Truncated {
+		other["truncated"] = true
+		other["finish_reason"] = relayInfo.UpstreamFinishReason
+	}
+	if retryMaxTokens := ctx.GetInt(helper.TruncationRetryMaxTokensContextKey); retryMaxTokens > 0 {
+		other["truncation_retry_max_tokens"] = retryMaxTokens
+	}
+	if verdict, ok := ctx.Get(PromptClassifierVerdictContextKey); ok {
+		other["prompt_classifier"] = verdict
+	}
+	if summarized := ctx.GetInt(ContextSummarizedMessagesContextKey); summarized > 0 {
+		other["context_summarized_messages"] = summarized
+	}
+	if ctx.GetBool(ContextSummaryRequestContextKey) {
+		other["context_summary_request"] = true
+	}
 	adminInfo := make(map[string]interface{})
 	adminInfo["use_channel"] = ctx.GetStringSlice("use_channel")
 	other["admin_info"] = adminInfo
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"one-api/setting/operation_setting"
+	"sync"
+	"time"
+)
+
+var ErrModelConcurrencyTimeout = errors.New("model concurrency queue timeout")
+
+type concurrencyWaiter struct {
+	userId int
+	weight float64
+	seq    uint64
+	ready  chan struct{}
+}
+
+// modelLimiter hands out a model's slots. When slots are contended, the freed
+// slot goes to the waiting user with the lowest active/weight share, so a
+// heavy user cannot starve others. State is per node.
+type modelLimiter struct {
+	mu      sync.Mutex
+	inUse   int
+	active  map[int]int
+	waiters []*concurrencyWaiter
+	seq     uint64
+}
+
+var modelLimiters sync.Map // model name -> *modelLimiter
+
+func getModelLimiter(modelName string) *modelLimiter {
+	limiter, _ := modelLimiters.LoadOrStore(modelName, &modelLimiter{active: make(map[int]int)})
+	return limiter.(*modelLimiter)
+}
+
+// AcquireModelSlot waits for a concurrency slot of the model. The returned
+// release function must be called once the request is done. Models without
+// a configured limit return immediately. The waiter's weight combines the
+// group weight with the quota tier of the user's balance.
+func AcquireModelSlot(ctx context.Context, modelName string, userId int, group string, userQuota int) (func(), error) {
+	limit := operation_setting.GetModelConcurrencyLimit(modelName)
+	if limit <= 0 {
+		return func() {}, nil
+	}
+	limiter := getModelLimiter(modelName)
+	limiter.mu.Lock()
+	if limiter.inUse < limit && len(limiter.waiters) == 0 {
+		limiter.grant(userId)
+		limiter.mu.Unlock()
+		queueAdmitted(QueueModelConcurrency)
+		return limiter.releaseFunc(modelName, userId), nil
+	}
+	limiter.seq++
+	waiter := &concurrencyWaiter{
+		userId: userId,
+		weight: operation_setting.GetModelConcurrencyWeight(group) * operation_setting.GetModelConcurrencyQuotaTierWeight(userQuota),
+		seq:    limiter.seq,
+		ready:  make(chan struct{}),
+	}
+	limiter.waiters = append(limiter.waiters, waiter)
+	limiter.mu.Unlock()
+	done := queueEnter(QueueModelConcurrency)
+
+	timeout := time.Duration(operation_setting.GetModelConcurrencySetting().QueueTimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-waiter.ready:
+		done(queueOutcomeAdmitted)
+		return limiter.releaseFunc(modelName, userId), nil
+	case <-ctx.Done():
+		if limiter.cancel(waiter) {
+			done(queueOutcomeAdmitted)
+			return limiter.releaseFunc(modelName, userId), nil
+		}
+		done(queueOutcomeCancelled)
+		return nil, ctx.Err()
+	case <-timer.C:
+		if limiter.cancel(waiter) {
+			done(queueOutcomeAdmitted)
+			return limiter.releaseFunc(modelName, userId), nil
+		}
+		done(queueOutcomeDropped)
+		return nil, ErrModelConcurrencyTimeout
+	}
+}
+
+// grant must be called with mu held.
+func (l *modelLimiter) grant(userId int) {
+	l.inUse++
+	l.active[userId]++
+}
+
+// cancel removes a waiter. It reports true when the slot was granted in the
+// meantime, in which case the caller owns it.
+func (l *modelLimiter) cancel(waiter *concurrencyWaiter) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, w := range l.waiters {
+		if w == waiter {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			return false
+		}
+	}
+	return true
+}
+
+func (l *modelLimiter) releaseFunc(modelName string, userId int) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.release(modelName, userId)
+		})
+	}
+}
+
+func (l *modelLimiter) release(modelName string, userId int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inUse--
+	l.active[userId]--
+	if l.active[userId] <= 0 {
+		delete(l.active, userId)
+	}
+	limit := operation_setting.GetModelConcurrencyLimit(modelName)
+	for len(l.waiters) > 0 && (limit <= 0 || l.inUse < limit) {
+		index := l.nextWaiter()
+		waiter := l.waiters[index]
+		l.waiters = append(l.waiters[:index], l.waiters[index+1:]...)
+		l.grant(waiter.userId)
+		close(waiter.ready)
+	}
+}
+
+// nextWaiter picks the waiter whose user holds the smallest weighted share;
+// on equal shares the higher weight goes first, then arrival order. Must be
+// called with mu held.
+func (l *modelLimiter) nextWaiter() int {
+	best := 0
+	bestShare := float64(l.active[l.waiters[0].userId]) / l.waiters[0].weight
+	for i := 1; i < len(l.waiters); i++ {
+		w := l.waiters[i]
+		share := float64(l.active[w.userId]) / w.weight
+		if share < bestShare || (share == bestShare && w.before(l.waiters[best])) {
+			best = i
+			bestShare = share
+		}
+	}
+	return best
+}
+
+// before reports whether w should be served ahead of o when their shares are
+// equal.
+func (w *concurrencyWaiter) before(o *concurrencyWaiter) bool {
+	if w.weight != o.weight {
+		return w.weight > o.weight
+	}
+	return w.seq < o.seq
+}
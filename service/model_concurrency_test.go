@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"one-api/setting/operation_setting"
+	"slices"
+	"testing"
+	"time"
+)
+
+func setModelConcurrencyLimit(t *testing.T, modelName string, limit int, tiers []operation_setting.ModelConcurrencyQuotaTier) {
+	t.Helper()
+	setting := operation_setting.GetModelConcurrencySetting()
+	saved := *setting
+	setting.Enabled = true
+	setting.ModelLimits = map[string]int{modelName: limit}
+	setting.FairnessMode = operation_setting.ModelConcurrencyFairnessStrict
+	setting.QuotaTierWeights = tiers
+	setting.QueueTimeoutSeconds = 5
+	t.Cleanup(func() {
+		*setting = saved
+		modelLimiters.Delete(modelName)
+	})
+}
+
+// waitForWaiters blocks until n requests are queued for the model.
+func waitForWaiters(t *testing.T, modelName string, n int) {
+	t.Helper()
+	limiter := getModelLimiter(modelName)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		limiter.mu.Lock()
+		queued := len(limiter.waiters)
+		limiter.mu.Unlock()
+		if queued >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d requests queued, want %d", queued, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAcquireModelSlotPrefersHigherQuotaTier(t *testing.T) {
+	const modelName = "concurrency-tier-test"
+	setModelConcurrencyLimit(t, modelName, 1, []operation_setting.ModelConcurrencyQuotaTier{
+		{MinQuota: 1000, Weight: 2},
+		{MinQuota: 100000, Weight: 4},
+	})
+
+	holderRelease, err := AcquireModelSlot(context.Background(), modelName, 1, "default", 0)
+	if err != nil {
+		t.Fatalf("AcquireModelSlot() error = %v", err)
+	}
+
+	type grant struct {
+		userId  int
+		release func()
+	}
+	granted := make(chan grant, 3)
+	queue := func(userId, quota int) {
+		go func() {
+			release, err := AcquireModelSlot(context.Background(), modelName, userId, "default", quota)
+			if err != nil {
+				t.Errorf("user %d: AcquireModelSlot() error = %v", userId, err)
+				return
+			}
+			granted <- grant{userId: userId, release: release}
+		}()
+	}
+	// 低余额用户先排队，高余额用户后到但应先获得空闲的并发位
+	queue(2, 10)
+	waitForWaiters(t, modelName, 1)
+	queue(3, 5000)
+	waitForWaiters(t, modelName, 2)
+	queue(4, 200000)
+	waitForWaiters(t, modelName, 3)
+
+	holderRelease()
+	for _, want := range []int{4, 3, 2} {
+		select {
+		case g := <-granted:
+			if g.userId != want {
+				t.Fatalf("slot went to user %d, want user %d", g.userId, want)
+			}
+			g.release()
+		case <-time.After(5 * time.Second):
+			t.Fatalf("user %d was not granted a slot", want)
+		}
+	}
+}
+
+func TestAcquireModelSlotQueueTimeout(t *testing.T) {
+	const modelName = "concurrency-timeout-test"
+	setModelConcurrencyLimit(t, modelName, 1, nil)
+	operation_setting.GetModelConcurrencySetting().QueueTimeoutSeconds = 1
+
+	release, err := AcquireModelSlot(context.Background(), modelName, 1, "default", 0)
+	if err != nil {
+		t.Fatalf("AcquireModelSlot() error = %v", err)
+	}
+	defer release()
+
+	if _, err := AcquireModelSlot(context.Background(), modelName, 2, "default", 0); err != ErrModelConcurrencyTimeout {
+		t.Fatalf("queued AcquireModelSlot() error = %v, want %v", err, ErrModelConcurrencyTimeout)
+	}
+	limiter := getModelLimiter(modelName)
+	limiter.mu.Lock()
+	queued := len(limiter.waiters)
+	limiter.mu.Unlock()
+	if queued != 0 {
+		t.Errorf("%d requests still queued after the timeout", queued)
+	}
+}
+
+type queuedSlotRequest struct {
+	userId int
+	group  string
+}
+
+func TestAcquireModelSlotSharesSlotsFairly(t *testing.T) {
+	tests := []struct {
+		name         string
+		limit        int
+		fairnessMode string
+		groupWeights map[string]float64
+		// 先由 holder 占满并发位，再依次排队
+		holder int
+		queued []queuedSlotRequest
+		want   []int
+	}{
+		{name: "strict fairness alternates between users", limit: 2, fairnessMode: operation_setting.ModelConcurrencyFairnessStrict,
+			holder: 1, queued: []queuedSlotRequest{{1, "default"}, {1, "default"}, {1, "default"}, {2, "default"}, {2, "default"}},
+			want: []int{2, 1, 2, 1, 1}},
+		{name: "group weight gives the heavier group more slots", limit: 3, fairnessMode: operation_setting.ModelConcurrencyFairnessGroupWeight,
+			groupWeights: map[string]float64{"vip": 2}, holder: 3, queued: []queuedSlotRequest{{1, "vip"}, {1, "vip"}, {1, "vip"}, {2, "default"}, {2, "default"}, {2, "default"}},
+			want: []int{1, 2, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const modelName = "concurrency-fairness-test"
+			setModelConcurrencyLimit(t, modelName, tt.limit, nil)
+			setting := operation_setting.GetModelConcurrencySetting()
+			setting.FairnessMode, setting.GroupWeights = tt.fairnessMode, tt.groupWeights
+
+			var held []func()
+			for i := 0; i < tt.limit; i++ {
+				release, err := AcquireModelSlot(context.Background(), modelName, tt.holder, "default", 0)
+				if err != nil {
+					t.Fatalf("AcquireModelSlot() error = %v", err)
+				}
+				held = append(held, release)
+			}
+
+			type grant struct {
+				userId  int
+				release func()
+			}
+			granted := make(chan grant, len(tt.queued))
+			for i, q := range tt.queued {
+				go func() {
+					release, err := AcquireModelSlot(context.Background(), modelName, q.userId, q.group, 0)
+					if err != nil {
+						t.Errorf("user %d: AcquireModelSlot() error = %v", q.userId, err)
+						return
+					}
+					granted <- grant{userId: q.userId, release: release}
+				}()
+				waitForWaiters(t, modelName, i+1)
+			}
+
+			// 逐个释放并发位，记录空出的并发位依次分配给谁
+			var got []int
+			var grants []grant
+			for len(got) < len(tt.want) {
+				if len(held) > 0 {
+					held[0]()
+					held = held[1:]
+				} else {
+					grants[0].release()
+					grants = grants[1:]
+				}
+				select {
+				case g := <-granted:
+					got = append(got, g.userId)
+					grants = append(grants, g)
+				case <-time.After(5 * time.Second):
+					t.Fatalf("no slot granted after %v", got)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("slots granted to users %v, want %v", got, tt.want)
+			}
+
+			for _, release := range held {
+				release()
+			}
+			for _, g := range grants {
+				g.release()
+			}
+			for i := len(got); i < len(tt.queued); i++ {
+				(<-granted).release()
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/setting/operation_setting"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PromptClassifierVerdictContextKey 分类结果，记录在日志的 other 中
+const PromptClassifierVerdictContextKey = "prompt_classifier_verdict"
+
+var ErrPromptInjectionDetected = errors.New("prompt injection detected")
+
+// PromptClassifier 对提示词打分，返回 0~1，越高越可能是注入或越狱
+type PromptClassifier interface {
+	Classify(ctx context.Context, text string) (float64, error)
+}
+
+// HeuristicPromptClassifier sums the weights of well-known jailbreak phrases
+// found in the text, capped at 1.
+type HeuristicPromptClassifier struct{}
+
+var promptInjectionPhrases = map[string]float64{
+	"ignore previous instructions":     0.8,
+	"ignore all previous instructions": 0.9,
+	"ignore the above":                 0.6,
+	"disregard previous instructions":  0.8,
+	"disregard all prior":              0.8,
+	"forget your instructions":         0.7,
+	"reveal your system prompt":        0.7,
+	"print your system prompt":         0.7,
+	"you are now dan":                  0.9,
+	"do anything now":                  0.6,
+	"developer mode enabled":           0.6,
+	"jailbreak":                        0.4,
+	"without any restrictions":         0.3,
+	"no ethical guidelines":            0.4,
+	"pretend you have no rules":        0.6,
+}
+
+func (HeuristicPromptClassifier) Classify(_ context.Context, text string) (float64, error) {
+	lower := strings.ToLower(text)
+	score := 0.0
+	for phrase, weight := range promptInjectionPhrases {
+		if strings.Contains(lower, phrase) {
+			score += weight
+		}
+	}
+	return min(score, 1), nil
+}
+
+// RemotePromptClassifier posts {"text": ...} to an external service and reads
+// {"score": ...} from the response.
+type RemotePromptClassifier struct {
+	Url     string
+	Timeout time.Duration
+}
+
+func (r *RemotePromptClassifier) Classify(ctx context.Context, text string) (float64, error) {
+	if r.Url == "" {
+		return 0, errors.New("prompt classifier url is not set")
+	}
+	payload, err := common.EncodeJson(map[string]string{"text": text})
+	if err != nil {
+		return 0, err
+	}
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.Url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := GetHttpClient().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("prompt classifier returned status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return 0, err
+	}
+	var result struct {
+		Score float64 `json:"score"`
+	}
+	if err := common.UnmarshalJson(body, &result); err != nil {
+		return 0, err
+	}
+	return result.Score, nil
+}
+
+// promptClassifierOverride 非空时替代配置的分类器，供嵌入方接入自定义实现
+var promptClassifierOverride PromptClassifier
+
+// SetPromptClassifier replaces the configured classifier; nil restores it.
+func SetPromptClassifier(classifier PromptClassifier) {
+	promptClassifierOverride = classifier
+}
+
+func getPromptClassifier() PromptClassifier {
+	if promptClassifierOverride != nil {
+		return promptClassifierOverride
+	}
+	setting := operation_setting.GetPromptClassifierSetting()
+	if setting.Type == operation_setting.PromptClassifierRemote {
+		return &RemotePromptClassifier{Url: setting.Url, Timeout: time.Duration(setting.TimeoutMs) * time.Millisecond}
+	}
+	return HeuristicPromptClassifier{}
+}
+
+// ClassifyPrompt scores the prompt when the group has a classifier policy and
+// records the verdict in the context. It returns ErrPromptInjectionDetected
+// when the score reaches the threshold and the policy blocks. Classifier
+// failures are logged and let the request through.
+func ClassifyPrompt(c *gin.Context, group string, text string) error {
+	action, threshold, ok := operation_setting.GetPromptClassifierPolicy(group)
+	if !ok || strings.TrimSpace(text) == "" {
+		return nil
+	}
+	score, err := getPromptClassifier().Classify(c.Request.Context(), text)
+	if err != nil {
+		common.LogError(c, fmt.Sprintf("prompt classifier failed: %s", err.Error()))
+		return nil
+	}
+	flagged := score >= threshold
+	c.Set(PromptClassifierVerdictContextKey, map[string]interface{}{
+		"score":   score,
+		"flagged": flagged,
+		"action":  action,
+	})
+	if !flagged {
+		return nil
+	}
+	common.LogWarn(c, fmt.Sprintf("prompt classifier flagged request, score %.2f, action %s", score, action))
+	if action == operation_setting.PromptClassifierActionBlock {
+		return ErrPromptInjectionDetected
+	}
+	return nil
+}
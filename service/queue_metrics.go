@@ -0,0 +1,174 @@
+package service
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/setting/operation_setting"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	QueueGlobalConcurrency = "global_concurrency"
+	QueueModelConcurrency  = "model_concurrency"
+
+	queueWaitSampleSize = 1024
+)
+
+type queueOutcome int
+
+const (
+	queueOutcomeAdmitted queueOutcome = iota
+	queueOutcomeDropped
+	queueOutcomeCancelled
+)
+
+type QueueStats struct {
+	Depth     int64 `json:"depth"`
+	Drops     int64 `json:"drops"`
+	WaitP50Ms int64 `json:"wait_p50_ms"`
+	WaitP99Ms int64 `json:"wait_p99_ms"`
+}
+
+// queueMetrics keeps the depth, timeout drops and the most recent wait times
+// of one queue. State is per node.
+type queueMetrics struct {
+	mu          sync.Mutex
+	depth       int64
+	drops       int64
+	waits       []int64
+	next        int
+	alerted     map[string]bool
+	lastAlertAt map[string]time.Time
+	alertDrops  int64
+}
+
+var queueMetricsMap sync.Map // queue name -> *queueMetrics
+
+func getQueueMetrics(name string) *queueMetrics {
+	m, _ := queueMetricsMap.LoadOrStore(name, &queueMetrics{
+		waits:       make([]int64, 0, queueWaitSampleSize),
+		alerted:     make(map[string]bool),
+		lastAlertAt: make(map[string]time.Time),
+	})
+	return m.(*queueMetrics)
+}
+
+// queueAdmitted records a request that got a slot without waiting.
+func queueAdmitted(name string) {
+	m := getQueueMetrics(name)
+	m.mu.Lock()
+	m.recordWait(0)
+	m.mu.Unlock()
+}
+
+// queueEnter records a request starting to wait. The returned function must
+// be called once the wait ends. Only timeouts count as drops.
+func queueEnter(name string) func(outcome queueOutcome) {
+	m := getQueueMetrics(name)
+	m.mu.Lock()
+	m.depth++
+	m.mu.Unlock()
+	m.checkAlerts(name)
+	start := time.Now()
+	return func(outcome queueOutcome) {
+		m.mu.Lock()
+		m.depth--
+		switch outcome {
+		case queueOutcomeAdmitted:
+			m.recordWait(time.Since(start).Milliseconds())
+		case queueOutcomeDropped:
+			m.drops++
+		}
+		m.mu.Unlock()
+		m.checkAlerts(name)
+	}
+}
+
+// recordWait must be called with mu held.
+func (m *queueMetrics) recordWait(ms int64) {
+	if len(m.waits) < queueWaitSampleSize {
+		m.waits = append(m.waits, ms)
+		return
+	}
+	m.waits[m.next] = ms
+	m.next = (m.next + 1) % queueWaitSampleSize
+}
+
+// stats must be called with mu held.
+func (m *queueMetrics) stats() QueueStats {
+	stats := QueueStats{Depth: m.depth, Drops: m.drops}
+	if len(m.waits) == 0 {
+		return stats
+	}
+	sorted := append([]int64(nil), m.waits...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	stats.WaitP50Ms = sorted[(len(sorted)-1)*50/100]
+	stats.WaitP99Ms = sorted[(len(sorted)-1)*99/100]
+	return stats
+}
+
+// checkAlerts sends a webhook when a metric crosses its threshold. An alert
+// re-arms once the metric falls back below the threshold.
+func (m *queueMetrics) checkAlerts(name string) {
+	setting := operation_setting.GetQueueAlertSetting()
+	threshold, ok := setting.Thresholds[name]
+	if !ok || setting.WebhookUrl == "" {
+		return
+	}
+	m.mu.Lock()
+	stats := m.stats()
+	var alerts []string
+	check := func(metric string, value int64, limit int64) {
+		if limit <= 0 {
+			return
+		}
+		if value < limit {
+			m.alerted[metric] = false
+			return
+		}
+		cooldown := time.Duration(setting.CooldownSeconds) * time.Second
+		if m.alerted[metric] || time.Since(m.lastAlertAt[metric]) < cooldown {
+			return
+		}
+		m.alerted[metric] = true
+		m.lastAlertAt[metric] = time.Now()
+		alerts = append(alerts, fmt.Sprintf("%s 为 %d，超过阈值 %d", metric, value, limit))
+	}
+	check("depth", stats.Depth, threshold.Depth)
+	check("wait_p99_ms", stats.WaitP99Ms, threshold.WaitP99Ms)
+	check("drops", stats.Drops-m.alertDrops, threshold.Drops)
+	if m.alerted["drops"] {
+		// 新增超时数从本次告警后重新计算
+		m.alertDrops = stats.Drops
+		m.alerted["drops"] = false
+	}
+	m.mu.Unlock()
+
+	for _, alert := range alerts {
+		content := fmt.Sprintf("队列 %s 的 %s", name, alert)
+		common.SysLog(content)
+		gopool.Go(func() {
+			notify := dto.NewNotify(dto.NotifyTypeQueueAlert, "队列积压告警", content, nil)
+			if err := SendWebhookNotify(setting.WebhookUrl, setting.WebhookSecret, notify); err != nil {
+				common.SysError(fmt.Sprintf("failed to send queue alert webhook: %s", err.Error()))
+			}
+		})
+	}
+}
+
+func GetQueueStats() map[string]QueueStats {
+	result := make(map[string]QueueStats)
+	queueMetricsMap.Range(func(key, value any) bool {
+		m := value.(*queueMetrics)
+		m.mu.Lock()
+		result[key.(string)] = m.stats()
+		m.mu.Unlock()
+		return true
+	})
+	return result
+}
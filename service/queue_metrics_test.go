@@ -0,0 +1,141 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"one-api/dto"
+	"one-api/setting/operation_setting"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQueueMetrics(t *testing.T) {
+	const queue = "test_queue_metrics"
+	t.Cleanup(func() { queueMetricsMap.Delete(queue) })
+
+	first := queueEnter(queue)
+	second := queueEnter(queue)
+	third := queueEnter(queue)
+	if got := GetQueueStats()[queue].Depth; got != 3 {
+		t.Fatalf("depth = %d, want 3 while three requests wait", got)
+	}
+	first(queueOutcomeAdmitted)
+	second(queueOutcomeDropped)
+	third(queueOutcomeCancelled)
+
+	stats := GetQueueStats()[queue]
+	if stats.Depth != 0 {
+		t.Errorf("depth = %d, want 0 after every wait ended", stats.Depth)
+	}
+	if stats.Drops != 1 {
+		t.Errorf("drops = %d, want 1: only timeouts count as drops", stats.Drops)
+	}
+
+	// 1..100 毫秒各一次，共 101 个样本（含首个等待）
+	m := getQueueMetrics(queue)
+	m.mu.Lock()
+	m.waits = m.waits[:0]
+	for ms := int64(1); ms <= 100; ms++ {
+		m.recordWait(ms)
+	}
+	m.mu.Unlock()
+	queueAdmitted(queue)
+	stats = GetQueueStats()[queue]
+	if stats.WaitP50Ms != 50 || stats.WaitP99Ms != 99 {
+		t.Errorf("p50 = %d, p99 = %d, want 50 and 99", stats.WaitP50Ms, stats.WaitP99Ms)
+	}
+
+	// 样本数超过上限后覆盖最早的样本
+	m.mu.Lock()
+	for i := 0; i < queueWaitSampleSize; i++ {
+		m.recordWait(1000)
+	}
+	size := len(m.waits)
+	m.mu.Unlock()
+	stats = GetQueueStats()[queue]
+	if size != queueWaitSampleSize || stats.WaitP50Ms != 1000 {
+		t.Errorf("samples = %d, p50 = %d, want %d samples of the latest waits", size, stats.WaitP50Ms, queueWaitSampleSize)
+	}
+}
+
+func TestQueueAlertFiresOncePerCrossing(t *testing.T) {
+	const queue = "test_queue_alert"
+	var alertsLock sync.Mutex
+	var alerts []WebhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload WebhookPayload
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		alertsLock.Lock()
+		alerts = append(alerts, payload)
+		alertsLock.Unlock()
+	}))
+	defer server.Close()
+
+	InitHttpClient()
+	setting := operation_setting.GetQueueAlertSetting()
+	saved := *setting
+	*setting = operation_setting.QueueAlertSetting{WebhookUrl: server.URL,
+		Thresholds: map[string]operation_setting.QueueAlertThreshold{queue: {Depth: 2, Drops: 2}}}
+	t.Cleanup(func() {
+		*setting = saved
+		queueMetricsMap.Delete(queue)
+	})
+
+	waitForAlerts := func(want int) int {
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			alertsLock.Lock()
+			got := len(alerts)
+			alertsLock.Unlock()
+			if got >= want || time.Now().After(deadline) {
+				// 再等一会儿，确认没有多余的通知
+				time.Sleep(50 * time.Millisecond)
+				alertsLock.Lock()
+				got = len(alerts)
+				alertsLock.Unlock()
+				return got
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	var waiting []func(queueOutcome)
+	enter := func(n int) {
+		for i := 0; i < n; i++ {
+			waiting = append(waiting, queueEnter(queue))
+		}
+	}
+	leave := func(n int, outcome queueOutcome) {
+		for i := 0; i < n; i++ {
+			waiting[0](outcome)
+			waiting = waiting[1:]
+		}
+	}
+	steps := []struct {
+		name   string
+		run    func()
+		alerts int
+	}{
+		{name: "depth below the threshold", run: func() { enter(1) }},
+		{name: "depth reaches the threshold", run: func() { enter(1) }, alerts: 1},
+		{name: "depth stays above the threshold", run: func() { enter(2) }, alerts: 1},
+		{name: "depth falls back below the threshold", run: func() { leave(3, queueOutcomeAdmitted) }, alerts: 1},
+		{name: "depth crosses the threshold again", run: func() { enter(1) }, alerts: 2},
+		{name: "one drop", run: func() { leave(1, queueOutcomeDropped) }, alerts: 2},
+		{name: "drops reach the threshold", run: func() { leave(1, queueOutcomeDropped) }, alerts: 3},
+		{name: "drops count again from the last alert", run: func() { enter(1); leave(1, queueOutcomeDropped) }, alerts: 3},
+	}
+	for _, step := range steps {
+		step.run()
+		if got := waitForAlerts(step.alerts); got != step.alerts {
+			t.Fatalf("%s: %d alerts sent, want %d", step.name, got, step.alerts)
+		}
+	}
+	alertsLock.Lock()
+	defer alertsLock.Unlock()
+	if alerts[0].Type != dto.NotifyTypeQueueAlert {
+		t.Errorf("alert type = %q, want %q", alerts[0].Type, dto.NotifyTypeQueueAlert)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	relaycommon "one-api/relay/common"
 	"one-api/relay/helper"
 	"one-api/setting"
+	"one-api/setting/model_setting"
 	"one-api/setting/ratio_setting"
 	"strings"
 	"time"
@@ -226,6 +227,15 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 	})
 }
 
+// GetClaudeThinkingRatio returns the configured ratio for Claude thinking
+// tokens, or 0 when they are billed as regular completion tokens.
+func GetClaudeThinkingRatio(modelName string, thinkingTokens int) float64 {
+	if thinkingTokens <= 0 || !strings.HasPrefix(strings.ToLower(modelName), "claude") {
+		return 0
+	}
+	return model_setting.GetClaudeSettings().ThinkingTokenRatio
+}
+
 func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	usage *dto.Usage, preConsumedQuota int, userQuota int, priceData helper.PriceData, extraContent string) {
 
@@ -245,6 +255,9 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	cacheCreationRatio := priceData.CacheCreationRatio
 	cacheCreationTokens := usage.PromptTokensDetails.CachedCreationTokens
 
+	thinkingTokens := usage.CompletionTokenDetails.ReasoningTokens
+	thinkingRatio := GetClaudeThinkingRatio(modelName, thinkingTokens)
+
 	if relayInfo.ChannelType == constant.ChannelTypeOpenRouter {
 		promptTokens -= cacheTokens
 		if cacheCreationTokens == 0 && priceData.CacheCreationRatio != 1 && usage.Cost != 0 {
@@ -256,22 +269,31 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		promptTokens -= cacheCreationTokens
 	}
 
-	calculateQuota := 0.0
+	dGroupRatio := decimal.NewFromFloat(groupRatio)
+	dModelRatio := decimal.NewFromFloat(modelRatio)
+	dCompletionRatio := decimal.NewFromFloat(completionRatio)
+
+	var quotaCalculateDecimal decimal.Decimal
 	if !priceData.UsePrice {
-		calculateQuota = float64(promptTokens)
-		calculateQuota += float64(cacheTokens) * cacheRatio
-		calculateQuota += float64(cacheCreationTokens) * cacheCreationRatio
-		calculateQuota += float64(completionTokens) * completionRatio
-		calculateQuota = calculateQuota * groupRatio * modelRatio
+		promptQuota := decimal.NewFromInt(int64(promptTokens)).
+			Add(decimal.NewFromInt(int64(cacheTokens)).Mul(decimal.NewFromFloat(cacheRatio))).
+			Add(decimal.NewFromInt(int64(cacheCreationTokens)).Mul(decimal.NewFromFloat(cacheCreationRatio)))
+		completionQuota := decimal.NewFromInt(int64(completionTokens)).Mul(dCompletionRatio)
+		if thinkingRatio > 0 {
+			// 思考 token 已包含在 completion tokens 中，在补全倍率之上再乘以思考倍率
+			dThinkingTokens := decimal.NewFromInt(int64(thinkingTokens))
+			completionQuota = decimal.NewFromInt(int64(completionTokens - thinkingTokens)).Mul(dCompletionRatio).
+				Add(dThinkingTokens.Mul(dCompletionRatio).Mul(decimal.NewFromFloat(thinkingRatio)))
+		}
+		quotaCalculateDecimal = promptQuota.Add(completionQuota).Mul(dGroupRatio).Mul(dModelRatio)
+		if !dModelRatio.IsZero() && quotaCalculateDecimal.LessThanOrEqual(decimal.Zero) {
+			quotaCalculateDecimal = decimal.NewFromInt(1)
+		}
 	} else {
-		calculateQuota = modelPrice * common.QuotaPerUnit * groupRatio
+		quotaCalculateDecimal = decimal.NewFromFloat(modelPrice).Mul(decimal.NewFromFloat(common.QuotaPerUnit)).Mul(dGroupRatio)
 	}
 
-	if modelRatio != 0 && calculateQuota <= 0 {
-		calculateQuota = 1
-	}
-
-	quota := int(calculateQuota)
+	quota := int(quotaCalculateDecimal.Round(0).IntPart())
 
 	totalTokens := promptTokens + completionTokens
 
@@ -299,6 +321,11 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 
 	other := GenerateClaudeOtherInfo(ctx, relayInfo, modelRatio, groupRatio, completionRatio,
 		cacheTokens, cacheRatio, cacheCreationTokens, cacheCreationRatio, modelPrice, priceData.GroupRatioInfo.GroupSpecialRatio)
+	if thinkingRatio > 0 {
+		other["thinking_tokens"] = thinkingTokens
+		other["thinking_ratio"] = thinkingRatio
+	}
+	SetBilledHeaders(ctx, relayInfo, userQuota, quota)
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     promptTokens,
@@ -411,6 +438,7 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	}
 	other := GenerateAudioOtherInfo(ctx, relayInfo, usage, modelRatio, groupRatio,
 		completionRatio.InexactFloat64(), audioRatio.InexactFloat64(), audioCompletionRatio.InexactFloat64(), modelPrice, priceData.GroupRatioInfo.GroupSpecialRatio)
+	SetBilledHeaders(ctx, relayInfo, userQuota, quota)
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     usage.PromptTokens,
@@ -478,6 +506,7 @@ func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQu
 		if (quota + preConsumedQuota) != 0 {
 			checkAndSendQuotaNotify(relayInfo, quota, preConsumedQuota)
 		}
+		checkUserQuotaAlerts(relayInfo, quota+preConsumedQuota)
 	}
 
 	return nil
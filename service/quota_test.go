@@ -0,0 +1,153 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func enableRequestCost(t *testing.T) {
+	t.Helper()
+	setting := operation_setting.GetQuotaHeaderSetting()
+	saved := *setting
+	setting.RequestCostEnabled, setting.Groups = true, nil
+	t.Cleanup(func() { *setting = saved })
+}
+
+func TestBilledHeadersHoldNonStreamResponse(t *testing.T) {
+	enableRequestCost(t)
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	info := &relaycommon.RelayInfo{UsingGroup: "default"}
+
+	// 每次重试都会再次调用
+	for attempt := 0; attempt < 3; attempt++ {
+		HoldResponseForBilledHeaders(c, info)
+	}
+	common.IOCopyBytesGracefully(c, nil, []byte(`{"id":"1"}`))
+	if recorder.Body.Len() != 0 || recorder.Code != http.StatusOK || recorder.Flushed {
+		t.Fatalf("response was sent before billing: %q", recorder.Body.String())
+	}
+	if !c.Writer.Written() {
+		t.Error("Written() = false for a held response")
+	}
+
+	SetBilledHeaders(c, info, 10000, 1234)
+	if got := recorder.Header().Get(RequestCostHeader); got != "1234" {
+		t.Errorf("%s = %q, want 1234", RequestCostHeader, got)
+	}
+	if got := recorder.Header().Get("Content-Length"); got != "10" {
+		t.Errorf("Content-Length = %q, want 10", got)
+	}
+	if len(recorder.Header().Values("Trailer")) != 0 {
+		t.Errorf("Trailer = %v, want none for a non-stream response", recorder.Header().Values("Trailer"))
+	}
+	if recorder.Body.String() != `{"id":"1"}` {
+		t.Errorf("body = %q, want the held response", recorder.Body.String())
+	}
+}
+
+func TestBilledHeadersUseTrailersForStreams(t *testing.T) {
+	enableRequestCost(t)
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	info := &relaycommon.RelayInfo{UsingGroup: "default", IsStream: true}
+
+	HoldResponseForBilledHeaders(c, info)
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Render(-1, common.CustomEvent{Data: "data: {}"})
+	c.Writer.Flush()
+	if recorder.Body.String() != "data: {}\n\n" {
+		t.Fatalf("body = %q, want the chunk written on flush", recorder.Body.String())
+	}
+
+	SetBilledHeaders(c, info, 10000, 42)
+	result := recorder.Result()
+	if got := result.Header.Values("Trailer"); len(got) != 1 || got[0] != RequestCostHeader {
+		t.Errorf("Trailer = %v, want [%s]", got, RequestCostHeader)
+	}
+	if got := result.Trailer.Get(RequestCostHeader); got != "42" {
+		t.Errorf("trailer %s = %q, want 42", RequestCostHeader, got)
+	}
+}
+
+func TestReleaseBilledResponseWritesUnbilledResponse(t *testing.T) {
+	enableRequestCost(t)
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	writer := c.Writer
+
+	HoldResponseForBilledHeaders(c, &relaycommon.RelayInfo{UsingGroup: "default"})
+	c.JSON(http.StatusBadGateway, gin.H{"error": "upstream"})
+	ReleaseBilledResponse(c)
+
+	if c.Writer != writer {
+		t.Error("ReleaseBilledResponse did not restore the response writer")
+	}
+	if recorder.Code != http.StatusBadGateway || recorder.Body.String() != `{"error":"upstream"}` {
+		t.Errorf("response = %d %q, want the held error", recorder.Code, recorder.Body.String())
+	}
+	if recorder.Header().Get(RequestCostHeader) != "" {
+		t.Error("unbilled response has a request cost header")
+	}
+}
+
+func TestSetBilledHeadersQuotaWarning(t *testing.T) {
+	setting := operation_setting.GetQuotaHeaderSetting()
+	saved := *setting
+	setting.Enabled, setting.Groups = true, []string{"vip"}
+	t.Cleanup(func() { *setting = saved })
+
+	// 剩余额度按本次结算后的余额计算，即请求前余额减去最终额度
+	tests := []struct {
+		name          string
+		group         string
+		tokenOptIn    bool
+		threshold     float64
+		userQuota     int
+		quota         int
+		wantRemaining string
+		wantWarning   bool
+	}{
+		{name: "above the threshold", group: "vip", threshold: 1000, userQuota: 5000, quota: 3000, wantRemaining: "2000"},
+		{name: "final charge drops below the threshold", group: "vip", threshold: 1000, userQuota: 5000, quota: 4500,
+			wantRemaining: "500", wantWarning: true},
+		{name: "exactly at the threshold", group: "vip", threshold: 1000, userQuota: 2000, quota: 1000, wantRemaining: "1000"},
+		{name: "group not enabled", group: "default", threshold: 1000, userQuota: 5000, quota: 4500},
+		{name: "token opted in", group: "default", tokenOptIn: true, threshold: 1000, userQuota: 5000, quota: 4500,
+			wantRemaining: "500", wantWarning: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			c.Set("token_quota_header", tt.tokenOptIn)
+			info := &relaycommon.RelayInfo{UsingGroup: tt.group}
+			info.UserSetting.QuotaWarningThreshold = tt.threshold
+
+			HoldResponseForBilledHeaders(c, info)
+			c.JSON(http.StatusOK, gin.H{"id": "1"})
+			SetBilledHeaders(c, info, tt.userQuota, tt.quota)
+
+			if got := recorder.Header().Get(QuotaRemainingHeader); got != tt.wantRemaining {
+				t.Errorf("%s = %q, want %q", QuotaRemainingHeader, got, tt.wantRemaining)
+			}
+			if got := recorder.Header().Get(QuotaWarningHeader); (got == "low_quota") != tt.wantWarning {
+				t.Errorf("%s = %q, want warning %v", QuotaWarningHeader, got, tt.wantWarning)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/setting/operation_setting"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/go-redis/redis/v8"
+)
+
+var ErrRealtimeConnectionLimit = errors.New("当前分组的 Realtime 连接数已达上限，请关闭其他连接后重试")
+
+// realtimeConnectionLease 是 Redis 中连接记录的有效期，连接打开期间定期续期，
+// 节点异常退出时未释放的记录会在到期后自动清除
+const realtimeConnectionLease = 60 * time.Second
+
+var (
+	realtimeConnections     = make(map[string]int)
+	realtimeConnectionsLock sync.Mutex
+)
+
+// AcquireRealtimeConnection counts an open realtime connection of the group.
+// With Redis the count is shared by all nodes, otherwise it is kept on this
+// node. It fails when the group already has its configured maximum open;
+// otherwise the returned release function must be called once the
+// connection is closed. Redis errors fail open.
+func AcquireRealtimeConnection(group string) (func(), error) {
+	limit := operation_setting.GetRealtimeMaxConnections(group)
+	if limit <= 0 {
+		return func() {}, nil
+	}
+	if common.RedisEnabled {
+		release, err := acquireRedisRealtimeConnection(group, limit)
+		if err == nil || errors.Is(err, ErrRealtimeConnectionLimit) {
+			return release, err
+		}
+		common.SysError(fmt.Sprintf("failed to count realtime connection of group %s: %s", group, err.Error()))
+		return func() {}, nil
+	}
+	return acquireMemoryRealtimeConnection(group, limit)
+}
+
+func acquireMemoryRealtimeConnection(group string, limit int) (func(), error) {
+	realtimeConnectionsLock.Lock()
+	defer realtimeConnectionsLock.Unlock()
+	if realtimeConnections[group] >= limit {
+		return nil, ErrRealtimeConnectionLimit
+	}
+	realtimeConnections[group]++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			realtimeConnectionsLock.Lock()
+			defer realtimeConnectionsLock.Unlock()
+			realtimeConnections[group]--
+			if realtimeConnections[group] <= 0 {
+				delete(realtimeConnections, group)
+			}
+		})
+	}, nil
+}
+
+// 清理过期连接后在未超限时登记新连接，返回是否登记成功
+var acquireRealtimeConnectionScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local lease = tonumber(ARGV[2])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now)
+if redis.call("ZCARD", KEYS[1]) >= tonumber(ARGV[3]) then
+	return 0
+end
+redis.call("ZADD", KEYS[1], now + lease, ARGV[4])
+redis.call("PEXPIRE", KEYS[1], lease)
+return 1
+`)
+
+// acquireRedisRealtimeConnection records the connection in a sorted set of
+// the group scored by its lease expiry. The lease is renewed while the
+// connection is open and the record is removed on release.
+func acquireRedisRealtimeConnection(group string, limit int) (func(), error) {
+	key := fmt.Sprintf("realtime_connections:%s", group)
+	member := common.GetUUID()
+	admitted, err := acquireRealtimeConnectionScript.Run(context.Background(), common.RDB, []string{key},
+		time.Now().UnixMilli(), realtimeConnectionLease.Milliseconds(), limit, member).Int()
+	if err != nil {
+		return nil, err
+	}
+	if admitted != 1 {
+		return nil, ErrRealtimeConnectionLimit
+	}
+	done := make(chan struct{})
+	gopool.Go(func() {
+		ticker := time.NewTicker(realtimeConnectionLease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				ctx := context.Background()
+				expireAt := float64(time.Now().Add(realtimeConnectionLease).UnixMilli())
+				if err := common.RDB.ZAddXX(ctx, key, &redis.Z{Score: expireAt, Member: member}).Err(); err != nil {
+					common.SysError(fmt.Sprintf("failed to renew realtime connection of group %s: %s", group, err.Error()))
+				}
+				common.RDB.PExpire(ctx, key, realtimeConnectionLease)
+			}
+		}
+	})
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			if err := common.RDB.ZRem(context.Background(), key, member).Err(); err != nil {
+				common.SysError(fmt.Sprintf("failed to release realtime connection of group %s: %s", group, err.Error()))
+			}
+		})
+	}, nil
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"errors"
+	"one-api/common"
+	"one-api/setting/operation_setting"
+	"testing"
+)
+
+func TestAcquireRealtimeConnectionLimit(t *testing.T) {
+	redisEnabled := common.RedisEnabled
+	common.RedisEnabled = false
+	setting := operation_setting.GetRealtimeConnectionSetting()
+	maxConnections := setting.GroupMaxConnections
+	setting.GroupMaxConnections = map[string]int{"limited": 2}
+	t.Cleanup(func() {
+		common.RedisEnabled = redisEnabled
+		setting.GroupMaxConnections = maxConnections
+	})
+
+	var releases []func()
+	for i := 0; i < 2; i++ {
+		release, err := AcquireRealtimeConnection("limited")
+		if err != nil {
+			t.Fatalf("connection %d: unexpected error %v", i, err)
+		}
+		releases = append(releases, release)
+	}
+	if _, err := AcquireRealtimeConnection("limited"); !errors.Is(err, ErrRealtimeConnectionLimit) {
+		t.Fatalf("connection over the limit: got %v, want ErrRealtimeConnectionLimit", err)
+	}
+
+	// 释放函数重复调用只释放一次
+	releases[0]()
+	releases[0]()
+	release, err := AcquireRealtimeConnection("limited")
+	if err != nil {
+		t.Fatalf("connection after release: unexpected error %v", err)
+	}
+	if _, err := AcquireRealtimeConnection("limited"); !errors.Is(err, ErrRealtimeConnectionLimit) {
+		t.Fatalf("connection over the limit after release: got %v, want ErrRealtimeConnectionLimit", err)
+	}
+	release()
+	releases[1]()
+
+	for i := 0; i < 5; i++ {
+		if _, err := AcquireRealtimeConnection("unlimited"); err != nil {
+			t.Fatalf("unlimited group: unexpected error %v", err)
+		}
+	}
+}
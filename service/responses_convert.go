@@ -0,0 +1,542 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"strings"
+)
+
+// responsesInputItem is one item of the input array of a responses request.
+type responsesInputItem struct {
+	Type    string          `json:"type,omitempty"`
+	Role    string          `json:"role,omitempty"`
+	Content json.RawMessage `json:"content,omitempty"`
+	// function_call / function_call_output
+	CallId    string          `json:"call_id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Arguments string          `json:"arguments,omitempty"`
+	Output    json.RawMessage `json:"output,omitempty"`
+}
+
+type responsesInputContent struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageUrl string `json:"image_url,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+type responsesTextFormat struct {
+	Type        string `json:"type"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Schema      any    `json:"schema,omitempty"`
+	Strict      any    `json:"strict,omitempty"`
+}
+
+type responsesText struct {
+	Format *responsesTextFormat `json:"format,omitempty"`
+}
+
+// ChatCompletionsToResponsesRequest converts a chat completions request to a
+// responses request. Parameters the responses API has no equivalent for are
+// rejected instead of being dropped silently.
+func ChatCompletionsToResponsesRequest(request *dto.GeneralOpenAIRequest) (*dto.OpenAIResponsesRequest, error) {
+	if request.N > 1 {
+		return nil, errors.New("n greater than 1 is not supported by the responses API")
+	}
+	if len(request.LogProbs) > 0 && string(request.LogProbs) != "false" || request.TopLogProbs > 0 {
+		return nil, errors.New("logprobs is not supported by the responses API")
+	}
+	if request.Stop != nil {
+		return nil, errors.New("stop is not supported by the responses API")
+	}
+	if request.FrequencyPenalty != 0 || request.PresencePenalty != 0 {
+		return nil, errors.New("frequency_penalty and presence_penalty are not supported by the responses API")
+	}
+	if request.Seed != 0 {
+		return nil, errors.New("seed is not supported by the responses API")
+	}
+	if len(request.Functions) > 0 || len(request.FunctionCall) > 0 {
+		return nil, errors.New("functions and function_call are not supported by the responses API, use tools instead")
+	}
+	if len(request.Modalities) > 0 || len(request.Audio) > 0 {
+		return nil, errors.New("audio output is not supported by the responses API")
+	}
+	if request.Prediction != nil {
+		return nil, errors.New("prediction is not supported by the responses API")
+	}
+	if request.WebSearchOptions != nil {
+		return nil, errors.New("web_search_options is not supported by the responses API, use a web_search_preview tool instead")
+	}
+
+	responsesRequest := &dto.OpenAIResponsesRequest{
+		Model:           request.Model,
+		MaxOutputTokens: request.MaxCompletionTokens,
+		Stream:          request.Stream,
+		TopP:            request.TopP,
+		User:            request.User,
+	}
+	if responsesRequest.MaxOutputTokens == 0 {
+		responsesRequest.MaxOutputTokens = request.MaxTokens
+	}
+	if request.Temperature != nil {
+		responsesRequest.Temperature = *request.Temperature
+	}
+	if request.ParallelTooCalls != nil {
+		responsesRequest.ParallelToolCalls = *request.ParallelTooCalls
+	}
+	if request.ReasoningEffort != "" {
+		responsesRequest.Reasoning = &dto.Reasoning{Effort: request.ReasoningEffort}
+	}
+
+	input := make([]responsesInputItem, 0, len(request.Messages))
+	for i := range request.Messages {
+		items, err := chatMessageToResponsesInput(&request.Messages[i])
+		if err != nil {
+			return nil, err
+		}
+		input = append(input, items...)
+	}
+	inputJson, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	responsesRequest.Input = inputJson
+
+	for _, tool := range request.Tools {
+		if tool.Type != "function" {
+			return nil, fmt.Errorf("tool type %s is not supported by the responses API", tool.Type)
+		}
+		var parameters json.RawMessage
+		if tool.Function.Parameters != nil {
+			if parameters, err = json.Marshal(tool.Function.Parameters); err != nil {
+				return nil, err
+			}
+		}
+		responsesRequest.Tools = append(responsesRequest.Tools, dto.ResponsesToolsCall{
+			Type:        "function",
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			Parameters:  parameters,
+		})
+	}
+
+	if request.ToolChoice != nil {
+		toolChoice := request.ToolChoice
+		if choice, ok := request.ToolChoice.(map[string]any); ok {
+			function, _ := choice["function"].(map[string]any)
+			if choice["type"] != "function" || function == nil {
+				return nil, errors.New("tool_choice is not supported by the responses API")
+			}
+			toolChoice = map[string]any{"type": "function", "name": function["name"]}
+		}
+		if responsesRequest.ToolChoice, err = json.Marshal(toolChoice); err != nil {
+			return nil, err
+		}
+	}
+
+	if request.ResponseFormat != nil && request.ResponseFormat.Type != "" && request.ResponseFormat.Type != "text" {
+		format := &responsesTextFormat{Type: request.ResponseFormat.Type}
+		if schema := request.ResponseFormat.JsonSchema; schema != nil {
+			format.Name = schema.Name
+			format.Description = schema.Description
+			format.Schema = schema.Schema
+			format.Strict = schema.Strict
+		}
+		if responsesRequest.Text, err = json.Marshal(responsesText{Format: format}); err != nil {
+			return nil, err
+		}
+	}
+	return responsesRequest, nil
+}
+
+func chatMessageToResponsesInput(message *dto.Message) ([]responsesInputItem, error) {
+	switch message.Role {
+	case "tool":
+		output, err := json.Marshal(message.StringContent())
+		if err != nil {
+			return nil, err
+		}
+		return []responsesInputItem{{Type: "function_call_output", CallId: message.ToolCallId, Output: output}}, nil
+	case "system", "developer", "user", "assistant":
+	default:
+		return nil, fmt.Errorf("message role %s is not supported by the responses API", message.Role)
+	}
+
+	textType := "input_text"
+	if message.Role == "assistant" {
+		textType = "output_text"
+	}
+	var items []responsesInputItem
+	var contents []responsesInputContent
+	for _, part := range message.ParseContent() {
+		switch part.Type {
+		case dto.ContentTypeText:
+			if part.Text != "" {
+				contents = append(contents, responsesInputContent{Type: textType, Text: part.Text})
+			}
+		case dto.ContentTypeImageURL:
+			image := part.GetImageMedia()
+			if image == nil || message.Role == "assistant" {
+				return nil, errors.New("invalid image_url content")
+			}
+			contents = append(contents, responsesInputContent{Type: "input_image", ImageUrl: image.Url, Detail: image.Detail})
+		default:
+			return nil, fmt.Errorf("content type %s is not supported by the responses API", part.Type)
+		}
+	}
+	if len(contents) > 0 {
+		content, err := json.Marshal(contents)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, responsesInputItem{Type: dto.ResponsesOutputItemMessage, Role: message.Role, Content: content})
+	}
+	for _, toolCall := range message.ParseToolCalls() {
+		items = append(items, responsesInputItem{
+			Type:      dto.ResponsesOutputItemFunctionCall,
+			CallId:    toolCall.ID,
+			Name:      toolCall.Function.Name,
+			Arguments: toolCall.Function.Arguments,
+		})
+	}
+	return items, nil
+}
+
+// ResponsesToChatCompletionsRequest converts a responses request to a chat
+// completions request. Stateful and built-in tool features of the responses
+// API are rejected.
+func ResponsesToChatCompletionsRequest(request *dto.OpenAIResponsesRequest) (*dto.GeneralOpenAIRequest, error) {
+	if request.PreviousResponseID != "" {
+		return nil, errors.New("previous_response_id is not supported by the chat completions API")
+	}
+	if len(request.Include) > 0 && string(request.Include) != "null" && string(request.Include) != "[]" {
+		return nil, errors.New("include is not supported by the chat completions API")
+	}
+	if request.Truncation != "" && request.Truncation != "disabled" {
+		return nil, errors.New("truncation is not supported by the chat completions API")
+	}
+
+	chatRequest := &dto.GeneralOpenAIRequest{
+		Model:               request.Model,
+		MaxCompletionTokens: request.MaxOutputTokens,
+		Stream:              request.Stream,
+		TopP:                request.TopP,
+		User:                request.User,
+	}
+	if request.Stream {
+		// 需要上游返回用量以正确计费
+		chatRequest.StreamOptions = &dto.StreamOptions{IncludeUsage: true}
+	}
+	if request.Temperature != 0 {
+		temperature := request.Temperature
+		chatRequest.Temperature = &temperature
+	}
+	if request.ParallelToolCalls {
+		parallelToolCalls := true
+		chatRequest.ParallelTooCalls = &parallelToolCalls
+	}
+	if request.Reasoning != nil {
+		chatRequest.ReasoningEffort = request.Reasoning.Effort
+	}
+
+	if len(request.Instructions) > 0 && string(request.Instructions) != "null" {
+		var instructions string
+		if err := common.UnmarshalJson(request.Instructions, &instructions); err != nil {
+			return nil, errors.New("instructions must be a string")
+		}
+		if instructions != "" {
+			message := dto.Message{Role: "system"}
+			message.SetStringContent(instructions)
+			chatRequest.Messages = append(chatRequest.Messages, message)
+		}
+	}
+	messages, err := responsesInputToChatMessages(request.Input)
+	if err != nil {
+		return nil, err
+	}
+	chatRequest.Messages = append(chatRequest.Messages, messages...)
+
+	for _, tool := range request.Tools {
+		if tool.Type != "function" {
+			return nil, fmt.Errorf("tool type %s is not supported by the chat completions API", tool.Type)
+		}
+		chatRequest.Tools = append(chatRequest.Tools, dto.ToolCallRequest{
+			Type: "function",
+			Function: dto.FunctionRequest{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+
+	if len(request.ToolChoice) > 0 {
+		var toolChoice any
+		if err := common.UnmarshalJson(request.ToolChoice, &toolChoice); err != nil {
+			return nil, err
+		}
+		if choice, ok := toolChoice.(map[string]any); ok {
+			if choice["type"] != "function" {
+				return nil, fmt.Errorf("tool_choice type %v is not supported by the chat completions API", choice["type"])
+			}
+			toolChoice = map[string]any{"type": "function", "function": map[string]any{"name": choice["name"]}}
+		}
+		chatRequest.ToolChoice = toolChoice
+	}
+
+	if len(request.Text) > 0 {
+		var text responsesText
+		if err := common.UnmarshalJson(request.Text, &text); err != nil {
+			return nil, err
+		}
+		if format := text.Format; format != nil && format.Type != "" && format.Type != "text" {
+			chatRequest.ResponseFormat = &dto.ResponseFormat{Type: format.Type}
+			if format.Type == "json_schema" {
+				chatRequest.ResponseFormat.JsonSchema = &dto.FormatJsonSchema{
+					Name:        format.Name,
+					Description: format.Description,
+					Schema:      format.Schema,
+					Strict:      format.Strict,
+				}
+			}
+		}
+	}
+	return chatRequest, nil
+}
+
+func responsesInputToChatMessages(input json.RawMessage) ([]dto.Message, error) {
+	var text string
+	if err := common.UnmarshalJson(input, &text); err == nil {
+		message := dto.Message{Role: "user"}
+		message.SetStringContent(text)
+		return []dto.Message{message}, nil
+	}
+	var items []responsesInputItem
+	if err := common.UnmarshalJson(input, &items); err != nil {
+		return nil, errors.New("input must be a string or an array of input items")
+	}
+
+	var messages []dto.Message
+	var pendingToolCalls []dto.ToolCallRequest
+	flushToolCalls := func() {
+		if len(pendingToolCalls) == 0 {
+			return
+		}
+		message := dto.Message{Role: "assistant"}
+		message.SetToolCalls(pendingToolCalls)
+		messages = append(messages, message)
+		pendingToolCalls = nil
+	}
+	for _, item := range items {
+		switch item.Type {
+		case dto.ResponsesOutputItemFunctionCall:
+			pendingToolCalls = append(pendingToolCalls, dto.ToolCallRequest{
+				ID:   item.CallId,
+				Type: "function",
+				Function: dto.FunctionRequest{
+					Name:      item.Name,
+					Arguments: item.Arguments,
+				},
+			})
+			continue
+		case "function_call_output":
+			flushToolCalls()
+			var output string
+			if err := common.UnmarshalJson(item.Output, &output); err != nil {
+				output = string(item.Output)
+			}
+			message := dto.Message{Role: "tool", ToolCallId: item.CallId}
+			message.SetStringContent(output)
+			messages = append(messages, message)
+		case dto.ResponsesOutputItemReasoning:
+			// 推理内容无法回传给 chat completions 接口
+			continue
+		case "", dto.ResponsesOutputItemMessage:
+			flushToolCalls()
+			message, err := responsesInputMessageToChat(item)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, message)
+		default:
+			return nil, fmt.Errorf("input item type %s is not supported by the chat completions API", item.Type)
+		}
+	}
+	flushToolCalls()
+	return messages, nil
+}
+
+func responsesInputMessageToChat(item responsesInputItem) (dto.Message, error) {
+	message := dto.Message{Role: item.Role}
+	if message.Role == "" {
+		message.Role = "user"
+	}
+	var text string
+	if err := common.UnmarshalJson(item.Content, &text); err == nil {
+		message.Content = text
+		return message, nil
+	}
+	var contents []responsesInputContent
+	if err := common.UnmarshalJson(item.Content, &contents); err != nil {
+		return message, errors.New("message content must be a string or an array of content parts")
+	}
+	parts := make([]any, 0, len(contents))
+	for _, content := range contents {
+		switch content.Type {
+		case "input_text", "output_text":
+			parts = append(parts, map[string]any{"type": dto.ContentTypeText, "text": content.Text})
+		case "input_image":
+			imageUrl := map[string]any{"url": content.ImageUrl}
+			if content.Detail != "" {
+				imageUrl["detail"] = content.Detail
+			}
+			parts = append(parts, map[string]any{"type": dto.ContentTypeImageURL, "image_url": imageUrl})
+		default:
+			return message, fmt.Errorf("content type %s is not supported by the chat completions API", content.Type)
+		}
+	}
+	message.Content = parts
+	return message, nil
+}
+
+// ResponseResponses2OpenAI converts a responses API response to a chat
+// completion response.
+func ResponseResponses2OpenAI(response *dto.OpenAIResponsesResponse) *dto.OpenAITextResponse {
+	message := dto.Message{Role: "assistant"}
+	var content, reasoning strings.Builder
+	var toolCalls []dto.ToolCallResponse
+	for _, output := range response.Output {
+		switch output.Type {
+		case dto.ResponsesOutputItemMessage:
+			for _, part := range output.Content {
+				if part.Type == "output_text" {
+					content.WriteString(part.Text)
+				}
+			}
+		case dto.ResponsesOutputItemReasoning:
+			for _, part := range output.Summary {
+				reasoning.WriteString(part.Text)
+			}
+		case dto.ResponsesOutputItemFunctionCall:
+			toolCalls = append(toolCalls, dto.ToolCallResponse{
+				ID:   output.CallId,
+				Type: "function",
+				Function: dto.FunctionResponse{
+					Name:      output.Name,
+					Arguments: output.Arguments,
+				},
+			})
+		}
+	}
+	message.SetStringContent(content.String())
+	message.ReasoningContent = reasoning.String()
+	finishReason := constant.FinishReasonStop
+	if len(toolCalls) > 0 {
+		message.SetToolCalls(toolCalls)
+		finishReason = constant.FinishReasonToolCalls
+	}
+	if response.Status == "incomplete" {
+		finishReason = constant.FinishReasonLength
+	}
+
+	chatResponse := &dto.OpenAITextResponse{
+		Id:      response.ID,
+		Model:   response.Model,
+		Object:  "chat.completion",
+		Created: response.CreatedAt,
+		Choices: []dto.OpenAITextResponseChoice{{Index: 0, Message: message, FinishReason: finishReason}},
+	}
+	if response.Usage != nil {
+		chatResponse.Usage = ResponsesUsage2OpenAI(response.Usage)
+	}
+	return chatResponse
+}
+
+// ResponsesUsage2OpenAI maps the input/output token counts of the responses
+// API to prompt/completion tokens.
+func ResponsesUsage2OpenAI(usage *dto.Usage) dto.Usage {
+	openAIUsage := dto.Usage{
+		PromptTokens:     usage.InputTokens,
+		CompletionTokens: usage.OutputTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
+	if usage.InputTokensDetails != nil {
+		openAIUsage.PromptTokensDetails = *usage.InputTokensDetails
+	}
+	if usage.OutputTokensDetails != nil {
+		openAIUsage.CompletionTokenDetails = *usage.OutputTokensDetails
+	}
+	if openAIUsage.TotalTokens == 0 {
+		openAIUsage.TotalTokens = openAIUsage.PromptTokens + openAIUsage.CompletionTokens
+	}
+	return openAIUsage
+}
+
+// ResponseOpenAI2Responses converts a chat completion response to a
+// responses API response. Only the first choice is converted.
+func ResponseOpenAI2Responses(response *dto.OpenAITextResponse) *dto.OpenAIResponsesResponse {
+	responsesResponse := &dto.OpenAIResponsesResponse{
+		ID:        "resp_" + response.Id,
+		Object:    "response",
+		CreatedAt: int(common.GetTimestamp()),
+		Status:    "completed",
+		Model:     response.Model,
+		Output:    make([]dto.ResponsesOutput, 0),
+		Usage:     OpenAIUsage2Responses(&response.Usage),
+	}
+	if created, ok := response.Created.(float64); ok && created > 0 {
+		responsesResponse.CreatedAt = int(created)
+	}
+	if len(response.Choices) == 0 {
+		return responsesResponse
+	}
+	choice := response.Choices[0]
+	if choice.FinishReason == constant.FinishReasonLength {
+		responsesResponse.Status = "incomplete"
+		responsesResponse.IncompleteDetails = &dto.IncompleteDetails{Reasoning: "max_output_tokens"}
+	}
+	if reasoning := choice.Message.ReasoningContent + choice.Message.Reasoning; reasoning != "" {
+		responsesResponse.Output = append(responsesResponse.Output, dto.ResponsesOutput{
+			Type:    dto.ResponsesOutputItemReasoning,
+			ID:      "rs_" + response.Id,
+			Status:  "completed",
+			Summary: []dto.ResponsesOutputContent{{Type: "summary_text", Text: reasoning}},
+		})
+	}
+	if content := choice.Message.StringContent(); content != "" {
+		responsesResponse.Output = append(responsesResponse.Output, dto.ResponsesOutput{
+			Type:    dto.ResponsesOutputItemMessage,
+			ID:      "msg_" + response.Id,
+			Status:  "completed",
+			Role:    "assistant",
+			Content: []dto.ResponsesOutputContent{{Type: "output_text", Text: content, Annotations: []interface{}{}}},
+		})
+	}
+	for _, toolCall := range choice.Message.ParseToolCalls() {
+		responsesResponse.Output = append(responsesResponse.Output, dto.ResponsesOutput{
+			Type:      dto.ResponsesOutputItemFunctionCall,
+			ID:        "fc_" + toolCall.ID,
+			Status:    "completed",
+			CallId:    toolCall.ID,
+			Name:      toolCall.Function.Name,
+			Arguments: toolCall.Function.Arguments,
+		})
+	}
+	return responsesResponse
+}
+
+// OpenAIUsage2Responses fills the input/output token counts used by the
+// responses API from chat completion usage.
+func OpenAIUsage2Responses(usage *dto.Usage) *dto.Usage {
+	responsesUsage := *usage
+	responsesUsage.InputTokens = usage.PromptTokens
+	responsesUsage.OutputTokens = usage.CompletionTokens
+	responsesUsage.InputTokensDetails = &usage.PromptTokensDetails
+	responsesUsage.OutputTokensDetails = &usage.CompletionTokenDetails
+	return &responsesUsage
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"encoding/json"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"reflect"
+	"testing"
+)
+
+// roundTripMessage is the part of a chat message both APIs can express.
+type roundTripMessage struct {
+	Role       string
+	Content    []dto.MediaContent
+	ToolCalls  []dto.ToolCallRequest
+	ToolCallId string
+}
+
+func normalizeRoundTripMessages(t *testing.T, messages []dto.Message) []roundTripMessage {
+	t.Helper()
+	normalized := make([]roundTripMessage, 0, len(messages))
+	for i := range messages {
+		message := roundTripMessage{
+			Role:       messages[i].Role,
+			ToolCalls:  messages[i].ParseToolCalls(),
+			ToolCallId: messages[i].ToolCallId,
+		}
+		for _, part := range messages[i].ParseContent() {
+			if part.Type == dto.ContentTypeText && part.Text == "" {
+				continue
+			}
+			if image := part.GetImageMedia(); image != nil {
+				part.ImageUrl = *image
+			}
+			message.Content = append(message.Content, part)
+		}
+		normalized = append(normalized, message)
+	}
+	return normalized
+}
+
+func mustMarshalJson(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal %T: %v", v, err)
+	}
+	return string(data)
+}
+
+func TestChatResponsesRequestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		request string
+	}{
+		{
+			name: "messages",
+			request: `{"model":"gpt-4o","max_completion_tokens":256,"temperature":0.5,"messages":[
+				{"role":"system","content":"be brief"},
+				{"role":"user","content":[{"type":"text","text":"what is this"},{"type":"image_url","image_url":{"url":"https://example.com/cat.png","detail":"low"}}]},
+				{"role":"assistant","content":"a cat"},
+				{"role":"user","content":"thanks"}]}`,
+		},
+		{
+			name: "tools",
+			request: `{"model":"gpt-4o","messages":[{"role":"user","content":"weather in Paris"}],
+				"tools":[{"type":"function","function":{"name":"get_weather","description":"current weather","parameters":{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}}}],
+				"tool_choice":{"type":"function","function":{"name":"get_weather"}}}`,
+		},
+		{
+			name: "tool calls",
+			request: `{"model":"gpt-4o","stream":true,"messages":[
+				{"role":"user","content":"weather in Paris and Rome"},
+				{"role":"assistant","content":null,"tool_calls":[
+					{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},
+					{"id":"call_2","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Rome\"}"}}]},
+				{"role":"tool","tool_call_id":"call_1","content":"sunny"},
+				{"role":"tool","tool_call_id":"call_2","content":"rain"},
+				{"role":"assistant","content":"Paris is sunny, Rome is rainy."}],
+				"tools":[{"type":"function","function":{"name":"get_weather","parameters":{"type":"object"}}}],
+				"tool_choice":"auto"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var chatRequest dto.GeneralOpenAIRequest
+			if err := common.UnmarshalJson([]byte(tt.request), &chatRequest); err != nil {
+				t.Fatalf("decode chat request: %v", err)
+			}
+			responsesRequest, err := ChatCompletionsToResponsesRequest(&chatRequest)
+			if err != nil {
+				t.Fatalf("ChatCompletionsToResponsesRequest() error = %v", err)
+			}
+			// 经过一次 JSON 编解码，与实际转发给上游的请求一致
+			var decoded dto.OpenAIResponsesRequest
+			if err := common.UnmarshalJson([]byte(mustMarshalJson(t, responsesRequest)), &decoded); err != nil {
+				t.Fatalf("decode responses request: %v", err)
+			}
+			got, err := ResponsesToChatCompletionsRequest(&decoded)
+			if err != nil {
+				t.Fatalf("ResponsesToChatCompletionsRequest() error = %v", err)
+			}
+
+			if got.Model != chatRequest.Model || got.Stream != chatRequest.Stream || got.MaxCompletionTokens != chatRequest.MaxCompletionTokens {
+				t.Errorf("model/stream/max tokens = %s/%v/%d, want %s/%v/%d", got.Model, got.Stream, got.MaxCompletionTokens,
+					chatRequest.Model, chatRequest.Stream, chatRequest.MaxCompletionTokens)
+			}
+			if !reflect.DeepEqual(got.Temperature, chatRequest.Temperature) {
+				t.Errorf("temperature = %v, want %v", got.Temperature, chatRequest.Temperature)
+			}
+			gotMessages, wantMessages := normalizeRoundTripMessages(t, got.Messages), normalizeRoundTripMessages(t, chatRequest.Messages)
+			if g, w := mustMarshalJson(t, gotMessages), mustMarshalJson(t, wantMessages); g != w {
+				t.Errorf("messages after round trip:\n got %s\nwant %s", g, w)
+			}
+			if g, w := mustMarshalJson(t, got.Tools), mustMarshalJson(t, chatRequest.Tools); g != w {
+				t.Errorf("tools after round trip:\n got %s\nwant %s", g, w)
+			}
+			if g, w := mustMarshalJson(t, got.ToolChoice), mustMarshalJson(t, chatRequest.ToolChoice); g != w {
+				t.Errorf("tool_choice after round trip = %s, want %s", g, w)
+			}
+		})
+	}
+}
+
+func TestChatResponsesResponseRoundTrip(t *testing.T) {
+	tests := []struct {
+		name         string
+		message      string
+		finishReason string
+		usage        dto.Usage
+	}{
+		{
+			name:         "text",
+			message:      `{"role":"assistant","content":"hello there"}`,
+			finishReason: constant.FinishReasonStop,
+			usage: dto.Usage{PromptTokens: 12, CompletionTokens: 3, TotalTokens: 15,
+				PromptTokensDetails: dto.InputTokenDetails{CachedTokens: 8}},
+		},
+		{
+			name: "tool calls",
+			message: `{"role":"assistant","content":"","tool_calls":[
+				{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]}`,
+			finishReason: constant.FinishReasonToolCalls,
+			usage:        dto.Usage{PromptTokens: 40, CompletionTokens: 9, TotalTokens: 49},
+		},
+		{
+			name:         "truncated with reasoning",
+			message:      `{"role":"assistant","content":"partial","reasoning_content":"thinking"}`,
+			finishReason: constant.FinishReasonLength,
+			usage: dto.Usage{PromptTokens: 5, CompletionTokens: 100, TotalTokens: 105,
+				CompletionTokenDetails: dto.OutputTokenDetails{ReasoningTokens: 60}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var message dto.Message
+			if err := common.UnmarshalJson([]byte(tt.message), &message); err != nil {
+				t.Fatalf("decode message: %v", err)
+			}
+			chatResponse := &dto.OpenAITextResponse{
+				Id:      "chatcmpl-1",
+				Model:   "gpt-4o",
+				Object:  "chat.completion",
+				Choices: []dto.OpenAITextResponseChoice{{Index: 0, Message: message, FinishReason: tt.finishReason}},
+				Usage:   tt.usage,
+			}
+
+			got := ResponseResponses2OpenAI(ResponseOpenAI2Responses(chatResponse))
+
+			if len(got.Choices) != 1 {
+				t.Fatalf("choices = %d, want 1", len(got.Choices))
+			}
+			choice := got.Choices[0]
+			if choice.FinishReason != tt.finishReason {
+				t.Errorf("finish_reason = %s, want %s", choice.FinishReason, tt.finishReason)
+			}
+			if choice.Message.StringContent() != message.StringContent() {
+				t.Errorf("content = %q, want %q", choice.Message.StringContent(), message.StringContent())
+			}
+			if choice.Message.ReasoningContent != message.ReasoningContent {
+				t.Errorf("reasoning_content = %q, want %q", choice.Message.ReasoningContent, message.ReasoningContent)
+			}
+			if g, w := mustMarshalJson(t, choice.Message.ParseToolCalls()), mustMarshalJson(t, message.ParseToolCalls()); g != w {
+				t.Errorf("tool_calls after round trip:\n got %s\nwant %s", g, w)
+			}
+			if got.Usage.PromptTokens != tt.usage.PromptTokens || got.Usage.CompletionTokens != tt.usage.CompletionTokens ||
+				got.Usage.TotalTokens != tt.usage.TotalTokens {
+				t.Errorf("usage = %d/%d/%d, want %d/%d/%d", got.Usage.PromptTokens, got.Usage.CompletionTokens, got.Usage.TotalTokens,
+					tt.usage.PromptTokens, tt.usage.CompletionTokens, tt.usage.TotalTokens)
+			}
+			if got.Usage.PromptTokensDetails != tt.usage.PromptTokensDetails || got.Usage.CompletionTokenDetails != tt.usage.CompletionTokenDetails {
+				t.Errorf("usage details = %+v/%+v, want %+v/%+v", got.Usage.PromptTokensDetails, got.Usage.CompletionTokenDetails,
+					tt.usage.PromptTokensDetails, tt.usage.CompletionTokenDetails)
+			}
+		})
+	}
+}
+
+func TestResponsesUsageReasoningTokens(t *testing.T) {
+	var responsesUsage dto.Usage
+	if err := json.Unmarshal([]byte(`{"input_tokens":10,"output_tokens":50,"total_tokens":60,`+
+		`"output_tokens_details":{"reasoning_tokens":32}}`), &responsesUsage); err != nil {
+		t.Fatalf("decode usage: %v", err)
+	}
+	usage := ResponsesUsage2OpenAI(&responsesUsage)
+	if usage.CompletionTokens != 50 || usage.CompletionTokenDetails.ReasoningTokens != 32 {
+		t.Fatalf("chat usage = %+v, want the reasoning tokens in completion_tokens_details", usage)
+	}
+	back := OpenAIUsage2Responses(&usage)
+	if back.OutputTokensDetails == nil || back.OutputTokensDetails.ReasoningTokens != 32 {
+		t.Errorf("responses usage = %+v, want the reasoning tokens in output_tokens_details", back)
+	}
+}
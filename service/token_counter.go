@@ -472,3 +472,40 @@ func CountTextToken(text string, model string) int {
 	tokenEncoder := getTokenEncoder(model)
 	return getTokenNum(tokenEncoder, text)
 }
+
+// EstimateTokenByChars is a tokenizer-free fallback. It counts one token per
+// two characters, which overestimates typical text so billing stays on the
+// safe side.
+func EstimateTokenByChars(text string) int {
+	return (utf8.RuneCountInString(text) + 1) / 2
+}
+
+// SplitTextByTokens splits text into windows of at most maxTokens tokens,
+// with overlap tokens shared by adjacent windows. The text is returned as a
+// single window when it fits.
+func SplitTextByTokens(text string, model string, maxTokens int, overlap int) []string {
+	if maxTokens <= 0 {
+		return []string{text}
+	}
+	tokenEncoder := getTokenEncoder(model)
+	ids, _, err := tokenEncoder.Encode(text)
+	if err != nil || len(ids) <= maxTokens {
+		return []string{text}
+	}
+	if overlap < 0 || overlap >= maxTokens {
+		overlap = 0
+	}
+	var windows []string
+	for start := 0; start < len(ids); start += maxTokens - overlap {
+		end := min(start+maxTokens, len(ids))
+		window, err := tokenEncoder.Decode(ids[start:end])
+		if err != nil {
+			return []string{text}
+		}
+		windows = append(windows, window)
+		if end == len(ids) {
+			break
+		}
+	}
+	return windows
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+var ErrTokenFingerprintExceeded = errors.New("该令牌短时间内被过多不同的 IP/客户端使用，已拒绝新的请求来源")
+
+// tokenFingerprints 未启用 Redis 时在内存中记录令牌的指纹及最近使用时间
+type tokenFingerprints struct {
+	seen      map[string]time.Time
+	flaggedAt time.Time
+}
+
+var (
+	tokenFingerprintStore       = make(map[int]*tokenFingerprints)
+	tokenFingerprintLock        sync.Mutex
+	tokenFingerprintCleanupOnce sync.Once
+)
+
+// CheckTokenFingerprint records the (IP, User-Agent) fingerprint of the
+// request for the token. When the token has been used by more distinct
+// fingerprints than allowed within the window, the event is logged once per
+// window, and with the block action requests from new fingerprints are
+// rejected while known ones keep working. Storage errors fail open.
+func CheckTokenFingerprint(c *gin.Context, userId int, tokenId int, tokenName string) error {
+	setting := operation_setting.GetTokenFingerprintSetting()
+	if !setting.Enabled || setting.MaxFingerprints <= 0 || setting.WindowSeconds <= 0 {
+		return nil
+	}
+	window := time.Duration(setting.WindowSeconds) * time.Second
+	block := setting.Action == operation_setting.TokenFingerprintActionBlock
+	fingerprint := tokenFingerprint(c.ClientIP(), c.Request.UserAgent())
+
+	var count int
+	var exceeded, admitted, report bool
+	var err error
+	if common.RedisEnabled {
+		count, exceeded, admitted, report, err = touchRedisTokenFingerprint(tokenId, fingerprint, window, setting.MaxFingerprints, block)
+	} else {
+		count, exceeded, admitted, report = touchMemoryTokenFingerprint(tokenId, fingerprint, window, setting.MaxFingerprints, block)
+	}
+	if err != nil {
+		common.LogWarn(c, fmt.Sprintf("failed to record token fingerprint: %s", err.Error()))
+		return nil
+	}
+	if exceeded && report {
+		content := fmt.Sprintf("令牌 %s（#%d）在 %d 秒内被 %d 个不同的 IP/User-Agent 使用，超过阈值 %d，可能已被共享或泄露",
+			tokenName, tokenId, setting.WindowSeconds, count, setting.MaxFingerprints)
+		common.LogWarn(c, content)
+		model.RecordLog(userId, model.LogTypeSystem, content)
+	}
+	if !admitted {
+		return ErrTokenFingerprintExceeded
+	}
+	return nil
+}
+
+func tokenFingerprint(ip string, userAgent string) string {
+	hash := sha256.Sum256([]byte(ip + "\n" + userAgent))
+	return hex.EncodeToString(hash[:16])
+}
+
+// touchMemoryTokenFingerprint adds the fingerprint to the token's window and
+// returns the number of distinct fingerprints, whether the limit is
+// exceeded, whether the fingerprint was admitted and whether the event
+// should be reported. A new fingerprint over the limit is not admitted when
+// blocking.
+func touchMemoryTokenFingerprint(tokenId int, fingerprint string, window time.Duration, limit int, block bool) (count int, exceeded bool, admitted bool, report bool) {
+	tokenFingerprintCleanupOnce.Do(startTokenFingerprintCleanup)
+	now := time.Now()
+	tokenFingerprintLock.Lock()
+	defer tokenFingerprintLock.Unlock()
+	entry, ok := tokenFingerprintStore[tokenId]
+	if !ok {
+		entry = &tokenFingerprints{seen: make(map[string]time.Time)}
+		tokenFingerprintStore[tokenId] = entry
+	}
+	for fp, lastSeen := range entry.seen {
+		if now.Sub(lastSeen) >= window {
+			delete(entry.seen, fp)
+		}
+	}
+	_, known := entry.seen[fingerprint]
+	count = len(entry.seen)
+	if !known {
+		count++
+	}
+	exceeded = count > limit
+	if !known && exceeded && block {
+		count--
+	} else {
+		entry.seen[fingerprint] = now
+		admitted = true
+	}
+	if exceeded && now.Sub(entry.flaggedAt) >= window {
+		entry.flaggedAt = now
+		report = true
+	}
+	return count, exceeded, admitted, report
+}
+
+func startTokenFingerprintCleanup() {
+	gopool.Go(func() {
+		for {
+			time.Sleep(10 * time.Minute)
+			window := time.Duration(operation_setting.GetTokenFingerprintSetting().WindowSeconds) * time.Second
+			now := time.Now()
+			tokenFingerprintLock.Lock()
+			for tokenId, entry := range tokenFingerprintStore {
+				for fp, lastSeen := range entry.seen {
+					if now.Sub(lastSeen) >= window {
+						delete(entry.seen, fp)
+					}
+				}
+				if len(entry.seen) == 0 && now.Sub(entry.flaggedAt) >= window {
+					delete(tokenFingerprintStore, tokenId)
+				}
+			}
+			tokenFingerprintLock.Unlock()
+		}
+	})
+}
+
+// 清理过期指纹后记录新指纹，返回 {指纹数, 是否超限, 是否放行, 是否需要上报}
+var touchTokenFingerprintScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", "(" .. (now - window))
+local known = redis.call("ZSCORE", KEYS[1], ARGV[5])
+local count = redis.call("ZCARD", KEYS[1])
+if not known then
+	count = count + 1
+end
+local exceeded = count > tonumber(ARGV[3])
+local admitted = 0
+if not known and exceeded and ARGV[4] == "1" then
+	count = count - 1
+else
+	redis.call("ZADD", KEYS[1], now, ARGV[5])
+	redis.call("PEXPIRE", KEYS[1], window)
+	admitted = 1
+end
+local report = 0
+if exceeded and redis.call("SET", KEYS[2], "1", "NX", "PX", window) then
+	report = 1
+end
+return {count, exceeded and 1 or 0, admitted, report}
+`)
+
+// touchRedisTokenFingerprint is the Redis counterpart of
+// touchMemoryTokenFingerprint, so that all nodes share the window. Each token
+// has a sorted set of fingerprints scored by the time they were last seen,
+// updated atomically by a Lua script.
+func touchRedisTokenFingerprint(tokenId int, fingerprint string, window time.Duration, limit int, block bool) (count int, exceeded bool, admitted bool, report bool, err error) {
+	keys := []string{fmt.Sprintf("token_fingerprint:%d", tokenId), fmt.Sprintf("token_fingerprint_flagged:%d", tokenId)}
+	blockArg := 0
+	if block {
+		blockArg = 1
+	}
+	result, err := touchTokenFingerprintScript.Run(context.Background(), common.RDB, keys,
+		time.Now().UnixMilli(), window.Milliseconds(), limit, blockArg, fingerprint).Int64Slice()
+	if err != nil {
+		return 0, false, false, false, err
+	}
+	if len(result) != 4 {
+		return 0, false, false, false, fmt.Errorf("unexpected token fingerprint script result: %v", result)
+	}
+	return int(result[0]), result[1] == 1, result[2] == 1, result[3] == 1, nil
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTouchMemoryTokenFingerprint(t *testing.T) {
+	const tokenId = 1001
+	t.Cleanup(func() {
+		tokenFingerprintLock.Lock()
+		delete(tokenFingerprintStore, tokenId)
+		tokenFingerprintLock.Unlock()
+	})
+	window := time.Hour
+	a, b, c := tokenFingerprint("10.0.0.1", "curl"), tokenFingerprint("10.0.0.2", "curl"), tokenFingerprint("10.0.0.1", "python")
+
+	tests := []struct {
+		name        string
+		fingerprint string
+		count       int
+		exceeded    bool
+		admitted    bool
+		report      bool
+	}{
+		{name: "first fingerprint", fingerprint: a, count: 1, admitted: true},
+		{name: "second fingerprint", fingerprint: b, count: 2, admitted: true},
+		{name: "known fingerprint", fingerprint: a, count: 2, admitted: true},
+		{name: "new fingerprint over the limit is blocked", fingerprint: c, count: 2, exceeded: true, report: true},
+		{name: "blocked fingerprint stays blocked and is not reported again", fingerprint: c, count: 2, exceeded: true},
+		{name: "known fingerprint still works", fingerprint: b, count: 2, admitted: true},
+	}
+	for _, tt := range tests {
+		count, exceeded, admitted, report := touchMemoryTokenFingerprint(tokenId, tt.fingerprint, window, 2, true)
+		if count != tt.count || exceeded != tt.exceeded || admitted != tt.admitted || report != tt.report {
+			t.Fatalf("%s: got count=%d exceeded=%v admitted=%v report=%v, want %d %v %v %v", tt.name,
+				count, exceeded, admitted, report, tt.count, tt.exceeded, tt.admitted, tt.report)
+		}
+	}
+
+	// 窗口外的指纹不再计数
+	tokenFingerprintLock.Lock()
+	tokenFingerprintStore[tokenId].seen[a] = time.Now().Add(-2 * window)
+	tokenFingerprintLock.Unlock()
+	if count, exceeded, admitted, _ := touchMemoryTokenFingerprint(tokenId, c, window, 2, true); count != 2 || exceeded || !admitted {
+		t.Errorf("after expiry: count=%d exceeded=%v admitted=%v, want 2 false true", count, exceeded, admitted)
+	}
+}
+
+func TestTouchMemoryTokenFingerprintFlagOnly(t *testing.T) {
+	const tokenId = 1002
+	t.Cleanup(func() {
+		tokenFingerprintLock.Lock()
+		delete(tokenFingerprintStore, tokenId)
+		tokenFingerprintLock.Unlock()
+	})
+	touchMemoryTokenFingerprint(tokenId, tokenFingerprint("10.0.0.1", "curl"), time.Hour, 1, false)
+
+	count, exceeded, admitted, report := touchMemoryTokenFingerprint(tokenId, tokenFingerprint("10.0.0.2", "curl"), time.Hour, 1, false)
+	if count != 2 || !exceeded || !admitted || !report {
+		t.Fatalf("got count=%d exceeded=%v admitted=%v report=%v, want 2 true true true", count, exceeded, admitted, report)
+	}
+	if _, _, admitted, report := touchMemoryTokenFingerprint(tokenId, tokenFingerprint("10.0.0.3", "curl"), time.Hour, 1, false); !admitted || report {
+		t.Errorf("third fingerprint admitted=%v report=%v, want true false", admitted, report)
+	}
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/setting"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const defaultQuotaAlertIntervalMinutes = 1440
+
+// userQuotaAlertExpiry 未启用 Redis 时记录 用户:阈值 的通知间隔结束时间，过期记录定期清理
+var (
+	userQuotaAlertExpiry      sync.Map
+	userQuotaAlertCleanupOnce sync.Once
+)
+
+// crossedQuotaAlertThreshold returns the lowest threshold the balance crossed
+// downward, from at or above it to below it.
+func crossedQuotaAlertThreshold(thresholds []int, before int, after int) (int, bool) {
+	crossed, found := 0, false
+	for _, threshold := range thresholds {
+		if threshold <= 0 || before < threshold || after >= threshold {
+			continue
+		}
+		if !found || threshold < crossed {
+			crossed, found = threshold, true
+		}
+	}
+	return crossed, found
+}
+
+// markUserQuotaAlerted 记录一次通知，同一用户同一阈值在间隔内只通知一次
+func markUserQuotaAlerted(userId int, threshold int, interval time.Duration) bool {
+	if common.RedisEnabled {
+		key := fmt.Sprintf("user_quota_alert:%d:%d", userId, threshold)
+		ok, err := common.RDB.SetNX(context.Background(), key, "1", interval).Result()
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to record user quota alert: %s", err.Error()))
+			return true
+		}
+		return ok
+	}
+	userQuotaAlertCleanupOnce.Do(startUserQuotaAlertCleanup)
+	key := fmt.Sprintf("%d:%d", userId, threshold)
+	now := time.Now()
+	expiry := now.Add(interval)
+	for {
+		last, loaded := userQuotaAlertExpiry.LoadOrStore(key, expiry)
+		if !loaded {
+			return true
+		}
+		if now.Before(last.(time.Time)) {
+			return false
+		}
+		if userQuotaAlertExpiry.CompareAndSwap(key, last, expiry) {
+			return true
+		}
+	}
+}
+
+func startUserQuotaAlertCleanup() {
+	gopool.Go(func() {
+		for {
+			time.Sleep(10 * time.Minute)
+			now := time.Now()
+			userQuotaAlertExpiry.Range(func(key, expiry any) bool {
+				if !now.Before(expiry.(time.Time)) {
+					userQuotaAlertExpiry.CompareAndDelete(key, expiry)
+				}
+				return true
+			})
+		}
+	})
+}
+
+// checkUserQuotaAlerts notifies the user when the consumption of a request
+// moves their balance below one of their alert thresholds.
+func checkUserQuotaAlerts(relayInfo *relaycommon.RelayInfo, consumeQuota int) {
+	userSetting := relayInfo.UserSetting
+	if len(userSetting.QuotaAlertThresholds) == 0 || consumeQuota <= 0 {
+		return
+	}
+	before := relayInfo.UserQuota
+	after := before - consumeQuota
+	threshold, ok := crossedQuotaAlertThreshold(userSetting.QuotaAlertThresholds, before, after)
+	if !ok {
+		return
+	}
+	intervalMinutes := userSetting.QuotaAlertIntervalMinutes
+	if intervalMinutes <= 0 {
+		intervalMinutes = defaultQuotaAlertIntervalMinutes
+	}
+	if !markUserQuotaAlerted(relayInfo.UserId, threshold, time.Duration(intervalMinutes)*time.Minute) {
+		return
+	}
+	gopool.Go(func() {
+		prompt := "您的余额已低于预警阈值"
+		topUpLink := fmt.Sprintf("%s/topup", setting.ServerAddress)
+		content := "{{value}} {{value}}，当前剩余额度为 {{value}}。<br/>充值链接：<a href='{{value}}'>{{value}}</a>"
+		notify := dto.NewNotify(dto.NotifyTypeQuotaAlert, prompt, content, []interface{}{prompt, common.FormatQuota(threshold), common.FormatQuota(after), topUpLink, topUpLink})
+		if err := NotifyUser(relayInfo.UserId, relayInfo.UserEmail, userSetting, notify); err != nil {
+			common.SysError(fmt.Sprintf("failed to send quota alert to user %d: %s", relayInfo.UserId, err.Error()))
+		}
+	})
+}
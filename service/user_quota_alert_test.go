@@ -0,0 +1,131 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCrossedQuotaAlertThreshold(t *testing.T) {
+	tests := []struct {
+		name          string
+		thresholds    []int
+		before, after int
+		want          int
+		crossed       bool
+	}{
+		{name: "crosses one threshold", thresholds: []int{1000, 500}, before: 1200, after: 900, want: 1000, crossed: true},
+		{name: "crosses several thresholds", thresholds: []int{1000, 500}, before: 1200, after: 100, want: 500, crossed: true},
+		{name: "starts exactly at the threshold", thresholds: []int{1000}, before: 1000, after: 999, want: 1000, crossed: true},
+		{name: "ends exactly at the threshold", thresholds: []int{1000}, before: 1200, after: 1000},
+		{name: "already below", thresholds: []int{1000}, before: 900, after: 800},
+		{name: "stays above", thresholds: []int{500}, before: 1200, after: 900},
+		{name: "non-positive thresholds are ignored", thresholds: []int{0, -100}, before: 100, after: -200},
+		{name: "no thresholds", before: 1200, after: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, crossed := crossedQuotaAlertThreshold(tt.thresholds, tt.before, tt.after)
+			if got != tt.want || crossed != tt.crossed {
+				t.Errorf("crossedQuotaAlertThreshold() = %d, %v, want %d, %v", got, crossed, tt.want, tt.crossed)
+			}
+		})
+	}
+}
+
+func TestCheckUserQuotaAlertsFiresOnce(t *testing.T) {
+	var alertsLock sync.Mutex
+	var alerts []WebhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload WebhookPayload
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		alertsLock.Lock()
+		alerts = append(alerts, payload)
+		alertsLock.Unlock()
+	}))
+	defer server.Close()
+
+	InitHttpClient()
+	const userId = 2203
+	redisEnabled, notifyLimitCount, notifyLimitDuration := common.RedisEnabled, constant.NotifyLimitCount, constant.NotificationLimitDurationMinute
+	common.RedisEnabled, constant.NotifyLimitCount, constant.NotificationLimitDurationMinute = false, 100, 10
+	t.Cleanup(func() {
+		common.RedisEnabled, constant.NotifyLimitCount, constant.NotificationLimitDurationMinute = redisEnabled, notifyLimitCount, notifyLimitDuration
+		userQuotaAlertExpiry.Delete("2203:1000")
+		userQuotaAlertExpiry.Delete("2203:500")
+	})
+	userSetting := dto.UserSetting{NotifyType: dto.NotifyTypeWebhook, WebhookUrl: server.URL, QuotaAlertThresholds: []int{1000, 500}}
+
+	waitForAlerts := func(want int) int {
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			alertsLock.Lock()
+			got := len(alerts)
+			alertsLock.Unlock()
+			if got >= want || time.Now().After(deadline) {
+				// 再等一会儿，确认没有多余的通知
+				time.Sleep(50 * time.Millisecond)
+				alertsLock.Lock()
+				got = len(alerts)
+				alertsLock.Unlock()
+				return got
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	steps := []struct {
+		name    string
+		quota   int
+		consume int
+		alerts  int
+	}{
+		{name: "balance drops below 1000", quota: 1200, consume: 300, alerts: 1},
+		{name: "balance stays below 1000", quota: 900, consume: 100, alerts: 1},
+		{name: "recharged and crossing 1000 again within the interval", quota: 1100, consume: 200, alerts: 1},
+		{name: "balance drops below 500", quota: 700, consume: 300, alerts: 2},
+	}
+	for _, step := range steps {
+		info := &relaycommon.RelayInfo{UserId: userId, UserQuota: step.quota, UserSetting: userSetting}
+		checkUserQuotaAlerts(info, step.consume)
+		if got := waitForAlerts(step.alerts); got != step.alerts {
+			t.Fatalf("%s: %d alerts sent, want %d", step.name, got, step.alerts)
+		}
+	}
+	alertsLock.Lock()
+	defer alertsLock.Unlock()
+	if alerts[0].Type != dto.NotifyTypeQuotaAlert {
+		t.Errorf("alert type = %q, want %q", alerts[0].Type, dto.NotifyTypeQuotaAlert)
+	}
+}
+
+func TestMarkUserQuotaAlerted(t *testing.T) {
+	redisEnabled := common.RedisEnabled
+	common.RedisEnabled = false
+	t.Cleanup(func() {
+		common.RedisEnabled = redisEnabled
+		userQuotaAlertExpiry.Delete("1:100")
+		userQuotaAlertExpiry.Delete("1:50")
+	})
+	if !markUserQuotaAlerted(1, 100, time.Hour) {
+		t.Fatal("first alert was not sent")
+	}
+	if markUserQuotaAlerted(1, 100, time.Hour) {
+		t.Fatal("alert was repeated within the interval")
+	}
+	if !markUserQuotaAlerted(1, 50, time.Hour) {
+		t.Fatal("alert for another threshold was suppressed")
+	}
+
+	userQuotaAlertExpiry.Store("1:100", time.Now().Add(-time.Second))
+	if !markUserQuotaAlerted(1, 100, time.Hour) {
+		t.Fatal("alert was suppressed after the interval expired")
+	}
+}
@@ -0,0 +1,84 @@
+package model_setting
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"one-api/setting/config"
+	"sort"
+)
+
+const (
+	ABAssignmentUser    = "user"    // 同一用户固定命中同一变体
+	ABAssignmentRequest = "request" // 每个请求随机
+)
+
+type ABVariant struct {
+	Model  string `json:"model"`
+	Weight int    `json:"weight"`
+}
+
+// ABTest 一个别名背后的多个变体，按权重分流
+type ABTest struct {
+	// 变体名 -> 变体
+	Variants   map[string]ABVariant `json:"variants"`
+	Assignment string               `json:"assignment"`
+}
+
+type ABTestSettings struct {
+	// 别名 -> 实验，在模型别名解析之后、渠道选择之前生效
+	Tests map[string]ABTest `json:"tests"`
+}
+
+// 默认配置
+var abTestSettings = ABTestSettings{
+	Tests: map[string]ABTest{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("ab_test", &abTestSettings)
+}
+
+func GetABTestSettings() *ABTestSettings {
+	return &abTestSettings
+}
+
+// PickABVariant returns the variant and model for a model name configured as
+// an A/B alias. With user assignment the pick only depends on the alias and
+// user id, so the same user keeps getting the same variant.
+func PickABVariant(alias string, userId int) (variant string, modelName string, ok bool) {
+	test, ok := abTestSettings.Tests[alias]
+	if !ok {
+		return "", "", false
+	}
+	names := make([]string, 0, len(test.Variants))
+	totalWeight := 0
+	for name, v := range test.Variants {
+		if v.Model == "" || v.Weight <= 0 {
+			continue
+		}
+		names = append(names, name)
+		totalWeight += v.Weight
+	}
+	if totalWeight == 0 {
+		return "", "", false
+	}
+	sort.Strings(names)
+
+	var point int
+	if test.Assignment == ABAssignmentUser {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(fmt.Sprintf("%s:%d", alias, userId)))
+		point = int(h.Sum32() % uint32(totalWeight))
+	} else {
+		point = rand.Intn(totalWeight)
+	}
+	for _, name := range names {
+		point -= test.Variants[name].Weight
+		if point < 0 {
+			return name, test.Variants[name].Model, true
+		}
+	}
+	return "", "", false
+}
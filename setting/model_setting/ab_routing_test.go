@@ -0,0 +1,75 @@
+package model_setting
+
+import (
+	"testing"
+)
+
+func setABTests(t *testing.T, tests map[string]ABTest) {
+	t.Helper()
+	saved := abTestSettings.Tests
+	abTestSettings.Tests = tests
+	t.Cleanup(func() { abTestSettings.Tests = saved })
+}
+
+func TestPickABVariantUserAssignmentIsSticky(t *testing.T) {
+	setABTests(t, map[string]ABTest{"chat": {
+		Assignment: ABAssignmentUser,
+		Variants: map[string]ABVariant{
+			"control":   {Model: "gpt-4o", Weight: 80},
+			"candidate": {Model: "gpt-4.1", Weight: 20},
+		},
+	}})
+
+	counts := map[string]int{}
+	for userId := 1; userId <= 2000; userId++ {
+		variant, modelName, ok := PickABVariant("chat", userId)
+		if !ok {
+			t.Fatalf("user %d: no variant picked", userId)
+		}
+		for i := 0; i < 3; i++ {
+			if again, againModel, _ := PickABVariant("chat", userId); again != variant || againModel != modelName {
+				t.Fatalf("user %d switched from %s to %s", userId, variant, again)
+			}
+		}
+		counts[variant]++
+	}
+	// 按用户哈希分流，比例应接近权重
+	if share := float64(counts["candidate"]) / 2000; share < 0.15 || share > 0.25 {
+		t.Errorf("candidate share = %.2f, want about 0.20 (counts %v)", share, counts)
+	}
+}
+
+func TestPickABVariant(t *testing.T) {
+	setABTests(t, map[string]ABTest{
+		"chat": {Assignment: ABAssignmentRequest, Variants: map[string]ABVariant{
+			"a": {Model: "model-a", Weight: 1},
+			"b": {Model: "model-b", Weight: 1},
+		}},
+		"skipped": {Assignment: ABAssignmentRequest, Variants: map[string]ABVariant{
+			"zero":     {Model: "model-zero", Weight: 0},
+			"no-model": {Weight: 5},
+			"only":     {Model: "model-only", Weight: 1},
+		}},
+		"empty": {Variants: map[string]ABVariant{"zero": {Model: "model-zero"}}},
+	})
+
+	if _, _, ok := PickABVariant("gpt-4o", 1); ok {
+		t.Error("model without A/B test picked a variant")
+	}
+	if _, _, ok := PickABVariant("empty", 1); ok {
+		t.Error("test without weighted variants picked a variant")
+	}
+	for i := 0; i < 20; i++ {
+		if variant, modelName, ok := PickABVariant("skipped", 1); !ok || variant != "only" || modelName != "model-only" {
+			t.Fatalf("PickABVariant(skipped) = %s, %s, %v, want only the valid variant", variant, modelName, ok)
+		}
+	}
+	seen := map[string]bool{}
+	for i := 0; i < 200 && len(seen) < 2; i++ {
+		_, modelName, _ := PickABVariant("chat", 1)
+		seen[modelName] = true
+	}
+	if !seen["model-a"] || !seen["model-b"] {
+		t.Errorf("per-request assignment picked %v, want both variants for the same user", seen)
+	}
+}
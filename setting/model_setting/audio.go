@@ -0,0 +1,45 @@
+package model_setting
+
+import (
+	"one-api/setting/config"
+)
+
+// AudioSettings 语音转写/翻译按音频时长计价，语音合成按输入字符数计价
+type AudioSettings struct {
+	// 模型 -> 每分钟价格（美元），未配置的模型仍按 token 倍率计费
+	PricePerMinute map[string]float64 `json:"price_per_minute"`
+	// 模型 -> 每百万字符价格（美元），未配置的模型仍按 token 倍率计费
+	SpeechPricePerMillionChars map[string]float64 `json:"speech_price_per_million_chars"`
+}
+
+// 默认配置
+var defaultAudioSettings = AudioSettings{
+	PricePerMinute:             map[string]float64{},
+	SpeechPricePerMillionChars: map[string]float64{},
+}
+
+// 全局实例
+var audioSettings = defaultAudioSettings
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("audio", &audioSettings)
+}
+
+func GetAudioSettings() *AudioSettings {
+	return &audioSettings
+}
+
+// GetAudioPricePerMinute returns the per-minute price of the transcription
+// model and whether one is configured.
+func GetAudioPricePerMinute(modelName string) (float64, bool) {
+	price, ok := audioSettings.PricePerMinute[modelName]
+	return price, ok
+}
+
+// GetSpeechPricePerMillionChars returns the per-million-character price of the
+// speech model and whether one is configured.
+func GetSpeechPricePerMillionChars(modelName string) (float64, bool) {
+	price, ok := audioSettings.SpeechPricePerMillionChars[modelName]
+	return price, ok
+}
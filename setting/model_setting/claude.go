@@ -17,6 +17,8 @@ type ClaudeSettings struct {
 	DefaultMaxTokens                      map[string]int                 `json:"default_max_tokens"`
 	ThinkingAdapterEnabled                bool                           `json:"thinking_adapter_enabled"`
 	ThinkingAdapterBudgetTokensPercentage float64                        `json:"thinking_adapter_budget_tokens_percentage"`
+	// 思考 token 相对补全倍率的计费倍率，0 表示按普通补全 token 计费
+	ThinkingTokenRatio float64 `json:"thinking_token_ratio"`
 }
 
 // 默认配置
@@ -0,0 +1,45 @@
+package model_setting
+
+import (
+	"one-api/setting/config"
+)
+
+const (
+	EmbeddingSplitModeOff      = "off"
+	EmbeddingSplitModeTruncate = "truncate"
+	EmbeddingSplitModePool     = "pool"
+)
+
+// EmbeddingSettings 定义超长 embedding 输入的处理方式
+type EmbeddingSettings struct {
+	// off 不处理；truncate 截断到模型上限；pool 按滑动窗口切分后对各窗口向量取平均
+	SplitMode string `json:"split_mode"`
+	// 各模型单条输入的最大 token 数，未配置的模型不处理
+	ModelMaxInputTokens map[string]int `json:"model_max_input_tokens"`
+	// 相邻窗口重叠的 token 数
+	WindowOverlapTokens int `json:"window_overlap_tokens"`
+}
+
+// 默认配置
+var defaultEmbeddingSettings = EmbeddingSettings{
+	SplitMode: EmbeddingSplitModeOff,
+	ModelMaxInputTokens: map[string]int{
+		"text-embedding-3-small": 8191,
+		"text-embedding-3-large": 8191,
+		"text-embedding-ada-002": 8191,
+	},
+	WindowOverlapTokens: 0,
+}
+
+// 全局实例
+var embeddingSettings = defaultEmbeddingSettings
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("embedding", &embeddingSettings)
+}
+
+// GetEmbeddingSettings 获取Embedding配置
+func GetEmbeddingSettings() *EmbeddingSettings {
+	return &embeddingSettings
+}
@@ -2,15 +2,54 @@ package model_setting
 
 import (
 	"one-api/setting/config"
+	"strings"
 )
 
 type GlobalSettings struct {
 	PassThroughRequestEnabled bool `json:"pass_through_request_enabled"`
+	// 非透传模式下保留请求结构体未定义的字段（原样转发），仅对 OpenAI 格式上游生效
+	PreserveUnknownFields bool `json:"preserve_unknown_fields"`
+	// 模型名称归一化：转为小写并去除指定前缀，例如 OpenAI/gpt-4o -> gpt-4o
+	ModelNameNormalizationEnabled bool     `json:"model_name_normalization_enabled"`
+	ModelNameStripPrefixes        []string `json:"model_name_strip_prefixes"`
+	// 在响应中还原客户端请求的模型名称
+	RestoreRequestModelName bool `json:"restore_request_model_name"`
+	// 分组级模型别名：分组 -> (请求模型 -> 实际路由模型)，在渠道模型重定向之前生效
+	GroupModelAlias map[string]map[string]string `json:"group_model_alias"`
+	// 各模型允许的最大输出 token 数，超出时截断为该值
+	ModelMaxOutputTokens map[string]int `json:"model_max_output_tokens"`
+	// 流式响应转换器：模型/分组 -> 转换器名称，模型配置优先，未配置时原样透传
+	ModelSSETransformer map[string]string `json:"model_sse_transformer"`
+	GroupSSETransformer map[string]string `json:"group_sse_transformer"`
+	// 预测输出中被采纳/拒绝的 token 相对补全倍率的计费倍率
+	AcceptedPredictionTokenRatio float64 `json:"accepted_prediction_token_ratio"`
+	RejectedPredictionTokenRatio float64 `json:"rejected_prediction_token_ratio"`
+	// 推理 token（如 o 系列的 reasoning_tokens）相对补全倍率的计费倍率，Claude 思考 token 另按 Claude 设置计费
+	ReasoningTokenRatio float64 `json:"reasoning_token_ratio"`
+	// 各模型生成的音频/图片输出 token 相对补全倍率的计费倍率，未配置时按普通补全 token 计费
+	ModelOutputModalityRatio map[string]OutputModalityRatio `json:"model_output_modality_ratio"`
+}
+
+// OutputModalityRatio 多模态输出 token 的计费倍率，0 表示按普通补全 token 计费
+type OutputModalityRatio struct {
+	Audio float64 `json:"audio"`
+	Image float64 `json:"image"`
 }
 
 // 默认配置
 var defaultOpenaiSettings = GlobalSettings{
-	PassThroughRequestEnabled: false,
+	PassThroughRequestEnabled:     false,
+	ModelNameNormalizationEnabled: false,
+	ModelNameStripPrefixes:        []string{"openai/"},
+	RestoreRequestModelName:       true,
+	GroupModelAlias:               map[string]map[string]string{},
+	ModelMaxOutputTokens:          map[string]int{},
+	ModelSSETransformer:           map[string]string{},
+	GroupSSETransformer:           map[string]string{},
+	AcceptedPredictionTokenRatio:  1,
+	RejectedPredictionTokenRatio:  1,
+	ReasoningTokenRatio:           1,
+	ModelOutputModalityRatio:      map[string]OutputModalityRatio{},
 }
 
 // 全局实例
@@ -24,3 +63,67 @@ func init() {
 func GetGlobalSettings() *GlobalSettings {
 	return &globalSettings
 }
+
+// NormalizeModelName lowercases the model name and strips the first matching
+// configured prefix. The name is returned unchanged when normalization is off.
+func NormalizeModelName(modelName string) string {
+	if !globalSettings.ModelNameNormalizationEnabled || modelName == "" {
+		return modelName
+	}
+	normalized := strings.ToLower(strings.TrimSpace(modelName))
+	for _, prefix := range globalSettings.ModelNameStripPrefixes {
+		prefix = strings.ToLower(prefix)
+		if prefix != "" && strings.HasPrefix(normalized, prefix) {
+			normalized = strings.TrimPrefix(normalized, prefix)
+			break
+		}
+	}
+	return normalized
+}
+
+// ResolveModelAlias returns the model the request should be routed to. The
+// user's alias wins over the group's; aliases are not chained.
+func ResolveModelAlias(group string, userAlias map[string]string, modelName string) string {
+	if alias, ok := userAlias[modelName]; ok && alias != "" {
+		return alias
+	}
+	if alias, ok := globalSettings.GroupModelAlias[group][modelName]; ok && alias != "" {
+		return alias
+	}
+	return modelName
+}
+
+// GetModelMaxOutputTokens returns the configured output token cap for the
+// model, or 0 when the model has no cap.
+func GetModelMaxOutputTokens(modelName string) int {
+	return globalSettings.ModelMaxOutputTokens[modelName]
+}
+
+// GetSSETransformerName returns the stream transformer configured for the
+// model, falling back to the group's. Empty means passthrough.
+func GetSSETransformerName(group string, modelName string) string {
+	if name, ok := globalSettings.ModelSSETransformer[modelName]; ok {
+		return name
+	}
+	return globalSettings.GroupSSETransformer[group]
+}
+
+// GetPredictionTokenRatios returns the billing multipliers, relative to the
+// completion ratio, for accepted and rejected prediction tokens.
+func GetPredictionTokenRatios() (accepted float64, rejected float64) {
+	return globalSettings.AcceptedPredictionTokenRatio, globalSettings.RejectedPredictionTokenRatio
+}
+
+// GetReasoningTokenRatio returns the billing multiplier, relative to the
+// completion ratio, for reasoning tokens.
+func GetReasoningTokenRatio() float64 {
+	return globalSettings.ReasoningTokenRatio
+}
+
+// GetOutputModalityRatios returns the billing multipliers, relative to the
+// completion ratio, for audio and image output tokens of the model. A zero
+// ratio means the tokens are billed as regular completion tokens.
+func GetOutputModalityRatios(modelName string) (audio float64, image float64) {
+	ratio := globalSettings.ModelOutputModalityRatio[modelName]
+	return ratio.Audio, ratio.Image
+}
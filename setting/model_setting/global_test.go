@@ -0,0 +1,68 @@
+package model_setting
+
+import "testing"
+
+func TestNormalizeModelName(t *testing.T) {
+	saved := globalSettings
+	globalSettings.ModelNameNormalizationEnabled = true
+	globalSettings.ModelNameStripPrefixes = []string{"openai/", "Azure/"}
+	t.Cleanup(func() { globalSettings = saved })
+
+	tests := []struct {
+		name      string
+		modelName string
+		want      string
+	}{
+		{name: "prefixed", modelName: "openai/gpt-4o", want: "gpt-4o"},
+		{name: "mixed-case prefix and name", modelName: "OpenAI/GPT-4o", want: "gpt-4o"},
+		{name: "mixed-case configured prefix", modelName: "azure/gpt-4o-mini", want: "gpt-4o-mini"},
+		{name: "mixed case", modelName: "GPT-4o", want: "gpt-4o"},
+		{name: "already normalized", modelName: "gpt-4o", want: "gpt-4o"},
+		{name: "only the first matching prefix is stripped", modelName: "openai/azure/gpt-4o", want: "azure/gpt-4o"},
+		{name: "unknown prefix is kept", modelName: "Anthropic/claude-3-5-sonnet", want: "anthropic/claude-3-5-sonnet"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeModelName(tt.modelName); got != tt.want {
+				t.Errorf("NormalizeModelName(%q) = %q, want %q", tt.modelName, got, tt.want)
+			}
+		})
+	}
+
+	globalSettings.ModelNameNormalizationEnabled = false
+	if got := NormalizeModelName("OpenAI/GPT-4o"); got != "OpenAI/GPT-4o" {
+		t.Errorf("NormalizeModelName() with normalization off = %q, want the name unchanged", got)
+	}
+}
+
+func TestResolveModelAlias(t *testing.T) {
+	saved := globalSettings
+	globalSettings.GroupModelAlias = map[string]map[string]string{
+		"vip": {"fast": "gpt-4o-mini", "smart": "gpt-4o", "empty": ""},
+	}
+	t.Cleanup(func() { globalSettings = saved })
+
+	userAlias := map[string]string{"smart": "claude-3-5-sonnet", "chained": "fast"}
+	tests := []struct {
+		name      string
+		group     string
+		userAlias map[string]string
+		modelName string
+		want      string
+	}{
+		{name: "user alias wins over group alias", group: "vip", userAlias: userAlias, modelName: "smart", want: "claude-3-5-sonnet"},
+		{name: "group alias without user alias", group: "vip", userAlias: userAlias, modelName: "fast", want: "gpt-4o-mini"},
+		{name: "group alias for a user without aliases", group: "vip", modelName: "smart", want: "gpt-4o"},
+		{name: "other group has no alias", group: "default", modelName: "smart", want: "smart"},
+		{name: "empty alias is ignored", group: "vip", modelName: "empty", want: "empty"},
+		{name: "aliases are not chained", group: "vip", userAlias: userAlias, modelName: "chained", want: "fast"},
+		{name: "unaliased model", group: "vip", userAlias: userAlias, modelName: "gpt-4o", want: "gpt-4o"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResolveModelAlias(tt.group, tt.userAlias, tt.modelName); got != tt.want {
+				t.Errorf("ResolveModelAlias(%q, %q) = %q, want %q", tt.group, tt.modelName, got, tt.want)
+			}
+		})
+	}
+}
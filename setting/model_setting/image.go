@@ -0,0 +1,57 @@
+package model_setting
+
+import (
+	"fmt"
+	"one-api/setting/config"
+)
+
+// ImageWildcard 尺寸或品质为该值时匹配任意取值
+const ImageWildcard = "*"
+
+// ImageSettings 图片生成按尺寸与品质计价
+type ImageSettings struct {
+	// 模型 -> 尺寸 -> 品质 -> 单张图片价格（美元），配置了的模型只接受表中出现的组合
+	Prices map[string]map[string]map[string]float64 `json:"prices"`
+}
+
+// 默认配置
+var defaultImageSettings = ImageSettings{
+	Prices: map[string]map[string]map[string]float64{},
+}
+
+// 全局实例
+var imageSettings = defaultImageSettings
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("image", &imageSettings)
+}
+
+func GetImageSettings() *ImageSettings {
+	return &imageSettings
+}
+
+// GetImagePrice returns the per-image price of the model for the size and
+// quality. ok is false when the model has no price table; an error is
+// returned when the table does not cover the size/quality combination.
+func GetImagePrice(modelName string, size string, quality string) (price float64, ok bool, err error) {
+	sizes, ok := imageSettings.Prices[modelName]
+	if !ok {
+		return 0, false, nil
+	}
+	qualities, found := sizes[size]
+	if !found {
+		qualities, found = sizes[ImageWildcard]
+	}
+	if !found {
+		return 0, true, fmt.Errorf("size %q is not supported for model %s", size, modelName)
+	}
+	price, found = qualities[quality]
+	if !found {
+		price, found = qualities[ImageWildcard]
+	}
+	if !found {
+		return 0, true, fmt.Errorf("quality %q is not supported for model %s with size %s", quality, modelName, size)
+	}
+	return price, true, nil
+}
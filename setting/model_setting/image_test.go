@@ -0,0 +1,48 @@
+package model_setting
+
+import (
+	"testing"
+)
+
+func TestGetImagePrice(t *testing.T) {
+	saved := imageSettings.Prices
+	imageSettings.Prices = map[string]map[string]map[string]float64{
+		"gpt-image-1": {
+			"1024x1024": {"low": 0.011, "medium": 0.042, "high": 0.167},
+			"1536x1024": {"low": 0.016, ImageWildcard: 0.063},
+		},
+		"flux": {
+			ImageWildcard: {ImageWildcard: 0.03},
+		},
+	}
+	t.Cleanup(func() { imageSettings.Prices = saved })
+
+	tests := []struct {
+		name      string
+		model     string
+		size      string
+		quality   string
+		price     float64
+		hasPrices bool
+		wantErr   bool
+	}{
+		{name: "exact size and quality", model: "gpt-image-1", size: "1024x1024", quality: "high", price: 0.167, hasPrices: true},
+		{name: "wildcard quality", model: "gpt-image-1", size: "1536x1024", quality: "high", price: 0.063, hasPrices: true},
+		{name: "exact quality wins over the wildcard", model: "gpt-image-1", size: "1536x1024", quality: "low", price: 0.016, hasPrices: true},
+		{name: "wildcard size and quality", model: "flux", size: "2048x2048", price: 0.03, hasPrices: true},
+		{name: "unsupported size", model: "gpt-image-1", size: "256x256", quality: "low", hasPrices: true, wantErr: true},
+		{name: "unsupported quality", model: "gpt-image-1", size: "1024x1024", quality: "hd", hasPrices: true, wantErr: true},
+		{name: "model without price table", model: "dall-e-3", size: "1024x1024", quality: "hd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			price, ok, err := GetImagePrice(tt.model, tt.size, tt.quality)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetImagePrice() error = %v, want error %v", err, tt.wantErr)
+			}
+			if price != tt.price || ok != tt.hasPrices {
+				t.Errorf("GetImagePrice() = %v, %v, want %v, %v", price, ok, tt.price, tt.hasPrices)
+			}
+		})
+	}
+}
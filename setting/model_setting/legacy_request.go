@@ -0,0 +1,33 @@
+package model_setting
+
+import (
+	"one-api/setting/config"
+	"slices"
+)
+
+// LegacyRequestSettings 将旧版请求字段（functions、function_call、整数 logprobs）升级为当前格式
+type LegacyRequestSettings struct {
+	// 需要升级请求的渠道类型，为空时不升级
+	ChannelTypes []int `json:"channel_types"`
+}
+
+// 默认配置
+var defaultLegacyRequestSettings = LegacyRequestSettings{
+	ChannelTypes: []int{},
+}
+
+// 全局实例
+var legacyRequestSettings = defaultLegacyRequestSettings
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("legacy_request", &legacyRequestSettings)
+}
+
+func GetLegacyRequestSettings() *LegacyRequestSettings {
+	return &legacyRequestSettings
+}
+
+func ShouldUpgradeLegacyRequest(channelType int) bool {
+	return slices.Contains(legacyRequestSettings.ChannelTypes, channelType)
+}
@@ -0,0 +1,37 @@
+package model_setting
+
+import (
+	"one-api/setting/config"
+)
+
+// ModelContextLimitSettings 模型的最大上下文 token 数，输入 token 与请求的最大输出 token 之和超出时在本地直接拒绝
+type ModelContextLimitSettings struct {
+	// 模型名 -> 最大上下文 token 数，未配置或小于等于 0 的模型不检查
+	Models map[string]int `json:"models"`
+}
+
+// 默认配置
+var defaultModelContextLimitSettings = ModelContextLimitSettings{
+	Models: map[string]int{},
+}
+
+// 全局实例
+var modelContextLimitSettings = defaultModelContextLimitSettings
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("model_context_limit", &modelContextLimitSettings)
+}
+
+func GetModelContextLimitSettings() *ModelContextLimitSettings {
+	return &modelContextLimitSettings
+}
+
+// GetModelMaxContextTokens 返回模型配置的最大上下文 token 数，未配置时返回 false
+func GetModelMaxContextTokens(model string) (int, bool) {
+	limit, ok := modelContextLimitSettings.Models[model]
+	if !ok || limit <= 0 {
+		return 0, false
+	}
+	return limit, true
+}
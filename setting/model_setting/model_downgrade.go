@@ -0,0 +1,42 @@
+package model_setting
+
+import (
+	"one-api/setting/config"
+)
+
+// ModelDowngradeSettings 模型的所有渠道均不可用时自动降级到的替代模型
+type ModelDowngradeSettings struct {
+	Enabled bool `json:"enabled"`
+	// 模型名 -> 降级模型名，降级不会链式传递
+	Models map[string]string `json:"models"`
+}
+
+// 默认配置
+var defaultModelDowngradeSettings = ModelDowngradeSettings{
+	Enabled: false,
+	Models:  map[string]string{},
+}
+
+// 全局实例
+var modelDowngradeSettings = defaultModelDowngradeSettings
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("model_downgrade", &modelDowngradeSettings)
+}
+
+func GetModelDowngradeSettings() *ModelDowngradeSettings {
+	return &modelDowngradeSettings
+}
+
+// GetModelDowngrade 返回模型配置的降级模型，未启用或未配置时返回 false
+func GetModelDowngrade(model string) (string, bool) {
+	if !modelDowngradeSettings.Enabled {
+		return "", false
+	}
+	target, ok := modelDowngradeSettings.Models[model]
+	if !ok || target == "" || target == model {
+		return "", false
+	}
+	return target, true
+}
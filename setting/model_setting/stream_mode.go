@@ -0,0 +1,42 @@
+package model_setting
+
+import (
+	"one-api/setting/config"
+)
+
+const (
+	StreamModeForceStream    = "stream"     // 上游总是流式请求，非流式客户端收到聚合后的响应
+	StreamModeForceNonStream = "non_stream" // 上游总是非流式请求，流式客户端收到合成的 SSE
+)
+
+// StreamModeSettings 按模型强制上游请求的流式模式
+type StreamModeSettings struct {
+	// 模型名 -> stream | non_stream
+	Models map[string]string `json:"models"`
+}
+
+// 默认配置
+var defaultStreamModeSettings = StreamModeSettings{
+	Models: map[string]string{},
+}
+
+// 全局实例
+var streamModeSettings = defaultStreamModeSettings
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("stream_mode", &streamModeSettings)
+}
+
+func GetStreamModeSettings() *StreamModeSettings {
+	return &streamModeSettings
+}
+
+// GetForcedStreamMode 返回模型强制的上游流式模式，未配置或配置无效时返回空字符串
+func GetForcedStreamMode(model string) string {
+	switch mode := streamModeSettings.Models[model]; mode {
+	case StreamModeForceStream, StreamModeForceNonStream:
+		return mode
+	}
+	return ""
+}
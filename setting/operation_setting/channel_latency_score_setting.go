@@ -0,0 +1,43 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+const (
+	ChannelLatencyMetricAvg = "avg"
+	ChannelLatencyMetricP95 = "p95"
+)
+
+// ChannelLatencyScoreSetting 按渠道的响应延迟调整加权随机选择中的权重，持续偏慢的渠道被选中的概率降低
+// 非流式请求的延迟为收到响应头的时间，流式请求为收到首个 token 的时间
+type ChannelLatencyScoreSetting struct {
+	Enabled bool `json:"enabled"`
+	// 评分使用的延迟指标：avg 或 p95
+	Metric string `json:"metric"`
+	// 延迟权重：系数为 (同优先级最快渠道延迟 / 本渠道延迟) 的该次方，0 表示不按延迟调整
+	LatencyWeight float64 `json:"latency_weight"`
+	// 每个渠道保留的最近样本数
+	WindowSize int `json:"window_size"`
+	// 样本数少于该值的渠道不参与评分，保证新渠道仍有机会被选中
+	MinSamples int `json:"min_samples"`
+	// 系数下限，避免慢渠道完全不被选中而无法恢复
+	MinFactor float64 `json:"min_factor"`
+}
+
+// 默认配置
+var channelLatencyScoreSetting = ChannelLatencyScoreSetting{
+	Enabled:       false,
+	Metric:        ChannelLatencyMetricP95,
+	LatencyWeight: 1,
+	WindowSize:    50,
+	MinSamples:    10,
+	MinFactor:     0.1,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("channel_latency_score_setting", &channelLatencyScoreSetting)
+}
+
+func GetChannelLatencyScoreSetting() *ChannelLatencyScoreSetting {
+	return &channelLatencyScoreSetting
+}
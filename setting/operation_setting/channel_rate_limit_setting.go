@@ -0,0 +1,36 @@
+package operation_setting
+
+import (
+	"one-api/setting/config"
+	"slices"
+)
+
+// ChannelRateLimitSetting 根据上游返回的 x-ratelimit-* 响应头，在渠道接近限额时降低其被选中的优先级
+type ChannelRateLimitSetting struct {
+	// 启用的渠道类型，为空时不启用
+	ChannelTypes []int `json:"channel_types"`
+	// 剩余额度占总额度的比例低于该值时视为接近限额
+	RemainingRatioThreshold float64 `json:"remaining_ratio_threshold"`
+	// 上游未返回 reset 头时，状态的有效期（秒）
+	DefaultResetSeconds int `json:"default_reset_seconds"`
+}
+
+// 默认配置
+var channelRateLimitSetting = ChannelRateLimitSetting{
+	ChannelTypes:            []int{},
+	RemainingRatioThreshold: 0.1,
+	DefaultResetSeconds:     60,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("channel_rate_limit_setting", &channelRateLimitSetting)
+}
+
+func GetChannelRateLimitSetting() *ChannelRateLimitSetting {
+	return &channelRateLimitSetting
+}
+
+func IsChannelRateLimitTracked(channelType int) bool {
+	return slices.Contains(channelRateLimitSetting.ChannelTypes, channelType)
+}
@@ -0,0 +1,37 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+const (
+	ChannelSelectionWeighted   = "weighted"
+	ChannelSelectionRoundRobin = "round_robin"
+)
+
+// ChannelSelectionSetting 同一优先级内的渠道选择策略：weighted 按权重随机，round_robin 按顺序轮询
+type ChannelSelectionSetting struct {
+	Strategy string `json:"strategy"`
+	// 模型 -> 策略，优先于全局策略
+	ModelStrategies map[string]string `json:"model_strategies"`
+}
+
+// 默认配置
+var channelSelectionSetting = ChannelSelectionSetting{
+	Strategy:        ChannelSelectionWeighted,
+	ModelStrategies: map[string]string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("channel_selection_setting", &channelSelectionSetting)
+}
+
+func GetChannelSelectionSetting() *ChannelSelectionSetting {
+	return &channelSelectionSetting
+}
+
+func GetChannelSelectionStrategy(modelName string) string {
+	if strategy, ok := channelSelectionSetting.ModelStrategies[modelName]; ok && strategy != "" {
+		return strategy
+	}
+	return channelSelectionSetting.Strategy
+}
@@ -0,0 +1,34 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// ChannelWeightDecaySetting 按 AIMD 调整渠道的有效权重：出错时乘以衰减系数，成功时加回恢复步长，上限为原始权重
+type ChannelWeightDecaySetting struct {
+	Enabled bool `json:"enabled"`
+	// 每次出错后系数乘以该值，取值 (0, 1)
+	DecreaseFactor float64 `json:"decrease_factor"`
+	// 每次成功后系数增加该值
+	IncreaseStep float64 `json:"increase_step"`
+	// 系数下限，避免渠道完全不被选中而无法恢复
+	MinFactor float64 `json:"min_factor"`
+	// 出错后连续成功（期间无错误）持续该秒数时直接将系数恢复为 1，0 表示只按步长逐步恢复
+	RecoveryGraceSeconds int `json:"recovery_grace_seconds"`
+}
+
+// 默认配置
+var channelWeightDecaySetting = ChannelWeightDecaySetting{
+	Enabled:              false,
+	DecreaseFactor:       0.5,
+	IncreaseStep:         0.1,
+	MinFactor:            0.05,
+	RecoveryGraceSeconds: 300,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("channel_weight_decay_setting", &channelWeightDecaySetting)
+}
+
+func GetChannelWeightDecaySetting() *ChannelWeightDecaySetting {
+	return &channelWeightDecaySetting
+}
@@ -0,0 +1,31 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// ContextSummarySetting 上游因上下文超长拒绝请求时，摘要较早的消息后重试一次
+type ContextSummarySetting struct {
+	Enabled bool `json:"enabled"`
+	// 生成摘要使用的模型，在当前分组内选择渠道并单独计费
+	Model string `json:"model"`
+	// 原样保留的最近消息条数，开头的 system 消息始终保留
+	KeepRecentMessages int `json:"keep_recent_messages"`
+	// 摘要请求的 max_tokens
+	MaxSummaryTokens int `json:"max_summary_tokens"`
+}
+
+// 默认配置
+var contextSummarySetting = ContextSummarySetting{
+	Enabled:            false,
+	Model:              "gpt-4o-mini",
+	KeepRecentMessages: 4,
+	MaxSummaryTokens:   1024,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("context_summary_setting", &contextSummarySetting)
+}
+
+func GetContextSummarySetting() *ContextSummarySetting {
+	return &contextSummarySetting
+}
@@ -0,0 +1,58 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+const (
+	GroupBudgetModeSoft = "soft" // 只告警
+	GroupBudgetModeHard = "hard" // 告警并在超出后拒绝请求
+
+	GroupBudgetPeriodDay   = "day"
+	GroupBudgetPeriodMonth = "month"
+)
+
+// GroupBudget 分组在一个周期内的消费上限
+type GroupBudget struct {
+	// 上限额度，0 表示不限制
+	Quota int    `json:"quota"`
+	Mode  string `json:"mode"`
+	// 告警百分比，每个周期每档只告警一次，为空时使用 80、100
+	AlertPercents []int `json:"alert_percents"`
+}
+
+// GroupBudgetSetting 分组消费上限，按独立的分组消费计数（group_spends）统计，不依赖消费日志和数据看板
+type GroupBudgetSetting struct {
+	Period  string                 `json:"period"`
+	Budgets map[string]GroupBudget `json:"budgets"`
+	// 告警 webhook，为空时只通知 root 用户
+	WebhookUrl    string `json:"webhook_url"`
+	WebhookSecret string `json:"webhook_secret"`
+	NotifyRoot    bool   `json:"notify_root"`
+	// 统计消费的间隔（秒）
+	CheckIntervalSeconds int `json:"check_interval_seconds"`
+}
+
+// 默认配置
+var groupBudgetSetting = GroupBudgetSetting{
+	Period:               GroupBudgetPeriodMonth,
+	Budgets:              map[string]GroupBudget{},
+	NotifyRoot:           true,
+	CheckIntervalSeconds: 60,
+}
+
+var defaultGroupBudgetAlertPercents = []int{80, 100}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("group_budget_setting", &groupBudgetSetting)
+}
+
+func GetGroupBudgetSetting() *GroupBudgetSetting {
+	return &groupBudgetSetting
+}
+
+func (b GroupBudget) GetAlertPercents() []int {
+	if len(b.AlertPercents) == 0 {
+		return defaultGroupBudgetAlertPercents
+	}
+	return b.AlertPercents
+}
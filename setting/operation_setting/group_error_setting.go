@@ -0,0 +1,38 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+const (
+	GroupErrorSaturated         = "saturated"
+	GroupErrorInsufficientQuota = "insufficient_quota"
+)
+
+// GroupErrorOverride 自定义返回给用户的错误信息及状态码，为空/0 时使用默认值
+type GroupErrorOverride struct {
+	Message    string `json:"message"`
+	StatusCode int    `json:"status_code"`
+}
+
+// GroupErrorSetting 分组 -> 错误类型（saturated、insufficient_quota）-> 自定义错误
+type GroupErrorSetting struct {
+	Overrides map[string]map[string]GroupErrorOverride `json:"overrides"`
+}
+
+// 默认配置
+var groupErrorSetting = GroupErrorSetting{
+	Overrides: map[string]map[string]GroupErrorOverride{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("group_error_setting", &groupErrorSetting)
+}
+
+func GetGroupErrorSetting() *GroupErrorSetting {
+	return &groupErrorSetting
+}
+
+func GetGroupErrorOverride(group string, errorKind string) (GroupErrorOverride, bool) {
+	override, ok := groupErrorSetting.Overrides[group][errorKind]
+	return override, ok
+}
@@ -0,0 +1,39 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// HedgeSetting 对冲请求：首个渠道在 DelayMs 内未完成时，再向另一个渠道发送同一请求，先成功者返回，仅按胜者计费。
+// 只对启用分组中带有 X-Hedge-Request: true 请求头的非流式文本请求生效
+type HedgeSetting struct {
+	Enabled bool     `json:"enabled"`
+	Groups  []string `json:"groups"`
+	DelayMs int      `json:"delay_ms"`
+}
+
+// 默认配置
+var hedgeSetting = HedgeSetting{
+	Enabled: false,
+	Groups:  []string{},
+	DelayMs: 2000,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("hedge_setting", &hedgeSetting)
+}
+
+func GetHedgeSetting() *HedgeSetting {
+	return &hedgeSetting
+}
+
+func IsHedgeEnabledForGroup(group string) bool {
+	if !hedgeSetting.Enabled {
+		return false
+	}
+	for _, g := range hedgeSetting.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
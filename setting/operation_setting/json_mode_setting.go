@@ -0,0 +1,34 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+const (
+	JsonModeActionInject  = "inject"
+	JsonModeActionRequire = "require"
+)
+
+// JsonModeSetting 分组 -> JSON 模式策略，仅作用于 chat completions 请求
+// inject：请求未指定 JSON 格式时自动加入 response_format: {type: json_object}；
+// require：请求未指定 json_object 或 json_schema 时直接拒绝。
+// 开启请求透传时请求体不会被修改，inject 不生效，需要使用 require。
+type JsonModeSetting struct {
+	GroupPolicies map[string]string `json:"group_policies"`
+}
+
+// 默认配置
+var jsonModeSetting = JsonModeSetting{
+	GroupPolicies: map[string]string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("json_mode_setting", &jsonModeSetting)
+}
+
+func GetJsonModeSetting() *JsonModeSetting {
+	return &jsonModeSetting
+}
+
+func GetGroupJsonModePolicy(group string) string {
+	return jsonModeSetting.GroupPolicies[group]
+}
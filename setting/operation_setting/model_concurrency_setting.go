@@ -0,0 +1,82 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+const (
+	ModelConcurrencyFairnessStrict      = "strict"
+	ModelConcurrencyFairnessGroupWeight = "group_weight"
+)
+
+// ModelConcurrencyQuotaTier 余额达到 MinQuota 的用户排队时乘以 Weight
+type ModelConcurrencyQuotaTier struct {
+	MinQuota int     `json:"min_quota"`
+	Weight   float64 `json:"weight"`
+}
+
+type ModelConcurrencySetting struct {
+	Enabled bool `json:"enabled"`
+	// 模型 -> 本节点允许同时进行的请求数
+	ModelLimits map[string]int `json:"model_limits"`
+	// strict：各用户平分并发；group_weight：按分组权重分配
+	FairnessMode string `json:"fairness_mode"`
+	// 分组权重，未配置的分组权重为 1
+	GroupWeights map[string]float64 `json:"group_weights"`
+	// 按用户余额分档的权重，与分组权重相乘；取满足条件的最高档，未命中时为 1
+	QuotaTierWeights []ModelConcurrencyQuotaTier `json:"quota_tier_weights"`
+	// 排队等待的最长时间（秒），超时返回 429
+	QueueTimeoutSeconds int `json:"queue_timeout_seconds"`
+}
+
+// 默认配置
+var modelConcurrencySetting = ModelConcurrencySetting{
+	Enabled:             false,
+	ModelLimits:         map[string]int{},
+	FairnessMode:        ModelConcurrencyFairnessStrict,
+	GroupWeights:        map[string]float64{},
+	QuotaTierWeights:    []ModelConcurrencyQuotaTier{},
+	QueueTimeoutSeconds: 30,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("model_concurrency_setting", &modelConcurrencySetting)
+}
+
+func GetModelConcurrencySetting() *ModelConcurrencySetting {
+	return &modelConcurrencySetting
+}
+
+// GetModelConcurrencyLimit returns the slot count for the model, or 0 when
+// the model is not limited.
+func GetModelConcurrencyLimit(modelName string) int {
+	if !modelConcurrencySetting.Enabled {
+		return 0
+	}
+	return modelConcurrencySetting.ModelLimits[modelName]
+}
+
+// GetModelConcurrencyWeight returns the fair-share weight of a group.
+func GetModelConcurrencyWeight(group string) float64 {
+	if modelConcurrencySetting.FairnessMode != ModelConcurrencyFairnessGroupWeight {
+		return 1
+	}
+	if weight, ok := modelConcurrencySetting.GroupWeights[group]; ok && weight > 0 {
+		return weight
+	}
+	return 1
+}
+
+// GetModelConcurrencyQuotaTierWeight returns the weight of the highest quota
+// tier the balance reaches, or 1 when no tier applies.
+func GetModelConcurrencyQuotaTierWeight(quota int) float64 {
+	weight, tierQuota, found := 1.0, 0, false
+	for _, tier := range modelConcurrencySetting.QuotaTierWeights {
+		if tier.Weight <= 0 || quota < tier.MinQuota {
+			continue
+		}
+		if !found || tier.MinQuota > tierQuota {
+			weight, tierQuota, found = tier.Weight, tier.MinQuota, true
+		}
+	}
+	return weight
+}
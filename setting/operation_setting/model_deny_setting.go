@@ -0,0 +1,39 @@
+package operation_setting
+
+import (
+	"one-api/setting/config"
+	"strings"
+)
+
+// ModelDenySetting 全局禁用的模型，优先于渠道、分组及令牌的模型限制
+type ModelDenySetting struct {
+	Models []string `json:"models"`
+}
+
+// 默认配置
+var modelDenySetting = ModelDenySetting{
+	Models: []string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("model_deny_setting", &modelDenySetting)
+}
+
+func GetModelDenySetting() *ModelDenySetting {
+	return &modelDenySetting
+}
+
+// IsModelDenied reports whether the model is on the global deny-list. The
+// comparison ignores case.
+func IsModelDenied(modelName string) bool {
+	if modelName == "" {
+		return false
+	}
+	for _, denied := range modelDenySetting.Models {
+		if strings.EqualFold(denied, modelName) {
+			return true
+		}
+	}
+	return false
+}
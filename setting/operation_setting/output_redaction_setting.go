@@ -0,0 +1,58 @@
+package operation_setting
+
+import (
+	"one-api/setting/config"
+	"regexp"
+	"sync"
+)
+
+// OutputRedactionSetting 按分组对模型输出中匹配正则的内容打码，例如身份证号、银行卡号
+type OutputRedactionSetting struct {
+	// 分组 -> 正则表达式列表
+	GroupPatterns map[string][]string `json:"group_patterns"`
+	Replacement   string              `json:"replacement"`
+	// 单个匹配的最大长度（字节），流式输出时保留该长度的尾部与下一块拼接后再匹配
+	MaxMatchLength int `json:"max_match_length"`
+}
+
+// 默认配置
+var outputRedactionSetting = OutputRedactionSetting{
+	GroupPatterns:  map[string][]string{},
+	Replacement:    "[REDACTED]",
+	MaxMatchLength: 64,
+}
+
+var redactionRegexCache sync.Map // pattern -> *regexp.Regexp, nil when invalid
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("output_redaction_setting", &outputRedactionSetting)
+}
+
+func GetOutputRedactionSetting() *OutputRedactionSetting {
+	return &outputRedactionSetting
+}
+
+// GetGroupRedactionRegexps returns the compiled patterns of the group.
+// Invalid patterns are skipped.
+func GetGroupRedactionRegexps(group string) []*regexp.Regexp {
+	patterns := outputRedactionSetting.GroupPatterns[group]
+	if len(patterns) == 0 {
+		return nil
+	}
+	regexps := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		cached, ok := redactionRegexCache.Load(pattern)
+		if !ok {
+			compiled, err := regexp.Compile(pattern)
+			if err != nil {
+				compiled = nil
+			}
+			cached, _ = redactionRegexCache.LoadOrStore(pattern, compiled)
+		}
+		if re := cached.(*regexp.Regexp); re != nil {
+			regexps = append(regexps, re)
+		}
+	}
+	return regexps
+}
@@ -0,0 +1,47 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+const (
+	ParamPolicyActionClamp  = "clamp"
+	ParamPolicyActionReject = "reject"
+)
+
+// ParamBound 参数取值范围，min/max 为空时不限制；action 为 clamp（截断到范围内）或 reject（直接拒绝）
+type ParamBound struct {
+	Min    *float64 `json:"min"`
+	Max    *float64 `json:"max"`
+	Action string   `json:"action"`
+}
+
+// ParamPolicySetting 分组 -> 参数名 -> 取值范围
+// 支持 temperature、top_p、top_k、n、max_tokens、max_completion_tokens、frequency_penalty、presence_penalty。
+// 开启请求透传时请求体不会被修改，clamp 不生效，需要使用 reject。
+type ParamPolicySetting struct {
+	GroupPolicies map[string]map[string]ParamBound `json:"group_policies"`
+	// 分组 -> 参数名 -> 客户端未设置该参数时使用的默认值，支持的参数同上，默认值同样受取值范围约束
+	GroupDefaults map[string]map[string]float64 `json:"group_defaults"`
+}
+
+// 默认配置
+var paramPolicySetting = ParamPolicySetting{
+	GroupPolicies: map[string]map[string]ParamBound{},
+	GroupDefaults: map[string]map[string]float64{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("param_policy_setting", &paramPolicySetting)
+}
+
+func GetParamPolicySetting() *ParamPolicySetting {
+	return &paramPolicySetting
+}
+
+func GetGroupParamPolicy(group string) map[string]ParamBound {
+	return paramPolicySetting.GroupPolicies[group]
+}
+
+func GetGroupParamDefaults(group string) map[string]float64 {
+	return paramPolicySetting.GroupDefaults[group]
+}
@@ -0,0 +1,69 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+const (
+	PromptClassifierHeuristic = "heuristic" // 本地关键词启发式评分
+	PromptClassifierRemote    = "remote"    // 调用外部分类服务
+
+	PromptClassifierActionBlock = "block" // 超过阈值时拒绝请求
+	PromptClassifierActionFlag  = "flag"  // 超过阈值时仅在日志中标记
+)
+
+// PromptClassifierGroupPolicy 分组的处理方式，Threshold 为 0 时使用全局阈值
+type PromptClassifierGroupPolicy struct {
+	Action    string  `json:"action"`
+	Threshold float64 `json:"threshold"`
+}
+
+// PromptClassifierSetting 请求前的提示词注入/越狱分类，仅对 Groups 中配置的分组生效
+type PromptClassifierSetting struct {
+	Enabled bool `json:"enabled"`
+	// heuristic 或 remote
+	Type string `json:"type"`
+	// 外部分类服务地址，接收 {"text": "..."}，返回 {"score": 0~1}
+	Url       string `json:"url"`
+	TimeoutMs int    `json:"timeout_ms"`
+	// 评分不低于该值视为疑似注入，取值 0~1
+	Threshold float64                                `json:"threshold"`
+	Groups    map[string]PromptClassifierGroupPolicy `json:"groups"`
+}
+
+// 默认配置
+var promptClassifierSetting = PromptClassifierSetting{
+	Enabled:   false,
+	Type:      PromptClassifierHeuristic,
+	TimeoutMs: 2000,
+	Threshold: 0.8,
+	Groups:    map[string]PromptClassifierGroupPolicy{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("prompt_classifier_setting", &promptClassifierSetting)
+}
+
+func GetPromptClassifierSetting() *PromptClassifierSetting {
+	return &promptClassifierSetting
+}
+
+// GetPromptClassifierPolicy returns the action and threshold for the group,
+// or false when the group is not classified.
+func GetPromptClassifierPolicy(group string) (action string, threshold float64, ok bool) {
+	if !promptClassifierSetting.Enabled {
+		return "", 0, false
+	}
+	policy, ok := promptClassifierSetting.Groups[group]
+	if !ok {
+		return "", 0, false
+	}
+	action = policy.Action
+	if action != PromptClassifierActionBlock {
+		action = PromptClassifierActionFlag
+	}
+	threshold = policy.Threshold
+	if threshold <= 0 {
+		threshold = promptClassifierSetting.Threshold
+	}
+	return action, threshold, true
+}
@@ -0,0 +1,38 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// QueueAlertThreshold 队列告警阈值，0 表示不检查该项
+type QueueAlertThreshold struct {
+	// 排队中的请求数
+	Depth int64 `json:"depth"`
+	// 等待时间 p99（毫秒）
+	WaitP99Ms int64 `json:"wait_p99_ms"`
+	// 自上次告警以来新增的排队超时数
+	Drops int64 `json:"drops"`
+}
+
+// QueueAlertSetting 队列积压告警，指标越过阈值时发送 webhook
+type QueueAlertSetting struct {
+	WebhookUrl    string `json:"webhook_url"`
+	WebhookSecret string `json:"webhook_secret"`
+	// 队列名 -> 阈值，队列名为 global_concurrency、model_concurrency
+	Thresholds map[string]QueueAlertThreshold `json:"thresholds"`
+	// 同一队列同一指标两次告警的最小间隔（秒）
+	CooldownSeconds int `json:"cooldown_seconds"`
+}
+
+// 默认配置
+var queueAlertSetting = QueueAlertSetting{
+	Thresholds:      map[string]QueueAlertThreshold{},
+	CooldownSeconds: 300,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("queue_alert_setting", &queueAlertSetting)
+}
+
+func GetQueueAlertSetting() *QueueAlertSetting {
+	return &queueAlertSetting
+}
@@ -0,0 +1,58 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type QuotaHeaderSetting struct {
+	// 在响应头中返回本次请求结算后的剩余额度 X-Quota-Remaining
+	Enabled bool `json:"enabled"`
+	// 在响应头 X-Request-Cost 中返回本次请求实际消耗的额度，流式响应以 trailer 返回
+	RequestCostEnabled bool `json:"request_cost_enabled"`
+	// 仅对这些分组生效，为空时对所有分组生效；剩余额度响应头也可由令牌单独开启
+	Groups []string `json:"groups"`
+}
+
+// 默认配置
+var quotaHeaderSetting = QuotaHeaderSetting{
+	Enabled:            false,
+	RequestCostEnabled: false,
+	Groups:             []string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("quota_header_setting", &quotaHeaderSetting)
+}
+
+func GetQuotaHeaderSetting() *QuotaHeaderSetting {
+	return &quotaHeaderSetting
+}
+
+// ShouldSendQuotaHeader reports whether the remaining quota headers should be
+// returned for requests using the given group, or by a token that opted in.
+func ShouldSendQuotaHeader(group string, tokenEnabled bool) bool {
+	if !quotaHeaderSetting.Enabled {
+		return false
+	}
+	return tokenEnabled || quotaHeaderGroupAllowed(group)
+}
+
+// ShouldSendRequestCost reports whether the request cost header should be
+// returned for requests using the given group.
+func ShouldSendRequestCost(group string) bool {
+	if !quotaHeaderSetting.RequestCostEnabled {
+		return false
+	}
+	return quotaHeaderGroupAllowed(group)
+}
+
+func quotaHeaderGroupAllowed(group string) bool {
+	if len(quotaHeaderSetting.Groups) == 0 {
+		return true
+	}
+	for _, g := range quotaHeaderSetting.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
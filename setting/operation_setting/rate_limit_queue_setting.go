@@ -0,0 +1,55 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// 限流器名称，用于按限流器配置排队等待
+const (
+	RateLimiterGlobalApi = "global_api"
+	RateLimiterGlobalWeb = "global_web"
+	RateLimiterCritical  = "critical"
+	RateLimiterDownload  = "download"
+	RateLimiterUpload    = "upload"
+	RateLimiterUserTier  = "user_tier"
+)
+
+// RateLimitQueueSetting 超出限流的请求先排队等待空位，等待超时后才返回 429，用于平滑突发请求
+type RateLimitQueueSetting struct {
+	// 限流器名称 -> 最长等待秒数，未配置或小于等于 0 时直接拒绝
+	MaxWaitSeconds map[string]int `json:"max_wait_seconds"`
+	// 限流器名称 -> 单节点同时排队的最大请求数，未配置或小于等于 0 时使用 DefaultMaxQueued，排满后直接拒绝
+	MaxQueued        map[string]int `json:"max_queued"`
+	DefaultMaxQueued int            `json:"default_max_queued"`
+}
+
+// 默认配置
+var rateLimitQueueSetting = RateLimitQueueSetting{
+	MaxWaitSeconds:   map[string]int{},
+	MaxQueued:        map[string]int{},
+	DefaultMaxQueued: 100,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("rate_limit_queue_setting", &rateLimitQueueSetting)
+}
+
+func GetRateLimitQueueSetting() *RateLimitQueueSetting {
+	return &rateLimitQueueSetting
+}
+
+// GetRateLimitMaxWait 返回限流器排队的最长等待时间，0 表示不排队
+func GetRateLimitMaxWait(limiter string) int {
+	seconds := rateLimitQueueSetting.MaxWaitSeconds[limiter]
+	if seconds < 0 {
+		return 0
+	}
+	return seconds
+}
+
+// GetRateLimitMaxQueued 返回限流器同时排队的最大请求数
+func GetRateLimitMaxQueued(limiter string) int {
+	if queued := rateLimitQueueSetting.MaxQueued[limiter]; queued > 0 {
+		return queued
+	}
+	return rateLimitQueueSetting.DefaultMaxQueued
+}
@@ -0,0 +1,34 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// RealtimeConnectionSetting 限制每个分组同时打开的 Realtime WebSocket 连接数，
+// 启用 Redis 时为所有节点合计，否则按单个节点计数
+type RealtimeConnectionSetting struct {
+	// 分组 -> 最大同时连接数，未配置或小于等于 0 的分组不限制
+	GroupMaxConnections map[string]int `json:"group_max_connections"`
+}
+
+// 默认配置
+var realtimeConnectionSetting = RealtimeConnectionSetting{
+	GroupMaxConnections: map[string]int{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("realtime_connection_setting", &realtimeConnectionSetting)
+}
+
+func GetRealtimeConnectionSetting() *RealtimeConnectionSetting {
+	return &realtimeConnectionSetting
+}
+
+// GetRealtimeMaxConnections returns the connection cap of the group, or 0
+// when the group is not limited.
+func GetRealtimeMaxConnections(group string) int {
+	limit := realtimeConnectionSetting.GroupMaxConnections[group]
+	if limit < 0 {
+		return 0
+	}
+	return limit
+}
@@ -0,0 +1,67 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+const (
+	// RefusalBillingScopeTotal 按倍率调整整笔请求的额度，包括按次计费的模型
+	RefusalBillingScopeTotal = "total"
+	// RefusalBillingScopeCompletion 只调整补全部分，提示词照常计费
+	RefusalBillingScopeCompletion = "completion"
+)
+
+// RefusalSetting 识别模型拒绝回答的响应，并按分组调整计费
+type RefusalSetting struct {
+	Enabled bool `json:"enabled"`
+	// 补全 token 数不超过该值且内容以 Patterns 之一开头时判定为拒绝
+	MaxCompletionTokens int `json:"max_completion_tokens"`
+	// 拒绝话术片段，不区分大小写
+	Patterns []string `json:"patterns"`
+	// 分组 -> 拒绝回答的计费倍率，0 表示不计费，未配置的分组照常计费
+	GroupBillingRatio map[string]float64 `json:"group_billing_ratio"`
+	// 倍率的作用范围，total 或 completion，留空按 total 处理
+	BillingScope string `json:"billing_scope"`
+}
+
+// 默认配置
+var refusalSetting = RefusalSetting{
+	Enabled:             false,
+	MaxCompletionTokens: 64,
+	Patterns: []string{
+		"I'm sorry, but I can't",
+		"I'm sorry, but I cannot",
+		"I can't help with that",
+		"I can't assist with that",
+		"I cannot help with that",
+		"I cannot assist with that",
+		"抱歉，我无法",
+		"抱歉，我不能",
+	},
+	GroupBillingRatio: map[string]float64{},
+	BillingScope:      RefusalBillingScopeTotal,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("refusal_setting", &refusalSetting)
+}
+
+func GetRefusalSetting() *RefusalSetting {
+	return &refusalSetting
+}
+
+// GetRefusalBillingScope 返回拒绝回答计费倍率的作用范围
+func GetRefusalBillingScope() string {
+	if refusalSetting.BillingScope == RefusalBillingScopeCompletion {
+		return RefusalBillingScopeCompletion
+	}
+	return RefusalBillingScopeTotal
+}
+
+// GetRefusalBillingRatio 返回分组拒绝回答时的计费倍率，未配置时返回 false
+func GetRefusalBillingRatio(group string) (float64, bool) {
+	ratio, ok := refusalSetting.GroupBillingRatio[group]
+	if !ok || ratio < 0 {
+		return 0, false
+	}
+	return ratio, true
+}
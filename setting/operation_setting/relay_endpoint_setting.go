@@ -0,0 +1,50 @@
+package operation_setting
+
+import (
+	"net/http"
+	"one-api/setting/config"
+)
+
+// RelayEndpointSetting 限制可用的中继能力，未开启时所有能力均可用
+type RelayEndpointSetting struct {
+	Enabled bool `json:"enabled"`
+	// 允许的能力，例如 chat、embeddings、images、audio、realtime、midjourney
+	EnabledCapabilities []string `json:"enabled_capabilities"`
+	// 能力被禁用时返回的状态码，仅支持 403 或 404
+	DisabledStatusCode int `json:"disabled_status_code"`
+}
+
+// 默认配置
+var relayEndpointSetting = RelayEndpointSetting{
+	Enabled:             false,
+	EnabledCapabilities: []string{},
+	DisabledStatusCode:  http.StatusNotFound,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("relay_endpoint_setting", &relayEndpointSetting)
+}
+
+func GetRelayEndpointSetting() *RelayEndpointSetting {
+	return &relayEndpointSetting
+}
+
+func IsRelayCapabilityEnabled(capability string) bool {
+	if !relayEndpointSetting.Enabled || capability == "" {
+		return true
+	}
+	for _, enabled := range relayEndpointSetting.EnabledCapabilities {
+		if enabled == capability {
+			return true
+		}
+	}
+	return false
+}
+
+func GetRelayDisabledStatusCode() int {
+	if relayEndpointSetting.DisabledStatusCode == http.StatusForbidden {
+		return http.StatusForbidden
+	}
+	return http.StatusNotFound
+}
@@ -0,0 +1,28 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// RequestDedupSetting 合并并发的相同非流式上游请求：请求地址与请求体完全相同时只发起一次上游调用，结果共享给所有等待的请求。
+// 只合并结果确定的请求，即显式设置 temperature 为 0 且 n 不大于 1 的请求
+type RequestDedupSetting struct {
+	Enabled bool `json:"enabled"`
+	// 复用响应的请求是否照常计费
+	BillSharedResponses bool `json:"bill_shared_responses"`
+	// 共享的上游调用的超时秒数，与发起请求的客户端是否断开无关
+	TimeoutSeconds int `json:"timeout_seconds"`
+}
+
+// 默认配置
+var requestDedupSetting = RequestDedupSetting{
+	BillSharedResponses: true,
+	TimeoutSeconds:      300,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("request_dedup_setting", &requestDedupSetting)
+}
+
+func GetRequestDedupSetting() *RequestDedupSetting {
+	return &requestDedupSetting
+}
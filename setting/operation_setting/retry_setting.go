@@ -0,0 +1,74 @@
+package operation_setting
+
+import (
+	"one-api/setting/config"
+	"strings"
+)
+
+// RetryRule 根据上游错误的 code/type/message 强制重试或强制不重试，优先于状态码判断
+type RetryRule struct {
+	// 适用的渠道类型，为空时对所有渠道类型生效
+	ChannelTypes []int  `json:"channel_types"`
+	Code         string `json:"code"`
+	Type         string `json:"type"`
+	// 错误信息包含该字符串时匹配（不区分大小写）
+	MessageContains string `json:"message_contains"`
+	Retry           bool   `json:"retry"`
+}
+
+type RetrySetting struct {
+	Rules []RetryRule `json:"rules"`
+	// 非流式响应没有任何内容且补全 token 为 0 时视为上游临时故障，换渠道重试
+	RetryOnEmptyResponse bool `json:"retry_on_empty_response"`
+	// 流式请求在该时间（毫秒）内未收到上游首个字节且尚未向客户端写入任何内容时，取消请求并换渠道重试，0 表示不限制
+	StreamFirstTokenTimeoutMs int `json:"stream_first_token_timeout_ms"`
+}
+
+// 默认配置
+var retrySetting = RetrySetting{
+	Rules: []RetryRule{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("retry_setting", &retrySetting)
+}
+
+func GetRetrySetting() *RetrySetting {
+	return &retrySetting
+}
+
+// MatchRetryRule returns the retry decision of the first rule matching the
+// upstream error. Empty rule fields match anything, but a rule must set at
+// least one of code, type or message_contains.
+func MatchRetryRule(channelType int, code string, errType string, message string) (retry bool, matched bool) {
+	lowerMessage := strings.ToLower(message)
+	for _, rule := range retrySetting.Rules {
+		if rule.Code == "" && rule.Type == "" && rule.MessageContains == "" {
+			continue
+		}
+		if len(rule.ChannelTypes) > 0 && !containsInt(rule.ChannelTypes, channelType) {
+			continue
+		}
+		if rule.Code != "" && rule.Code != code {
+			continue
+		}
+		if rule.Type != "" && rule.Type != errType {
+			continue
+		}
+		if rule.MessageContains != "" && !strings.Contains(lowerMessage, strings.ToLower(rule.MessageContains)) {
+			continue
+		}
+		return rule.Retry, true
+	}
+	return false, false
+}
+
+func containsInt(values []int, target int) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// StopSequenceSetting 按分组/模型强制加入的 stop 序列，与客户端的 stop 合并去重，仅作用于 chat completions 与 completions 请求。
+// 超出上游允许的 stop 数量时优先保留强制加入的序列。开启请求透传时请求体不会被修改，不生效。
+type StopSequenceSetting struct {
+	// 分组 -> 强制加入的 stop 序列
+	GroupStops map[string][]string `json:"group_stops"`
+	// 模型 -> 强制加入的 stop 序列
+	ModelStops map[string][]string `json:"model_stops"`
+	// 渠道类型 -> 上游允许的最大 stop 数量，覆盖内置默认值，0 表示不限制
+	ChannelTypeMaxStops map[int]int `json:"channel_type_max_stops"`
+}
+
+// 默认配置
+var stopSequenceSetting = StopSequenceSetting{
+	GroupStops:          map[string][]string{},
+	ModelStops:          map[string][]string{},
+	ChannelTypeMaxStops: map[int]int{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("stop_sequence_setting", &stopSequenceSetting)
+}
+
+func GetStopSequenceSetting() *StopSequenceSetting {
+	return &stopSequenceSetting
+}
+
+// GetInjectedStopSequences 返回分组与模型配置的 stop 序列，分组在前
+func GetInjectedStopSequences(group string, model string) []string {
+	groupStops := stopSequenceSetting.GroupStops[group]
+	modelStops := stopSequenceSetting.ModelStops[model]
+	if len(groupStops) == 0 && len(modelStops) == 0 {
+		return nil
+	}
+	stops := make([]string, 0, len(groupStops)+len(modelStops))
+	stops = append(stops, groupStops...)
+	return append(stops, modelStops...)
+}
+
+// GetChannelTypeMaxStops 返回配置的渠道类型最大 stop 数量，未配置时返回 false
+func GetChannelTypeMaxStops(channelType int) (int, bool) {
+	limit, ok := stopSequenceSetting.ChannelTypeMaxStops[channelType]
+	return limit, ok
+}
@@ -0,0 +1,50 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+const (
+	StreamPolicyAllow    = "allow"    // 按客户端请求决定是否流式
+	StreamPolicyDisallow = "disallow" // 不允许流式，流式请求收到聚合后的非流式响应
+	StreamPolicyForce    = "force"    // 必须流式，拒绝非流式请求
+)
+
+// StreamPolicySetting 按分组限制客户端的流式模式，用户设置中的 stream_policy 优先
+type StreamPolicySetting struct {
+	// 分组 -> allow | disallow | force
+	Groups map[string]string `json:"groups"`
+}
+
+// 默认配置
+var streamPolicySetting = StreamPolicySetting{
+	Groups: map[string]string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("stream_policy_setting", &streamPolicySetting)
+}
+
+func GetStreamPolicySetting() *StreamPolicySetting {
+	return &streamPolicySetting
+}
+
+// IsValidStreamPolicy reports whether policy is one of the known policies.
+func IsValidStreamPolicy(policy string) bool {
+	switch policy {
+	case StreamPolicyAllow, StreamPolicyDisallow, StreamPolicyForce:
+		return true
+	}
+	return false
+}
+
+// GetStreamPolicy returns the streaming policy of a user, falling back to
+// the policy of the group. Unset or unknown policies allow both modes.
+func GetStreamPolicy(userPolicy string, group string) string {
+	if IsValidStreamPolicy(userPolicy) {
+		return userPolicy
+	}
+	if policy := streamPolicySetting.Groups[group]; IsValidStreamPolicy(policy) {
+		return policy
+	}
+	return StreamPolicyAllow
+}
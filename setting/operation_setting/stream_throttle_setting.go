@@ -0,0 +1,35 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// StreamThrottleSetting 流式输出限速：按每秒 token 数匀速下发 SSE 数据块，上游数据先全部缓冲，不延长上游连接占用时间。
+// 客户端通过 X-Stream-Tokens-Per-Second 请求头指定速率，未指定时使用 DefaultTokensPerSecond
+type StreamThrottleSetting struct {
+	Enabled bool `json:"enabled"`
+	// 未带请求头时的默认速率，0 表示不限速
+	DefaultTokensPerSecond int `json:"default_tokens_per_second"`
+	// 速率上限，请求头中更大的值会被截断到该值
+	MaxTokensPerSecond int `json:"max_tokens_per_second"`
+	// 速率下限，请求头中更小的正值会被提高到该值
+	MinTokensPerSecond int `json:"min_tokens_per_second"`
+	// 单个请求因限速累计等待的最长秒数，超出后剩余数据不再限速，0 表示不限制
+	MaxDelaySeconds int `json:"max_delay_seconds"`
+}
+
+// 默认配置
+var streamThrottleSetting = StreamThrottleSetting{
+	Enabled:                false,
+	DefaultTokensPerSecond: 0,
+	MaxTokensPerSecond:     200,
+	MinTokensPerSecond:     10,
+	MaxDelaySeconds:        120,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("stream_throttle_setting", &streamThrottleSetting)
+}
+
+func GetStreamThrottleSetting() *StreamThrottleSetting {
+	return &streamThrottleSetting
+}
@@ -0,0 +1,36 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+const (
+	TokenFingerprintActionFlag  = "flag"  // 超过阈值时仅记录事件
+	TokenFingerprintActionBlock = "block" // 超过阈值时记录事件并拒绝新的请求来源
+)
+
+// TokenFingerprintSetting 统计每个令牌在滚动窗口内的不同 (IP, User-Agent) 组合数，用于发现共享或泄露的令牌
+type TokenFingerprintSetting struct {
+	Enabled bool `json:"enabled"`
+	// 滚动窗口（秒）
+	WindowSeconds int `json:"window_seconds"`
+	// 窗口内允许的不同指纹数，超过时触发
+	MaxFingerprints int `json:"max_fingerprints"`
+	// flag 或 block
+	Action string `json:"action"`
+}
+
+// 默认配置
+var tokenFingerprintSetting = TokenFingerprintSetting{
+	Enabled:         false,
+	WindowSeconds:   3600,
+	MaxFingerprints: 20,
+	Action:          TokenFingerprintActionFlag,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("token_fingerprint_setting", &tokenFingerprintSetting)
+}
+
+func GetTokenFingerprintSetting() *TokenFingerprintSetting {
+	return &tokenFingerprintSetting
+}
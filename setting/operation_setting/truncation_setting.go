@@ -0,0 +1,49 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// TruncationSetting 截断响应检测：finish_reason 为 length 或补全 token 过少时在日志中标记
+type TruncationSetting struct {
+	// 补全 token 少于该值视为截断，0 表示不按长度判断
+	MinCompletionTokens int `json:"min_completion_tokens"`
+	// 非流式请求因 length 截断时，调大 max_tokens 换渠道重试一次
+	RetryEnabled bool `json:"retry_enabled"`
+	// 重试时 max_tokens 相对原值（未设置时为本次补全 token 数）的倍数
+	RetryMaxTokensMultiplier float64 `json:"retry_max_tokens_multiplier"`
+	// 重试时 max_tokens 的上限，0 表示不限制
+	RetryMaxTokensCap int `json:"retry_max_tokens_cap"`
+}
+
+// 默认配置
+var truncationSetting = TruncationSetting{
+	MinCompletionTokens:      0,
+	RetryEnabled:             false,
+	RetryMaxTokensMultiplier: 2,
+	RetryMaxTokensCap:        0,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("truncation_setting", &truncationSetting)
+}
+
+func GetTruncationSetting() *TruncationSetting {
+	return &truncationSetting
+}
+
+// GetTruncationRetryMaxTokens 计算截断重试时使用的 max_tokens
+func GetTruncationRetryMaxTokens(maxTokens int, completionTokens int) int {
+	base := maxTokens
+	if base < completionTokens {
+		base = completionTokens
+	}
+	multiplier := truncationSetting.RetryMaxTokensMultiplier
+	if multiplier <= 1 {
+		multiplier = 2
+	}
+	next := int(float64(base) * multiplier)
+	if truncationSetting.RetryMaxTokensCap > 0 && next > truncationSetting.RetryMaxTokensCap {
+		next = truncationSetting.RetryMaxTokensCap
+	}
+	return next
+}
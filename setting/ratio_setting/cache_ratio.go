@@ -64,6 +64,7 @@ var cacheRatioMapMutex sync.RWMutex
 
 // GetCacheRatioMap returns the cache ratio map
 func GetCacheRatioMap() map[string]float64 {
+	defer rlockRatios()()
 	cacheRatioMapMutex.RLock()
 	defer cacheRatioMapMutex.RUnlock()
 	return cacheRatioMap
@@ -71,6 +72,7 @@ func GetCacheRatioMap() map[string]float64 {
 
 // CacheRatio2JSONString converts the cache ratio map to a JSON string
 func CacheRatio2JSONString() string {
+	defer rlockRatios()()
 	cacheRatioMapMutex.RLock()
 	defer cacheRatioMapMutex.RUnlock()
 	jsonBytes, err := json.Marshal(cacheRatioMap)
@@ -94,6 +96,7 @@ func UpdateCacheRatioByJSONString(jsonStr string) error {
 
 // GetCacheRatio returns the cache ratio for a model
 func GetCacheRatio(name string) (float64, bool) {
+	defer rlockRatios()()
 	cacheRatioMapMutex.RLock()
 	defer cacheRatioMapMutex.RUnlock()
 	ratio, ok := cacheRatioMap[name]
@@ -112,6 +115,7 @@ func GetCreateCacheRatio(name string) (float64, bool) {
 }
 
 func GetCacheRatioCopy() map[string]float64 {
+	defer rlockRatios()()
 	cacheRatioMapMutex.RLock()
 	defer cacheRatioMapMutex.RUnlock()
 	copyMap := make(map[string]float64, len(cacheRatioMap))
@@ -302,12 +302,14 @@ func InitRatioSettings() {
 }
 
 func GetModelPriceMap() map[string]float64 {
+	defer rlockRatios()()
 	modelPriceMapMutex.RLock()
 	defer modelPriceMapMutex.RUnlock()
 	return modelPriceMap
 }
 
 func ModelPrice2JSONString() string {
+	defer rlockRatios()()
 	modelPriceMapMutex.RLock()
 	defer modelPriceMapMutex.RUnlock()
 
@@ -331,6 +333,7 @@ func UpdateModelPriceByJSONString(jsonStr string) error {
 
 // GetModelPrice 返回模型的价格，如果模型不存在则返回-1，false
 func GetModelPrice(name string, printErr bool) (float64, bool) {
+	defer rlockRatios()()
 	modelPriceMapMutex.RLock()
 	defer modelPriceMapMutex.RUnlock()
 
@@ -370,6 +373,7 @@ func handleThinkingBudgetModel(name, prefix, wildcard string) string {
 }
 
 func GetModelRatio(name string) (float64, bool) {
+	defer rlockRatios()()
 	modelRatioMapMutex.RLock()
 	defer modelRatioMapMutex.RUnlock()
 
@@ -404,12 +408,14 @@ func GetDefaultModelRatioMap() map[string]float64 {
 }
 
 func GetCompletionRatioMap() map[string]float64 {
+	defer rlockRatios()()
 	CompletionRatioMutex.RLock()
 	defer CompletionRatioMutex.RUnlock()
 	return CompletionRatio
 }
 
 func CompletionRatio2JSONString() string {
+	defer rlockRatios()()
 	CompletionRatioMutex.RLock()
 	defer CompletionRatioMutex.RUnlock()
 
@@ -432,6 +438,7 @@ func UpdateCompletionRatioByJSONString(jsonStr string) error {
 }
 
 func GetCompletionRatio(name string) float64 {
+	defer rlockRatios()()
 	CompletionRatioMutex.RLock()
 	defer CompletionRatioMutex.RUnlock()
 	if strings.HasPrefix(name, "gpt-4-gizmo") {
@@ -597,6 +604,7 @@ func GetAudioCompletionRatio(name string) float64 {
 }
 
 func ModelRatio2JSONString() string {
+	defer rlockRatios()()
 	modelRatioMapMutex.RLock()
 	defer modelRatioMapMutex.RUnlock()
 
@@ -614,6 +622,7 @@ var imageRatioMap map[string]float64
 var imageRatioMapMutex sync.RWMutex
 
 func ImageRatio2JSONString() string {
+	defer rlockRatios()()
 	imageRatioMapMutex.RLock()
 	defer imageRatioMapMutex.RUnlock()
 	jsonBytes, err := json.Marshal(imageRatioMap)
@@ -631,6 +640,7 @@ func UpdateImageRatioByJSONString(jsonStr string) error {
 }
 
 func GetImageRatio(name string) (float64, bool) {
+	defer rlockRatios()()
 	imageRatioMapMutex.RLock()
 	defer imageRatioMapMutex.RUnlock()
 	ratio, ok := imageRatioMap[name]
@@ -641,6 +651,7 @@ func GetImageRatio(name string) (float64, bool) {
 }
 
 func GetModelRatioCopy() map[string]float64 {
+	defer rlockRatios()()
 	modelRatioMapMutex.RLock()
 	defer modelRatioMapMutex.RUnlock()
 	copyMap := make(map[string]float64, len(modelRatioMap))
@@ -651,6 +662,7 @@ func GetModelRatioCopy() map[string]float64 {
 }
 
 func GetModelPriceCopy() map[string]float64 {
+	defer rlockRatios()()
 	modelPriceMapMutex.RLock()
 	defer modelPriceMapMutex.RUnlock()
 	copyMap := make(map[string]float64, len(modelPriceMap))
@@ -661,6 +673,7 @@ func GetModelPriceCopy() map[string]float64 {
 }
 
 func GetCompletionRatioCopy() map[string]float64 {
+	defer rlockRatios()()
 	CompletionRatioMutex.RLock()
 	defer CompletionRatioMutex.RUnlock()
 	copyMap := make(map[string]float64, len(CompletionRatio))
@@ -0,0 +1,25 @@
+package ratio_setting
+
+import "sync"
+
+// 重新加载倍率时持有写锁，读取倍率时持有读锁，避免读到重置为默认值的中间状态
+var ratioReloadLock sync.RWMutex
+
+// rlockRatios blocks while ratios are being reloaded and returns the
+// matching unlock function. Every reader of the ratios reset by
+// InitRatioSettings takes it; the setters do not, as the reload calls them
+// while holding the write lock.
+func rlockRatios() func() {
+	ratioReloadLock.RLock()
+	return ratioReloadLock.RUnlock
+}
+
+// ReloadRatioSettings resets every ratio to its default and then runs load,
+// which is expected to re-apply the stored options, as one step for the
+// readers. load must only call the ratio setters.
+func ReloadRatioSettings(load func()) {
+	ratioReloadLock.Lock()
+	defer ratioReloadLock.Unlock()
+	InitRatioSettings()
+	load()
+}
@@ -0,0 +1,40 @@
+package ratio_setting
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestReloadRatioSettingsHidesDefaults(t *testing.T) {
+	load := func() {
+		if err := UpdateModelRatioByJSONString(`{"reload-test-model":5}`); err != nil {
+			t.Error(err)
+		}
+	}
+	InitRatioSettings()
+	load()
+	t.Cleanup(InitRatioSettings)
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			ReloadRatioSettings(load)
+		}
+		close(stop)
+	}()
+	for {
+		select {
+		case <-stop:
+			wg.Wait()
+			return
+		default:
+		}
+		// 重载期间不应读到重置后的默认倍率
+		if ratio, ok := GetModelRatio("reload-test-model"); !ok || ratio != 5 {
+			t.Fatalf("GetModelRatio during reload = %v, %v, want 5, true", ratio, ok)
+		}
+	}
+}